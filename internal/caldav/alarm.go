@@ -0,0 +1,263 @@
+package caldav
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// Alarm actions
+const (
+	AlarmDisplay = "DISPLAY"
+	AlarmAudio   = "AUDIO"
+	AlarmEmail   = "EMAIL"
+)
+
+// Alarm is a VALARM reminder on an event or task.
+type Alarm struct {
+	Action      string        `json:"action"`             // DISPLAY (default), AUDIO or EMAIL
+	Before      time.Duration `json:"before,omitempty"`   // Fire this long before the start; negative is after
+	FromEnd     bool          `json:"from_end,omitempty"` // Before counts from the end (the due date for tasks)
+	At          time.Time     `json:"at,omitempty"`       // Fire at this time instead
+	Description string        `json:"description,omitempty"`
+}
+
+// Time returns when the alarm fires for an occurrence from start to end
+// (for tasks: DTSTART to DUE), or the zero time if the time it is relative
+// to is not set.
+func (a Alarm) Time(start, end time.Time) time.Time {
+	if !a.At.IsZero() {
+		return a.At
+	}
+	ref := start
+	if a.FromEnd {
+		ref = end
+	}
+	if ref.IsZero() {
+		return time.Time{}
+	}
+	return ref.Add(-a.Before)
+}
+
+// String describes the trigger, like "15m before" or "1d before due".
+func (a Alarm) String() string {
+	if !a.At.IsZero() {
+		return "at " + a.At.Format("2006-01-02 15:04 MST")
+	}
+	d, when := a.Before, "before"
+	if d < 0 {
+		d, when = -d, "after"
+	}
+	s := formatReminder(d) + " " + when
+	if d == 0 {
+		s = "at"
+	}
+	if a.FromEnd {
+		s += " end"
+	} else {
+		s += " start"
+	}
+	if a.Action != "" && a.Action != AlarmDisplay {
+		s += " (" + strings.ToLower(a.Action) + ")"
+	}
+	return s
+}
+
+// formatReminder formats a duration in the units --remind takes, like
+// "1d", "2h30m" or "1w".
+func formatReminder(d time.Duration) string {
+	if d == 0 {
+		return "0m"
+	}
+	const day = 24 * time.Hour
+	var s string
+	if d%(7*day) == 0 {
+		return fmt.Sprintf("%dw", d/(7*day))
+	}
+	if d >= day {
+		s += fmt.Sprintf("%dd", d/day)
+		d %= day
+	}
+	if d >= time.Hour {
+		s += fmt.Sprintf("%dh", d/time.Hour)
+		d %= time.Hour
+	}
+	if d >= time.Minute {
+		s += fmt.Sprintf("%dm", d/time.Minute)
+		d %= time.Minute
+	}
+	if d > 0 {
+		s += fmt.Sprintf("%ds", d/time.Second)
+	}
+	return s
+}
+
+// alarmsFromComponent parses the VALARMs of an event or task. Alarms
+// without a usable TRIGGER are skipped.
+func alarmsFromComponent(comp *ical.Component) []Alarm {
+	var alarms []Alarm
+	for _, child := range comp.Children {
+		if child.Name != ical.CompAlarm {
+			continue
+		}
+		if alarm, ok := parseAlarm(child); ok {
+			alarms = append(alarms, alarm)
+		}
+	}
+	return alarms
+}
+
+func parseAlarm(comp *ical.Component) (Alarm, bool) {
+	trigger := comp.Props.Get(ical.PropTrigger)
+	if trigger == nil {
+		return Alarm{}, false
+	}
+	alarm := Alarm{Action: strings.ToUpper(propValue(comp, ical.PropAction))}
+	if prop := comp.Props.Get(ical.PropDescription); prop != nil {
+		alarm.Description = textValue(prop)
+	}
+	if trigger.ValueType() == ical.ValueDateTime {
+		t, err := trigger.DateTime(time.UTC)
+		if err != nil {
+			return Alarm{}, false
+		}
+		alarm.At = t
+		return alarm, true
+	}
+	d, err := trigger.Duration()
+	if err != nil {
+		return Alarm{}, false
+	}
+	alarm.Before = -d
+	alarm.FromEnd = strings.EqualFold(trigger.Params.Get(ical.ParamRelated), "END")
+	return alarm, true
+}
+
+// alarmComponent builds a VALARM. summary is used as the description of
+// display and email alarms without one, which RFC 5545 requires.
+func alarmComponent(alarm Alarm, summary string) *ical.Component {
+	comp := ical.NewComponent(ical.CompAlarm)
+	action := alarm.Action
+	if action == "" {
+		action = AlarmDisplay
+	}
+	comp.Props.SetText(ical.PropAction, action)
+
+	trigger := ical.NewProp(ical.PropTrigger)
+	if !alarm.At.IsZero() {
+		trigger.SetDateTime(alarm.At.UTC())
+	} else {
+		trigger.Value = formatDuration(-alarm.Before)
+		if alarm.FromEnd {
+			trigger.Params.Set(ical.ParamRelated, "END")
+		}
+	}
+	comp.Props.Set(trigger)
+
+	description := alarm.Description
+	if description == "" {
+		description = summary
+	}
+	if description == "" {
+		description = "Reminder"
+	}
+	if action != AlarmAudio {
+		comp.Props.SetText(ical.PropDescription, description)
+	}
+	if action == AlarmEmail {
+		comp.Props.SetText(ical.PropSummary, description)
+	}
+	return comp
+}
+
+// formatDuration formats a duration as an iCalendar DURATION value, like
+// "-PT15M" or "-P1D".
+func formatDuration(d time.Duration) string {
+	var s string
+	if d < 0 {
+		s, d = "-", -d
+	}
+	const day = 24 * time.Hour
+	if d == 0 {
+		return "PT0S"
+	}
+	if d%(7*day) == 0 {
+		return fmt.Sprintf("%sP%dW", s, d/(7*day))
+	}
+	s += "P"
+	if d >= day {
+		s += fmt.Sprintf("%dD", d/day)
+		d %= day
+	}
+	if d == 0 {
+		return s
+	}
+	s += "T"
+	if d >= time.Hour {
+		s += fmt.Sprintf("%dH", d/time.Hour)
+		d %= time.Hour
+	}
+	if d >= time.Minute {
+		s += fmt.Sprintf("%dM", d/time.Minute)
+		d %= time.Minute
+	}
+	if d > 0 {
+		s += fmt.Sprintf("%dS", d/time.Second)
+	}
+	return s
+}
+
+// sameAlarm reports whether two alarms fire at the same time in the same
+// way. Descriptions are not compared, so alarms written by other clients
+// match the ones given on the command line.
+func sameAlarm(a, b Alarm) bool {
+	action := func(s string) string {
+		if s == "" {
+			return AlarmDisplay
+		}
+		return strings.ToUpper(s)
+	}
+	return action(a.Action) == action(b.Action) && a.Before == b.Before &&
+		a.FromEnd == b.FromEnd && a.At.Equal(b.At)
+}
+
+// patchAlarms replaces the VALARMs of comp if the alarms changed. VALARMs
+// that stay keep all their properties (ACKNOWLEDGED, X- properties, ...).
+func patchAlarms(comp *ical.Component, old, updated []Alarm, summary string) bool {
+	if len(old) == len(updated) {
+		same := true
+		for i := range old {
+			same = same && sameAlarm(old[i], updated[i])
+		}
+		if same {
+			return false
+		}
+	}
+
+	wanted := append([]Alarm(nil), updated...)
+	var children []*ical.Component
+	for _, child := range comp.Children {
+		if child.Name != ical.CompAlarm {
+			children = append(children, child)
+			continue
+		}
+		alarm, ok := parseAlarm(child)
+		if !ok {
+			continue
+		}
+		for i, w := range wanted {
+			if sameAlarm(alarm, w) {
+				children = append(children, child)
+				wanted = append(wanted[:i], wanted[i+1:]...)
+				break
+			}
+		}
+	}
+	for _, alarm := range wanted {
+		children = append(children, alarmComponent(alarm, summary))
+	}
+	comp.Children = children
+	return true
+}
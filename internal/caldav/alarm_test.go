@@ -0,0 +1,126 @@
+package caldav
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const alarmTask = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Other//Client//EN
+BEGIN:VTODO
+UID:task@example.com
+DTSTAMP:20260101T000000Z
+SUMMARY:File taxes
+DUE:20260415T170000Z
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER;RELATED=END:-P1D
+DESCRIPTION:Taxes due tomorrow
+ACKNOWLEDGED:20260414T170100Z
+END:VALARM
+BEGIN:VALARM
+ACTION:AUDIO
+TRIGGER;VALUE=DATE-TIME:20260415T080000Z
+END:VALARM
+END:VTODO
+END:VCALENDAR
+`
+
+func TestAlarmsFromComponent(t *testing.T) {
+	cal := decodeCalendar(t, alarmTask)
+	task, err := parseICalTask(cal)
+	require.NoError(t, err)
+
+	require.Len(t, task.Alarms, 2)
+	assert.Equal(t, Alarm{Action: AlarmDisplay, Before: 24 * time.Hour, FromEnd: true, Description: "Taxes due tomorrow"}, task.Alarms[0])
+	assert.Equal(t, "1d before end", task.Alarms[0].String())
+	assert.Equal(t, time.Date(2026, 4, 14, 17, 0, 0, 0, time.UTC), task.Alarms[0].Time(task.Start, task.Due).UTC())
+	assert.Equal(t, time.Date(2026, 4, 15, 8, 0, 0, 0, time.UTC), task.Alarms[1].Time(task.Start, task.Due))
+
+	// Relative to an unset start
+	assert.True(t, Alarm{Before: time.Hour}.Time(time.Time{}, task.Due).IsZero())
+}
+
+func TestEventAlarms(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	event := &Event{
+		UID:     "alarm@example.com",
+		Summary: "Standup",
+		Start:   start,
+		End:     start.Add(15 * time.Minute),
+		Alarms:  []Alarm{{Before: 15 * time.Minute}, {Before: 24 * time.Hour}},
+	}
+	comp := eventComponent(event)
+	require.Len(t, comp.Children, 2)
+	alarm := comp.Children[0]
+	assert.Equal(t, ical.CompAlarm, alarm.Name)
+	assert.Equal(t, "DISPLAY", propValue(alarm, ical.PropAction))
+	assert.Equal(t, "-PT15M", propValue(alarm, ical.PropTrigger))
+	assert.Equal(t, "Standup", propValue(alarm, ical.PropDescription))
+	assert.Equal(t, "-P1D", propValue(comp.Children[1], ical.PropTrigger))
+
+	parsed := eventFromComponent(comp)
+	require.Len(t, parsed.Alarms, 2)
+	assert.Equal(t, start.Add(-15*time.Minute), parsed.Alarms[0].Time(parsed.Start, parsed.End))
+	assert.Equal(t, "15m before start", parsed.Alarms[0].String())
+}
+
+func TestPatchAlarms(t *testing.T) {
+	cal := decodeCalendar(t, alarmTask)
+	comp := cal.Children[0]
+	old := taskFromComponent(comp)
+
+	// Keep the first alarm, drop the second, add one
+	updated := *old
+	updated.Alarms = []Alarm{{Before: 24 * time.Hour, FromEnd: true}, {Before: time.Hour, FromEnd: true}}
+	require.True(t, patchTask(comp, old, &updated))
+
+	require.Len(t, comp.Children, 2)
+	assert.Equal(t, "20260414T170100Z", propValue(comp.Children[0], "ACKNOWLEDGED"))
+	assert.Equal(t, "-PT1H", propValue(comp.Children[1], ical.PropTrigger))
+	assert.Equal(t, "END", comp.Children[1].Props.Get(ical.PropTrigger).Params.Get(ical.ParamRelated))
+
+	// No change
+	again := taskFromComponent(comp)
+	assert.False(t, patchAlarms(comp, again.Alarms, updated.Alarms, updated.Summary))
+
+	// Removing all alarms
+	require.True(t, patchAlarms(comp, again.Alarms, nil, ""))
+	assert.Empty(t, comp.Children)
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "-PT15M", formatDuration(-15*time.Minute))
+	assert.Equal(t, "-P1W", formatDuration(-7*24*time.Hour))
+	assert.Equal(t, "P1DT2H30M", formatDuration(26*time.Hour+30*time.Minute))
+	assert.Equal(t, "PT0S", formatDuration(0))
+	assert.Equal(t, "1d2h", formatReminder(26*time.Hour))
+	assert.Equal(t, "2w", formatReminder(14*24*time.Hour))
+}
+
+func TestCompleteTaskKeepsAlarms(t *testing.T) {
+	server := &schedulingServer{calendar: alarmTask}
+	client := newSchedulingClient(t, server)
+	ctx := context.Background()
+
+	tasks, err := client.ListTasks(ctx, "/cal", true)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Len(t, tasks[0].Alarms, 2)
+
+	task, err := client.CompleteTask(ctx, "/cal", "task@example.com")
+	require.NoError(t, err)
+	assert.Len(t, task.Alarms, 2)
+
+	data := server.puts["/cal/meeting.ics"]
+	assert.Contains(t, data, "STATUS:COMPLETED")
+	assert.Equal(t, 2, strings.Count(data, "BEGIN:VALARM"))
+	assert.Contains(t, data, "ACKNOWLEDGED:20260414T170100Z")
+}
@@ -0,0 +1,74 @@
+package caldav
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/visionik/sogcli/internal/davsync"
+	"github.com/visionik/sogcli/internal/timezone"
+)
+
+// Syncer returns a syncer for keeping local copies of the server's
+// calendars.
+func (c *Client) Syncer() *davsync.Syncer {
+	return &davsync.Syncer{HTTP: c.http, BaseURL: c.calURL, Kind: davsync.CalDAV}
+}
+
+// CachedEvents returns the occurrences of the events in a cached calendar
+// that overlap [start, end), like ListEvents.
+func CachedEvents(col *davsync.Collection, start, end time.Time) ([]Event, error) {
+	var events []Event
+	for _, href := range col.Hrefs() {
+		obj := col.Objects[href]
+		cal, err := decodeCached(obj.Data)
+		if err != nil {
+			continue // Skip malformed events
+		}
+		occurrences, err := ExpandEvents(cal, start, end)
+		if err != nil {
+			continue
+		}
+		for _, event := range occurrences {
+			event.ETag = obj.ETag
+			event.Path = href
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events, nil
+}
+
+// CachedTasks returns the tasks in a cached task list, like ListTasks.
+func CachedTasks(col *davsync.Collection, includeCompleted bool) ([]Task, error) {
+	var tasks []Task
+	for _, href := range col.Hrefs() {
+		obj := col.Objects[href]
+		cal, err := decodeCached(obj.Data)
+		if err != nil {
+			continue
+		}
+		task, err := parseICalTask(cal)
+		if err != nil {
+			continue
+		}
+		if !includeCompleted && task.Status == TaskStatusCompleted {
+			continue
+		}
+		task.ETag = obj.ETag
+		task.Path = href
+		tasks = append(tasks, *task)
+	}
+	return tasks, nil
+}
+
+func decodeCached(data string) (*ical.Calendar, error) {
+	cal, err := ical.NewDecoder(strings.NewReader(data)).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cached object: %w", err)
+	}
+	timezone.Register(cal)
+	return cal, nil
+}
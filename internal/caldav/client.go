@@ -3,22 +3,31 @@ package caldav
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/emersion/go-ical"
 	"github.com/emersion/go-webdav"
 	"github.com/emersion/go-webdav/caldav"
+	"github.com/visionik/sogcli/internal/dav"
+	"github.com/visionik/sogcli/internal/timezone"
 )
 
 // Client wraps a CalDAV client with convenience methods.
 type Client struct {
 	client   *caldav.Client
+	http     webdav.HTTPClient
 	email    string
 	calURL   string
 	calendar *caldav.Calendar
+
+	taskRecurrence string      // TaskRecurrenceRoll or TaskRecurrenceNewInstance
+	scheduling     *Scheduling // Cached by Scheduling
 }
 
 // Config holds CalDAV connection configuration.
@@ -26,6 +35,10 @@ type Config struct {
 	URL      string // CalDAV server URL
 	Email    string // Account email (for auth)
 	Password string // Account password
+
+	// TaskRecurrence is how completing a recurring task is recorded:
+	// TaskRecurrenceRoll (default) or TaskRecurrenceNewInstance.
+	TaskRecurrence string
 }
 
 // Task represents a VTODO task.
@@ -40,7 +53,13 @@ type Task struct {
 	Priority    int       `json:"priority,omitempty"` // 1-9, 1=highest
 	Percent     int       `json:"percent,omitempty"`  // 0-100
 	Categories  []string  `json:"categories,omitempty"`
+	RRule       string    `json:"rrule,omitempty"` // Recurrence rule, anchored at Start or else Due
+	Alarms      []Alarm   `json:"alarms,omitempty"`
+	Parent      string    `json:"parent,omitempty"`     // UID of the parent task
+	Children    []string  `json:"children,omitempty"`   // UIDs of sub-tasks listed by this task
+	DependsOn   []string  `json:"depends_on,omitempty"` // UIDs of tasks that must be finished first
 	ETag        string    `json:"etag,omitempty"`
+	Path        string    `json:"path,omitempty"` // Object path on the server
 }
 
 // TaskStatus constants
@@ -62,22 +81,53 @@ type Event struct {
 	AllDay      bool      `json:"all_day,omitempty"`
 	Organizer   string    `json:"organizer,omitempty"`
 	Attendees   []string  `json:"attendees,omitempty"`
+	PartStat    PartStats `json:"partstat,omitempty"`
+	Sequence    int       `json:"sequence,omitempty"`
 	Status      string    `json:"status,omitempty"`
+	Transparent bool      `json:"transparent,omitempty"` // Does not block time (TRANSP:TRANSPARENT)
 	URL         string    `json:"url,omitempty"`
+	Alarms      []Alarm   `json:"alarms,omitempty"`
 	ETag        string    `json:"etag,omitempty"`
+	Path        string    `json:"path,omitempty"`     // Object path on the server
+	Source      string    `json:"source,omitempty"`   // Subscription or, in merged agendas, calendar the event is shown under
+	Calendar    string    `json:"calendar,omitempty"` // Path of the CalDAV calendar the event was listed from
+	Account     string    `json:"account,omitempty"`  // Account of that calendar
+
+	// Recurrence
+	RRule        string      `json:"rrule,omitempty"` // RRULE value, e.g. FREQ=WEEKLY;BYDAY=MO
+	RDates       []time.Time `json:"rdates,omitempty"`
+	ExDates      []time.Time `json:"exdates,omitempty"`
+	RecurrenceID time.Time   `json:"recurrence_id,omitempty"` // Original start of an occurrence
+}
+
+// PartStats maps attendee addresses, in lower case, to their participation
+// status (PARTSTAT).
+type PartStats map[string]string
+
+// Get returns the participation status of an attendee, or "".
+func (p PartStats) Get(address string) string {
+	return p[strings.ToLower(address)]
 }
 
 // Calendar represents a calendar.
 type Calendar struct {
-	Path        string `json:"path"`
-	Name        string `json:"name"`
-	Description string `json:"description,omitempty"`
-	Color       string `json:"color,omitempty"`
+	Path        string   `json:"path"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Color       string   `json:"color,omitempty"`      // #RRGGBB or #RRGGBBAA
+	CTag        string   `json:"ctag,omitempty"`       // Changes whenever the calendar's contents change
+	SyncToken   string   `json:"sync_token,omitempty"` // For sync-collection (RFC 6578)
+	Components  []string `json:"components,omitempty"` // VEVENT, VTODO, ...; empty means any
+	ReadOnly    bool     `json:"read_only,omitempty"`
+	Owner       string   `json:"owner,omitempty"` // Principal path of the owner
 }
 
+// ErrEventNotFound is returned when no event has the requested UID.
+var ErrEventNotFound = errors.New("event not found")
+
 // Connect establishes a connection to a CalDAV server.
 func Connect(cfg Config) (*Client, error) {
-	httpClient := webdav.HTTPClientWithBasicAuth(http.DefaultClient, cfg.Email, cfg.Password)
+	httpClient := dav.HTTPClient(webdav.HTTPClientWithBasicAuth(http.DefaultClient, cfg.Email, cfg.Password))
 
 	client, err := caldav.NewClient(httpClient, cfg.URL)
 	if err != nil {
@@ -86,8 +136,11 @@ func Connect(cfg Config) (*Client, error) {
 
 	return &Client{
 		client: client,
+		http:   httpClient,
 		email:  cfg.Email,
 		calURL: cfg.URL,
+
+		taskRecurrence: cfg.TaskRecurrence,
 	}, nil
 }
 
@@ -97,58 +150,16 @@ func (c *Client) Close() error {
 	return nil
 }
 
-// FindCalendars discovers available calendars.
-func (c *Client) FindCalendars(ctx context.Context) ([]Calendar, error) {
-	// First find the calendar home set
-	principal, err := c.client.FindCurrentUserPrincipal(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find principal: %w", err)
-	}
-
-	homeSet, err := c.client.FindCalendarHomeSet(ctx, principal)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find calendar home set: %w", err)
-	}
-
-	cals, err := c.client.FindCalendars(ctx, homeSet)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find calendars: %w", err)
-	}
-
-	result := make([]Calendar, 0, len(cals))
-	for _, cal := range cals {
-		result = append(result, Calendar{
-			Path:        cal.Path,
-			Name:        cal.Name,
-			Description: cal.Description,
-		})
-	}
-	return result, nil
-}
-
 // ListEvents retrieves events from a calendar within a time range.
+// Recurring events are returned as one event per occurrence. The server is
+// asked to expand recurrences; if it does not, they are expanded locally.
 func (c *Client) ListEvents(ctx context.Context, calPath string, start, end time.Time) ([]Event, error) {
 	query := &caldav.CalendarQuery{
 		CompRequest: caldav.CalendarCompRequest{
-			Name: "VCALENDAR",
-			Props: []string{
-				"VERSION",
-			},
-			Comps: []caldav.CalendarCompRequest{{
-				Name: "VEVENT",
-				Props: []string{
-					"UID",
-					"SUMMARY",
-					"DESCRIPTION",
-					"LOCATION",
-					"DTSTART",
-					"DTEND",
-					"ORGANIZER",
-					"ATTENDEE",
-					"STATUS",
-					"URL",
-				},
-			}},
+			Name:     "VCALENDAR",
+			AllProps: true,
+			AllComps: true,
+			Expand:   &caldav.CalendarExpandRequest{Start: start, End: end},
 		},
 		CompFilter: caldav.CompFilter{
 			Name: "VCALENDAR",
@@ -160,33 +171,79 @@ func (c *Client) ListEvents(ctx context.Context, calPath string, start, end time
 		},
 	}
 
-	objects, err := c.client.QueryCalendar(ctx, calPath, query)
+	objects, err := c.query(ctx, calPath, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query calendar: %w", err)
+		// Some servers reject <expand>; retry and expand locally
+		query.CompRequest.Expand = nil
+		objects, err = c.query(ctx, calPath, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query calendar: %w", err)
+		}
 	}
 
 	events := make([]Event, 0, len(objects))
 	for _, obj := range objects {
-		event, err := parseICalEvent(obj.Data)
+		occurrences, err := ExpandEvents(obj.Data, start, end)
 		if err != nil {
 			continue // Skip malformed events
 		}
-		event.ETag = obj.ETag
-		events = append(events, *event)
+		for _, event := range occurrences {
+			event.ETag = obj.ETag
+			event.Path = obj.Path
+			events = append(events, event)
+		}
 	}
 
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
 	return events, nil
 }
 
-// GetEvent retrieves a single event by UID.
+// GetEvent retrieves a single event by UID. For recurring events this is
+// the series (master) event.
 func (c *Client) GetEvent(ctx context.Context, calPath, uid string) (*Event, error) {
+	obj, err := c.getEventObject(ctx, calPath, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := parseICalEvent(obj.Data)
+	if err != nil {
+		return nil, err
+	}
+	event.ETag = obj.ETag
+	event.Path = obj.Path
+	return event, nil
+}
+
+// GetEventSeries retrieves an event by UID with the overrides of its
+// occurrences, which GetEvent leaves out.
+func (c *Client) GetEventSeries(ctx context.Context, calPath, uid string) (*Event, []Event, error) {
+	obj, err := c.getEventObject(ctx, calPath, uid)
+	if err != nil {
+		return nil, nil, err
+	}
+	comp := findMaster(obj.Data, uid)
+	if comp == nil {
+		return nil, nil, fmt.Errorf("event not found: %s", uid)
+	}
+	event := eventFromComponent(comp)
+	event.ETag = obj.ETag
+	event.Path = obj.Path
+	var overrides []Event
+	for _, override := range findOverrides(obj.Data, uid) {
+		overrides = append(overrides, *eventFromComponent(override))
+	}
+	return event, overrides, nil
+}
+
+// getEventObject fetches the calendar object holding the event with uid.
+func (c *Client) getEventObject(ctx context.Context, calPath, uid string) (*caldav.CalendarObject, error) {
 	// Query for the specific UID
 	query := &caldav.CalendarQuery{
 		CompRequest: caldav.CalendarCompRequest{
-			Name: "VCALENDAR",
-			Comps: []caldav.CalendarCompRequest{{
-				Name: "VEVENT",
-			}},
+			Name:     "VCALENDAR",
+			AllProps: true,
+			AllComps: true,
 		},
 		CompFilter: caldav.CompFilter{
 			Name: "VCALENDAR",
@@ -200,46 +257,78 @@ func (c *Client) GetEvent(ctx context.Context, calPath, uid string) (*Event, err
 		},
 	}
 
-	objects, err := c.client.QueryCalendar(ctx, calPath, query)
+	objects, err := c.query(ctx, calPath, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query calendar: %w", err)
 	}
 
 	if len(objects) == 0 {
-		return nil, fmt.Errorf("event not found: %s", uid)
+		return nil, fmt.Errorf("%w: %s", ErrEventNotFound, uid)
 	}
-
-	event, err := parseICalEvent(objects[0].Data)
-	if err != nil {
-		return nil, err
-	}
-	event.ETag = objects[0].ETag
-	return event, nil
+	return &objects[0], nil
 }
 
-// CreateEvent creates a new event.
+// CreateEvent creates a new event. It fails with a *dav.ConflictError if
+// an object with the same name already exists.
 func (c *Client) CreateEvent(ctx context.Context, calPath string, event *Event) error {
 	cal := createICalEvent(event)
-	_, err := c.client.PutCalendarObject(ctx, calPath+"/"+event.UID+".ics", cal)
+	obj, err := c.client.PutCalendarObject(dav.IfNoneMatch(ctx), calPath+"/"+event.UID+".ics", cal)
 	if err != nil {
 		return fmt.Errorf("failed to create event: %w", err)
 	}
+	event.Path, event.ETag = obj.Path, obj.ETag
 	return nil
 }
 
-// UpdateEvent updates an existing event.
+// UpdateEvent updates an existing event. Only changed properties are
+// written; everything else in the stored object, including overrides of
+// recurring events, alarms and time zones, is kept.
+//
+// The write is conditional on event.ETag (or, if empty, the ETag just
+// fetched): if the event changed on the server in the meantime, a
+// *dav.ConflictError is returned. On success event.ETag is updated.
+//
+// When the start of a recurring event moves, its RDATEs, EXDATEs (unless
+// event changes them) and overrides move with it, as in UpdateFuture.
 func (c *Client) UpdateEvent(ctx context.Context, calPath string, event *Event) error {
-	cal := createICalEvent(event)
-	_, err := c.client.PutCalendarObject(ctx, calPath+"/"+event.UID+".ics", cal)
+	obj, err := c.getEventObject(ctx, calPath, event.UID)
+	if err != nil {
+		return err
+	}
+	comp := findMaster(obj.Data, event.UID)
+	if comp == nil {
+		return fmt.Errorf("event not found: %s", event.UID)
+	}
+	event.Path = obj.Path
+	if event.ETag == "" {
+		event.ETag = obj.ETag
+	}
+	old := eventFromComponent(comp)
+	if sameTimes(old.RDates, event.RDates) && sameTimes(old.ExDates, event.ExDates) {
+		shiftRecurrence(event, old.Start)
+	}
+	if !patchEvent(comp, old, event) {
+		return nil
+	}
+	shiftOverrides(findOverrides(obj.Data, event.UID), comp, old.Start, event.Start)
+
+	timezone.Embed(obj.Data)
+	updated, err := c.client.PutCalendarObject(dav.IfMatch(ctx, event.ETag), obj.Path, obj.Data)
 	if err != nil {
 		return fmt.Errorf("failed to update event: %w", err)
 	}
+	event.ETag = updated.ETag
 	return nil
 }
 
-// DeleteEvent deletes an event.
+// DeleteEvent deletes an event, unless it changed on the server while
+// being looked up.
 func (c *Client) DeleteEvent(ctx context.Context, calPath, uid string) error {
-	err := c.client.RemoveAll(ctx, calPath+"/"+uid+".ics")
+	path, etag := calPath+"/"+uid+".ics", ""
+	if obj, err := c.getEventObject(ctx, calPath, uid); err == nil {
+		path, etag = obj.Path, obj.ETag
+	}
+	err := c.client.RemoveAll(dav.IfMatch(ctx, etag), path)
 	if err != nil {
 		return fmt.Errorf("failed to delete event: %w", err)
 	}
@@ -258,7 +347,13 @@ func (c *Client) ListTasks(ctx context.Context, calPath string, includeCompleted
 				Props: []string{
 					"UID", "SUMMARY", "DESCRIPTION", "DUE", "DTSTART",
 					"COMPLETED", "STATUS", "PRIORITY", "PERCENT-COMPLETE", "CATEGORIES",
+					"RELATED-TO", "RRULE",
 				},
+				// Reminders
+				Comps: []caldav.CalendarCompRequest{{
+					Name:     "VALARM",
+					AllProps: true,
+				}},
 			}},
 		},
 		CompFilter: caldav.CompFilter{
@@ -269,7 +364,7 @@ func (c *Client) ListTasks(ctx context.Context, calPath string, includeCompleted
 		},
 	}
 
-	objects, err := c.client.QueryCalendar(ctx, calPath, query)
+	objects, err := c.query(ctx, calPath, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tasks: %w", err)
 	}
@@ -285,6 +380,7 @@ func (c *Client) ListTasks(ctx context.Context, calPath string, includeCompleted
 			continue
 		}
 		task.ETag = obj.ETag
+		task.Path = obj.Path
 		tasks = append(tasks, *task)
 	}
 
@@ -298,45 +394,132 @@ func (c *Client) GetTask(ctx context.Context, calPath, uid string) (*Task, error
 	return c.findTaskByUID(ctx, calPath, uid)
 }
 
-// CreateTask creates a new task.
+// CreateTask creates a new task. It fails with a *dav.ConflictError if an
+// object with the same name already exists.
 func (c *Client) CreateTask(ctx context.Context, calPath string, task *Task) error {
 	cal := createICalTask(task)
-	_, err := c.client.PutCalendarObject(ctx, calPath+"/"+task.UID+".ics", cal)
+	obj, err := c.client.PutCalendarObject(dav.IfNoneMatch(ctx), calPath+"/"+task.UID+".ics", cal)
 	if err != nil {
 		return fmt.Errorf("failed to create task: %w", err)
 	}
+	task.Path, task.ETag = obj.Path, obj.ETag
 	return nil
 }
 
-// UpdateTask updates an existing task.
+// UpdateTask updates an existing task. Only changed properties are
+// written; everything else in the stored object is kept. Like UpdateEvent
+// the write is conditional on task.ETag.
 func (c *Client) UpdateTask(ctx context.Context, calPath string, task *Task) error {
-	cal := createICalTask(task)
-	_, err := c.client.PutCalendarObject(ctx, calPath+"/"+task.UID+".ics", cal)
+	obj, comp, err := c.getTaskObject(ctx, calPath, task.UID)
+	if err != nil {
+		return err
+	}
+	task.Path = obj.Path
+	if task.ETag == "" {
+		task.ETag = obj.ETag
+	}
+	if !patchTask(comp, taskFromComponent(comp), task) {
+		return nil
+	}
+
+	timezone.Embed(obj.Data)
+	updated, err := c.client.PutCalendarObject(dav.IfMatch(ctx, task.ETag), obj.Path, obj.Data)
 	if err != nil {
 		return fmt.Errorf("failed to update task: %w", err)
 	}
+	task.ETag = updated.ETag
 	return nil
 }
 
-// DeleteTask deletes a task.
+// getTaskObject fetches the calendar object holding the task with uid and
+// its VTODO component. It scans all tasks rather than relying on a UID
+// filter, which some servers don't support.
+func (c *Client) getTaskObject(ctx context.Context, calPath, uid string) (*caldav.CalendarObject, *ical.Component, error) {
+	query := &caldav.CalendarQuery{
+		CompRequest: caldav.CalendarCompRequest{
+			Name:     "VCALENDAR",
+			AllProps: true,
+			AllComps: true,
+		},
+		CompFilter: caldav.CompFilter{
+			Name: "VCALENDAR",
+			Comps: []caldav.CompFilter{{
+				Name: "VTODO",
+			}},
+		},
+	}
+
+	objects, err := c.query(ctx, calPath, query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query tasks: %w", err)
+	}
+	for i := range objects {
+		for _, child := range objects[i].Data.Children {
+			if child.Name == ical.CompToDo && propValue(child, ical.PropUID) == uid {
+				return &objects[i], child, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("task not found: %s", uid)
+}
+
+// DeleteTask deletes a task, unless it changed on the server while being
+// looked up.
 func (c *Client) DeleteTask(ctx context.Context, calPath, uid string) error {
-	err := c.client.RemoveAll(ctx, calPath+"/"+uid+".ics")
+	task := &Task{UID: uid, Path: calPath + "/" + uid + ".ics"}
+	if obj, _, err := c.getTaskObject(ctx, calPath, uid); err == nil {
+		task.Path, task.ETag = obj.Path, obj.ETag
+	}
+	return c.RemoveTask(ctx, task)
+}
+
+// RemoveTask deletes a task returned by ListTasks or GetTask, unless it
+// changed on the server since.
+func (c *Client) RemoveTask(ctx context.Context, task *Task) error {
+	err := c.client.RemoveAll(dav.IfMatch(ctx, task.ETag), task.Path)
 	if err != nil {
 		return fmt.Errorf("failed to delete task: %w", err)
 	}
 	return nil
 }
 
-// CompleteTask marks a task as completed.
-func (c *Client) CompleteTask(ctx context.Context, calPath, uid string) error {
+// CompleteTask marks a task as completed and returns it as stored. A
+// recurring task is instead rolled forward to its next occurrence and
+// stays open; with TaskRecurrenceNewInstance the finished occurrence is
+// also kept as a completed task of its own. A series without further
+// occurrences is completed.
+func (c *Client) CompleteTask(ctx context.Context, calPath, uid string) (*Task, error) {
 	task, err := c.findTaskByUID(ctx, calPath, uid)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	now := time.Now()
+
+	if task.IsRecurring() {
+		rolled, ok, err := nextTaskOccurrence(task)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if err := c.UpdateTask(ctx, calPath, &rolled); err != nil {
+				return nil, err
+			}
+			if c.taskRecurrence == TaskRecurrenceNewInstance {
+				if err := c.CreateTask(ctx, calPath, completedOccurrence(task, now)); err != nil {
+					return &rolled, fmt.Errorf("moved %s to its next occurrence but failed to keep the completed one: %w", uid, err)
+				}
+			}
+			return &rolled, nil
+		}
+	}
+
 	task.Status = TaskStatusCompleted
-	task.Completed = time.Now()
+	task.Completed = now
 	task.Percent = 100
-	return c.UpdateTask(ctx, calPath, task)
+	if err := c.UpdateTask(ctx, calPath, task); err != nil {
+		return nil, err
+	}
+	return task, nil
 }
 
 // UncompleteTask marks a task as not completed.
@@ -353,103 +536,158 @@ func (c *Client) UncompleteTask(ctx context.Context, calPath, uid string) error
 
 // findTaskByUID finds a task by iterating through all tasks.
 // This is a workaround for servers that don't support PropFilter well.
+// The task is read from the whole stored object, so that updating it does
+// not drop properties or alarms a narrower query would leave out.
 func (c *Client) findTaskByUID(ctx context.Context, calPath, uid string) (*Task, error) {
-	tasks, err := c.ListTasks(ctx, calPath, true)
+	obj, comp, err := c.getTaskObject(ctx, calPath, uid)
 	if err != nil {
 		return nil, err
 	}
-	for _, t := range tasks {
-		if t.UID == uid {
-			return &t, nil
-		}
+	task := taskFromComponent(comp)
+	task.ETag, task.Path = obj.ETag, obj.Path
+	return task, nil
+}
+
+// query runs a calendar query and learns the time zones of the results.
+func (c *Client) query(ctx context.Context, calPath string, query *caldav.CalendarQuery) ([]caldav.CalendarObject, error) {
+	objects, err := c.client.QueryCalendar(ctx, calPath, query)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("task not found: %s", uid)
+	for _, obj := range objects {
+		timezone.Register(obj.Data)
+	}
+	return objects, nil
 }
 
-// parseICalEvent parses an iCalendar VEVENT into an Event.
+// parseICalEvent parses an iCalendar VEVENT into an Event. If the data
+// holds a recurring event, the master VEVENT is used.
 func parseICalEvent(cal *ical.Calendar) (*Event, error) {
 	if cal == nil {
 		return nil, fmt.Errorf("nil calendar data")
 	}
 
+	var first *ical.Component
 	for _, child := range cal.Children {
 		if child.Name != ical.CompEvent {
 			continue
 		}
+		if child.Props.Get(ical.PropRecurrenceID) == nil {
+			return eventFromComponent(child), nil
+		}
+		if first == nil {
+			first = child
+		}
+	}
+	if first != nil {
+		return eventFromComponent(first), nil
+	}
 
-		event := &Event{}
+	return nil, fmt.Errorf("no VEVENT found in calendar data")
+}
 
-		// UID
-		if prop := child.Props.Get(ical.PropUID); prop != nil {
-			event.UID = prop.Value
-		}
+// eventFromComponent converts a VEVENT component into an Event.
+func eventFromComponent(child *ical.Component) *Event {
+	event := &Event{}
 
-		// Summary
-		if prop := child.Props.Get(ical.PropSummary); prop != nil {
-			event.Summary = prop.Value
-		}
+	// UID
+	if prop := child.Props.Get(ical.PropUID); prop != nil {
+		event.UID = prop.Value
+	}
 
-		// Description
-		if prop := child.Props.Get(ical.PropDescription); prop != nil {
-			event.Description = prop.Value
-		}
+	// Summary
+	if prop := child.Props.Get(ical.PropSummary); prop != nil {
+		event.Summary = textValue(prop)
+	}
 
-		// Location
-		if prop := child.Props.Get(ical.PropLocation); prop != nil {
-			event.Location = prop.Value
-		}
+	// Description
+	if prop := child.Props.Get(ical.PropDescription); prop != nil {
+		event.Description = textValue(prop)
+	}
 
-		// Organizer
-		if prop := child.Props.Get(ical.PropOrganizer); prop != nil {
-			event.Organizer = strings.TrimPrefix(prop.Value, "mailto:")
-		}
+	// Location
+	if prop := child.Props.Get(ical.PropLocation); prop != nil {
+		event.Location = textValue(prop)
+	}
 
-		// Status
-		if prop := child.Props.Get(ical.PropStatus); prop != nil {
-			event.Status = prop.Value
-		}
+	// Organizer
+	if prop := child.Props.Get(ical.PropOrganizer); prop != nil {
+		event.Organizer = calAddress(prop.Value)
+	}
 
-		// URL
-		if prop := child.Props.Get(ical.PropURL); prop != nil {
-			event.URL = prop.Value
+	// Status
+	if prop := child.Props.Get(ical.PropStatus); prop != nil {
+		event.Status = prop.Value
+	}
+
+	// Transparency
+	if prop := child.Props.Get(ical.PropTransparency); prop != nil {
+		event.Transparent = strings.EqualFold(prop.Value, "TRANSPARENT")
+	}
+
+	// URL
+	if prop := child.Props.Get(ical.PropURL); prop != nil {
+		event.URL = prop.Value
+	}
+
+	// Start time
+	if prop := child.Props.Get(ical.PropDateTimeStart); prop != nil {
+		t, err := timezone.ParseProp(prop, time.Local)
+		if err == nil {
+			event.Start = t
+		}
+		// Check for all-day event (VALUE=DATE)
+		if param := prop.Params.Get(ical.ParamValue); param == "DATE" {
+			event.AllDay = true
 		}
+	}
 
-		// Start time
-		if prop := child.Props.Get(ical.PropDateTimeStart); prop != nil {
-			t, err := prop.DateTime(time.Local)
-			if err == nil {
-				event.Start = t
-			}
-			// Check for all-day event (VALUE=DATE)
-			if param := prop.Params.Get(ical.ParamValue); param == "DATE" {
-				event.AllDay = true
-			}
+	// End time
+	if prop := child.Props.Get(ical.PropDateTimeEnd); prop != nil {
+		t, err := timezone.ParseProp(prop, time.Local)
+		if err == nil {
+			event.End = t
+		}
+	} else if prop := child.Props.Get(ical.PropDuration); prop != nil {
+		// Handle DURATION property instead of DTEND
+		dur, err := prop.Duration()
+		if err == nil && !event.Start.IsZero() {
+			event.End = event.Start.Add(dur)
 		}
+	}
 
-		// End time
-		if prop := child.Props.Get(ical.PropDateTimeEnd); prop != nil {
-			t, err := prop.DateTime(time.Local)
-			if err == nil {
-				event.End = t
-			}
-		} else if prop := child.Props.Get(ical.PropDuration); prop != nil {
-			// Handle DURATION property instead of DTEND
-			dur, err := prop.Duration()
-			if err == nil && !event.Start.IsZero() {
-				event.End = event.Start.Add(dur)
+	// Attendees
+	for _, prop := range child.Props[ical.PropAttendee] {
+		attendee := calAddress(prop.Value)
+		event.Attendees = append(event.Attendees, attendee)
+		if partstat := prop.Params.Get(ical.ParamParticipationStatus); partstat != "" {
+			if event.PartStat == nil {
+				event.PartStat = PartStats{}
 			}
+			event.PartStat[strings.ToLower(attendee)] = strings.ToUpper(partstat)
 		}
+	}
 
-		// Attendees
-		for _, prop := range child.Props[ical.PropAttendee] {
-			attendee := strings.TrimPrefix(prop.Value, "mailto:")
-			event.Attendees = append(event.Attendees, attendee)
-		}
+	if prop := child.Props.Get(ical.PropSequence); prop != nil {
+		event.Sequence, _ = prop.Int()
+	}
 
-		return event, nil
+	// Recurrence
+	if prop := child.Props.Get(ical.PropRecurrenceRule); prop != nil {
+		event.RRule = prop.Value
+	}
+	event.RDates = propTimes(child, ical.PropRecurrenceDates)
+	event.ExDates = propTimes(child, ical.PropExceptionDates)
+	if prop := child.Props.Get(ical.PropRecurrenceID); prop != nil {
+		t, err := timezone.ParseProp(prop, time.Local)
+		if err == nil {
+			event.RecurrenceID = t
+		}
 	}
 
-	return nil, fmt.Errorf("no VEVENT found in calendar data")
+	event.Alarms = alarmsFromComponent(child)
+
+	return event
 }
 
 // createICalEvent creates an iCalendar from an Event.
@@ -457,7 +695,13 @@ func createICalEvent(event *Event) *ical.Calendar {
 	cal := ical.NewCalendar()
 	cal.Props.SetText(ical.PropVersion, "2.0")
 	cal.Props.SetText(ical.PropProductID, "-//sog//CalDAV Client//EN")
+	cal.Children = append(cal.Children, eventComponent(event))
+	timezone.Embed(cal)
+	return cal
+}
 
+// eventComponent creates a VEVENT component from an Event.
+func eventComponent(event *Event) *ical.Component {
 	vevent := ical.NewComponent(ical.CompEvent)
 	vevent.Props.SetText(ical.PropUID, event.UID)
 	vevent.Props.SetText(ical.PropSummary, event.Summary)
@@ -474,6 +718,9 @@ func createICalEvent(event *Event) *ical.Calendar {
 	if event.Status != "" {
 		vevent.Props.SetText(ical.PropStatus, event.Status)
 	}
+	if event.Transparent {
+		vevent.Props.SetText(ical.PropTransparency, "TRANSPARENT")
+	}
 
 	// Start and end times
 	if event.AllDay {
@@ -486,31 +733,68 @@ func createICalEvent(event *Event) *ical.Calendar {
 		vevent.Props.Set(endProp)
 	} else {
 		startProp := ical.NewProp(ical.PropDateTimeStart)
-		startProp.SetDateTime(event.Start)
+		timezone.SetTime(startProp, event.Start)
 		vevent.Props.Set(startProp)
 
 		endProp := ical.NewProp(ical.PropDateTimeEnd)
-		endProp.SetDateTime(event.End)
+		timezone.SetTime(endProp, event.End.In(event.Start.Location()))
 		vevent.Props.Set(endProp)
 	}
 
+	// Recurrence, in the same form as DTSTART
+	startProp := vevent.Props.Get(ical.PropDateTimeStart)
+	if !event.RecurrenceID.IsZero() {
+		prop := ical.NewProp(ical.PropRecurrenceID)
+		setTimeLike(prop, event.RecurrenceID, startProp)
+		vevent.Props.Set(prop)
+	} else {
+		if event.RRule != "" {
+			prop := ical.NewProp(ical.PropRecurrenceRule)
+			prop.Value = event.RRule
+			vevent.Props.Set(prop)
+		}
+		for _, t := range event.RDates {
+			prop := ical.NewProp(ical.PropRecurrenceDates)
+			setTimeLike(prop, t, startProp)
+			vevent.Props.Add(prop)
+		}
+		for _, t := range event.ExDates {
+			prop := ical.NewProp(ical.PropExceptionDates)
+			setTimeLike(prop, t, startProp)
+			vevent.Props.Add(prop)
+		}
+	}
+
 	if event.Organizer != "" {
-		vevent.Props.SetText(ical.PropOrganizer, "mailto:"+event.Organizer)
+		prop := ical.NewProp(ical.PropOrganizer)
+		prop.Value = "mailto:" + event.Organizer
+		vevent.Props.Set(prop)
 	}
 
 	for _, attendee := range event.Attendees {
 		prop := ical.NewProp(ical.PropAttendee)
 		prop.Value = "mailto:" + attendee
+		if partstat := event.PartStat.Get(attendee); partstat != "" {
+			prop.Params.Set(ical.ParamParticipationStatus, partstat)
+		}
 		vevent.Props.Add(prop)
 	}
+	if event.Sequence > 0 {
+		prop := ical.NewProp(ical.PropSequence)
+		prop.Value = strconv.Itoa(event.Sequence)
+		vevent.Props.Set(prop)
+	}
 
 	// DTSTAMP is required
 	dtstamp := ical.NewProp(ical.PropDateTimeStamp)
 	dtstamp.SetDateTime(time.Now().UTC())
 	vevent.Props.Set(dtstamp)
 
-	cal.Children = append(cal.Children, vevent)
-	return cal
+	for _, alarm := range event.Alarms {
+		vevent.Children = append(vevent.Children, alarmComponent(alarm, event.Summary))
+	}
+
+	return vevent
 }
 
 // parseICalTask parses an iCalendar VTODO into a Task.
@@ -520,77 +804,90 @@ func parseICalTask(cal *ical.Calendar) (*Task, error) {
 	}
 
 	for _, child := range cal.Children {
-		if child.Name != ical.CompToDo {
-			continue
+		if child.Name == ical.CompToDo {
+			return taskFromComponent(child), nil
 		}
+	}
 
-		task := &Task{
-			Status: TaskStatusNeedsAction, // Default
-		}
+	return nil, fmt.Errorf("no VTODO found in calendar data")
+}
 
-		// UID
-		if prop := child.Props.Get(ical.PropUID); prop != nil {
-			task.UID = prop.Value
-		}
+// taskFromComponent converts a VTODO component into a Task.
+func taskFromComponent(child *ical.Component) *Task {
+	task := &Task{
+		Status: TaskStatusNeedsAction, // Default
+	}
 
-		// Summary
-		if prop := child.Props.Get(ical.PropSummary); prop != nil {
-			task.Summary = prop.Value
-		}
+	// UID
+	if prop := child.Props.Get(ical.PropUID); prop != nil {
+		task.UID = prop.Value
+	}
 
-		// Description
-		if prop := child.Props.Get(ical.PropDescription); prop != nil {
-			task.Description = prop.Value
-		}
+	// Summary
+	if prop := child.Props.Get(ical.PropSummary); prop != nil {
+		task.Summary = textValue(prop)
+	}
 
-		// Status
-		if prop := child.Props.Get(ical.PropStatus); prop != nil {
-			task.Status = prop.Value
-		}
+	// Description
+	if prop := child.Props.Get(ical.PropDescription); prop != nil {
+		task.Description = textValue(prop)
+	}
 
-		// Priority (1-9, 1=highest)
-		if prop := child.Props.Get(ical.PropPriority); prop != nil {
-			fmt.Sscanf(prop.Value, "%d", &task.Priority)
-		}
+	// Status
+	if prop := child.Props.Get(ical.PropStatus); prop != nil {
+		task.Status = prop.Value
+	}
 
-		// Percent complete
-		if prop := child.Props.Get(ical.PropPercentComplete); prop != nil {
-			fmt.Sscanf(prop.Value, "%d", &task.Percent)
-		}
+	// Priority (1-9, 1=highest)
+	if prop := child.Props.Get(ical.PropPriority); prop != nil {
+		fmt.Sscanf(prop.Value, "%d", &task.Priority)
+	}
 
-		// Due date
-		if prop := child.Props.Get(ical.PropDue); prop != nil {
-			t, err := prop.DateTime(time.Local)
-			if err == nil {
-				task.Due = t
-			}
+	// Percent complete
+	if prop := child.Props.Get(ical.PropPercentComplete); prop != nil {
+		fmt.Sscanf(prop.Value, "%d", &task.Percent)
+	}
+
+	// Due date
+	if prop := child.Props.Get(ical.PropDue); prop != nil {
+		t, err := timezone.ParseProp(prop, time.Local)
+		if err == nil {
+			task.Due = t
 		}
+	}
 
-		// Start date
-		if prop := child.Props.Get(ical.PropDateTimeStart); prop != nil {
-			t, err := prop.DateTime(time.Local)
-			if err == nil {
-				task.Start = t
-			}
+	// Start date
+	if prop := child.Props.Get(ical.PropDateTimeStart); prop != nil {
+		t, err := timezone.ParseProp(prop, time.Local)
+		if err == nil {
+			task.Start = t
 		}
+	}
 
-		// Completed date
-		if prop := child.Props.Get(ical.PropCompleted); prop != nil {
-			t, err := prop.DateTime(time.Local)
-			if err == nil {
-				task.Completed = t
-			}
+	// Completed date
+	if prop := child.Props.Get(ical.PropCompleted); prop != nil {
+		t, err := timezone.ParseProp(prop, time.Local)
+		if err == nil {
+			task.Completed = t
 		}
+	}
 
-		// Categories
-		if prop := child.Props.Get(ical.PropCategories); prop != nil {
-			task.Categories = strings.Split(prop.Value, ",")
+	// Categories
+	for _, prop := range child.Props[ical.PropCategories] {
+		if list, err := prop.TextList(); err == nil {
+			task.Categories = append(task.Categories, list...)
 		}
+	}
 
-		return task, nil
+	// Recurrence rule
+	if prop := child.Props.Get(ical.PropRecurrenceRule); prop != nil {
+		task.RRule = prop.Value
 	}
 
-	return nil, fmt.Errorf("no VTODO found in calendar data")
+	task.Alarms = alarmsFromComponent(child)
+	relationsFromComponent(child, task)
+
+	return task
 }
 
 // createICalTask creates an iCalendar from a Task.
@@ -626,27 +923,41 @@ func createICalTask(task *Task) *ical.Calendar {
 	// Due date
 	if !task.Due.IsZero() {
 		dueProp := ical.NewProp(ical.PropDue)
-		dueProp.SetDateTime(task.Due)
+		timezone.SetTime(dueProp, task.Due)
 		vtodo.Props.Set(dueProp)
 	}
 
 	// Start date
 	if !task.Start.IsZero() {
 		startProp := ical.NewProp(ical.PropDateTimeStart)
-		startProp.SetDateTime(task.Start)
+		timezone.SetTime(startProp, task.Start)
 		vtodo.Props.Set(startProp)
 	}
 
 	// Completed date
 	if !task.Completed.IsZero() {
 		completedProp := ical.NewProp(ical.PropCompleted)
-		completedProp.SetDateTime(task.Completed)
+		completedProp.SetDateTime(task.Completed.UTC())
 		vtodo.Props.Set(completedProp)
 	}
 
 	// Categories
 	if len(task.Categories) > 0 {
-		vtodo.Props.SetText(ical.PropCategories, strings.Join(task.Categories, ","))
+		prop := ical.NewProp(ical.PropCategories)
+		prop.SetTextList(task.Categories)
+		vtodo.Props.Set(prop)
+	}
+
+	// Recurrence rule
+	if task.RRule != "" {
+		prop := ical.NewProp(ical.PropRecurrenceRule)
+		prop.Value = task.RRule
+		vtodo.Props.Set(prop)
+	}
+
+	// Sub-tasks and dependencies
+	if props := relationProps(task); len(props) > 0 {
+		vtodo.Props[ical.PropRelatedTo] = props
 	}
 
 	// DTSTAMP is required
@@ -654,6 +965,11 @@ func createICalTask(task *Task) *ical.Calendar {
 	dtstamp.SetDateTime(time.Now().UTC())
 	vtodo.Props.Set(dtstamp)
 
+	for _, alarm := range task.Alarms {
+		vtodo.Children = append(vtodo.Children, alarmComponent(alarm, task.Summary))
+	}
+
 	cal.Children = append(cal.Children, vtodo)
+	timezone.Embed(cal)
 	return cal
 }
@@ -0,0 +1,301 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// Calendar collections are listed, created (MKCALENDAR, RFC 4791),
+// changed (PROPPATCH) and deleted over the client's HTTP connection, as
+// go-webdav only reads a few of their properties.
+
+const (
+	nsDAV       = "DAV:"
+	nsApple     = "http://apple.com/ns/ical/"
+	nsCalServer = "http://calendarserver.org/ns/"
+)
+
+var calendarProps = []xml.Name{
+	{Space: nsDAV, Local: "resourcetype"},
+	{Space: nsDAV, Local: "displayname"},
+	{Space: nsDAV, Local: "sync-token"},
+	{Space: nsDAV, Local: "current-user-privilege-set"},
+	{Space: nsDAV, Local: "owner"},
+	{Space: nsCalDAV, Local: "calendar-description"},
+	{Space: nsCalDAV, Local: "supported-calendar-component-set"},
+	{Space: nsApple, Local: "calendar-color"},
+	{Space: nsCalServer, Local: "getctag"},
+}
+
+// collectionMultistatus is a PROPFIND response for calendarProps.
+type collectionMultistatus struct {
+	XMLName   xml.Name `xml:"DAV: multistatus"`
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Prop   collectionProps `xml:"DAV: prop"`
+			Status string          `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+type collectionProps struct {
+	ResourceType *struct {
+		Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+	} `xml:"DAV: resourcetype"`
+	DisplayName string `xml:"DAV: displayname"`
+	SyncToken   string `xml:"DAV: sync-token"`
+	Privileges  *struct {
+		Privilege []struct {
+			All          *struct{} `xml:"DAV: all"`
+			Write        *struct{} `xml:"DAV: write"`
+			WriteContent *struct{} `xml:"DAV: write-content"`
+			Bind         *struct{} `xml:"DAV: bind"`
+		} `xml:"DAV: privilege"`
+	} `xml:"DAV: current-user-privilege-set"`
+	Owner *struct {
+		Href string `xml:"DAV: href"`
+	} `xml:"DAV: owner"`
+	Description string `xml:"urn:ietf:params:xml:ns:caldav calendar-description"`
+	Components  *struct {
+		Comps []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"urn:ietf:params:xml:ns:caldav comp"`
+	} `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set"`
+	Color string `xml:"http://apple.com/ns/ical/ calendar-color"`
+	CTag  string `xml:"http://calendarserver.org/ns/ getctag"`
+}
+
+// Supports reports whether the calendar can hold components of kind
+// (ical.CompEvent, ical.CompToDo).
+func (cal *Calendar) Supports(kind string) bool {
+	if len(cal.Components) == 0 {
+		return true
+	}
+	for _, comp := range cal.Components {
+		if strings.EqualFold(comp, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// calendarHome returns the path of the current user's calendar home set.
+func (c *Client) calendarHome(ctx context.Context) (string, error) {
+	principal, err := c.principal(ctx)
+	if err != nil {
+		return "", err
+	}
+	homeSet, err := c.client.FindCalendarHomeSet(ctx, principal)
+	if err != nil {
+		return "", fmt.Errorf("failed to find calendar home set: %w", err)
+	}
+	return homeSet, nil
+}
+
+// FindCalendars discovers available calendars.
+func (c *Client) FindCalendars(ctx context.Context) ([]Calendar, error) {
+	homeSet, err := c.calendarHome(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ms collectionMultistatus
+	if err := c.propfindInto(ctx, homeSet, "1", &ms, calendarProps...); err != nil {
+		return nil, fmt.Errorf("failed to find calendars: %w", err)
+	}
+
+	var result []Calendar
+	for _, resp := range ms.Responses {
+		cal := Calendar{Path: hrefPath(resp.Href)}
+		isCalendar, canWrite, hasPrivileges := false, false, false
+		for _, ps := range resp.Propstats {
+			if ps.Status != "" && !strings.Contains(ps.Status, " 2") {
+				continue
+			}
+			p := ps.Prop
+			if p.ResourceType != nil && p.ResourceType.Calendar != nil {
+				isCalendar = true
+			}
+			cal.Name = firstNonEmpty(cal.Name, strings.TrimSpace(p.DisplayName))
+			cal.Description = firstNonEmpty(cal.Description, strings.TrimSpace(p.Description))
+			cal.Color = firstNonEmpty(cal.Color, strings.TrimSpace(p.Color))
+			cal.CTag = firstNonEmpty(cal.CTag, strings.TrimSpace(p.CTag))
+			cal.SyncToken = firstNonEmpty(cal.SyncToken, strings.TrimSpace(p.SyncToken))
+			if p.Owner != nil {
+				cal.Owner = firstNonEmpty(cal.Owner, hrefPath(p.Owner.Href))
+			}
+			if p.Components != nil {
+				for _, comp := range p.Components.Comps {
+					cal.Components = append(cal.Components, strings.ToUpper(comp.Name))
+				}
+			}
+			if p.Privileges != nil {
+				hasPrivileges = true
+				for _, priv := range p.Privileges.Privilege {
+					if priv.All != nil || priv.Write != nil || priv.WriteContent != nil || priv.Bind != nil {
+						canWrite = true
+					}
+				}
+			}
+		}
+		if !isCalendar {
+			continue
+		}
+		cal.ReadOnly = hasPrivileges && !canWrite
+		result = append(result, cal)
+	}
+	return result, nil
+}
+
+// hrefPath returns the path of an href, which servers may send as a full
+// URL.
+func hrefPath(href string) string {
+	href = strings.TrimSpace(href)
+	if u, err := url.Parse(href); err == nil && u.Scheme != "" {
+		return u.Path
+	}
+	return href
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// ErrCalendarExists is returned by CreateCalendar when the path is taken.
+var ErrCalendarExists = errors.New("a calendar already exists at this path")
+
+// CreateCalendar creates a calendar collection with cal's name,
+// description, color and components (none means VEVENT). Without a path,
+// one is derived from the name in the calendar home set; cal.Path is set
+// to the new calendar's path.
+func (c *Client) CreateCalendar(ctx context.Context, cal *Calendar) error {
+	if cal.Path == "" {
+		homeSet, err := c.calendarHome(ctx)
+		if err != nil {
+			return err
+		}
+		cal.Path = strings.TrimSuffix(homeSet, "/") + "/" + calendarSlug(cal.Name) + "/"
+	}
+	components := cal.Components
+	if len(components) == 0 {
+		components = []string{ical.CompEvent}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	buf.WriteString(`<C:mkcalendar xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:A="http://apple.com/ns/ical/">`)
+	buf.WriteString(`<D:set><D:prop>`)
+	writeTextProp(&buf, "D:displayname", cal.Name)
+	writeTextProp(&buf, "C:calendar-description", cal.Description)
+	writeTextProp(&buf, "A:calendar-color", cal.Color)
+	buf.WriteString(`<C:supported-calendar-component-set>`)
+	for _, comp := range components {
+		fmt.Fprintf(&buf, `<C:comp name="%s"/>`, xmlEscape(strings.ToUpper(comp)))
+	}
+	buf.WriteString(`</C:supported-calendar-component-set>`)
+	buf.WriteString(`</D:prop></D:set></C:mkcalendar>`)
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	_, _, err := c.do(ctx, "MKCALENDAR", cal.Path, header, buf.Bytes(), http.StatusCreated)
+	var status *statusError
+	if errors.As(err, &status) && status.Code == http.StatusMethodNotAllowed {
+		return fmt.Errorf("failed to create calendar %s: %w", cal.Path, ErrCalendarExists)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create calendar: %w", err)
+	}
+	cal.Components = components
+	return nil
+}
+
+// RenameCalendar sets a calendar's display name.
+func (c *Client) RenameCalendar(ctx context.Context, calPath, name string) error {
+	var buf bytes.Buffer
+	writeTextProp(&buf, "D:displayname", name)
+	return c.proppatch(ctx, calPath, buf.String())
+}
+
+// SetCalendarColor sets a calendar's color (#RRGGBB or #RRGGBBAA).
+func (c *Client) SetCalendarColor(ctx context.Context, calPath, color string) error {
+	var buf bytes.Buffer
+	writeTextProp(&buf, "A:calendar-color", color)
+	return c.proppatch(ctx, calPath, buf.String())
+}
+
+// proppatch sets the properties in props, XML elements using the D, C and
+// A prefixes, on path.
+func (c *Client) proppatch(ctx context.Context, path, props string) error {
+	body := `<?xml version="1.0" encoding="utf-8"?>` + "\n" +
+		`<D:propertyupdate xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:A="http://apple.com/ns/ical/">` +
+		`<D:set><D:prop>` + props + `</D:prop></D:set></D:propertyupdate>`
+	header := http.Header{}
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	data, _, err := c.do(ctx, "PROPPATCH", path, header, []byte(body), http.StatusMultiStatus, http.StatusOK, http.StatusNoContent)
+	if err != nil {
+		return fmt.Errorf("failed to update calendar: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	var ms multistatus
+	if err := xml.Unmarshal(data, &ms); err != nil {
+		return fmt.Errorf("failed to parse PROPPATCH response: %w", err)
+	}
+	for _, resp := range ms.Responses {
+		for _, ps := range resp.Propstats {
+			if ps.Status != "" && !strings.Contains(ps.Status, " 2") {
+				return fmt.Errorf("failed to update calendar: server answered %s", strings.TrimPrefix(ps.Status, "HTTP/1.1 "))
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteCalendar deletes a calendar collection and everything in it.
+func (c *Client) DeleteCalendar(ctx context.Context, calPath string) error {
+	if _, _, err := c.do(ctx, http.MethodDelete, calPath, nil, nil, http.StatusOK, http.StatusNoContent); err != nil {
+		return fmt.Errorf("failed to delete calendar: %w", err)
+	}
+	return nil
+}
+
+func writeTextProp(buf *bytes.Buffer, name, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(buf, "<%s>%s</%s>", name, xmlEscape(value), name)
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+var slugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// calendarSlug derives a collection name from a display name.
+func calendarSlug(name string) string {
+	slug := strings.Trim(slugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if slug == "" {
+		slug = fmt.Sprintf("calendar-%d", time.Now().Unix())
+	}
+	return slug
+}
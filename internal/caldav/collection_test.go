@@ -0,0 +1,143 @@
+package caldav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectionServer fakes a calendar home with a calendar, a read-only task
+// list and a scheduling inbox. Requests are recorded.
+type collectionServer struct {
+	requests map[string]string // "METHOD path" to body
+}
+
+func (s *collectionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.requests[r.Method+" "+r.URL.Path] = string(body)
+	multistatus := func(responses string) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:A="http://apple.com/ns/ical/" xmlns:CS="http://calendarserver.org/ns/">%s</D:multistatus>`, responses)
+	}
+
+	switch {
+	case r.Method == "PROPFIND" && strings.Contains(string(body), "current-user-principal"):
+		multistatus(`<D:response><D:href>` + r.URL.Path + `</D:href><D:propstat><D:prop>
+<D:current-user-principal><D:href>/principals/me/</D:href></D:current-user-principal></D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`)
+	case r.Method == "PROPFIND" && r.URL.Path == "/principals/me/":
+		multistatus(`<D:response><D:href>/principals/me/</D:href><D:propstat><D:prop>
+<C:calendar-home-set><D:href>/calendars/me/</D:href></C:calendar-home-set></D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`)
+	case r.Method == "PROPFIND" && r.URL.Path == "/calendars/me/":
+		multistatus(`<D:response><D:href>/calendars/me/</D:href><D:propstat><D:prop>
+<D:resourcetype><D:collection/></D:resourcetype></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>
+<D:response><D:href>http://example.com/calendars/me/work/</D:href><D:propstat><D:prop>
+<D:resourcetype><D:collection/><C:calendar/></D:resourcetype>
+<D:displayname>Work</D:displayname>
+<A:calendar-color>#FF0000FF</A:calendar-color>
+<CS:getctag>ctag-1</CS:getctag>
+<D:sync-token>http://example.com/sync/1</D:sync-token>
+<C:supported-calendar-component-set><C:comp name="VEVENT"/><C:comp name="VTODO"/></C:supported-calendar-component-set>
+<D:current-user-privilege-set><D:privilege><D:read/></D:privilege><D:privilege><D:all/></D:privilege></D:current-user-privilege-set>
+<D:owner><D:href>/principals/me/</D:href></D:owner>
+</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat>
+<D:propstat><D:prop><C:calendar-description/></D:prop><D:status>HTTP/1.1 404 Not Found</D:status></D:propstat></D:response>
+<D:response><D:href>/calendars/me/shared-tasks/</D:href><D:propstat><D:prop>
+<D:resourcetype><D:collection/><C:calendar/></D:resourcetype>
+<D:displayname>Shared tasks</D:displayname>
+<C:supported-calendar-component-set><C:comp name="VTODO"/></C:supported-calendar-component-set>
+<D:current-user-privilege-set><D:privilege><D:read/></D:privilege></D:current-user-privilege-set>
+<D:owner><D:href>/principals/boss/</D:href></D:owner>
+</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>
+<D:response><D:href>/calendars/me/inbox/</D:href><D:propstat><D:prop>
+<D:resourcetype><D:collection/><C:schedule-inbox/></D:resourcetype></D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`)
+	case r.Method == "MKCALENDAR" && r.URL.Path == "/calendars/me/work/":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	case r.Method == "MKCALENDAR":
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PROPPATCH" && r.URL.Path == "/calendars/me/shared-tasks/":
+		multistatus(`<D:response><D:href>/calendars/me/shared-tasks/</D:href><D:propstat><D:prop><D:displayname/></D:prop>
+<D:status>HTTP/1.1 403 Forbidden</D:status></D:propstat></D:response>`)
+	case r.Method == "PROPPATCH":
+		multistatus(`<D:response><D:href>` + r.URL.Path + `</D:href><D:propstat><D:prop><D:displayname/></D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`)
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func newCollectionClient(t *testing.T) (*Client, *collectionServer) {
+	t.Helper()
+	s := &collectionServer{requests: map[string]string{}}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	client, err := Connect(Config{URL: server.URL + "/", Email: "me@example.com"})
+	require.NoError(t, err)
+	return client, s
+}
+
+func TestFindCalendars(t *testing.T) {
+	client, _ := newCollectionClient(t)
+	cals, err := client.FindCalendars(context.Background())
+	require.NoError(t, err)
+	require.Len(t, cals, 2)
+
+	work := cals[0]
+	assert.Equal(t, "/calendars/me/work/", work.Path)
+	assert.Equal(t, "Work", work.Name)
+	assert.Empty(t, work.Description)
+	assert.Equal(t, "#FF0000FF", work.Color)
+	assert.Equal(t, "ctag-1", work.CTag)
+	assert.Equal(t, "http://example.com/sync/1", work.SyncToken)
+	assert.Equal(t, []string{"VEVENT", "VTODO"}, work.Components)
+	assert.False(t, work.ReadOnly)
+	assert.Equal(t, "/principals/me/", work.Owner)
+
+	tasks := cals[1]
+	assert.True(t, tasks.ReadOnly)
+	assert.True(t, tasks.Supports("VTODO"))
+	assert.False(t, tasks.Supports("VEVENT"))
+	assert.True(t, (&Calendar{}).Supports("VEVENT"))
+}
+
+func TestCreateCalendar(t *testing.T) {
+	client, server := newCollectionClient(t)
+	cal := &Calendar{Name: "Side <Projects>", Color: "#00FF00", Components: []string{"VEVENT", "VTODO"}}
+	require.NoError(t, client.CreateCalendar(context.Background(), cal))
+	assert.Equal(t, "/calendars/me/side-projects/", cal.Path)
+	body := server.requests["MKCALENDAR /calendars/me/side-projects/"]
+	assert.Contains(t, body, "<D:displayname>Side &lt;Projects&gt;</D:displayname>")
+	assert.Contains(t, body, "<A:calendar-color>#00FF00</A:calendar-color>")
+	assert.Contains(t, body, `<C:comp name="VEVENT"/><C:comp name="VTODO"/>`)
+	assert.NotContains(t, body, "calendar-description")
+
+	err := client.CreateCalendar(context.Background(), &Calendar{Path: "/calendars/me/work/", Name: "Work"})
+	assert.True(t, errors.Is(err, ErrCalendarExists))
+}
+
+func TestUpdateAndDeleteCalendar(t *testing.T) {
+	client, server := newCollectionClient(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.RenameCalendar(ctx, "/calendars/me/work/", "Office"))
+	assert.Contains(t, server.requests["PROPPATCH /calendars/me/work/"], "<D:displayname>Office</D:displayname>")
+	require.NoError(t, client.SetCalendarColor(ctx, "/calendars/me/work/", "#0000FF"))
+	assert.Contains(t, server.requests["PROPPATCH /calendars/me/work/"], "<A:calendar-color>#0000FF</A:calendar-color>")
+	assert.Error(t, client.RenameCalendar(ctx, "/calendars/me/shared-tasks/", "Mine"))
+
+	require.NoError(t, client.DeleteCalendar(ctx, "/calendars/me/work/"))
+	assert.Contains(t, server.requests, "DELETE /calendars/me/work/")
+}
@@ -0,0 +1,107 @@
+package caldav
+
+import (
+	"sort"
+	"time"
+)
+
+// Blocks reports whether an event makes its time busy: it is neither
+// cancelled (STATUS:CANCELLED) nor transparent (TRANSP:TRANSPARENT).
+func (e *Event) Blocks() bool {
+	return !isCancelled(e) && !e.Transparent
+}
+
+// Occurrences returns the occurrences of a single event in [start, end),
+// or the event itself if it does not recur.
+func Occurrences(e *Event, start, end time.Time) ([]Event, error) {
+	return expandEvent(e, nil, start, end)
+}
+
+// Conflicts returns the events that block time and overlap any of
+// occurrences, in order of start. Occurrences of the same event are not
+// conflicts.
+func Conflicts(occurrences, events []Event) []Event {
+	var conflicts []Event
+	for i := range events {
+		e := &events[i]
+		if !e.Blocks() {
+			continue
+		}
+		for j := range occurrences {
+			occ := &occurrences[j]
+			if e.UID != occ.UID && overlapsEvent(e, occ) {
+				conflicts = append(conflicts, *e)
+				break
+			}
+		}
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool { return conflicts[i].Start.Before(conflicts[j].Start) })
+	return conflicts
+}
+
+// Overlap is a pair of events whose times overlap.
+type Overlap struct {
+	A, B  Event
+	Start time.Time // Start of the overlap
+	End   time.Time
+}
+
+// FindOverlaps returns the pairs of events that block time and overlap,
+// in order of start.
+func FindOverlaps(events []Event) []Overlap {
+	var blocking []Event
+	for i := range events {
+		if events[i].Blocks() {
+			blocking = append(blocking, events[i])
+		}
+	}
+	sort.SliceStable(blocking, func(i, j int) bool { return blocking[i].Start.Before(blocking[j].Start) })
+
+	var overlaps []Overlap
+	var active []*Event
+	for i := range blocking {
+		e := &blocking[i]
+		kept := active[:0]
+		for _, a := range active {
+			if eventEnd(a).After(e.Start) {
+				kept = append(kept, a)
+			}
+		}
+		active = kept
+		if sameOccurrence(active, e) {
+			continue // Already seen, e.g. in another calendar
+		}
+		for _, a := range active {
+			end := eventEnd(a)
+			if eventEnd(e).Before(end) {
+				end = eventEnd(e)
+			}
+			overlaps = append(overlaps, Overlap{A: *a, B: *e, Start: e.Start, End: end})
+		}
+		active = append(active, e)
+	}
+	return overlaps
+}
+
+// sameOccurrence reports whether events holds the occurrence e.
+func sameOccurrence(events []*Event, e *Event) bool {
+	for _, a := range events {
+		if a.UID == e.UID && a.Start.Equal(e.Start) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlapsEvent reports whether two events overlap in time. Events
+// without duration overlap the events around their start.
+func overlapsEvent(a, b *Event) bool {
+	if eventDuration(b) == 0 {
+		return overlaps(a, b.Start, b.Start.Add(time.Nanosecond))
+	}
+	return overlaps(a, b.Start, eventEnd(b))
+}
+
+func eventEnd(e *Event) time.Time {
+	return e.Start.Add(eventDuration(e))
+}
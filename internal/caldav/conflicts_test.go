@@ -0,0 +1,62 @@
+package caldav
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConflicts(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC) }
+	events := []Event{
+		{UID: "standup", Start: at(19, 9), End: at(19, 10)},
+		{UID: "lunch", Start: at(19, 12), End: at(19, 13)},
+		{UID: "focus", Start: at(19, 10), End: at(19, 12), Transparent: true},
+		{UID: "cancelled", Start: at(19, 10), End: at(19, 11), Status: "CANCELLED"},
+		{UID: "review", Start: at(26, 10), End: at(26, 11)},
+	}
+
+	// 09:30-10:30 clashes with the standup only; touching is fine
+	meeting := Event{UID: "new", Start: at(19, 9).Add(30 * time.Minute), End: at(19, 10).Add(30 * time.Minute)}
+	occurrences, err := Occurrences(&meeting, meeting.Start, meeting.Start.AddDate(0, 0, 30))
+	require.NoError(t, err)
+	conflicts := Conflicts(occurrences, events)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "standup", conflicts[0].UID)
+
+	meeting = Event{UID: "new", Start: at(19, 10), End: at(19, 12)}
+	assert.Empty(t, Conflicts([]Event{meeting}, events))
+
+	// Every occurrence of a recurring event is checked
+	meeting = Event{UID: "new", Start: at(12, 10), End: at(12, 11), RRule: "FREQ=WEEKLY;COUNT=3"}
+	occurrences, err = Occurrences(&meeting, meeting.Start, meeting.Start.AddDate(0, 0, 30))
+	require.NoError(t, err)
+	require.Len(t, occurrences, 3)
+	conflicts = Conflicts(occurrences, events)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "review", conflicts[0].UID)
+
+	// An event does not conflict with itself
+	assert.Empty(t, Conflicts([]Event{events[0]}, events[:1]))
+}
+
+func TestFindOverlaps(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2026, 10, 19, hour, minute, 0, 0, time.UTC) }
+	events := []Event{
+		{UID: "c", Start: at(10, 30), End: at(11, 30)},
+		{UID: "a", Start: at(9, 0), End: at(11, 0)},
+		{UID: "b", Start: at(10, 0), End: at(10, 15)},
+		{UID: "d", Start: at(11, 30), End: at(12, 0)},                     // Touches c
+		{UID: "e", Start: at(9, 0), End: at(17, 0), Transparent: true},    // Free
+		{UID: "a", Start: at(9, 0), End: at(11, 0), Calendar: "/shared/"}, // The same meeting
+	}
+
+	overlaps := FindOverlaps(events)
+	var got []string
+	for _, o := range overlaps {
+		got = append(got, o.A.UID+"-"+o.B.UID+" "+o.Start.Format("15:04")+"-"+o.End.Format("15:04"))
+	}
+	assert.Equal(t, []string{"a-b 10:00-10:15", "a-c 10:30-11:00"}, got)
+}
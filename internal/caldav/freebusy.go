@@ -0,0 +1,319 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// Free/busy types (FBTYPE)
+const (
+	BusyTypeBusy        = "BUSY"
+	BusyTypeTentative   = "BUSY-TENTATIVE"
+	BusyTypeUnavailable = "BUSY-UNAVAILABLE"
+)
+
+// Busy is a period of time that is not free.
+type Busy struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Type  string    `json:"type,omitempty"` // BUSY, BUSY-TENTATIVE or BUSY-UNAVAILABLE
+}
+
+// FreeBusyResponse is the free/busy of one attendee, as reported by the
+// server's scheduling outbox.
+type FreeBusyResponse struct {
+	Attendee string `json:"attendee"`
+	Status   string `json:"status"` // iTIP request status, like "2.0;Success"
+	Busy     []Busy `json:"busy,omitempty"`
+}
+
+// OK reports whether the server could look up the attendee's free/busy.
+func (r FreeBusyResponse) OK() bool {
+	return strings.HasPrefix(r.Status, "2.")
+}
+
+const freeBusyTimeFormat = "20060102T150405Z"
+
+// FreeBusy returns the busy periods of a calendar between start and end,
+// using a free-busy-query REPORT (RFC 4791 section 7.10). If the server
+// does not support the report, they are computed from the events.
+func (c *Client) FreeBusy(ctx context.Context, calPath string, start, end time.Time) ([]Busy, error) {
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<C:free-busy-query xmlns:C="%s"><C:time-range start="%s" end="%s"/></C:free-busy-query>`,
+		nsCalDAV, start.UTC().Format(freeBusyTimeFormat), end.UTC().Format(freeBusyTimeFormat))
+	header := http.Header{}
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	header.Set("Depth", "1")
+
+	data, _, err := c.do(ctx, "REPORT", calPath, header, []byte(body), http.StatusOK)
+	if err == nil {
+		if cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode(); err == nil {
+			return MergeBusy(parseFreeBusy(cal)), nil
+		}
+	}
+	var status *statusError
+	if err != nil && !errors.As(err, &status) {
+		return nil, fmt.Errorf("failed to query free/busy: %w", err)
+	}
+
+	events, err := c.ListEvents(ctx, calPath, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return MergeBusy(busyFromEvents(events, start, end)), nil
+}
+
+// AttendeeFreeBusy asks the server's scheduling outbox for the free/busy of
+// attendees (RFC 6638 section 5), with the account as the organizer.
+// Attendees the server cannot look up, such as people on other servers, get
+// a response with a non-2.x status.
+func (c *Client) AttendeeFreeBusy(ctx context.Context, attendees []string, start, end time.Time) ([]FreeBusyResponse, error) {
+	outbox, err := c.scheduleOutbox(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(freeBusyRequest(c.email, attendees, start, end)); err != nil {
+		return nil, fmt.Errorf("failed to encode free/busy request: %w", err)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "text/calendar; charset=utf-8; method=REQUEST")
+	header.Set("Originator", "mailto:"+c.email)
+	for _, a := range attendees {
+		header.Add("Recipient", "mailto:"+a)
+	}
+	data, _, err := c.do(ctx, http.MethodPost, outbox, header, buf.Bytes(), http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request free/busy: %w", err)
+	}
+	return parseScheduleResponse(data)
+}
+
+// scheduleOutbox returns the path of the current user's scheduling outbox.
+func (c *Client) scheduleOutbox(ctx context.Context) (string, error) {
+	s, err := c.Scheduling(ctx)
+	if err != nil {
+		return "", err
+	}
+	if s.Outbox == "" {
+		return "", ErrNoScheduling
+	}
+	return s.Outbox, nil
+}
+
+// freeBusyRequest builds the iTIP VFREEBUSY REQUEST sent to the outbox.
+func freeBusyRequest(organizer string, attendees []string, start, end time.Time) *ical.Calendar {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//sog//CalDAV Client//EN")
+	cal.Props.SetText(ical.PropMethod, "REQUEST")
+
+	fb := ical.NewComponent(ical.CompFreeBusy)
+	fb.Props.SetText(ical.PropUID, fmt.Sprintf("%d-freebusy@sog", time.Now().UnixNano()))
+	fb.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	fb.Props.SetDateTime(ical.PropDateTimeStart, start.UTC())
+	fb.Props.SetDateTime(ical.PropDateTimeEnd, end.UTC())
+	org := ical.NewProp(ical.PropOrganizer)
+	org.Value = "mailto:" + organizer
+	fb.Props.Set(org)
+	for _, a := range attendees {
+		prop := ical.NewProp(ical.PropAttendee)
+		prop.Value = "mailto:" + a
+		fb.Props.Add(prop)
+	}
+	cal.Children = append(cal.Children, fb)
+	return cal
+}
+
+// scheduleResponse is the body of a scheduling outbox POST response.
+type scheduleResponse struct {
+	XMLName   xml.Name `xml:"urn:ietf:params:xml:ns:caldav schedule-response"`
+	Responses []struct {
+		Recipient struct {
+			Href string `xml:"DAV: href"`
+		} `xml:"urn:ietf:params:xml:ns:caldav recipient"`
+		RequestStatus string `xml:"urn:ietf:params:xml:ns:caldav request-status"`
+		CalendarData  string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+	} `xml:"urn:ietf:params:xml:ns:caldav response"`
+}
+
+func parseScheduleResponse(data []byte) ([]FreeBusyResponse, error) {
+	var sr scheduleResponse
+	if err := xml.Unmarshal(data, &sr); err != nil {
+		return nil, fmt.Errorf("failed to parse schedule response: %w", err)
+	}
+	var responses []FreeBusyResponse
+	for _, r := range sr.Responses {
+		resp := FreeBusyResponse{
+			Attendee: strings.TrimPrefix(strings.TrimSpace(r.Recipient.Href), "mailto:"),
+			Status:   strings.TrimSpace(r.RequestStatus),
+		}
+		if strings.TrimSpace(r.CalendarData) != "" {
+			cal, err := ical.NewDecoder(strings.NewReader(r.CalendarData)).Decode()
+			if err == nil {
+				resp.Busy = MergeBusy(parseFreeBusy(cal))
+			}
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+// parseFreeBusy returns the busy periods in the VFREEBUSY components of
+// cal. FREE periods and unparsable values are skipped.
+func parseFreeBusy(cal *ical.Calendar) []Busy {
+	var busy []Busy
+	for _, comp := range cal.Children {
+		if comp.Name != ical.CompFreeBusy {
+			continue
+		}
+		for _, prop := range comp.Props[ical.PropFreeBusy] {
+			fbtype := strings.ToUpper(prop.Params.Get(ical.ParamFreeBusyType))
+			if fbtype == "" {
+				fbtype = BusyTypeBusy
+			}
+			if fbtype == "FREE" {
+				continue
+			}
+			for _, value := range strings.Split(prop.Value, ",") {
+				start, end, ok := parsePeriod(value)
+				if ok {
+					busy = append(busy, Busy{Start: start, End: end, Type: fbtype})
+				}
+			}
+		}
+	}
+	return busy
+}
+
+// parsePeriod parses an iCalendar PERIOD: "start/end" or "start/duration".
+func parsePeriod(value string) (start, end time.Time, ok bool) {
+	from, to, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		return time.Time{}, time.Time{}, false
+	}
+	start, err := time.Parse(freeBusyTimeFormat, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	if end, err = time.Parse(freeBusyTimeFormat, to); err == nil {
+		return start, end, true
+	}
+	prop := ical.NewProp(ical.PropDuration)
+	prop.Value = to
+	d, err := prop.Duration()
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, start.Add(d), true
+}
+
+// busyFromEvents returns the busy periods of events, skipping cancelled
+// and transparent ones.
+func busyFromEvents(events []Event, start, end time.Time) []Busy {
+	var busy []Busy
+	for i := range events {
+		e := &events[i]
+		if isCancelled(e) || e.Transparent || !overlaps(e, start, end) {
+			continue
+		}
+		fbtype := BusyTypeBusy
+		if strings.EqualFold(e.Status, "TENTATIVE") {
+			fbtype = BusyTypeTentative
+		}
+		busy = append(busy, Busy{Start: e.Start, End: e.Start.Add(eventDuration(e)), Type: fbtype})
+	}
+	return busy
+}
+
+// MergeBusy sorts busy periods and merges the ones that overlap or touch.
+// Merged periods keep the type of the first period.
+func MergeBusy(busy []Busy) []Busy {
+	sorted := append([]Busy(nil), busy...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+	var merged []Busy
+	for _, b := range sorted {
+		if !b.End.After(b.Start) {
+			continue
+		}
+		if n := len(merged); n > 0 && !b.Start.After(merged[n-1].End) {
+			if b.End.After(merged[n-1].End) {
+				merged[n-1].End = b.End
+			}
+			continue
+		}
+		merged = append(merged, b)
+	}
+	return merged
+}
+
+// WorkingHours limits free slots to part of each day.
+type WorkingHours struct {
+	Start    time.Duration  // Time of day the day starts
+	End      time.Duration  // Time of day the day ends; 0 is midnight at the end
+	Weekends bool           // Include Saturdays and Sundays
+	Location *time.Location // Zone the hours are in; nil is the local zone
+}
+
+// Slot is a free period.
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// FreeSlots returns the free periods of at least d between start and end
+// that are within working hours and not covered by busy.
+func FreeSlots(busy []Busy, start, end time.Time, d time.Duration, hours WorkingHours) []Slot {
+	loc := hours.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	dayEnd := hours.End
+	if dayEnd == 0 {
+		dayEnd = 24 * time.Hour
+	}
+	busy = MergeBusy(busy)
+
+	var slots []Slot
+	first := start.In(loc)
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !hours.Weekends && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+			continue
+		}
+		// Wall-clock times, so working hours stay put across DST changes
+		from := time.Date(day.Year(), day.Month(), day.Day(), 0, int(hours.Start/time.Minute), 0, 0, loc)
+		to := time.Date(day.Year(), day.Month(), day.Day(), 0, int(dayEnd/time.Minute), 0, 0, loc)
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		for _, b := range busy {
+			if !from.Before(to) {
+				break
+			}
+			if !b.End.After(from) || !b.Start.Before(to) {
+				continue
+			}
+			if b.Start.Sub(from) >= d {
+				slots = append(slots, Slot{Start: from, End: b.Start.In(loc)})
+			}
+			from = b.End.In(loc)
+		}
+		if to.Sub(from) >= d {
+			slots = append(slots, Slot{Start: from, End: to})
+		}
+	}
+	return slots
+}
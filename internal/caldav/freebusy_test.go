@@ -0,0 +1,159 @@
+package caldav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const freeBusyReply = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Server//EN
+METHOD:REPLY
+BEGIN:VFREEBUSY
+UID:fb@example.com
+DTSTAMP:20260301T000000Z
+DTSTART:20260302T000000Z
+DTEND:20260303T000000Z
+FREEBUSY:20260302T090000Z/20260302T100000Z,20260302T093000Z/PT1H
+FREEBUSY;FBTYPE=BUSY-TENTATIVE:20260302T140000Z/PT30M
+FREEBUSY;FBTYPE=FREE:20260302T160000Z/PT1H
+END:VFREEBUSY
+END:VCALENDAR
+`
+
+func TestParseFreeBusy(t *testing.T) {
+	busy := MergeBusy(parseFreeBusy(decodeCalendar(t, freeBusyReply)))
+	require.Len(t, busy, 2)
+	assert.Equal(t, Busy{Start: utc("2026-03-02T09:00"), End: utc("2026-03-02T10:30"), Type: BusyTypeBusy}, busy[0])
+	assert.Equal(t, Busy{Start: utc("2026-03-02T14:00"), End: utc("2026-03-02T14:30"), Type: BusyTypeTentative}, busy[1])
+}
+
+func TestBusyFromEvents(t *testing.T) {
+	events := []Event{
+		{Summary: "Meeting", Start: utc("2026-03-02T09:00"), End: utc("2026-03-02T10:00")},
+		{Summary: "Maybe", Status: "TENTATIVE", Start: utc("2026-03-02T11:00"), End: utc("2026-03-02T12:00")},
+		{Summary: "Cancelled", Status: "CANCELLED", Start: utc("2026-03-02T13:00"), End: utc("2026-03-02T14:00")},
+		{Summary: "Reminder", Transparent: true, Start: utc("2026-03-02T15:00"), End: utc("2026-03-02T16:00")},
+	}
+	busy := busyFromEvents(events, utc("2026-03-02T00:00"), utc("2026-03-03T00:00"))
+	require.Len(t, busy, 2)
+	assert.Equal(t, BusyTypeBusy, busy[0].Type)
+	assert.Equal(t, BusyTypeTentative, busy[1].Type)
+}
+
+func TestFreeSlots(t *testing.T) {
+	busy := []Busy{
+		{Start: utc("2026-03-02T09:00"), End: utc("2026-03-02T10:00")},
+		{Start: utc("2026-03-02T10:00"), End: utc("2026-03-02T10:30")},
+		{Start: utc("2026-03-02T11:00"), End: utc("2026-03-02T16:30")},
+	}
+	hours := WorkingHours{Start: 9 * time.Hour, End: 17 * time.Hour, Location: time.UTC}
+
+	// Monday to Sunday: Monday has 10:30-11:00 (too short) and 16:30-17:00
+	slots := FreeSlots(busy, utc("2026-03-02T00:00"), utc("2026-03-09T00:00"), 30*time.Minute, hours)
+	require.Len(t, slots, 6)
+	assert.Equal(t, Slot{Start: utc("2026-03-02T10:30"), End: utc("2026-03-02T11:00")}, slots[0])
+	assert.Equal(t, Slot{Start: utc("2026-03-02T16:30"), End: utc("2026-03-02T17:00")}, slots[1])
+	assert.Equal(t, Slot{Start: utc("2026-03-03T09:00"), End: utc("2026-03-03T17:00")}, slots[2])
+	assert.Equal(t, utc("2026-03-06T09:00"), slots[5].Start)
+
+	slots = FreeSlots(busy, utc("2026-03-02T00:00"), utc("2026-03-03T00:00"), 45*time.Minute, hours)
+	assert.Empty(t, slots)
+
+	// The search starts part way through a day
+	slots = FreeSlots(nil, utc("2026-03-03T15:10"), utc("2026-03-04T00:00"), time.Hour, hours)
+	require.Len(t, slots, 1)
+	assert.Equal(t, utc("2026-03-03T15:10"), slots[0].Start)
+
+	// Weekends
+	hours.Weekends = true
+	slots = FreeSlots(nil, utc("2026-03-07T00:00"), utc("2026-03-08T00:00"), time.Hour, hours)
+	assert.Len(t, slots, 1)
+}
+
+func TestFreeSlotsAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	hours := WorkingHours{Start: 9 * time.Hour, End: 17 * time.Hour, Location: berlin}
+
+	// Clocks go forward on Sunday, 2026-03-29
+	start := time.Date(2026, 3, 27, 0, 0, 0, 0, berlin)
+	slots := FreeSlots(nil, start, start.AddDate(0, 0, 4), time.Hour, hours)
+	require.Len(t, slots, 2)
+	assert.Equal(t, time.Date(2026, 3, 27, 9, 0, 0, 0, berlin), slots[0].Start)
+	assert.Equal(t, time.Date(2026, 3, 30, 9, 0, 0, 0, berlin), slots[1].Start)
+	assert.Equal(t, 8*time.Hour, slots[1].End.Sub(slots[1].Start))
+}
+
+func TestAttendeeFreeBusy(t *testing.T) {
+	var posted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.Method == "PROPFIND" && strings.Contains(string(body), "current-user-principal"):
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<D:multistatus xmlns:D="DAV:"><D:response><D:href>`+r.URL.Path+`</D:href>
+<D:propstat><D:prop><D:current-user-principal><D:href>/principals/me/</D:href></D:current-user-principal></D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response></D:multistatus>`)
+		case r.Method == "PROPFIND" && r.URL.Path == "/principals/me/":
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:response>
+<D:href>/principals/me/</D:href><D:propstat><D:prop><C:schedule-outbox-URL><D:href>/outbox/</D:href></C:schedule-outbox-URL></D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response></D:multistatus>`)
+		case r.Method == http.MethodPost && r.URL.Path == "/outbox/":
+			posted = string(body)
+			io.WriteString(w, `<C:schedule-response xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+<C:response><C:recipient><D:href>mailto:alice@example.com</D:href></C:recipient>
+<C:request-status>2.0;Success</C:request-status><C:calendar-data>`+freeBusyReply+`</C:calendar-data></C:response>
+<C:response><C:recipient><D:href>mailto:bob@elsewhere.com</D:href></C:recipient>
+<C:request-status>3.7;Invalid calendar user</C:request-status></C:response>
+</C:schedule-response>`)
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	defer server.Close()
+
+	client, err := Connect(Config{URL: server.URL + "/", Email: "me@example.com"})
+	require.NoError(t, err)
+	responses, err := client.AttendeeFreeBusy(context.Background(),
+		[]string{"alice@example.com", "bob@elsewhere.com"}, utc("2026-03-02T00:00"), utc("2026-03-03T00:00"))
+	require.NoError(t, err)
+
+	assert.Contains(t, posted, "BEGIN:VFREEBUSY")
+	assert.Contains(t, posted, "ORGANIZER:mailto:me@example.com")
+	assert.Contains(t, posted, "ATTENDEE:mailto:bob@elsewhere.com")
+	require.Len(t, responses, 2)
+	assert.Equal(t, "alice@example.com", responses[0].Attendee)
+	assert.True(t, responses[0].OK())
+	assert.Len(t, responses[0].Busy, 2)
+	assert.False(t, responses[1].OK())
+
+	// Calendars without the free-busy-query report are read event by event;
+	// this server answers neither, so the error comes from the fallback
+	_, err = client.FreeBusy(context.Background(), "/cal/", utc("2026-03-02T00:00"), utc("2026-03-03T00:00"))
+	assert.Error(t, err)
+}
+
+func TestAttendeeFreeBusyNoScheduling(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, `<D:multistatus xmlns:D="DAV:"><D:response><D:href>`+r.URL.Path+`</D:href>
+<D:propstat><D:prop><D:current-user-principal><D:href>/</D:href></D:current-user-principal></D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response></D:multistatus>`)
+	}))
+	defer server.Close()
+
+	client, err := Connect(Config{URL: server.URL + "/", Email: "me@example.com"})
+	require.NoError(t, err)
+	_, err = client.AttendeeFreeBusy(context.Background(), []string{"alice@example.com"}, time.Now(), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrNoScheduling)
+}
@@ -0,0 +1,343 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/visionik/sogcli/internal/dav"
+	"github.com/visionik/sogcli/internal/timezone"
+)
+
+// Scheduling messages received by email (iMIP, RFC 6047) are applied to the
+// calendar by the client, as servers only process the ones that arrive in
+// their scheduling inbox.
+
+// ErrOutdated is returned for a scheduling message older than the event in
+// the calendar: its SEQUENCE is lower.
+var ErrOutdated = errors.New("message is older than the calendar's event")
+
+// ErrWrongSender is returned for a scheduling message whose email sender
+// is not the one it speaks for: the event's organizer for a cancellation,
+// or the replying attendee.
+var ErrWrongSender = errors.New("message was not sent by the organizer or attendee it is from")
+
+// decodeMessage decodes an iTIP message and returns it with the UID and
+// SEQUENCE of its first VEVENT.
+func decodeMessage(data []byte) (*ical.Calendar, string, int, error) {
+	cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to decode iCalendar: %w", err)
+	}
+	timezone.Register(cal)
+	for _, child := range cal.Children {
+		if child.Name == ical.CompEvent {
+			seq, _ := strconv.Atoi(propValue(child, ical.PropSequence))
+			return cal, propValue(child, ical.PropUID), seq, nil
+		}
+	}
+	return nil, "", 0, fmt.Errorf("no event in scheduling message")
+}
+
+// accountAddresses returns the account's calendar user addresses. Without
+// scheduling support that is just the account's email address.
+func (c *Client) accountAddresses(ctx context.Context) *Scheduling {
+	if s, err := c.Scheduling(ctx); err == nil {
+		return s
+	}
+	return &Scheduling{Addresses: []string{c.email}}
+}
+
+// objectSequence returns the SEQUENCE of the master event for uid in cal.
+func objectSequence(cal *ical.Calendar, uid string) int {
+	comp := findMaster(cal, uid)
+	if comp == nil {
+		return 0
+	}
+	seq, _ := strconv.Atoi(propValue(comp, ical.PropSequence))
+	return seq
+}
+
+// SaveInvitation stores an invitation or update (an iTIP REQUEST) in the
+// calendar with the account's partstat. An event already in one of the
+// calendars is replaced, keeping its reminders; otherwise it is created in
+// calPath, except for declined invitations, which are not added. The
+// event is returned.
+func (c *Client) SaveInvitation(ctx context.Context, calPath string, data []byte, partstat string) (*Event, error) {
+	cal, uid, seq, err := decodeMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	cal.Props.Del(ical.PropMethod)
+	if !setPartStat(cal, uid, c.accountAddresses(ctx), partstat) {
+		return nil, fmt.Errorf("%s is not an attendee of %s", c.email, uid)
+	}
+
+	obj, err := c.findEventObject(ctx, calPath, uid)
+	switch {
+	case err == nil:
+		if findMaster(cal, uid) == nil {
+			// An update of single occurrences: merge into the series
+			if !mergeOverrides(obj.Data, cal, uid) {
+				return nil, ErrOutdated
+			}
+			cal = obj.Data
+		} else {
+			if seq < objectSequence(obj.Data, uid) {
+				return nil, ErrOutdated
+			}
+			keepAlarms(cal, obj.Data, uid)
+		}
+		timezone.Embed(cal)
+		if _, err := c.client.PutCalendarObject(dav.IfMatch(ctx, obj.ETag), obj.Path, cal); err != nil {
+			return nil, fmt.Errorf("failed to update event: %w", err)
+		}
+	case !errors.Is(err, ErrEventNotFound):
+		return nil, err
+	case partstat != PartStatDeclined:
+		timezone.Embed(cal)
+		if _, err := c.client.PutCalendarObject(dav.IfNoneMatch(ctx), calPath+"/"+uid+".ics", cal); err != nil {
+			return nil, fmt.Errorf("failed to create event: %w", err)
+		}
+	}
+	return parseICalEvent(cal)
+}
+
+// mergeOverrides puts the occurrences in msg into cal, replacing the
+// overrides with the same RECURRENCE-ID unless those have a higher
+// SEQUENCE. It reports whether any occurrence was merged.
+func mergeOverrides(cal, msg *ical.Calendar, uid string) bool {
+	merged := false
+	for _, comp := range msg.Children {
+		if comp.Name != ical.CompEvent || propValue(comp, ical.PropUID) != uid {
+			continue
+		}
+		seq, _ := strconv.Atoi(propValue(comp, ical.PropSequence))
+		replaced := false
+		for i, prev := range cal.Children {
+			if prev.Name != ical.CompEvent || propValue(prev, ical.PropUID) != uid ||
+				!sameRecurrence(prev.Props.Get(ical.PropRecurrenceID), comp.Props.Get(ical.PropRecurrenceID)) {
+				continue
+			}
+			replaced = true
+			if prevSeq, _ := strconv.Atoi(propValue(prev, ical.PropSequence)); seq >= prevSeq {
+				if !hasAlarms(comp) {
+					copyAlarms(comp, prev)
+				}
+				cal.Children[i] = comp
+				merged = true
+			}
+			break
+		}
+		if !replaced {
+			cal.Children = append(cal.Children, comp)
+			merged = true
+		}
+	}
+	return merged
+}
+
+// keepAlarms copies the VALARMs of the events in old to the events with
+// the same RECURRENCE-ID in cal that have none.
+func keepAlarms(cal, old *ical.Calendar, uid string) {
+	for _, comp := range cal.Children {
+		if comp.Name != ical.CompEvent || propValue(comp, ical.PropUID) != uid || hasAlarms(comp) {
+			continue
+		}
+		for _, prev := range old.Children {
+			if prev.Name == ical.CompEvent && propValue(prev, ical.PropUID) == uid &&
+				propValue(prev, ical.PropRecurrenceID) == propValue(comp, ical.PropRecurrenceID) {
+				copyAlarms(comp, prev)
+			}
+		}
+	}
+}
+
+func copyAlarms(dst, src *ical.Component) {
+	for _, child := range src.Children {
+		if child.Name == ical.CompAlarm {
+			dst.Children = append(dst.Children, child)
+		}
+	}
+}
+
+func hasAlarms(comp *ical.Component) bool {
+	for _, child := range comp.Children {
+		if child.Name == ical.CompAlarm {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyCancel applies a cancellation (an iTIP CANCEL) emailed by from to
+// the event in the calendar: the event, or the occurrence given by
+// RECURRENCE-ID, is marked CANCELLED. It fails with ErrEventNotFound if
+// the event is in none of the calendars, and with ErrWrongSender unless
+// from is the organizer of the event in the calendar. The event is
+// returned.
+func (c *Client) ApplyCancel(ctx context.Context, calPath string, data []byte, from string) (*Event, error) {
+	msg, uid, seq, err := decodeMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := c.findEventObject(ctx, calPath, uid)
+	if err != nil {
+		return nil, err
+	}
+	master := findMaster(obj.Data, uid)
+	if master == nil || from == "" || !strings.EqualFold(calAddress(propValue(master, ical.PropOrganizer)), from) {
+		return nil, ErrWrongSender
+	}
+	if seq < objectSequence(obj.Data, uid) {
+		return nil, ErrOutdated
+	}
+
+	for _, cancel := range msg.Children {
+		if cancel.Name != ical.CompEvent || propValue(cancel, ical.PropUID) != uid {
+			continue
+		}
+		rid := cancel.Props.Get(ical.PropRecurrenceID)
+		if rid == nil {
+			for _, comp := range obj.Data.Children {
+				if comp.Name == ical.CompEvent && propValue(comp, ical.PropUID) == uid {
+					cancelComponent(comp, seq)
+				}
+			}
+			continue
+		}
+		if err := cancelOccurrence(obj.Data, uid, rid, seq); err != nil {
+			return nil, err
+		}
+	}
+
+	timezone.Embed(obj.Data)
+	if _, err := c.client.PutCalendarObject(dav.IfMatch(ctx, obj.ETag), obj.Path, obj.Data); err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	return parseICalEvent(obj.Data)
+}
+
+// cancelComponent marks an event CANCELLED with the organizer's SEQUENCE.
+func cancelComponent(comp *ical.Component, seq int) {
+	status := ical.NewProp(ical.PropStatus)
+	status.Value = "CANCELLED"
+	comp.Props.Set(status)
+	sequence := ical.NewProp(ical.PropSequence)
+	sequence.Value = strconv.Itoa(seq)
+	comp.Props.Set(sequence)
+	bumpRevision(comp, false)
+}
+
+// cancelOccurrence cancels one occurrence of a recurring event: its
+// override is marked CANCELLED, or an EXDATE is added to the series.
+func cancelOccurrence(cal *ical.Calendar, uid string, rid *ical.Prop, seq int) error {
+	for _, comp := range findOverrides(cal, uid) {
+		if sameRecurrence(comp.Props.Get(ical.PropRecurrenceID), rid) {
+			cancelComponent(comp, seq)
+			return nil
+		}
+	}
+	master := findMaster(cal, uid)
+	if master == nil {
+		return fmt.Errorf("%w: %s", ErrEventNotFound, uid)
+	}
+	t, err := timezone.ParseProp(rid, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid RECURRENCE-ID: %w", err)
+	}
+	exdate := ical.NewProp(ical.PropExceptionDates)
+	setTimeLike(exdate, t, master.Props.Get(ical.PropDateTimeStart))
+	master.Props.Add(exdate)
+	bumpRevision(master, false)
+	return nil
+}
+
+// sameRecurrence reports whether two RECURRENCE-IDs name the same
+// occurrence, which may be written in different time zones.
+func sameRecurrence(a, b *ical.Prop) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ta, erra := timezone.ParseProp(a, time.Local)
+	tb, errb := timezone.ParseProp(b, time.Local)
+	if erra != nil || errb != nil {
+		return a.Value == b.Value
+	}
+	return ta.Equal(tb)
+}
+
+// ApplyReply applies a reply (an iTIP REPLY) emailed by the attendee from
+// to the account's meeting: their PARTSTAT is set on the event, or on the
+// occurrence given by RECURRENCE-ID. Other attendees in the reply are
+// ignored. It fails with ErrEventNotFound if the meeting is in none of the
+// calendars, and with ErrWrongSender if the reply has no answer from an
+// attendee with the from address. The event is returned.
+func (c *Client) ApplyReply(ctx context.Context, calPath string, data []byte, from string) (*Event, error) {
+	msg, uid, _, err := decodeMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := c.findEventObject(ctx, calPath, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := false
+	for _, reply := range msg.Children {
+		if reply.Name != ical.CompEvent || propValue(reply, ical.PropUID) != uid {
+			continue
+		}
+		rid := reply.Props.Get(ical.PropRecurrenceID)
+		for _, att := range reply.Props[ical.PropAttendee] {
+			partstat := strings.ToUpper(att.Params.Get(ical.ParamParticipationStatus))
+			if partstat == "" || from == "" || !strings.EqualFold(calAddress(att.Value), from) {
+				continue
+			}
+			for _, comp := range obj.Data.Children {
+				if comp.Name != ical.CompEvent || propValue(comp, ical.PropUID) != uid {
+					continue
+				}
+				if rid != nil && !sameRecurrence(comp.Props.Get(ical.PropRecurrenceID), rid) {
+					continue
+				}
+				if setAttendeePartStat(comp, calAddress(att.Value), partstat) {
+					updated = true
+				}
+			}
+		}
+	}
+	if !updated {
+		return nil, ErrWrongSender
+	}
+
+	timezone.Embed(obj.Data)
+	if _, err := c.client.PutCalendarObject(dav.IfMatch(ctx, obj.ETag), obj.Path, obj.Data); err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	return parseICalEvent(obj.Data)
+}
+
+// setAttendeePartStat sets the PARTSTAT of an attendee, reporting whether
+// they are one.
+func setAttendeePartStat(comp *ical.Component, address, partstat string) bool {
+	found := false
+	for i := range comp.Props[ical.PropAttendee] {
+		prop := &comp.Props[ical.PropAttendee][i]
+		if !strings.EqualFold(calAddress(prop.Value), address) {
+			continue
+		}
+		prop.Params.Set(ical.ParamParticipationStatus, partstat)
+		prop.Params.Del(ical.ParamRSVP)
+		found = true
+	}
+	if found {
+		bumpRevision(comp, false)
+	}
+	return found
+}
@@ -0,0 +1,109 @@
+package caldav
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// onCalendar turns a scheduling message into the stored event.
+func onCalendar(msg string) string {
+	return strings.Replace(msg, "METHOD:REQUEST\n", "", 1)
+}
+
+func TestSaveInvitation(t *testing.T) {
+	server := &schedulingServer{}
+	client := newSchedulingClient(t, server)
+	event, err := client.SaveInvitation(context.Background(), "/cal", []byte(inboxRequest), PartStatAccepted)
+	require.NoError(t, err)
+	assert.Equal(t, PartStatAccepted, event.PartStat.Get("me@alias.example.com"))
+	data := server.puts["/cal/meeting@example.com.ics"]
+	assert.NotContains(t, data, "METHOD")
+	assert.Contains(t, data, "PARTSTAT=ACCEPTED")
+
+	// Declined invitations are not added
+	server = &schedulingServer{}
+	client = newSchedulingClient(t, server)
+	_, err = client.SaveInvitation(context.Background(), "/cal", []byte(inboxRequest), PartStatDeclined)
+	require.NoError(t, err)
+	assert.Empty(t, server.puts)
+
+	// Updates replace the event and keep its reminders
+	stored := strings.Replace(onCalendar(inboxRequest), "SEQUENCE:2", "SEQUENCE:1", 1)
+	stored = strings.Replace(stored, "END:VEVENT", "BEGIN:VALARM\nACTION:DISPLAY\nTRIGGER:-PT15M\nEND:VALARM\nEND:VEVENT", 1)
+	server = &schedulingServer{calendar: stored}
+	client = newSchedulingClient(t, server)
+	update := strings.Replace(inboxRequest, "SUMMARY:Planning", "SUMMARY:Planning (moved)", 1)
+	event, err = client.SaveInvitation(context.Background(), "/cal", []byte(update), PartStatTentative)
+	require.NoError(t, err)
+	assert.Equal(t, "Planning (moved)", event.Summary)
+	data = server.puts["/cal/meeting.ics"]
+	assert.Contains(t, data, "PARTSTAT=TENTATIVE")
+	assert.Contains(t, data, "TRIGGER:-PT15M")
+
+	// Older messages are rejected
+	server.calendar = strings.Replace(stored, "SEQUENCE:1", "SEQUENCE:3", 1)
+	_, err = client.SaveInvitation(context.Background(), "/cal", []byte(update), PartStatAccepted)
+	assert.ErrorIs(t, err, ErrOutdated)
+}
+
+func TestApplyCancel(t *testing.T) {
+	server := &schedulingServer{calendar: onCalendar(inboxRequest)}
+	client := newSchedulingClient(t, server)
+	cancel := strings.Replace(inboxRequest, "METHOD:REQUEST", "METHOD:CANCEL", 1)
+	event, err := client.ApplyCancel(context.Background(), "/cal", []byte(cancel), "Alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "CANCELLED", event.Status)
+	assert.Contains(t, server.puts["/cal/meeting.ics"], "STATUS:CANCELLED")
+
+	// One occurrence of a recurring meeting
+	server.calendar = strings.Replace(onCalendar(inboxRequest), "SUMMARY:Planning", "SUMMARY:Planning\nRRULE:FREQ=DAILY;COUNT=5", 1)
+	occurrence := strings.Replace(cancel, "SUMMARY:Planning", "SUMMARY:Planning\nRECURRENCE-ID:20260303T090000Z", 1)
+	_, err = client.ApplyCancel(context.Background(), "/cal", []byte(occurrence), "alice@example.com")
+	require.NoError(t, err)
+	data := server.puts["/cal/meeting.ics"]
+	assert.Contains(t, data, "EXDATE:20260303T090000Z")
+	assert.NotContains(t, data, "STATUS:CANCELLED")
+
+	// Only the organizer can cancel
+	server.puts = map[string]string{}
+	_, err = client.ApplyCancel(context.Background(), "/cal", []byte(cancel), "me@alias.example.com")
+	assert.ErrorIs(t, err, ErrWrongSender)
+	assert.Empty(t, server.puts)
+
+	// Not on the calendar
+	server.calendar = ""
+	_, err = client.ApplyCancel(context.Background(), "/cal", []byte(cancel), "Alice@example.com")
+	assert.ErrorIs(t, err, ErrEventNotFound)
+}
+
+func TestApplyReply(t *testing.T) {
+	server := &schedulingServer{calendar: onCalendar(inboxRequest)}
+	client := newSchedulingClient(t, server)
+	reply := `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Other//EN
+METHOD:REPLY
+BEGIN:VEVENT
+UID:meeting@example.com
+DTSTAMP:20260301T100000Z
+SEQUENCE:2
+ORGANIZER:mailto:alice@example.com
+ATTENDEE;PARTSTAT=DECLINED:mailto:me@alias.example.com
+ATTENDEE;PARTSTAT=DECLINED:mailto:alice@example.com
+END:VEVENT
+END:VCALENDAR
+`
+	// Only the answer of the sender is applied
+	event, err := client.ApplyReply(context.Background(), "/cal", []byte(reply), "me@alias.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, PartStatDeclined, event.PartStat.Get("me@alias.example.com"))
+	assert.Equal(t, PartStatAccepted, event.PartStat.Get("alice@example.com"))
+	assert.Contains(t, server.puts["/cal/meeting.ics"], "PARTSTAT=DECLINED")
+
+	_, err = client.ApplyReply(context.Background(), "/cal", []byte(reply), "eve@example.com")
+	assert.ErrorIs(t, err, ErrWrongSender)
+}
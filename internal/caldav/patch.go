@@ -0,0 +1,324 @@
+package caldav
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/visionik/sogcli/internal/timezone"
+)
+
+// Updates patch the stored component instead of rebuilding it from the
+// Event or Task struct: only properties whose value changed are written,
+// and every other property, parameter (PARTSTAT, CN, TZID, ...) and nested
+// component (VALARM) is kept as it is.
+
+// patchEvent applies the differences between old and updated to the VEVENT
+// comp. old must be the event parsed from comp. It reports whether
+// anything changed; if so, SEQUENCE, LAST-MODIFIED and DTSTAMP are bumped.
+func patchEvent(comp *ical.Component, old, updated *Event) bool {
+	changed := false
+	changed = patchText(comp, ical.PropSummary, old.Summary, updated.Summary) || changed
+	changed = patchText(comp, ical.PropDescription, old.Description, updated.Description) || changed
+	changed = patchText(comp, ical.PropLocation, old.Location, updated.Location) || changed
+	changed = patchText(comp, ical.PropStatus, old.Status, updated.Status) || changed
+	changed = patchValue(comp, ical.PropURL, old.URL, updated.URL) || changed
+	changed = patchValue(comp, ical.PropTransparency, transparency(old), transparency(updated)) || changed
+	changed = patchAddress(comp, ical.PropOrganizer, old.Organizer, updated.Organizer) || changed
+	changed = patchAddresses(comp, ical.PropAttendee, old.Attendees, updated.Attendees) || changed
+
+	// Times keep their TZID or UTC form unless the event switches between
+	// all-day and timed
+	start := comp.Props.Get(ical.PropDateTimeStart)
+	if !old.Start.Equal(updated.Start) || old.AllDay != updated.AllDay {
+		prop := ical.NewProp(ical.PropDateTimeStart)
+		setEventTime(prop, updated.Start, updated.AllDay, start, old.AllDay == updated.AllDay)
+		comp.Props.Set(prop)
+		start = comp.Props.Get(ical.PropDateTimeStart)
+		changed = true
+	}
+	if !old.End.Equal(updated.End) || old.AllDay != updated.AllDay {
+		if comp.Props.Get(ical.PropDuration) != nil && old.AllDay == updated.AllDay {
+			prop := ical.NewProp(ical.PropDuration)
+			prop.SetDuration(updated.End.Sub(updated.Start))
+			comp.Props.Set(prop)
+		} else if !updated.End.IsZero() {
+			comp.Props.Del(ical.PropDuration)
+			prop := ical.NewProp(ical.PropDateTimeEnd)
+			setEventTime(prop, updated.End, updated.AllDay, start, true)
+			comp.Props.Set(prop)
+		}
+		changed = true
+	}
+
+	if old.RRule != updated.RRule {
+		if updated.RRule == "" {
+			comp.Props.Del(ical.PropRecurrenceRule)
+		} else {
+			prop := ical.NewProp(ical.PropRecurrenceRule)
+			prop.Value = updated.RRule
+			comp.Props.Set(prop)
+		}
+		changed = true
+	}
+	changed = patchTimes(comp, ical.PropRecurrenceDates, old.RDates, updated.RDates, start) || changed
+	changed = patchTimes(comp, ical.PropExceptionDates, old.ExDates, updated.ExDates, start) || changed
+	changed = patchAlarms(comp, old.Alarms, updated.Alarms, updated.Summary) || changed
+
+	if changed {
+		bumpRevision(comp, true)
+	}
+	return changed
+}
+
+// transparency returns the TRANSP value written for an event. Opaque is
+// the default and is left out.
+func transparency(event *Event) string {
+	if event.Transparent {
+		return "TRANSPARENT"
+	}
+	return ""
+}
+
+// patchTask applies the differences between old and updated to the VTODO
+// comp. old must be the task parsed from comp.
+func patchTask(comp *ical.Component, old, updated *Task) bool {
+	changed := false
+	changed = patchText(comp, ical.PropSummary, old.Summary, updated.Summary) || changed
+	changed = patchText(comp, ical.PropDescription, old.Description, updated.Description) || changed
+	changed = patchText(comp, ical.PropStatus, old.Status, updated.Status) || changed
+	changed = patchInt(comp, ical.PropPriority, old.Priority, updated.Priority) || changed
+	changed = patchInt(comp, ical.PropPercentComplete, old.Percent, updated.Percent) || changed
+	changed = patchDateTime(comp, ical.PropDue, old.Due, updated.Due) || changed
+	changed = patchDateTime(comp, ical.PropDateTimeStart, old.Start, updated.Start) || changed
+	changed = patchDateTime(comp, ical.PropCompleted, old.Completed, updated.Completed) || changed
+	changed = patchValue(comp, ical.PropRecurrenceRule, old.RRule, updated.RRule) || changed
+
+	if strings.Join(old.Categories, ",") != strings.Join(updated.Categories, ",") {
+		comp.Props.Del(ical.PropCategories)
+		if len(updated.Categories) > 0 {
+			prop := ical.NewProp(ical.PropCategories)
+			prop.SetTextList(updated.Categories)
+			comp.Props.Set(prop)
+		}
+		changed = true
+	}
+	changed = patchAlarms(comp, old.Alarms, updated.Alarms, updated.Summary) || changed
+	changed = patchRelations(comp, old, updated) || changed
+
+	if changed {
+		bumpRevision(comp, true)
+	}
+	return changed
+}
+
+// bumpRevision marks comp as modified now. SEQUENCE is incremented when
+// sequence is true.
+func bumpRevision(comp *ical.Component, sequence bool) {
+	now := time.Now().UTC()
+	if sequence {
+		seq := 0
+		if prop := comp.Props.Get(ical.PropSequence); prop != nil {
+			seq, _ = prop.Int()
+		}
+		prop := ical.NewProp(ical.PropSequence)
+		prop.Value = strconv.Itoa(seq + 1)
+		comp.Props.Set(prop)
+	}
+	comp.Props.SetDateTime(ical.PropLastModified, now)
+	comp.Props.SetDateTime(ical.PropDateTimeStamp, now)
+}
+
+// patchText sets a text property if its value changed, keeping its
+// parameters (e.g. LANGUAGE). An empty value removes the property.
+func patchText(comp *ical.Component, name, old, updated string) bool {
+	if old == updated {
+		return false
+	}
+	if updated == "" {
+		comp.Props.Del(name)
+		return true
+	}
+	prop := comp.Props.Get(name)
+	if prop == nil {
+		comp.Props.SetText(name, updated)
+		return true
+	}
+	prop.SetText(updated)
+	return true
+}
+
+// patchValue sets a property whose value needs no escaping, like URL.
+func patchValue(comp *ical.Component, name, old, updated string) bool {
+	if old == updated {
+		return false
+	}
+	if updated == "" {
+		comp.Props.Del(name)
+		return true
+	}
+	if prop := comp.Props.Get(name); prop != nil {
+		prop.Value = updated
+		return true
+	}
+	prop := ical.NewProp(name)
+	prop.Value = updated
+	comp.Props.Set(prop)
+	return true
+}
+
+// patchInt sets an integer property if its value changed. Zero removes it.
+func patchInt(comp *ical.Component, name string, old, updated int) bool {
+	if old == updated {
+		return false
+	}
+	if updated == 0 {
+		comp.Props.Del(name)
+		return true
+	}
+	prop := ical.NewProp(name)
+	prop.Value = strconv.Itoa(updated)
+	comp.Props.Set(prop)
+	return true
+}
+
+// patchDateTime sets a date-time property if its value changed, keeping
+// its TZID and value type. A zero time removes it.
+func patchDateTime(comp *ical.Component, name string, old, updated time.Time) bool {
+	if old.Equal(updated) {
+		return false
+	}
+	if updated.IsZero() {
+		comp.Props.Del(name)
+		return true
+	}
+	prop := ical.NewProp(name)
+	setTimeLike(prop, updated, comp.Props.Get(name))
+	comp.Props.Set(prop)
+	return true
+}
+
+// patchTimes rewrites a multi-valued date property like EXDATE if the set
+// of times changed, formatting values like DTSTART.
+func patchTimes(comp *ical.Component, name string, old, updated []time.Time, start *ical.Prop) bool {
+	if sameTimes(old, updated) {
+		return false
+	}
+	comp.Props.Del(name)
+	for _, t := range updated {
+		prop := ical.NewProp(name)
+		setTimeLike(prop, t, start)
+		comp.Props.Add(prop)
+	}
+	return true
+}
+
+func sameTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// setEventTime sets DTSTART or DTEND. With keepForm the value takes the
+// form of ref (the old DTSTART); otherwise it is a date or a time in t's
+// zone.
+func setEventTime(prop *ical.Prop, t time.Time, allDay bool, ref *ical.Prop, keepForm bool) {
+	switch {
+	case allDay:
+		prop.SetDate(t)
+	case keepForm && ref != nil:
+		setTimeLike(prop, t, ref)
+	default:
+		timezone.SetTime(prop, t)
+	}
+}
+
+// patchAddress sets a cal-address property (ORGANIZER) if the address
+// changed, keeping parameters like CN when only the case differs.
+func patchAddress(comp *ical.Component, name, old, updated string) bool {
+	if strings.EqualFold(old, updated) {
+		return false
+	}
+	if updated == "" {
+		comp.Props.Del(name)
+		return true
+	}
+	prop := ical.NewProp(name)
+	prop.Value = "mailto:" + updated
+	comp.Props.Set(prop)
+	return true
+}
+
+// patchAddresses updates a multi-valued cal-address property (ATTENDEE).
+// Addresses that stay keep their property with all parameters, so
+// PARTSTAT, ROLE and CN survive.
+func patchAddresses(comp *ical.Component, name string, old, updated []string) bool {
+	if sameAddresses(old, updated) {
+		return false
+	}
+
+	wanted := map[string]bool{}
+	for _, addr := range updated {
+		wanted[strings.ToLower(addr)] = true
+	}
+	var props []ical.Prop
+	for _, prop := range comp.Props[name] {
+		addr := strings.ToLower(calAddress(prop.Value))
+		if wanted[addr] {
+			props = append(props, prop)
+			delete(wanted, addr)
+		}
+	}
+	for _, addr := range updated {
+		if wanted[strings.ToLower(addr)] {
+			prop := ical.NewProp(name)
+			prop.Value = "mailto:" + addr
+			props = append(props, *prop)
+			delete(wanted, strings.ToLower(addr))
+		}
+	}
+
+	if len(props) == 0 {
+		comp.Props.Del(name)
+	} else {
+		comp.Props[name] = props
+	}
+	return true
+}
+
+func sameAddresses(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// cloneComponent returns a deep copy of comp.
+func cloneComponent(comp *ical.Component) *ical.Component {
+	clone := ical.NewComponent(comp.Name)
+	for name, props := range comp.Props {
+		for _, prop := range props {
+			copied := ical.Prop{Name: prop.Name, Value: prop.Value, Params: make(ical.Params, len(prop.Params))}
+			for k, v := range prop.Params {
+				copied.Params[k] = append([]string(nil), v...)
+			}
+			clone.Props[name] = append(clone.Props[name], copied)
+		}
+	}
+	for _, child := range comp.Children {
+		clone.Children = append(clone.Children, cloneComponent(child))
+	}
+	return clone
+}
@@ -0,0 +1,134 @@
+package caldav
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const richEvent = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Other//Client//EN
+BEGIN:VTIMEZONE
+TZID:Europe/Berlin
+BEGIN:STANDARD
+DTSTART:19701025T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+END:STANDARD
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:rich@example.com
+DTSTAMP:20260101T000000Z
+SEQUENCE:2
+SUMMARY;LANGUAGE=en:Planning
+DTSTART;TZID=Europe/Berlin:20260115T100000
+DTEND;TZID=Europe/Berlin:20260115T110000
+RRULE:FREQ=WEEKLY
+CATEGORIES:Work
+ORGANIZER;CN=Boss:mailto:boss@example.com
+ATTENDEE;PARTSTAT=ACCEPTED;CN=Alice:mailto:alice@example.com
+ATTENDEE;PARTSTAT=DECLINED:mailto:bob@example.com
+X-CUSTOM:keep me
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER:-PT15M
+DESCRIPTION:Reminder
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestPatchEvent(t *testing.T) {
+	cal := decodeCalendar(t, richEvent)
+	comp := findMaster(cal, "rich@example.com")
+	old := eventFromComponent(comp)
+
+	updated := *old
+	updated.Summary = "Planning, v2"
+	updated.Attendees = []string{"alice@example.com", "carol@example.com"}
+	updated.Start = old.Start.Add(time.Hour)
+	updated.End = old.End.Add(time.Hour)
+	require.True(t, patchEvent(comp, old, &updated))
+
+	// Changed properties keep their parameters and form
+	assert.Equal(t, "en", comp.Props.Get(ical.PropSummary).Params.Get(ical.ParamLanguage))
+	assert.Equal(t, `Planning\, v2`, comp.Props.Get(ical.PropSummary).Value)
+	start := comp.Props.Get(ical.PropDateTimeStart)
+	assert.Equal(t, "Europe/Berlin", start.Params.Get(ical.PropTimezoneID))
+	assert.Equal(t, "20260115T110000", start.Value)
+
+	attendees := comp.Props[ical.PropAttendee]
+	require.Len(t, attendees, 2)
+	assert.Equal(t, "ACCEPTED", attendees[0].Params.Get(ical.ParamParticipationStatus))
+	assert.Equal(t, "mailto:carol@example.com", attendees[1].Value)
+
+	// Everything else is untouched
+	assert.Equal(t, "FREQ=WEEKLY", comp.Props.Get(ical.PropRecurrenceRule).Value)
+	assert.Equal(t, "keep me", comp.Props.Get("X-CUSTOM").Value)
+	assert.Equal(t, "Boss", comp.Props.Get(ical.PropOrganizer).Params.Get(ical.ParamCommonName))
+	require.Len(t, comp.Children, 1)
+	assert.Equal(t, ical.CompAlarm, comp.Children[0].Name)
+	assert.Equal(t, ical.CompTimezone, cal.Children[0].Name)
+
+	// Revision bumped
+	assert.Equal(t, "3", comp.Props.Get(ical.PropSequence).Value)
+	assert.NotNil(t, comp.Props.Get(ical.PropLastModified))
+
+	var buf bytes.Buffer
+	require.NoError(t, ical.NewEncoder(&buf).Encode(cal))
+
+	// No changes, no revision
+	again := eventFromComponent(comp)
+	assert.False(t, patchEvent(comp, again, again))
+	assert.Equal(t, "3", comp.Props.Get(ical.PropSequence).Value)
+}
+
+func TestPatchTask(t *testing.T) {
+	cal := decodeCalendar(t, `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VTODO
+UID:task@example.com
+DTSTAMP:20260101T000000Z
+SUMMARY:Write report
+DUE;VALUE=DATE:20260120
+CATEGORIES:work,urgent
+X-APPLE-SORT-ORDER:42
+END:VTODO
+END:VCALENDAR`)
+	comp := cal.Children[0]
+	old := taskFromComponent(comp)
+	assert.Equal(t, []string{"work", "urgent"}, old.Categories)
+
+	updated := *old
+	updated.Status = TaskStatusCompleted
+	updated.Percent = 100
+	updated.Completed = time.Date(2026, 1, 18, 12, 0, 0, 0, time.UTC)
+	updated.Due = old.Due.AddDate(0, 0, 1)
+	require.True(t, patchTask(comp, old, &updated))
+
+	assert.Equal(t, "COMPLETED", comp.Props.Get(ical.PropStatus).Value)
+	assert.Equal(t, "100", comp.Props.Get(ical.PropPercentComplete).Value)
+	assert.Equal(t, "20260121", comp.Props.Get(ical.PropDue).Value)
+	assert.Equal(t, "42", comp.Props.Get("X-APPLE-SORT-ORDER").Value)
+	assert.Equal(t, "1", comp.Props.Get(ical.PropSequence).Value)
+
+	parsed := taskFromComponent(comp)
+	assert.Equal(t, updated.Categories, parsed.Categories)
+	assert.True(t, parsed.Completed.Equal(updated.Completed))
+}
+
+func TestCloneComponent(t *testing.T) {
+	cal := decodeCalendar(t, richEvent)
+	comp := findMaster(cal, "rich@example.com")
+	clone := cloneComponent(comp)
+
+	clone.Props.Get(ical.PropAttendee).Params.Set(ical.ParamParticipationStatus, "TENTATIVE")
+	clone.Children[0].Props.SetText(ical.PropDescription, "changed")
+	assert.Equal(t, "ACCEPTED", comp.Props.Get(ical.PropAttendee).Params.Get(ical.ParamParticipationStatus))
+	assert.Equal(t, "Reminder", comp.Children[0].Props.Get(ical.PropDescription).Value)
+}
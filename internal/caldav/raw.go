@@ -0,0 +1,169 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// The parts of CalDAV that go-webdav does not cover (free/busy, scheduling)
+// are spoken directly over the client's HTTP connection.
+
+const nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+
+// statusError is an unexpected HTTP response status.
+type statusError struct {
+	Method string
+	Path   string
+	Code   int
+	Status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.Path, e.Status)
+}
+
+// resolve turns a server path into an absolute URL.
+func (c *Client) resolve(path string) (string, error) {
+	base, err := url.Parse(c.calURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid CalDAV URL: %w", err)
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path %q: %w", path, err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// do sends a request and returns the response body if the status is one
+// of ok; otherwise it returns a *statusError.
+func (c *Client) do(ctx context.Context, method, path string, header http.Header, body []byte, ok ...int) ([]byte, http.Header, error) {
+	target, err := c.resolve(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s response: %w", method, err)
+	}
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return data, resp.Header, nil
+		}
+	}
+	return nil, nil, &statusError{Method: method, Path: path, Code: resp.StatusCode, Status: resp.Status}
+}
+
+// multistatus is a WebDAV 207 response body.
+type multistatus struct {
+	XMLName   xml.Name      `xml:"DAV: multistatus"`
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Status    string        `xml:"DAV: status"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"DAV: prop"`
+	Status string  `xml:"DAV: status"`
+}
+
+type davProp struct {
+	Values []rawProp `xml:",any"`
+}
+
+// rawProp is a property value: its DAV:href children or its text.
+type rawProp struct {
+	XMLName xml.Name
+	Hrefs   []string `xml:"DAV: href"`
+	Text    string   `xml:",chardata"`
+}
+
+// prop returns the value of a property found with a 2xx status.
+func (r davResponse) prop(name xml.Name) *rawProp {
+	for _, ps := range r.Propstats {
+		if ps.Status != "" && !strings.Contains(ps.Status, " 2") {
+			continue
+		}
+		for i, v := range ps.Prop.Values {
+			if v.XMLName == name {
+				return &ps.Prop.Values[i]
+			}
+		}
+	}
+	return nil
+}
+
+// hrefs returns the DAV:href elements in a property value.
+func (p *rawProp) hrefs() []string {
+	if p == nil {
+		return nil
+	}
+	var hrefs []string
+	for _, href := range p.Hrefs {
+		hrefs = append(hrefs, strings.TrimSpace(href))
+	}
+	return hrefs
+}
+
+// propfind fetches properties of path with the given depth ("0" or "1").
+func (c *Client) propfind(ctx context.Context, path, depth string, names ...xml.Name) ([]davResponse, error) {
+	var ms multistatus
+	if err := c.propfindInto(ctx, path, depth, &ms, names...); err != nil {
+		return nil, err
+	}
+	return ms.Responses, nil
+}
+
+// propfindInto sends a PROPFIND and decodes the multistatus response into v.
+func (c *Client) propfindInto(ctx context.Context, path, depth string, v any, names ...xml.Name) error {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	buf.WriteString(`<D:propfind xmlns:D="DAV:"><D:prop>`)
+	for _, name := range names {
+		fmt.Fprintf(&buf, `<x:%s xmlns:x="%s"/>`, name.Local, name.Space)
+	}
+	buf.WriteString(`</D:prop></D:propfind>`)
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	header.Set("Depth", depth)
+	data, _, err := c.do(ctx, "PROPFIND", path, header, buf.Bytes(), http.StatusMultiStatus)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse PROPFIND response: %w", err)
+	}
+	return nil
+}
+
+// principal returns the path of the current user's principal.
+func (c *Client) principal(ctx context.Context) (string, error) {
+	principal, err := c.client.FindCurrentUserPrincipal(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to find principal: %w", err)
+	}
+	return principal, nil
+}
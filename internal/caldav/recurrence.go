@@ -0,0 +1,334 @@
+package caldav
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/teambition/rrule-go"
+	"github.com/visionik/sogcli/internal/timezone"
+)
+
+// IsRecurring reports whether the event is part of a recurring series.
+func (e *Event) IsRecurring() bool {
+	return e.RRule != "" || len(e.RDates) > 0 || !e.RecurrenceID.IsZero()
+}
+
+// ExpandEvents returns the occurrences of the events in cal that overlap
+// [start, end), sorted by start time. Recurring events are expanded from
+// RRULE, RDATE and EXDATE, and RECURRENCE-ID overrides replace the
+// occurrences they modify. Cancelled occurrences are omitted. Overrides
+// without a master, as returned by servers that expand recurrences
+// themselves, are returned as they are.
+func ExpandEvents(cal *ical.Calendar, start, end time.Time) ([]Event, error) {
+	if cal == nil {
+		return nil, fmt.Errorf("nil calendar data")
+	}
+	timezone.Register(cal)
+
+	masters := map[string]bool{}
+	overrides := map[string][]*ical.Component{}
+	for _, child := range cal.Children {
+		if child.Name != ical.CompEvent {
+			continue
+		}
+		uid := propValue(child, ical.PropUID)
+		if child.Props.Get(ical.PropRecurrenceID) != nil {
+			overrides[uid] = append(overrides[uid], child)
+		} else {
+			masters[uid] = true
+		}
+	}
+
+	var events []Event
+	for _, child := range cal.Children {
+		if child.Name != ical.CompEvent {
+			continue
+		}
+		event := eventFromComponent(child)
+		switch {
+		case child.Props.Get(ical.PropRecurrenceID) == nil:
+			occurrences, err := expandEvent(event, overrides[event.UID], start, end)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, occurrences...)
+		case !masters[event.UID]:
+			if !isCancelled(event) && overlaps(event, start, end) {
+				events = append(events, *event)
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events, nil
+}
+
+// expandEvent returns the occurrences of master that overlap [start, end).
+func expandEvent(master *Event, overrides []*ical.Component, start, end time.Time) ([]Event, error) {
+	if master.RRule == "" && len(master.RDates) == 0 {
+		if isCancelled(master) || !overlaps(master, start, end) {
+			return nil, nil
+		}
+		return []Event{*master}, nil
+	}
+
+	set, err := recurrenceSet(master)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand %s: %w", master.UID, err)
+	}
+
+	modified := map[int64]*Event{}
+	for _, comp := range overrides {
+		override := eventFromComponent(comp)
+		modified[override.RecurrenceID.Unix()] = override
+	}
+
+	var events []Event
+	dur := eventDuration(master)
+	for _, t := range set.Between(start.Add(-dur), end, true) {
+		if override, ok := modified[t.Unix()]; ok {
+			delete(modified, t.Unix())
+			if !isCancelled(override) && overlaps(override, start, end) {
+				events = append(events, *override)
+			}
+			continue
+		}
+		occurrence := occurrenceAt(master, t)
+		if overlaps(&occurrence, start, end) {
+			events = append(events, occurrence)
+		}
+	}
+
+	// Overrides that moved an occurrence from outside the range into it
+	for _, override := range modified {
+		if !isCancelled(override) && overlaps(override, start, end) {
+			events = append(events, *override)
+		}
+	}
+	return events, nil
+}
+
+// recurrenceSet builds the recurrence set of a master event. DTSTART is
+// always the first occurrence, as RFC 5545 requires.
+func recurrenceSet(master *Event) (*rrule.Set, error) {
+	set := &rrule.Set{}
+	if master.RRule != "" {
+		opt, err := rrule.StrToROptionInLocation(master.RRule, master.Start.Location())
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE %q: %w", master.RRule, err)
+		}
+		opt.Dtstart = master.Start
+		rule, err := rrule.NewRRule(*opt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE %q: %w", master.RRule, err)
+		}
+		set.RRule(rule)
+	}
+	set.DTStart(master.Start)
+	set.RDate(master.Start)
+	for _, t := range master.RDates {
+		set.RDate(t)
+	}
+	for _, t := range master.ExDates {
+		set.ExDate(t)
+	}
+	return set, nil
+}
+
+// occurrenceAt returns a copy of master starting at t.
+func occurrenceAt(master *Event, t time.Time) Event {
+	occurrence := *master
+	occurrence.Start = t
+	if master.AllDay {
+		occurrence.End = t.AddDate(0, 0, int(eventDuration(master).Hours()+12)/24)
+	} else {
+		occurrence.End = t.Add(eventDuration(master))
+	}
+	occurrence.RecurrenceID = t
+	return occurrence
+}
+
+// resolveOccurrence finds the original start (RECURRENCE-ID) of the
+// occurrence of master identified by on: the occurrence starting exactly
+// at on, or, if on is midnight, the first occurrence on that day.
+// Occurrences moved by an override are found at their new start.
+func resolveOccurrence(cal *ical.Calendar, master *Event, on time.Time) (time.Time, error) {
+	dayOnly := on.Hour() == 0 && on.Minute() == 0 && on.Second() == 0
+	matches := func(t time.Time) bool {
+		if !dayOnly {
+			return t.Equal(on)
+		}
+		y, m, d := t.In(on.Location()).Date()
+		return y == on.Year() && m == on.Month() && d == on.Day()
+	}
+
+	for _, comp := range findOverrides(cal, master.UID) {
+		override := eventFromComponent(comp)
+		if matches(override.Start) {
+			return override.RecurrenceID, nil
+		}
+	}
+
+	set, err := recurrenceSet(master)
+	if err != nil {
+		return time.Time{}, err
+	}
+	to := on
+	if dayOnly {
+		to = on.AddDate(0, 0, 1)
+	}
+	for _, t := range set.Between(on.Add(-24*time.Hour), to, true) {
+		if matches(t) {
+			return t, nil
+		}
+	}
+
+	if dayOnly {
+		return time.Time{}, fmt.Errorf("%s has no occurrence on %s", master.UID, on.Format("2006-01-02"))
+	}
+	return time.Time{}, fmt.Errorf("%s has no occurrence at %s", master.UID, on.Format("2006-01-02 15:04"))
+}
+
+// findMaster returns the master VEVENT for uid, or nil.
+func findMaster(cal *ical.Calendar, uid string) *ical.Component {
+	for _, child := range cal.Children {
+		if child.Name == ical.CompEvent && propValue(child, ical.PropUID) == uid &&
+			child.Props.Get(ical.PropRecurrenceID) == nil {
+			return child
+		}
+	}
+	return nil
+}
+
+// findOverrides returns the RECURRENCE-ID overrides for uid.
+func findOverrides(cal *ical.Calendar, uid string) []*ical.Component {
+	var overrides []*ical.Component
+	for _, child := range cal.Children {
+		if child.Name == ical.CompEvent && propValue(child, ical.PropUID) == uid &&
+			child.Props.Get(ical.PropRecurrenceID) != nil {
+			overrides = append(overrides, child)
+		}
+	}
+	return overrides
+}
+
+// rulePart returns the value of a part of an RRULE, e.g. "COUNT".
+func rulePart(rule, key string) string {
+	for _, part := range strings.Split(rule, ";") {
+		if k, v, ok := strings.Cut(part, "="); ok && strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// setRulePart sets a part of an RRULE. An empty value removes the part.
+func setRulePart(rule, key, value string) string {
+	var parts []string
+	for _, part := range strings.Split(rule, ";") {
+		if k, _, _ := strings.Cut(part, "="); part == "" || strings.EqualFold(k, key) {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if value != "" {
+		parts = append(parts, key+"="+value)
+	}
+	return strings.Join(parts, ";")
+}
+
+// untilValue formats t as an RRULE UNTIL matching the value type of
+// DTSTART, as RFC 5545 requires.
+func untilValue(t time.Time, start *ical.Prop) string {
+	switch {
+	case start != nil && start.Params.Get(ical.ParamValue) == string(ical.ValueDate):
+		return t.Format("20060102")
+	case start != nil && start.Params.Get(ical.PropTimezoneID) == "" && !strings.HasSuffix(start.Value, "Z"):
+		return t.In(time.Local).Format("20060102T150405")
+	}
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// eventDuration returns the length of an event. All-day events without an
+// end last one day.
+func eventDuration(e *Event) time.Duration {
+	if e.End.After(e.Start) {
+		return e.End.Sub(e.Start)
+	}
+	if e.AllDay {
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// overlaps reports whether e overlaps [start, end). Events without a
+// duration overlap when they start inside the range.
+func overlaps(e *Event, start, end time.Time) bool {
+	if !e.Start.Before(end) {
+		return false
+	}
+	eventEnd := e.Start.Add(eventDuration(e))
+	return eventEnd.After(start) || (eventEnd.Equal(e.Start) && !e.Start.Before(start))
+}
+
+func isCancelled(e *Event) bool {
+	return strings.EqualFold(e.Status, "CANCELLED")
+}
+
+// propValue returns the value of a component property, or "".
+func propValue(comp *ical.Component, name string) string {
+	if prop := comp.Props.Get(name); prop != nil {
+		return prop.Value
+	}
+	return ""
+}
+
+// textValue returns the unescaped value of a text property.
+func textValue(prop *ical.Prop) string {
+	if text, err := prop.Text(); err == nil {
+		return text
+	}
+	return prop.Value
+}
+
+// propTimes parses a multi-valued date or date-time property such as RDATE
+// or EXDATE. Values that cannot be parsed, like RDATE periods, are skipped.
+func propTimes(comp *ical.Component, name string) []time.Time {
+	var times []time.Time
+	for _, prop := range comp.Props[name] {
+		for _, value := range strings.Split(prop.Value, ",") {
+			single := ical.Prop{Name: prop.Name, Params: prop.Params, Value: value}
+			if t, err := timezone.ParseProp(&single, time.Local); err == nil {
+				times = append(times, t)
+			}
+		}
+	}
+	return times
+}
+
+// setTimeLike sets prop to t using the value type and time zone of ref,
+// usually the DTSTART of the series. RECURRENCE-ID, RDATE and EXDATE must
+// match DTSTART this way.
+func setTimeLike(prop *ical.Prop, t time.Time, ref *ical.Prop) {
+	switch {
+	case ref == nil:
+		prop.SetDateTime(t.UTC())
+	case ref.Params.Get(ical.ParamValue) == string(ical.ValueDate):
+		prop.SetDate(t)
+	case ref.Params.Get(ical.PropTimezoneID) != "":
+		tzid := ref.Params.Get(ical.PropTimezoneID)
+		if loc := timezone.Lookup(tzid); loc != nil {
+			t = t.In(loc)
+		}
+		prop.Params.Set(ical.PropTimezoneID, tzid)
+		prop.Value = t.Format("20060102T150405")
+	case strings.HasSuffix(ref.Value, "Z"):
+		prop.SetDateTime(t.UTC())
+	default:
+		// Floating time
+		prop.Value = t.In(time.Local).Format("20060102T150405")
+	}
+}
@@ -0,0 +1,270 @@
+package caldav
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const weeklyStandup = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Test//Test//EN
+BEGIN:VEVENT
+UID:standup@example.com
+DTSTAMP:20250101T000000Z
+SUMMARY:Standup
+DTSTART:20250106T090000Z
+DTEND:20250106T091500Z
+RRULE:FREQ=WEEKLY;BYDAY=MO,WE
+EXDATE:20260114T090000Z
+RDATE:20260116T090000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:standup@example.com
+DTSTAMP:20250101T000000Z
+RECURRENCE-ID:20260112T090000Z
+SUMMARY:Standup (moved)
+DTSTART:20260112T130000Z
+DTEND:20260112T131500Z
+END:VEVENT
+BEGIN:VEVENT
+UID:standup@example.com
+DTSTAMP:20250101T000000Z
+RECURRENCE-ID:20260119T090000Z
+SUMMARY:Standup
+STATUS:CANCELLED
+DTSTART:20260119T090000Z
+DTEND:20260119T091500Z
+END:VEVENT
+END:VCALENDAR`
+
+func decodeCalendar(t *testing.T, data string) *ical.Calendar {
+	t.Helper()
+	cal, err := ical.NewDecoder(strings.NewReader(data)).Decode()
+	require.NoError(t, err)
+	return cal
+}
+
+func utc(s string) time.Time {
+	t, _ := time.Parse("2006-01-02T15:04", s)
+	return t
+}
+
+func TestExpandEvents(t *testing.T) {
+	cal := decodeCalendar(t, weeklyStandup)
+
+	events, err := ExpandEvents(cal, utc("2026-01-12T00:00"), utc("2026-01-22T00:00"))
+	require.NoError(t, err)
+
+	var starts []time.Time
+	for _, e := range events {
+		starts = append(starts, e.Start.UTC())
+	}
+	assert.Equal(t, []time.Time{
+		utc("2026-01-12T13:00"), // moved by override
+		utc("2026-01-16T09:00"), // RDATE
+		utc("2026-01-21T09:00"),
+	}, starts)
+	// 14th excluded by EXDATE, 19th cancelled
+
+	assert.Equal(t, "Standup (moved)", events[0].Summary)
+	assert.Equal(t, utc("2026-01-12T09:00"), events[0].RecurrenceID.UTC())
+	assert.Equal(t, 15*time.Minute, events[2].End.Sub(events[2].Start))
+	assert.Equal(t, utc("2026-01-21T09:00"), events[2].RecurrenceID.UTC())
+	assert.True(t, events[2].IsRecurring())
+}
+
+func TestExpandEvents_ServerExpanded(t *testing.T) {
+	// Servers that support <C:expand> return occurrences without a master
+	cal := decodeCalendar(t, `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:standup@example.com
+RECURRENCE-ID:20260112T090000Z
+SUMMARY:Standup
+DTSTART:20260112T090000Z
+DTEND:20260112T091500Z
+END:VEVENT
+BEGIN:VEVENT
+UID:standup@example.com
+RECURRENCE-ID:20260114T090000Z
+SUMMARY:Standup
+DTSTART:20260114T090000Z
+DTEND:20260114T091500Z
+END:VEVENT
+END:VCALENDAR`)
+
+	events, err := ExpandEvents(cal, utc("2026-01-12T00:00"), utc("2026-01-19T00:00"))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, utc("2026-01-14T09:00"), events[1].Start.UTC())
+}
+
+func TestExpandEvents_AllDayCount(t *testing.T) {
+	cal := decodeCalendar(t, `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:holiday@example.com
+SUMMARY:Conference
+DTSTART;VALUE=DATE:20260301
+DTEND;VALUE=DATE:20260303
+RRULE:FREQ=YEARLY;COUNT=2
+END:VEVENT
+END:VCALENDAR`)
+
+	events, err := ExpandEvents(cal, utc("2026-01-01T00:00"), utc("2030-01-01T00:00"))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.True(t, events[1].AllDay)
+	assert.Equal(t, 2027, events[1].Start.Year())
+	assert.Equal(t, 3, events[1].End.Day())
+}
+
+func TestResolveOccurrence(t *testing.T) {
+	cal := decodeCalendar(t, weeklyStandup)
+	master := eventFromComponent(findMaster(cal, "standup@example.com"))
+
+	rid, err := resolveOccurrence(cal, master, utc("2026-01-21T09:00"))
+	require.NoError(t, err)
+	assert.Equal(t, utc("2026-01-21T09:00"), rid.UTC())
+
+	// By day
+	rid, err = resolveOccurrence(cal, master, time.Date(2026, 1, 21, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, utc("2026-01-21T09:00"), rid.UTC())
+
+	// A moved occurrence is found at its new time
+	rid, err = resolveOccurrence(cal, master, utc("2026-01-12T13:00"))
+	require.NoError(t, err)
+	assert.Equal(t, utc("2026-01-12T09:00"), rid.UTC())
+
+	_, err = resolveOccurrence(cal, master, time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC))
+	assert.Error(t, err)
+	_, err = resolveOccurrence(cal, master, utc("2026-01-14T09:00"))
+	assert.Error(t, err, "excluded occurrence")
+}
+
+func TestSeriesTruncate(t *testing.T) {
+	cal := decodeCalendar(t, weeklyStandup)
+	comp := findMaster(cal, "standup@example.com")
+	s := &series{obj: &caldav.CalendarObject{Data: cal}, masterComp: comp, master: eventFromComponent(comp)}
+
+	require.NoError(t, s.truncate(utc("2026-01-14T09:00")))
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20260114T085959Z", comp.Props.Get(ical.PropRecurrenceRule).Value)
+	assert.Empty(t, comp.Props[ical.PropRecurrenceDates])
+	assert.Empty(t, comp.Props[ical.PropExceptionDates])
+	require.Len(t, findOverrides(cal, "standup@example.com"), 1)
+
+	events, err := ExpandEvents(cal, utc("2026-01-01T00:00"), utc("2026-03-01T00:00"))
+	require.NoError(t, err)
+	require.NotEmpty(t, events)
+	assert.Equal(t, utc("2026-01-12T13:00"), events[len(events)-1].Start.UTC())
+}
+
+func TestRuleCountBefore(t *testing.T) {
+	master := &Event{UID: "x", Start: utc("2026-01-05T09:00"), RRule: "FREQ=DAILY;COUNT=10"}
+	n, err := ruleCountBefore(master, utc("2026-01-08T09:00"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestSetRulePart(t *testing.T) {
+	assert.Equal(t, "FREQ=DAILY", setRulePart("FREQ=DAILY;UNTIL=20260101", "UNTIL", ""))
+	assert.Equal(t, "FREQ=DAILY;COUNT=5", setRulePart("FREQ=DAILY;COUNT=10", "COUNT", "5"))
+	assert.Equal(t, "10", rulePart("FREQ=DAILY;COUNT=10", "count"))
+}
+
+func TestCreateICalEvent_Recurrence(t *testing.T) {
+	event := &Event{
+		UID:     "rec@sog",
+		Summary: "Gym",
+		Start:   utc("2026-01-05T18:00"),
+		End:     utc("2026-01-05T19:00"),
+		RRule:   "FREQ=WEEKLY;BYDAY=MO,TH",
+		ExDates: []time.Time{utc("2026-01-08T18:00")},
+	}
+	cal := createICalEvent(event)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,TH", cal.Children[0].Props.Get(ical.PropRecurrenceRule).Value)
+
+	parsed, err := parseICalEvent(cal)
+	require.NoError(t, err)
+	assert.Equal(t, event.RRule, parsed.RRule)
+	require.Len(t, parsed.ExDates, 1)
+	assert.True(t, parsed.ExDates[0].Equal(event.ExDates[0]))
+}
+
+func TestUpdateFutureMovesOverrides(t *testing.T) {
+	later := func(e *Event) {
+		e.Start = e.Start.Add(time.Hour)
+		e.End = e.End.Add(time.Hour)
+	}
+
+	// Splitting the series: the new one keeps the overrides
+	server := &schedulingServer{calendar: weeklyStandup}
+	client := newSchedulingClient(t, server)
+	uid, err := client.UpdateFuture(context.Background(), "/cal", "standup@example.com", utc("2026-01-07T09:00"), later)
+	require.NoError(t, err)
+	tail := decodeCalendar(t, server.puts["/cal/"+uid+".ics"])
+	master := eventFromComponent(findMaster(tail, uid))
+	assert.Equal(t, utc("2026-01-07T10:00"), master.Start.UTC())
+	assert.Equal(t, []time.Time{utc("2026-01-14T10:00")}, master.ExDates)
+	assert.Equal(t, []time.Time{utc("2026-01-16T10:00")}, master.RDates)
+
+	events, err := ExpandEvents(tail, utc("2026-01-12T00:00"), utc("2026-01-22T00:00"))
+	require.NoError(t, err)
+	var starts []time.Time
+	for _, e := range events {
+		starts = append(starts, e.Start.UTC())
+	}
+	// Jan 12 stays moved to 13:00, Jan 14 excluded, Jan 19 cancelled
+	assert.Equal(t, []time.Time{utc("2026-01-12T13:00"), utc("2026-01-16T10:00"), utc("2026-01-21T10:00")}, starts)
+
+	// Changing the whole series
+	server = &schedulingServer{calendar: weeklyStandup}
+	client = newSchedulingClient(t, server)
+	_, err = client.UpdateFuture(context.Background(), "/cal", "standup@example.com", utc("2025-01-06T09:00"), later)
+	require.NoError(t, err)
+	cal := decodeCalendar(t, server.puts["/cal/meeting.ics"])
+	var rids []time.Time
+	for _, comp := range findOverrides(cal, "standup@example.com") {
+		rids = append(rids, eventFromComponent(comp).RecurrenceID.UTC())
+	}
+	assert.Equal(t, []time.Time{utc("2026-01-12T10:00"), utc("2026-01-19T10:00")}, rids)
+}
+
+func TestUpdateEventMovesOverrides(t *testing.T) {
+	server := &schedulingServer{calendar: weeklyStandup}
+	client := newSchedulingClient(t, server)
+	ctx := context.Background()
+	event, err := client.GetEvent(ctx, "/cal", "standup@example.com")
+	require.NoError(t, err)
+	event.Start = event.Start.Add(time.Hour)
+	event.End = event.End.Add(time.Hour)
+	require.NoError(t, client.UpdateEvent(ctx, "/cal", event))
+
+	cal := decodeCalendar(t, server.puts["/cal/meeting.ics"])
+	master := eventFromComponent(findMaster(cal, "standup@example.com"))
+	assert.Equal(t, []time.Time{utc("2026-01-14T10:00")}, master.ExDates)
+	assert.Equal(t, []time.Time{utc("2026-01-16T10:00")}, master.RDates)
+	var rids []time.Time
+	for _, comp := range findOverrides(cal, "standup@example.com") {
+		rids = append(rids, eventFromComponent(comp).RecurrenceID.UTC())
+	}
+	assert.Equal(t, []time.Time{utc("2026-01-12T10:00"), utc("2026-01-19T10:00")}, rids)
+}
+
+func TestShiftTime(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	from := time.Date(2026, 3, 2, 9, 0, 0, 0, ny)
+	to := time.Date(2026, 3, 3, 10, 30, 0, 0, ny)
+	// Across the switch to daylight saving time
+	assert.Equal(t, time.Date(2026, 3, 17, 10, 30, 0, 0, ny), shiftTime(time.Date(2026, 3, 16, 9, 0, 0, 0, ny), from, to))
+	assert.Equal(t, time.Date(2026, 3, 2, 23, 30, 0, 0, ny), shiftTime(time.Date(2026, 3, 1, 22, 0, 0, 0, ny), from, to))
+}
@@ -0,0 +1,92 @@
+package caldav
+
+import (
+	"strings"
+
+	"github.com/emersion/go-ical"
+)
+
+// RELATED-TO relationship types (RFC 5545 and RFC 9253) that are read
+// into a Task. Others, such as SIBLING, are kept in the stored object but
+// not interpreted.
+const (
+	RelParent    = "PARENT"
+	RelChild     = "CHILD"
+	RelDependsOn = "DEPENDS-ON"
+)
+
+// relationsFromComponent reads the RELATED-TO properties of a VTODO into
+// task. A RELATED-TO without RELTYPE is a parent.
+func relationsFromComponent(comp *ical.Component, task *Task) {
+	for _, prop := range comp.Props[ical.PropRelatedTo] {
+		uid := strings.TrimSpace(prop.Value)
+		if uid == "" {
+			continue
+		}
+		switch relType(prop) {
+		case RelParent:
+			if task.Parent == "" {
+				task.Parent = uid
+			}
+		case RelChild:
+			task.Children = append(task.Children, uid)
+		case RelDependsOn:
+			task.DependsOn = append(task.DependsOn, uid)
+		}
+	}
+}
+
+// relType returns the upper-cased RELTYPE of a RELATED-TO property.
+func relType(prop ical.Prop) string {
+	rel := strings.ToUpper(prop.Params.Get(ical.ParamRelationshipType))
+	if rel == "" {
+		return RelParent
+	}
+	return rel
+}
+
+// relationProps returns the RELATED-TO properties of task.
+func relationProps(task *Task) []ical.Prop {
+	var props []ical.Prop
+	add := func(rel, uid string) {
+		prop := ical.NewProp(ical.PropRelatedTo)
+		prop.Value = uid
+		prop.Params.Set(ical.ParamRelationshipType, rel)
+		props = append(props, *prop)
+	}
+	if task.Parent != "" {
+		add(RelParent, task.Parent)
+	}
+	for _, uid := range task.Children {
+		add(RelChild, uid)
+	}
+	for _, uid := range task.DependsOn {
+		add(RelDependsOn, uid)
+	}
+	return props
+}
+
+// patchRelations rewrites the PARENT, CHILD and DEPENDS-ON relations of
+// comp if they changed. Relations of other types are kept.
+func patchRelations(comp *ical.Component, old, updated *Task) bool {
+	if old.Parent == updated.Parent &&
+		strings.Join(old.Children, ",") == strings.Join(updated.Children, ",") &&
+		strings.Join(old.DependsOn, ",") == strings.Join(updated.DependsOn, ",") {
+		return false
+	}
+	var kept []ical.Prop
+	for _, prop := range comp.Props[ical.PropRelatedTo] {
+		switch relType(prop) {
+		case RelParent, RelChild, RelDependsOn:
+		default:
+			kept = append(kept, prop)
+		}
+	}
+	props := append(kept, relationProps(updated)...)
+	if len(props) == 0 {
+		comp.Props.Del(ical.PropRelatedTo)
+	} else {
+		comp.Props[ical.PropRelatedTo] = props
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package caldav
+
+import (
+	"testing"
+
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskRelations(t *testing.T) {
+	cal := decodeCalendar(t, `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VTODO
+UID:child@example.com
+DTSTAMP:20260101T000000Z
+SUMMARY:Draft chapter
+RELATED-TO:book@example.com
+RELATED-TO;RELTYPE=DEPENDS-ON:outline@example.com
+RELATED-TO;RELTYPE=SIBLING:other@example.com
+RELATED-TO;RELTYPE=CHILD:notes@example.com
+END:VTODO
+END:VCALENDAR`)
+	comp := cal.Children[0]
+	old := taskFromComponent(comp)
+	assert.Equal(t, "book@example.com", old.Parent)
+	assert.Equal(t, []string{"notes@example.com"}, old.Children)
+	assert.Equal(t, []string{"outline@example.com"}, old.DependsOn)
+
+	// Unrelated changes leave the relations alone
+	updated := *old
+	updated.Summary = "Draft chapter 1"
+	require.True(t, patchTask(comp, old, &updated))
+	assert.Len(t, comp.Props[ical.PropRelatedTo], 4)
+
+	// Changed relations are rewritten; SIBLING is kept
+	old = taskFromComponent(comp)
+	updated = *old
+	updated.Parent = ""
+	updated.DependsOn = append(updated.DependsOn, "research@example.com")
+	require.True(t, patchTask(comp, old, &updated))
+	props := comp.Props[ical.PropRelatedTo]
+	require.Len(t, props, 4)
+	assert.Equal(t, "other@example.com", props[0].Value)
+
+	parsed := taskFromComponent(comp)
+	assert.Empty(t, parsed.Parent)
+	assert.Equal(t, []string{"notes@example.com"}, parsed.Children)
+	assert.Equal(t, []string{"outline@example.com", "research@example.com"}, parsed.DependsOn)
+}
+
+func TestCreateICalTaskRelations(t *testing.T) {
+	task := &Task{
+		UID:       "child@example.com",
+		Summary:   "Draft chapter",
+		Parent:    "book@example.com",
+		DependsOn: []string{"outline@example.com"},
+	}
+	cal := createICalTask(task)
+	props := cal.Children[0].Props[ical.PropRelatedTo]
+	require.Len(t, props, 2)
+	assert.Equal(t, "PARENT", props[0].Params.Get(ical.ParamRelationshipType))
+	assert.Equal(t, "DEPENDS-ON", props[1].Params.Get(ical.ParamRelationshipType))
+
+	parsed := taskFromComponent(cal.Children[0])
+	assert.Equal(t, task.Parent, parsed.Parent)
+	assert.Equal(t, task.DependsOn, parsed.DependsOn)
+}
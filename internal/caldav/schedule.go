@@ -0,0 +1,417 @@
+package caldav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+	"github.com/visionik/sogcli/internal/dav"
+	"github.com/visionik/sogcli/internal/timezone"
+)
+
+// CalDAV scheduling (RFC 6638). On servers with calendar-auto-schedule,
+// writing an event with an ORGANIZER and ATTENDEEs makes the server deliver
+// the invitations, attendees' changes to their PARTSTAT become replies, and
+// incoming messages land in the scheduling inbox.
+
+// Participation statuses (PARTSTAT)
+const (
+	PartStatNeedsAction = "NEEDS-ACTION"
+	PartStatAccepted    = "ACCEPTED"
+	PartStatDeclined    = "DECLINED"
+	PartStatTentative   = "TENTATIVE"
+)
+
+// Scheduling describes the server's scheduling support for the account.
+type Scheduling struct {
+	AutoSchedule bool     `json:"auto_schedule"` // The server delivers invitations and replies
+	Inbox        string   `json:"inbox,omitempty"`
+	Outbox       string   `json:"outbox,omitempty"`
+	Addresses    []string `json:"addresses,omitempty"` // The account's calendar user addresses
+}
+
+// IsAddress reports whether address is one of the account's addresses.
+func (s *Scheduling) IsAddress(address string) bool {
+	for _, a := range s.Addresses {
+		if strings.EqualFold(a, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNoScheduling is returned when the server has no scheduling inbox or
+// outbox.
+var ErrNoScheduling = errors.New("server does not support CalDAV scheduling")
+
+var (
+	propInbox     = xml.Name{Space: nsCalDAV, Local: "schedule-inbox-URL"}
+	propOutbox    = xml.Name{Space: nsCalDAV, Local: "schedule-outbox-URL"}
+	propAddresses = xml.Name{Space: nsCalDAV, Local: "calendar-user-address-set"}
+)
+
+// Scheduling detects the server's scheduling support. The result is
+// cached for the lifetime of the client.
+func (c *Client) Scheduling(ctx context.Context) (*Scheduling, error) {
+	if c.scheduling != nil {
+		return c.scheduling, nil
+	}
+	s := &Scheduling{Addresses: []string{c.email}}
+
+	// calendar-auto-schedule is advertised in the DAV header
+	_, header, err := c.do(ctx, http.MethodOptions, "", nil, nil, http.StatusOK, http.StatusNoContent)
+	if err == nil {
+		for _, value := range header.Values("DAV") {
+			for _, class := range strings.Split(value, ",") {
+				if strings.TrimSpace(class) == "calendar-auto-schedule" {
+					s.AutoSchedule = true
+				}
+			}
+		}
+	}
+
+	principal, err := c.principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	responses, err := c.propfind(ctx, principal, "0", propInbox, propOutbox, propAddresses)
+	var status *statusError
+	if err != nil && !errors.As(err, &status) {
+		return nil, fmt.Errorf("failed to get scheduling properties: %w", err)
+	}
+	for _, resp := range responses {
+		if hrefs := resp.prop(propInbox).hrefs(); len(hrefs) > 0 {
+			s.Inbox = hrefs[0]
+		}
+		if hrefs := resp.prop(propOutbox).hrefs(); len(hrefs) > 0 {
+			s.Outbox = hrefs[0]
+		}
+		for _, href := range resp.prop(propAddresses).hrefs() {
+			if strings.HasPrefix(strings.ToLower(href), "mailto:") && !s.IsAddress(calAddress(href)) {
+				s.Addresses = append(s.Addresses, calAddress(href))
+			}
+		}
+	}
+	if s.Inbox == "" {
+		s.AutoSchedule = false
+	}
+	c.scheduling = s
+	return s, nil
+}
+
+// calAddress strips "mailto:" from a calendar user address.
+func calAddress(value string) string {
+	if len(value) >= 7 && strings.EqualFold(value[:7], "mailto:") {
+		return value[7:]
+	}
+	return value
+}
+
+// CreateInvitation creates a meeting in the organizer's calendar: an event
+// with event.Organizer and event.Attendees, who are asked to reply. If
+// deliver is true the server sends the invitations; otherwise attendees
+// are marked SCHEDULE-AGENT=CLIENT so that it does not, and the caller
+// sends them by email (iMIP).
+func (c *Client) CreateInvitation(ctx context.Context, calPath string, event *Event, deliver bool) error {
+	cal := createICalEvent(event)
+	comp := cal.Children[0]
+	for i := range comp.Props[ical.PropAttendee] {
+		prop := &comp.Props[ical.PropAttendee][i]
+		if prop.Params.Get(ical.ParamParticipationStatus) == "" {
+			prop.Params.Set(ical.ParamParticipationStatus, PartStatNeedsAction)
+		}
+		prop.Params.Set(ical.ParamRSVP, "TRUE")
+		prop.Params.Set("ROLE", "REQ-PARTICIPANT")
+		if !deliver {
+			prop.Params.Set("SCHEDULE-AGENT", "CLIENT")
+		}
+	}
+	prop := ical.NewProp(ical.PropSequence)
+	prop.Value = fmt.Sprint(event.Sequence)
+	comp.Props.Set(prop)
+
+	obj, err := c.client.PutCalendarObject(dav.IfNoneMatch(ctx), calPath+"/"+event.UID+".ics", cal)
+	if err != nil {
+		return fmt.Errorf("failed to create event: %w", err)
+	}
+	event.Path, event.ETag = obj.Path, obj.ETag
+	return nil
+}
+
+// UpdateInvitation updates a meeting in the organizer's calendar with the
+// changes in event, which should come from GetEvent, bumping its SEQUENCE.
+// Added attendees are asked to reply; if the meeting moved, all attendees
+// are asked again. Like UpdateEvent, the overrides, RDATEs and EXDATEs of
+// a recurring meeting move with its start. event.Sequence is set to the
+// new SEQUENCE.
+//
+// It returns the attendees, current and removed, that the server sends the
+// update or cancellation to; the others are marked SCHEDULE-AGENT=CLIENT
+// and have to be sent it by email.
+func (c *Client) UpdateInvitation(ctx context.Context, calPath string, event *Event) ([]string, error) {
+	obj, err := c.getEventObject(ctx, calPath, event.UID)
+	if err != nil {
+		return nil, err
+	}
+	comp := findMaster(obj.Data, event.UID)
+	if comp == nil {
+		return nil, fmt.Errorf("event not found: %s", event.UID)
+	}
+	old := eventFromComponent(comp)
+
+	// Added attendees get their invitation the way the others did
+	byClient := false
+	var oldScheduled []string
+	for _, prop := range comp.Props[ical.PropAttendee] {
+		if strings.EqualFold(prop.Params.Get("SCHEDULE-AGENT"), "CLIENT") {
+			byClient = true
+		} else {
+			oldScheduled = append(oldScheduled, calAddress(prop.Value))
+		}
+	}
+	if sameTimes(old.RDates, event.RDates) && sameTimes(old.ExDates, event.ExDates) {
+		shiftRecurrence(event, old.Start)
+	}
+	if !patchEvent(comp, old, event) {
+		return nil, nil
+	}
+	shiftOverrides(findOverrides(obj.Data, event.UID), comp, old.Start, event.Start)
+
+	moved := !old.Start.Equal(event.Start) || !old.End.Equal(event.End) || old.RRule != event.RRule
+	known := map[string]bool{}
+	for _, a := range old.Attendees {
+		known[strings.ToLower(a)] = true
+	}
+	for i := range comp.Props[ical.PropAttendee] {
+		prop := &comp.Props[ical.PropAttendee][i]
+		addr := calAddress(prop.Value)
+		if strings.EqualFold(addr, event.Organizer) || (known[strings.ToLower(addr)] && !moved) {
+			continue
+		}
+		prop.Params.Set(ical.ParamParticipationStatus, PartStatNeedsAction)
+		prop.Params.Set(ical.ParamRSVP, "TRUE")
+		if !known[strings.ToLower(addr)] {
+			prop.Params.Set("ROLE", "REQ-PARTICIPANT")
+			if byClient {
+				prop.Params.Set("SCHEDULE-AGENT", "CLIENT")
+			}
+		}
+	}
+
+	var scheduled []string
+	if s, err := c.Scheduling(ctx); err == nil && s.AutoSchedule {
+		current := map[string]bool{}
+		for _, prop := range comp.Props[ical.PropAttendee] {
+			addr := calAddress(prop.Value)
+			current[strings.ToLower(addr)] = true
+			if !strings.EqualFold(prop.Params.Get("SCHEDULE-AGENT"), "CLIENT") && !strings.EqualFold(addr, event.Organizer) {
+				scheduled = append(scheduled, addr)
+			}
+		}
+		for _, addr := range oldScheduled {
+			if !current[strings.ToLower(addr)] {
+				scheduled = append(scheduled, addr)
+			}
+		}
+	}
+
+	event.Path = obj.Path
+	if event.ETag == "" {
+		event.ETag = obj.ETag
+	}
+	timezone.Embed(obj.Data)
+	updated, err := c.client.PutCalendarObject(dav.IfMatch(ctx, event.ETag), obj.Path, obj.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	event.ETag = updated.ETag
+	event.Sequence, _ = comp.Props.Get(ical.PropSequence).Int()
+	return scheduled, nil
+}
+
+// InboxItem is a message in the scheduling inbox: an invitation (REQUEST),
+// an update, a reply to one of the account's meetings (REPLY) or a
+// cancellation (CANCEL).
+type InboxItem struct {
+	Path   string `json:"path"`
+	ETag   string `json:"etag,omitempty"`
+	Method string `json:"method"`
+	Event  Event  `json:"event"`
+
+	data *ical.Calendar
+}
+
+// ListInbox returns the messages in the scheduling inbox.
+func (c *Client) ListInbox(ctx context.Context) ([]InboxItem, error) {
+	s, err := c.Scheduling(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.Inbox == "" {
+		return nil, ErrNoScheduling
+	}
+	objects, err := c.query(ctx, s.Inbox, &caldav.CalendarQuery{
+		CompRequest: caldav.CalendarCompRequest{Name: "VCALENDAR", AllProps: true, AllComps: true},
+		CompFilter:  caldav.CompFilter{Name: "VCALENDAR"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduling inbox: %w", err)
+	}
+
+	var items []InboxItem
+	for _, obj := range objects {
+		event, err := parseICalEvent(obj.Data)
+		if err != nil {
+			continue // Only events are handled
+		}
+		items = append(items, InboxItem{
+			Path:   obj.Path,
+			ETag:   obj.ETag,
+			Method: strings.ToUpper(propValue(obj.Data.Component, ical.PropMethod)),
+			Event:  *event,
+			data:   obj.Data,
+		})
+	}
+	return items, nil
+}
+
+// DeleteInboxItem removes a processed message from the scheduling inbox.
+func (c *Client) DeleteInboxItem(ctx context.Context, item *InboxItem) error {
+	if err := c.client.RemoveAll(dav.IfMatch(ctx, item.ETag), item.Path); err != nil {
+		return fmt.Errorf("failed to delete inbox item: %w", err)
+	}
+	return nil
+}
+
+// Respond answers an invitation from the scheduling inbox with partstat
+// and removes it from the inbox. The account's PARTSTAT is set on the
+// event in the calendar, where the server has put it, which makes the
+// server send the reply; if it is in none of the calendars, it is created
+// in calPath. The event is returned.
+func (c *Client) Respond(ctx context.Context, item *InboxItem, calPath, partstat string) (*Event, error) {
+	if item.data == nil {
+		return nil, fmt.Errorf("not an inbox item: %s", item.Path)
+	}
+	s, err := c.Scheduling(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := c.findEventObject(ctx, calPath, item.Event.UID)
+	if err != nil {
+		// Not on a calendar yet: store the invitation itself
+		cal := item.data
+		cal.Props.Del(ical.PropMethod)
+		if !setPartStat(cal, item.Event.UID, s, partstat) {
+			return nil, fmt.Errorf("%s is not an attendee of %s", c.email, item.Event.Summary)
+		}
+		timezone.Embed(cal)
+		if _, err := c.client.PutCalendarObject(dav.IfNoneMatch(ctx), calPath+"/"+item.Event.UID+".ics", cal); err != nil {
+			return nil, fmt.Errorf("failed to create event: %w", err)
+		}
+	} else {
+		if !setPartStat(obj.Data, item.Event.UID, s, partstat) {
+			return nil, fmt.Errorf("%s is not an attendee of %s", c.email, item.Event.Summary)
+		}
+		timezone.Embed(obj.Data)
+		if _, err := c.client.PutCalendarObject(dav.IfMatch(ctx, obj.ETag), obj.Path, obj.Data); err != nil {
+			return nil, fmt.Errorf("failed to update event: %w", err)
+		}
+	}
+
+	if err := c.DeleteInboxItem(ctx, item); err != nil {
+		return nil, err
+	}
+	event := item.Event
+	if event.PartStat == nil {
+		event.PartStat = PartStats{}
+	}
+	for _, a := range event.Attendees {
+		if s.IsAddress(a) {
+			event.PartStat[strings.ToLower(a)] = partstat
+		}
+	}
+	return &event, nil
+}
+
+// findEventObject looks for the event with uid in calPath, then in all
+// calendars.
+func (c *Client) findEventObject(ctx context.Context, calPath, uid string) (*caldav.CalendarObject, error) {
+	obj, err := c.getEventObject(ctx, calPath, uid)
+	if err == nil {
+		return obj, nil
+	}
+	calendars, ferr := c.FindCalendars(ctx)
+	if ferr != nil {
+		return nil, err
+	}
+	for _, cal := range calendars {
+		if cal.Path == calPath {
+			continue
+		}
+		if obj, err := c.getEventObject(ctx, cal.Path, uid); err == nil {
+			return obj, nil
+		}
+	}
+	return nil, err
+}
+
+// setPartStat sets the PARTSTAT of the account's ATTENDEE properties in
+// every VEVENT with uid (the series and its overrides), reporting whether
+// the account is an attendee. SEQUENCE is left alone: a reply does not
+// change the meeting.
+func setPartStat(cal *ical.Calendar, uid string, s *Scheduling, partstat string) bool {
+	found := false
+	for _, comp := range cal.Children {
+		if comp.Name != ical.CompEvent || propValue(comp, ical.PropUID) != uid {
+			continue
+		}
+		for i := range comp.Props[ical.PropAttendee] {
+			prop := &comp.Props[ical.PropAttendee][i]
+			if !s.IsAddress(calAddress(prop.Value)) {
+				continue
+			}
+			prop.Params.Set(ical.ParamParticipationStatus, partstat)
+			prop.Params.Del(ical.ParamRSVP)
+			found = true
+		}
+		if found {
+			bumpRevision(comp, false)
+		}
+	}
+	return found
+}
+
+// CancelInvitation deletes a meeting from the organizer's calendar. It
+// reports whether the server notifies the attendees, which it does on
+// scheduling servers unless the invitations were sent by email
+// (SCHEDULE-AGENT=CLIENT). The deleted event is returned.
+func (c *Client) CancelInvitation(ctx context.Context, calPath, uid string) (*Event, bool, error) {
+	obj, err := c.getEventObject(ctx, calPath, uid)
+	if err != nil {
+		return nil, false, err
+	}
+	comp := findMaster(obj.Data, uid)
+	if comp == nil {
+		return nil, false, fmt.Errorf("event not found: %s", uid)
+	}
+	event := eventFromComponent(comp)
+
+	delivered := false
+	if s, err := c.Scheduling(ctx); err == nil && s.AutoSchedule {
+		for _, prop := range comp.Props[ical.PropAttendee] {
+			if !strings.EqualFold(prop.Params.Get("SCHEDULE-AGENT"), "CLIENT") {
+				delivered = true
+			}
+		}
+	}
+	if err := c.client.RemoveAll(dav.IfMatch(ctx, obj.ETag), obj.Path); err != nil {
+		return nil, false, fmt.Errorf("failed to delete event: %w", err)
+	}
+	return event, delivered, nil
+}
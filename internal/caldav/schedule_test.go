@@ -0,0 +1,272 @@
+package caldav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const inboxRequest = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Server//EN
+METHOD:REQUEST
+BEGIN:VEVENT
+UID:meeting@example.com
+DTSTAMP:20260301T000000Z
+DTSTART:20260302T090000Z
+DTEND:20260302T100000Z
+SUMMARY:Planning
+SEQUENCE:2
+ORGANIZER;CN=Alice:mailto:alice@example.com
+ATTENDEE;PARTSTAT=ACCEPTED:mailto:alice@example.com
+ATTENDEE;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:MAILTO:me@alias.example.com
+END:VEVENT
+END:VCALENDAR
+`
+
+// schedulingServer fakes a CalDAV server with scheduling. Objects in
+// calendar are returned for queries on /cal, without alarms unless the
+// query asks for them; written objects are recorded.
+type schedulingServer struct {
+	autoSchedule bool
+	calendar     string
+	puts         map[string]string
+	deletes      []string
+	reports      []string
+}
+
+func (s *schedulingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	multistatus := func(responses string) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">%s</D:multistatus>`, responses)
+	}
+	object := func(path, data string) string {
+		return `<D:response><D:href>` + path + `</D:href><D:propstat><D:prop><D:getetag>"1"</D:getetag>
+<C:calendar-data>` + strings.ReplaceAll(data, "\n", "\r\n") + `</C:calendar-data></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`
+	}
+
+	switch {
+	case r.Method == http.MethodOptions:
+		dav := "1, 2, calendar-access"
+		if s.autoSchedule {
+			dav += ", calendar-auto-schedule"
+		}
+		w.Header().Set("DAV", dav)
+	case r.Method == "PROPFIND" && strings.Contains(string(body), "current-user-principal"):
+		multistatus(`<D:response><D:href>` + r.URL.Path + `</D:href><D:propstat><D:prop>
+<D:current-user-principal><D:href>/principals/me/</D:href></D:current-user-principal></D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`)
+	case r.Method == "PROPFIND" && r.URL.Path == "/principals/me/":
+		multistatus(`<D:response><D:href>/principals/me/</D:href><D:propstat><D:prop>
+<C:schedule-inbox-URL><D:href>/inbox/</D:href></C:schedule-inbox-URL>
+<C:schedule-outbox-URL><D:href>/outbox/</D:href></C:schedule-outbox-URL>
+<C:calendar-user-address-set><D:href>mailto:me@example.com</D:href><D:href>mailto:me@alias.example.com</D:href><D:href>/principals/me/</D:href></C:calendar-user-address-set>
+</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`)
+	case r.Method == "REPORT" && r.URL.Path == "/inbox/":
+		multistatus(object("/inbox/1.ics", inboxRequest))
+	case r.Method == "REPORT" && strings.TrimSuffix(r.URL.Path, "/") == "/cal":
+		s.reports = append(s.reports, string(body))
+		if s.calendar == "" {
+			multistatus("")
+			return
+		}
+		data := s.calendar
+		if !strings.Contains(string(body), "VALARM") && !strings.Contains(string(body), "allcomp") {
+			data = regexp.MustCompile(`(?s)BEGIN:VALARM.*?END:VALARM\n`).ReplaceAllString(data, "")
+		}
+		multistatus(object("/cal/meeting.ics", data))
+	case r.Method == http.MethodPut:
+		s.puts[r.URL.Path] = string(body)
+		w.Header().Set("ETag", `"2"`)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodDelete:
+		s.deletes = append(s.deletes, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func newSchedulingClient(t *testing.T, s *schedulingServer) *Client {
+	t.Helper()
+	s.puts = map[string]string{}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	client, err := Connect(Config{URL: server.URL + "/", Email: "me@example.com"})
+	require.NoError(t, err)
+	return client
+}
+
+func TestScheduling(t *testing.T) {
+	client := newSchedulingClient(t, &schedulingServer{autoSchedule: true})
+	s, err := client.Scheduling(context.Background())
+	require.NoError(t, err)
+	assert.True(t, s.AutoSchedule)
+	assert.Equal(t, "/inbox/", s.Inbox)
+	assert.Equal(t, "/outbox/", s.Outbox)
+	assert.Equal(t, []string{"me@example.com", "me@alias.example.com"}, s.Addresses)
+	assert.True(t, s.IsAddress("ME@alias.example.com"))
+
+	client = newSchedulingClient(t, &schedulingServer{})
+	s, err = client.Scheduling(context.Background())
+	require.NoError(t, err)
+	assert.False(t, s.AutoSchedule)
+}
+
+func TestListInbox(t *testing.T) {
+	client := newSchedulingClient(t, &schedulingServer{autoSchedule: true})
+	items, err := client.ListInbox(context.Background())
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "REQUEST", items[0].Method)
+	assert.Equal(t, "meeting@example.com", items[0].Event.UID)
+	assert.Equal(t, "alice@example.com", items[0].Event.Organizer)
+	assert.Equal(t, 2, items[0].Event.Sequence)
+	assert.Equal(t, PartStatNeedsAction, items[0].Event.PartStat.Get("me@alias.example.com"))
+}
+
+func TestRespond(t *testing.T) {
+	// The server put the invitation on the calendar
+	server := &schedulingServer{autoSchedule: true, calendar: strings.Replace(inboxRequest, "METHOD:REQUEST\n", "", 1)}
+	client := newSchedulingClient(t, server)
+	items, err := client.ListInbox(context.Background())
+	require.NoError(t, err)
+
+	event, err := client.Respond(context.Background(), &items[0], "/cal", PartStatAccepted)
+	require.NoError(t, err)
+	assert.Equal(t, PartStatAccepted, event.PartStat.Get("me@alias.example.com"))
+	assert.Equal(t, []string{"/inbox/1.ics"}, server.deletes)
+
+	written := decodeCalendar(t, server.puts["/cal/meeting.ics"])
+	comp := written.Children[0]
+	attendees := comp.Props[ical.PropAttendee]
+	assert.Equal(t, "ACCEPTED", attendees[1].Params.Get(ical.ParamParticipationStatus))
+	assert.Empty(t, attendees[1].Params.Get(ical.ParamRSVP))
+	assert.Equal(t, "2", propValue(comp, ical.PropSequence))
+
+	// Not on a calendar: the invitation is stored without METHOD
+	server = &schedulingServer{autoSchedule: true}
+	client = newSchedulingClient(t, server)
+	items, err = client.ListInbox(context.Background())
+	require.NoError(t, err)
+	_, err = client.Respond(context.Background(), &items[0], "/cal", PartStatDeclined)
+	require.NoError(t, err)
+	data := server.puts["/cal/meeting@example.com.ics"]
+	assert.NotContains(t, data, "METHOD")
+	assert.Contains(t, data, "PARTSTAT=DECLINED")
+}
+
+func TestCreateInvitation(t *testing.T) {
+	server := &schedulingServer{autoSchedule: true}
+	client := newSchedulingClient(t, server)
+	event := &Event{
+		UID:       "new@example.com",
+		Summary:   "Sync",
+		Start:     utc("2026-03-02T09:00"),
+		End:       utc("2026-03-02T09:30"),
+		Organizer: "me@example.com",
+		Attendees: []string{"alice@example.com"},
+	}
+	require.NoError(t, client.CreateInvitation(context.Background(), "/cal", event, true))
+	data := server.puts["/cal/new@example.com.ics"]
+	assert.Contains(t, data, "ORGANIZER:mailto:me@example.com")
+	assert.Contains(t, data, "PARTSTAT=NEEDS-ACTION")
+	assert.Contains(t, data, "RSVP=TRUE")
+	assert.NotContains(t, data, "SCHEDULE-AGENT")
+
+	event.UID = "email@example.com"
+	require.NoError(t, client.CreateInvitation(context.Background(), "/cal", event, false))
+	assert.Contains(t, server.puts["/cal/email@example.com.ics"], "SCHEDULE-AGENT=CLIENT")
+}
+
+func TestCancelInvitation(t *testing.T) {
+	server := &schedulingServer{autoSchedule: true, calendar: strings.Replace(inboxRequest, "METHOD:REQUEST\n", "", 1)}
+	client := newSchedulingClient(t, server)
+	event, delivered, err := client.CancelInvitation(context.Background(), "/cal", "meeting@example.com")
+	require.NoError(t, err)
+	assert.True(t, delivered)
+	assert.Equal(t, 2, event.Sequence)
+	assert.Equal(t, []string{"/cal/meeting.ics"}, server.deletes)
+
+	// Invitations sent by email are cancelled by email
+	server.calendar = strings.ReplaceAll(server.calendar, "ATTENDEE;", "ATTENDEE;SCHEDULE-AGENT=CLIENT;")
+	_, delivered, err = client.CancelInvitation(context.Background(), "/cal", "meeting@example.com")
+	require.NoError(t, err)
+	assert.False(t, delivered)
+}
+
+// attendeeParams returns the parameters of an attendee in the first event
+// of an iCalendar object.
+func attendeeParams(t *testing.T, data, address string) ical.Params {
+	t.Helper()
+	cal, err := ical.NewDecoder(strings.NewReader(data)).Decode()
+	require.NoError(t, err)
+	for _, prop := range cal.Events()[0].Props[ical.PropAttendee] {
+		if strings.EqualFold(calAddress(prop.Value), address) {
+			return prop.Params
+		}
+	}
+	t.Fatalf("no attendee %s", address)
+	return nil
+}
+
+func TestUpdateInvitation(t *testing.T) {
+	stored := strings.Replace(onCalendar(inboxRequest), "PARTSTAT=NEEDS-ACTION;RSVP=TRUE", "PARTSTAT=ACCEPTED", 1)
+	stored = strings.ReplaceAll(stored, "ATTENDEE;", "ATTENDEE;SCHEDULE-AGENT=CLIENT;")
+	server := &schedulingServer{autoSchedule: true, calendar: stored}
+	client := newSchedulingClient(t, server)
+	ctx := context.Background()
+
+	// An added attendee is invited; the others keep their replies
+	event, err := client.GetEvent(ctx, "/cal", "meeting@example.com")
+	require.NoError(t, err)
+	event.Attendees = append(event.Attendees, "bob@example.com")
+	scheduled, err := client.UpdateInvitation(ctx, "/cal", event)
+	require.NoError(t, err)
+	assert.Empty(t, scheduled)
+	assert.Equal(t, 3, event.Sequence)
+	data := server.puts["/cal/meeting.ics"]
+	assert.Contains(t, data, "SEQUENCE:3")
+	assert.Equal(t, PartStatAccepted, attendeeParams(t, data, "me@alias.example.com").Get(ical.ParamParticipationStatus))
+	bob := attendeeParams(t, data, "bob@example.com")
+	assert.Equal(t, PartStatNeedsAction, bob.Get(ical.ParamParticipationStatus))
+	assert.Equal(t, "CLIENT", bob.Get("SCHEDULE-AGENT"))
+
+	// Moving the meeting asks everyone again
+	server.calendar = data
+	event, err = client.GetEvent(ctx, "/cal", "meeting@example.com")
+	require.NoError(t, err)
+	event.Start = event.Start.Add(time.Hour)
+	event.End = event.End.Add(time.Hour)
+	_, err = client.UpdateInvitation(ctx, "/cal", event)
+	require.NoError(t, err)
+	data = server.puts["/cal/meeting.ics"]
+	assert.Equal(t, PartStatNeedsAction, attendeeParams(t, data, "me@alias.example.com").Get(ical.ParamParticipationStatus))
+	assert.Equal(t, PartStatAccepted, attendeeParams(t, data, "alice@example.com").Get(ical.ParamParticipationStatus))
+	assert.Contains(t, data, "SEQUENCE:4")
+
+	// The server only notifies the attendees it schedules, including
+	// removed ones
+	stored = strings.Replace(onCalendar(inboxRequest), "ATTENDEE;PARTSTAT=NEEDS-ACTION", "ATTENDEE;SCHEDULE-AGENT=CLIENT;PARTSTAT=NEEDS-ACTION", 1)
+	stored = strings.Replace(stored, "END:VEVENT", "ATTENDEE:mailto:bob@example.com\nATTENDEE:mailto:carol@example.com\nEND:VEVENT", 1)
+	server.calendar = stored
+	event, err = client.GetEvent(ctx, "/cal", "meeting@example.com")
+	require.NoError(t, err)
+	event.Summary = "Planning (agenda)"
+	event.Attendees = event.Attendees[:3]
+	scheduled, err = client.UpdateInvitation(ctx, "/cal", event)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob@example.com", "carol@example.com"}, scheduled)
+}
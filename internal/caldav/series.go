@@ -0,0 +1,351 @@
+package caldav
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+	"github.com/teambition/rrule-go"
+	"github.com/visionik/sogcli/internal/dav"
+	"github.com/visionik/sogcli/internal/timezone"
+)
+
+// Editing single occurrences and splitting recurring series.
+//
+// Occurrences are identified by a time: the exact start of an occurrence,
+// or midnight to select the first occurrence on that day.
+
+// series is a recurring event's calendar object and master component.
+type series struct {
+	obj        *caldav.CalendarObject
+	masterComp *ical.Component
+	master     *Event
+}
+
+// getSeries fetches a recurring event.
+func (c *Client) getSeries(ctx context.Context, calPath, uid string) (*series, error) {
+	obj, err := c.getEventObject(ctx, calPath, uid)
+	if err != nil {
+		return nil, err
+	}
+	comp := findMaster(obj.Data, uid)
+	if comp == nil {
+		return nil, fmt.Errorf("event not found: %s", uid)
+	}
+	master := eventFromComponent(comp)
+	if master.RRule == "" && len(master.RDates) == 0 {
+		return nil, fmt.Errorf("event %s is not recurring", uid)
+	}
+	return &series{obj: obj, masterComp: comp, master: master}, nil
+}
+
+// putSeries writes the series back to the server, unless it changed there
+// since getSeries.
+func (c *Client) putSeries(ctx context.Context, s *series) error {
+	timezone.Embed(s.obj.Data)
+	if _, err := c.client.PutCalendarObject(dav.IfMatch(ctx, s.obj.ETag), s.obj.Path, s.obj.Data); err != nil {
+		return fmt.Errorf("failed to update event: %w", err)
+	}
+	return nil
+}
+
+// UpdateOccurrence changes a single occurrence of a recurring event. The
+// occurrence is passed to update, and the result is stored as a
+// RECURRENCE-ID override.
+func (c *Client) UpdateOccurrence(ctx context.Context, calPath, uid string, on time.Time, update func(*Event)) error {
+	s, err := c.getSeries(ctx, calPath, uid)
+	if err != nil {
+		return err
+	}
+	rid, err := resolveOccurrence(s.obj.Data, s.master, on)
+	if err != nil {
+		return err
+	}
+
+	// Patch the existing override, or a copy of the master that keeps
+	// its alarms, attendee parameters and other properties
+	var comp *ical.Component
+	for _, child := range findOverrides(s.obj.Data, uid) {
+		if eventFromComponent(child).RecurrenceID.Equal(rid) {
+			comp = child
+		}
+	}
+	fresh := comp == nil
+	if fresh {
+		comp = cloneComponent(s.masterComp)
+		for _, name := range []string{ical.PropRecurrenceRule, ical.PropRecurrenceDates, ical.PropExceptionDates} {
+			comp.Props.Del(name)
+		}
+		ridProp := ical.NewProp(ical.PropRecurrenceID)
+		setTimeLike(ridProp, rid, s.masterComp.Props.Get(ical.PropDateTimeStart))
+		comp.Props.Set(ridProp)
+		s.obj.Data.Children = append(s.obj.Data.Children, comp)
+	}
+
+	old := eventFromComponent(comp)
+	occurrence := *old
+	if fresh {
+		// The copy still has the first occurrence's times
+		occurrence = occurrenceAt(old, rid)
+	}
+	update(&occurrence)
+	occurrence.UID = uid
+	occurrence.RRule, occurrence.RDates, occurrence.ExDates = "", nil, nil
+	patchEvent(comp, old, &occurrence)
+	return c.putSeries(ctx, s)
+}
+
+// DeleteOccurrence removes a single occurrence of a recurring event by
+// adding an EXDATE.
+func (c *Client) DeleteOccurrence(ctx context.Context, calPath, uid string, on time.Time) error {
+	s, err := c.getSeries(ctx, calPath, uid)
+	if err != nil {
+		return err
+	}
+	rid, err := resolveOccurrence(s.obj.Data, s.master, on)
+	if err != nil {
+		return err
+	}
+
+	s.removeOverrides(func(t time.Time) bool { return t.Equal(rid) })
+	exdate := ical.NewProp(ical.PropExceptionDates)
+	setTimeLike(exdate, rid, s.masterComp.Props.Get(ical.PropDateTimeStart))
+	s.masterComp.Props.Add(exdate)
+	bumpRevision(s.masterComp, true)
+	return c.putSeries(ctx, s)
+}
+
+// UpdateFuture changes an occurrence and all later ones. Unless the
+// occurrence is the first, the series is split: the original event ends
+// before the occurrence and a new event with its own UID continues from
+// it. It returns the UID of the event holding the changed occurrences.
+// When the start moves, the RDATEs, EXDATEs and overrides of the changed
+// occurrences move with it.
+func (c *Client) UpdateFuture(ctx context.Context, calPath, uid string, on time.Time, update func(*Event)) (string, error) {
+	s, err := c.getSeries(ctx, calPath, uid)
+	if err != nil {
+		return "", err
+	}
+	rid, err := resolveOccurrence(s.obj.Data, s.master, on)
+	if err != nil {
+		return "", err
+	}
+
+	if !rid.After(s.master.Start) {
+		updated := *s.master
+		update(&updated)
+		shiftRecurrence(&updated, s.master.Start)
+		if !patchEvent(s.masterComp, s.master, &updated) {
+			return uid, nil
+		}
+		shiftOverrides(findOverrides(s.obj.Data, uid), s.masterComp, s.master.Start, updated.Start)
+		return uid, c.putSeries(ctx, s)
+	}
+
+	// The new series starts at the occurrence, as a copy of the master
+	comp := cloneComponent(s.masterComp)
+	base := eventFromComponent(comp)
+	tail := occurrenceAt(base, rid)
+	tail.UID = fmt.Sprintf("%s-%s", uid, rid.UTC().Format("20060102T150405Z"))
+	tail.RecurrenceID = time.Time{}
+	tail.RDates = timesFrom(s.master.RDates, rid, true)
+	tail.ExDates = timesFrom(s.master.ExDates, rid, true)
+	if count := rulePart(s.master.RRule, "COUNT"); count != "" {
+		n, _ := strconv.Atoi(count)
+		before, err := ruleCountBefore(s.master, rid)
+		if err != nil {
+			return "", err
+		}
+		tail.RRule = setRulePart(tail.RRule, "COUNT", strconv.Itoa(n-before))
+	}
+	update(&tail)
+	shiftRecurrence(&tail, rid)
+	patchEvent(comp, base, &tail)
+	comp.Props.SetText(ical.PropUID, tail.UID)
+
+	tailCal := ical.NewCalendar()
+	tailCal.Props.SetText(ical.PropVersion, "2.0")
+	tailCal.Props.SetText(ical.PropProductID, "-//sog//CalDAV Client//EN")
+	for _, child := range s.obj.Data.Children {
+		if child.Name == ical.CompTimezone {
+			tailCal.Children = append(tailCal.Children, child)
+		}
+	}
+	tailCal.Children = append(tailCal.Children, comp)
+
+	// Overrides of later occurrences move to the new series
+	var moved []*ical.Component
+	for _, comp := range findOverrides(s.obj.Data, uid) {
+		if !eventFromComponent(comp).RecurrenceID.Before(rid) {
+			moved = append(moved, comp)
+		}
+	}
+	if err := s.truncate(rid); err != nil {
+		return "", err
+	}
+	shiftOverrides(moved, comp, rid, tail.Start)
+	for _, override := range moved {
+		override.Props.SetText(ical.PropUID, tail.UID)
+		tailCal.Children = append(tailCal.Children, override)
+	}
+
+	timezone.Embed(tailCal)
+
+	// End the old series first so a conflict leaves nothing behind
+	if err := c.putSeries(ctx, s); err != nil {
+		return "", err
+	}
+	if _, err := c.client.PutCalendarObject(dav.IfNoneMatch(ctx), calPath+"/"+tail.UID+".ics", tailCal); err != nil {
+		return "", fmt.Errorf("failed to create event: %w", err)
+	}
+	return tail.UID, nil
+}
+
+// shiftRecurrence moves the RDATEs and EXDATEs of e along with its start,
+// which was from before it was changed.
+func shiftRecurrence(e *Event, from time.Time) {
+	if e.Start.Equal(from) {
+		return
+	}
+	shift := func(times []time.Time) []time.Time {
+		var shifted []time.Time
+		for _, t := range times {
+			shifted = append(shifted, shiftTime(t, from, e.Start))
+		}
+		return shifted
+	}
+	e.RDates, e.ExDates = shift(e.RDates), shift(e.ExDates)
+}
+
+// shiftOverrides moves the RECURRENCE-ID of overrides along with the start
+// of their series from from to to, so that they still replace the same
+// occurrences. The overrides' own times are kept.
+func shiftOverrides(overrides []*ical.Component, master *ical.Component, from, to time.Time) {
+	if from.Equal(to) {
+		return
+	}
+	start := master.Props.Get(ical.PropDateTimeStart)
+	for _, override := range overrides {
+		rid := ical.NewProp(ical.PropRecurrenceID)
+		setTimeLike(rid, shiftTime(eventFromComponent(override).RecurrenceID, from, to), start)
+		override.Props.Set(rid)
+	}
+}
+
+// shiftTime moves t by the change in date and time of day from from to to,
+// in the time zone of to, so that it keeps its wall-clock time across DST
+// changes as the occurrences of a series do.
+func shiftTime(t, from, to time.Time) time.Time {
+	loc := to.Location()
+	from, t = from.In(loc), t.In(loc)
+	day := func(x time.Time) time.Time { return time.Date(x.Year(), x.Month(), x.Day(), 0, 0, 0, 0, time.UTC) }
+	clock := func(x time.Time) int { return x.Hour()*3600 + x.Minute()*60 + x.Second() }
+	days := int(day(to).Sub(day(from)).Hours() / 24)
+	return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, clock(t)+clock(to)-clock(from), 0, loc)
+}
+
+// DeleteFuture deletes an occurrence and all later ones. Deleting from the
+// first occurrence deletes the whole event.
+func (c *Client) DeleteFuture(ctx context.Context, calPath, uid string, on time.Time) error {
+	s, err := c.getSeries(ctx, calPath, uid)
+	if err != nil {
+		return err
+	}
+	rid, err := resolveOccurrence(s.obj.Data, s.master, on)
+	if err != nil {
+		return err
+	}
+
+	if !rid.After(s.master.Start) {
+		return c.DeleteEvent(ctx, calPath, uid)
+	}
+	if err := s.truncate(rid); err != nil {
+		return err
+	}
+	return c.putSeries(ctx, s)
+}
+
+// truncate ends the series before rid, dropping later RDATEs, EXDATEs and
+// overrides.
+func (s *series) truncate(rid time.Time) error {
+	start := s.masterComp.Props.Get(ical.PropDateTimeStart)
+
+	if rule := s.master.RRule; rule != "" {
+		if rulePart(rule, "COUNT") != "" {
+			before, err := ruleCountBefore(s.master, rid)
+			if err != nil {
+				return err
+			}
+			rule = setRulePart(rule, "COUNT", strconv.Itoa(before))
+		} else {
+			until := rid.Add(-time.Second)
+			if s.master.AllDay {
+				until = rid.AddDate(0, 0, -1)
+			}
+			rule = setRulePart(rule, "UNTIL", untilValue(until, start))
+		}
+		s.masterComp.Props.Get(ical.PropRecurrenceRule).Value = rule
+	}
+
+	for _, name := range []string{ical.PropRecurrenceDates, ical.PropExceptionDates} {
+		times := timesFrom(propTimes(s.masterComp, name), rid, false)
+		s.masterComp.Props.Del(name)
+		for _, t := range times {
+			prop := ical.NewProp(name)
+			setTimeLike(prop, t, start)
+			s.masterComp.Props.Add(prop)
+		}
+	}
+
+	s.removeOverrides(func(t time.Time) bool { return !t.Before(rid) })
+	bumpRevision(s.masterComp, true)
+	return nil
+}
+
+// removeOverrides removes the overrides whose RECURRENCE-ID matches.
+func (s *series) removeOverrides(match func(time.Time) bool) {
+	uid := s.master.UID
+	children := s.obj.Data.Children[:0]
+	for _, child := range s.obj.Data.Children {
+		if child.Name == ical.CompEvent && propValue(child, ical.PropUID) == uid &&
+			child.Props.Get(ical.PropRecurrenceID) != nil && match(eventFromComponent(child).RecurrenceID) {
+			continue
+		}
+		children = append(children, child)
+	}
+	s.obj.Data.Children = children
+}
+
+// ruleCountBefore returns the number of occurrences generated by the
+// RRULE of master before t.
+func ruleCountBefore(master *Event, t time.Time) (int, error) {
+	opt, err := rrule.StrToROptionInLocation(master.RRule, master.Start.Location())
+	if err != nil {
+		return 0, fmt.Errorf("invalid RRULE %q: %w", master.RRule, err)
+	}
+	opt.Dtstart = master.Start
+	rule, err := rrule.NewRRule(*opt)
+	if err != nil {
+		return 0, fmt.Errorf("invalid RRULE %q: %w", master.RRule, err)
+	}
+	n := 0
+	next := rule.Iterator()
+	for occurrence, ok := next(); ok && occurrence.Before(t); occurrence, ok = next() {
+		n++
+	}
+	return n, nil
+}
+
+// timesFrom returns the times at or after t (after is true) or before t.
+func timesFrom(times []time.Time, t time.Time, after bool) []time.Time {
+	var result []time.Time
+	for _, x := range times {
+		if x.Before(t) != after {
+			result = append(result, x)
+		}
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package caldav
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// How completing an occurrence of a recurring task is recorded.
+const (
+	// TaskRecurrenceRoll moves the task to its next occurrence, as
+	// Nextcloud Tasks and most CalDAV task apps do. This is the default.
+	TaskRecurrenceRoll = "roll"
+	// TaskRecurrenceNewInstance also keeps the finished occurrence as a
+	// separate completed task, as Apple Reminders does.
+	TaskRecurrenceNewInstance = "new-instance"
+)
+
+// IsRecurring reports whether the task repeats.
+func (t *Task) IsRecurring() bool {
+	return t.RRule != ""
+}
+
+// nextTaskOccurrence returns task rolled forward to its next occurrence
+// and open again, or false if the series has no further occurrences. The
+// rule is anchored at DTSTART or, without one, at DUE; DUE keeps its
+// distance from DTSTART. COUNT is reduced by the occurrence completed, so
+// the series still ends where it did.
+func nextTaskOccurrence(task *Task) (Task, bool, error) {
+	anchor := task.Start
+	if anchor.IsZero() {
+		anchor = task.Due
+	}
+	if anchor.IsZero() {
+		return Task{}, false, fmt.Errorf("recurring task %s has neither a start nor a due date", task.UID)
+	}
+
+	opt, err := rrule.StrToROptionInLocation(task.RRule, anchor.Location())
+	if err != nil {
+		return Task{}, false, fmt.Errorf("invalid RRULE %q: %w", task.RRule, err)
+	}
+	opt.Dtstart = anchor
+	rule, err := rrule.NewRRule(*opt)
+	if err != nil {
+		return Task{}, false, fmt.Errorf("invalid RRULE %q: %w", task.RRule, err)
+	}
+	next := rule.After(anchor, false)
+	if next.IsZero() {
+		return Task{}, false, nil
+	}
+
+	rolled := *task
+	if !task.Start.IsZero() {
+		rolled.Start = next
+		if !task.Due.IsZero() {
+			rolled.Due = next.Add(task.Due.Sub(task.Start))
+		}
+	} else {
+		rolled.Due = next
+	}
+	if count := rulePart(task.RRule, "COUNT"); count != "" {
+		if n, err := strconv.Atoi(count); err == nil && n > 1 {
+			rolled.RRule = setRulePart(task.RRule, "COUNT", strconv.Itoa(n-1))
+		}
+	}
+	rolled.Status = TaskStatusNeedsAction
+	rolled.Completed = time.Time{}
+	rolled.Percent = 0
+	return rolled, true, nil
+}
+
+// completedOccurrence returns the finished occurrence of a recurring task
+// as a completed task of its own. Its UID is derived from the series and
+// the occurrence, so completing the same occurrence twice conflicts
+// instead of creating a duplicate.
+func completedOccurrence(task *Task, completed time.Time) *Task {
+	anchor := task.Start
+	if anchor.IsZero() {
+		anchor = task.Due
+	}
+	return &Task{
+		UID:         task.UID + "-" + anchor.UTC().Format("20060102T150405Z"),
+		Summary:     task.Summary,
+		Description: task.Description,
+		Due:         task.Due,
+		Start:       task.Start,
+		Completed:   completed,
+		Status:      TaskStatusCompleted,
+		Priority:    task.Priority,
+		Percent:     100,
+		Categories:  task.Categories,
+		Parent:      task.Parent,
+	}
+}
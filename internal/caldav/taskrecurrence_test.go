@@ -0,0 +1,71 @@
+package caldav
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextTaskOccurrence(t *testing.T) {
+	friday := time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC)
+	task := &Task{
+		UID:       "report",
+		Start:     friday.Add(-8 * time.Hour),
+		Due:       friday,
+		RRule:     "FREQ=WEEKLY;COUNT=3",
+		Status:    TaskStatusInProcess,
+		Percent:   50,
+		Completed: friday,
+	}
+
+	rolled, ok, err := nextTaskOccurrence(task)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, friday.AddDate(0, 0, 7).Add(-8*time.Hour), rolled.Start)
+	assert.Equal(t, friday.AddDate(0, 0, 7), rolled.Due)
+	assert.Equal(t, "FREQ=WEEKLY;COUNT=2", rolled.RRule)
+	assert.Equal(t, TaskStatusNeedsAction, rolled.Status)
+	assert.Zero(t, rolled.Percent)
+	assert.True(t, rolled.Completed.IsZero())
+
+	// The last occurrence ends the series
+	rolled, ok, err = nextTaskOccurrence(&Task{UID: "report", Due: friday, RRule: "FREQ=WEEKLY;COUNT=1"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Without DTSTART the rule is anchored at DUE
+	rolled, ok, err = nextTaskOccurrence(&Task{UID: "bins", Due: friday, RRule: "FREQ=DAILY;INTERVAL=2"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, friday.AddDate(0, 0, 2), rolled.Due)
+	assert.True(t, rolled.Start.IsZero())
+
+	_, _, err = nextTaskOccurrence(&Task{UID: "undated", RRule: "FREQ=DAILY"})
+	assert.Error(t, err)
+}
+
+func TestCompletedOccurrence(t *testing.T) {
+	due := time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC)
+	task := &Task{UID: "report", Summary: "Weekly report", Due: due, RRule: "FREQ=WEEKLY", Alarms: []Alarm{{Before: time.Hour}}}
+	done := completedOccurrence(task, due)
+	assert.Equal(t, "report-20261016T170000Z", done.UID)
+	assert.Equal(t, TaskStatusCompleted, done.Status)
+	assert.Empty(t, done.RRule)
+	assert.Empty(t, done.Alarms)
+}
+
+func TestTaskRRule(t *testing.T) {
+	task := &Task{UID: "report", Summary: "Weekly report", Due: time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC), RRule: "FREQ=WEEKLY"}
+	comp := createICalTask(task).Children[0]
+	assert.Equal(t, "FREQ=WEEKLY", comp.Props.Get(ical.PropRecurrenceRule).Value)
+
+	old := taskFromComponent(comp)
+	assert.Equal(t, "FREQ=WEEKLY", old.RRule)
+	updated := *old
+	updated.RRule = ""
+	require.True(t, patchTask(comp, old, &updated))
+	assert.Nil(t, comp.Props.Get(ical.PropRecurrenceRule))
+}
@@ -0,0 +1,262 @@
+package caldav
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+	"github.com/visionik/sogcli/internal/dav"
+	"github.com/visionik/sogcli/internal/timezone"
+)
+
+// Export and import move whole calendar objects: every component, property
+// and VALARM is kept as it is, and recurring events travel with their
+// overrides.
+
+// Export returns the objects of calPath holding components of kind
+// (ical.CompEvent or ical.CompToDo) as one VCALENDAR, with one VTIMEZONE
+// per TZID. If start or end is set, only components in that range are
+// exported.
+func (c *Client) Export(ctx context.Context, calPath, kind string, start, end time.Time) (*ical.Calendar, error) {
+	if !start.IsZero() && end.IsZero() {
+		// A time-range always has an end; year 1 would match nothing
+		end = openEnd
+	}
+	objects, err := c.query(ctx, calPath, &caldav.CalendarQuery{
+		CompRequest: caldav.CalendarCompRequest{Name: "VCALENDAR", AllProps: true, AllComps: true},
+		CompFilter: caldav.CompFilter{
+			Name:  "VCALENDAR",
+			Comps: []caldav.CompFilter{{Name: kind, Start: start, End: end}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query calendar: %w", err)
+	}
+
+	out := ical.NewCalendar()
+	out.Props.SetText(ical.PropVersion, "2.0")
+	out.Props.SetText(ical.PropProductID, "-//sog//CalDAV Client//EN")
+	zones := map[string]bool{}
+	var components []*ical.Component
+	for _, obj := range objects {
+		for _, child := range obj.Data.Children {
+			if child.Name != ical.CompTimezone {
+				components = append(components, child)
+				continue
+			}
+			tzid := propValue(child, ical.PropTimezoneID)
+			if !zones[tzid] {
+				zones[tzid] = true
+				out.Children = append(out.Children, child)
+			}
+		}
+	}
+	out.Children = append(out.Children, components...)
+	timezone.Embed(out)
+	return out, nil
+}
+
+// openEnd ends time ranges that have only a start.
+var openEnd = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// ImportMode decides what happens to imported items that are already in
+// the calendar.
+type ImportMode int
+
+const (
+	ImportSkip    ImportMode = iota // Keep the calendar's copy
+	ImportMerge                     // Keep the newer copy: higher SEQUENCE, then later LAST-MODIFIED
+	ImportReplace                   // Replace the calendar's copy
+)
+
+// Import results
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// ImportResult is the outcome of importing one item: a component and its
+// recurrence overrides, which share a UID.
+type ImportResult struct {
+	UID     string `json:"uid"`
+	Summary string `json:"summary,omitempty"`
+	Result  string `json:"result"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// ReadICalendar decodes the VCALENDARs in r, of which .ics files may hold
+// several, into one.
+func ReadICalendar(r io.Reader) (*ical.Calendar, error) {
+	dec := ical.NewDecoder(r)
+	var out *ical.Calendar
+	for {
+		cal, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode iCalendar: %w", err)
+		}
+		timezone.Register(cal)
+		if out == nil {
+			out = cal
+			continue
+		}
+		out.Children = append(out.Children, cal.Children...)
+	}
+	if out == nil {
+		return nil, fmt.Errorf("no VCALENDAR found")
+	}
+	return out, nil
+}
+
+// Import stores the components of kind (ical.CompEvent or ical.CompToDo)
+// in cal in calPath, one object per UID. Items already in calPath are
+// handled according to mode. Failures are reported per item.
+func (c *Client) Import(ctx context.Context, calPath, kind string, cal *ical.Calendar, mode ImportMode) ([]ImportResult, error) {
+	// Group the components by UID, keeping their order
+	var uids []string
+	items := map[string][]*ical.Component{}
+	var results []ImportResult
+	for _, child := range cal.Children {
+		if child.Name == ical.CompTimezone {
+			continue
+		}
+		uid := propValue(child, ical.PropUID)
+		switch {
+		case child.Name != kind:
+			results = append(results, ImportResult{UID: uid, Summary: propValue(child, ical.PropSummary),
+				Result: ImportSkipped, Reason: "not a " + kind})
+			continue
+		case uid == "":
+			results = append(results, ImportResult{Summary: propValue(child, ical.PropSummary),
+				Result: ImportSkipped, Reason: "no UID"})
+			continue
+		}
+		if _, ok := items[uid]; !ok {
+			uids = append(uids, uid)
+		}
+		items[uid] = append(items[uid], child)
+	}
+
+	existing, err := c.objectsByUID(ctx, calPath, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, uid := range uids {
+		comps := items[uid]
+		obj := ical.NewCalendar()
+		obj.Props.SetText(ical.PropVersion, "2.0")
+		obj.Props.SetText(ical.PropProductID, "-//sog//CalDAV Client//EN")
+		obj.Children = comps
+		timezone.Embed(obj)
+
+		master := findMaster(obj, uid)
+		if master == nil {
+			master = comps[0]
+		}
+		result := ImportResult{UID: uid, Summary: propValue(master, ical.PropSummary)}
+
+		var putErr error
+		old, found := existing[uid]
+		switch {
+		case !found:
+			result.Result = ImportCreated
+			_, putErr = c.client.PutCalendarObject(dav.IfNoneMatch(ctx), calPath+"/"+objectName(uid), obj)
+		case mode == ImportSkip:
+			result.Result, result.Reason = ImportSkipped, "already in the calendar"
+		case mode == ImportMerge && !newerComponent(master, old.master):
+			result.Result, result.Reason = ImportSkipped, "the calendar's copy is not older"
+		default:
+			result.Result = ImportUpdated
+			_, putErr = c.client.PutCalendarObject(dav.IfMatch(ctx, old.obj.ETag), old.obj.Path, obj)
+		}
+		if putErr != nil {
+			result.Result, result.Reason = ImportFailed, putErr.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// storedObject is a calendar object and the main component for a UID.
+type storedObject struct {
+	obj    *caldav.CalendarObject
+	master *ical.Component
+}
+
+// objectsByUID returns the objects in calPath holding components of kind,
+// by UID.
+func (c *Client) objectsByUID(ctx context.Context, calPath, kind string) (map[string]storedObject, error) {
+	objects, err := c.query(ctx, calPath, &caldav.CalendarQuery{
+		CompRequest: caldav.CalendarCompRequest{Name: "VCALENDAR", AllProps: true, AllComps: true},
+		CompFilter: caldav.CompFilter{
+			Name:  "VCALENDAR",
+			Comps: []caldav.CompFilter{{Name: kind}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query calendar: %w", err)
+	}
+	byUID := map[string]storedObject{}
+	for i := range objects {
+		for _, child := range objects[i].Data.Children {
+			if child.Name != kind {
+				continue
+			}
+			uid := propValue(child, ical.PropUID)
+			if s, ok := byUID[uid]; ok && s.master.Props.Get(ical.PropRecurrenceID) == nil {
+				continue
+			}
+			byUID[uid] = storedObject{obj: &objects[i], master: child}
+		}
+	}
+	return byUID, nil
+}
+
+// newerComponent reports whether a is a later revision than b: a higher
+// SEQUENCE, or the same SEQUENCE and a later LAST-MODIFIED.
+func newerComponent(a, b *ical.Component) bool {
+	seqA, _ := strconv.Atoi(propValue(a, ical.PropSequence))
+	seqB, _ := strconv.Atoi(propValue(b, ical.PropSequence))
+	if seqA != seqB {
+		return seqA > seqB
+	}
+	modA, errA := a.Props.DateTime(ical.PropLastModified, time.UTC)
+	modB, errB := b.Props.DateTime(ical.PropLastModified, time.UTC)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return modA.After(modB)
+}
+
+var safeObjectName = regexp.MustCompile(`^[A-Za-z0-9@._-]+$`)
+
+// objectName returns the resource name for a new object. UIDs that are
+// not safe in a URL path are replaced by their hash.
+func objectName(uid string) string {
+	if safeObjectName.MatchString(uid) {
+		return uid + ".ics"
+	}
+	sum := sha1.Sum([]byte(uid))
+	return hex.EncodeToString(sum[:]) + ".ics"
+}
+
+// WriteICalendar encodes cal to w.
+func WriteICalendar(w io.Writer, cal *ical.Calendar) error {
+	if err := ical.NewEncoder(w).Encode(cal); err != nil {
+		return fmt.Errorf("failed to encode iCalendar: %w", err)
+	}
+	return nil
+}
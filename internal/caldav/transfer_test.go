@@ -0,0 +1,155 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recurringMeeting = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Other//EN
+BEGIN:VTIMEZONE
+TZID:Europe/Berlin
+BEGIN:STANDARD
+DTSTART:19701025T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:19700329T020000
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU
+END:DAYLIGHT
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:meeting@example.com
+DTSTAMP:20260301T000000Z
+DTSTART;TZID=Europe/Berlin:20260302T090000
+DTEND;TZID=Europe/Berlin:20260302T100000
+RRULE:FREQ=WEEKLY;COUNT=4
+SUMMARY:Weekly
+SEQUENCE:2
+LAST-MODIFIED:20260301T000000Z
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER:-PT15M
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+UID:meeting@example.com
+DTSTAMP:20260301T000000Z
+RECURRENCE-ID;TZID=Europe/Berlin:20260309T090000
+DTSTART;TZID=Europe/Berlin:20260309T110000
+DTEND;TZID=Europe/Berlin:20260309T120000
+SUMMARY:Weekly (moved)
+SEQUENCE:2
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestExport(t *testing.T) {
+	server := &schedulingServer{calendar: recurringMeeting}
+	client := newSchedulingClient(t, server)
+	cal, err := client.Export(context.Background(), "/cal", ical.CompEvent, time.Time{}, time.Time{})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteICalendar(&buf, cal))
+	data := buf.String()
+	assert.Equal(t, 1, strings.Count(data, "BEGIN:VTIMEZONE"))
+	assert.Equal(t, 2, strings.Count(data, "BEGIN:VEVENT"))
+	assert.Contains(t, data, "RECURRENCE-ID;TZID=Europe/Berlin:20260309T090000")
+	assert.Contains(t, data, "TRIGGER:-PT15M")
+
+	// Exports read back as one calendar
+	back, err := ReadICalendar(strings.NewReader(data + data))
+	require.NoError(t, err)
+	assert.Len(t, back.Events(), 4)
+}
+
+func TestExportFrom(t *testing.T) {
+	server := &schedulingServer{calendar: recurringMeeting}
+	client := newSchedulingClient(t, server)
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, err := client.Export(context.Background(), "/cal", ical.CompEvent, from, time.Time{})
+	require.NoError(t, err)
+
+	// The range is open at the end, not ending in year 1
+	require.Len(t, server.reports, 1)
+	assert.Contains(t, server.reports[0], `start="20260301T000000Z"`)
+	assert.Contains(t, server.reports[0], `end="99991231T235959Z"`)
+}
+
+func TestImport(t *testing.T) {
+	file := strings.Replace(recurringMeeting, "END:VCALENDAR", `BEGIN:VEVENT
+UID:new@example.com
+DTSTAMP:20260301T000000Z
+DTSTART:20260305T090000Z
+SUMMARY:New
+END:VEVENT
+BEGIN:VTODO
+UID:task@example.com
+SUMMARY:A task
+END:VTODO
+BEGIN:VEVENT
+DTSTART:20260306T090000Z
+SUMMARY:No UID
+END:VEVENT
+END:VCALENDAR`, 1)
+	cal, err := ReadICalendar(strings.NewReader(file))
+	require.NoError(t, err)
+
+	server := &schedulingServer{calendar: recurringMeeting}
+	client := newSchedulingClient(t, server)
+	ctx := context.Background()
+	results, err := client.Import(ctx, "/cal", ical.CompEvent, cal, ImportSkip)
+	require.NoError(t, err)
+	byUID := map[string]ImportResult{}
+	for _, r := range results {
+		byUID[r.UID] = r
+	}
+	assert.Equal(t, ImportSkipped, byUID["task@example.com"].Result)
+	assert.Equal(t, "no UID", byUID[""].Reason)
+	assert.Equal(t, ImportSkipped, byUID["meeting@example.com"].Result)
+	assert.Equal(t, ImportCreated, byUID["new@example.com"].Result)
+	require.Len(t, server.puts, 1)
+	assert.Contains(t, server.puts["/cal/new@example.com.ics"], "SUMMARY:New")
+	assert.NotContains(t, server.puts["/cal/new@example.com.ics"], "A task")
+
+	// Merging keeps the newer copy
+	newer := strings.Replace(recurringMeeting, "SEQUENCE:2\nLAST-MODIFIED:20260301T000000Z", "SEQUENCE:2\nLAST-MODIFIED:20260302T000000Z", 1)
+	cal, err = ReadICalendar(strings.NewReader(newer))
+	require.NoError(t, err)
+	results, err = client.Import(ctx, "/cal", ical.CompEvent, cal, ImportMerge)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, ImportUpdated, results[0].Result)
+	data := server.puts["/cal/meeting.ics"]
+	assert.Equal(t, 2, strings.Count(data, "BEGIN:VEVENT"))
+	assert.Contains(t, data, "BEGIN:VTIMEZONE")
+
+	cal, err = ReadICalendar(strings.NewReader(recurringMeeting))
+	require.NoError(t, err)
+	results, err = client.Import(ctx, "/cal", ical.CompEvent, cal, ImportMerge)
+	require.NoError(t, err)
+	assert.Equal(t, ImportSkipped, results[0].Result)
+	results, err = client.Import(ctx, "/cal", ical.CompEvent, cal, ImportReplace)
+	require.NoError(t, err)
+	assert.Equal(t, ImportUpdated, results[0].Result)
+}
+
+func TestObjectName(t *testing.T) {
+	assert.Equal(t, "meeting@example.com.ics", objectName("meeting@example.com"))
+	name := objectName("a/b c")
+	assert.Len(t, name, 44)
+	assert.Equal(t, name, objectName("a/b c"))
+}
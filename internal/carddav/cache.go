@@ -0,0 +1,32 @@
+package carddav
+
+import (
+	"strings"
+
+	"github.com/emersion/go-vcard"
+	"github.com/visionik/sogcli/internal/davsync"
+)
+
+// Syncer returns a syncer for keeping local copies of the server's address
+// books.
+func (c *Client) Syncer() *davsync.Syncer {
+	return &davsync.Syncer{HTTP: c.http, BaseURL: c.url, Kind: davsync.CardDAV}
+}
+
+// CachedContacts returns the contacts in a cached address book, like
+// ListContacts.
+func CachedContacts(col *davsync.Collection) []Contact {
+	contacts := make([]Contact, 0, len(col.Objects))
+	for _, href := range col.Hrefs() {
+		obj := col.Objects[href]
+		card, err := vcard.NewDecoder(strings.NewReader(obj.Data)).Decode()
+		if err != nil {
+			continue // Skip malformed cards
+		}
+		contact := parseVCard(card)
+		contact.ETag = obj.ETag
+		contact.Path = href
+		contacts = append(contacts, contact)
+	}
+	return contacts
+}
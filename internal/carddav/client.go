@@ -10,11 +10,13 @@ import (
 	"github.com/emersion/go-vcard"
 	"github.com/emersion/go-webdav"
 	"github.com/emersion/go-webdav/carddav"
+	"github.com/visionik/sogcli/internal/dav"
 )
 
 // Client wraps a CardDAV client with convenience methods.
 type Client struct {
 	client *carddav.Client
+	http   webdav.HTTPClient
 	email  string
 	url    string
 }
@@ -28,19 +30,21 @@ type Config struct {
 
 // Contact represents a contact/vCard.
 type Contact struct {
-	UID         string   `json:"uid"`
-	FullName    string   `json:"full_name"`
-	FirstName   string   `json:"first_name,omitempty"`
-	LastName    string   `json:"last_name,omitempty"`
-	Emails      []string `json:"emails,omitempty"`
-	Phones      []string `json:"phones,omitempty"`
-	Org         string   `json:"org,omitempty"`
-	Title       string   `json:"title,omitempty"`
-	Note        string   `json:"note,omitempty"`
-	Birthday    string   `json:"birthday,omitempty"`
-	Addresses   []string `json:"addresses,omitempty"`
-	URL         string   `json:"url,omitempty"`
-	ETag        string   `json:"etag,omitempty"`
+	UID        string   `json:"uid"`
+	FullName   string   `json:"full_name"`
+	FirstName  string   `json:"first_name,omitempty"`
+	LastName   string   `json:"last_name,omitempty"`
+	Emails     []string `json:"emails,omitempty"`
+	Phones     []string `json:"phones,omitempty"`
+	Org        string   `json:"org,omitempty"`
+	Title      string   `json:"title,omitempty"`
+	Note       string   `json:"note,omitempty"`
+	Birthday   string   `json:"birthday,omitempty"`
+	Addresses  []string `json:"addresses,omitempty"`
+	URL        string   `json:"url,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	ETag       string   `json:"etag,omitempty"`
+	Path       string   `json:"path,omitempty"` // Object path on the server
 }
 
 // AddressBook represents an address book.
@@ -52,7 +56,7 @@ type AddressBook struct {
 
 // Connect establishes a connection to a CardDAV server.
 func Connect(cfg Config) (*Client, error) {
-	httpClient := webdav.HTTPClientWithBasicAuth(http.DefaultClient, cfg.Email, cfg.Password)
+	httpClient := dav.HTTPClient(webdav.HTTPClientWithBasicAuth(http.DefaultClient, cfg.Email, cfg.Password))
 
 	client, err := carddav.NewClient(httpClient, cfg.URL)
 	if err != nil {
@@ -61,6 +65,7 @@ func Connect(cfg Config) (*Client, error) {
 
 	return &Client{
 		client: client,
+		http:   httpClient,
 		email:  cfg.Email,
 		url:    cfg.URL,
 	}, nil
@@ -138,6 +143,19 @@ func (c *Client) ListContacts(ctx context.Context, bookPath string) ([]Contact,
 
 // GetContact retrieves a single contact by UID.
 func (c *Client) GetContact(ctx context.Context, bookPath, uid string) (*Contact, error) {
+	obj, err := c.getContactObject(ctx, bookPath, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	contact := parseVCard(obj.Card)
+	contact.ETag = obj.ETag
+	contact.Path = obj.Path
+	return &contact, nil
+}
+
+// getContactObject fetches the address object holding the contact with uid.
+func (c *Client) getContactObject(ctx context.Context, bookPath, uid string) (*carddav.AddressObject, error) {
 	query := &carddav.AddressBookQuery{
 		DataRequest: carddav.AddressDataRequest{
 			AllProp: true,
@@ -156,10 +174,7 @@ func (c *Client) GetContact(ctx context.Context, bookPath, uid string) (*Contact
 	if len(objects) == 0 {
 		return nil, fmt.Errorf("contact not found: %s", uid)
 	}
-
-	contact := parseVCard(objects[0].Card)
-	contact.ETag = objects[0].ETag
-	return &contact, nil
+	return &objects[0], nil
 }
 
 // SearchContacts searches for contacts matching a query.
@@ -189,29 +204,149 @@ func (c *Client) SearchContacts(ctx context.Context, bookPath, searchQuery strin
 	return contacts, nil
 }
 
-// CreateContact creates a new contact.
+// FindContactByEmail returns the first contact with the given email address.
+func (c *Client) FindContactByEmail(ctx context.Context, bookPath, email string) (*Contact, error) {
+	query := &carddav.AddressBookQuery{
+		DataRequest: carddav.AddressDataRequest{
+			AllProp: true,
+		},
+		PropFilters: []carddav.PropFilter{{
+			Name:        vcard.FieldEmail,
+			TextMatches: []carddav.TextMatch{{Text: email, MatchType: carddav.MatchEquals}},
+		}},
+	}
+
+	objects, err := c.client.QueryAddressBook(ctx, bookPath, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search address book: %w", err)
+	}
+
+	for _, obj := range objects {
+		contact := parseVCard(obj.Card)
+		for _, e := range contact.Emails {
+			if strings.EqualFold(e, email) {
+				contact.ETag = obj.ETag
+				return &contact, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("contact not found: %s", email)
+}
+
+// ListGroup returns the contacts in a group. A group is either a category
+// (CATEGORIES) or a group card (KIND:group, or Apple's
+// X-ADDRESSBOOKSERVER-KIND) whose members are listed by MEMBER.
+func (c *Client) ListGroup(ctx context.Context, bookPath, group string) ([]Contact, error) {
+	query := &carddav.AddressBookQuery{
+		DataRequest: carddav.AddressDataRequest{
+			AllProp: true,
+		},
+	}
+
+	objects, err := c.client.QueryAddressBook(ctx, bookPath, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query address book: %w", err)
+	}
+
+	// Collect members of matching group cards
+	members := map[string]bool{}
+	foundGroup := false
+	for _, obj := range objects {
+		if !isGroupCard(obj.Card) || !strings.EqualFold(obj.Card.PreferredValue(vcard.FieldFormattedName), group) {
+			continue
+		}
+		foundGroup = true
+		for _, key := range []string{vcard.FieldMember, "X-ADDRESSBOOKSERVER-MEMBER"} {
+			for _, field := range obj.Card[key] {
+				members[strings.TrimPrefix(field.Value, "urn:uuid:")] = true
+			}
+		}
+	}
+
+	var contacts []Contact
+	for _, obj := range objects {
+		if isGroupCard(obj.Card) {
+			continue
+		}
+		contact := parseVCard(obj.Card)
+		contact.ETag = obj.ETag
+
+		inGroup := members[strings.TrimPrefix(contact.UID, "urn:uuid:")]
+		for _, cat := range contact.Categories {
+			if strings.EqualFold(cat, group) {
+				inGroup = true
+				foundGroup = true
+			}
+		}
+		if inGroup {
+			contacts = append(contacts, contact)
+		}
+	}
+
+	if !foundGroup {
+		return nil, fmt.Errorf("group not found: %s", group)
+	}
+	return contacts, nil
+}
+
+// isGroupCard reports whether a vCard describes a group rather than a person.
+func isGroupCard(card vcard.Card) bool {
+	if strings.EqualFold(card.Value(vcard.FieldKind), string(vcard.KindGroup)) {
+		return true
+	}
+	return strings.EqualFold(card.Value("X-ADDRESSBOOKSERVER-KIND"), "group")
+}
+
+// CreateContact creates a new contact. It fails with a *dav.ConflictError
+// if a vCard with the same name already exists.
 func (c *Client) CreateContact(ctx context.Context, bookPath string, contact *Contact) error {
 	card := createVCard(contact)
-	_, err := c.client.PutAddressObject(ctx, bookPath+"/"+contact.UID+".vcf", card)
+	obj, err := c.client.PutAddressObject(dav.IfNoneMatch(ctx), bookPath+"/"+contact.UID+".vcf", card)
 	if err != nil {
 		return fmt.Errorf("failed to create contact: %w", err)
 	}
+	contact.Path, contact.ETag = obj.Path, obj.ETag
 	return nil
 }
 
-// UpdateContact updates an existing contact.
+// UpdateContact updates an existing contact. Only changed fields are
+// written; photos, custom labels and other fields of the stored vCard are
+// kept.
+//
+// The write is conditional on contact.ETag (or, if empty, the ETag just
+// fetched): if the contact changed on the server in the meantime, a
+// *dav.ConflictError is returned. On success contact.ETag is updated.
 func (c *Client) UpdateContact(ctx context.Context, bookPath string, contact *Contact) error {
-	card := createVCard(contact)
-	_, err := c.client.PutAddressObject(ctx, bookPath+"/"+contact.UID+".vcf", card)
+	obj, err := c.getContactObject(ctx, bookPath, contact.UID)
+	if err != nil {
+		return err
+	}
+	contact.Path = obj.Path
+	if contact.ETag == "" {
+		contact.ETag = obj.ETag
+	}
+	old := parseVCard(obj.Card)
+	if !patchVCard(obj.Card, &old, contact) {
+		return nil
+	}
+
+	updated, err := c.client.PutAddressObject(dav.IfMatch(ctx, contact.ETag), obj.Path, obj.Card)
 	if err != nil {
 		return fmt.Errorf("failed to update contact: %w", err)
 	}
+	contact.ETag = updated.ETag
 	return nil
 }
 
-// DeleteContact deletes a contact.
+// DeleteContact deletes a contact, unless it changed on the server while
+// being looked up.
 func (c *Client) DeleteContact(ctx context.Context, bookPath, uid string) error {
-	err := c.client.RemoveAll(ctx, bookPath+"/"+uid+".vcf")
+	path, etag := bookPath+"/"+uid+".vcf", ""
+	if obj, err := c.getContactObject(ctx, bookPath, uid); err == nil {
+		path, etag = obj.Path, obj.ETag
+	}
+	err := c.client.RemoveAll(dav.IfMatch(ctx, etag), path)
 	if err != nil {
 		return fmt.Errorf("failed to delete contact: %w", err)
 	}
@@ -289,6 +424,9 @@ func parseVCard(card vcard.Card) Contact {
 		contact.URL = field.Value
 	}
 
+	// Categories
+	contact.Categories = card.Categories()
+
 	return contact
 }
 
@@ -360,5 +498,10 @@ func createVCard(contact *Contact) vcard.Card {
 		card.SetValue(vcard.FieldURL, contact.URL)
 	}
 
+	// Categories
+	if len(contact.Categories) > 0 {
+		card.SetCategories(contact.Categories)
+	}
+
 	return card
 }
@@ -0,0 +1,127 @@
+package carddav
+
+import (
+	"strings"
+	"time"
+
+	"github.com/emersion/go-vcard"
+)
+
+// patchVCard applies the differences between old and updated to card,
+// keeping every other field and all parameters (TYPE, PREF, PHOTO, X-
+// fields, ...). old must be the contact parsed from card. It reports
+// whether anything changed; if so, REV is set to now.
+func patchVCard(card vcard.Card, old, updated *Contact) bool {
+	changed := false
+	changed = patchValue(card, vcard.FieldFormattedName, old.FullName, updated.FullName) || changed
+	changed = patchValue(card, vcard.FieldOrganization, old.Org, updated.Org) || changed
+	changed = patchValue(card, vcard.FieldTitle, old.Title, updated.Title) || changed
+	changed = patchValue(card, vcard.FieldNote, old.Note, updated.Note) || changed
+	changed = patchValue(card, vcard.FieldBirthday, old.Birthday, updated.Birthday) || changed
+	changed = patchValue(card, vcard.FieldURL, old.URL, updated.URL) || changed
+
+	// N keeps its additional names, prefixes and suffixes
+	if old.FirstName != updated.FirstName || old.LastName != updated.LastName {
+		parts := []string{"", "", "", "", ""}
+		if field := card.Get(vcard.FieldName); field != nil {
+			copy(parts, strings.Split(field.Value, ";"))
+		}
+		parts[0], parts[1] = updated.LastName, updated.FirstName
+		if updated.FirstName == "" && updated.LastName == "" && strings.Join(parts[2:], "") == "" {
+			delete(card, vcard.FieldName)
+		} else if field := card.Get(vcard.FieldName); field != nil {
+			field.Value = strings.Join(parts, ";")
+		} else {
+			card.Set(vcard.FieldName, &vcard.Field{Value: strings.Join(parts, ";")})
+		}
+		changed = true
+	}
+
+	changed = patchFields(card, vcard.FieldEmail, old.Emails, updated.Emails, strings.ToLower) || changed
+	changed = patchFields(card, vcard.FieldTelephone, old.Phones, updated.Phones, nil) || changed
+	changed = patchFields(card, vcard.FieldAddress, old.Addresses, updated.Addresses, formatAddress) || changed
+
+	if strings.Join(nonEmpty(old.Categories), ",") != strings.Join(nonEmpty(updated.Categories), ",") {
+		if categories := nonEmpty(updated.Categories); len(categories) > 0 {
+			card.SetCategories(categories)
+		} else {
+			delete(card, vcard.FieldCategories)
+		}
+		changed = true
+	}
+
+	if changed {
+		card.SetRevision(time.Now().UTC())
+	}
+	return changed
+}
+
+// patchValue sets a single-valued field if its value changed, keeping its
+// parameters. An empty value removes the field.
+func patchValue(card vcard.Card, name, old, updated string) bool {
+	if old == updated {
+		return false
+	}
+	switch field := card.Get(name); {
+	case updated == "":
+		delete(card, name)
+	case field != nil:
+		field.Value = updated
+	default:
+		card.SetValue(name, updated)
+	}
+	return true
+}
+
+// patchFields updates a multi-valued field such as EMAIL. Fields whose
+// value is kept stay untouched with their parameters; removed values are
+// dropped and new ones added. display maps a raw field value to the form
+// used in Contact (nil for identity).
+func patchFields(card vcard.Card, name string, old, updated []string, display func(string) string) bool {
+	if display == nil {
+		display = func(s string) string { return s }
+	}
+	if strings.Join(old, "\x00") == strings.Join(updated, "\x00") {
+		return false
+	}
+
+	wanted := map[string]int{}
+	for _, v := range updated {
+		wanted[display(v)]++
+	}
+	var fields []*vcard.Field
+	for _, field := range card[name] {
+		if key := display(field.Value); wanted[key] > 0 {
+			fields = append(fields, field)
+			wanted[key]--
+		}
+	}
+	for _, v := range updated {
+		if key := display(v); wanted[key] > 0 {
+			fields = append(fields, &vcard.Field{Value: v})
+			wanted[key]--
+		}
+	}
+
+	if len(fields) == 0 {
+		delete(card, name)
+	} else {
+		card[name] = fields
+	}
+	return true
+}
+
+// formatAddress formats an ADR value the way parseVCard shows it.
+func formatAddress(value string) string {
+	return strings.Trim(strings.ReplaceAll(value, ";", ", "), ", ")
+}
+
+func nonEmpty(list []string) []string {
+	var result []string
+	for _, s := range list {
+		if s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package carddav
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-vcard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const richCard = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"UID:alice@example.com\r\n" +
+	"FN:Alice Smith\r\n" +
+	"N:Smith;Alice;Marie;Dr.;\r\n" +
+	"EMAIL;TYPE=WORK:alice@work.example.com\r\n" +
+	"EMAIL;TYPE=HOME:alice@home.example.com\r\n" +
+	"TEL;TYPE=CELL:+1 555 0100\r\n" +
+	"ADR;TYPE=HOME:;;1 Main St;Springfield;;12345;USA\r\n" +
+	"PHOTO;ENCODING=b;TYPE=JPEG:AAAA\r\n" +
+	"X-ABLabel:friend\r\n" +
+	"END:VCARD\r\n"
+
+func TestPatchVCard(t *testing.T) {
+	card, err := vcard.NewDecoder(strings.NewReader(richCard)).Decode()
+	require.NoError(t, err)
+	old := parseVCard(card)
+
+	updated := old
+	updated.FirstName = "Alicia"
+	updated.Emails = []string{"alice@home.example.com", "alice@new.example.com"}
+	updated.Org = "Acme"
+	require.True(t, patchVCard(card, &old, &updated))
+
+	assert.Equal(t, "Smith;Alicia;Marie;Dr.;", card.Get(vcard.FieldName).Value)
+	emails := card[vcard.FieldEmail]
+	require.Len(t, emails, 2)
+	assert.Equal(t, "HOME", emails[0].Params.Get(vcard.ParamType))
+	assert.Equal(t, "alice@new.example.com", emails[1].Value)
+	assert.Equal(t, "Acme", card.Value(vcard.FieldOrganization))
+
+	// Untouched fields keep their parameters
+	assert.Equal(t, ";;1 Main St;Springfield;;12345;USA", card.Value(vcard.FieldAddress))
+	assert.Equal(t, "CELL", card.Get(vcard.FieldTelephone).Params.Get(vcard.ParamType))
+	assert.Equal(t, "AAAA", card.Value(vcard.FieldPhoto))
+	assert.Equal(t, "friend", card.Value("X-ABLABEL"))
+	assert.NotEmpty(t, card.Value(vcard.FieldRevision))
+
+	again := parseVCard(card)
+	assert.False(t, patchVCard(card, &again, &again))
+}
@@ -0,0 +1,335 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/webcal"
+)
+
+// AgendaFlags selects the calendars read by the agenda commands.
+type AgendaFlags struct {
+	Calendars    []string `name:"calendar" sep:"none" help:"Calendar path or name, or subscription name; repeatable (default: primary calendar and subscriptions)"`
+	AllCalendars bool     `help:"Read every calendar of the account"`
+	AllAccounts  bool     `help:"Read every account with a CalDAV server"`
+}
+
+// with returns the flags with a calendar given as a positional argument
+// added.
+func (f AgendaFlags) with(calendar string) AgendaFlags {
+	if calendar != "" {
+		f.Calendars = append([]string{calendar}, f.Calendars...)
+	}
+	return f
+}
+
+// agendaSource is a calendar or subscription read by an agenda command.
+type agendaSource struct {
+	sub     *config.Subscription // Set for subscriptions
+	root    *Root                // Root for the calendar's account
+	client  *caldav.Client
+	account string
+	path    string
+	label   string // Shown with the events; "" if only one calendar is read
+	color   string
+}
+
+// agendaEvents returns the events in [start, end) of the selected
+// calendars and subscriptions, fetched concurrently and merged: sorted by
+// start time, with an event found in several calendars shown once. It also
+// returns the calendar colors by label. Without a selection, the primary
+// calendar and all subscriptions are read; without a CalDAV server, only
+// the subscriptions.
+func agendaEvents(root *Root, flags AgendaFlags, start, end time.Time) ([]caldav.Event, map[string]string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	ctx := context.Background()
+
+	sources, err := agendaSources(ctx, root, cfg, flags, ical.CompEvent)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, src := range sources {
+		if src.client != nil {
+			defer src.client.Close()
+		}
+	}
+
+	lists := make([][]caldav.Event, len(sources))
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i := range sources {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lists[i], errs[i] = sources[i].events(ctx, start, end)
+		}(i)
+	}
+	wg.Wait()
+
+	colors := map[string]string{}
+	for i, src := range sources {
+		if src.label != "" && src.color != "" {
+			colors[src.label] = src.color
+		}
+		if errs[i] == nil {
+			continue
+		}
+		if len(sources) == 1 {
+			return nil, nil, errs[i]
+		}
+		fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", src.name(), errs[i])
+	}
+	return mergeEvents(lists), colors, nil
+}
+
+// agendaSources resolves the selected calendars and subscriptions. comp
+// is the component read, VEVENT or VTODO: --all-calendars selects the
+// calendars that support it, and subscriptions only hold events.
+func agendaSources(ctx context.Context, root *Root, cfg *config.Config, flags AgendaFlags, comp string) ([]agendaSource, error) {
+	var subs []config.Subscription
+	if comp == ical.CompEvent {
+		subs = cfg.Subscriptions
+	}
+
+	var sources []agendaSource
+	var refs []string // CalDAV calendars
+	for _, ref := range flags.Calendars {
+		if sub := cfg.FindSubscription(ref); sub != nil && len(subs) > 0 {
+			sources = append(sources, agendaSource{sub: sub, label: sub.Name})
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	if len(flags.Calendars) == 0 {
+		for i := range subs {
+			sub := &subs[i]
+			sources = append(sources, agendaSource{sub: sub, label: sub.Name})
+		}
+	}
+
+	var accounts []string
+	switch {
+	case len(flags.Calendars) > 0 && len(refs) == 0:
+		// Subscriptions only
+	case flags.AllAccounts:
+		for email, acct := range cfg.Accounts {
+			if acct.CalDAV.URL != "" {
+				accounts = append(accounts, email)
+			}
+		}
+		sort.Slice(accounts, func(i, j int) bool {
+			// The default account first
+			if (accounts[i] == cfg.DefaultAccount) != (accounts[j] == cfg.DefaultAccount) {
+				return accounts[i] == cfg.DefaultAccount
+			}
+			return accounts[i] < accounts[j]
+		})
+		if len(accounts) == 0 && len(sources) == 0 {
+			return nil, fmt.Errorf("no account has a CalDAV server")
+		}
+	case len(refs) > 0 || len(subs) == 0 || hasCalDAV(cfg, root):
+		accounts = []string{root.Account}
+	}
+
+	var calendars []agendaSource
+	found := map[string]bool{}
+	for _, email := range accounts {
+		acctRoot := *root
+		if email != "" {
+			acctRoot.Account = email
+		}
+		client, defaultPath, err := getCalDAVClient(&acctRoot)
+		if err != nil {
+			return nil, err
+		}
+		if acctRoot.Account == "" {
+			acctRoot.Account = cfg.DefaultAccount
+		}
+		src := agendaSource{root: &acctRoot, client: client, account: acctRoot.Account}
+
+		// Names and --all-calendars need the list of calendars; it also
+		// provides labels and colors when several calendars are read
+		var list []caldav.Calendar
+		listed := false
+		if flags.AllCalendars || hasCalendarNames(refs) || ((len(accounts) > 1 || len(refs) > 1) && !root.Offline) {
+			list, err = client.FindCalendars(ctx)
+			switch {
+			case err == nil:
+				listed = true
+			case flags.AllCalendars || hasCalendarNames(refs):
+				client.Close()
+				return nil, fmt.Errorf("failed to list calendars of %s: %w", src.account, err)
+			}
+		}
+
+		var selected []caldav.Calendar
+		switch {
+		case flags.AllCalendars:
+			for _, cal := range list {
+				if cal.Supports(comp) {
+					selected = append(selected, cal)
+				}
+			}
+		case len(refs) > 0:
+			for _, ref := range refs {
+				cal, err := matchCalendar(list, ref)
+				switch {
+				case err == nil:
+				case strings.HasPrefix(ref, "/") && email == accounts[0]:
+					cal = &caldav.Calendar{Path: ref}
+				case len(accounts) > 1:
+					continue
+				default:
+					client.Close()
+					return nil, err
+				}
+				found[ref] = true
+				selected = append(selected, *cal)
+			}
+		default:
+			cal := caldav.Calendar{Path: defaultPath}
+			if match, err := matchCalendar(list, defaultPath); listed && err == nil {
+				cal = *match
+			}
+			selected = append(selected, cal)
+		}
+		if len(selected) == 0 {
+			client.Close()
+			continue
+		}
+		for _, cal := range selected {
+			s := src
+			s.path = cal.Path
+			s.label = cal.Name
+			if s.label == "" {
+				s.label = calendarLabel(cal.Path, s.account)
+			}
+			s.color = cal.Color
+			calendars = append(calendars, s)
+		}
+	}
+	for _, ref := range refs {
+		if !found[ref] && len(accounts) > 1 {
+			return nil, fmt.Errorf("calendar not found: %s", ref)
+		}
+	}
+
+	// Labels tell calendars apart, so they are only needed for several
+	labelCalendars(calendars)
+	return append(calendars, sources...), nil
+}
+
+// hasCalendarNames reports whether any calendar is given by name rather
+// than path.
+func hasCalendarNames(refs []string) bool {
+	for _, ref := range refs {
+		if !strings.HasPrefix(ref, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// calendarLabel names a calendar without a display name.
+func calendarLabel(calPath, account string) string {
+	if name := path.Base(strings.TrimSuffix(calPath, "/")); name != "." && name != "/" && name != "" {
+		return name
+	}
+	return account
+}
+
+// labelCalendars clears the labels of a lone calendar and qualifies
+// labels that several accounts share with the account.
+func labelCalendars(calendars []agendaSource) {
+	if len(calendars) == 1 {
+		calendars[0].label = ""
+		return
+	}
+	accounts := map[string]map[string]bool{}
+	for _, cal := range calendars {
+		if accounts[cal.label] == nil {
+			accounts[cal.label] = map[string]bool{}
+		}
+		accounts[cal.label][cal.account] = true
+	}
+	for i := range calendars {
+		if len(accounts[calendars[i].label]) > 1 {
+			calendars[i].label += " (" + calendars[i].account + ")"
+		}
+	}
+}
+
+// name identifies the source in warnings.
+func (s *agendaSource) name() string {
+	if s.sub != nil {
+		return s.sub.Name
+	}
+	if s.label != "" {
+		return s.label
+	}
+	return calendarLabel(s.path, s.account)
+}
+
+// events returns the source's events in [start, end).
+func (s *agendaSource) events(ctx context.Context, start, end time.Time) ([]caldav.Event, error) {
+	if s.sub != nil {
+		feed, err := subscriptionFeed(s.sub)
+		if err != nil {
+			return nil, err
+		}
+		events, err := feed.Events(ctx, start, end)
+		if errors.Is(err, webcal.ErrStale) {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", s.sub.Name, err)
+			err = nil
+		}
+		return events, err
+	}
+
+	events, err := listEvents(ctx, s.root, s.client, s.path, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	for i := range events {
+		events[i].Source = s.label
+		events[i].Calendar = s.path
+		events[i].Account = s.account
+	}
+	return events, nil
+}
+
+// mergeEvents merges event lists, sorted by start time. An occurrence
+// found in several lists, such as a meeting in a shared calendar, is kept
+// from the first.
+func mergeEvents(lists [][]caldav.Event) []caldav.Event {
+	seen := map[string]bool{}
+	var events []caldav.Event
+	for _, list := range lists {
+		for _, e := range list {
+			if e.UID != "" {
+				key := e.UID + "|" + e.Start.UTC().Format(time.RFC3339)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+	return events
+}
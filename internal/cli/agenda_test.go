@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+func TestMergeEvents(t *testing.T) {
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return day.Add(time.Duration(h) * time.Hour) }
+	work := []caldav.Event{
+		{UID: "standup", Start: at(9), Source: "Work"},
+		{UID: "review", Start: at(14), Source: "Work"},
+	}
+	team := []caldav.Event{
+		{UID: "standup", Start: at(9), Source: "Team"}, // Also in Work
+		{UID: "weekly", Start: at(11), Source: "Team"},
+		{UID: "weekly", Start: at(11).AddDate(0, 0, 7), Source: "Team"}, // Next occurrence
+	}
+	personal := []caldav.Event{{Start: at(8), Source: "Personal"}, {Start: at(8), Source: "Personal"}}
+
+	events := mergeEvents([][]caldav.Event{work, team, personal})
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Source+":"+e.UID)
+	}
+	assert.Equal(t, []string{"Personal:", "Personal:", "Work:standup", "Team:weekly", "Work:review", "Team:weekly"}, got)
+}
+
+func TestLabelCalendars(t *testing.T) {
+	calendars := []agendaSource{
+		{account: "me@work.example", label: "Calendar"},
+		{account: "me@work.example", label: "Team"},
+		{account: "me@home.example", label: "Calendar"},
+	}
+	labelCalendars(calendars)
+	assert.Equal(t, "Calendar (me@work.example)", calendars[0].label)
+	assert.Equal(t, "Team", calendars[1].label)
+	assert.Equal(t, "Calendar (me@home.example)", calendars[2].label)
+
+	// A lone calendar needs no label
+	calendars = []agendaSource{{account: "me@work.example", label: "Calendar"}}
+	labelCalendars(calendars)
+	assert.Empty(t, calendars[0].label)
+
+	assert.Equal(t, "work", calendarLabel("/dav/calendars/me/work/", "me@work.example"))
+	assert.Equal(t, "me@work.example", calendarLabel("", "me@work.example"))
+}
+
+func TestAgendaFlagsWith(t *testing.T) {
+	flags := AgendaFlags{Calendars: []string{"Team"}}
+	assert.Equal(t, []string{"Work", "Team"}, flags.with("Work").Calendars)
+	assert.Equal(t, []string{"Team"}, flags.with("").Calendars)
+	assert.Equal(t, []string{"Team"}, flags.Calendars)
+}
+
+func TestColorize(t *testing.T) {
+	assert.Equal(t, "\x1b[38;2;58;135;173m[Work]\x1b[0m", colorize("#3A87AD", "[Work]"))
+	assert.Equal(t, "\x1b[38;2;58;135;173m[Work]\x1b[0m", colorize("#3a87adff", "[Work]"))
+	assert.Equal(t, "[Work]", colorize("blue", "[Work]"))
+
+	assert.True(t, useColor(&Root{Color: "always"}))
+	assert.False(t, useColor(&Root{Color: "never"}))
+	assert.False(t, useColor(&Root{Color: "auto", JSON: true}))
+}
@@ -1,7 +1,11 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/visionik/sogcli/internal/config"
 	"github.com/visionik/sogcli/internal/discover"
@@ -16,6 +20,7 @@ type AuthCmd struct {
 	Test     AuthTestCmd     `cmd:"" help:"Test account connection"`
 	Remove   AuthRemoveCmd   `cmd:"" help:"Remove an account"`
 	Password AuthPasswordCmd `cmd:"" help:"Set protocol-specific passwords"`
+	Identity AuthIdentityCmd `cmd:"" aliases:"identities" help:"Manage sending identities and aliases"`
 }
 
 // AuthAddCmd adds a new account.
@@ -266,6 +271,8 @@ type AuthPasswordCmd struct {
 	CalDAV  string `help:"Password for CalDAV" name:"caldav"`
 	CardDAV string `help:"Password for CardDAV" name:"carddav"`
 	WebDAV  string `help:"Password for WebDAV" name:"webdav"`
+	PGP     string `help:"Passphrase for the OpenPGP secret key" name:"pgp"`
+	SMIME   string `help:"Password for S/MIME PKCS#12 files" name:"smime"`
 	Default string `help:"Default password (used when protocol-specific not set)" name:"default"`
 }
 
@@ -319,11 +326,162 @@ func (c *AuthPasswordCmd) Run(root *Root) error {
 		}
 		set = append(set, "webdav")
 	}
+	if c.PGP != "" {
+		if err := config.SetPasswordForProtocol(c.Email, config.ProtocolPGP, c.PGP); err != nil {
+			return fmt.Errorf("failed to set PGP passphrase: %w", err)
+		}
+		set = append(set, "pgp")
+	}
+	if c.SMIME != "" {
+		if err := config.SetPasswordForProtocol(c.Email, config.ProtocolSMIME, c.SMIME); err != nil {
+			return fmt.Errorf("failed to set S/MIME password: %w", err)
+		}
+		set = append(set, "smime")
+	}
 
 	if len(set) == 0 {
-		return fmt.Errorf("no passwords specified. Use --default, --imap, --smtp, --caldav, --carddav, or --webdav")
+		return fmt.Errorf("no passwords specified. Use --default, --imap, --smtp, --caldav, --carddav, --webdav, --pgp, or --smime")
 	}
 
 	fmt.Printf("Set passwords for %s: %v\n", c.Email, set)
 	return nil
 }
+
+// AuthIdentityCmd manages sending identities for an account.
+type AuthIdentityCmd struct {
+	List   AuthIdentityListCmd   `cmd:"" default:"1" help:"List sending identities"`
+	Add    AuthIdentityAddCmd    `cmd:"" aliases:"set" help:"Add or update a sending identity"`
+	Remove AuthIdentityRemoveCmd `cmd:"" aliases:"rm" help:"Remove a sending identity"`
+}
+
+// AuthIdentityListCmd lists sending identities.
+type AuthIdentityListCmd struct{}
+
+// Run executes the auth identity list command.
+func (c *AuthIdentityListCmd) Run(root *Root) error {
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return err
+	}
+	acct, err := cfg.GetAccount(email)
+	if err != nil {
+		return err
+	}
+
+	ids := acct.SendingIdentities()
+	if root.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, id := range ids {
+			if err := enc.Encode(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fmt.Printf("%-32s %-24s %-28s %s\n", "ADDRESS", "NAME", "REPLY-TO", "BCC-SELF")
+	for _, id := range ids {
+		bcc := ""
+		if id.BccSelf {
+			bcc = "yes"
+		}
+		fmt.Printf("%-32s %-24s %-28s %s\n", id.Email, id.Name, id.ReplyTo, bcc)
+	}
+	return nil
+}
+
+// AuthIdentityAddCmd adds or updates a sending identity.
+type AuthIdentityAddCmd struct {
+	Address       string `arg:"" help:"Address to send as"`
+	Name          string `help:"Display name"`
+	ReplyTo       string `help:"Reply-To address" name:"reply-to"`
+	Signature     string `help:"Signature text"`
+	SignatureFile string `help:"Read signature from file" name:"signature-file" type:"existingfile"`
+	BccSelf       bool   `help:"Bcc this address on every message sent as it" name:"bcc-self"`
+	PGPKey        string `help:"OpenPGP signing key (key ID or fingerprint; default: the address)" name:"pgp-key"`
+	SMIMECert     string `help:"S/MIME certificate and key (PKCS#12 .p12/.pfx, or PEM)" name:"smime-cert" type:"existingfile"`
+	SMIMEKey      string `help:"S/MIME private key (PEM), if not in --smime-cert" name:"smime-key" type:"existingfile"`
+}
+
+// Run executes the auth identity add command.
+func (c *AuthIdentityAddCmd) Run(root *Root) error {
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return err
+	}
+
+	signature := c.Signature
+	if c.SignatureFile != "" {
+		data, err := os.ReadFile(c.SignatureFile)
+		if err != nil {
+			return fmt.Errorf("failed to read signature: %w", err)
+		}
+		signature = strings.TrimRight(string(data), "\n")
+	}
+
+	// Store certificate paths absolute so they work from any directory
+	for _, path := range []*string{&c.SMIMECert, &c.SMIMEKey} {
+		if *path != "" {
+			abs, err := filepath.Abs(*path)
+			if err != nil {
+				return err
+			}
+			*path = abs
+		}
+	}
+
+	id := config.Identity{
+		Email:     c.Address,
+		Name:      c.Name,
+		ReplyTo:   c.ReplyTo,
+		Signature: signature,
+		BccSelf:   c.BccSelf,
+		PGPKey:    c.PGPKey,
+		SMIMECert: c.SMIMECert,
+		SMIMEKey:  c.SMIMEKey,
+	}
+	if err := cfg.SetIdentity(email, id); err != nil {
+		return fmt.Errorf("failed to save identity: %w", err)
+	}
+
+	fmt.Printf("Saved identity %s for %s\n", c.Address, email)
+	return nil
+}
+
+// AuthIdentityRemoveCmd removes a sending identity.
+type AuthIdentityRemoveCmd struct {
+	Address string `arg:"" help:"Identity address to remove"`
+}
+
+// Run executes the auth identity remove command.
+func (c *AuthIdentityRemoveCmd) Run(root *Root) error {
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.RemoveIdentity(email, c.Address); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed identity %s from %s\n", c.Address, email)
+	return nil
+}
+
+// loadAccountConfig loads the config and resolves the account to use.
+func loadAccountConfig(root *Root) (*config.Config, string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load config: %w", err)
+	}
+
+	email := root.Account
+	if email == "" {
+		email = cfg.DefaultAccount
+	}
+	if email == "" {
+		return nil, "", fmt.Errorf("no account specified. Use --account or set a default")
+	}
+
+	return cfg, email, nil
+}
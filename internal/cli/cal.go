@@ -3,59 +3,88 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/visionik/sogcli/internal/caldav"
 	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/dateexpr"
+	"github.com/visionik/sogcli/internal/dav"
+	"github.com/visionik/sogcli/internal/timezone"
 )
 
 // CalCmd handles calendar operations.
 type CalCmd struct {
-	List      CalListCmd      `cmd:"" aliases:"events" help:"List events"`
-	Get       CalGetCmd       `cmd:"" aliases:"event" help:"Get event details"`
-	Search    CalSearchCmd    `cmd:"" help:"Search events"`
-	Today     CalTodayCmd     `cmd:"" help:"Today's events"`
-	Week      CalWeekCmd      `cmd:"" help:"This week's events"`
-	Create    CalCreateCmd    `cmd:"" help:"Create an event"`
-	Update    CalUpdateCmd    `cmd:"" help:"Update an event"`
-	Delete    CalDeleteCmd    `cmd:"" help:"Delete an event"`
-	Calendars CalCalendarsCmd `cmd:"" help:"List calendars"`
+	List          CalListCmd          `cmd:"" aliases:"events" help:"List events"`
+	Get           CalGetCmd           `cmd:"" aliases:"event" help:"Get event details"`
+	Search        CalSearchCmd        `cmd:"" help:"Search events"`
+	Today         CalTodayCmd         `cmd:"" help:"Today's events"`
+	Week          CalWeekCmd          `cmd:"" help:"This week's events"`
+	Month         CalMonthCmd         `cmd:"" help:"Month grid"`
+	Agenda        CalAgendaCmd        `cmd:"" help:"Events grouped by day"`
+	Conflicts     CalConflictsCmd     `cmd:"" help:"Find events that overlap each other"`
+	Create        CalCreateCmd        `cmd:"" help:"Create an event"`
+	Update        CalUpdateCmd        `cmd:"" help:"Update an event"`
+	Delete        CalDeleteCmd        `cmd:"" help:"Delete an event"`
+	Calendars     CalCalendarsCmd     `cmd:"" help:"List calendars"`
+	Free          CalFreeCmd          `cmd:"" help:"Find times when you and attendees are free"`
+	Inbox         CalInboxCmd         `cmd:"" help:"Pending invitations and replies in the scheduling inbox"`
+	Export        CalExportCmd        `cmd:"" help:"Export a calendar as iCalendar (.ics) to stdout"`
+	Import        CalImportCmd        `cmd:"" help:"Import events from an .ics file"`
+	Subscribe     CalSubscribeCmd     `cmd:"" help:"Subscribe to a read-only iCalendar feed (webcal)"`
+	Unsubscribe   CalUnsubscribeCmd   `cmd:"" help:"Remove a subscription"`
+	Subscriptions CalSubscriptionsCmd `cmd:"" help:"List subscriptions"`
+
+	TZ        string `name:"tz" help:"Time zone for times given on the command line, e.g. Europe/Berlin (default: system zone)"`
+	DisplayTZ string `name:"display-tz" help:"Show event times in this time zone (default: system zone)"`
+}
+
+// inputZone returns the time zone for times given on the command line.
+func (c *CalCmd) inputZone() (*time.Location, error) {
+	loc, err := timezone.Load(c.TZ)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --tz: %w", err)
+	}
+	return loc, nil
+}
+
+// displayZone returns the time zone events are shown in.
+func (c *CalCmd) displayZone() (*time.Location, error) {
+	loc, err := timezone.Load(c.DisplayTZ)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --display-tz: %w", err)
+	}
+	return loc, nil
 }
 
 // CalListCmd lists events in a calendar.
 type CalListCmd struct {
-	Calendar string `arg:"" optional:"" help:"Calendar path (default: primary)"`
-	From     string `help:"Start date (YYYY-MM-DD or relative: today, tomorrow)" default:"today"`
-	To       string `help:"End date (YYYY-MM-DD or relative: +7d, +30d)" default:"+30d"`
+	Calendar string `arg:"" optional:"" help:"Calendar path or name, or subscription name (default: primary calendar and subscriptions)"`
+	From     string `help:"Start date (YYYY-MM-DD or relative: today, tomorrow, monday, -1w)" default:"today"`
+	To       string `help:"End date (YYYY-MM-DD or relative: +7d, +30d, 'next month')" default:"+30d"`
 	Max      int    `help:"Maximum events to return" default:"50"`
+	AgendaFlags
 }
 
 // Run executes the cal list command.
 func (c *CalListCmd) Run(root *Root) error {
-	client, calPath, err := getCalDAVClient(root)
+	display, err := root.Cal.displayZone()
 	if err != nil {
 		return err
 	}
-	defer client.Close()
-
-	if c.Calendar != "" {
-		calPath = c.Calendar
-	}
-
-	start, err := parseDate(c.From)
+	start, err := parseDate(c.From, display)
 	if err != nil {
 		return fmt.Errorf("invalid --from date: %w", err)
 	}
-	end, err := parseDate(c.To)
+	end, err := parseDate(c.To, display)
 	if err != nil {
 		return fmt.Errorf("invalid --to date: %w", err)
 	}
 
-	ctx := context.Background()
-	events, err := client.ListEvents(ctx, calPath, start, end)
+	events, colors, err := agendaEvents(root, c.AgendaFlags.with(c.Calendar), start, end)
 	if err != nil {
-		return fmt.Errorf("failed to list events: %w", err)
+		return err
 	}
 
 	if len(events) == 0 {
@@ -67,44 +96,71 @@ func (c *CalListCmd) Run(root *Root) error {
 	if c.Max > 0 && len(events) > c.Max {
 		events = events[:c.Max]
 	}
+	events = inZone(events, display)
 
 	if root.JSON {
 		return outputEventsJSON(events)
 	}
+	if root.Plain {
+		return outputEventsPlain(events)
+	}
 
-	return outputEventsTable(events)
+	return outputEventsTable(events, agendaColors(root, colors))
 }
 
 // CalTodayCmd lists today's events.
 type CalTodayCmd struct {
-	Calendar string `arg:"" optional:"" help:"Calendar path (default: primary)"`
+	Calendar string `arg:"" optional:"" help:"Calendar path or name, or subscription name (default: primary calendar and subscriptions)"`
+	AgendaFlags
 }
 
 // Run executes the cal today command.
 func (c *CalTodayCmd) Run(root *Root) error {
 	cmd := &CalListCmd{
-		Calendar: c.Calendar,
-		From:     "today",
-		To:       "tomorrow",
+		Calendar:    c.Calendar,
+		AgendaFlags: c.AgendaFlags,
+		From:        "today",
+		To:          "tomorrow",
 	}
 	return cmd.Run(root)
 }
 
 // CalWeekCmd lists this week's events.
 type CalWeekCmd struct {
-	Calendar string `arg:"" optional:"" help:"Calendar path (default: primary)"`
+	Calendar string `arg:"" optional:"" help:"Calendar path or name, or subscription name (default: primary calendar and subscriptions)"`
+	Grid     bool   `help:"Show a grid with a column per day and a row per hour"`
+	AgendaFlags
 }
 
 // Run executes the cal week command.
 func (c *CalWeekCmd) Run(root *Root) error {
+	if c.Grid && !root.JSON && !root.Plain {
+		return c.grid(root)
+	}
 	cmd := &CalListCmd{
-		Calendar: c.Calendar,
-		From:     "today",
-		To:       "+7d",
+		Calendar:    c.Calendar,
+		AgendaFlags: c.AgendaFlags,
+		From:        "today",
+		To:          "+7d",
 	}
 	return cmd.Run(root)
 }
 
+// grid shows the next seven days as a grid.
+func (c *CalWeekCmd) grid(root *Root) error {
+	display, err := root.Cal.displayZone()
+	if err != nil {
+		return err
+	}
+	start := dayStart(time.Now().In(display))
+	end := start.AddDate(0, 0, 7)
+	events, colors, err := agendaEvents(root, c.AgendaFlags.with(c.Calendar), start, end)
+	if err != nil {
+		return err
+	}
+	return newCalView(root, colors, display).weekGrid(os.Stdout, start, 7, inZone(events, display))
+}
+
 // CalGetCmd gets event details.
 type CalGetCmd struct {
 	UID      string `arg:"" help:"Event UID"`
@@ -129,47 +185,47 @@ func (c *CalGetCmd) Run(root *Root) error {
 		return fmt.Errorf("failed to get event: %w", err)
 	}
 
+	display, err := root.Cal.displayZone()
+	if err != nil {
+		return err
+	}
+	zone := event.Start.Location()
+	events := inZone([]caldav.Event{*event}, display)
+
 	if root.JSON {
-		return outputEventsJSON([]caldav.Event{*event})
+		return outputEventsJSON(events)
 	}
 
-	return outputEventDetail(event)
+	return outputEventDetail(&events[0], zone)
 }
 
 // CalSearchCmd searches events.
 type CalSearchCmd struct {
-	Query    string `arg:"" help:"Search query (matches title, description, location)"`
-	Calendar string `help:"Calendar path (default: primary)"`
-	From     string `help:"Start date" default:"today"`
-	To       string `help:"End date" default:"+365d"`
-	Max      int    `help:"Maximum results" default:"50"`
+	Query string `arg:"" help:"Search query (matches title, description, location)"`
+	From  string `help:"Start date" default:"today"`
+	To    string `help:"End date" default:"+365d"`
+	Max   int    `help:"Maximum results" default:"50"`
+	AgendaFlags
 }
 
 // Run executes the cal search command.
 func (c *CalSearchCmd) Run(root *Root) error {
-	client, calPath, err := getCalDAVClient(root)
+	display, err := root.Cal.displayZone()
 	if err != nil {
 		return err
 	}
-	defer client.Close()
-
-	if c.Calendar != "" {
-		calPath = c.Calendar
-	}
-
-	from, err := parseDate(c.From)
+	from, err := parseDate(c.From, display)
 	if err != nil {
 		return fmt.Errorf("invalid --from: %w", err)
 	}
-	to, err := parseDate(c.To)
+	to, err := parseDate(c.To, display)
 	if err != nil {
 		return fmt.Errorf("invalid --to: %w", err)
 	}
 
-	ctx := context.Background()
-	events, err := client.ListEvents(ctx, calPath, from, to)
+	events, colors, err := agendaEvents(root, c.AgendaFlags, from, to)
 	if err != nil {
-		return fmt.Errorf("failed to list events: %w", err)
+		return err
 	}
 
 	// Filter by query
@@ -190,24 +246,32 @@ func (c *CalSearchCmd) Run(root *Root) error {
 		fmt.Println("No matching events found.")
 		return nil
 	}
+	matches = inZone(matches, display)
 
 	if root.JSON {
 		return outputEventsJSON(matches)
 	}
+	if root.Plain {
+		return outputEventsPlain(matches)
+	}
 
-	return outputEventsTable(matches)
+	return outputEventsTable(matches, agendaColors(root, colors))
 }
 
 // CalCreateCmd creates an event.
 type CalCreateCmd struct {
 	Title       string   `arg:"" help:"Event title"`
-	Start       string   `help:"Start time (YYYY-MM-DDTHH:MM or YYYY-MM-DD for all-day)" required:""`
-	End         string   `help:"End time (YYYY-MM-DDTHH:MM or YYYY-MM-DD for all-day)"`
-	Duration    string   `help:"Duration (e.g., 1h, 30m) - alternative to --end"`
+	Start       string   `help:"Start time (YYYY-MM-DDTHH:MM, 'tomorrow 2pm', 'next friday 9:30'; a date alone for all-day)" required:""`
+	End         string   `help:"End time (same formats as --start)"`
+	Duration    string   `help:"Duration (e.g., 1h, 30m, 2d) - alternative to --end"`
 	Location    string   `help:"Event location"`
 	Description string   `help:"Event description"`
 	Calendar    string   `help:"Calendar path (default: primary)"`
 	Attendees   []string `help:"Attendee email addresses"`
+	Repeat      string   `help:"Repeat rule (e.g., 'daily', 'weekdays', 'weekly on mon,wed until 2027-01-01', 'monthly 6 times')"`
+	RRule       string   `name:"rrule" help:"Raw iCalendar RRULE (e.g., FREQ=WEEKLY;BYDAY=MO,WE)"`
+	Remind      []string `help:"Reminder before the start (e.g., 15m, 1h, 1d); repeatable"`
+	NoConflicts bool     `name:"no-conflicts" help:"Refuse to create the event if it overlaps another in your calendars"`
 }
 
 // Run executes the cal create command.
@@ -222,19 +286,23 @@ func (c *CalCreateCmd) Run(root *Root) error {
 		calPath = c.Calendar
 	}
 
-	start, allDay, err := parseDateTime(c.Start)
+	loc, err := root.Cal.inputZone()
+	if err != nil {
+		return err
+	}
+	start, allDay, err := parseDateTime(c.Start, loc)
 	if err != nil {
 		return fmt.Errorf("invalid --start: %w", err)
 	}
 
 	var end time.Time
 	if c.End != "" {
-		end, _, err = parseDateTime(c.End)
+		end, _, err = parseDateTime(c.End, loc)
 		if err != nil {
 			return fmt.Errorf("invalid --end: %w", err)
 		}
 	} else if c.Duration != "" {
-		dur, err := time.ParseDuration(c.Duration)
+		dur, err := dateexpr.ParseDuration(c.Duration)
 		if err != nil {
 			return fmt.Errorf("invalid --duration: %w", err)
 		}
@@ -245,6 +313,15 @@ func (c *CalCreateCmd) Run(root *Root) error {
 		end = start.Add(1 * time.Hour)
 	}
 
+	rule, err := eventRepeatRule(c.Repeat, c.RRule, start, allDay)
+	if err != nil {
+		return err
+	}
+	alarms, _, err := parseReminders(c.Remind, false)
+	if err != nil {
+		return err
+	}
+
 	event := &caldav.Event{
 		UID:         generateUID(),
 		Summary:     c.Title,
@@ -254,6 +331,12 @@ func (c *CalCreateCmd) Run(root *Root) error {
 		Location:    c.Location,
 		Description: c.Description,
 		Attendees:   c.Attendees,
+		RRule:       rule,
+		Alarms:      alarms,
+	}
+
+	if err := checkConflicts(root, event, c.NoConflicts); err != nil {
+		return err
 	}
 
 	ctx := context.Background()
@@ -271,13 +354,19 @@ func (c *CalCreateCmd) Run(root *Root) error {
 
 // CalUpdateCmd updates an event.
 type CalUpdateCmd struct {
-	UID         string `arg:"" help:"Event UID"`
-	Title       string `help:"New title"`
-	Start       string `help:"New start time"`
-	End         string `help:"New end time"`
-	Location    string `help:"New location"`
-	Description string `help:"New description"`
-	Calendar    string `help:"Calendar path (default: primary)"`
+	UID           string   `arg:"" help:"Event UID"`
+	Title         string   `help:"New title"`
+	Start         string   `help:"New start time"`
+	End           string   `help:"New end time"`
+	Location      string   `help:"New location"`
+	Description   string   `help:"New description"`
+	Calendar      string   `help:"Calendar path (default: primary)"`
+	Repeat        string   `help:"New repeat rule for the series ('none' to stop repeating)"`
+	RRule         string   `name:"rrule" help:"New raw RRULE for the series"`
+	Instance      string   `help:"Update only the occurrence on this date (YYYY-MM-DD or YYYY-MM-DDTHH:MM)"`
+	ThisAndFuture bool     `help:"With --instance, update this and all later occurrences (splits the series)"`
+	Merge         bool     `help:"If the event changed on the server, merge both versions and ask about conflicting fields"`
+	Remind        []string `help:"Replace reminders (e.g., 15m, 1d; 'none' removes them)"`
 }
 
 // Run executes the cal update command.
@@ -291,27 +380,103 @@ func (c *CalUpdateCmd) Run(root *Root) error {
 	if c.Calendar != "" {
 		calPath = c.Calendar
 	}
+	if c.ThisAndFuture && c.Instance == "" {
+		return fmt.Errorf("--this-and-future requires --instance")
+	}
+	if root.Force && c.Merge {
+		return fmt.Errorf("--force and --merge cannot be combined")
+	}
+
+	loc, err := root.Cal.inputZone()
+	if err != nil {
+		return err
+	}
+
+	ctx := writeContext(root.Force)
+	if c.Instance != "" {
+		on, err := parseInstance(c.Instance, loc)
+		if err != nil {
+			return err
+		}
+		if (c.Repeat != "" || c.RRule != "") && !c.ThisAndFuture {
+			return fmt.Errorf("--repeat and --rrule change the series; use them with --this-and-future or without --instance")
+		}
+
+		var applyErr error
+		apply := func(event *caldav.Event) {
+			applyErr = c.apply(event, loc)
+		}
+		if c.ThisAndFuture {
+			uid, err := client.UpdateFuture(ctx, calPath, c.UID, on, apply)
+			if applyErr != nil {
+				return applyErr
+			}
+			if err != nil {
+				return fmt.Errorf("failed to update event: %w", conflictHint(err, false))
+			}
+			if uid != c.UID {
+				fmt.Printf("Updated event: %s (this and future occurrences are now %s)\n", c.UID, uid)
+				return nil
+			}
+		} else {
+			err := client.UpdateOccurrence(ctx, calPath, c.UID, on, apply)
+			if applyErr != nil {
+				return applyErr
+			}
+			if err != nil {
+				return fmt.Errorf("failed to update event: %w", conflictHint(err, false))
+			}
+		}
+		fmt.Printf("Updated event: %s (%s)\n", c.UID, c.Instance)
+		return nil
+	}
 
-	ctx := context.Background()
 	event, err := client.GetEvent(ctx, calPath, c.UID)
 	if err != nil {
 		return fmt.Errorf("failed to get event: %w", err)
 	}
+	base := *event
+	if err := c.apply(event, loc); err != nil {
+		return err
+	}
 
-	// Apply updates
+	err = client.UpdateEvent(ctx, calPath, event)
+	if dav.IsConflict(err) && c.Merge {
+		var theirs *caldav.Event
+		theirs, err = client.GetEvent(ctx, calPath, c.UID)
+		if err != nil {
+			return fmt.Errorf("failed to get event: %w", err)
+		}
+		merged := dav.Merge(base, *event, *theirs, promptConflict(os.Stdin, os.Stdout))
+		err = client.UpdateEvent(ctx, calPath, &merged)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update event: %w", conflictHint(err, true))
+	}
+
+	fmt.Printf("Updated event: %s\n", c.UID)
+	return nil
+}
+
+// apply applies the requested changes to an event or occurrence.
+func (c *CalUpdateCmd) apply(event *caldav.Event, loc *time.Location) error {
 	if c.Title != "" {
 		event.Summary = c.Title
 	}
 	if c.Start != "" {
-		start, allDay, err := parseDateTime(c.Start)
+		start, allDay, err := parseDateTime(c.Start, loc)
 		if err != nil {
 			return fmt.Errorf("invalid --start: %w", err)
 		}
+		// Keep the duration unless a new end is given
+		if c.End == "" && !event.End.IsZero() {
+			event.End = start.Add(event.End.Sub(event.Start))
+		}
 		event.Start = start
 		event.AllDay = allDay
 	}
 	if c.End != "" {
-		end, _, err := parseDateTime(c.End)
+		end, _, err := parseDateTime(c.End, loc)
 		if err != nil {
 			return fmt.Errorf("invalid --end: %w", err)
 		}
@@ -323,19 +488,33 @@ func (c *CalUpdateCmd) Run(root *Root) error {
 	if c.Description != "" {
 		event.Description = c.Description
 	}
-
-	if err := client.UpdateEvent(ctx, calPath, event); err != nil {
-		return fmt.Errorf("failed to update event: %w", err)
+	if len(c.Remind) > 0 {
+		alarms, _, err := parseReminders(c.Remind, false)
+		if err != nil {
+			return err
+		}
+		event.Alarms = alarms
+	}
+	if strings.EqualFold(c.Repeat, "none") {
+		event.RRule = ""
+		event.RDates = nil
+		event.ExDates = nil
+	} else if c.Repeat != "" || c.RRule != "" {
+		rule, err := eventRepeatRule(c.Repeat, c.RRule, event.Start, event.AllDay)
+		if err != nil {
+			return err
+		}
+		event.RRule = rule
 	}
-
-	fmt.Printf("Updated event: %s\n", c.UID)
 	return nil
 }
 
 // CalDeleteCmd deletes an event.
 type CalDeleteCmd struct {
-	UID      string `arg:"" help:"Event UID"`
-	Calendar string `help:"Calendar path (default: primary)"`
+	UID           string `arg:"" help:"Event UID"`
+	Calendar      string `help:"Calendar path (default: primary)"`
+	Instance      string `help:"Delete only the occurrence on this date (YYYY-MM-DD or YYYY-MM-DDTHH:MM)"`
+	ThisAndFuture bool   `help:"With --instance, delete this and all later occurrences"`
 }
 
 // Run executes the cal delete command.
@@ -349,46 +528,41 @@ func (c *CalDeleteCmd) Run(root *Root) error {
 	if c.Calendar != "" {
 		calPath = c.Calendar
 	}
-
-	ctx := context.Background()
-	if err := client.DeleteEvent(ctx, calPath, c.UID); err != nil {
-		return fmt.Errorf("failed to delete event: %w", err)
-	}
-
-	fmt.Printf("Deleted event: %s\n", c.UID)
-	return nil
-}
-
-// CalCalendarsCmd lists available calendars.
-type CalCalendarsCmd struct{}
-
-// Run executes the cal calendars command.
-func (c *CalCalendarsCmd) Run(root *Root) error {
-	client, _, err := getCalDAVClient(root)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	ctx := context.Background()
-	calendars, err := client.FindCalendars(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to list calendars: %w", err)
+	if c.ThisAndFuture && c.Instance == "" {
+		return fmt.Errorf("--this-and-future requires --instance")
 	}
 
-	if len(calendars) == 0 {
-		fmt.Println("No calendars found.")
+	ctx := writeContext(root.Force)
+	if c.Instance != "" {
+		loc, err := root.Cal.inputZone()
+		if err != nil {
+			return err
+		}
+		on, err := parseInstance(c.Instance, loc)
+		if err != nil {
+			return err
+		}
+		if c.ThisAndFuture {
+			err = client.DeleteFuture(ctx, calPath, c.UID, on)
+		} else {
+			err = client.DeleteOccurrence(ctx, calPath, c.UID, on)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete event: %w", conflictHint(err, false))
+		}
+		if c.ThisAndFuture {
+			fmt.Printf("Deleted event: %s (from %s on)\n", c.UID, c.Instance)
+		} else {
+			fmt.Printf("Deleted event: %s (%s)\n", c.UID, c.Instance)
+		}
 		return nil
 	}
 
-	if root.JSON {
-		return outputCalendarsJSON(calendars)
+	if err := client.DeleteEvent(ctx, calPath, c.UID); err != nil {
+		return fmt.Errorf("failed to delete event: %w", conflictHint(err, false))
 	}
 
-	fmt.Printf("%-40s %s\n", "PATH", "NAME")
-	for _, cal := range calendars {
-		fmt.Printf("%-40s %s\n", cal.Path, cal.Name)
-	}
+	fmt.Printf("Deleted event: %s\n", c.UID)
 	return nil
 }
 
@@ -421,10 +595,18 @@ func getCalDAVClient(root *Root) (*caldav.Client, string, error) {
 		return nil, "", fmt.Errorf("failed to get password: %w", err)
 	}
 
+	switch acct.CalDAV.TaskRecurrence {
+	case "", caldav.TaskRecurrenceRoll, caldav.TaskRecurrenceNewInstance:
+	default:
+		return nil, "", fmt.Errorf("invalid caldav.task_recurrence %q for %s (use %s or %s)",
+			acct.CalDAV.TaskRecurrence, email, caldav.TaskRecurrenceRoll, caldav.TaskRecurrenceNewInstance)
+	}
+
 	client, err := caldav.Connect(caldav.Config{
-		URL:      acct.CalDAV.URL,
-		Email:    email,
-		Password: password,
+		URL:            acct.CalDAV.URL,
+		Email:          email,
+		Password:       password,
+		TaskRecurrence: acct.CalDAV.TaskRecurrence,
 	})
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to connect to CalDAV: %w", err)
@@ -433,69 +615,80 @@ func getCalDAVClient(root *Root) (*caldav.Client, string, error) {
 	return client, acct.CalDAV.DefaultCalendar, nil
 }
 
-// parseDate parses a date string (YYYY-MM-DD, today, tomorrow, +Nd).
-func parseDate(s string) (time.Time, error) {
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-
-	switch strings.ToLower(s) {
-	case "today":
-		return today, nil
-	case "tomorrow":
-		return today.AddDate(0, 0, 1), nil
-	case "yesterday":
-		return today.AddDate(0, 0, -1), nil
-	}
-
-	// Relative: +Nd
-	if strings.HasPrefix(s, "+") && strings.HasSuffix(s, "d") {
-		var days int
-		if _, err := fmt.Sscanf(s, "+%dd", &days); err == nil {
-			return today.AddDate(0, 0, days), nil
-		}
-	}
+// parseDate parses a date expression like "today", "+7d" or "next
+// monday" (see dateexpr.Parse) relative to the current time in loc.
+func parseDate(s string, loc *time.Location) (time.Time, error) {
+	t, _, err := dateexpr.Parse(s, time.Now().In(loc))
+	return t, err
+}
+
+// parseDateTime parses a date and time expression like "2026-03-01T14:00"
+// or "tomorrow 2pm" in loc. allDay reports that only a day was given.
+func parseDateTime(s string, loc *time.Location) (t time.Time, allDay bool, err error) {
+	return dateexpr.Parse(s, time.Now().In(loc))
+}
 
-	// ISO date
-	t, err := time.Parse("2006-01-02", s)
+// parseInstance parses an --instance value in loc. A date selects the
+// first occurrence on that day.
+func parseInstance(s string, loc *time.Location) (time.Time, error) {
+	t, _, err := dateexpr.Parse(s, time.Now().In(loc))
 	if err != nil {
-		return time.Time{}, fmt.Errorf("invalid date format: %s (use YYYY-MM-DD, today, tomorrow, or +Nd)", s)
+		return time.Time{}, fmt.Errorf("invalid --instance: %w", err)
 	}
 	return t, nil
 }
 
-// parseDateTime parses a datetime string (YYYY-MM-DDTHH:MM or YYYY-MM-DD).
-func parseDateTime(s string) (time.Time, bool, error) {
-	// Try full datetime first
-	t, err := time.Parse("2006-01-02T15:04", s)
-	if err == nil {
-		return t, false, nil
-	}
+// generateUID generates a unique identifier for an event.
+func generateUID() string {
+	return fmt.Sprintf("%d@sog", time.Now().UnixNano())
+}
 
-	// Try date only (all-day event)
-	t, err = time.Parse("2006-01-02", s)
-	if err == nil {
-		return t, true, nil
+// inZone returns events with their times converted to loc. All-day events
+// keep their dates.
+func inZone(events []caldav.Event, loc *time.Location) []caldav.Event {
+	out := make([]caldav.Event, len(events))
+	for i, e := range events {
+		if !e.AllDay {
+			e.Start = e.Start.In(loc)
+			e.End = e.End.In(loc)
+			if !e.RecurrenceID.IsZero() {
+				e.RecurrenceID = e.RecurrenceID.In(loc)
+			}
+			e.RDates = timesIn(e.RDates, loc)
+			e.ExDates = timesIn(e.ExDates, loc)
+		}
+		out[i] = e
 	}
-
-	return time.Time{}, false, fmt.Errorf("invalid datetime format: %s (use YYYY-MM-DDTHH:MM or YYYY-MM-DD)", s)
+	return out
 }
 
-// generateUID generates a unique identifier for an event.
-func generateUID() string {
-	return fmt.Sprintf("%d@sog", time.Now().UnixNano())
+func timesIn(times []time.Time, loc *time.Location) []time.Time {
+	if times == nil {
+		return nil
+	}
+	out := make([]time.Time, len(times))
+	for i, t := range times {
+		out[i] = t.In(loc)
+	}
+	return out
 }
 
 // outputEventsJSON outputs events as JSON.
 func outputEventsJSON(events []caldav.Event) error {
 	for _, e := range events {
-		fmt.Printf(`{"uid":"%s","summary":"%s","start":"%s","end":"%s","location":"%s","all_day":%t}`+"\n",
-			e.UID, e.Summary, e.Start.Format(time.RFC3339), e.End.Format(time.RFC3339), e.Location, e.AllDay)
+		var recurrenceID string
+		if !e.RecurrenceID.IsZero() {
+			recurrenceID = e.RecurrenceID.Format(time.RFC3339)
+		}
+		fmt.Printf(`{"uid":"%s","summary":"%s","start":"%s","end":"%s","location":"%s","all_day":%t,"rrule":"%s","recurrence_id":"%s","reminders":%s,"source":"%s","calendar":"%s","account":"%s"}`+"\n",
+			e.UID, e.Summary, e.Start.Format(time.RFC3339), e.End.Format(time.RFC3339), e.Location, e.AllDay, e.RRule, recurrenceID, remindersJSON(e.Alarms), e.Source, e.Calendar, e.Account)
 	}
 	return nil
 }
 
-// outputEventsTable outputs events as a table.
-func outputEventsTable(events []caldav.Event) error {
+// outputEventsTable outputs events as a table. Each event's source label
+// is shown in its color from colors, if any.
+func outputEventsTable(events []caldav.Event, colors map[string]string) error {
 	fmt.Printf("%-20s %-12s %-8s %s\n", "DATE", "TIME", "DURATION", "SUMMARY")
 	for _, e := range events {
 		date := e.Start.Format("2006-01-02 Mon")
@@ -518,24 +711,36 @@ func outputEventsTable(events []caldav.Event) error {
 			}
 		}
 		summary := e.Summary
+		var label string
+		if e.Source != "" {
+			label = "[" + e.Source + "]"
+			summary = label + " " + summary
+		}
 		if len(summary) > 40 {
 			summary = summary[:37] + "..."
 		}
+		if color := colors[e.Source]; color != "" && strings.HasPrefix(summary, label) {
+			summary = colorize(color, label) + summary[len(label):]
+		}
 		fmt.Printf("%-20s %-12s %-8s %s\n", date, timeStr, durStr, summary)
 	}
 	return nil
 }
 
-// outputEventDetail outputs a single event in detail.
-func outputEventDetail(event *caldav.Event) error {
+// outputEventDetail outputs a single event in detail. zone is the time
+// zone the event was created in, shown if it differs from the display zone.
+func outputEventDetail(event *caldav.Event, zone *time.Location) error {
 	fmt.Printf("UID:         %s\n", event.UID)
 	fmt.Printf("Summary:     %s\n", event.Summary)
 	if event.AllDay {
 		fmt.Printf("Date:        %s (all day)\n", event.Start.Format("2006-01-02 Mon"))
 	} else {
-		fmt.Printf("Start:       %s\n", event.Start.Format("2006-01-02 15:04 Mon"))
-		fmt.Printf("End:         %s\n", event.End.Format("2006-01-02 15:04 Mon"))
+		fmt.Printf("Start:       %s\n", event.Start.Format("2006-01-02 15:04 Mon MST"))
+		fmt.Printf("End:         %s\n", event.End.Format("2006-01-02 15:04 Mon MST"))
 		fmt.Printf("Duration:    %s\n", event.End.Sub(event.Start))
+		if timezone.TZID(zone) != "" && zone.String() != event.Start.Location().String() {
+			fmt.Printf("Time zone:   %s (%s)\n", zone, event.Start.In(zone).Format("15:04 MST"))
+		}
 	}
 	if event.Location != "" {
 		fmt.Printf("Location:    %s\n", event.Location)
@@ -552,13 +757,17 @@ func outputEventDetail(event *caldav.Event) error {
 	if event.Status != "" {
 		fmt.Printf("Status:      %s\n", event.Status)
 	}
-	return nil
-}
-
-// outputCalendarsJSON outputs calendars as JSON.
-func outputCalendarsJSON(calendars []caldav.Calendar) error {
-	for _, c := range calendars {
-		fmt.Printf(`{"path":"%s","name":"%s","description":"%s"}`+"\n", c.Path, c.Name, c.Description)
+	if event.RRule != "" {
+		fmt.Printf("Repeats:     %s\n", event.RRule)
+	}
+	for _, t := range event.RDates {
+		fmt.Printf("Also on:     %s\n", t.Format("2006-01-02 15:04 Mon"))
+	}
+	for _, t := range event.ExDates {
+		fmt.Printf("Except:      %s\n", t.Format("2006-01-02 15:04 Mon"))
+	}
+	for _, a := range event.Alarms {
+		fmt.Printf("Reminder:    %s\n", a)
 	}
 	return nil
 }
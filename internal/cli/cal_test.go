@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+func TestParseDateTimeInZone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	start, allDay, err := parseDateTime("2026-07-15T10:00", berlin)
+	require.NoError(t, err)
+	assert.False(t, allDay)
+	assert.Equal(t, berlin, start.Location())
+	assert.Equal(t, time.Date(2026, 7, 15, 8, 0, 0, 0, time.UTC), start.UTC())
+
+	day, allDay, err := parseDateTime("2026-07-15", berlin)
+	require.NoError(t, err)
+	assert.True(t, allDay)
+	assert.Equal(t, 15, day.Day())
+
+	_, _, err = parseDateTime("15.07.2026", berlin)
+	assert.Error(t, err)
+}
+
+func TestInZone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	start := time.Date(2026, 7, 15, 10, 0, 0, 0, time.UTC)
+	events := []caldav.Event{
+		{Summary: "Call", Start: start, End: start.Add(time.Hour), ExDates: []time.Time{start}},
+		{Summary: "Holiday", Start: time.Date(2026, 7, 15, 0, 0, 0, 0, time.UTC), AllDay: true},
+	}
+
+	shown := inZone(events, tokyo)
+	assert.Equal(t, 19, shown[0].Start.Hour())
+	assert.Equal(t, 20, shown[0].End.Hour())
+	assert.Equal(t, tokyo, shown[0].ExDates[0].Location())
+	assert.True(t, shown[0].Start.Equal(start))
+	// All-day events keep their date
+	assert.Equal(t, time.UTC, shown[1].Start.Location())
+	// The input is not modified
+	assert.Equal(t, time.UTC, events[0].ExDates[0].Location())
+}
@@ -0,0 +1,301 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/emersion/go-ical"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+// CalCalendarsCmd manages calendar collections.
+type CalCalendarsCmd struct {
+	List   CalCalendarsListCmd   `cmd:"" aliases:"ls" default:"1" help:"List calendars"`
+	Create CalCalendarsCreateCmd `cmd:"" help:"Create a calendar or task list"`
+	Rename CalCalendarsRenameCmd `cmd:"" help:"Rename a calendar"`
+	Color  CalCalendarsColorCmd  `cmd:"" help:"Set a calendar's color"`
+	Delete CalCalendarsDeleteCmd `cmd:"" aliases:"rm" help:"Delete a calendar and everything in it"`
+}
+
+// CalCalendarsListCmd lists available calendars.
+type CalCalendarsListCmd struct{}
+
+// Run executes the cal calendars list command.
+func (c *CalCalendarsListCmd) Run(root *Root) error {
+	client, _, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	calendars, err := client.FindCalendars(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list calendars: %w", err)
+	}
+
+	if len(calendars) == 0 {
+		fmt.Println("No calendars found.")
+		return nil
+	}
+
+	if root.JSON {
+		return outputCalendarsJSON(calendars)
+	}
+
+	fmt.Printf("%-40s %-12s %-10s %s\n", "PATH", "TYPE", "COLOR", "NAME")
+	for _, cal := range calendars {
+		fmt.Printf("%-40s %-12s %-10s %s\n", cal.Path, calendarType(&cal), cal.Color, calendarName(&cal))
+	}
+	return nil
+}
+
+// CalCalendarsCreateCmd creates a calendar collection.
+type CalCalendarsCreateCmd struct {
+	Name        string `arg:"" help:"Display name"`
+	Type        string `help:"What the calendar holds: event, todo (a task list) or both" enum:"event,todo,both" default:"event"`
+	Color       string `name:"calendar-color" help:"Color, e.g. #3A87AD"`
+	Description string `help:"Description"`
+	Path        string `help:"Collection path (default: derived from the name in your calendar home)"`
+}
+
+// Run executes the cal calendars create command.
+func (c *CalCalendarsCreateCmd) Run(root *Root) error {
+	cal := &caldav.Calendar{Name: c.Name, Description: c.Description, Path: c.Path}
+	switch c.Type {
+	case "event":
+		cal.Components = []string{ical.CompEvent}
+	case "todo":
+		cal.Components = []string{ical.CompToDo}
+	default:
+		cal.Components = []string{ical.CompEvent, ical.CompToDo}
+	}
+	if c.Color != "" {
+		color, err := normalizeColor(c.Color)
+		if err != nil {
+			return err
+		}
+		cal.Color = color
+	}
+
+	client, _, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.CreateCalendar(context.Background(), cal); err != nil {
+		return err
+	}
+	if root.JSON {
+		return outputCalendarsJSON([]caldav.Calendar{*cal})
+	}
+	fmt.Printf("Created calendar: %s (%s)\n", cal.Name, cal.Path)
+	return nil
+}
+
+// CalCalendarsRenameCmd renames a calendar.
+type CalCalendarsRenameCmd struct {
+	Calendar string `arg:"" help:"Calendar path or name"`
+	Name     string `arg:"" help:"New display name"`
+}
+
+// Run executes the cal calendars rename command.
+func (c *CalCalendarsRenameCmd) Run(root *Root) error {
+	client, _, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	cal, err := resolveCalendar(ctx, client, c.Calendar)
+	if err != nil {
+		return err
+	}
+	if err := client.RenameCalendar(ctx, cal.Path, c.Name); err != nil {
+		return err
+	}
+	fmt.Printf("Renamed %s to %s\n", cal.Path, c.Name)
+	return nil
+}
+
+// CalCalendarsColorCmd sets a calendar's color.
+type CalCalendarsColorCmd struct {
+	Calendar string `arg:"" help:"Calendar path or name"`
+	Color    string `arg:"" help:"Color as #RRGGBB or #RRGGBBAA"`
+}
+
+// Run executes the cal calendars color command.
+func (c *CalCalendarsColorCmd) Run(root *Root) error {
+	color, err := normalizeColor(c.Color)
+	if err != nil {
+		return err
+	}
+	client, _, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	cal, err := resolveCalendar(ctx, client, c.Calendar)
+	if err != nil {
+		return err
+	}
+	if err := client.SetCalendarColor(ctx, cal.Path, color); err != nil {
+		return err
+	}
+	fmt.Printf("Set color of %s to %s\n", calendarName(cal), color)
+	return nil
+}
+
+// CalCalendarsDeleteCmd deletes a calendar.
+type CalCalendarsDeleteCmd struct {
+	Calendar string `arg:"" help:"Calendar path or name"`
+	// Note: Uses global --force flag for confirmation skip
+}
+
+// Run executes the cal calendars delete command.
+func (c *CalCalendarsDeleteCmd) Run(root *Root) error {
+	client, defaultPath, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	cal, err := resolveCalendar(ctx, client, c.Calendar)
+	if err != nil {
+		return err
+	}
+	if !root.Force {
+		if root.NoInput {
+			return fmt.Errorf("deleting a calendar needs confirmation; use --force")
+		}
+		question := fmt.Sprintf("Delete calendar %q (%s) and everything in it?", calendarName(cal), cal.Path)
+		if samePath(cal.Path, defaultPath) {
+			question = "This is your default calendar. " + question
+		}
+		if !confirm(os.Stdin, os.Stderr, question) {
+			return fmt.Errorf("not deleted")
+		}
+	}
+	if err := client.DeleteCalendar(ctx, cal.Path); err != nil {
+		return err
+	}
+	if store, err := cacheStore(root); err == nil {
+		_ = store.Remove(cacheCalendar, cal.Path)
+	}
+	fmt.Printf("Deleted calendar: %s\n", cal.Path)
+	return nil
+}
+
+// resolveCalendar finds a calendar by path or, ignoring case, display name.
+func resolveCalendar(ctx context.Context, client *caldav.Client, ref string) (*caldav.Calendar, error) {
+	calendars, err := client.FindCalendars(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendars: %w", err)
+	}
+	return matchCalendar(calendars, ref)
+}
+
+// matchCalendar returns the calendar whose path or display name is ref.
+func matchCalendar(calendars []caldav.Calendar, ref string) (*caldav.Calendar, error) {
+	var byName []*caldav.Calendar
+	for i := range calendars {
+		if samePath(calendars[i].Path, ref) {
+			return &calendars[i], nil
+		}
+		if strings.EqualFold(calendars[i].Name, ref) {
+			byName = append(byName, &calendars[i])
+		}
+	}
+	switch len(byName) {
+	case 0:
+		return nil, fmt.Errorf("calendar not found: %s", ref)
+	case 1:
+		return byName[0], nil
+	}
+	var paths []string
+	for _, cal := range byName {
+		paths = append(paths, cal.Path)
+	}
+	return nil, fmt.Errorf("several calendars are named %q, use the path: %s", ref, strings.Join(paths, ", "))
+}
+
+// samePath compares collection paths, ignoring a trailing slash.
+func samePath(a, b string) bool {
+	return a != "" && strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
+
+var colorPattern = regexp.MustCompile(`^#?([0-9A-Fa-f]{6}|[0-9A-Fa-f]{8})$`)
+
+// normalizeColor checks a color and returns it as #RRGGBB or #RRGGBBAA.
+func normalizeColor(color string) (string, error) {
+	m := colorPattern.FindStringSubmatch(strings.TrimSpace(color))
+	if m == nil {
+		return "", fmt.Errorf("invalid color %q: use #RRGGBB or #RRGGBBAA", color)
+	}
+	return "#" + strings.ToUpper(m[1]), nil
+}
+
+// calendarType describes what a calendar holds.
+func calendarType(cal *caldav.Calendar) string {
+	if len(cal.Components) == 0 {
+		return "any"
+	}
+	var kinds []string
+	for _, comp := range cal.Components {
+		switch comp {
+		case ical.CompEvent:
+			kinds = append(kinds, "events")
+		case ical.CompToDo:
+			kinds = append(kinds, "tasks")
+		default:
+			kinds = append(kinds, strings.ToLower(comp))
+		}
+	}
+	return strings.Join(kinds, ",")
+}
+
+// calendarName returns a calendar's display name, marked if read-only.
+func calendarName(cal *caldav.Calendar) string {
+	name := cal.Name
+	if name == "" {
+		name = cal.Path
+	}
+	if cal.ReadOnly {
+		name += " (read-only)"
+	}
+	return name
+}
+
+// confirm asks a yes/no question; anything but yes is no.
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// outputCalendarsJSON outputs calendars as JSON, one per line.
+func outputCalendarsJSON(calendars []caldav.Calendar) error {
+	for _, cal := range calendars {
+		data, err := json.Marshal(cal)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+func TestMatchCalendar(t *testing.T) {
+	calendars := []caldav.Calendar{
+		{Path: "/cal/work/", Name: "Work"},
+		{Path: "/cal/home/", Name: "Home"},
+		{Path: "/cal/home-2/", Name: "home"},
+	}
+	cal, err := matchCalendar(calendars, "/cal/work")
+	require.NoError(t, err)
+	assert.Equal(t, "Work", cal.Name)
+
+	cal, err = matchCalendar(calendars, "WORK")
+	require.NoError(t, err)
+	assert.Equal(t, "/cal/work/", cal.Path)
+
+	_, err = matchCalendar(calendars, "Home")
+	assert.ErrorContains(t, err, "/cal/home/, /cal/home-2/")
+	_, err = matchCalendar(calendars, "Gym")
+	assert.Error(t, err)
+}
+
+func TestNormalizeColor(t *testing.T) {
+	color, err := normalizeColor("3a87ad")
+	require.NoError(t, err)
+	assert.Equal(t, "#3A87AD", color)
+	color, err = normalizeColor("#3A87ADFF")
+	require.NoError(t, err)
+	assert.Equal(t, "#3A87ADFF", color)
+	_, err = normalizeColor("blue")
+	assert.Error(t, err)
+}
+
+func TestCalendarType(t *testing.T) {
+	assert.Equal(t, "any", calendarType(&caldav.Calendar{}))
+	assert.Equal(t, "events,tasks", calendarType(&caldav.Calendar{Components: []string{"VEVENT", "VTODO"}}))
+	assert.Equal(t, "Shared (read-only)", calendarName(&caldav.Calendar{Name: "Shared", ReadOnly: true}))
+}
+
+func TestConfirm(t *testing.T) {
+	assert.True(t, confirm(strings.NewReader("y\n"), io.Discard, "Delete?"))
+	assert.True(t, confirm(strings.NewReader("Yes\n"), io.Discard, "Delete?"))
+	assert.False(t, confirm(strings.NewReader("\n"), io.Discard, "Delete?"))
+	assert.False(t, confirm(strings.NewReader(""), io.Discard, "Delete?"))
+}
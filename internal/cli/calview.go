@@ -0,0 +1,528 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+// CalMonthCmd shows a month as a grid.
+type CalMonthCmd struct {
+	Month string `arg:"" optional:"" help:"Month: YYYY-MM or a date in it, e.g. 'next month' (default: this month)"`
+	AgendaFlags
+}
+
+// Run executes the cal month command.
+func (c *CalMonthCmd) Run(root *Root) error {
+	display, err := root.Cal.displayZone()
+	if err != nil {
+		return err
+	}
+	first, err := parseMonth(c.Month, display)
+	if err != nil {
+		return err
+	}
+	end := first.AddDate(0, 1, 0)
+
+	events, colors, err := agendaEvents(root, c.AgendaFlags, first, end)
+	if err != nil {
+		return err
+	}
+	events = inZone(events, display)
+
+	switch {
+	case root.JSON:
+		return outputEventsJSON(events)
+	case root.Plain:
+		return outputEventsPlain(events)
+	}
+	return newCalView(root, colors, display).month(os.Stdout, first, events)
+}
+
+// CalAgendaCmd lists events grouped by day.
+type CalAgendaCmd struct {
+	Calendar string `arg:"" optional:"" help:"Calendar path or name, or subscription name (default: primary calendar and subscriptions)"`
+	From     string `help:"Start date" default:"today"`
+	To       string `help:"End date" default:"+7d"`
+	AgendaFlags
+}
+
+// Run executes the cal agenda command.
+func (c *CalAgendaCmd) Run(root *Root) error {
+	display, err := root.Cal.displayZone()
+	if err != nil {
+		return err
+	}
+	start, err := parseDate(c.From, display)
+	if err != nil {
+		return fmt.Errorf("invalid --from date: %w", err)
+	}
+	end, err := parseDate(c.To, display)
+	if err != nil {
+		return fmt.Errorf("invalid --to date: %w", err)
+	}
+
+	events, colors, err := agendaEvents(root, c.AgendaFlags.with(c.Calendar), start, end)
+	if err != nil {
+		return err
+	}
+	events = inZone(events, display)
+
+	switch {
+	case root.JSON:
+		return outputEventsJSON(events)
+	case root.Plain:
+		return outputEventsPlain(events)
+	case len(events) == 0:
+		fmt.Println("No events found.")
+		return nil
+	}
+	return newCalView(root, colors, display).agenda(os.Stdout, start, end, events)
+}
+
+// parseMonth returns the first day of the month given as YYYY-MM or by a
+// date in it.
+func parseMonth(s string, loc *time.Location) (time.Time, error) {
+	var t time.Time
+	var err error
+	switch {
+	case s == "":
+		t = time.Now().In(loc)
+	default:
+		if t, err = time.ParseInLocation("2006-01", s, loc); err != nil {
+			if t, err = parseDate(s, loc); err != nil {
+				return time.Time{}, fmt.Errorf("invalid month: %w", err)
+			}
+		}
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc), nil
+}
+
+// calView renders events as a month grid, a week grid or an agenda.
+type calView struct {
+	width  int               // Terminal width
+	color  bool              // Use ANSI colors
+	colors map[string]string // Calendar colors by label
+	today  time.Time
+}
+
+func newCalView(root *Root, colors map[string]string, loc *time.Location) *calView {
+	return &calView{
+		width:  terminalWidth(),
+		color:  useColor(root),
+		colors: agendaColors(root, colors),
+		today:  time.Now().In(loc),
+	}
+}
+
+// Month grid lines per day for events
+const monthEventLines = 3
+
+// month writes the month starting at first as a grid of weeks, Monday
+// first, with the first events of each day.
+func (v *calView) month(w io.Writer, first time.Time, events []caldav.Event) error {
+	cellW := max((v.width-1)/7-1, 6)
+	rule := func(left, mid, right string) {
+		cells := make([]string, 7)
+		for i := range cells {
+			cells[i] = strings.Repeat("─", cellW)
+		}
+		fmt.Fprintln(w, left+strings.Join(cells, mid)+right)
+	}
+
+	title := first.Format("January 2006")
+	fmt.Fprintf(w, "%*s\n", (7*(cellW+1)+1+len(title))/2, title)
+	rule("┌", "┬", "┐")
+	row := make([]string, 7)
+	for i := range row {
+		row[i] = fit(time.Weekday((i + 1) % 7).String()[:3], cellW)
+	}
+	fmt.Fprintln(w, "│"+strings.Join(row, "│")+"│")
+
+	day := first.AddDate(0, 0, -((int(first.Weekday()) + 6) % 7))
+	for day.Before(first.AddDate(0, 1, 0)) {
+		rule("├", "┼", "┤")
+		week := make([]time.Time, 7)
+		lists := make([][]caldav.Event, 7)
+		for i := range week {
+			week[i] = day.AddDate(0, 0, i)
+			if week[i].Month() == first.Month() {
+				lists[i] = dayEvents(events, week[i])
+			}
+		}
+
+		for i, d := range week {
+			row[i] = strings.Repeat(" ", cellW)
+			if d.Month() != first.Month() {
+				continue
+			}
+			num := fmt.Sprintf("%2d", d.Day())
+			switch {
+			case !sameDay(d, v.today):
+				row[i] = fit(num, cellW)
+			case v.color:
+				row[i] = "\x1b[7m" + num + "\x1b[0m" + strings.Repeat(" ", cellW-len(num))
+			default:
+				row[i] = fit(num+"*", cellW)
+			}
+		}
+		fmt.Fprintln(w, "│"+strings.Join(row, "│")+"│")
+
+		for line := 0; line < monthEventLines; line++ {
+			for i, d := range week {
+				list := lists[i]
+				switch {
+				case line >= len(list):
+					row[i] = strings.Repeat(" ", cellW)
+				case line == monthEventLines-1 && len(list) > monthEventLines:
+					row[i] = fit(fmt.Sprintf("+%d more", len(list)-line), cellW)
+				default:
+					e := list[line]
+					text := e.Summary
+					if !e.AllDay && sameDay(e.Start, d) {
+						text = e.Start.Format("15:04") + " " + text
+					}
+					row[i] = v.paint(e.Source, "•") + fit(" "+text, cellW-1)
+				}
+			}
+			fmt.Fprintln(w, "│"+strings.Join(row, "│")+"│")
+		}
+		day = day.AddDate(0, 0, 7)
+	}
+	rule("└", "┴", "┘")
+	return nil
+}
+
+// weekGrid writes the days from start as columns of hourly rows, with
+// all-day events on top. Overlapping events are shown side by side.
+func (v *calView) weekGrid(w io.Writer, start time.Time, days int, events []caldav.Event) error {
+	const gutter = 5
+	colW := max((v.width-gutter)/days-1, 8)
+	start = dayStart(start)
+
+	type column struct {
+		day    time.Time
+		allDay []caldav.Event
+		lanes  [][]caldav.Event
+	}
+	columns := make([]column, days)
+	firstHour, lastHour := 8, 18
+	maxAllDay := 0
+	for i := range columns {
+		col := &columns[i]
+		col.day = start.AddDate(0, 0, i)
+		var timed []caldav.Event
+		for _, e := range dayEvents(events, col.day) {
+			if e.AllDay {
+				col.allDay = append(col.allDay, e)
+				continue
+			}
+			timed = append(timed, e)
+			s, end := clip(e, col.day)
+			firstHour = min(firstHour, s.Hour())
+			lastHour = max(lastHour, hoursInto(col.day, end))
+		}
+		col.lanes = lanes(timed)
+		maxAllDay = max(maxAllDay, len(col.allDay))
+	}
+
+	cells := make([]string, days)
+	for i, col := range columns {
+		header := col.day.Format("Mon 02")
+		switch {
+		case !sameDay(col.day, v.today):
+			cells[i] = fit(header, colW)
+		case v.color:
+			cells[i] = "\x1b[7m" + header + "\x1b[0m" + strings.Repeat(" ", colW-len(header))
+		default:
+			cells[i] = fit(header+"*", colW)
+		}
+	}
+	fmt.Fprintln(w, strings.Repeat(" ", gutter)+" "+strings.Join(cells, " "))
+	fmt.Fprintln(w, strings.Repeat("─", gutter+days*(colW+1)))
+
+	for line := 0; line < maxAllDay; line++ {
+		for i, col := range columns {
+			cells[i] = strings.Repeat(" ", colW)
+			if line < len(col.allDay) {
+				e := col.allDay[line]
+				cells[i] = v.paint(e.Source, "•") + fit(" "+e.Summary, colW-1)
+			}
+		}
+		label := ""
+		if line == 0 {
+			label = "all"
+		}
+		fmt.Fprintln(w, fit(label, gutter)+" "+strings.Join(cells, " "))
+	}
+
+	for hour := firstHour; hour < lastHour; hour++ {
+		for i, col := range columns {
+			from := col.day.Add(time.Duration(hour) * time.Hour)
+			cells[i] = v.hourCell(col.lanes, from, from.Add(time.Hour), hour == firstHour, colW)
+		}
+		fmt.Fprintln(w, fmt.Sprintf("%02d:00", hour)+" "+strings.Join(cells, " "))
+	}
+	return nil
+}
+
+// hourCell renders the lanes of a day for the hour [from, to). An event
+// is named in the hour it starts, or the first hour shown, and marked with
+// │ below. Lanes that do not fit are shown as a + at the end.
+func (v *calView) hourCell(dayLanes [][]caldav.Event, from, to time.Time, first bool, width int) string {
+	if len(dayLanes) == 0 {
+		return strings.Repeat(" ", width)
+	}
+	shown := min(len(dayLanes), max((width+1)/4, 1))
+	laneW := (width - (shown - 1)) / shown
+	hidden := false
+
+	texts := make([]string, shown)
+	sources := make([]string, shown)
+	for i, lane := range dayLanes {
+		busy := false
+		for _, e := range lane {
+			s, end := clip(e, dayStart(from))
+			if !s.Before(to) || !end.After(from) {
+				continue
+			}
+			if busy || i >= shown {
+				hidden = true // Another event in this lane, or a lane not shown
+				continue
+			}
+			busy, sources[i] = true, e.Source
+			switch {
+			case !first && s.Before(from):
+				texts[i] = "│"
+			case laneW < 12:
+				texts[i] = e.Summary // No room for the time
+			default:
+				texts[i] = s.Format("15:04") + " " + e.Summary
+			}
+		}
+	}
+
+	pad := width - (shown*laneW + shown - 1)
+	parts := make([]string, shown)
+	for i := range parts {
+		w := laneW
+		if hidden && pad == 0 && i == shown-1 {
+			w-- // Room for the +
+		}
+		parts[i] = v.paint(sources[i], fit(texts[i], w))
+	}
+	cell := strings.Join(parts, " ")
+	switch {
+	case hidden && pad == 0:
+		cell += "+"
+	case hidden:
+		cell += strings.Repeat(" ", pad-1) + "+"
+	default:
+		cell += strings.Repeat(" ", pad)
+	}
+	return cell
+}
+
+// agenda writes the events in [start, end) grouped by day, all-day events
+// first. Days without events are left out.
+func (v *calView) agenda(w io.Writer, start, end time.Time, events []caldav.Event) error {
+	firstDay := true
+	for day := dayStart(start); day.Before(end); day = day.AddDate(0, 0, 1) {
+		list := dayEvents(events, day)
+		if len(list) == 0 {
+			continue
+		}
+		if !firstDay {
+			fmt.Fprintln(w)
+		}
+		firstDay = false
+
+		header := day.Format("Monday, 2 January 2006")
+		if sameDay(day, v.today) {
+			header += " (today)"
+		}
+		if v.color {
+			header = "\x1b[1m" + header + "\x1b[0m"
+		}
+		fmt.Fprintln(w, header)
+
+		for _, e := range list {
+			when := "all-day"
+			if !e.AllDay {
+				s, end := clip(e, day)
+				from, to := s.Format("15:04"), end.Format("15:04")
+				if s.After(e.Start) {
+					from = "…"
+				}
+				if end.Before(eventEnd(e)) {
+					to = "…"
+				}
+				when = from + "-" + to
+			}
+			text := e.Summary
+			if e.Location != "" {
+				text += " (" + e.Location + ")"
+			}
+			var label string
+			if e.Source != "" {
+				label = "[" + e.Source + "] "
+			}
+			line := fit(label+text, max(v.width-16, 20))
+			if label != "" && strings.HasPrefix(line, label) {
+				line = v.paint(e.Source, label) + line[len(label):]
+			}
+			fmt.Fprintf(w, "  %-13s %s\n", when, strings.TrimRight(line, " "))
+		}
+	}
+	return nil
+}
+
+// paint colors s in the color of a calendar label.
+func (v *calView) paint(label, s string) string {
+	if color := v.colors[label]; v.color && color != "" {
+		return colorize(color, s)
+	}
+	return s
+}
+
+// outputEventsPlain outputs events as tab-separated lines for scripts:
+// date, start time, end time, calendar and summary. Times are empty for
+// all-day events.
+func outputEventsPlain(events []caldav.Event) error {
+	clean := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+	for _, e := range events {
+		var from, to string
+		if !e.AllDay {
+			from, to = e.Start.Format("15:04"), eventEnd(e).Format("15:04")
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", e.Start.Format("2006-01-02"), from, to,
+			clean.Replace(e.Source), clean.Replace(e.Summary))
+	}
+	return nil
+}
+
+// dayEvents returns the events on day, all-day events first.
+func dayEvents(events []caldav.Event, day time.Time) []caldav.Event {
+	var list []caldav.Event
+	for _, e := range events {
+		if onDay(e, day) {
+			list = append(list, e)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].AllDay != list[j].AllDay {
+			return list[i].AllDay
+		}
+		return list[i].Start.Before(list[j].Start)
+	})
+	return list
+}
+
+// onDay reports whether an event takes place on day. All-day events are
+// compared by date.
+func onDay(e caldav.Event, day time.Time) bool {
+	if e.AllDay {
+		d := civil(day)
+		return !civil(e.Start).After(d) && civil(eventEnd(e)).After(d)
+	}
+	start := dayStart(day)
+	next := start.AddDate(0, 0, 1)
+	if e.Start.Equal(eventEnd(e)) {
+		return !e.Start.Before(start) && e.Start.Before(next)
+	}
+	return e.Start.Before(next) && eventEnd(e).After(start)
+}
+
+// eventEnd returns the end of an event. Events without a valid end last a
+// day if all-day, and no time otherwise.
+func eventEnd(e caldav.Event) time.Time {
+	if !e.End.After(e.Start) {
+		if e.AllDay {
+			return e.Start.AddDate(0, 0, 1)
+		}
+		return e.Start
+	}
+	return e.End
+}
+
+// clip returns the part of a timed event on day.
+func clip(e caldav.Event, day time.Time) (time.Time, time.Time) {
+	start, end := dayStart(day), eventEnd(e)
+	next := start.AddDate(0, 0, 1)
+	s := e.Start
+	if s.Before(start) {
+		s = start
+	}
+	if end.After(next) {
+		end = next
+	}
+	return s, end
+}
+
+// lanes assigns timed events to lanes so that the events in a lane do not
+// overlap. Events must be sorted by start.
+func lanes(events []caldav.Event) [][]caldav.Event {
+	var out [][]caldav.Event
+	var ends []time.Time
+	for _, e := range events {
+		end := eventEnd(e)
+		if !end.After(e.Start) {
+			end = e.Start.Add(time.Minute)
+		}
+		placed := false
+		for i := range out {
+			if !ends[i].After(e.Start) {
+				out[i] = append(out[i], e)
+				ends[i] = end
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			out = append(out, []caldav.Event{e})
+			ends = append(ends, end)
+		}
+	}
+	return out
+}
+
+// hoursInto returns the hour t ends in on day, rounded up: 24 for the
+// next midnight.
+func hoursInto(day, t time.Time) int {
+	d := t.Sub(dayStart(day))
+	return int((d + time.Hour - 1) / time.Hour)
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// civil returns t's date as midnight UTC, for comparing dates across
+// time zones.
+func civil(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func sameDay(a, b time.Time) bool {
+	return civil(a).Equal(civil(b))
+}
+
+// fit truncates s to width characters, marking the cut with …, and pads
+// it with spaces.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		r := []rune(s)
+		return string(r[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
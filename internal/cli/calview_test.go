@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+func viewEventsFixture() []caldav.Event {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+	return []caldav.Event{
+		{UID: "standup", Summary: "Standup", Start: at(19, 9, 0), End: at(19, 10, 30)},
+		{UID: "review", Summary: "Review", Start: at(19, 10, 0), End: at(19, 11, 0), Location: "Room 1"},
+		{UID: "trip", Summary: "Trip", Start: at(20, 0, 0), End: at(22, 0, 0), AllDay: true},
+		{UID: "deploy", Summary: "Deploy", Start: at(21, 22, 0), End: at(22, 1, 0)},
+	}
+}
+
+func TestCalViewAgenda(t *testing.T) {
+	v := &calView{width: 80, today: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	var out bytes.Buffer
+	start := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, v.agenda(&out, start, start.AddDate(0, 0, 7), viewEventsFixture()))
+	assert.Equal(t, `Monday, 19 October 2026 (today)
+  09:00-10:30   Standup
+  10:00-11:00   Review (Room 1)
+
+Tuesday, 20 October 2026
+  all-day       Trip
+
+Wednesday, 21 October 2026
+  all-day       Trip
+  22:00-…       Deploy
+
+Thursday, 22 October 2026
+  …-01:00       Deploy
+`, out.String())
+}
+
+func TestCalViewMonth(t *testing.T) {
+	v := &calView{width: 71, today: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	var out bytes.Buffer
+	require.NoError(t, v.month(&out, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), viewEventsFixture()))
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+
+	assert.Equal(t, "October 2026", strings.TrimSpace(lines[0]))
+	assert.Equal(t, "│Mon      │Tue      │Wed      │Thu      │Fri      │Sat      │Sun      │", lines[2])
+	// October 1st is a Thursday
+	assert.Equal(t, "│         │         │         │ 1       │ 2       │ 3       │ 4       │", lines[4])
+	// The week of the 19th
+	assert.Equal(t, "│19*      │20       │21       │22       │23       │24       │25       │", lines[19])
+	assert.Equal(t, "│• 09:00 …│• Trip   │• Trip   │• Deploy │         │         │         │", lines[20])
+	assert.Equal(t, "│• 10:00 …│         │• 22:00 …│         │         │         │         │", lines[21])
+	for _, line := range lines[1:] {
+		assert.Equal(t, 71, len([]rune(line)), line)
+	}
+}
+
+func TestCalViewWeekGrid(t *testing.T) {
+	v := &calView{width: 110, today: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	var out bytes.Buffer
+	start := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	events := viewEventsFixture()[:2]
+	require.NoError(t, v.weekGrid(&out, start, 7, events))
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+
+	assert.True(t, strings.HasPrefix(lines[0], "      Mon 19*"), lines[0])
+	// 08:00 to 18:00
+	require.Len(t, lines, 2+10)
+	assert.True(t, strings.HasPrefix(lines[2], "08:00 "))
+	// The overlapping events are side by side
+	assert.True(t, strings.HasPrefix(lines[3], "09:00 Stand…        "), lines[3])
+	assert.True(t, strings.HasPrefix(lines[4], "10:00 │      Review "), lines[4])
+	assert.True(t, strings.HasPrefix(lines[5], "11:00  "), lines[5])
+}
+
+func TestLanes(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2026, 10, 19, hour, 0, 0, 0, time.UTC) }
+	events := []caldav.Event{
+		{UID: "a", Start: at(9), End: at(11)},
+		{UID: "b", Start: at(10), End: at(12)},
+		{UID: "c", Start: at(11), End: at(12)},
+		{UID: "d", Start: at(11), End: at(13)},
+	}
+	var got [][]string
+	for _, lane := range lanes(events) {
+		var uids []string
+		for _, e := range lane {
+			uids = append(uids, e.UID)
+		}
+		got = append(got, uids)
+	}
+	assert.Equal(t, [][]string{{"a", "c"}, {"b"}, {"d"}}, got)
+}
+
+func TestParseMonth(t *testing.T) {
+	first, err := parseMonth("2026-02", time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), first)
+
+	first, err = parseMonth("2026-03-17", time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), first)
+
+	_, err = parseMonth("someday", time.UTC)
+	assert.Error(t, err)
+}
+
+func TestFit(t *testing.T) {
+	assert.Equal(t, "abc  ", fit("abc", 5))
+	assert.Equal(t, "abcd…", fit("abcdefgh", 5))
+	assert.Equal(t, "Grüß…", fit("Grüße aus", 5))
+	assert.Equal(t, "", fit("abc", 0))
+}
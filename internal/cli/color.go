@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// useColor reports whether output should be colored, following --color.
+// auto colors human output on a terminal unless NO_COLOR is set.
+func useColor(root *Root) bool {
+	switch root.Color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if root.JSON || root.Plain || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalWidth returns the width to fit output to: $COLUMNS, else the
+// width of the terminal, else 80.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	if n := terminalColumns(os.Stdout); n > 0 {
+		return n
+	}
+	return 80
+}
+
+// agendaColors returns the calendar colors to show, or nil if output is
+// not colored.
+func agendaColors(root *Root, colors map[string]string) map[string]string {
+	if !useColor(root) {
+		return nil
+	}
+	return colors
+}
+
+// colorize wraps s in the ANSI escape for a #RRGGBB or #RRGGBBAA color.
+// Other colors leave s unchanged.
+func colorize(color, s string) string {
+	color, err := normalizeColor(color)
+	if err != nil {
+		return s
+	}
+	rgb, _ := strconv.ParseUint(color[1:7], 16, 32)
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm%s\x1b[0m", rgb>>16, rgb>>8&0xFF, rgb&0xFF, s)
+}
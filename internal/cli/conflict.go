@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/visionik/sogcli/internal/dav"
+)
+
+// writeContext returns the context for a DAV write. With force, writes
+// overwrite whatever is on the server instead of failing on a conflict.
+func writeContext(force bool) context.Context {
+	ctx := context.Background()
+	if force {
+		ctx = dav.Force(ctx)
+	}
+	return ctx
+}
+
+// conflictHint adds the ways out of a write conflict to err.
+func conflictHint(err error, canMerge bool) error {
+	if !dav.IsConflict(err) {
+		return err
+	}
+	hint := "run the command again to apply it to the current version, or use --force to overwrite"
+	if canMerge {
+		hint = "run the command again, use --merge to merge both versions, or --force to overwrite"
+	}
+	return fmt.Errorf("%w; %s", err, hint)
+}
+
+// promptConflict returns a resolver for dav.Merge that asks which version
+// of each conflicting field to keep.
+func promptConflict(in io.Reader, out io.Writer) func(dav.Conflict) bool {
+	reader := bufio.NewReader(in)
+	return func(c dav.Conflict) bool {
+		fmt.Fprintf(out, "Conflict in %s:\n", c.Field)
+		fmt.Fprintf(out, "  [m]ine:   %s\n", formatConflictValue(c.Mine))
+		fmt.Fprintf(out, "  [t]heirs: %s\n", formatConflictValue(c.Theirs))
+		for {
+			fmt.Fprint(out, "Keep which? [m/t] ")
+			line, err := reader.ReadString('\n')
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "m", "mine":
+				return true
+			case "t", "theirs":
+				return false
+			}
+			if errors.Is(err, io.EOF) {
+				// No answer: the server's version wins
+				fmt.Fprintln(out)
+				return false
+			}
+		}
+	}
+}
+
+func formatConflictValue(v any) string {
+	switch v := v.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return "(none)"
+		}
+		return v.Local().Format("2006-01-02 15:04")
+	case []time.Time:
+		var parts []string
+		for _, t := range v {
+			parts = append(parts, formatConflictValue(t))
+		}
+		return formatConflictValue(parts)
+	case []string:
+		if len(v) == 0 {
+			return "(none)"
+		}
+		return strings.Join(v, ", ")
+	case string:
+		if v == "" {
+			return "(none)"
+		}
+		return v
+	}
+	return fmt.Sprint(v)
+}
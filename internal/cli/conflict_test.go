@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/visionik/sogcli/internal/dav"
+)
+
+func TestPromptConflict(t *testing.T) {
+	var out bytes.Buffer
+	resolve := promptConflict(strings.NewReader("x\nm\nt\n"), &out)
+	conflict := dav.Conflict{Field: "summary", Mine: "Lunch", Theirs: ""}
+
+	assert.True(t, resolve(conflict))
+	assert.Contains(t, out.String(), "Conflict in summary")
+	assert.Contains(t, out.String(), "[t]heirs: (none)")
+	assert.False(t, resolve(conflict))
+
+	// End of input keeps the server's version
+	assert.False(t, resolve(conflict))
+}
+
+func TestConflictHint(t *testing.T) {
+	err := conflictHint(&dav.ConflictError{Path: "/cal/a.ics", ETag: "1"}, true)
+	assert.True(t, dav.IsConflict(err))
+	assert.Contains(t, err.Error(), "--merge")
+
+	plain := errors.New("boom")
+	assert.Equal(t, plain, conflictHint(plain, true))
+}
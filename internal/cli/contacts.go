@@ -3,11 +3,13 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/visionik/sogcli/internal/carddav"
 	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/dav"
 )
 
 // ContactsCmd handles contact operations.
@@ -40,7 +42,7 @@ func (c *ContactsListCmd) Run(root *Root) error {
 	}
 
 	ctx := context.Background()
-	contacts, err := client.ListContacts(ctx, bookPath)
+	contacts, err := listContacts(ctx, root, client, bookPath)
 	if err != nil {
 		return fmt.Errorf("failed to list contacts: %w", err)
 	}
@@ -112,10 +114,11 @@ func (c *ContactsSearchCmd) Run(root *Root) error {
 	}
 
 	ctx := context.Background()
-	contacts, err := client.SearchContacts(ctx, bookPath, c.Query)
+	all, err := listContacts(ctx, root, client, bookPath)
 	if err != nil {
 		return fmt.Errorf("failed to search contacts: %w", err)
 	}
+	contacts := searchContacts(all, c.Query)
 
 	if len(contacts) == 0 {
 		fmt.Println("No contacts found.")
@@ -129,6 +132,19 @@ func (c *ContactsSearchCmd) Run(root *Root) error {
 	return outputContactsTable(contacts)
 }
 
+// searchContacts returns the contacts whose full name contains query,
+// ignoring case, as the server-side search does.
+func searchContacts(contacts []carddav.Contact, query string) []carddav.Contact {
+	query = strings.ToLower(query)
+	var matches []carddav.Contact
+	for _, contact := range contacts {
+		if strings.Contains(strings.ToLower(contact.FullName), query) {
+			matches = append(matches, contact)
+		}
+	}
+	return matches
+}
+
 // ContactsCreateCmd creates a contact.
 type ContactsCreateCmd struct {
 	Name        string   `arg:"" help:"Full name"`
@@ -190,6 +206,7 @@ type ContactsUpdateCmd struct {
 	Title       string   `help:"Job title"`
 	Note        string   `help:"Note"`
 	AddressBook string   `help:"Address book path (default: primary)"`
+	Merge       bool     `help:"If the contact changed on the server, merge both versions and ask about conflicting fields"`
 }
 
 // Run executes the contacts update command.
@@ -204,11 +221,16 @@ func (c *ContactsUpdateCmd) Run(root *Root) error {
 		bookPath = c.AddressBook
 	}
 
-	ctx := context.Background()
+	if root.Force && c.Merge {
+		return fmt.Errorf("--force and --merge cannot be combined")
+	}
+
+	ctx := writeContext(root.Force)
 	contact, err := client.GetContact(ctx, bookPath, c.UID)
 	if err != nil {
 		return fmt.Errorf("failed to get contact: %w", err)
 	}
+	base := *contact
 
 	// Apply updates
 	if c.Name != "" {
@@ -231,8 +253,18 @@ func (c *ContactsUpdateCmd) Run(root *Root) error {
 		contact.Note = c.Note
 	}
 
-	if err := client.UpdateContact(ctx, bookPath, contact); err != nil {
-		return fmt.Errorf("failed to update contact: %w", err)
+	err = client.UpdateContact(ctx, bookPath, contact)
+	if dav.IsConflict(err) && c.Merge {
+		var theirs *carddav.Contact
+		theirs, err = client.GetContact(ctx, bookPath, c.UID)
+		if err != nil {
+			return fmt.Errorf("failed to get contact: %w", err)
+		}
+		merged := dav.Merge(base, *contact, *theirs, promptConflict(os.Stdin, os.Stdout))
+		err = client.UpdateContact(ctx, bookPath, &merged)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update contact: %w", conflictHint(err, true))
 	}
 
 	fmt.Printf("Updated contact: %s\n", c.UID)
@@ -257,9 +289,9 @@ func (c *ContactsDeleteCmd) Run(root *Root) error {
 		bookPath = c.AddressBook
 	}
 
-	ctx := context.Background()
+	ctx := writeContext(root.Force)
 	if err := client.DeleteContact(ctx, bookPath, c.UID); err != nil {
-		return fmt.Errorf("failed to delete contact: %w", err)
+		return fmt.Errorf("failed to delete contact: %w", conflictHint(err, false))
 	}
 
 	fmt.Printf("Deleted contact: %s\n", c.UID)
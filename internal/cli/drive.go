@@ -7,6 +7,7 @@ import (
 	"path"
 
 	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/dav"
 	"github.com/visionik/sogcli/internal/webdav"
 )
 
@@ -142,8 +143,10 @@ func (c *DriveDownloadCmd) Run(root *Root) error {
 
 // DriveUploadCmd uploads a file.
 type DriveUploadCmd struct {
-	Local  string `arg:"" help:"Local file path"`
-	Remote string `arg:"" optional:"" help:"Remote path (default: / with same name)"`
+	Local       string `arg:"" help:"Local file path"`
+	Remote      string `arg:"" optional:"" help:"Remote path (default: / with same name)"`
+	ETag        string `help:"Only overwrite the remote file if it still has this ETag (see 'drive get')"`
+	NoOverwrite bool   `help:"Fail if the remote file already exists"`
 }
 
 // Run executes the drive upload command.
@@ -159,7 +162,10 @@ func (c *DriveUploadCmd) Run(root *Root) error {
 		remote = "/" + path.Base(c.Local)
 	}
 
-	ctx := context.Background()
+	ctx := dav.IfMatch(context.Background(), c.ETag)
+	if c.NoOverwrite {
+		ctx = dav.IfNoneMatch(ctx)
+	}
 	if err := client.Upload(ctx, c.Local, remote); err != nil {
 		return fmt.Errorf("failed to upload: %w", err)
 	}
@@ -193,6 +199,7 @@ func (c *DriveMkdirCmd) Run(root *Root) error {
 // DriveDeleteCmd deletes a file or directory.
 type DriveDeleteCmd struct {
 	Path string `arg:"" help:"Path to delete"`
+	ETag string `help:"Only delete if the file still has this ETag (see 'drive get')"`
 }
 
 // Run executes the drive delete command.
@@ -203,7 +210,7 @@ func (c *DriveDeleteCmd) Run(root *Root) error {
 	}
 	defer client.Close()
 
-	ctx := context.Background()
+	ctx := dav.IfMatch(context.Background(), c.ETag)
 	if err := client.Delete(ctx, c.Path); err != nil {
 		return fmt.Errorf("failed to delete: %w", err)
 	}
@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/dateexpr"
+)
+
+// CalFreeCmd finds times when the account and attendees are all free.
+type CalFreeCmd struct {
+	Attendees    []string `arg:"" optional:"" help:"Attendee email addresses (looked up via the server's scheduling outbox)"`
+	Within       string   `help:"When to look: 'next week', 'tomorrow', 'friday to monday', 7d" default:"7d"`
+	Duration     string   `help:"Meeting length (e.g., 30m, 45m, 1h30m)" default:"30m"`
+	WorkingHours string   `name:"working-hours" help:"Working hours in --tz, e.g. 9-17 or 8:30-16:30; 'none' for the whole day" default:"9-17"`
+	Weekends     bool     `help:"Include Saturdays and Sundays"`
+	Calendars    []string `name:"calendar" help:"Calendar paths whose events count as busy (default: primary); repeatable"`
+	Max          int      `help:"Maximum slots to propose" default:"10"`
+}
+
+// freeSlot is a proposed meeting time.
+type freeSlot struct {
+	Start     string   `json:"start"`
+	End       string   `json:"end"`
+	Duration  string   `json:"duration"`
+	FreeUntil string   `json:"free_until"`
+	Attendees []string `json:"attendees,omitempty"`
+}
+
+// Run executes the cal free command.
+func (c *CalFreeCmd) Run(root *Root) error {
+	d, err := dateexpr.ParseDuration(c.Duration)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid --duration: %s", c.Duration)
+	}
+	loc, err := root.Cal.inputZone()
+	if err != nil {
+		return err
+	}
+	display, err := root.Cal.displayZone()
+	if err != nil {
+		return err
+	}
+	hours, err := parseWorkingHours(c.WorkingHours)
+	if err != nil {
+		return err
+	}
+	hours.Weekends = c.Weekends
+	hours.Location = loc
+
+	now := time.Now().In(loc)
+	start, end, err := dateexpr.Range(c.Within, now)
+	if err != nil {
+		return fmt.Errorf("invalid --within: %w", err)
+	}
+	if start.Before(now) {
+		start = now
+	}
+	if !end.After(start) {
+		return fmt.Errorf("--within %q is in the past", c.Within)
+	}
+
+	client, calPath, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	calendars := c.Calendars
+	if len(calendars) == 0 {
+		calendars = []string{calPath}
+	}
+
+	ctx := context.Background()
+	var busy []caldav.Busy
+	for _, cal := range calendars {
+		b, err := client.FreeBusy(ctx, cal, start, end)
+		if err != nil {
+			return fmt.Errorf("failed to get free/busy for %s: %w", cal, err)
+		}
+		busy = append(busy, b...)
+	}
+
+	if len(c.Attendees) > 0 {
+		responses, err := client.AttendeeFreeBusy(ctx, c.Attendees, start, end)
+		switch {
+		case errors.Is(err, caldav.ErrNoScheduling):
+			fmt.Fprintln(os.Stderr, "Warning: the server cannot look up attendees' free/busy; only your calendar was checked")
+		case err != nil:
+			return err
+		}
+		for _, r := range responses {
+			if !r.OK() {
+				fmt.Fprintf(os.Stderr, "Warning: no free/busy for %s (%s)\n", r.Attendee, r.Status)
+				continue
+			}
+			busy = append(busy, r.Busy...)
+		}
+	}
+
+	slots := proposeSlots(caldav.FreeSlots(busy, start, end, d, hours), d, display)
+	if c.Max > 0 && len(slots) > c.Max {
+		slots = slots[:c.Max]
+	}
+	if len(slots) == 0 {
+		fmt.Println("No free slots found.")
+		return nil
+	}
+
+	if root.JSON {
+		for _, s := range slots {
+			data, err := json.Marshal(freeSlot{
+				Start:     s.Start.Format(time.RFC3339),
+				End:       s.Start.Add(d).Format(time.RFC3339),
+				Duration:  c.Duration,
+				FreeUntil: s.End.Format(time.RFC3339),
+				Attendees: c.Attendees,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		}
+		return nil
+	}
+
+	fmt.Printf("%-16s %-12s %s\n", "DATE", "TIME", "FREE UNTIL")
+	for _, s := range slots {
+		fmt.Printf("%-16s %-12s %s\n", s.Start.Format("2006-01-02 Mon"),
+			s.Start.Format("15:04")+"-"+s.Start.Add(d).Format("15:04"), s.End.Format("15:04"))
+	}
+	args := append([]string{`"<title>"`}, c.Attendees...)
+	fmt.Printf("\nTo invite: sog invite send %s --start %s --duration %s\n",
+		strings.Join(args, " "), slots[0].Start.Format(time.RFC3339), c.Duration)
+	return nil
+}
+
+// proposeSlots moves the start of each free period to the next quarter
+// hour in loc, dropping periods too short for d after that.
+func proposeSlots(free []caldav.Slot, d time.Duration, loc *time.Location) []caldav.Slot {
+	var slots []caldav.Slot
+	for _, s := range free {
+		start := s.Start.In(loc)
+		if rem := (start.Minute()%15)*int(time.Minute) + start.Second()*int(time.Second) + start.Nanosecond(); rem > 0 {
+			start = start.Add(15*time.Minute - time.Duration(rem))
+		}
+		if s.End.Sub(start) >= d {
+			slots = append(slots, caldav.Slot{Start: start, End: s.End.In(loc)})
+		}
+	}
+	return slots
+}
+
+// parseWorkingHours parses "9-17", "8:30-16:30" or "none" (the whole day).
+func parseWorkingHours(s string) (caldav.WorkingHours, error) {
+	if strings.EqualFold(s, "none") || s == "" {
+		return caldav.WorkingHours{}, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return caldav.WorkingHours{}, fmt.Errorf("invalid --working-hours: %s (expected e.g. 9-17)", s)
+	}
+	start, err := timeOfDay(from)
+	if err != nil {
+		return caldav.WorkingHours{}, fmt.Errorf("invalid --working-hours: %w", err)
+	}
+	end, err := timeOfDay(to)
+	if err != nil {
+		return caldav.WorkingHours{}, fmt.Errorf("invalid --working-hours: %w", err)
+	}
+	if end <= start {
+		return caldav.WorkingHours{}, fmt.Errorf("invalid --working-hours: %s ends before it starts", s)
+	}
+	if end == 24*time.Hour {
+		end = 0
+	}
+	return caldav.WorkingHours{Start: start, End: end}, nil
+}
+
+// timeOfDay parses "9", "17:30" or "24" as an offset from midnight.
+func timeOfDay(s string) (time.Duration, error) {
+	hour, minute, _ := strings.Cut(strings.TrimSpace(s), ":")
+	h, err := strconv.Atoi(hour)
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid hour %q", s)
+	}
+	m := 0
+	if minute != "" {
+		m, err = strconv.Atoi(minute)
+		if err != nil || m < 0 || m > 59 || (h == 24 && m > 0) {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
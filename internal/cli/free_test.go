@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+func TestParseWorkingHours(t *testing.T) {
+	hours, err := parseWorkingHours("9-17")
+	require.NoError(t, err)
+	assert.Equal(t, 9*time.Hour, hours.Start)
+	assert.Equal(t, 17*time.Hour, hours.End)
+
+	hours, err = parseWorkingHours("8:30-16:45")
+	require.NoError(t, err)
+	assert.Equal(t, 8*time.Hour+30*time.Minute, hours.Start)
+	assert.Equal(t, 16*time.Hour+45*time.Minute, hours.End)
+
+	for _, s := range []string{"none", "0-24"} {
+		hours, err = parseWorkingHours(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, caldav.WorkingHours{}, hours, s)
+	}
+
+	for _, s := range []string{"9", "17-9", "9-25", "9:60-17", "nine-five"} {
+		_, err = parseWorkingHours(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestProposeSlots(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 3, 2, hour, minute, 0, 0, time.UTC)
+	}
+	free := []caldav.Slot{
+		{Start: at(9, 0), End: at(10, 0)},
+		{Start: at(10, 50), End: at(11, 45)}, // Starts at 11:00, too short for 1h
+		{Start: at(13, 7), End: at(17, 0)},
+	}
+	slots := proposeSlots(free, time.Hour, time.UTC)
+	require.Len(t, slots, 2)
+	assert.Equal(t, at(9, 0), slots[0].Start)
+	assert.Equal(t, at(13, 15), slots[1].Start)
+	assert.Equal(t, at(17, 0), slots[1].End)
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+// CalExportCmd writes a calendar as an .ics file.
+type CalExportCmd struct {
+	Calendar string `help:"Calendar path (default: primary)"`
+	From     string `help:"Only events from this date (YYYY-MM-DD or relative)"`
+	To       string `help:"Only events before this date (YYYY-MM-DD or relative)"`
+}
+
+// Run executes the cal export command.
+func (c *CalExportCmd) Run(root *Root) error {
+	display, err := root.Cal.displayZone()
+	if err != nil {
+		return err
+	}
+	var start, end time.Time
+	if c.From != "" {
+		if start, err = parseDate(c.From, display); err != nil {
+			return fmt.Errorf("invalid --from date: %w", err)
+		}
+	}
+	if c.To != "" {
+		if end, err = parseDate(c.To, display); err != nil {
+			return fmt.Errorf("invalid --to date: %w", err)
+		}
+	}
+	return exportICS(root, c.Calendar, ical.CompEvent, start, end)
+}
+
+// CalImportCmd adds the events in an .ics file to a calendar.
+type CalImportCmd struct {
+	File     string `arg:"" help:".ics file or - for stdin"`
+	Calendar string `help:"Calendar path (default: primary)"`
+	Merge    bool   `help:"Replace events already in the calendar when the file's copy is newer (SEQUENCE, then LAST-MODIFIED)"`
+}
+
+// Run executes the cal import command.
+func (c *CalImportCmd) Run(root *Root) error {
+	return importICS(root, c.Calendar, ical.CompEvent, c.File, c.Merge)
+}
+
+// TasksExportCmd writes a task list as an .ics file.
+type TasksExportCmd struct {
+	List string `help:"Task list path (default: primary)"`
+}
+
+// Run executes the tasks export command.
+func (c *TasksExportCmd) Run(root *Root) error {
+	return exportICS(root, c.List, ical.CompToDo, time.Time{}, time.Time{})
+}
+
+// TasksImportCmd adds the tasks in an .ics file to a task list.
+type TasksImportCmd struct {
+	File  string `arg:"" help:".ics file or - for stdin"`
+	List  string `help:"Task list path (default: primary)"`
+	Merge bool   `help:"Replace tasks already in the list when the file's copy is newer (SEQUENCE, then LAST-MODIFIED)"`
+}
+
+// Run executes the tasks import command.
+func (c *TasksImportCmd) Run(root *Root) error {
+	return importICS(root, c.List, ical.CompToDo, c.File, c.Merge)
+}
+
+// exportICS writes the objects of a calendar holding components of kind to
+// stdout.
+func exportICS(root *Root, calendar, kind string, start, end time.Time) error {
+	client, calPath, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if calendar != "" {
+		calPath = calendar
+	}
+
+	cal, err := client.Export(context.Background(), calPath, kind, start, end)
+	if err != nil {
+		return err
+	}
+	return caldav.WriteICalendar(os.Stdout, cal)
+}
+
+// importICS stores the components of kind in an .ics file in a calendar.
+// Items already in it are kept, replaced if newer with merge, or replaced
+// with --force.
+func importICS(root *Root, calendar, kind, file string, merge bool) error {
+	if root.Force && merge {
+		return fmt.Errorf("--force and --merge cannot be combined")
+	}
+	var in io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	cal, err := caldav.ReadICalendar(in)
+	if err != nil {
+		return err
+	}
+
+	client, calPath, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if calendar != "" {
+		calPath = calendar
+	}
+
+	mode := caldav.ImportSkip
+	switch {
+	case root.Force:
+		mode = caldav.ImportReplace
+	case merge:
+		mode = caldav.ImportMerge
+	}
+	results, err := client.Import(writeContext(root.Force), calPath, kind, cal, mode)
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Result]++
+		if root.JSON {
+			data, _ := json.Marshal(r)
+			fmt.Println(string(data))
+			continue
+		}
+		name := r.Summary
+		if name == "" {
+			name = r.UID
+		}
+		line := fmt.Sprintf("%-8s %s", r.Result, name)
+		if r.Reason != "" {
+			line += " (" + r.Reason + ")"
+		}
+		fmt.Println(line)
+	}
+	if !root.JSON {
+		fmt.Printf("Imported: %d created, %d updated, %d skipped", counts[caldav.ImportCreated],
+			counts[caldav.ImportUpdated], counts[caldav.ImportSkipped])
+		if counts[caldav.ImportFailed] > 0 {
+			fmt.Printf(", %d failed", counts[caldav.ImportFailed])
+		}
+		fmt.Println()
+	}
+	if counts[caldav.ImportFailed] > 0 {
+		return fmt.Errorf("%d item(s) could not be imported", counts[caldav.ImportFailed])
+	}
+	return nil
+}
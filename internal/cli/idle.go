@@ -13,8 +13,9 @@ import (
 
 // IdleCmd watches for new mail using IMAP IDLE.
 type IdleCmd struct {
-	Folder string `help:"Folder to watch" default:"INBOX"`
-	Exec   string `help:"Command to execute on new mail (receives subject as arg)"`
+	Folder  string `help:"Folder to watch" default:"INBOX"`
+	Exec    string `help:"Command to execute on new mail (receives subject as arg)"`
+	Invites bool   `help:"Process meeting invitations in new mail: apply replies and cancellations to the calendar, announce invitations"`
 }
 
 // Run executes the idle command.
@@ -56,6 +57,15 @@ func (c *IdleCmd) Run(root *Root) error {
 	}
 	defer client.Close()
 
+	var invites *inviteWatcher
+	if c.Invites {
+		invites, err = newInviteWatcher(root, cfg, email, client)
+		if err != nil {
+			return err
+		}
+		defer invites.close()
+	}
+
 	fmt.Printf("Watching %s for new mail (Ctrl+C to stop)...\n", c.Folder)
 
 	// Handle interrupt
@@ -79,6 +89,10 @@ func (c *IdleCmd) Run(root *Root) error {
 			cmd.Stderr = os.Stderr
 			_ = cmd.Run()
 		}
+
+		if invites != nil {
+			invites.check(c.Folder)
+		}
 	})
 
 	if err != nil {
@@ -8,18 +8,26 @@ import (
 	"strings"
 	"time"
 
+	"github.com/visionik/sogcli/internal/caldav"
 	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/dateexpr"
 	"github.com/visionik/sogcli/internal/itip"
 	"github.com/visionik/sogcli/internal/smtp"
+	"github.com/visionik/sogcli/internal/timezone"
 )
 
 // InviteCmd handles meeting invitation operations.
 type InviteCmd struct {
-	Send    InviteSendCmd    `cmd:"" help:"Send a meeting invitation"`
-	Reply   InviteReplyCmd   `cmd:"" help:"Reply to a meeting invitation"`
-	Cancel  InviteCancelCmd  `cmd:"" help:"Cancel a meeting"`
-	Parse   InviteParseCmd   `cmd:"" help:"Parse an .ics file"`
-	Preview InvitePreviewCmd `cmd:"" help:"Preview invite without sending"`
+	Send           InviteSendCmd           `cmd:"" help:"Send a meeting invitation"`
+	Reply          InviteReplyCmd          `cmd:"" help:"Reply to a meeting invitation"`
+	Update         InviteUpdateCmd         `cmd:"" help:"Change a meeting and send the update"`
+	Cancel         InviteCancelCmd         `cmd:"" help:"Cancel a meeting"`
+	Counter        InviteCounterCmd        `cmd:"" help:"Propose a different time to the organizer"`
+	DeclineCounter InviteDeclineCounterCmd `cmd:"" help:"Reject an attendee's proposed change"`
+	Refresh        InviteRefreshCmd        `cmd:"" help:"Ask the organizer for the latest version of a meeting"`
+	Parse          InviteParseCmd          `cmd:"" help:"Parse an .ics file"`
+	Preview        InvitePreviewCmd        `cmd:"" help:"Preview invite without sending"`
+	Inbox          InviteInboxCmd          `cmd:"" help:"Invitations, replies and cancellations received by email"`
 }
 
 // InviteSendCmd sends a meeting invitation.
@@ -32,6 +40,10 @@ type InviteSendCmd struct {
 	Location    string   `help:"Meeting location" short:"l"`
 	Description string   `help:"Meeting description" short:"d"`
 	Organizer   string   `help:"Organizer name"`
+	TZ          string   `name:"tz" help:"Time zone of --start and --end, e.g. Europe/Berlin (default: system zone)"`
+	Calendar    string   `help:"Calendar to add the meeting to (default: primary)"`
+	Via         string   `help:"Delivery: auto (the CalDAV server if it supports scheduling, else email), server, or email" enum:"auto,server,email" default:"auto"`
+	NoConflicts bool     `name:"no-conflicts" help:"Refuse to send the invite if the meeting overlaps another in your calendars"`
 }
 
 // Run executes the invite send command.
@@ -49,8 +61,13 @@ func (c *InviteSendCmd) Run(root *Root) error {
 		return fmt.Errorf("no account specified")
 	}
 
+	loc, err := timezone.Load(c.TZ)
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+
 	// Parse start time
-	start, _, err := parseDateTime(c.Start)
+	start, _, err := parseDateTime(c.Start, loc)
 	if err != nil {
 		return fmt.Errorf("invalid start time: %w", err)
 	}
@@ -58,12 +75,12 @@ func (c *InviteSendCmd) Run(root *Root) error {
 	// Calculate end time
 	var end time.Time
 	if c.End != "" {
-		end, _, err = parseDateTime(c.End)
+		end, _, err = parseDateTime(c.End, loc)
 		if err != nil {
 			return fmt.Errorf("invalid end time: %w", err)
 		}
 	} else {
-		dur, err := time.ParseDuration(c.Duration)
+		dur, err := dateexpr.ParseDuration(c.Duration)
 		if err != nil {
 			return fmt.Errorf("invalid duration: %w", err)
 		}
@@ -94,20 +111,33 @@ func (c *InviteSendCmd) Run(root *Root) error {
 		})
 	}
 
-	// Generate iCalendar
-	icsData, err := itip.CreateInvite(inv)
+	event := &caldav.Event{UID: inv.UID, Summary: inv.Summary, Start: inv.Start, End: inv.End}
+	if err := checkConflicts(root, event, c.NoConflicts); err != nil {
+		return err
+	}
+
+	// Add to the organizer's calendar; scheduling servers deliver it
+	added, delivered, err := scheduleInvite(root, c.Calendar, c.Via, inv)
 	if err != nil {
-		return fmt.Errorf("failed to create invite: %w", err)
+		return err
 	}
 
-	// Send via SMTP
-	if err := sendInviteEmail(cfg, email, inv, icsData); err != nil {
-		return fmt.Errorf("failed to send invite: %w", err)
+	if !delivered {
+		// Generate iCalendar
+		icsData, err := itip.CreateInvite(inv)
+		if err != nil {
+			return fmt.Errorf("failed to create invite: %w", err)
+		}
+
+		// Send via SMTP
+		if err := sendInviteEmail(cfg, email, inv, c.Attendees, icsData); err != nil {
+			return fmt.Errorf("failed to send invite: %w", err)
+		}
 	}
 
 	if root.JSON {
-		fmt.Printf(`{"uid":"%s","summary":"%s","start":"%s","end":"%s","attendees":%d}`+"\n",
-			inv.UID, inv.Summary, inv.Start.Format(time.RFC3339), inv.End.Format(time.RFC3339), len(inv.Attendees))
+		fmt.Printf(`{"uid":"%s","summary":"%s","start":"%s","end":"%s","attendees":%d,"via":"%s","calendar":%t}`+"\n",
+			inv.UID, inv.Summary, inv.Start.Format(time.RFC3339), inv.End.Format(time.RFC3339), len(inv.Attendees), viaName(delivered), added)
 		return nil
 	}
 
@@ -118,6 +148,10 @@ func (c *InviteSendCmd) Run(root *Root) error {
 		fmt.Printf("  Where: %s\n", inv.Location)
 	}
 	fmt.Printf("  Attendees: %s\n", strings.Join(c.Attendees, ", "))
+	fmt.Printf("  Sent by: %s\n", formatDelivery(delivered))
+	if added {
+		fmt.Println("  Added to your calendar")
+	}
 	return nil
 }
 
@@ -203,6 +237,7 @@ func (c *InviteReplyCmd) Run(root *Root) error {
 type InviteCancelCmd struct {
 	UID       string   `arg:"" help:"Meeting UID to cancel"`
 	Attendees []string `arg:"" help:"Attendee emails to notify"`
+	Calendar  string   `help:"Calendar the meeting is on (default: primary)"`
 }
 
 // Run executes the invite cancel command.
@@ -220,24 +255,31 @@ func (c *InviteCancelCmd) Run(root *Root) error {
 		return fmt.Errorf("no account specified")
 	}
 
-	organizer := itip.Participant{Email: email}
-	var attendees []itip.Participant
-	for _, att := range c.Attendees {
-		attendees = append(attendees, itip.Participant{Email: att})
-	}
+	// Remove from the organizer's calendar; scheduling servers notify
+	// the attendees themselves
+	delivered, sequence := cancelScheduled(root, c.Calendar, c.UID)
 
-	cancelData, err := itip.CreateCancel(c.UID, organizer, attendees, 1)
-	if err != nil {
-		return fmt.Errorf("failed to create cancel: %w", err)
-	}
+	if !delivered {
+		organizer := itip.Participant{Email: email}
+		var attendees []itip.Participant
+		for _, att := range c.Attendees {
+			attendees = append(attendees, itip.Participant{Email: att})
+		}
 
-	// Send cancel to all attendees
-	if err := sendCancelEmail(cfg, email, c.UID, c.Attendees, cancelData); err != nil {
-		return fmt.Errorf("failed to send cancel: %w", err)
+		cancelData, err := itip.CreateCancel(c.UID, organizer, attendees, sequence+1)
+		if err != nil {
+			return fmt.Errorf("failed to create cancel: %w", err)
+		}
+
+		// Send cancel to all attendees
+		if err := sendCancelEmail(cfg, email, c.UID, c.Attendees, cancelData); err != nil {
+			return fmt.Errorf("failed to send cancel: %w", err)
+		}
 	}
 
 	fmt.Printf("Sent cancellation for meeting: %s\n", c.UID)
 	fmt.Printf("  Notified: %s\n", strings.Join(c.Attendees, ", "))
+	fmt.Printf("  Sent by: %s\n", formatDelivery(delivered))
 	return nil
 }
 
@@ -309,10 +351,11 @@ func (c *InviteParseCmd) Run(root *Root) error {
 type InvitePreviewCmd struct {
 	Summary     string   `arg:"" help:"Meeting title/summary"`
 	Attendees   []string `arg:"" help:"Attendee email addresses"`
-	Start       string   `help:"Start time (YYYY-MM-DDTHH:MM)" required:""`
+	Start       string   `help:"Start time (YYYY-MM-DDTHH:MM or 'tomorrow 2pm')" required:""`
 	Duration    string   `help:"Duration (e.g., 1h, 30m)" default:"1h"`
 	Location    string   `help:"Meeting location" short:"l"`
 	Description string   `help:"Meeting description" short:"d"`
+	TZ          string   `name:"tz" help:"Time zone of --start, e.g. Europe/Berlin (default: system zone)"`
 }
 
 // Run executes the invite preview command.
@@ -328,12 +371,16 @@ func (c *InvitePreviewCmd) Run(root *Root) error {
 		email = "organizer@example.com"
 	}
 
-	start, _, err := parseDateTime(c.Start)
+	loc, err := timezone.Load(c.TZ)
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+	start, _, err := parseDateTime(c.Start, loc)
 	if err != nil {
 		return fmt.Errorf("invalid start time: %w", err)
 	}
 
-	dur, err := time.ParseDuration(c.Duration)
+	dur, err := dateexpr.ParseDuration(c.Duration)
 	if err != nil {
 		return fmt.Errorf("invalid duration: %w", err)
 	}
@@ -375,13 +422,14 @@ func getDomain(email string) string {
 	return "sog.local"
 }
 
-func sendInviteEmail(cfg *config.Config, from string, inv *itip.Invite, icsData []byte) error {
-	acct, err := cfg.GetAccount(from)
+// sendITIPEmail sends a scheduling message by email (iMIP, RFC 6047).
+func sendITIPEmail(cfg *config.Config, msg *smtp.Message) error {
+	acct, err := cfg.GetAccount(msg.From)
 	if err != nil {
 		return err
 	}
 
-	password, err := cfg.GetPasswordForProtocol(from, config.ProtocolSMTP)
+	password, err := cfg.GetPasswordForProtocol(msg.From, config.ProtocolSMTP)
 	if err != nil {
 		return err
 	}
@@ -389,7 +437,7 @@ func sendInviteEmail(cfg *config.Config, from string, inv *itip.Invite, icsData
 	client, err := smtp.Connect(smtp.Config{
 		Host:     acct.SMTP.Host,
 		Port:     acct.SMTP.Port,
-		Email:    from,
+		Email:    msg.From,
 		Password: password,
 		StartTLS: acct.SMTP.StartTLS,
 		TLS:      acct.SMTP.TLS,
@@ -399,17 +447,21 @@ func sendInviteEmail(cfg *config.Config, from string, inv *itip.Invite, icsData
 	}
 	defer client.Close()
 
-	// Build recipient list
-	var to []string
-	for _, att := range inv.Attendees {
-		to = append(to, att.Email)
+	return client.Send(context.Background(), msg)
+}
+
+// sendInviteEmail sends an invitation or update to the attendees in to.
+func sendInviteEmail(cfg *config.Config, from string, inv *itip.Invite, to []string, icsData []byte) error {
+	subject := fmt.Sprintf("Meeting Invitation: %s", inv.Summary)
+	if inv.Sequence > 0 {
+		subject = fmt.Sprintf("Updated Meeting Invitation: %s", inv.Summary)
 	}
 
 	// Create message with calendar attachment
 	msg := &smtp.Message{
 		From:    from,
 		To:      to,
-		Subject: fmt.Sprintf("Meeting Invitation: %s", inv.Summary),
+		Subject: subject,
 		Body:    fmt.Sprintf("You have been invited to: %s\n\nWhen: %s - %s\nWhere: %s\n\n%s",
 			inv.Summary,
 			inv.Start.Format("Mon Jan 2, 2006 15:04"),
@@ -417,36 +469,13 @@ func sendInviteEmail(cfg *config.Config, from string, inv *itip.Invite, icsData
 			inv.Location,
 			inv.Description),
 		CalendarData:   icsData,
-		CalendarMethod: string(inv.Method),
+		CalendarMethod: string(itip.MethodRequest),
 	}
 
-	return client.Send(context.Background(), msg)
+	return sendITIPEmail(cfg, msg)
 }
 
 func sendReplyEmail(cfg *config.Config, from string, inv *itip.Invite, resp *itip.Response, replyData []byte) error {
-	acct, err := cfg.GetAccount(from)
-	if err != nil {
-		return err
-	}
-
-	password, err := cfg.GetPasswordForProtocol(from, config.ProtocolSMTP)
-	if err != nil {
-		return err
-	}
-
-	client, err := smtp.Connect(smtp.Config{
-		Host:     acct.SMTP.Host,
-		Port:     acct.SMTP.Port,
-		Email:    from,
-		Password: password,
-		StartTLS: acct.SMTP.StartTLS,
-		TLS:      acct.SMTP.TLS,
-	})
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
 	statusWord := "responded to"
 	switch resp.Status {
 	case itip.StatusAccepted:
@@ -466,33 +495,10 @@ func sendReplyEmail(cfg *config.Config, from string, inv *itip.Invite, resp *iti
 		CalendarMethod: string(itip.MethodReply),
 	}
 
-	return client.Send(context.Background(), msg)
+	return sendITIPEmail(cfg, msg)
 }
 
 func sendCancelEmail(cfg *config.Config, from string, uid string, attendees []string, cancelData []byte) error {
-	acct, err := cfg.GetAccount(from)
-	if err != nil {
-		return err
-	}
-
-	password, err := cfg.GetPasswordForProtocol(from, config.ProtocolSMTP)
-	if err != nil {
-		return err
-	}
-
-	client, err := smtp.Connect(smtp.Config{
-		Host:     acct.SMTP.Host,
-		Port:     acct.SMTP.Port,
-		Email:    from,
-		Password: password,
-		StartTLS: acct.SMTP.StartTLS,
-		TLS:      acct.SMTP.TLS,
-	})
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
 	msg := &smtp.Message{
 		From:           from,
 		To:             attendees,
@@ -502,5 +508,5 @@ func sendCancelEmail(cfg *config.Config, from string, uid string, attendees []st
 		CalendarMethod: string(itip.MethodCancel),
 	}
 
-	return client.Send(context.Background(), msg)
+	return sendITIPEmail(cfg, msg)
 }
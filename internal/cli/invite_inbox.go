@@ -0,0 +1,744 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/imap"
+	"github.com/visionik/sogcli/internal/itip"
+	"github.com/visionik/sogcli/internal/mimepart"
+)
+
+// InviteInboxCmd processes meeting invitations received by email (iMIP).
+type InviteInboxCmd struct {
+	List      InviteInboxListCmd      `cmd:"" aliases:"ls" default:"1" help:"List pending invitations, replies and cancellations"`
+	Accept    InviteInboxAcceptCmd    `cmd:"" help:"Accept an invitation: reply and add it to the calendar"`
+	Decline   InviteInboxDeclineCmd   `cmd:"" help:"Decline an invitation"`
+	Tentative InviteInboxTentativeCmd `cmd:"" help:"Tentatively accept an invitation"`
+	Apply     InviteInboxApplyCmd     `cmd:"" help:"Apply replies and cancellations to the calendar, answer refresh requests"`
+	Dismiss   InviteInboxDismissCmd   `cmd:"" help:"Hide messages without acting on them"`
+}
+
+// inviteScan holds the flags for finding invitations in the mailbox.
+type inviteScan struct {
+	Folder string `help:"Folder to scan" default:"INBOX"`
+	Max    int    `help:"Number of recent messages to scan" default:"200"`
+}
+
+// mailInvite is a scheduling message (iTIP) found in an email.
+type mailInvite struct {
+	Message   uint32    `json:"message"` // IMAP UID of the email
+	From      string    `json:"from"`
+	Method    string    `json:"method"`
+	UID       string    `json:"uid"`
+	Sequence  int       `json:"sequence"`
+	Summary   string    `json:"summary,omitempty"`
+	Start     time.Time `json:"start,omitempty"`
+	End       time.Time `json:"end,omitempty"`
+	Organizer string    `json:"organizer,omitempty"`
+	Attendee  string    `json:"attendee,omitempty"` // Who replied or countered
+	Status    string    `json:"status,omitempty"`   // PARTSTAT of a reply
+
+	invite *itip.Invite
+	data   []byte
+	over   time.Time // End of the last occurrence; zero if it never ends
+}
+
+// key identifies a scheduling message, wherever it is stored.
+func (m *mailInvite) key() string {
+	key := fmt.Sprintf("%s/%s/%d", m.Method, m.UID, m.Sequence)
+	switch m.Method {
+	case string(itip.MethodReply):
+		key += "/" + strings.ToLower(m.Attendee) + "/" + m.Status
+	case string(itip.MethodRefresh):
+		key += "/" + strings.ToLower(m.Attendee)
+	case string(itip.MethodCounter):
+		key += "/" + strings.ToLower(m.Attendee) + "/" + m.Start.UTC().Format(time.RFC3339)
+	}
+	return key
+}
+
+// scanInvites finds the scheduling messages in the last max emails of
+// folder. Messages the account sent itself are skipped.
+func scanInvites(client *imap.Client, folder string, max int, self string) ([]mailInvite, error) {
+	messages, err := client.ListCalendarMessages(folder, max)
+	if err != nil {
+		return nil, err
+	}
+	var items []mailInvite
+	seen := map[string]bool{}
+	for _, msg := range messages {
+		for _, item := range parseMailInvites(msg) {
+			if seen[item.key()] || isOwnMessage(&item, self) {
+				continue
+			}
+			seen[item.key()] = true
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// parseMailInvites returns the scheduling messages in an email's calendar
+// parts.
+func parseMailInvites(msg imap.Message) []mailInvite {
+	entity, err := mimepart.Parse([]byte(msg.Body))
+	if err != nil {
+		return nil
+	}
+	var items []mailInvite
+	for _, part := range entity.Find("text/calendar", "application/ics") {
+		data, err := part.Decoded()
+		if err != nil {
+			continue
+		}
+		inv, err := itip.ParseInvite(data)
+		if err != nil || inv.UID == "" {
+			continue
+		}
+		item := mailInvite{
+			Message:   msg.UID,
+			From:      msg.From,
+			Method:    strings.ToUpper(string(inv.Method)),
+			UID:       inv.UID,
+			Sequence:  inv.Sequence,
+			Summary:   inv.Summary,
+			Start:     inv.Start,
+			End:       inv.End,
+			Organizer: inv.Organizer.Email,
+			invite:    inv,
+			data:      data,
+			over:      inv.Over(),
+		}
+		switch itip.Method(item.Method) {
+		case itip.MethodRequest, itip.MethodCancel, itip.MethodDeclineCounter:
+		case itip.MethodReply, itip.MethodCounter, itip.MethodRefresh:
+			if sender := messageSender(inv, msg.From); sender != nil {
+				item.Attendee = sender.Email
+				item.Status = string(sender.Status)
+			}
+		default:
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// messageSender returns the attendee who sent a reply, counter or refresh:
+// the one with the email's From address. Other attendees in the message
+// can't be trusted to have sent it.
+func messageSender(inv *itip.Invite, from string) *itip.Participant {
+	for i := range inv.Attendees {
+		if strings.EqualFold(inv.Attendees[i].Email, from) {
+			return &inv.Attendees[i]
+		}
+	}
+	return nil
+}
+
+// isOwnMessage reports whether the account sent a scheduling message, like
+// copies of its own invitations and replies.
+func isOwnMessage(m *mailInvite, self string) bool {
+	switch itip.Method(m.Method) {
+	case itip.MethodRequest, itip.MethodCancel, itip.MethodDeclineCounter:
+		return strings.EqualFold(m.Organizer, self)
+	default:
+		return strings.EqualFold(m.Attendee, self)
+	}
+}
+
+// pendingInvites returns the messages still to act on: not handled, not
+// about meetings that are over, and not superseded by a later update or
+// cancellation of the same meeting.
+func pendingInvites(items []mailInvite, state *inviteState, now time.Time) []mailInvite {
+	latest := map[string]int{}    // Highest REQUEST sequence per UID
+	cancelled := map[string]int{} // Highest CANCEL sequence per UID
+	for _, m := range items {
+		switch itip.Method(m.Method) {
+		case itip.MethodRequest:
+			if seq, ok := latest[m.UID]; !ok || m.Sequence > seq {
+				latest[m.UID] = m.Sequence
+			}
+		case itip.MethodCancel:
+			if seq, ok := cancelled[m.UID]; !ok || m.Sequence > seq {
+				cancelled[m.UID] = m.Sequence
+			}
+		}
+	}
+
+	var pending []mailInvite
+	for _, m := range items {
+		if _, handled := state.Handled[m.key()]; handled {
+			continue
+		}
+		if !m.over.IsZero() && m.over.Before(now) {
+			continue
+		}
+		if itip.Method(m.Method) == itip.MethodRequest {
+			if m.Sequence < latest[m.UID] {
+				continue
+			}
+			if seq, ok := cancelled[m.UID]; ok && seq >= m.Sequence {
+				continue
+			}
+		}
+		pending = append(pending, m)
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].Message < pending[j].Message })
+	return pending
+}
+
+// describe summarizes a scheduling message for a listing.
+func (m *mailInvite) describe() string {
+	switch itip.Method(m.Method) {
+	case itip.MethodReply:
+		return fmt.Sprintf("%s %s %s", m.Attendee, strings.ToLower(m.Status), m.Summary)
+	case itip.MethodCancel:
+		return fmt.Sprintf("%s cancelled by %s", m.Summary, m.Organizer)
+	case itip.MethodCounter:
+		return fmt.Sprintf("%s proposes %s for %s", m.Attendee, m.Start.Local().Format("Mon Jan 2 15:04"), m.Summary)
+	case itip.MethodDeclineCounter:
+		return fmt.Sprintf("%s declined your proposal for %s", m.Organizer, m.UID)
+	case itip.MethodRefresh:
+		return fmt.Sprintf("%s asks for the latest version of %s", m.Attendee, m.UID)
+	}
+	if m.Sequence > 0 {
+		return fmt.Sprintf("%s from %s (updated)", m.Summary, m.Organizer)
+	}
+	return fmt.Sprintf("%s from %s", m.Summary, m.Organizer)
+}
+
+// connectIMAP connects to the account's IMAP server.
+func connectIMAP(cfg *config.Config, email string) (*imap.Client, error) {
+	acct, err := cfg.GetAccount(email)
+	if err != nil {
+		return nil, err
+	}
+	password, err := cfg.GetPassword(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password: %w", err)
+	}
+	client, err := imap.Connect(imap.Config{
+		Host:     acct.IMAP.Host,
+		Port:     acct.IMAP.Port,
+		TLS:      acct.IMAP.TLS,
+		Insecure: acct.IMAP.Insecure,
+		NoTLS:    acct.IMAP.NoTLS,
+		Email:    email,
+		Password: password,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	return client, nil
+}
+
+// inviteSession is an open mailbox and invitation state.
+type inviteSession struct {
+	cfg       *config.Config
+	email     string
+	imap      *imap.Client
+	state     *inviteState
+	statePath string
+	items     []mailInvite
+}
+
+// openInvites scans the mailbox for scheduling messages.
+func openInvites(root *Root, scan inviteScan) (*inviteSession, error) {
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return nil, err
+	}
+	statePath, err := inviteStatePath(email)
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadInviteState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	client, err := connectIMAP(cfg, email)
+	if err != nil {
+		return nil, err
+	}
+	s := &inviteSession{cfg: cfg, email: email, imap: client, state: state, statePath: statePath}
+	if err := s.scan(scan.Folder, scan.Max); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// scan looks for scheduling messages in the last max emails of folder.
+func (s *inviteSession) scan(folder string, max int) error {
+	items, err := scanInvites(s.imap, folder, max, s.email)
+	if err != nil {
+		return err
+	}
+	s.items = items
+	return nil
+}
+
+func (s *inviteSession) close() {
+	s.imap.Close()
+}
+
+// handle records messages as handled and saves the state.
+func (s *inviteSession) handle(items ...mailInvite) error {
+	now := time.Now()
+	for _, m := range items {
+		s.state.Handled[m.key()] = m.over
+		if m.over.IsZero() {
+			s.state.Handled[m.key()] = now
+		}
+	}
+	s.state.prune(now.Add(-30 * 24 * time.Hour))
+	return s.state.save(s.statePath)
+}
+
+// InviteInboxListCmd lists pending scheduling messages.
+type InviteInboxListCmd struct {
+	inviteScan
+}
+
+// Run executes the invite inbox list command.
+func (c *InviteInboxListCmd) Run(root *Root) error {
+	s, err := openInvites(root, c.inviteScan)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	pending := pendingInvites(s.items, s.state, time.Now())
+	if root.JSON {
+		for _, m := range pending {
+			data, err := json.Marshal(m)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		}
+		return nil
+	}
+	if len(pending) == 0 {
+		fmt.Println("No pending invitations.")
+		return nil
+	}
+
+	fmt.Printf("%-8s %-17s %s\n", "METHOD", "WHEN", "SUMMARY")
+	for _, m := range pending {
+		when := ""
+		if !m.Start.IsZero() {
+			when = m.Start.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-8s %-17s %s\n", m.Method, when, m.describe())
+		fmt.Printf("         uid: %s\n", m.UID)
+	}
+	return nil
+}
+
+// InviteInboxAcceptCmd accepts an invitation received by email.
+type InviteInboxAcceptCmd struct {
+	inviteRespond
+}
+
+// Run executes the invite inbox accept command.
+func (c *InviteInboxAcceptCmd) Run(root *Root) error {
+	return c.respond(root, itip.StatusAccepted)
+}
+
+// InviteInboxDeclineCmd declines an invitation received by email.
+type InviteInboxDeclineCmd struct {
+	inviteRespond
+}
+
+// Run executes the invite inbox decline command.
+func (c *InviteInboxDeclineCmd) Run(root *Root) error {
+	return c.respond(root, itip.StatusDeclined)
+}
+
+// InviteInboxTentativeCmd tentatively accepts an invitation received by
+// email.
+type InviteInboxTentativeCmd struct {
+	inviteRespond
+}
+
+// Run executes the invite inbox tentative command.
+func (c *InviteInboxTentativeCmd) Run(root *Root) error {
+	return c.respond(root, itip.StatusTentative)
+}
+
+// inviteRespond holds the flags shared by accept, decline and tentative.
+type inviteRespond struct {
+	UID      string `arg:"" help:"Invitation UID (see 'sog invite inbox')"`
+	Comment  string `help:"Comment for the organizer"`
+	Calendar string `help:"Calendar to add the meeting to (default: primary)"`
+	inviteScan
+}
+
+// respond replies to the latest invitation with the UID and puts it on the
+// calendar with the account's status.
+func (c *inviteRespond) respond(root *Root, status itip.ParticipantStatus) error {
+	s, err := openInvites(root, c.inviteScan)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	var inv *mailInvite
+	var requests []mailInvite
+	for i := range s.items {
+		m := &s.items[i]
+		if m.UID != c.UID || itip.Method(m.Method) != itip.MethodRequest {
+			continue
+		}
+		requests = append(requests, *m)
+		if inv == nil || m.Sequence >= inv.Sequence {
+			inv = m
+		}
+	}
+	if inv == nil {
+		return fmt.Errorf("no invitation %s in the last %d messages of %s", c.UID, c.Max, c.Folder)
+	}
+
+	resp := &itip.Response{
+		UID:       inv.UID,
+		Attendee:  itip.Participant{Email: s.email, Status: status},
+		Organizer: inv.invite.Organizer,
+		Status:    status,
+		Comment:   c.Comment,
+		Sequence:  inv.Sequence,
+	}
+	replyData, err := itip.CreateReply(resp)
+	if err != nil {
+		return fmt.Errorf("failed to create reply: %w", err)
+	}
+	if err := sendReplyEmail(s.cfg, s.email, inv.invite, resp, replyData); err != nil {
+		return fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	calendar := "not configured"
+	if client, calPath, err := getCalDAVClient(root); err == nil {
+		defer client.Close()
+		if c.Calendar != "" {
+			calPath = c.Calendar
+		}
+		_, err := client.SaveInvitation(context.Background(), calPath, inv.data, string(status))
+		switch {
+		case errors.Is(err, caldav.ErrOutdated):
+			calendar = "unchanged (it has a newer version)"
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Warning: reply sent, but the calendar was not updated: %v\n", err)
+			calendar = "not updated"
+		default:
+			calendar = "updated"
+		}
+	}
+	if err := s.handle(requests...); err != nil {
+		return err
+	}
+
+	if root.JSON {
+		fmt.Printf(`{"uid":"%s","status":"%s","organizer":"%s","calendar":"%s"}`+"\n", inv.UID, status, inv.Organizer, calendar)
+		return nil
+	}
+	fmt.Printf("Sent %s reply to: %s\n", strings.ToLower(string(status)), inv.Organizer)
+	fmt.Printf("  Meeting: %s\n", inv.Summary)
+	fmt.Printf("  Calendar: %s\n", calendar)
+	return nil
+}
+
+// InviteInboxApplyCmd applies replies and cancellations to the calendar.
+type InviteInboxApplyCmd struct {
+	Calendar string `help:"Calendar to look in first (default: primary)"`
+	inviteScan
+}
+
+// Run executes the invite inbox apply command.
+func (c *InviteInboxApplyCmd) Run(root *Root) error {
+	s, err := openInvites(root, c.inviteScan)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	client, calPath, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if c.Calendar != "" {
+		calPath = c.Calendar
+	}
+
+	results, err := s.applyUpdates(context.Background(), client, calPath)
+	if err != nil {
+		return err
+	}
+	printInviteResults(root, results)
+	return nil
+}
+
+// inviteResult is the outcome of applying a scheduling message.
+type inviteResult struct {
+	Method  string `json:"method"`
+	UID     string `json:"uid"`
+	Summary string `json:"summary,omitempty"`
+	Result  string `json:"result"`
+}
+
+// applyUpdates applies the pending replies and cancellations to the
+// calendar and answers refresh requests with the meeting on it. Messages
+// about meetings that aren't on it are dismissed.
+func (s *inviteSession) applyUpdates(ctx context.Context, client *caldav.Client, calPath string) ([]inviteResult, error) {
+	var results []inviteResult
+	var handled []mailInvite
+	for _, m := range pendingInvites(s.items, s.state, time.Now()) {
+		var err error
+		switch itip.Method(m.Method) {
+		case itip.MethodCancel:
+			_, err = client.ApplyCancel(ctx, calPath, m.data, m.From)
+		case itip.MethodReply:
+			_, err = client.ApplyReply(ctx, calPath, m.data, m.From)
+		case itip.MethodRefresh:
+			err = s.resend(ctx, client, calPath, m)
+		default:
+			continue
+		}
+		result := inviteResult{Method: m.Method, UID: m.UID, Summary: m.describe(), Result: "applied"}
+		if itip.Method(m.Method) == itip.MethodRefresh {
+			result.Result = "sent"
+		}
+		switch {
+		case errors.Is(err, caldav.ErrEventNotFound):
+			result.Result = "not in calendar"
+		case errors.Is(err, caldav.ErrOutdated):
+			result.Result = "outdated"
+		case errors.Is(err, caldav.ErrWrongSender):
+			result.Result = "ignored: not sent by the attendee"
+			if itip.Method(m.Method) == itip.MethodCancel {
+				result.Result = "ignored: not sent by the organizer"
+			}
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Warning: %s %s: %v\n", m.Method, m.UID, err)
+			continue
+		}
+		results = append(results, result)
+		handled = append(handled, m)
+	}
+	if len(handled) == 0 {
+		return results, nil
+	}
+	return results, s.handle(handled...)
+}
+
+// resend answers a refresh request: the attendee is sent the meeting as it
+// is on the calendar.
+func (s *inviteSession) resend(ctx context.Context, client *caldav.Client, calPath string, m mailInvite) error {
+	if m.Attendee == "" {
+		return caldav.ErrWrongSender
+	}
+	event, overrides, err := client.GetEventSeries(ctx, calPath, m.UID)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(event.Organizer, s.email) {
+		return fmt.Errorf("%s is organized by %s", event.Summary, event.Organizer)
+	}
+	inv := eventInvite(event, overrides)
+	data, err := itip.CreateInvite(inv)
+	if err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+	if err := sendInviteEmail(s.cfg, s.email, inv, []string{m.Attendee}, data); err != nil {
+		return fmt.Errorf("failed to send invite: %w", err)
+	}
+	return nil
+}
+
+func printInviteResults(root *Root, results []inviteResult) {
+	if root.JSON {
+		for _, r := range results {
+			data, _ := json.Marshal(r)
+			fmt.Println(string(data))
+		}
+		return
+	}
+	if len(results) == 0 {
+		fmt.Println("No replies or cancellations to apply.")
+		return
+	}
+	for _, r := range results {
+		fmt.Printf("%-8s %-16s %s\n", r.Method, r.Result, r.Summary)
+	}
+}
+
+// InviteInboxDismissCmd hides scheduling messages.
+type InviteInboxDismissCmd struct {
+	UID string `arg:"" optional:"" help:"UID of the meeting whose messages to hide"`
+	All bool   `help:"Hide all pending messages"`
+	inviteScan
+}
+
+// Run executes the invite inbox dismiss command.
+func (c *InviteInboxDismissCmd) Run(root *Root) error {
+	if (c.UID == "") == !c.All {
+		return fmt.Errorf("give a UID or --all")
+	}
+	s, err := openInvites(root, c.inviteScan)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	var dismissed []mailInvite
+	for _, m := range pendingInvites(s.items, s.state, time.Now()) {
+		if c.All || m.UID == c.UID {
+			dismissed = append(dismissed, m)
+		}
+	}
+	if len(dismissed) == 0 && !c.All {
+		return fmt.Errorf("no pending messages for %s", c.UID)
+	}
+	if err := s.handle(dismissed...); err != nil {
+		return err
+	}
+	fmt.Printf("Dismissed %d message(s)\n", len(dismissed))
+	return nil
+}
+
+// inviteWatcher processes scheduling messages in new mail for 'sog idle':
+// replies and cancellations are applied to the calendar, invitations are
+// announced.
+type inviteWatcher struct {
+	session   *inviteSession
+	calendar  *caldav.Client // nil without CalDAV
+	calPath   string
+	announced map[string]bool
+}
+
+// newInviteWatcher prepares processing of invitations arriving on client.
+func newInviteWatcher(root *Root, cfg *config.Config, email string, client *imap.Client) (*inviteWatcher, error) {
+	statePath, err := inviteStatePath(email)
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadInviteState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	w := &inviteWatcher{
+		session:   &inviteSession{cfg: cfg, email: email, imap: client, state: state, statePath: statePath},
+		announced: map[string]bool{},
+	}
+	if cal, calPath, err := getCalDAVClient(root); err == nil {
+		w.calendar, w.calPath = cal, calPath
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: replies and cancellations can't be applied: %v\n", err)
+	}
+	return w, nil
+}
+
+func (w *inviteWatcher) close() {
+	if w.calendar != nil {
+		w.calendar.Close()
+	}
+}
+
+// check processes the scheduling messages among the newest emails.
+func (w *inviteWatcher) check(folder string) {
+	if err := w.session.scan(folder, 20); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	if w.calendar != nil {
+		results, err := w.session.applyUpdates(context.Background(), w.calendar, w.calPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		for _, r := range results {
+			fmt.Printf("%s %s: %s\n", r.Method, r.Result, r.Summary)
+		}
+	}
+	for _, m := range pendingInvites(w.session.items, w.session.state, time.Now()) {
+		method := itip.Method(m.Method)
+		if w.announced[m.key()] || (method != itip.MethodRequest && method != itip.MethodCounter) {
+			continue
+		}
+		w.announced[m.key()] = true
+		fmt.Printf("%s: %s\n", m.Method, m.describe())
+		if method == itip.MethodRequest {
+			fmt.Printf("  Reply with: sog invite inbox accept %s (or decline, tentative)\n", m.UID)
+		}
+	}
+}
+
+// inviteState records handled scheduling messages by key, with the end of
+// their meeting (or when they were handled).
+type inviteState struct {
+	Handled map[string]time.Time `json:"handled"`
+}
+
+func inviteStatePath(account string) (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "invites", unsafeFileChars.ReplaceAllString(account, "_")+".json"), nil
+}
+
+func loadInviteState(path string) (*inviteState, error) {
+	state := &inviteState{Handled: map[string]time.Time{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read invitation state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse invitation state %s: %w", path, err)
+	}
+	if state.Handled == nil {
+		state.Handled = map[string]time.Time{}
+	}
+	return state, nil
+}
+
+// prune forgets messages about meetings that ended before cutoff; those
+// are no longer listed anyway.
+func (s *inviteState) prune(cutoff time.Time) {
+	for key, end := range s.Handled {
+		if end.Before(cutoff) {
+			delete(s.Handled, key)
+		}
+	}
+}
+
+func (s *inviteState) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write invitation state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write invitation state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/imap"
+)
+
+func invitationEmail(method, extra string) string {
+	ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\nMETHOD:" + method + "\r\n" +
+		"BEGIN:VEVENT\r\nUID:meeting@example.com\r\nDTSTAMP:20260301T000000Z\r\n" +
+		"DTSTART:20260302T090000Z\r\nDTEND:20260302T100000Z\r\nSUMMARY:Planning\r\n" + extra +
+		"ORGANIZER:mailto:alice@example.com\r\n" +
+		"ATTENDEE;PARTSTAT=ACCEPTED:mailto:bob@example.com\r\n" +
+		"END:VEVENT\r\nEND:VCALENDAR\r\n"
+	return "From: alice@example.com\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"B\"\r\n\r\n" +
+		"--B\r\nContent-Type: text/plain\r\n\r\nYou are invited\r\n" +
+		"--B\r\nContent-Type: text/calendar; method=" + method + "\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+		base64.StdEncoding.EncodeToString([]byte(ics)) + "\r\n" +
+		"--B\r\nContent-Type: application/ics\r\nContent-Disposition: attachment; filename=invite.ics\r\n\r\n" +
+		ics + "\r\n--B--\r\n"
+}
+
+func TestParseMailInvites(t *testing.T) {
+	items := parseMailInvites(imap.Message{UID: 7, From: "alice@example.com", Body: invitationEmail("REQUEST", "SEQUENCE:1\r\n")})
+	require.Len(t, items, 2) // The inline part and the attachment
+	m := items[0]
+	assert.Equal(t, uint32(7), m.Message)
+	assert.Equal(t, "REQUEST", m.Method)
+	assert.Equal(t, "meeting@example.com", m.UID)
+	assert.Equal(t, 1, m.Sequence)
+	assert.Equal(t, "alice@example.com", m.Organizer)
+	assert.Equal(t, items[0].key(), items[1].key())
+	assert.Contains(t, string(m.data), "METHOD:REQUEST")
+
+	reply := parseMailInvites(imap.Message{UID: 8, From: "bob@example.com", Body: invitationEmail("REPLY", "")})
+	require.NotEmpty(t, reply)
+	assert.Equal(t, "bob@example.com", reply[0].Attendee)
+	assert.Equal(t, "ACCEPTED", reply[0].Status)
+	assert.True(t, isOwnMessage(&reply[0], "BOB@example.com"))
+	assert.False(t, isOwnMessage(&reply[0], "alice@example.com"))
+
+	// A reply from someone who is not the attendee names no one
+	forged := parseMailInvites(imap.Message{UID: 8, From: "eve@example.com", Body: invitationEmail("REPLY", "")})
+	require.NotEmpty(t, forged)
+	assert.Empty(t, forged[0].Attendee)
+
+	refresh := parseMailInvites(imap.Message{UID: 9, From: "bob@example.com", Body: invitationEmail("REFRESH", "")})
+	require.NotEmpty(t, refresh)
+	assert.Equal(t, "bob@example.com", refresh[0].Attendee)
+	assert.Contains(t, refresh[0].describe(), "asks for the latest version")
+
+	decline := parseMailInvites(imap.Message{UID: 10, From: "alice@example.com", Body: invitationEmail("DECLINECOUNTER", "")})
+	require.NotEmpty(t, decline)
+	assert.True(t, isOwnMessage(&decline[0], "alice@example.com"))
+
+	assert.Empty(t, parseMailInvites(imap.Message{Body: invitationEmail("PUBLISH", "")}))
+	assert.Empty(t, parseMailInvites(imap.Message{Body: "Subject: hi\r\n\r\nno invitation"}))
+}
+
+func TestPendingInvites(t *testing.T) {
+	parse := func(uid uint32, method, extra string) mailInvite {
+		items := parseMailInvites(imap.Message{UID: uid, Body: invitationEmail(method, extra)})
+		require.NotEmpty(t, items)
+		return items[0]
+	}
+	first := parse(1, "REQUEST", "")
+	update := parse(2, "REQUEST", "SEQUENCE:1\r\n")
+	reply := parse(3, "REPLY", "")
+	state := &inviteState{Handled: map[string]time.Time{}}
+	before := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// Older versions of an invitation are superseded
+	pending := pendingInvites([]mailInvite{first, update, reply}, state, before)
+	require.Len(t, pending, 2)
+	assert.Equal(t, uint32(2), pending[0].Message)
+	assert.Equal(t, "REPLY", pending[1].Method)
+
+	// Handled messages and meetings that are over are hidden
+	state.Handled[reply.key()] = reply.End
+	assert.Len(t, pendingInvites([]mailInvite{first, update, reply}, state, before), 1)
+	assert.Empty(t, pendingInvites([]mailInvite{update}, state, before.AddDate(0, 1, 0)))
+
+	// Unless they recur
+	series := parse(5, "REQUEST", "SEQUENCE:1\r\nRRULE:FREQ=WEEKLY;COUNT=10\r\n")
+	assert.Len(t, pendingInvites([]mailInvite{series}, state, before.AddDate(0, 1, 0)), 1)
+	assert.Empty(t, pendingInvites([]mailInvite{series}, state, before.AddDate(0, 3, 0)))
+
+	// Cancelled meetings need no reply
+	cancel := parse(4, "CANCEL", "SEQUENCE:2\r\n")
+	pending = pendingInvites([]mailInvite{update, cancel}, state, before)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "CANCEL", pending[0].Method)
+	assert.Contains(t, pending[0].describe(), "cancelled by alice@example.com")
+}
@@ -0,0 +1,532 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/dateexpr"
+	"github.com/visionik/sogcli/internal/itip"
+	"github.com/visionik/sogcli/internal/smtp"
+	"github.com/visionik/sogcli/internal/timezone"
+)
+
+// InviteUpdateCmd changes a meeting and sends the update to its attendees.
+type InviteUpdateCmd struct {
+	UID         string   `arg:"" help:"Meeting UID"`
+	Start       string   `help:"New start time (the duration is kept unless --end or --duration is given)"`
+	End         string   `help:"New end time"`
+	Duration    string   `help:"New duration (e.g., 1h, 30m)"`
+	Summary     string   `help:"New title"`
+	Location    string   `help:"New location" short:"l"`
+	Description string   `help:"New description" short:"d"`
+	Add         []string `help:"Attendees to invite (repeatable)"`
+	Remove      []string `help:"Attendees to uninvite; they are sent a cancellation (repeatable)"`
+	File        string   `help:"The meeting's last invitation (.ics), for meetings that are not on your calendar"`
+	TZ          string   `name:"tz" help:"Time zone of --start and --end, e.g. Europe/Berlin (default: system zone)"`
+	Calendar    string   `help:"Calendar the meeting is on (default: primary)"`
+}
+
+// Run executes the invite update command.
+func (c *InviteUpdateCmd) Run(root *Root) error {
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return err
+	}
+	loc, err := timezone.Load(c.TZ)
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+
+	// The meeting as it is: on the calendar, or in its last invitation
+	var client *caldav.Client
+	var calPath string
+	var event *caldav.Event
+	var inv *itip.Invite
+	ctx := context.Background()
+	if c.File != "" {
+		data, err := readInviteFile(c.File)
+		if err != nil {
+			return err
+		}
+		if inv, err = itip.ParseInvite(data); err != nil {
+			return fmt.Errorf("failed to parse invite: %w", err)
+		}
+	} else {
+		client, calPath, err = getCalDAVClient(root)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		if c.Calendar != "" {
+			calPath = c.Calendar
+		}
+		var overrides []caldav.Event
+		if event, overrides, err = client.GetEventSeries(ctx, calPath, c.UID); err != nil {
+			return err
+		}
+		inv = eventInvite(event, overrides)
+	}
+	if inv.UID != c.UID {
+		return fmt.Errorf("%s is not the invitation for %s", c.File, c.UID)
+	}
+	if inv.Organizer.Email != "" && !strings.EqualFold(inv.Organizer.Email, email) {
+		return fmt.Errorf("%s is organized by %s; propose a change with 'sog invite counter'", inv.Summary, inv.Organizer.Email)
+	}
+	inv.Organizer.Email = email
+
+	// Apply the changes
+	moved := false
+	if c.Start != "" || c.End != "" || c.Duration != "" {
+		start, end, err := c.times(inv, loc)
+		if err != nil {
+			return err
+		}
+		moved = !start.Equal(inv.Start) || !end.Equal(inv.End)
+		inv.Start, inv.End = start, end
+	}
+	changed := moved
+	if c.Summary != "" && c.Summary != inv.Summary {
+		inv.Summary, changed = c.Summary, true
+	}
+	if c.Location != "" && c.Location != inv.Location {
+		inv.Location, changed = c.Location, true
+	}
+	if c.Description != "" && c.Description != inv.Description {
+		inv.Description, changed = c.Description, true
+	}
+	added, removed := updateAttendees(inv, c.Add, c.Remove)
+	if !changed && len(added) == 0 && len(removed) == 0 {
+		return fmt.Errorf("nothing to update")
+	}
+	if moved {
+		for i := range inv.Attendees {
+			if strings.EqualFold(inv.Attendees[i].Email, email) {
+				continue
+			}
+			inv.Attendees[i].Status = itip.StatusNeedsAction
+			inv.Attendees[i].RSVP = true
+		}
+	}
+
+	// Update the calendar, which bumps SEQUENCE; scheduling servers
+	// deliver the update themselves to the attendees they schedule
+	var scheduled []string
+	if event != nil {
+		updated := *event
+		updated.Summary, updated.Location, updated.Description = inv.Summary, inv.Location, inv.Description
+		updated.Start, updated.End = inv.Start, inv.End
+		updated.Attendees = nil
+		for _, att := range inv.Attendees {
+			updated.Attendees = append(updated.Attendees, att.Email)
+		}
+		if scheduled, err = client.UpdateInvitation(ctx, calPath, &updated); err != nil {
+			return err
+		}
+		inv.Sequence = updated.Sequence
+	} else {
+		inv.Sequence++
+	}
+
+	// Attendees who already have the meeting only need to hear about
+	// changes to it; added ones get the whole invitation
+	if cancelled := emailed(removed, scheduled); len(cancelled) > 0 {
+		var participants []itip.Participant
+		for _, addr := range cancelled {
+			participants = append(participants, itip.Participant{Email: addr})
+		}
+		cancelData, err := itip.CreateCancel(inv.UID, inv.Organizer, participants, inv.Sequence)
+		if err != nil {
+			return fmt.Errorf("failed to create cancel: %w", err)
+		}
+		if err := sendCancelEmail(cfg, email, inv.UID, cancelled, cancelData); err != nil {
+			return fmt.Errorf("failed to send cancel: %w", err)
+		}
+	}
+	to := added
+	if changed {
+		to = nil
+		for _, att := range inv.Attendees {
+			if !strings.EqualFold(att.Email, email) {
+				to = append(to, att.Email)
+			}
+		}
+	}
+	notified := emailed(to, scheduled)
+	if len(notified) > 0 {
+		if event != nil {
+			// The whole series as stored, with its exceptions and the
+			// occurrences moved along with it
+			stored, overrides, err := client.GetEventSeries(ctx, calPath, c.UID)
+			if err != nil {
+				return err
+			}
+			inv = eventInvite(stored, overrides)
+			inv.Organizer.Email = email
+		}
+		icsData, err := itip.CreateInvite(inv)
+		if err != nil {
+			return fmt.Errorf("failed to create invite: %w", err)
+		}
+		if err := sendInviteEmail(cfg, email, inv, notified, icsData); err != nil {
+			return fmt.Errorf("failed to send invite: %w", err)
+		}
+	}
+	delivered := len(scheduled) > 0
+	via, sentBy := viaName(delivered), formatDelivery(delivered)
+	if delivered && len(notified) > 0 {
+		via, sentBy = "server+email", sentBy+" and email"
+	}
+
+	if root.JSON {
+		fmt.Printf(`{"uid":"%s","sequence":%d,"start":"%s","end":"%s","added":%d,"removed":%d,"notified":%d,"via":"%s"}`+"\n",
+			inv.UID, inv.Sequence, inv.Start.Format(time.RFC3339), inv.End.Format(time.RFC3339),
+			len(added), len(removed), len(notified), via)
+		return nil
+	}
+
+	fmt.Printf("Updated meeting: %s\n", inv.Summary)
+	fmt.Printf("  UID: %s (sequence %d)\n", inv.UID, inv.Sequence)
+	fmt.Printf("  When: %s - %s\n", inv.Start.Format("Mon Jan 2 15:04"), inv.End.Format("15:04"))
+	if len(added) > 0 {
+		fmt.Printf("  Invited: %s\n", strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		fmt.Printf("  Uninvited: %s\n", strings.Join(removed, ", "))
+	}
+	if len(notified) > 0 {
+		fmt.Printf("  Notified: %s\n", strings.Join(notified, ", "))
+	}
+	fmt.Printf("  Sent by: %s\n", sentBy)
+	return nil
+}
+
+// times returns the meeting's new start and end. A new start keeps the
+// meeting's duration unless --end or --duration is given.
+func (c *InviteUpdateCmd) times(inv *itip.Invite, loc *time.Location) (time.Time, time.Time, error) {
+	start, end := inv.Start, inv.End
+	if c.Start != "" {
+		t, _, err := parseDateTime(c.Start, loc)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid start time: %w", err)
+		}
+		start, end = t, t.Add(inv.End.Sub(inv.Start))
+	}
+	switch {
+	case c.End != "":
+		t, _, err := parseDateTime(c.End, loc)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid end time: %w", err)
+		}
+		end = t
+	case c.Duration != "":
+		dur, err := dateexpr.ParseDuration(c.Duration)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid duration: %w", err)
+		}
+		end = start.Add(dur)
+	}
+	if !end.After(start) {
+		return start, end, fmt.Errorf("the meeting must end after it starts")
+	}
+	return start, end, nil
+}
+
+// updateAttendees adds and removes attendees of inv, returning the ones
+// actually added and removed.
+func updateAttendees(inv *itip.Invite, add, remove []string) (added, removed []string) {
+	drop := map[string]bool{}
+	for _, addr := range remove {
+		drop[strings.ToLower(addr)] = true
+	}
+	var kept []itip.Participant
+	for _, att := range inv.Attendees {
+		if drop[strings.ToLower(att.Email)] {
+			removed = append(removed, att.Email)
+			continue
+		}
+		kept = append(kept, att)
+	}
+	inv.Attendees = kept
+
+	for _, addr := range add {
+		if drop[strings.ToLower(addr)] || hasAttendee(inv, addr) {
+			continue
+		}
+		inv.Attendees = append(inv.Attendees, itip.Participant{Email: addr, Status: itip.StatusNeedsAction, RSVP: true})
+		added = append(added, addr)
+	}
+	return added, removed
+}
+
+func hasAttendee(inv *itip.Invite, address string) bool {
+	for _, att := range inv.Attendees {
+		if strings.EqualFold(att.Email, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// emailed returns the addresses the server does not send to.
+func emailed(addrs, scheduled []string) []string {
+	byServer := map[string]bool{}
+	for _, addr := range scheduled {
+		byServer[strings.ToLower(addr)] = true
+	}
+	var out []string
+	for _, addr := range addrs {
+		if !byServer[strings.ToLower(addr)] {
+			out = append(out, addr)
+		}
+	}
+	return out
+}
+
+// eventInvite returns the invitation for a meeting on the calendar, with
+// the overrides of its changed occurrences.
+func eventInvite(event *caldav.Event, overrides []caldav.Event) *itip.Invite {
+	inv := &itip.Invite{
+		Method:      itip.MethodRequest,
+		UID:         event.UID,
+		Summary:     event.Summary,
+		Description: event.Description,
+		Location:    event.Location,
+		Start:       event.Start,
+		End:         event.End,
+		Organizer:   itip.Participant{Email: event.Organizer},
+		Sequence:    event.Sequence,
+		RRule:       event.RRule,
+		RDates:      event.RDates,
+		ExDates:     event.ExDates,
+		Occurrence:  event.RecurrenceID,
+		Status:      event.Status,
+	}
+	for _, addr := range event.Attendees {
+		status := itip.ParticipantStatus(event.PartStat.Get(addr))
+		inv.Attendees = append(inv.Attendees, itip.Participant{
+			Email:  addr,
+			Status: status,
+			RSVP:   status == "" || status == itip.StatusNeedsAction,
+		})
+	}
+	for i := range overrides {
+		inv.Overrides = append(inv.Overrides, *eventInvite(&overrides[i], nil))
+	}
+	return inv
+}
+
+// viaName names the delivery in JSON output.
+func viaName(delivered bool) string {
+	if delivered {
+		return "server"
+	}
+	return "email"
+}
+
+// readInviteFile reads an .ics file, or stdin for "-".
+func readInviteFile(name string) ([]byte, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, nil
+}
+
+// InviteCounterCmd proposes a different time for a meeting.
+type InviteCounterCmd struct {
+	File     string `arg:"" help:"The invitation (.ics file or - for stdin)"`
+	Start    string `help:"Proposed start time" required:""`
+	End      string `help:"Proposed end time"`
+	Duration string `help:"Proposed duration (default: the meeting's)"`
+	Location string `help:"Proposed location" short:"l"`
+	Comment  string `help:"Comment for the organizer"`
+	TZ       string `name:"tz" help:"Time zone of --start and --end, e.g. Europe/Berlin (default: system zone)"`
+}
+
+// Run executes the invite counter command.
+func (c *InviteCounterCmd) Run(root *Root) error {
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return err
+	}
+	loc, err := timezone.Load(c.TZ)
+	if err != nil {
+		return fmt.Errorf("invalid --tz: %w", err)
+	}
+	data, err := readInviteFile(c.File)
+	if err != nil {
+		return err
+	}
+	inv, err := itip.ParseInvite(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse invite: %w", err)
+	}
+	if inv.Method != "" && inv.Method != itip.MethodRequest {
+		return fmt.Errorf("not an invitation: %s", inv.Method)
+	}
+	if inv.Organizer.Email == "" {
+		return fmt.Errorf("the invitation has no organizer")
+	}
+
+	// The meeting as proposed, from the account as attendee
+	update := InviteUpdateCmd{Start: c.Start, End: c.End, Duration: c.Duration}
+	start, end, err := update.times(inv, loc)
+	if err != nil {
+		return err
+	}
+	proposal := *inv
+	proposal.Start, proposal.End = start, end
+	proposal.Overrides = nil
+	if c.Location != "" {
+		proposal.Location = c.Location
+	}
+	proposal.Comment = c.Comment
+	me := itip.Participant{Email: email, Status: itip.StatusNeedsAction}
+	for _, att := range inv.Attendees {
+		if strings.EqualFold(att.Email, email) && att.Status != "" {
+			me.Status = att.Status
+		}
+	}
+	proposal.Attendees = []itip.Participant{me}
+
+	counterData, err := itip.CreateCounter(&proposal)
+	if err != nil {
+		return fmt.Errorf("failed to create counter-proposal: %w", err)
+	}
+	body := fmt.Sprintf("%s proposes a new time for %s: %s - %s", email, inv.Summary,
+		start.Format("Mon Jan 2, 2006 15:04"), end.Format("15:04"))
+	if c.Comment != "" {
+		body += "\n\n" + c.Comment
+	}
+	err = sendITIPEmail(cfg, &smtp.Message{
+		From:           email,
+		To:             []string{inv.Organizer.Email},
+		Subject:        fmt.Sprintf("New time proposed: %s", inv.Summary),
+		Body:           body,
+		CalendarData:   counterData,
+		CalendarMethod: string(itip.MethodCounter),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send counter-proposal: %w", err)
+	}
+
+	if root.JSON {
+		fmt.Printf(`{"uid":"%s","organizer":"%s","start":"%s","end":"%s"}`+"\n",
+			inv.UID, inv.Organizer.Email, start.Format(time.RFC3339), end.Format(time.RFC3339))
+		return nil
+	}
+	fmt.Printf("Proposed a new time to: %s\n", inv.Organizer.Email)
+	fmt.Printf("  Meeting: %s\n", inv.Summary)
+	fmt.Printf("  When: %s - %s\n", start.Format("Mon Jan 2 15:04"), end.Format("15:04"))
+	return nil
+}
+
+// InviteDeclineCounterCmd rejects an attendee's counter-proposal.
+type InviteDeclineCounterCmd struct {
+	File    string `arg:"" help:"The counter-proposal (.ics file or - for stdin)"`
+	Comment string `help:"Comment for the attendee"`
+}
+
+// Run executes the invite decline-counter command.
+func (c *InviteDeclineCounterCmd) Run(root *Root) error {
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return err
+	}
+	data, err := readInviteFile(c.File)
+	if err != nil {
+		return err
+	}
+	counter, err := itip.ParseInvite(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse counter-proposal: %w", err)
+	}
+	if counter.Method != itip.MethodCounter || len(counter.Attendees) == 0 {
+		return fmt.Errorf("not a counter-proposal")
+	}
+
+	attendee := counter.Attendees[0]
+	declineData, err := itip.CreateDeclineCounter(&itip.Response{
+		UID:       counter.UID,
+		Attendee:  itip.Participant{Email: attendee.Email, Name: attendee.Name},
+		Organizer: itip.Participant{Email: email},
+		Comment:   c.Comment,
+		Sequence:  counter.Sequence,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create decline: %w", err)
+	}
+	body := fmt.Sprintf("%s declined your proposed change to %s.", email, counter.Summary)
+	if c.Comment != "" {
+		body += "\n\n" + c.Comment
+	}
+	err = sendITIPEmail(cfg, &smtp.Message{
+		From:           email,
+		To:             []string{attendee.Email},
+		Subject:        fmt.Sprintf("Declined: %s", counter.Summary),
+		Body:           body,
+		CalendarData:   declineData,
+		CalendarMethod: string(itip.MethodDeclineCounter),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send decline: %w", err)
+	}
+
+	fmt.Printf("Declined the proposal from: %s\n", attendee.Email)
+	fmt.Printf("  Meeting: %s\n", counter.Summary)
+	return nil
+}
+
+// InviteRefreshCmd asks the organizer for the latest version of a meeting.
+type InviteRefreshCmd struct {
+	File string `arg:"" help:"The invitation (.ics file or - for stdin)"`
+}
+
+// Run executes the invite refresh command.
+func (c *InviteRefreshCmd) Run(root *Root) error {
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return err
+	}
+	data, err := readInviteFile(c.File)
+	if err != nil {
+		return err
+	}
+	inv, err := itip.ParseInvite(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse invite: %w", err)
+	}
+	if inv.Organizer.Email == "" {
+		return fmt.Errorf("the invitation has no organizer")
+	}
+
+	refreshData, err := itip.CreateRefresh(inv.UID, inv.Organizer, itip.Participant{Email: email})
+	if err != nil {
+		return fmt.Errorf("failed to create refresh: %w", err)
+	}
+	err = sendITIPEmail(cfg, &smtp.Message{
+		From:           email,
+		To:             []string{inv.Organizer.Email},
+		Subject:        fmt.Sprintf("Refresh: %s", inv.Summary),
+		Body:           fmt.Sprintf("%s asks for the latest version of %s.", email, inv.Summary),
+		CalendarData:   refreshData,
+		CalendarMethod: string(itip.MethodRefresh),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send refresh: %w", err)
+	}
+
+	fmt.Printf("Asked %s for the latest version of: %s\n", inv.Organizer.Email, inv.Summary)
+	return nil
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/itip"
+)
+
+func TestUpdateAttendees(t *testing.T) {
+	inv := eventInvite(&caldav.Event{
+		UID:       "meeting@example.com",
+		Organizer: "alice@example.com",
+		Attendees: []string{"bob@example.com", "carol@example.com"},
+		PartStat:  caldav.PartStats{"bob@example.com": caldav.PartStatAccepted},
+	}, nil)
+	assert.Equal(t, itip.StatusAccepted, inv.Attendees[0].Status)
+	assert.False(t, inv.Attendees[0].RSVP)
+	assert.True(t, inv.Attendees[1].RSVP)
+
+	added, removed := updateAttendees(inv, []string{"dave@example.com", "BOB@example.com"}, []string{"Carol@example.com", "eve@example.com"})
+	assert.Equal(t, []string{"dave@example.com"}, added)
+	assert.Equal(t, []string{"carol@example.com"}, removed)
+	require.Len(t, inv.Attendees, 2)
+	assert.Equal(t, "bob@example.com", inv.Attendees[0].Email)
+	assert.Equal(t, itip.StatusNeedsAction, inv.Attendees[1].Status)
+}
+
+func TestEventInviteSeries(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	event := &caldav.Event{
+		UID:     "weekly@example.com",
+		Start:   start,
+		End:     start.Add(time.Hour),
+		RRule:   "FREQ=WEEKLY;COUNT=4",
+		ExDates: []time.Time{start.AddDate(0, 0, 14)},
+	}
+	moved := caldav.Event{UID: event.UID, RecurrenceID: start.AddDate(0, 0, 7), Start: start.AddDate(0, 0, 8)}
+	inv := eventInvite(event, []caldav.Event{moved})
+	assert.Equal(t, event.ExDates, inv.ExDates)
+	require.Len(t, inv.Overrides, 1)
+	assert.Equal(t, moved.RecurrenceID, inv.Overrides[0].Occurrence)
+
+	data, err := itip.CreateInvite(inv)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "EXDATE")
+	assert.Contains(t, string(data), "RECURRENCE-ID")
+}
+
+func TestEmailed(t *testing.T) {
+	to := []string{"bob@example.com", "Carol@example.com", "dave@example.com"}
+	assert.Equal(t, []string{"dave@example.com"}, emailed(to, []string{"carol@example.com", "bob@example.com"}))
+	assert.Equal(t, to, emailed(to, nil))
+}
+
+func TestInviteUpdateTimes(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	inv := &itip.Invite{Start: start, End: start.Add(90 * time.Minute)}
+
+	// A new start keeps the duration
+	c := &InviteUpdateCmd{Start: "2026-03-03 14:00"}
+	s, e, err := c.times(inv, time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 3, 14, 0, 0, 0, time.UTC), s)
+	assert.Equal(t, 90*time.Minute, e.Sub(s))
+
+	c = &InviteUpdateCmd{Duration: "30m"}
+	s, e, err = c.times(inv, time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, start, s)
+	assert.Equal(t, start.Add(30*time.Minute), e)
+
+	c = &InviteUpdateCmd{End: "2026-03-02 08:00"}
+	_, _, err = c.times(inv, time.UTC)
+	assert.Error(t, err)
+}
@@ -2,29 +2,34 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 
+	"github.com/visionik/sogcli/internal/carddav"
 	"github.com/visionik/sogcli/internal/config"
 	"github.com/visionik/sogcli/internal/imap"
 	"github.com/visionik/sogcli/internal/smtp"
+	"github.com/visionik/sogcli/internal/templates"
 )
 
 // MailCmd handles reading and sending mail.
 type MailCmd struct {
-	List    MailListCmd    `cmd:"" help:"List messages in a folder"`
-	Get     MailGetCmd     `cmd:"" help:"Get a message by UID"`
-	Search  MailSearchCmd  `cmd:"" help:"Search messages"`
-	Send    MailSendCmd    `cmd:"" help:"Send a message"`
-	Reply   MailReplyCmd   `cmd:"" help:"Reply to a message"`
-	Forward MailForwardCmd `cmd:"" help:"Forward a message"`
-	Move    MailMoveCmd    `cmd:"" help:"Move a message to another folder"`
-	Copy    MailCopyCmd    `cmd:"" help:"Copy a message to another folder"`
-	Flag    MailFlagCmd    `cmd:"" help:"Set a flag on a message"`
-	Unflag  MailUnflagCmd  `cmd:"" help:"Remove a flag from a message"`
-	Delete  MailDeleteCmd  `cmd:"" help:"Delete a message"`
+	List      MailListCmd      `cmd:"" help:"List messages in a folder"`
+	Get       MailGetCmd       `cmd:"" help:"Get a message by UID"`
+	Search    MailSearchCmd    `cmd:"" help:"Search messages"`
+	Send      MailSendCmd      `cmd:"" help:"Send a message"`
+	Reply     MailReplyCmd     `cmd:"" help:"Reply to a message"`
+	Forward   MailForwardCmd   `cmd:"" help:"Forward a message"`
+	Merge     MailMergeCmd     `cmd:"" help:"Send a personalized message to each recipient in a list"`
+	Move      MailMoveCmd      `cmd:"" help:"Move a message to another folder"`
+	Copy      MailCopyCmd      `cmd:"" help:"Copy a message to another folder"`
+	Flag      MailFlagCmd      `cmd:"" help:"Set a flag on a message"`
+	Unflag    MailUnflagCmd    `cmd:"" help:"Remove a flag from a message"`
+	Delete    MailDeleteCmd    `cmd:"" help:"Delete a message"`
+	Templates MailTemplatesCmd `cmd:"" help:"List message templates"`
 }
 
 // MailListCmd lists messages in a folder.
@@ -164,17 +169,47 @@ func (c *MailGetCmd) Run(root *Root) error {
 		return fmt.Errorf("failed to get message: %w", err)
 	}
 
+	// Decrypt and verify PGP and S/MIME messages unless the raw message
+	// was asked for
+	body := msg.Body
+	var secure *secureResult
+	if !c.Headers && !c.Raw && body != "" {
+		text, result, err := openSecureMessage(acct, []byte(body), msg.From)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else if result != nil {
+			body, secure = text, result
+		}
+	}
+
 	if root.JSON {
-		fmt.Printf(`{"uid":%d,"from":"%s","date":"%s","subject":"%s","body":"%s"}`+"\n",
-			msg.UID, msg.From, msg.Date, msg.Subject, msg.Body)
-	} else {
-		fmt.Printf("From: %s\n", msg.From)
-		fmt.Printf("Date: %s\n", msg.Date)
-		fmt.Printf("Subject: %s\n", msg.Subject)
-		if !c.Headers && msg.Body != "" {
-			fmt.Println("")
-			fmt.Println(msg.Body)
+		out := struct {
+			UID       uint32 `json:"uid"`
+			From      string `json:"from"`
+			Date      string `json:"date"`
+			Subject   string `json:"subject"`
+			Body      string `json:"body"`
+			Security  string `json:"security,omitempty"`
+			Encrypted bool   `json:"encrypted,omitempty"`
+			Signature any    `json:"signature,omitempty"`
+		}{UID: msg.UID, From: msg.From, Date: msg.Date, Subject: msg.Subject, Body: body}
+		if secure != nil {
+			out.Security = secure.Kind
+			out.Encrypted = secure.Encrypted
+			out.Signature = secure.signature()
 		}
+		return json.NewEncoder(os.Stdout).Encode(out)
+	}
+
+	fmt.Printf("From: %s\n", msg.From)
+	fmt.Printf("Date: %s\n", msg.Date)
+	fmt.Printf("Subject: %s\n", msg.Subject)
+	if secure != nil {
+		secure.print()
+	}
+	if !c.Headers && body != "" {
+		fmt.Println("")
+		fmt.Println(body)
 	}
 
 	return nil
@@ -265,12 +300,18 @@ func (c *MailSearchCmd) Run(root *Root) error {
 
 // MailSendCmd sends a message.
 type MailSendCmd struct {
-	To       string `help:"Recipients (comma-separated)" required:""`
-	Cc       string `help:"CC recipients (comma-separated)"`
-	Bcc      string `help:"BCC recipients (comma-separated)"`
-	Subject  string `help:"Subject line" required:""`
-	Body     string `help:"Body (plain text)"`
-	BodyFile string `help:"Body file path (plain text; '-' for stdin)" name:"body-file"`
+	To          string            `help:"Recipients (comma-separated)"`
+	Cc          string            `help:"CC recipients (comma-separated)"`
+	Bcc         string            `help:"BCC recipients (comma-separated)"`
+	Subject     string            `help:"Subject line"`
+	Body        string            `help:"Body (plain text)" xor:"body"`
+	BodyFile    string            `help:"Body file path (plain text; '-' for stdin)" name:"body-file" xor:"body"`
+	Template    string            `help:"Render body and headers from a template (see 'sog mail templates')" xor:"body"`
+	Var         map[string]string `help:"Template variable (key=value, repeatable)"`
+	From        string            `help:"Send as identity (address configured with 'sog auth identity add')"`
+	NoSignature bool              `help:"Don't append the identity's signature" name:"no-signature"`
+
+	SecurityFlags `embed:""`
 }
 
 // Run executes the mail send command.
@@ -313,14 +354,51 @@ func (c *MailSendCmd) Run(root *Root) error {
 		body = string(data)
 	}
 
-	if body == "" {
-		return fmt.Errorf("--body or --body-file is required")
-	}
-
 	// Parse comma-separated recipients
 	to := parseRecipients(c.To)
 	cc := parseRecipients(c.Cc)
 	bcc := parseRecipients(c.Bcc)
+	subject := c.Subject
+
+	identity, err := resolveIdentity(acct, c.From)
+	if err != nil {
+		return err
+	}
+
+	// Render template; explicit flags override its front-matter
+	if c.Template != "" {
+		rendered, err := renderTemplate(root, c.Template, c.Var, to, identity)
+		if err != nil {
+			return err
+		}
+		body = rendered.Body
+		if len(to) == 0 {
+			to = rendered.To
+		}
+		if len(cc) == 0 {
+			cc = rendered.Cc
+		}
+		if len(bcc) == 0 {
+			bcc = rendered.Bcc
+		}
+		if subject == "" {
+			subject = rendered.Subject
+		}
+	}
+
+	if len(to) == 0 {
+		return fmt.Errorf("--to is required")
+	}
+	if subject == "" {
+		return fmt.Errorf("--subject is required")
+	}
+	if body == "" {
+		return fmt.Errorf("--body, --body-file or --template is required")
+	}
+
+	if !c.NoSignature {
+		body = appendSignature(body, identity)
+	}
 
 	// Create SMTP client
 	smtpClient := smtp.NewClient(smtp.Config{
@@ -336,22 +414,138 @@ func (c *MailSendCmd) Run(root *Root) error {
 
 	// Send
 	msg := &smtp.Message{
-		From:    email,
 		To:      to,
 		Cc:      cc,
 		Bcc:     bcc,
-		Subject: c.Subject,
+		Subject: subject,
 		Body:    body,
 	}
+	applyIdentity(msg, identity)
+	msgs, err := protectMessages(acct, msg, identity, c.SecurityFlags)
+	if err != nil {
+		return err
+	}
 
-	if err := smtpClient.Send(context.Background(), msg); err != nil {
-		return fmt.Errorf("failed to send: %w", err)
+	for _, m := range msgs {
+		if err := smtpClient.Send(context.Background(), m); err != nil {
+			return fmt.Errorf("failed to send: %w", err)
+		}
 	}
 
 	fmt.Printf("Sent to %v\n", to)
 	return nil
 }
 
+// resolveIdentity returns the identity for --from, or the account default.
+func resolveIdentity(acct *config.Account, from string) (*config.Identity, error) {
+	if from == "" {
+		id := acct.DefaultIdentity()
+		return &id, nil
+	}
+	return acct.FindIdentity(from)
+}
+
+// applyIdentity sets the sender fields of msg from an identity.
+func applyIdentity(msg *smtp.Message, id *config.Identity) {
+	msg.From = id.Email
+	msg.FromName = id.Name
+	msg.ReplyTo = id.ReplyTo
+	if id.BccSelf {
+		msg.Bcc = append(msg.Bcc, id.Email)
+	}
+}
+
+// appendSignature appends an identity's signature to body, separated by
+// the conventional "-- " delimiter line.
+func appendSignature(body string, id *config.Identity) string {
+	sig := strings.TrimRight(id.Signature, "\n")
+	if sig == "" {
+		return body
+	}
+	body = strings.TrimRight(body, "\n")
+	if body == "" {
+		return "-- \n" + sig + "\n"
+	}
+	return body + "\n\n-- \n" + sig + "\n"
+}
+
+// renderTemplate loads and renders a message template. The template is
+// rendered once to learn its recipients, then again with the first
+// recipient's contact card when it can be found via CardDAV.
+func renderTemplate(root *Root, name string, vars map[string]string, to []string, id *config.Identity) (*templates.Rendered, error) {
+	tmpl, err := templates.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data := templates.Data{Vars: vars, From: *id}
+	recipients := to
+	if len(recipients) == 0 {
+		rendered, err := tmpl.Render(data)
+		if err != nil {
+			return nil, err
+		}
+		recipients = rendered.To
+	}
+	if len(recipients) == 0 {
+		return tmpl.Render(data)
+	}
+
+	data.Recipient = recipients[0]
+	lookup, closeLookup := newContactLookup(root)
+	defer closeLookup()
+	if contact := lookup(data.Recipient); contact != nil {
+		data.Contact = *contact
+		data.HasContact = true
+	}
+	return tmpl.Render(data)
+}
+
+// newContactLookup returns a function that finds contacts by email address,
+// and a function to call when done with it. The lookup returns nil when
+// CardDAV isn't configured or the address isn't a known contact.
+func newContactLookup(root *Root) (func(email string) *carddav.Contact, func()) {
+	client, bookPath, err := getCardDAVClient(root)
+	if err != nil {
+		return func(string) *carddav.Contact { return nil }, func() {}
+	}
+
+	lookup := func(email string) *carddav.Contact {
+		contact, err := client.FindContactByEmail(context.Background(), bookPath, email)
+		if err != nil {
+			return nil
+		}
+		return contact
+	}
+	return lookup, func() { client.Close() }
+}
+
+// replyRecipients returns the To and Cc lists for a reply. For reply-all,
+// the original To/Cc are kept minus the account's own addresses.
+func replyRecipients(acct *config.Account, original *imap.Message, all bool) (to, cc []string) {
+	to = []string{original.From}
+	if !all {
+		return to, nil
+	}
+
+	seen := map[string]bool{strings.ToLower(original.From): true}
+	add := func(list []string, addr string) []string {
+		key := strings.ToLower(addr)
+		if seen[key] || acct.IsOwnAddress(addr) {
+			return list
+		}
+		seen[key] = true
+		return append(list, addr)
+	}
+	for _, addr := range parseRecipients(original.To) {
+		to = add(to, addr)
+	}
+	for _, addr := range parseRecipients(original.Cc) {
+		cc = add(cc, addr)
+	}
+	return to, cc
+}
+
 // parseRecipients splits a comma-separated string into trimmed recipients.
 func parseRecipients(s string) []string {
 	if s == "" {
@@ -641,10 +835,15 @@ func (c *MailDeleteCmd) Run(root *Root) error {
 
 // MailReplyCmd replies to a message.
 type MailReplyCmd struct {
-	UID     uint32 `arg:"" help:"Message UID to reply to"`
-	Body    string `help:"Reply body (plain text)" required:""`
-	All     bool   `help:"Reply to all recipients" name:"all"`
-	Folder  string `help:"Folder containing the message" default:"INBOX"`
+	UID    uint32 `arg:"" help:"Message UID to reply to"`
+	Body   string `help:"Reply body (plain text)" required:""`
+	All    bool   `help:"Reply to all recipients" name:"all"`
+	Folder string `help:"Folder containing the message" default:"INBOX"`
+	From   string `help:"Send as identity (default: the identity the message was sent to)"`
+
+	NoSignature bool `help:"Don't append the identity's signature" name:"no-signature"`
+
+	SecurityFlags `embed:""`
 }
 
 // Run executes the mail reply command.
@@ -692,8 +891,25 @@ func (c *MailReplyCmd) Run(root *Root) error {
 		return fmt.Errorf("failed to get message: %w", err)
 	}
 
+	// Pick the identity the original was addressed to, unless --from is given
+	var identity *config.Identity
+	if c.From != "" {
+		identity, err = acct.FindIdentity(c.From)
+		if err != nil {
+			return err
+		}
+	} else {
+		addressed := append(parseRecipients(original.To), parseRecipients(original.Cc)...)
+		id := acct.MatchIdentity(addressed)
+		identity = &id
+	}
+
+	if isEncryptedMessage([]byte(original.Body)) && !c.Encrypt && !c.SMIMEEncrypt {
+		fmt.Fprintln(os.Stderr, "Warning: the original message was encrypted; use --encrypt to encrypt the reply")
+	}
+
 	// Build reply
-	to := original.From
+	to, cc := replyRecipients(acct, original, c.All)
 	subject := original.Subject
 	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
 		subject = "Re: " + subject
@@ -711,11 +927,20 @@ func (c *MailReplyCmd) Run(root *Root) error {
 		Password: password,
 	})
 
+	body := c.Body
+	if !c.NoSignature {
+		body = appendSignature(body, identity)
+	}
+
 	msg := &smtp.Message{
-		From:    email,
-		To:      []string{to},
+		To:      to,
+		Cc:      cc,
 		Subject: subject,
-		Body:    c.Body,
+		Body:    body,
+	}
+	applyIdentity(msg, identity)
+	if err := protectMessage(acct, msg, identity, c.SecurityFlags); err != nil {
+		return err
 	}
 
 	if err := smtpClient.Send(context.Background(), msg); err != nil {
@@ -725,7 +950,7 @@ func (c *MailReplyCmd) Run(root *Root) error {
 	// Mark original as answered
 	_ = imapClient.SetFlag(c.Folder, c.UID, "answered", true)
 
-	fmt.Printf("Replied to %s\n", to)
+	fmt.Printf("Replied to %s\n", strings.Join(append(to, cc...), ", "))
 	return nil
 }
 
@@ -735,6 +960,11 @@ type MailForwardCmd struct {
 	To     string `help:"Forward to (comma-separated)" required:""`
 	Body   string `help:"Additional message (plain text)"`
 	Folder string `help:"Folder containing the message" default:"INBOX"`
+	From   string `help:"Send as identity (address configured with 'sog auth identity add')"`
+
+	NoSignature bool `help:"Don't append the identity's signature" name:"no-signature"`
+
+	SecurityFlags `embed:""`
 }
 
 // Run executes the mail forward command.
@@ -762,6 +992,11 @@ func (c *MailForwardCmd) Run(root *Root) error {
 		return fmt.Errorf("failed to get password: %w", err)
 	}
 
+	identity, err := resolveIdentity(acct, c.From)
+	if err != nil {
+		return err
+	}
+
 	// Get original message
 	imapClient, err := imap.Connect(imap.Config{
 		Host:     acct.IMAP.Host,
@@ -782,6 +1017,20 @@ func (c *MailForwardCmd) Run(root *Root) error {
 		return fmt.Errorf("failed to get message: %w", err)
 	}
 
+	// Forward the readable text of encrypted messages, never in the clear
+	// unless asked to
+	originalBody := original.Body
+	if isSecureMessage([]byte(originalBody)) {
+		if isEncryptedMessage([]byte(originalBody)) && !c.Encrypt && !c.SMIMEEncrypt && !root.Force {
+			return fmt.Errorf("the original message is encrypted; use --encrypt or --smime-encrypt (or --force to forward it in the clear)")
+		}
+		text, _, err := openSecureMessage(acct, []byte(originalBody), original.From)
+		if err != nil {
+			return err
+		}
+		originalBody = text
+	}
+
 	// Build forwarded message
 	subject := original.Subject
 	if !strings.HasPrefix(strings.ToLower(subject), "fwd:") {
@@ -796,7 +1045,12 @@ func (c *MailForwardCmd) Run(root *Root) error {
 	body += fmt.Sprintf("From: %s\n", original.From)
 	body += fmt.Sprintf("Date: %s\n", original.Date)
 	body += fmt.Sprintf("Subject: %s\n\n", original.Subject)
-	body += original.Body
+	body += originalBody
+	// The signature goes last, so that the forwarded message is not read
+	// as part of it
+	if !c.NoSignature {
+		body = appendSignature(body, identity)
+	}
 
 	// Send via SMTP
 	smtpClient := smtp.NewClient(smtp.Config{
@@ -813,11 +1067,14 @@ func (c *MailForwardCmd) Run(root *Root) error {
 	to := parseRecipients(c.To)
 
 	msg := &smtp.Message{
-		From:    email,
 		To:      to,
 		Subject: subject,
 		Body:    body,
 	}
+	applyIdentity(msg, identity)
+	if err := protectMessage(acct, msg, identity, c.SecurityFlags); err != nil {
+		return err
+	}
 
 	if err := smtpClient.Send(context.Background(), msg); err != nil {
 		return fmt.Errorf("failed to send: %w", err)
@@ -826,3 +1083,31 @@ func (c *MailForwardCmd) Run(root *Root) error {
 	fmt.Printf("Forwarded to %v\n", to)
 	return nil
 }
+
+// MailTemplatesCmd lists message templates.
+type MailTemplatesCmd struct{}
+
+// Run executes the mail templates command.
+func (c *MailTemplatesCmd) Run(root *Root) error {
+	names, err := templates.List()
+	if err != nil {
+		return err
+	}
+
+	if root.JSON {
+		if names == nil {
+			names = []string{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(names)
+	}
+
+	if len(names) == 0 {
+		dir, _ := templates.Dir()
+		fmt.Printf("No templates found in %s\n", dir)
+		return nil
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
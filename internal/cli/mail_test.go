@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/imap"
+	"github.com/visionik/sogcli/internal/smtp"
 )
 
 func TestParseRecipients(t *testing.T) {
@@ -27,3 +30,56 @@ func TestParseRecipients(t *testing.T) {
 		})
 	}
 }
+
+func TestReplyRecipients(t *testing.T) {
+	acct := &config.Account{
+		Email:      "me@example.com",
+		Identities: []config.Identity{{Email: "alias@example.com"}},
+	}
+	original := &imap.Message{
+		From: "bob@example.com",
+		To:   "alias@example.com, carol@example.com",
+		Cc:   "me@example.com, dave@example.com, bob@example.com",
+	}
+
+	to, cc := replyRecipients(acct, original, false)
+	assert.Equal(t, []string{"bob@example.com"}, to)
+	assert.Nil(t, cc)
+
+	to, cc = replyRecipients(acct, original, true)
+	assert.Equal(t, []string{"bob@example.com", "carol@example.com"}, to)
+	assert.Equal(t, []string{"dave@example.com"}, cc)
+}
+
+func TestApplyIdentity(t *testing.T) {
+	msg := &smtp.Message{Bcc: []string{"x@example.com"}}
+	applyIdentity(msg, &config.Identity{
+		Email:   "alias@example.com",
+		Name:    "Alias",
+		ReplyTo: "reply@example.com",
+		BccSelf: true,
+	})
+
+	assert.Equal(t, "alias@example.com", msg.From)
+	assert.Equal(t, "Alias", msg.FromName)
+	assert.Equal(t, "reply@example.com", msg.ReplyTo)
+	assert.Equal(t, []string{"x@example.com", "alias@example.com"}, msg.Bcc)
+}
+
+func TestAppendSignature(t *testing.T) {
+	id := &config.Identity{Email: "me@example.com", Signature: "Me\nExample Inc.\n"}
+
+	assert.Equal(t, "Hello\n\n-- \nMe\nExample Inc.\n", appendSignature("Hello\n\n", id))
+	assert.Equal(t, "-- \nMe\nExample Inc.\n", appendSignature("", id))
+	assert.Equal(t, "Hello", appendSignature("Hello", &config.Identity{Email: "me@example.com"}))
+}
+
+func TestEncryptionRecipients(t *testing.T) {
+	msg := &smtp.Message{
+		To:  []string{"a@example.com", "B@example.com"},
+		Cc:  []string{"b@example.com"},
+		Bcc: []string{"me@example.com"},
+	}
+	got := encryptionRecipients(msg, &config.Identity{Email: "me@example.com"})
+	assert.Equal(t, []string{"a@example.com", "B@example.com", "me@example.com"}, got)
+}
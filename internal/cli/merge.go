@@ -0,0 +1,502 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/visionik/sogcli/internal/carddav"
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/smtp"
+	"github.com/visionik/sogcli/internal/templates"
+)
+
+// MailMergeCmd sends a personalized message to each recipient in a list.
+type MailMergeCmd struct {
+	Template      string            `help:"Template to render for each recipient (see 'sog mail templates')" required:""`
+	Recipients    string            `help:"CSV file with an 'email' column; other columns become template variables ('-' for stdin)" xor:"source" required:""`
+	ContactsGroup string            `help:"Send to a contacts group (category or group card)" name:"contacts-group" xor:"source" required:""`
+	AddressBook   string            `help:"Address book path for --contacts-group (default: primary)" name:"addressbook"`
+	Var           map[string]string `help:"Template variable for every message (key=value, repeatable)"`
+	Subject       string            `help:"Subject line (overrides the template)"`
+	From          string            `help:"Send as identity (address configured with 'sog auth identity add')"`
+	NoSignature   bool              `help:"Don't append the identity's signature" name:"no-signature"`
+	Throttle      time.Duration     `help:"Delay between messages" default:"1s"`
+	Log           string            `help:"Send log for resuming (JSONL; default: ~/.config/sog/merge/<template>-<source>.jsonl)"`
+	Preview       int               `help:"Print the first N rendered messages without sending"`
+
+	SecurityFlags `embed:""`
+}
+
+// mergeRecipient is one row of a mail merge.
+type mergeRecipient struct {
+	Email   string
+	Vars    map[string]string
+	Contact *carddav.Contact
+}
+
+// mergeLogEntry is one line of the merge send log.
+type mergeLogEntry struct {
+	Time      time.Time `json:"time"`
+	Recipient string    `json:"recipient"`
+	Status    string    `json:"status"` // sent or failed
+	Error     string    `json:"error,omitempty"`
+}
+
+// Run executes the mail merge command.
+func (c *MailMergeCmd) Run(root *Root) error {
+	if c.Throttle < 0 {
+		return fmt.Errorf("--throttle must not be negative")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	email := root.Account
+	if email == "" {
+		email = cfg.DefaultAccount
+	}
+	if email == "" {
+		return fmt.Errorf("no account specified. Use --account or set a default")
+	}
+
+	acct, err := cfg.GetAccount(email)
+	if err != nil {
+		return err
+	}
+
+	identity, err := resolveIdentity(acct, c.From)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := templates.Load(c.Template)
+	if err != nil {
+		return err
+	}
+
+	recipients, source, err := c.loadRecipients(root)
+	if err != nil {
+		return err
+	}
+
+	logPath := c.Log
+	if logPath == "" {
+		logPath, err = defaultMergeLogPath(c.Template, source)
+		if err != nil {
+			return err
+		}
+	}
+
+	sent, err := readMergeLog(logPath)
+	if err != nil {
+		return err
+	}
+
+	pending := make([]mergeRecipient, 0, len(recipients))
+	for _, r := range recipients {
+		if !sent[strings.ToLower(r.Email)] {
+			pending = append(pending, r)
+		}
+	}
+	skipped := len(recipients) - len(pending)
+
+	// Contact fields come from CardDAV for CSV rows
+	lookup := func(string) *carddav.Contact { return nil }
+	if c.Recipients != "" {
+		var closeLookup func()
+		lookup, closeLookup = newContactLookup(root)
+		defer closeLookup()
+	}
+
+	warned := false
+	render := func(r mergeRecipient) (*smtp.Message, error) {
+		msg, copies, err := c.render(tmpl, r, identity, lookup)
+		if len(copies) > 0 && !warned {
+			warned = true
+			fmt.Fprintf(os.Stderr, "Warning: ignoring the template's Cc/Bcc (%s); each message goes to its recipient only\n", strings.Join(copies, ", "))
+		}
+		return msg, err
+	}
+
+	if c.Preview > 0 {
+		return c.preview(root, pending, identity, render)
+	}
+
+	if len(pending) == 0 {
+		fmt.Printf("Nothing to send: all %d recipients already sent (log: %s)\n", len(recipients), logPath)
+		return nil
+	}
+
+	password, err := cfg.GetPassword(email)
+	if err != nil {
+		return fmt.Errorf("failed to get password: %w", err)
+	}
+
+	smtpClient := smtp.NewClient(smtp.Config{
+		Host:     acct.SMTP.Host,
+		Port:     acct.SMTP.Port,
+		TLS:      acct.SMTP.TLS,
+		StartTLS: acct.SMTP.StartTLS,
+		Insecure: acct.SMTP.Insecure,
+		NoTLS:    acct.SMTP.NoTLS,
+		Email:    email,
+		Password: password,
+	})
+
+	if err := os.MkdirAll(filepath.Dir(logPath), 0700); err != nil {
+		return fmt.Errorf("failed to create log dir: %w", err)
+	}
+	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	defer logFile.Close()
+	logEnc := json.NewEncoder(logFile)
+
+	ctx := context.Background()
+	var session *smtp.Session
+	closeSession := func() {
+		if session != nil {
+			session.Close()
+			session = nil
+		}
+	}
+	defer closeSession()
+
+	var nSent, nFailed int
+	for i, r := range pending {
+		if i > 0 && c.Throttle > 0 {
+			time.Sleep(c.Throttle)
+		}
+
+		entry := mergeLogEntry{Recipient: r.Email, Status: "sent"}
+		msg, err := render(r)
+		if err == nil {
+			err = protectMessage(acct, msg, identity, c.SecurityFlags)
+		}
+		if err == nil {
+			if session == nil {
+				session, err = smtpClient.Dial(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to connect (sent %d, rerun to resume): %w", nSent, err)
+				}
+			}
+			err = session.Send(msg)
+			if smtp.IsConnectionError(err) {
+				// Reconnect for the next message
+				closeSession()
+			}
+		}
+
+		entry.Time = time.Now()
+		if err != nil {
+			entry.Status = "failed"
+			entry.Error = err.Error()
+			nFailed++
+			fmt.Fprintf(os.Stderr, "Failed %s: %v\n", r.Email, err)
+		} else {
+			nSent++
+			if !root.JSON {
+				fmt.Printf("Sent to %s (%d/%d)\n", r.Email, i+1, len(pending))
+			}
+		}
+		if err := logEnc.Encode(entry); err != nil {
+			return fmt.Errorf("failed to write log: %w", err)
+		}
+	}
+
+	if root.JSON {
+		return json.NewEncoder(os.Stdout).Encode(map[string]any{
+			"sent":    nSent,
+			"failed":  nFailed,
+			"skipped": skipped,
+			"log":     logPath,
+		})
+	}
+
+	fmt.Printf("Sent %d, failed %d, skipped %d already sent. Log: %s\n", nSent, nFailed, skipped, logPath)
+	if nFailed > 0 {
+		fmt.Println("Rerun the same command to retry failed recipients.")
+	}
+	return nil
+}
+
+// loadRecipients reads recipients from the CSV file or contacts group and
+// returns them with a short name for the source.
+func (c *MailMergeCmd) loadRecipients(root *Root) ([]mergeRecipient, string, error) {
+	if c.Recipients != "" {
+		var r io.Reader = os.Stdin
+		if c.Recipients != "-" {
+			f, err := os.Open(c.Recipients)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to open recipients: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+		recipients, err := readRecipientsCSV(r)
+		if err != nil {
+			return nil, "", err
+		}
+		source := strings.TrimSuffix(filepath.Base(c.Recipients), filepath.Ext(c.Recipients))
+		if c.Recipients == "-" {
+			source = "stdin"
+		}
+		return uniqueRecipients(recipients), source, nil
+	}
+
+	client, bookPath, err := getCardDAVClient(root)
+	if err != nil {
+		return nil, "", err
+	}
+	defer client.Close()
+
+	if c.AddressBook != "" {
+		bookPath = c.AddressBook
+	}
+
+	contacts, err := client.ListGroup(context.Background(), bookPath, c.ContactsGroup)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list group: %w", err)
+	}
+
+	recipients := make([]mergeRecipient, 0, len(contacts))
+	for i := range contacts {
+		contact := &contacts[i]
+		if len(contact.Emails) == 0 {
+			fmt.Fprintf(os.Stderr, "Skipping %s: no email address\n", contact.FullName)
+			continue
+		}
+		recipients = append(recipients, mergeRecipient{Email: contact.Emails[0], Contact: contact})
+	}
+	return uniqueRecipients(recipients), c.ContactsGroup, nil
+}
+
+// uniqueRecipients drops recipients whose address (in any case) is listed
+// before, so that no one gets the message twice.
+func uniqueRecipients(recipients []mergeRecipient) []mergeRecipient {
+	seen := map[string]bool{}
+	unique := recipients[:0]
+	for _, r := range recipients {
+		key := strings.ToLower(r.Email)
+		if seen[key] {
+			fmt.Fprintf(os.Stderr, "Skipping %s: listed more than once\n", r.Email)
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, r)
+	}
+	return unique
+}
+
+// render builds the message for one recipient, with the template's Cc and
+// Bcc addresses it leaves out. Template To, Cc and Bcc are ignored; each
+// message goes to its recipient only, so others don't get one copy per
+// recipient.
+func (c *MailMergeCmd) render(tmpl *templates.Template, r mergeRecipient, identity *config.Identity, lookup func(string) *carddav.Contact) (*smtp.Message, []string, error) {
+	vars := make(map[string]string, len(c.Var)+len(r.Vars))
+	for k, v := range c.Var {
+		vars[k] = v
+	}
+	for k, v := range r.Vars {
+		vars[k] = v
+	}
+
+	data := templates.Data{Vars: vars, Recipient: r.Email, From: *identity}
+	contact := r.Contact
+	if contact == nil {
+		contact = lookup(r.Email)
+	}
+	if contact != nil {
+		data.Contact = *contact
+		data.HasContact = true
+	}
+
+	rendered, err := tmpl.Render(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subject := c.Subject
+	if subject == "" {
+		subject = rendered.Subject
+	}
+	if subject == "" {
+		return nil, nil, fmt.Errorf("no subject: set it in the template or with --subject")
+	}
+
+	body := rendered.Body
+	if !c.NoSignature {
+		body = appendSignature(body, identity)
+	}
+
+	msg := &smtp.Message{
+		To:      []string{r.Email},
+		Subject: subject,
+		Body:    body,
+	}
+	applyIdentity(msg, identity)
+	return msg, append(rendered.Cc, rendered.Bcc...), nil
+}
+
+// preview prints the first rendered messages without sending.
+func (c *MailMergeCmd) preview(root *Root, pending []mergeRecipient, identity *config.Identity, render func(mergeRecipient) (*smtp.Message, error)) error {
+	n := c.Preview
+	if n > len(pending) {
+		n = len(pending)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for i, r := range pending[:n] {
+		msg, err := render(r)
+		if err != nil {
+			return fmt.Errorf("failed to render for %s: %w", r.Email, err)
+		}
+
+		if root.JSON {
+			if err := enc.Encode(map[string]any{
+				"from":    msg.From,
+				"to":      msg.To,
+				"cc":      msg.Cc,
+				"bcc":     msg.Bcc,
+				"subject": msg.Subject,
+				"body":    msg.Body,
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fmt.Printf("=== %d/%d: %s ===\n", i+1, n, r.Email)
+		fmt.Printf("From: %s\n", identity.Email)
+		fmt.Printf("To: %s\n", strings.Join(msg.To, ", "))
+		if len(msg.Cc) > 0 {
+			fmt.Printf("Cc: %s\n", strings.Join(msg.Cc, ", "))
+		}
+		if len(msg.Bcc) > 0 {
+			fmt.Printf("Bcc: %s\n", strings.Join(msg.Bcc, ", "))
+		}
+		fmt.Printf("Subject: %s\n\n", msg.Subject)
+		fmt.Println(strings.TrimRight(msg.Body, "\n"))
+		fmt.Println()
+	}
+
+	if !root.JSON {
+		fmt.Printf("Previewed %d of %d pending messages (nothing sent)\n", n, len(pending))
+	}
+	return nil
+}
+
+// readRecipientsCSV parses a CSV with a header row. One column must be the
+// email address ("email", "e-mail" or "mail"); every column, including the
+// email, becomes a template variable keyed by its header.
+func readRecipientsCSV(r io.Reader) ([]mergeRecipient, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("recipients CSV is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipients CSV: %w", err)
+	}
+
+	emailCol := -1
+	for i, h := range header {
+		h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		header[i] = h
+		switch strings.ToLower(h) {
+		case "email", "e-mail", "mail":
+			if emailCol < 0 {
+				emailCol = i
+			}
+		}
+	}
+	if emailCol < 0 {
+		return nil, fmt.Errorf("recipients CSV needs an 'email' column")
+	}
+
+	var recipients []mergeRecipient
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recipients CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		email := strings.TrimSpace(row[emailCol])
+		if email == "" {
+			return nil, fmt.Errorf("recipients CSV line %d: missing email", line)
+		}
+
+		vars := make(map[string]string, len(header))
+		for i, h := range header {
+			if h != "" {
+				vars[h] = strings.TrimSpace(row[i])
+			}
+		}
+		recipients = append(recipients, mergeRecipient{Email: email, Vars: vars})
+	}
+	return recipients, nil
+}
+
+// readMergeLog returns the recipients already sent according to the log,
+// keyed by lowercased address. A missing log is not an error.
+func readMergeLog(path string) (map[string]bool, error) {
+	sent := map[string]bool{}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return sent, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry mergeLogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse log %s: %w", path, err)
+		}
+		if entry.Status == "sent" {
+			sent[strings.ToLower(entry.Recipient)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log: %w", err)
+	}
+	return sent, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// defaultMergeLogPath returns the log path for a template and recipient source.
+func defaultMergeLogPath(template, source string) (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	name := unsafeFileChars.ReplaceAllString(filepath.Base(template)+"-"+source, "_")
+	return filepath.Join(dir, "merge", name+".jsonl"), nil
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/carddav"
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/templates"
+)
+
+func TestReadRecipientsCSV(t *testing.T) {
+	csv := "Name, Email ,team\nAlice,alice@example.com,red\n\nBob, bob@example.com ,blue\n"
+
+	recipients, err := readRecipientsCSV(strings.NewReader(csv))
+	require.NoError(t, err)
+	require.Len(t, recipients, 2)
+	assert.Equal(t, "alice@example.com", recipients[0].Email)
+	assert.Equal(t, map[string]string{"Name": "Alice", "Email": "alice@example.com", "team": "red"}, recipients[0].Vars)
+	assert.Equal(t, "bob@example.com", recipients[1].Email)
+
+	_, err = readRecipientsCSV(strings.NewReader("name\nAlice\n"))
+	assert.ErrorContains(t, err, "'email' column")
+
+	_, err = readRecipientsCSV(strings.NewReader("name,email\nAlice,\n"))
+	assert.ErrorContains(t, err, "line 2: missing email")
+
+	_, err = readRecipientsCSV(strings.NewReader(""))
+	assert.ErrorContains(t, err, "empty")
+}
+
+func TestUniqueRecipients(t *testing.T) {
+	recipients, err := readRecipientsCSV(strings.NewReader("name,email\nAlice,alice@example.com\nBob,bob@example.com\nAlice again,ALICE@example.com\n"))
+	require.NoError(t, err)
+	unique := uniqueRecipients(recipients)
+	require.Len(t, unique, 2)
+	assert.Equal(t, "Alice", unique[0].Vars["name"])
+	assert.Equal(t, "bob@example.com", unique[1].Email)
+}
+
+func TestReadMergeLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "merge.jsonl")
+
+	sent, err := readMergeLog(path)
+	require.NoError(t, err)
+	assert.Empty(t, sent)
+
+	log := `{"time":"2026-01-01T00:00:00Z","recipient":"Alice@example.com","status":"sent"}
+{"time":"2026-01-01T00:00:01Z","recipient":"bob@example.com","status":"failed","error":"550"}
+`
+	require.NoError(t, os.WriteFile(path, []byte(log), 0600))
+
+	sent, err = readMergeLog(path)
+	require.NoError(t, err)
+	assert.True(t, sent["alice@example.com"])
+	assert.False(t, sent["bob@example.com"])
+}
+
+func TestMergeRender(t *testing.T) {
+	tmpl, err := templates.Parse("announce", "---\nSubject: News for {{.Vars.team}}\nTo: ignored@example.com\nCc: {{.From.Email}}\n---\nHi {{default .Vars.Name .Contact.FirstName}}, release {{.Vars.version}}.\n")
+	require.NoError(t, err)
+
+	cmd := &MailMergeCmd{Var: map[string]string{"version": "1.2", "team": "default"}}
+	identity := &config.Identity{Email: "me@example.com", Signature: "Me"}
+	noLookup := func(string) *carddav.Contact { return nil }
+
+	msg, copies, err := cmd.render(tmpl, mergeRecipient{
+		Email: "alice@example.com",
+		Vars:  map[string]string{"Name": "Alice", "team": "red"},
+	}, identity, noLookup)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice@example.com"}, msg.To)
+	assert.Empty(t, msg.Cc)
+	assert.Equal(t, []string{"me@example.com"}, copies)
+	assert.Equal(t, "News for red", msg.Subject)
+	assert.Equal(t, "Hi Alice, release 1.2.\n\n-- \nMe\n", msg.Body)
+	assert.Equal(t, "me@example.com", msg.From)
+
+	// Contact fields win over CSV columns where the template prefers them
+	lookup := func(string) *carddav.Contact { return &carddav.Contact{FirstName: "Ally"} }
+	cmd.NoSignature = true
+	msg, _, err = cmd.render(tmpl, mergeRecipient{
+		Email: "alice@example.com",
+		Vars:  map[string]string{"Name": "Alice"},
+	}, identity, lookup)
+	require.NoError(t, err)
+	assert.Equal(t, "News for default", msg.Subject)
+	assert.Equal(t, "Hi Ally, release 1.2.\n", msg.Body)
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/config"
+)
+
+// conflictHorizon is how far ahead the occurrences of a new recurring
+// event are checked for conflicts.
+const conflictHorizon = 90 * 24 * time.Hour
+
+// checkConflicts looks for events in all of the account's calendars that
+// overlap event and lists them on stderr. With refuse, an overlap is an
+// error. Cancelled and transparent events do not count.
+func checkConflicts(root *Root, event *caldav.Event, refuse bool) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !hasCalDAV(cfg, root) {
+		return nil
+	}
+
+	occurrences, err := caldav.Occurrences(event, event.Start, event.Start.Add(conflictHorizon))
+	if err != nil {
+		return err
+	}
+	if len(occurrences) == 0 {
+		return nil
+	}
+	start, end := occurrences[0].Start, eventEnd(occurrences[len(occurrences)-1])
+	if !end.After(start) {
+		end = start.Add(time.Minute)
+	}
+
+	events, _, err := agendaEvents(root, AgendaFlags{AllCalendars: true}, start, end)
+	if err != nil {
+		if refuse {
+			return fmt.Errorf("failed to check for conflicts: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: could not check for conflicts: %v\n", err)
+		return nil
+	}
+	conflicts := caldav.Conflicts(occurrences, calDAVEvents(events))
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: %q overlaps %d event(s):\n", event.Summary, len(conflicts))
+	for _, e := range conflicts {
+		fmt.Fprintf(os.Stderr, "  %s\n", describeEvent(&e, start.Location()))
+	}
+	if refuse {
+		return fmt.Errorf("not created: the time is already booked (drop --no-conflicts to double-book)")
+	}
+	return nil
+}
+
+// calDAVEvents drops the events of subscriptions, which are not the
+// user's own bookings.
+func calDAVEvents(events []caldav.Event) []caldav.Event {
+	var out []caldav.Event
+	for _, e := range events {
+		if e.Account != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// describeEvent formats an event on one line: date, time, summary and
+// calendar.
+func describeEvent(e *caldav.Event, loc *time.Location) string {
+	when := e.Start.Format("2006-01-02 Mon") + " all-day    "
+	if !e.AllDay {
+		start := e.Start.In(loc)
+		when = start.Format("2006-01-02 Mon 15:04") + "-" + eventEnd(*e).In(loc).Format("15:04")
+	}
+	text := when + "  " + e.Summary
+	if e.Source != "" {
+		text += " [" + e.Source + "]"
+	}
+	return text
+}
+
+// CalConflictsCmd reports events that overlap each other.
+type CalConflictsCmd struct {
+	From string `help:"Start date" default:"today"`
+	To   string `help:"End date" default:"+30d"`
+	AgendaFlags
+}
+
+// overlapJSON is an overlap in JSON output.
+type overlapJSON struct {
+	Start string       `json:"start"`
+	End   string       `json:"end"`
+	A     caldav.Event `json:"a"`
+	B     caldav.Event `json:"b"`
+}
+
+// Run executes the cal conflicts command.
+func (c *CalConflictsCmd) Run(root *Root) error {
+	display, err := root.Cal.displayZone()
+	if err != nil {
+		return err
+	}
+	start, err := parseDate(c.From, display)
+	if err != nil {
+		return fmt.Errorf("invalid --from date: %w", err)
+	}
+	end, err := parseDate(c.To, display)
+	if err != nil {
+		return fmt.Errorf("invalid --to date: %w", err)
+	}
+
+	// All calendars unless some are named; subscriptions only if named
+	flags := c.AgendaFlags
+	if len(flags.Calendars) == 0 {
+		flags.AllCalendars = true
+	}
+	events, _, err := agendaEvents(root, flags, start, end)
+	if err != nil {
+		return err
+	}
+	if len(c.Calendars) == 0 {
+		events = calDAVEvents(events)
+	}
+	overlaps := caldav.FindOverlaps(inZone(events, display))
+
+	if len(overlaps) == 0 {
+		if !root.JSON {
+			fmt.Println("No conflicts found.")
+		}
+		return nil
+	}
+
+	switch {
+	case root.JSON:
+		for _, o := range overlaps {
+			data, err := json.Marshal(overlapJSON{
+				Start: o.Start.Format(time.RFC3339),
+				End:   o.End.Format(time.RFC3339),
+				A:     o.A,
+				B:     o.B,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		}
+	case root.Plain:
+		clean := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+		for _, o := range overlaps {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n", o.Start.Format(time.RFC3339), o.End.Format(time.RFC3339),
+				o.A.UID, clean.Replace(o.A.Summary), o.B.UID, clean.Replace(o.B.Summary))
+		}
+	default:
+		for i, o := range overlaps {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s %s-%s overlap:\n", o.Start.Format("2006-01-02 Mon"), o.Start.Format("15:04"), o.End.Format("15:04"))
+			fmt.Printf("  %s\n  %s\n", describeEvent(&o.A, display), describeEvent(&o.B, display))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+func TestDescribeEvent(t *testing.T) {
+	e := caldav.Event{
+		Summary: "Standup",
+		Start:   time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+		End:     time.Date(2026, 10, 19, 9, 15, 0, 0, time.UTC),
+		Source:  "Work",
+	}
+	assert.Equal(t, "2026-10-19 Mon 09:00-09:15  Standup [Work]", describeEvent(&e, time.UTC))
+
+	e = caldav.Event{Summary: "Offsite", Start: time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), AllDay: true}
+	assert.Equal(t, "2026-10-20 Tue all-day      Offsite", describeEvent(&e, time.UTC))
+}
+
+func TestCalDAVEvents(t *testing.T) {
+	events := []caldav.Event{
+		{UID: "a", Account: "me@example.com"},
+		{UID: "holiday", Source: "holidays"},
+	}
+	assert.Equal(t, events[:1], calDAVEvents(events))
+}
+
+func TestCheckConflictsWithoutCalDAV(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	event := &caldav.Event{UID: "new", Start: time.Now(), End: time.Now().Add(time.Hour)}
+	assert.NoError(t, checkConflicts(&Root{}, event, true))
+}
@@ -0,0 +1,322 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/pgp"
+	"github.com/visionik/sogcli/internal/smtp"
+)
+
+// PGPCmd manages OpenPGP keys and settings.
+type PGPCmd struct {
+	List   PGPListCmd   `cmd:"" aliases:"ls" default:"1" help:"List keys in the sog keyring"`
+	Import PGPImportCmd `cmd:"" help:"Import public or secret keys"`
+	Export PGPExportCmd `cmd:"" help:"Export a public key (armored)"`
+	Delete PGPDeleteCmd `cmd:"" aliases:"rm" help:"Delete a key"`
+	Lookup PGPLookupCmd `cmd:"" help:"Look up a public key via WKD"`
+	Config PGPConfigCmd `cmd:"" help:"Show or change OpenPGP settings for the account"`
+}
+
+// PGPListCmd lists keys.
+type PGPListCmd struct{}
+
+// Run executes the pgp list command.
+func (c *PGPListCmd) Run(root *Root) error {
+	k, err := openPGPKeyring("")
+	if err != nil {
+		return err
+	}
+
+	keys := k.List()
+	if root.JSON {
+		if keys == nil {
+			keys = []pgp.KeyInfo{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(keys)
+	}
+
+	if len(keys) == 0 {
+		fmt.Println("No keys. Import one with: sog pgp import <file>")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tFINGERPRINT\tUSER IDS")
+	for _, key := range keys {
+		kind := "pub"
+		if key.Secret {
+			kind = "sec"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", kind, key.Fingerprint, strings.Join(key.UserIDs, ", "))
+	}
+	return w.Flush()
+}
+
+// PGPImportCmd imports keys.
+type PGPImportCmd struct {
+	File string `arg:"" help:"Key file (armored or binary; '-' for stdin)"`
+}
+
+// Run executes the pgp import command.
+func (c *PGPImportCmd) Run(root *Root) error {
+	k, err := openPGPKeyring("")
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if c.File != "-" {
+		f, err := os.Open(c.File)
+		if err != nil {
+			return fmt.Errorf("failed to open key file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	infos, err := k.Import(r)
+	if err != nil {
+		return fmt.Errorf("failed to import keys: %w", err)
+	}
+	printImported(infos)
+	return nil
+}
+
+// printImported prints a summary of imported keys.
+func printImported(infos []pgp.KeyInfo) {
+	for _, info := range infos {
+		kind := "public"
+		if info.Secret {
+			kind = "secret"
+		}
+		fmt.Printf("Imported %s key %s %s\n", kind, info.Fingerprint, strings.Join(info.UserIDs, ", "))
+	}
+}
+
+// PGPExportCmd exports a public key.
+type PGPExportCmd struct {
+	Key string `arg:"" help:"Email, key ID or fingerprint"`
+}
+
+// Run executes the pgp export command.
+func (c *PGPExportCmd) Run(root *Root) error {
+	k, err := openPGPKeyring("")
+	if err != nil {
+		return err
+	}
+
+	data, err := k.Export(c.Key)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// PGPDeleteCmd deletes a key.
+type PGPDeleteCmd struct {
+	Key string `arg:"" help:"Email, key ID or fingerprint"`
+}
+
+// Run executes the pgp delete command.
+func (c *PGPDeleteCmd) Run(root *Root) error {
+	k, err := openPGPKeyring("")
+	if err != nil {
+		return err
+	}
+
+	if err := k.Delete(c.Key); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted key %s\n", c.Key)
+	return nil
+}
+
+// PGPLookupCmd looks up a key via WKD.
+type PGPLookupCmd struct {
+	Email  string `arg:"" help:"Email address"`
+	Import bool   `help:"Import the key into the sog keyring"`
+}
+
+// Run executes the pgp lookup command.
+func (c *PGPLookupCmd) Run(root *Root) error {
+	data, err := pgp.LookupWKD(context.Background(), nil, c.Email)
+	if err != nil {
+		return err
+	}
+
+	keys, err := pgp.ReadKeys(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	if c.Import {
+		k, err := openPGPKeyring("")
+		if err != nil {
+			return err
+		}
+		infos, err := k.Import(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to import key: %w", err)
+		}
+		printImported(infos)
+		return nil
+	}
+
+	for _, e := range keys {
+		names := make([]string, 0, len(e.Identities))
+		for name := range e.Identities {
+			names = append(names, name)
+		}
+		fmt.Printf("Found key %X %s\n", e.PrimaryKey.Fingerprint, strings.Join(names, ", "))
+	}
+	fmt.Println("Use --import to add it to the keyring.")
+	return nil
+}
+
+// PGPConfigCmd shows or changes OpenPGP settings.
+type PGPConfigCmd struct {
+	Backend string `help:"Key backend: keyring (sog keyring) or gpg (GnuPG)" enum:",keyring,gpg" default:""`
+	GPGPath string `help:"gpg binary for the gpg backend" name:"gpg-path"`
+	WKD     *bool  `help:"Look up missing recipient keys via WKD when encrypting" name:"wkd" negatable:""`
+}
+
+// Run executes the pgp config command.
+func (c *PGPConfigCmd) Run(root *Root) error {
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return err
+	}
+	acct := cfg.Accounts[email]
+
+	changed := false
+	if c.Backend != "" {
+		acct.PGP.Backend = c.Backend
+		changed = true
+	}
+	if c.GPGPath != "" {
+		acct.PGP.GPGPath = c.GPGPath
+		changed = true
+	}
+	if c.WKD != nil {
+		acct.PGP.WKD = *c.WKD
+		changed = true
+	}
+
+	if changed {
+		cfg.Accounts[email] = acct
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+	}
+
+	if root.JSON {
+		return json.NewEncoder(os.Stdout).Encode(acct.PGP)
+	}
+
+	backend := acct.PGP.Backend
+	if backend == "" {
+		backend = "keyring"
+	}
+	fmt.Printf("Account: %s\n", email)
+	fmt.Printf("Backend: %s\n", backend)
+	if backend == "gpg" {
+		path := acct.PGP.GPGPath
+		if path == "" {
+			path = "gpg"
+		}
+		fmt.Printf("GPG:     %s\n", path)
+	}
+	fmt.Printf("WKD:     %v\n", acct.PGP.WKD)
+	return nil
+}
+
+// openPGPKeyring opens the sog keyring. Locked secret keys are unlocked
+// with the passphrase stored for email, if any.
+func openPGPKeyring(email string) (*pgp.Keyring, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := pgp.OpenKeyring(filepath.Join(dir, "pgp"))
+	if err != nil {
+		return nil, err
+	}
+	if email != "" {
+		k.Passphrase = func() (string, error) {
+			return config.GetPassphrase(email, config.ProtocolPGP)
+		}
+	}
+	return k, nil
+}
+
+// newPGPBackend returns the OpenPGP backend configured for an account.
+func newPGPBackend(acct *config.Account) (pgp.Backend, error) {
+	switch acct.PGP.Backend {
+	case "", "keyring":
+		return openPGPKeyring(acct.Email)
+	case "gpg":
+		return &pgp.GPG{Path: acct.PGP.GPGPath, WKD: acct.PGP.WKD}, nil
+	default:
+		return nil, fmt.Errorf("unknown PGP backend: %s", acct.PGP.Backend)
+	}
+}
+
+// protectPGP signs and/or encrypts msg with PGP/MIME.
+func protectPGP(acct *config.Account, msg *smtp.Message, id *config.Identity, sign, encrypt bool) error {
+	backend, err := newPGPBackend(acct)
+	if err != nil {
+		return err
+	}
+
+	signer := ""
+	if sign {
+		signer = id.PGPKey
+		if signer == "" {
+			signer = id.Email
+		}
+	}
+
+	entity := smtp.BuildEntity(msg)
+	if !encrypt {
+		msg.Entity, err = pgp.SignMIME(backend, entity, signer)
+		return err
+	}
+
+	recipients := encryptionRecipients(msg, id)
+	if k, ok := backend.(*pgp.Keyring); ok && acct.PGP.WKD {
+		fetchWKDKeys(k, recipients)
+	}
+
+	msg.Entity, err = pgp.EncryptMIME(backend, entity, recipients, signer)
+	return err
+}
+
+// fetchWKDKeys imports keys for recipients missing from the keyring.
+// Lookup failures are ignored; encryption reports missing keys.
+func fetchWKDKeys(k *pgp.Keyring, recipients []string) {
+	for _, r := range recipients {
+		if k.HasKey(r) {
+			continue
+		}
+		data, err := pgp.LookupWKD(context.Background(), nil, r)
+		if err != nil {
+			continue
+		}
+		if infos, err := k.Import(bytes.NewReader(data)); err == nil {
+			for _, info := range infos {
+				fmt.Fprintf(os.Stderr, "Imported key %s for %s via WKD\n", info.Fingerprint, r)
+			}
+		}
+	}
+}
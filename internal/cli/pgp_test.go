@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/pgp"
+	"github.com/visionik/sogcli/internal/smtp"
+)
+
+func TestProtectAndOpenMessage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	k, err := openPGPKeyring("")
+	require.NoError(t, err)
+	for _, email := range []string{"me@example.com", "bob@example.com"} {
+		e, err := openpgp.NewEntity("test", "", email, nil)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+		require.NoError(t, err)
+		require.NoError(t, e.SerializePrivate(w, nil))
+		require.NoError(t, w.Close())
+		_, err = k.Import(&buf)
+		require.NoError(t, err)
+	}
+
+	acct := &config.Account{Email: "me@example.com"}
+	id := &config.Identity{Email: "me@example.com"}
+	msg := &smtp.Message{To: []string{"bob@example.com"}, Subject: "Secret", Body: "Hello Bob\n"}
+	require.NoError(t, protectMessage(acct, msg, id, SecurityFlags{Sign: true, Encrypt: true}))
+	assert.True(t, pgp.IsEncrypted(msg.Entity))
+	assert.NotContains(t, string(msg.Entity), "Hello Bob")
+
+	raw := append([]byte("Subject: Secret\r\nMIME-Version: 1.0\r\n"), msg.Entity...)
+	text, result, err := openSecureMessage(acct, raw, "")
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "pgp", result.Kind)
+	assert.True(t, result.Encrypted)
+	require.NotNil(t, result.PGP)
+	assert.Equal(t, pgp.SignatureGood, result.PGP.Status)
+	assert.Contains(t, text, "Hello Bob")
+
+	// Plain messages pass through
+	text, result, err = openSecureMessage(acct, []byte("Subject: Hi\r\n\r\nHi\r\n"), "")
+	require.NoError(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, text, "Hi")
+}
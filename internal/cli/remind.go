@@ -0,0 +1,409 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/dateexpr"
+	"github.com/visionik/sogcli/internal/timezone"
+)
+
+// RemindCmd handles event and task reminders.
+type RemindCmd struct {
+	Run  RemindRunCmd  `cmd:"" help:"Watch calendars and fire due reminders"`
+	List RemindListCmd `cmd:"" help:"List upcoming reminders"`
+}
+
+// parseReminders parses --remind values like "15m" or "1d". The alarms
+// fire before the start, or before the due date for tasks (fromEnd).
+// "none" clears all reminders; clear reports that.
+func parseReminders(values []string, fromEnd bool) (alarms []caldav.Alarm, clear bool, err error) {
+	for _, v := range values {
+		if strings.EqualFold(v, "none") {
+			if len(values) > 1 {
+				return nil, false, fmt.Errorf("--remind none cannot be combined with other reminders")
+			}
+			return nil, true, nil
+		}
+		d, err := dateexpr.ParseDuration(v)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid --remind %q (use e.g. 15m, 1h, 1d)", v)
+		}
+		alarms = append(alarms, caldav.Alarm{Action: caldav.AlarmDisplay, Before: d, FromEnd: fromEnd})
+	}
+	return alarms, false, nil
+}
+
+// remindersJSON formats alarms as a JSON array of descriptions like
+// "15m before start", for the hand-written JSON output of cal and tasks.
+func remindersJSON(alarms []caldav.Alarm) string {
+	descriptions := make([]string, 0, len(alarms))
+	for _, a := range alarms {
+		descriptions = append(descriptions, a.String())
+	}
+	data, _ := json.Marshal(descriptions)
+	return string(data)
+}
+
+// reminder is an alarm due for one event occurrence or task.
+type reminder struct {
+	Type        string    `json:"type"` // event or task
+	UID         string    `json:"uid"`
+	Summary     string    `json:"summary"`
+	Calendar    string    `json:"calendar"`
+	Trigger     time.Time `json:"trigger"`
+	Start       time.Time `json:"start,omitempty"`
+	End         time.Time `json:"end,omitempty"`
+	Due         time.Time `json:"due,omitempty"`
+	AllDay      bool      `json:"all_day,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	Action      string    `json:"action"`
+	Description string    `json:"description,omitempty"`
+}
+
+// key identifies the reminder across checks, so that it fires only once.
+func (r reminder) key() string {
+	start := r.Start
+	if r.Type == "task" {
+		start = r.Due
+	}
+	return fmt.Sprintf("%s|%s|%d|%d", r.Type, r.UID, start.Unix(), r.Trigger.Unix())
+}
+
+// collectReminders returns the reminders of events and tasks in a calendar
+// that fire after from and no later than to, by trigger time.
+func collectReminders(calendar string, events []caldav.Event, tasks []caldav.Task, from, to time.Time) []reminder {
+	var out []reminder
+	due := func(t time.Time) bool {
+		return !t.IsZero() && t.After(from) && !t.After(to)
+	}
+	for _, e := range events {
+		for _, a := range e.Alarms {
+			if t := a.Time(e.Start, e.End); due(t) {
+				out = append(out, reminder{
+					Type: "event", UID: e.UID, Summary: e.Summary, Calendar: calendar, Trigger: t,
+					Start: e.Start, End: e.End, AllDay: e.AllDay, Location: e.Location,
+					Action: a.Action, Description: a.Description,
+				})
+			}
+		}
+	}
+	for _, task := range tasks {
+		if task.Status == caldav.TaskStatusCompleted || task.Status == caldav.TaskStatusCancelled {
+			continue
+		}
+		for _, a := range task.Alarms {
+			if t := a.Time(task.Start, task.Due); due(t) {
+				out = append(out, reminder{
+					Type: "task", UID: task.UID, Summary: task.Summary, Calendar: calendar, Trigger: t,
+					Start: task.Start, Due: task.Due, Action: a.Action, Description: a.Description,
+				})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Trigger.Before(out[j].Trigger) })
+	return out
+}
+
+// reminderSource lists the reminders of one account's calendars.
+type reminderSource struct {
+	client    *caldav.Client
+	calendars []string
+	tasks     bool
+}
+
+// newReminderSource connects to the account's CalDAV server. Without
+// explicit calendars, all calendars of the account are watched.
+func newReminderSource(ctx context.Context, root *Root, calendars []string, tasks bool) (*reminderSource, error) {
+	client, _, err := getCalDAVClient(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(calendars) == 0 {
+		cals, err := client.FindCalendars(ctx)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+		for _, cal := range cals {
+			calendars = append(calendars, cal.Path)
+		}
+	}
+	return &reminderSource{client: client, calendars: calendars, tasks: tasks}, nil
+}
+
+// list returns the reminders firing after from and no later than to.
+// Events are looked up from a day before from, for alarms after the start,
+// until lookahead after to.
+func (s *reminderSource) list(ctx context.Context, from, to time.Time, lookahead time.Duration) ([]reminder, error) {
+	var all []reminder
+	for _, cal := range s.calendars {
+		events, err := s.client.ListEvents(ctx, cal, from.Add(-24*time.Hour), to.Add(lookahead))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list events in %s: %w", cal, err)
+		}
+		var tasks []caldav.Task
+		if s.tasks {
+			tasks, err = s.client.ListTasks(ctx, cal, false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list tasks in %s: %w", cal, err)
+			}
+		}
+		all = append(all, collectReminders(cal, events, tasks, from, to)...)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Trigger.Before(all[j].Trigger) })
+	return all, nil
+}
+
+// RemindRunCmd fires reminders as they become due.
+type RemindRunCmd struct {
+	Calendars []string      `name:"calendar" help:"Calendar paths to watch (default: all)"`
+	Interval  time.Duration `help:"How often to check" default:"1m"`
+	Lookahead string        `help:"How far ahead to look for events, bounding the longest reminder (e.g., 7d)" default:"7d"`
+	Grace     time.Duration `help:"Still fire reminders missed by up to this long (e.g., while offline)" default:"1h"`
+	Exec      string        `help:"Command to run for each reminder (JSON on stdin, SOG_REMINDER_* variables); default: print NDJSON"`
+	NoTasks   bool          `help:"Only remind of events"`
+	Once      bool          `help:"Check once and exit"`
+	State     string        `help:"File recording fired reminders (default: ~/.config/sog/remind/<account>.json)" type:"path"`
+}
+
+// Run executes the remind run command.
+func (c *RemindRunCmd) Run(root *Root) error {
+	lookahead, err := dateexpr.ParseDuration(c.Lookahead)
+	if err != nil {
+		return fmt.Errorf("invalid --lookahead: %w", err)
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	statePath := c.State
+	if statePath == "" {
+		statePath, err = defaultReminderStatePath(root)
+		if err != nil {
+			return err
+		}
+	}
+	state, err := loadReminderState(statePath)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	source, err := newReminderSource(ctx, root, c.Calendars, !c.NoTasks)
+	if err != nil {
+		return err
+	}
+	defer source.client.Close()
+	if !c.Once {
+		fmt.Fprintf(os.Stderr, "Watching %d calendar(s) for reminders (Ctrl+C to stop)...\n", len(source.calendars))
+	}
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		due, err := source.list(ctx, now.Add(-c.Grace), now, lookahead)
+		if err != nil {
+			if c.Once {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		for _, r := range due {
+			if _, fired := state.Fired[r.key()]; fired {
+				continue
+			}
+			if err := c.fire(r); err != nil {
+				// Not recorded, so it is retried until the grace period ends
+				fmt.Fprintf(os.Stderr, "Warning: reminder for %s: %v\n", r.Summary, err)
+				continue
+			}
+			state.Fired[r.key()] = r.Trigger
+		}
+		state.prune(now.Add(-c.Grace - 24*time.Hour))
+		if err := state.save(statePath); err != nil {
+			return err
+		}
+
+		if c.Once {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// fire prints a reminder as JSON or passes it to the --exec command.
+func (c *RemindRunCmd) fire(r reminder) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if c.Exec == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+
+	cmd := exec.Command("sh", "-c", c.Exec)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"SOG_REMINDER_TYPE="+r.Type,
+		"SOG_REMINDER_UID="+r.UID,
+		"SOG_REMINDER_SUMMARY="+r.Summary,
+		"SOG_REMINDER_CALENDAR="+r.Calendar,
+		"SOG_REMINDER_TRIGGER="+r.Trigger.Format(time.RFC3339),
+		"SOG_REMINDER_LOCATION="+r.Location,
+	)
+	if !r.Start.IsZero() {
+		cmd.Env = append(cmd.Env, "SOG_REMINDER_START="+r.Start.Format(time.RFC3339))
+	}
+	if !r.Due.IsZero() {
+		cmd.Env = append(cmd.Env, "SOG_REMINDER_DUE="+r.Due.Format(time.RFC3339))
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command failed: %w", err)
+	}
+	return nil
+}
+
+// reminderState records fired reminders by key, with their trigger time.
+type reminderState struct {
+	Fired map[string]time.Time `json:"fired"`
+}
+
+func defaultReminderStatePath(root *Root) (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	account := root.Account
+	if account == "" {
+		if cfg, err := config.Load(); err == nil {
+			account = cfg.DefaultAccount
+		}
+	}
+	return filepath.Join(dir, "remind", unsafeFileChars.ReplaceAllString(account, "_")+".json"), nil
+}
+
+func loadReminderState(path string) (*reminderState, error) {
+	state := &reminderState{Fired: map[string]time.Time{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reminder state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse reminder state %s: %w", path, err)
+	}
+	if state.Fired == nil {
+		state.Fired = map[string]time.Time{}
+	}
+	return state, nil
+}
+
+// prune forgets reminders that fired before cutoff; they can no longer be
+// due again.
+func (s *reminderState) prune(cutoff time.Time) {
+	for key, trigger := range s.Fired {
+		if trigger.Before(cutoff) {
+			delete(s.Fired, key)
+		}
+	}
+}
+
+func (s *reminderState) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write reminder state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write reminder state: %w", err)
+	}
+	return nil
+}
+
+// RemindListCmd lists upcoming reminders.
+type RemindListCmd struct {
+	Calendars []string `name:"calendar" help:"Calendar paths (default: all)"`
+	Within    string   `help:"How far ahead to list (e.g., 24h, 7d)" default:"24h"`
+	NoTasks   bool     `help:"Only list event reminders"`
+}
+
+// Run executes the remind list command.
+func (c *RemindListCmd) Run(root *Root) error {
+	within, err := dateexpr.ParseDuration(c.Within)
+	if err != nil {
+		return fmt.Errorf("invalid --within: %w", err)
+	}
+
+	ctx := context.Background()
+	source, err := newReminderSource(ctx, root, c.Calendars, !c.NoTasks)
+	if err != nil {
+		return err
+	}
+	defer source.client.Close()
+
+	now := time.Now()
+	reminders, err := source.list(ctx, now, now.Add(within), within)
+	if err != nil {
+		return err
+	}
+	if len(reminders) == 0 {
+		fmt.Println("No upcoming reminders.")
+		return nil
+	}
+
+	if root.JSON {
+		for _, r := range reminders {
+			data, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		}
+		return nil
+	}
+
+	loc := timezone.System()
+	fmt.Printf("%-20s %-6s %-20s %s\n", "REMIND AT", "TYPE", "WHEN", "SUMMARY")
+	for _, r := range reminders {
+		when := r.Start
+		if r.Type == "task" {
+			when = r.Due
+		}
+		whenStr := when.In(loc).Format("2006-01-02 15:04")
+		if r.AllDay {
+			whenStr = when.Format("2006-01-02") + " all-day"
+		}
+		fmt.Printf("%-20s %-6s %-20s %s\n", r.Trigger.In(loc).Format("2006-01-02 15:04"), r.Type, whenStr, r.Summary)
+	}
+	return nil
+}
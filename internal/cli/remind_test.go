@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+func TestParseReminders(t *testing.T) {
+	alarms, clear, err := parseReminders([]string{"15m", "1d"}, false)
+	require.NoError(t, err)
+	assert.False(t, clear)
+	require.Len(t, alarms, 2)
+	assert.Equal(t, 15*time.Minute, alarms[0].Before)
+	assert.Equal(t, 24*time.Hour, alarms[1].Before)
+	assert.False(t, alarms[0].FromEnd)
+
+	alarms, _, err = parseReminders([]string{"1h"}, true)
+	require.NoError(t, err)
+	assert.True(t, alarms[0].FromEnd)
+
+	_, clear, err = parseReminders([]string{"none"}, false)
+	require.NoError(t, err)
+	assert.True(t, clear)
+
+	_, _, err = parseReminders([]string{"none", "15m"}, false)
+	assert.Error(t, err)
+	_, _, err = parseReminders([]string{"soon"}, false)
+	assert.Error(t, err)
+}
+
+func TestCollectReminders(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	events := []caldav.Event{
+		{UID: "standup", Summary: "Standup", Start: now.Add(10 * time.Minute), End: now.Add(25 * time.Minute),
+			Alarms: []caldav.Alarm{{Before: 15 * time.Minute}, {Before: time.Hour}}},
+		{UID: "later", Summary: "Later", Start: now.Add(3 * time.Hour), Alarms: []caldav.Alarm{{Before: 15 * time.Minute}}},
+		{UID: "none", Summary: "No alarms", Start: now},
+	}
+	tasks := []caldav.Task{
+		{UID: "taxes", Summary: "Taxes", Due: now.Add(24 * time.Hour), Alarms: []caldav.Alarm{{Before: 24 * time.Hour, FromEnd: true}}},
+		{UID: "done", Summary: "Done", Status: caldav.TaskStatusCompleted, Due: now, Alarms: []caldav.Alarm{{FromEnd: true}}},
+	}
+
+	got := collectReminders("/cal/", events, tasks, now.Add(-30*time.Minute), now)
+	require.Len(t, got, 2)
+	assert.Equal(t, "standup", got[0].UID)
+	assert.Equal(t, now.Add(-5*time.Minute), got[0].Trigger)
+	assert.Equal(t, "taxes", got[1].UID)
+	assert.Equal(t, "task", got[1].Type)
+	assert.Equal(t, "/cal/", got[1].Calendar)
+
+	// Occurrences of a series have different keys
+	other := got[0]
+	other.Start = other.Start.Add(24 * time.Hour)
+	other.Trigger = other.Trigger.Add(24 * time.Hour)
+	assert.NotEqual(t, got[0].key(), other.key())
+}
+
+func TestReminderState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "remind", "me.json")
+	state, err := loadReminderState(path)
+	require.NoError(t, err)
+	assert.Empty(t, state.Fired)
+
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	state.Fired["old"] = now.Add(-48 * time.Hour)
+	state.Fired["new"] = now
+	state.prune(now.Add(-24 * time.Hour))
+	require.NoError(t, state.save(path))
+
+	loaded, err := loadReminderState(path)
+	require.NoError(t, err)
+	assert.Len(t, loaded.Fired, 1)
+	assert.True(t, loaded.Fired["new"].Equal(now))
+}
+
+func TestFireExec(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	cmd := &RemindRunCmd{Exec: `cat > "` + out + `"; echo "$SOG_REMINDER_SUMMARY" >> "` + out + `"`}
+	r := reminder{Type: "event", UID: "standup", Summary: "Standup", Trigger: time.Date(2026, 3, 2, 8, 55, 0, 0, time.UTC)}
+	require.NoError(t, cmd.fire(r))
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	var fired reminder
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &fired))
+	assert.Equal(t, "standup", fired.UID)
+	assert.Equal(t, "Standup", lines[1])
+
+	// A failing command is reported, so the reminder is retried
+	cmd.Exec = "exit 1"
+	assert.Error(t, cmd.fire(r))
+}
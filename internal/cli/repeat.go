@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// repeatUnits maps repeat words to RRULE frequencies.
+var repeatUnits = map[string]string{
+	"daily": "DAILY", "day": "DAILY", "days": "DAILY",
+	"weekly": "WEEKLY", "week": "WEEKLY", "weeks": "WEEKLY",
+	"monthly": "MONTHLY", "month": "MONTHLY", "months": "MONTHLY",
+	"yearly": "YEARLY", "annually": "YEARLY", "year": "YEARLY", "years": "YEARLY",
+}
+
+// repeatDays maps weekday names to RRULE BYDAY values.
+var repeatDays = map[string]string{
+	"mo": "MO", "mon": "MO", "monday": "MO",
+	"tu": "TU", "tue": "TU", "tues": "TU", "tuesday": "TU",
+	"we": "WE", "wed": "WE", "wednesday": "WE",
+	"th": "TH", "thu": "TH", "thur": "TH", "thurs": "TH", "thursday": "TH",
+	"fr": "FR", "fri": "FR", "friday": "FR",
+	"sa": "SA", "sat": "SA", "saturday": "SA",
+	"su": "SU", "sun": "SU", "sunday": "SU",
+}
+
+// parseRepeat converts a repeat expression into an RRULE value for an
+// event starting at start. Examples:
+//
+//	daily
+//	weekdays
+//	every 2 weeks on mon,thu
+//	weekly on mon,wed until 2027-01-01
+//	monthly on 15 for 6 times
+//	yearly 3 times
+func parseRepeat(s string, start time.Time, allDay bool) (string, error) {
+	fields := strings.Fields(strings.ToLower(strings.ReplaceAll(s, ",", ", ")))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty repeat expression")
+	}
+
+	var freq, interval, until, count string
+	var byDay, byMonthDay []string
+	for i := 0; i < len(fields); i++ {
+		word := strings.TrimSuffix(fields[i], ",")
+		switch {
+		case word == "every":
+			if i+1 < len(fields) {
+				if n, err := strconv.Atoi(fields[i+1]); err == nil && n > 0 {
+					interval = fields[i+1]
+					i++
+				}
+			}
+		case repeatUnits[word] != "":
+			freq = repeatUnits[word]
+		case word == "weekdays":
+			freq = "WEEKLY"
+			byDay = append(byDay, "MO", "TU", "WE", "TH", "FR")
+		case word == "on" || word == "and" || word == "for" || word == "":
+			// filler
+		case repeatDays[word] != "":
+			byDay = append(byDay, repeatDays[word])
+		case word == "until":
+			if i+1 >= len(fields) {
+				return "", fmt.Errorf("missing date after 'until'")
+			}
+			date, err := time.ParseInLocation("2006-01-02", fields[i+1], start.Location())
+			if err != nil {
+				return "", fmt.Errorf("invalid until date: %s (use YYYY-MM-DD)", fields[i+1])
+			}
+			if allDay {
+				until = date.Format("20060102")
+			} else {
+				// Include occurrences on the last day
+				until = date.AddDate(0, 0, 1).Add(-time.Second).UTC().Format("20060102T150405Z")
+			}
+			i++
+		case i+1 < len(fields) && (fields[i+1] == "times" || fields[i+1] == "x"):
+			n, err := strconv.Atoi(word)
+			if err != nil || n <= 0 {
+				return "", fmt.Errorf("invalid count: %s", word)
+			}
+			count = word
+			i++
+		default:
+			n, err := strconv.Atoi(word)
+			if err != nil || n < 1 || n > 31 {
+				return "", fmt.Errorf("unknown word in repeat expression: %q", fields[i])
+			}
+			byMonthDay = append(byMonthDay, word)
+		}
+	}
+
+	if freq == "" {
+		return "", fmt.Errorf("repeat expression needs a frequency (daily, weekly, monthly, yearly or every N days/weeks/...)")
+	}
+	if until != "" && count != "" {
+		return "", fmt.Errorf("use either 'until' or 'N times', not both")
+	}
+
+	parts := []string{"FREQ=" + freq}
+	if interval != "" && interval != "1" {
+		parts = append(parts, "INTERVAL="+interval)
+	}
+	if len(byDay) > 0 {
+		parts = append(parts, "BYDAY="+strings.Join(byDay, ","))
+	}
+	if len(byMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+strings.Join(byMonthDay, ","))
+	}
+	if until != "" {
+		parts = append(parts, "UNTIL="+until)
+	}
+	if count != "" {
+		parts = append(parts, "COUNT="+count)
+	}
+
+	rule := strings.Join(parts, ";")
+	return rule, validateRRule(rule)
+}
+
+// validateRRule checks a raw RRULE value.
+func validateRRule(rule string) error {
+	opt, err := rrule.StrToROption(strings.TrimPrefix(rule, "RRULE:"))
+	if err != nil {
+		return fmt.Errorf("invalid RRULE: %w", err)
+	}
+	if _, err := rrule.NewRRule(*opt); err != nil {
+		return fmt.Errorf("invalid RRULE: %w", err)
+	}
+	return nil
+}
+
+// eventRepeatRule returns the RRULE for the --repeat or --rrule flag, or ""
+// if neither is set.
+func eventRepeatRule(repeat, raw string, start time.Time, allDay bool) (string, error) {
+	switch {
+	case repeat != "" && raw != "":
+		return "", fmt.Errorf("use either --repeat or --rrule, not both")
+	case repeat != "":
+		rule, err := parseRepeat(repeat, start, allDay)
+		if err != nil {
+			return "", fmt.Errorf("invalid --repeat: %w", err)
+		}
+		return rule, nil
+	case raw != "":
+		raw = strings.TrimPrefix(raw, "RRULE:")
+		if err := validateRRule(raw); err != nil {
+			return "", err
+		}
+		return raw, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRepeat(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"daily", "FREQ=DAILY"},
+		{"weekdays", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"},
+		{"weekly on mon,wed until 2027-01-01", "FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20270101T235959Z"},
+		{"every 2 weeks on Mon, Thursday", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"},
+		{"monthly on 15 for 6 times", "FREQ=MONTHLY;BYMONTHDAY=15;COUNT=6"},
+		{"yearly 3 times", "FREQ=YEARLY;COUNT=3"},
+		{"every 3 days", "FREQ=DAILY;INTERVAL=3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := parseRepeat(tt.expr, start, false)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// All-day events use a DATE for UNTIL
+	got, err := parseRepeat("weekly until 2027-01-01", start, true)
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;UNTIL=20270101", got)
+
+	for _, bad := range []string{"", "on mon", "weekly until", "weekly until tomorrow", "daily until 2027-01-01 5 times", "fortnightly"} {
+		_, err := parseRepeat(bad, start, false)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestEventRepeatRule(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	rule, err := eventRepeatRule("", "RRULE:FREQ=WEEKLY;BYDAY=MO", start, false)
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO", rule)
+
+	_, err = eventRepeatRule("", "FREQ=SOMETIMES", start, false)
+	assert.Error(t, err)
+	_, err = eventRepeatRule("daily", "FREQ=DAILY", start, false)
+	assert.Error(t, err)
+
+	rule, err = eventRepeatRule("", "", start, false)
+	require.NoError(t, err)
+	assert.Empty(t, rule)
+}
@@ -14,9 +14,11 @@ type Root struct {
 	JSON    bool        `help:"Output JSON to stdout (best for scripting)" xor:"format"`
 	Plain   bool        `help:"Output stable, parseable text to stdout (TSV; no colors)" xor:"format"`
 	Color   string      `help:"Color output: auto|always|never" default:"auto" enum:"auto,always,never"`
-	Force   bool        `help:"Skip confirmations for destructive commands"`
+	Force   bool        `help:"Skip confirmations for destructive commands and overwrite on conflicts"`
 	NoInput bool        `help:"Never prompt; fail instead (useful for CI)" name:"no-input"`
 	Verbose bool        `help:"Enable verbose logging" short:"v"`
+	Offline bool        `help:"Read calendars, tasks and contacts from the local cache only (see 'sog sync')" xor:"cache"`
+	Refresh bool        `help:"Rebuild the local calendar and contact cache from the server" xor:"cache"`
 	Version VersionFlag `name:"version" help:"Print version and exit"`
 
 	// Subcommands
@@ -30,6 +32,10 @@ type Root struct {
 	Folders  FoldersCmd  `cmd:"" aliases:"f" help:"Manage folders"`
 	Drafts   DraftsCmd   `cmd:"" aliases:"d" help:"Manage drafts"`
 	Idle     IdleCmd     `cmd:"" help:"Watch for new mail (IMAP IDLE)"`
+	Remind   RemindCmd   `cmd:"" help:"Event and task reminders"`
+	Sync     SyncCmd     `cmd:"" help:"Sync calendars, tasks and contacts to the local cache"`
+	PGP      PGPCmd      `cmd:"" name:"pgp" help:"OpenPGP keys and settings"`
+	SMIME    SMIMECmd    `cmd:"" name:"smime" help:"S/MIME certificates"`
 }
 
 // VersionFlag handles --version.
@@ -59,9 +65,11 @@ sog mail list
 --account, -a    Account email to use ($SOG_ACCOUNT)
 --json           JSON output (for scripting)
 --plain          TSV output (parseable)
---force          Skip confirmations
+--force          Skip confirmations; overwrite on ETag conflicts
 --no-input       Never prompt (CI mode)
 --verbose, -v    Debug logging
+--offline        Read calendars, tasks and contacts from the local cache only
+--refresh        Rebuild the local cache from the server before reading
 --ai-help        This help text
 
 ## Authentication
@@ -82,6 +90,18 @@ sog auth test [email]            Test connection
 sog auth remove <email>          Remove account
 sog auth password <email>        Set protocol-specific passwords
   --imap, --smtp, --caldav, --carddav, --webdav
+  --pgp            Passphrase for your OpenPGP secret key
+  --smime          Password for your S/MIME PKCS#12 file
+
+sog auth identity list           List sending identities (aliases)
+sog auth identity add <address>  Add or update an identity
+  --name           Display name
+  --reply-to       Reply-To address
+  --signature      Signature text (or --signature-file)
+  --bcc-self       Bcc the identity on every message
+  --pgp-key        OpenPGP signing key (default: the address)
+  --smime-cert     S/MIME certificate: .p12/.pfx, or PEM (with --smime-key)
+sog auth identity remove <address>
 
 ## Mail (IMAP/SMTP)
 
@@ -91,11 +111,17 @@ sog mail list [folder]
 
 sog mail get <uid>
   --headers        Headers only
-  --raw            Raw RFC822 format
+  --raw            Raw RFC822 format (no decryption)
+  PGP/MIME and S/MIME messages are decrypted and verified; signature status
+  (and the S/MIME certificate chain) is shown. Certificates from trusted
+  S/MIME signatures are saved for the From address, for encrypting to the
+  sender later; a stored certificate is only replaced by 'sog smime import'.
 
 sog mail search <query>
   IMAP SEARCH syntax: FROM, TO, SUBJECT, SINCE, BEFORE, etc.
+  SINCE/BEFORE take any date expression (see Dates below)
   Example: sog mail search "FROM john SINCE 1-Jan-2026"
+           sog mail search "SINCE last monday UNREAD"
 
 sog mail send --to <email> --subject <text> [flags]
   --to             Recipient(s)
@@ -104,15 +130,74 @@ sog mail send --to <email> --subject <text> [flags]
   --subject        Subject line
   --body           Message body
   --body-file      Read body from file (- for stdin)
+  --from           Send as identity (alias)
+  --template       Render from ~/.config/sog/templates/<name>
+  --var k=v        Template variable (repeatable)
+  --no-signature   Don't append the identity's signature
+  --sign           Sign with OpenPGP (PGP/MIME)
+  --encrypt        Encrypt with OpenPGP to all recipients and yourself
+  --smime-sign     Sign with the identity's S/MIME certificate
+  --smime-encrypt  Encrypt with S/MIME (application/pkcs7-mime)
+  Encrypted mail is sent to each --bcc recipient as a separate copy.
+
+sog mail templates               List message templates
+  Templates are Go text/template files with optional front-matter:
+    ---
+    Subject: Weekly status, week {{.Vars.week}}
+    To: team@example.com
+    ---
+    Hi {{default "team" .Contact.FirstName}}, ...
+  Fields: .Vars, .Contact (CardDAV lookup of first recipient), .HasContact,
+  .Recipient, .From, .Date. Funcs: default, upper, lower, trim, date.
+
+sog mail merge --template <name> --recipients <file.csv>
+sog mail merge --template <name> --contacts-group <group>
+  --recipients     CSV with an email column; columns become .Vars
+  --contacts-group Contacts category or group card (fills .Contact)
+  --var k=v        Variable for every message
+  --throttle       Delay between messages (default: 1s)
+  --log            Send log; rerunning skips recipients already sent
+  --preview N      Print first N rendered messages, send nothing
+  --sign/--encrypt, --smime-sign/--smime-encrypt  Per message
+  Each message goes to its recipient only; template To/Cc/Bcc are ignored.
 
 sog mail reply <uid> --body <text>
-sog mail forward <uid> --to <email>
+  --all            Reply to all recipients
+  --from           Send as identity (default: identity the message was sent to)
+  --no-signature   Don't append the identity's signature
+  --sign/--encrypt, --smime-sign/--smime-encrypt
+                   (warns when replying to encrypted mail unencrypted)
+sog mail forward <uid> --to <email> [--from <identity>] [--no-signature]
+  --sign/--encrypt, --smime-sign/--smime-encrypt
+                   Encrypted originals need encryption (or --force)
 sog mail move <uid> <folder>
 sog mail copy <uid> <folder>
 sog mail flag <uid> <flag>       Flags: seen, flagged, answered, deleted
 sog mail unflag <uid> <flag>
 sog mail delete <uid>
 
+## OpenPGP
+
+sog pgp list                     Keys in the sog keyring (~/.config/sog/pgp)
+sog pgp import <file>            Import public/secret keys (- for stdin)
+sog pgp export <key>             Export a public key (armored)
+sog pgp delete <key>
+sog pgp lookup <email> [--import]  Find a key via WKD
+sog pgp config                   Show/set account settings
+  --backend        keyring (default) or gpg
+  --gpg-path       gpg binary for the gpg backend
+  --[no-]wkd       Fetch missing recipient keys via WKD when encrypting
+Keys are matched by email, key ID or fingerprint.
+
+## S/MIME
+
+sog smime list                   Recipient certificates (~/.config/sog/smime/certs)
+sog smime import <file>          Import certificates (PEM or DER, - for stdin),
+                                 replacing stored ones
+sog smime export <email>         Export a certificate (yours or a stored one)
+sog smime delete <email>
+sog smime identities             Your identities' certificates and chains
+
 ## Folders
 
 sog folders list
@@ -133,22 +218,122 @@ sog cal list [calendar]
   --from           Start date (default: today)
   --to             End date (default: +30d)
   --max            Maximum events
+  --calendar       Calendar path or name, or subscription (repeatable)
+  --all-calendars  Every calendar of the account
+  --all-accounts   Every account with a CalDAV server
 
 sog cal get <uid>
 sog cal search <query>           Search in title/description/location
 sog cal today [calendar]
-sog cal week [calendar]
+sog cal week [calendar]          --grid: a column per day, a row per hour;
+                                 overlapping events side by side
+sog cal month [YYYY-MM]          Month grid with the first events of each day
+sog cal agenda [calendar]        Events grouped by day, all-day first (--from, --to)
+Grids fit the terminal width ($COLUMNS overrides); --color auto|always|never
+marks calendars in their color. With --plain, list, search, agenda and month
+print tab-separated lines: date, start, end, calendar, summary (times empty
+for all-day events).
+list, search, today, week, month and agenda take --calendar,
+--all-calendars and --all-accounts. Calendars are fetched concurrently and merged; an event in
+several calendars is shown once. With more than one calendar, events are
+tagged [calendar] in the calendar's color; JSON has "source" (label),
+"calendar" (path) and "account".
 
 sog cal create <title> --start <datetime> [flags]
-  --start          Start time (YYYY-MM-DDTHH:MM or YYYY-MM-DD for all-day)
+  --start          Start time (YYYY-MM-DDTHH:MM, 'tomorrow 2pm'; a date alone for all-day)
   --end            End time
-  --duration       Duration (1h, 30m)
+  --duration       Duration (1h, 30m, 2d)
   --location       Location
   --description    Description
-
-sog cal update <uid> [flags]     Same flags as create
+  --repeat         Repeat rule: daily, weekdays, 'weekly on mon,wed until 2027-01-01',
+                   'every 2 weeks on fri', 'monthly on 15 for 6 times'
+  --rrule          Raw RRULE (FREQ=WEEKLY;BYDAY=MO,WE)
+  --remind         Reminder before the start (15m, 1h, 1d); repeatable
+  --no-conflicts   Refuse if the time overlaps another event
+Before creating, all your calendars are checked (recurring events: the next
+90 days); overlapping events are listed as a warning. Cancelled and
+transparent (TRANSP:TRANSPARENT) events do not count.
+
+sog cal conflicts                Pairs of overlapping events in your calendars
+  --from, --to     Range (default: today to +30d)
+  --calendar       Only these calendars (repeatable; default: all)
+
+sog cal update <uid> [flags]     Same flags as create (--repeat none stops repeating,
+                                 --remind replaces reminders, --remind none removes them)
+  --instance       Only the occurrence on this date (YYYY-MM-DD or YYYY-MM-DDTHH:MM)
+  --this-and-future  With --instance: this and later occurrences (splits the series)
+  --merge          On a conflict, merge both versions and ask about clashing fields
 sog cal delete <uid>
-sog cal calendars                List calendars
+  --instance       Only the occurrence on this date
+  --this-and-future  With --instance: this and later occurrences
+
+Recurring events are listed once per occurrence (expanded by the server
+when it supports it). JSON output includes rrule and recurrence_id.
+
+Time zones (flags on 'sog cal', usable after any cal subcommand):
+  --tz             Zone for --start/--end/--instance (default: system zone)
+  --display-tz     Show event times in this zone, e.g. when travelling
+Events are written with TZID and an embedded VTIMEZONE; 'sog invite send'
+and 'preview' take --tz too.
+
+Updates and deletes of events, tasks and contacts only succeed if the item
+is unchanged on the server since it was read (ETag). Otherwise they fail
+with a conflict; run again, or use the global --force to overwrite (or
+--merge where offered).
+sog cal calendars                List calendars with type (events/tasks), color and
+                                 read-only status; --json adds ctag, sync_token, owner
+sog cal calendars create <name>  Create a calendar (MKCALENDAR)
+  --type           event (default), todo (a task list) or both
+  --calendar-color Color, e.g. #3A87AD
+  --description    Description
+  --path           Collection path (default: from the name)
+sog cal calendars rename <calendar> <name>   Calendar by path or name
+sog cal calendars color <calendar> <#RRGGBB>
+sog cal calendars delete <calendar>          Asks first unless --force
+
+sog cal free [attendees...]      Propose times when you and attendees are free
+  --within         When to look: 'next week', 'tomorrow', 'mon to wed', 7d (default)
+  --duration       Meeting length (default: 30m)
+  --working-hours  Hours in --tz, e.g. 9-17 (default), 8:30-16:30, none
+  --weekends       Include Saturdays and Sundays
+  --calendar       Calendars to check (default: primary); repeatable
+  --max            Maximum slots (default: 10)
+Your busy times come from a free-busy-query REPORT (or your events if the
+server lacks it). Attendees are looked up via the server's scheduling
+outbox (RFC 6638), which usually only knows users on the same server;
+others are skipped with a warning. --json prints one slot per line with
+an RFC 3339 start that 'sog invite send --start' accepts.
+
+sog cal inbox                    List the scheduling inbox (RFC 6638)
+sog cal inbox accept <uid>       Accept an invitation (also decline, tentative)
+  --calendar       Calendar for invitations not yet on one (default: primary)
+  --comment        Comment for the organizer (email replies only)
+sog cal inbox delete <uid>       Remove messages (--all for every message)
+On servers with calendar-auto-schedule, invitations, updates, replies and
+cancellations arrive here and the server sends your replies. Elsewhere
+replies are emailed; servers without a scheduling inbox get an error, use
+'sog invite reply' for emailed invitations.
+
+sog cal export > out.ics         Write a calendar as one VCALENDAR
+  --calendar       Calendar path (default: primary)
+  --from, --to     Only events in this range (default: all)
+sog cal import <file>            Add the events in an .ics file (- for stdin)
+  --calendar       Calendar path (default: primary)
+  --merge          Replace events whose copy in the file is newer
+Exports keep every property, alarm, time zone and recurrence override.
+Imports store one object per UID and report each as created, updated or
+skipped; events already in the calendar are skipped unless --merge (higher
+SEQUENCE, then later LAST-MODIFIED wins) or --force (always replace).
+
+sog cal subscribe <url> --name <name>   Subscribe to a read-only feed (webcal://, https://)
+  --file           Subscribe to a local .ics file instead
+sog cal subscriptions            List subscriptions
+sog cal unsubscribe <name>
+Subscribed events are merged into 'cal list/today/week/search', shown as
+"[name] summary" (JSON: "source"). Pass the name as the calendar to see one
+feed alone; a calendar path shows only that calendar. Feeds are cached in
+~/.config/sog/subscriptions and refetched only when changed (ETag,
+Last-Modified); when a feed is unreachable, the cached copy is used.
 
 ## Contacts (CardDAV)
 
@@ -165,29 +350,71 @@ sog contacts create <name> [flags]
   --title          Job title
   --note           Note
 
-sog contacts update <uid> [flags]  Same flags as create
+sog contacts update <uid> [flags]  Same flags as create, plus --merge
 sog contacts delete <uid>
 sog contacts books               List address books
 
 ## Tasks (CalDAV VTODO)
 
-sog tasks list [list]
+sog tasks list [list]            Sub-tasks are indented under their parent
   --all            Include completed tasks
+  --where          Filter expression (see below)
+  --sort           Sort keys, e.g. due,priority or -priority
+  --list           Task list path or name; repeatable
+  --all-lists      Read every task list of the account
+  --all-accounts   Read every account with a CalDAV server
+  --save-as        Save the lists, filter and sort as a named view
 
 sog tasks add <title> [flags]
-  --due            Due date (YYYY-MM-DD)
+  --due            Due date (YYYY-MM-DD, 'friday 5pm', eod; a date alone means end of day)
+  --remind         Reminder before the due date (1h, 1d); repeatable
   -p, --priority   Priority (1-9, 1=highest)
   -d, --description Description
+  --parent         UID of the parent task (makes a sub-task)
+  --depends-on     UID of a task that must be finished first; repeatable
+  --repeat         Repeat from the due date ('weekly', 'every 2 weeks on fri', 'monthly 6 times')
+  --rrule          Raw RRULE instead of --repeat
 
 sog tasks get <uid>
-sog tasks update <uid> [flags]   Same flags as add
-sog tasks done <uid>             Mark complete
+sog tasks update <uid> [flags]   Same flags as add (--repeat none stops repeating)
+sog tasks done <uid>             Mark complete (--children also completes open sub-tasks)
 sog tasks undo <uid>             Mark incomplete
 sog tasks delete <uid>
 sog tasks clear                  Delete all completed tasks
-sog tasks due <date>             Tasks due by date
-sog tasks overdue                Overdue tasks
-sog tasks lists                  List task lists
+sog tasks due <date>             Tasks due by date (same --where/--sort/--list flags)
+sog tasks overdue                Overdue tasks (same --where/--sort/--list flags)
+sog tasks view <name>            Run a saved view (--delete removes it)
+sog tasks views                  List saved views
+sog tasks blocked [list]         Open tasks waiting on unfinished dependencies
+sog tasks lists                  List task lists (collections that accept VTODO)
+sog tasks export > tasks.ics     Write a task list as iCalendar (--list)
+sog tasks import <file>          Add the tasks in an .ics file (--list, --merge)
+
+Completing a recurring task moves its start and due date to the next
+occurrence and leaves it open; the series is completed after its last
+occurrence. To also keep each finished occurrence as a completed task (as
+Apple Reminders does), set "task_recurrence": "new-instance" in the
+account's "caldav" section of ~/.config/sog/config.json (default: "roll").
+
+Filter expressions (--where) join conditions with and (or just a space),
+or, not and parentheses; quote values with spaces:
+
+  priority<=3 and category:work and due<+7d and status!=completed
+  (due<today or status=doing) and not list:someday
+  due<"next friday" has:reminders
+
+  priority, percent          < <= > >= = != (tasks without priority never match < or >)
+  due, start, completed      Date expressions; a day means the whole day (due<=friday)
+  status                     todo, doing, done, cancelled or open
+  category:<name>            Has the category (also cat:, tag:)
+  summary:<t>, text:<t>       Summary (or summary and description) contains t
+  list:<name>, account:<email>
+  has:due|start|priority|description|categories|reminders|parent|children|deps|repeat
+  <word>                     Summary or description contains the word
+
+Completed tasks are left out unless --all is given or the filter mentions
+status or completed. Sort keys: due, start, completed, priority, percent,
+summary, status, list, account; tasks without the value come last.
 
 ## Files (WebDAV)
 
@@ -198,31 +425,118 @@ sog drive ls [path]
 sog drive get <path>             Get file metadata
 sog drive download <remote> [local]
 sog drive upload <local> [remote]
+  --etag           Only overwrite if the remote file still has this ETag
+  --no-overwrite   Fail if the remote file exists
 sog drive mkdir <path>
 sog drive delete <path>
+  --etag           Only delete if the file still has this ETag
 sog drive move <src> <dst>
 sog drive copy <src> <dst>
 sog drive cat <path>             Output file to stdout
 
+## Reminders
+
+sog remind list                  Reminders due in the next 24h (--within 7d)
+sog remind run [flags]           Fire reminders as they become due (daemon)
+  --calendar       Calendar paths to watch (default: all; repeatable)
+  --interval       How often to check (default: 1m)
+  --exec           Command per reminder: JSON on stdin, SOG_REMINDER_SUMMARY,
+                   SOG_REMINDER_START, SOG_REMINDER_DUE, ... in the environment
+                   (default: print one JSON object per line)
+  --grace          Still fire reminders missed by up to this long (default: 1h)
+  --once           Check once and exit (for cron)
+Reminders fire once; fired reminders are recorded in
+~/.config/sog/remind/<account>.json. A failing --exec command is retried.
+
+## Sync and Offline Use
+
+sog sync                         Sync all calendars, task lists and address books
+                                 (one line per collection: +added ~updated -deleted)
+Calendar, task and contact listings read from a local cache in
+~/.config/sog/cache/<account>/ that is synced before each read: only changes
+are downloaded (WebDAV sync-collection, or ctag/ETag comparison on servers
+without it). If the server cannot be reached, the last copy is used with a
+warning.
+  --offline        Read from the cache only (global flag)
+  --refresh        Discard the cache and download everything again (global flag)
+
+## Dates
+
+Wherever a date or time is expected (cal, tasks, invite, mail search):
+  2026-03-01, 2026-03-01T14:00, "2026-03-01 14:00", 1-Mar-2026, 03/01/2026,
+  "mar 1", today, tomorrow, yesterday, friday (today or later),
+  "next friday", "last week", "next month", "tomorrow 2pm", "friday at noon",
+  "in 3 days", "in 2 hours", "3 days ago", +3d, -2w, +1h30m, now,
+  eod (today 23:59), eow (Sunday 23:59), eom (end of month)
+A day alone (a date, "friday") means the whole day, e.g. an all-day event;
+relative times like "in 3 days" or +3d count from now.
+Ranges (cal free --within): "next week" and "this month" cover the whole
+week (Mon-Sun) or month, a day covers that day, 7d or 2w count from now,
+and "A to B" or A..B span from A to B.
+
 ## Meeting Invites (iTIP/iMIP)
 
 sog invite send <summary> <attendees>... --start <datetime> [flags]
-  --start          Start time
+  --start          Start time (e.g. 'tomorrow 2pm')
   --duration       Duration (default: 1h)
   --location       Location
   --description    Description
+  --calendar       Calendar to add the meeting to (default: primary)
+  --via            auto (default), server or email
+  --no-conflicts   Refuse if the meeting overlaps another event (default: warn)
+The meeting is added to your CalDAV calendar when one is configured. With
+--via auto the server delivers the invitations if it supports scheduling,
+otherwise they are emailed (iMIP); --via server fails without scheduling.
 
 sog invite reply <file> --status <accept|decline|tentative>
   --comment        Optional comment
 
 sog invite cancel <uid> <attendees>...
+  --calendar       Calendar the meeting is on (default: primary)
+Deletes the meeting from your calendar; attendees are notified by the
+server if it delivered the invitations, otherwise by email.
+
+sog invite update <uid> [flags]
+  --start/--end/--duration   New time (a new start keeps the duration)
+  --summary, --location, --description
+  --add <email>    Invite another attendee (repeatable)
+  --remove <email> Uninvite an attendee (repeatable)
+  --file <ics>     The last invitation, for meetings not on your calendar
+Bumps SEQUENCE and updates your calendar. Attendees whose invitation went
+by email (rather than through the server) are emailed: removed ones get a
+cancellation, added ones the invitation, and the others the update only if
+the meeting itself changed. Updates carry the whole series, with its
+exceptions and changed occurrences. Moving the meeting asks everyone to
+reply again.
+
+sog invite counter <file> --start <datetime>   Propose a new time
+  --end/--duration, --location, --comment
+sog invite decline-counter <file>   Reject a counter-proposal (--comment)
+sog invite refresh <file>           Ask the organizer for the latest version
 sog invite parse <file>          Parse .ics file
 sog invite preview <summary> <attendees>... --start <datetime>
 
+sog invite inbox                 List invitations, replies, cancellations,
+                                 counter-proposals and refreshes in mail
+sog invite inbox accept <uid>    Reply by email and add to the calendar
+                                 (also decline, tentative)
+  --comment        Comment for the organizer
+  --calendar       Calendar to add the meeting to (default: primary)
+sog invite inbox apply           Apply replies and cancellations to the calendar,
+                                 send the meeting to attendees asking for it
+sog invite inbox dismiss <uid>   Hide messages (--all for every pending one)
+  --folder         Folder to scan (default: INBOX)
+  --max            Recent messages to scan (default: 200)
+Updates replace the calendar's copy unless it is newer (higher SEQUENCE);
+declined invitations are only updated, not added. Handled messages are
+recorded in ~/.config/sog/invites/<account>.json.
+
 ## IMAP IDLE
 
 sog idle [folder]                Watch for new mail (push notifications)
   --timeout        Timeout in seconds
+  --invites        Apply replies and cancellations in new mail, announce
+                   invitations
 
 ## Output Formats
 
@@ -242,6 +556,9 @@ sog mail list --max 10
 # Send an email
 sog mail send --to user@example.com --subject "Hello" --body "Hi there"
 
+# Send from a template
+sog mail send --template weekly-status --var week=42
+
 # Today's calendar
 sog cal today
 
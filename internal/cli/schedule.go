@@ -0,0 +1,356 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/itip"
+)
+
+// CalInboxCmd handles the CalDAV scheduling inbox (RFC 6638).
+type CalInboxCmd struct {
+	List      CalInboxListCmd      `cmd:"" aliases:"ls" default:"1" help:"List pending scheduling messages"`
+	Accept    CalInboxAcceptCmd    `cmd:"" help:"Accept an invitation"`
+	Decline   CalInboxDeclineCmd   `cmd:"" help:"Decline an invitation"`
+	Tentative CalInboxTentativeCmd `cmd:"" help:"Tentatively accept an invitation"`
+	Delete    CalInboxDeleteCmd    `cmd:"" aliases:"rm" help:"Remove messages from the inbox"`
+}
+
+// CalInboxListCmd lists the scheduling inbox.
+type CalInboxListCmd struct{}
+
+// Run executes the cal inbox list command.
+func (c *CalInboxListCmd) Run(root *Root) error {
+	client, _, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	items, err := listInbox(context.Background(), client)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		fmt.Println("No scheduling messages.")
+		return nil
+	}
+
+	display, err := root.Cal.displayZone()
+	if err != nil {
+		return err
+	}
+	if root.JSON {
+		for _, item := range items {
+			item.Event = inZone([]caldav.Event{item.Event}, display)[0]
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		}
+		return nil
+	}
+
+	fmt.Printf("%-8s %-17s %-30s %s\n", "METHOD", "WHEN", "FROM", "SUMMARY")
+	for _, item := range items {
+		e := inZone([]caldav.Event{item.Event}, display)[0]
+		when := e.Start.Format("2006-01-02 15:04")
+		if e.AllDay {
+			when = e.Start.Format("2006-01-02")
+		}
+		fmt.Printf("%-8s %-17s %-30s %s\n", item.Method, when, inboxSender(item), inboxSummary(item))
+		fmt.Printf("         uid: %s\n", e.UID)
+	}
+	return nil
+}
+
+// inboxSender returns who sent a scheduling message: the organizer, or
+// for replies the attendee.
+func inboxSender(item caldav.InboxItem) string {
+	if item.Method == "REPLY" && len(item.Event.Attendees) > 0 {
+		return item.Event.Attendees[0]
+	}
+	return item.Event.Organizer
+}
+
+// inboxSummary describes a scheduling message.
+func inboxSummary(item caldav.InboxItem) string {
+	switch item.Method {
+	case "REPLY":
+		status := strings.ToLower(item.Event.PartStat.Get(inboxSender(item)))
+		if status == "" {
+			status = "replied"
+		}
+		return fmt.Sprintf("%s (%s)", item.Event.Summary, status)
+	case "CANCEL":
+		return item.Event.Summary + " (cancelled)"
+	case "REQUEST":
+		if item.Event.Sequence > 0 {
+			return item.Event.Summary + " (updated)"
+		}
+	}
+	return item.Event.Summary
+}
+
+// listInbox lists the scheduling inbox, explaining what to do instead on
+// servers without scheduling.
+func listInbox(ctx context.Context, client *caldav.Client) ([]caldav.InboxItem, error) {
+	items, err := client.ListInbox(ctx)
+	if errors.Is(err, caldav.ErrNoScheduling) {
+		return nil, fmt.Errorf("%w: invitations arrive by email; answer them with 'sog invite reply'", err)
+	}
+	return items, err
+}
+
+// CalInboxAcceptCmd accepts an invitation.
+type CalInboxAcceptCmd struct {
+	calInboxRespond
+}
+
+// Run executes the cal inbox accept command.
+func (c *CalInboxAcceptCmd) Run(root *Root) error {
+	return c.respond(root, caldav.PartStatAccepted)
+}
+
+// CalInboxDeclineCmd declines an invitation.
+type CalInboxDeclineCmd struct {
+	calInboxRespond
+}
+
+// Run executes the cal inbox decline command.
+func (c *CalInboxDeclineCmd) Run(root *Root) error {
+	return c.respond(root, caldav.PartStatDeclined)
+}
+
+// CalInboxTentativeCmd tentatively accepts an invitation.
+type CalInboxTentativeCmd struct {
+	calInboxRespond
+}
+
+// Run executes the cal inbox tentative command.
+func (c *CalInboxTentativeCmd) Run(root *Root) error {
+	return c.respond(root, caldav.PartStatTentative)
+}
+
+// calInboxRespond holds the flags shared by accept, decline and tentative.
+type calInboxRespond struct {
+	UID      string `arg:"" help:"Invitation UID (see 'sog cal inbox')"`
+	Calendar string `help:"Calendar for invitations the server has not put on one (default: primary)"`
+	Comment  string `help:"Comment for the organizer (sent with email replies)"`
+}
+
+// respond answers the latest invitation with the UID. Older messages for
+// the same meeting are removed as well.
+func (c *calInboxRespond) respond(root *Root, partstat string) error {
+	client, calPath, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if c.Calendar != "" {
+		calPath = c.Calendar
+	}
+
+	ctx := context.Background()
+	items, err := listInbox(ctx, client)
+	if err != nil {
+		return err
+	}
+	var latest *caldav.InboxItem
+	var older []caldav.InboxItem
+	for i := range items {
+		item := &items[i]
+		if item.Event.UID != c.UID || item.Method != "REQUEST" {
+			continue
+		}
+		if latest == nil || item.Event.Sequence >= latest.Event.Sequence {
+			if latest != nil {
+				older = append(older, *latest)
+			}
+			latest = item
+		} else {
+			older = append(older, *item)
+		}
+	}
+	if latest == nil {
+		return fmt.Errorf("no invitation %s in the scheduling inbox", c.UID)
+	}
+
+	event, err := client.Respond(ctx, latest, calPath, partstat)
+	if err != nil {
+		return err
+	}
+	for i := range older {
+		if err := client.DeleteInboxItem(ctx, &older[i]); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	// Servers without automatic scheduling don't send the reply
+	via := "server"
+	sched, err := client.Scheduling(ctx)
+	if err != nil {
+		return err
+	}
+	if !sched.AutoSchedule {
+		via = "email"
+		if err := c.sendReply(root, event, partstat); err != nil {
+			return err
+		}
+	}
+
+	if root.JSON {
+		fmt.Printf(`{"uid":"%s","partstat":"%s","organizer":"%s","via":"%s"}`+"\n", event.UID, partstat, event.Organizer, via)
+		return nil
+	}
+	fmt.Printf("%s: %s\n", strings.ToLower(partstat), event.Summary)
+	fmt.Printf("  Reply sent to %s by %s\n", event.Organizer, via)
+	return nil
+}
+
+// sendReply sends an iMIP REPLY to the organizer of event.
+func (c *calInboxRespond) sendReply(root *Root, event *caldav.Event, partstat string) error {
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return err
+	}
+	inv := &itip.Invite{UID: event.UID, Summary: event.Summary, Organizer: itip.Participant{Email: event.Organizer}}
+	resp := &itip.Response{
+		UID:       event.UID,
+		Attendee:  itip.Participant{Email: email, Status: itip.ParticipantStatus(partstat)},
+		Organizer: inv.Organizer,
+		Status:    itip.ParticipantStatus(partstat),
+		Comment:   c.Comment,
+		Sequence:  event.Sequence,
+	}
+	data, err := itip.CreateReply(resp)
+	if err != nil {
+		return fmt.Errorf("failed to create reply: %w", err)
+	}
+	if err := sendReplyEmail(cfg, email, inv, resp, data); err != nil {
+		return fmt.Errorf("failed to send reply: %w", err)
+	}
+	return nil
+}
+
+// CalInboxDeleteCmd removes messages from the scheduling inbox.
+type CalInboxDeleteCmd struct {
+	UID string `arg:"" optional:"" help:"UID of the messages to remove"`
+	All bool   `help:"Remove all messages"`
+}
+
+// Run executes the cal inbox delete command.
+func (c *CalInboxDeleteCmd) Run(root *Root) error {
+	if (c.UID == "") == !c.All {
+		return fmt.Errorf("give a UID or --all")
+	}
+	client, _, err := getCalDAVClient(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	items, err := listInbox(ctx, client)
+	if err != nil {
+		return err
+	}
+	deleted := 0
+	for i := range items {
+		if !c.All && items[i].Event.UID != c.UID {
+			continue
+		}
+		if err := client.DeleteInboxItem(ctx, &items[i]); err != nil {
+			return err
+		}
+		deleted++
+	}
+	if deleted == 0 && !c.All {
+		return fmt.Errorf("no message %s in the scheduling inbox", c.UID)
+	}
+	fmt.Printf("Removed %d message(s)\n", deleted)
+	return nil
+}
+
+// scheduleInvite puts a meeting on the organizer's calendar. On servers
+// with CalDAV scheduling the server delivers the invitations, unless via
+// is "email"; delivered reports whether it does. Without a CalDAV account
+// nothing happens, unless via is "server".
+func scheduleInvite(root *Root, calendar, via string, inv *itip.Invite) (added, delivered bool, err error) {
+	client, calPath, err := getCalDAVClient(root)
+	if err != nil {
+		if via == "server" {
+			return false, false, err
+		}
+		return false, false, nil
+	}
+	defer client.Close()
+	if calendar != "" {
+		calPath = calendar
+	}
+
+	ctx := context.Background()
+	auto := false
+	if sched, err := client.Scheduling(ctx); err == nil {
+		auto = sched.AutoSchedule
+	}
+	if via == "server" && !auto {
+		return false, false, fmt.Errorf("the CalDAV server does not support scheduling; use --via email")
+	}
+	deliver := auto && via != "email"
+
+	event := &caldav.Event{
+		UID:         inv.UID,
+		Summary:     inv.Summary,
+		Description: inv.Description,
+		Location:    inv.Location,
+		Start:       inv.Start,
+		End:         inv.End,
+		Organizer:   inv.Organizer.Email,
+		Sequence:    inv.Sequence,
+	}
+	for _, att := range inv.Attendees {
+		event.Attendees = append(event.Attendees, att.Email)
+	}
+	if err := client.CreateInvitation(ctx, calPath, event, deliver); err != nil {
+		if via == "server" {
+			return false, false, err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: could not add the meeting to your calendar: %v\n", err)
+		return false, false, nil
+	}
+	return true, deliver, nil
+}
+
+// cancelScheduled removes a meeting from the organizer's calendar,
+// reporting whether the server notifies the attendees and the event's
+// SEQUENCE. Meetings not on the calendar are left to email.
+func cancelScheduled(root *Root, calendar, uid string) (delivered bool, sequence int) {
+	client, calPath, err := getCalDAVClient(root)
+	if err != nil {
+		return false, 0
+	}
+	defer client.Close()
+	if calendar != "" {
+		calPath = calendar
+	}
+	event, delivered, err := client.CancelInvitation(context.Background(), calPath, uid)
+	if err != nil {
+		return false, 0
+	}
+	return delivered, event.Sequence
+}
+
+// formatDelivery describes how invitations were sent.
+func formatDelivery(delivered bool) string {
+	if delivered {
+		return "CalDAV server"
+	}
+	return "email"
+}
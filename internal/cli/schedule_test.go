@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+func TestInboxSummary(t *testing.T) {
+	request := caldav.InboxItem{Method: "REQUEST", Event: caldav.Event{
+		Summary:   "Planning",
+		Organizer: "alice@example.com",
+		Attendees: []string{"me@example.com"},
+	}}
+	assert.Equal(t, "alice@example.com", inboxSender(request))
+	assert.Equal(t, "Planning", inboxSummary(request))
+
+	request.Event.Sequence = 1
+	assert.Equal(t, "Planning (updated)", inboxSummary(request))
+
+	reply := caldav.InboxItem{Method: "REPLY", Event: caldav.Event{
+		Summary:   "Planning",
+		Organizer: "me@example.com",
+		Attendees: []string{"Bob@example.com"},
+		PartStat:  caldav.PartStats{"bob@example.com": caldav.PartStatDeclined},
+	}}
+	assert.Equal(t, "Bob@example.com", inboxSender(reply))
+	assert.Equal(t, "Planning (declined)", inboxSummary(reply))
+
+	cancel := caldav.InboxItem{Method: "CANCEL", Event: caldav.Event{Summary: "Planning"}}
+	assert.Equal(t, "Planning (cancelled)", inboxSummary(cancel))
+}
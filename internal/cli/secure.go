@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/mimepart"
+	"github.com/visionik/sogcli/internal/pgp"
+	"github.com/visionik/sogcli/internal/smime"
+	"github.com/visionik/sogcli/internal/smtp"
+)
+
+// SecurityFlags are the signing and encryption options shared by the
+// commands that send mail.
+type SecurityFlags struct {
+	Sign         bool `help:"Sign with OpenPGP (PGP/MIME)"`
+	Encrypt      bool `help:"Encrypt with OpenPGP to all recipients (PGP/MIME)"`
+	SMIMESign    bool `help:"Sign with the identity's S/MIME certificate" name:"smime-sign"`
+	SMIMEEncrypt bool `help:"Encrypt with S/MIME to all recipients" name:"smime-encrypt"`
+}
+
+// protectMessage signs and/or encrypts msg with PGP/MIME or S/MIME.
+// Messages are encrypted to all recipients and to the sender, so the sent
+// copy stays readable.
+func protectMessage(acct *config.Account, msg *smtp.Message, id *config.Identity, flags SecurityFlags) error {
+	usePGP := flags.Sign || flags.Encrypt
+	useSMIME := flags.SMIMESign || flags.SMIMEEncrypt
+	switch {
+	case usePGP && useSMIME:
+		return fmt.Errorf("use either OpenPGP (--sign/--encrypt) or S/MIME (--smime-sign/--smime-encrypt), not both")
+	case usePGP:
+		return protectPGP(acct, msg, id, flags.Sign, flags.Encrypt)
+	case useSMIME:
+		return protectSMIME(acct, msg, id, flags.SMIMESign, flags.SMIMEEncrypt)
+	}
+	return nil
+}
+
+// protectMessages protects msg like protectMessage and returns the
+// messages to send. An encrypted message names the keys it is encrypted
+// to, so Bcc recipients other than the sender are taken out of msg and
+// each get a copy of their own, encrypted to the visible recipients, the
+// sender and them.
+func protectMessages(acct *config.Account, msg *smtp.Message, id *config.Identity, flags SecurityFlags) ([]*smtp.Message, error) {
+	msgs := []*smtp.Message{msg}
+	if flags.Encrypt || flags.SMIMEEncrypt {
+		var bcc []string
+		for _, addr := range msg.Bcc {
+			if strings.EqualFold(addr, id.Email) {
+				bcc = append(bcc, addr)
+				continue
+			}
+			cp := *msg
+			cp.Bcc = []string{addr}
+			cp.Recipients = []string{addr}
+			msgs = append(msgs, &cp)
+		}
+		msg.Bcc = bcc
+	}
+	for _, m := range msgs {
+		if err := protectMessage(acct, m, id, flags); err != nil {
+			return nil, err
+		}
+	}
+	return msgs, nil
+}
+
+// encryptionRecipients returns the unique recipient addresses of msg plus
+// the sender. Bcc recipients are included, so msg should only Bcc the
+// sender or be a copy for one Bcc recipient; see protectMessages.
+func encryptionRecipients(msg *smtp.Message, id *config.Identity) []string {
+	seen := map[string]bool{}
+	var recipients []string
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc, {id.Email}} {
+		for _, addr := range list {
+			key := strings.ToLower(addr)
+			if addr == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			recipients = append(recipients, addr)
+		}
+	}
+	return recipients
+}
+
+// isSecureMessage reports whether a message is PGP or S/MIME protected.
+func isSecureMessage(raw []byte) bool {
+	return pgp.IsPGP(raw) || smime.IsSMIME(raw)
+}
+
+// isEncryptedMessage reports whether a message is PGP or S/MIME encrypted.
+func isEncryptedMessage(raw []byte) bool {
+	return pgp.IsEncrypted(raw) || smime.IsEncrypted(raw)
+}
+
+// secureResult is a decrypted and/or verified message.
+type secureResult struct {
+	Kind      string // "pgp" or "smime"
+	Encrypted bool
+	PGP       *pgp.Signature
+	SMIME     *smime.Signature
+}
+
+// signature returns the signature for JSON output, or nil if unsigned.
+func (r *secureResult) signature() any {
+	switch {
+	case r.PGP != nil:
+		return r.PGP
+	case r.SMIME != nil:
+		return r.SMIME
+	}
+	return nil
+}
+
+// print prints the encryption and signature status.
+func (r *secureResult) print() {
+	if r.Encrypted {
+		fmt.Printf("Encrypted: yes (%s)\n", map[string]string{"pgp": "OpenPGP", "smime": "S/MIME"}[r.Kind])
+	}
+	if r.PGP != nil {
+		fmt.Printf("Signature: %s\n", r.PGP)
+	}
+	if r.SMIME != nil {
+		fmt.Printf("Signature: %s\n", r.SMIME)
+		if len(r.SMIME.Chain) > 0 {
+			fmt.Println("Certificate chain:")
+			for _, c := range r.SMIME.Chain {
+				fmt.Printf("  %s (issued by %s, valid %s to %s)\n", c.Subject, c.Issuer,
+					c.NotBefore.Format("2006-01-02"), c.NotAfter.Format("2006-01-02"))
+			}
+		}
+	}
+}
+
+// openSecureMessage decrypts and verifies a PGP or S/MIME message sent by
+// the address from. It returns the readable text and the result, or a nil
+// result if the message is neither. The certificate of a trusted S/MIME
+// signature is saved for from.
+func openSecureMessage(acct *config.Account, raw []byte, from string) (string, *secureResult, error) {
+	var entity []byte
+	var result *secureResult
+
+	switch {
+	case pgp.IsPGP(raw):
+		backend, err := newPGPBackend(acct)
+		if err != nil {
+			return "", nil, err
+		}
+		r, err := pgp.Open(backend, raw)
+		if err != nil {
+			return "", nil, err
+		}
+		entity = r.Entity
+		result = &secureResult{Kind: "pgp", Encrypted: r.Encrypted, PGP: r.Signature}
+
+	case smime.IsSMIME(raw):
+		r, err := smime.Open(raw, smimeIdentities(acct), nil)
+		if err != nil {
+			return "", nil, err
+		}
+		if r.Signer != nil && r.Signature.Status == smime.SignatureGood && from != "" {
+			harvestCertificate(r.Signer, from)
+		}
+		entity = r.Entity
+		result = &secureResult{Kind: "smime", Encrypted: r.Encrypted, SMIME: r.Signature}
+
+	default:
+		return string(raw), nil, nil
+	}
+
+	part, err := mimepart.Parse(entity)
+	if err != nil {
+		return "", nil, err
+	}
+	return part.Text(), result, nil
+}
@@ -0,0 +1,342 @@
+package cli
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/smime"
+	"github.com/visionik/sogcli/internal/smtp"
+)
+
+// SMIMECmd manages S/MIME certificates.
+type SMIMECmd struct {
+	List       SMIMEListCmd       `cmd:"" aliases:"ls" default:"1" help:"List stored recipient certificates"`
+	Import     SMIMEImportCmd     `cmd:"" help:"Import recipient certificates (PEM or DER)"`
+	Export     SMIMEExportCmd     `cmd:"" help:"Export a certificate (PEM)"`
+	Delete     SMIMEDeleteCmd     `cmd:"" aliases:"rm" help:"Delete a stored certificate"`
+	Identities SMIMEIdentitiesCmd `cmd:"" help:"Show the account's S/MIME signing certificates"`
+}
+
+// SMIMEListCmd lists stored recipient certificates.
+type SMIMEListCmd struct{}
+
+// Run executes the smime list command.
+func (c *SMIMEListCmd) Run(root *Root) error {
+	store, err := smimeStore()
+	if err != nil {
+		return err
+	}
+
+	certs, err := store.List()
+	if err != nil {
+		return err
+	}
+	if root.JSON {
+		if certs == nil {
+			certs = []smime.StoredCert{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(certs)
+	}
+
+	if len(certs) == 0 {
+		fmt.Println("No certificates. They are saved from signed mail you read, or import one with: sog smime import <file>")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EMAIL\tEXPIRES\tSUBJECT\tISSUER")
+	for _, cert := range certs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", cert.Email, cert.NotAfter.Format("2006-01-02"), cert.Subject, cert.Issuer)
+	}
+	return w.Flush()
+}
+
+// SMIMEImportCmd imports recipient certificates.
+type SMIMEImportCmd struct {
+	File string `arg:"" help:"Certificate file (PEM or DER; '-' for stdin)"`
+}
+
+// Run executes the smime import command.
+func (c *SMIMEImportCmd) Run(root *Root) error {
+	var data []byte
+	var err error
+	if c.File == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(c.File)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	certs, err := smime.ReadCertificates(data)
+	if err != nil {
+		return err
+	}
+	store, err := smimeStore()
+	if err != nil {
+		return err
+	}
+
+	imported := 0
+	for _, cert := range certs {
+		emails, err := store.Import(cert)
+		if err != nil {
+			return err
+		}
+		for _, email := range emails {
+			fmt.Printf("Imported certificate for %s (%s, expires %s)\n", email, cert.Subject, cert.NotAfter.Format("2006-01-02"))
+			imported++
+		}
+	}
+	if imported == 0 {
+		fmt.Println("No new certificates (certificates need an email address)")
+	}
+	return nil
+}
+
+// SMIMEExportCmd exports a certificate.
+type SMIMEExportCmd struct {
+	Email string `arg:"" help:"Email address (a stored certificate or one of your identities)"`
+}
+
+// Run executes the smime export command.
+func (c *SMIMEExportCmd) Run(root *Root) error {
+	// Own identities first, so the certificate can be shared with others
+	if cfg, email, err := loadAccountConfig(root); err == nil {
+		if acct, err := cfg.GetAccount(email); err == nil {
+			if id, err := acct.FindIdentity(c.Email); err == nil && id.SMIMECert != "" {
+				sid, err := loadSMIMEIdentity(acct, id)
+				if err != nil {
+					return err
+				}
+				for _, cert := range append([]*x509.Certificate{sid.Certificate}, sid.Chain...) {
+					pem.Encode(os.Stdout, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+				}
+				return nil
+			}
+		}
+	}
+
+	store, err := smimeStore()
+	if err != nil {
+		return err
+	}
+	cert, err := store.Lookup(c.Email)
+	if err != nil {
+		return err
+	}
+	return pem.Encode(os.Stdout, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// SMIMEDeleteCmd deletes a stored certificate.
+type SMIMEDeleteCmd struct {
+	Email string `arg:"" help:"Email address"`
+}
+
+// Run executes the smime delete command.
+func (c *SMIMEDeleteCmd) Run(root *Root) error {
+	store, err := smimeStore()
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(c.Email); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted certificate for %s\n", c.Email)
+	return nil
+}
+
+// SMIMEIdentitiesCmd shows the S/MIME certificates of the account's
+// sending identities.
+type SMIMEIdentitiesCmd struct{}
+
+// Run executes the smime identities command.
+func (c *SMIMEIdentitiesCmd) Run(root *Root) error {
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return err
+	}
+	acct, err := cfg.GetAccount(email)
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		Identity string           `json:"identity"`
+		File     string           `json:"file"`
+		Chain    []smime.CertInfo `json:"chain,omitempty"`
+		Error    string           `json:"error,omitempty"`
+	}
+	var entries []entry
+	for _, id := range acct.SendingIdentities() {
+		if id.SMIMECert == "" {
+			continue
+		}
+		e := entry{Identity: id.Email, File: id.SMIMECert}
+		sid, err := loadSMIMEIdentity(acct, &id)
+		if err != nil {
+			e.Error = err.Error()
+		} else {
+			for _, cert := range append([]*x509.Certificate{sid.Certificate}, sid.Chain...) {
+				e.Chain = append(e.Chain, smime.Info(cert))
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	if root.JSON {
+		if entries == nil {
+			entries = []entry{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No S/MIME certificates configured. Add one with: sog auth identity add <address> --smime-cert <file.p12>")
+		return nil
+	}
+	for _, e := range entries {
+		fmt.Printf("%s (%s)\n", e.Identity, e.File)
+		if e.Error != "" {
+			fmt.Printf("  Error: %s\n", e.Error)
+			continue
+		}
+		for _, cert := range e.Chain {
+			fmt.Printf("  %s (issued by %s, valid %s to %s)\n", cert.Subject, cert.Issuer,
+				cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+		}
+	}
+	return nil
+}
+
+// smimeStore opens the recipient certificate store.
+func smimeStore() (*smime.Store, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return smime.OpenStore(filepath.Join(dir, "smime", "certs")), nil
+}
+
+// loadSMIMEIdentity loads an identity's certificate and key. PKCS#12
+// passwords come from 'sog auth password --smime'.
+func loadSMIMEIdentity(acct *config.Account, id *config.Identity) (*smime.Identity, error) {
+	if id.SMIMECert == "" {
+		return nil, fmt.Errorf("no S/MIME certificate for %s (set with 'sog auth identity add %s --smime-cert <file>')", id.Email, id.Email)
+	}
+
+	sid, err := smime.LoadIdentity(id.SMIMECert, id.SMIMEKey, "")
+	if errors.Is(err, smime.ErrPasswordRequired) {
+		password, perr := config.GetPassphrase(acct.Email, config.ProtocolSMIME)
+		if perr != nil {
+			return nil, perr
+		}
+		sid, err = smime.LoadIdentity(id.SMIMECert, id.SMIMEKey, password)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load S/MIME certificate for %s: %w", id.Email, err)
+	}
+	return sid, nil
+}
+
+// smimeIdentities loads the S/MIME certificates of all sending identities
+// for decryption. Identities that fail to load are skipped.
+func smimeIdentities(acct *config.Account) []*smime.Identity {
+	var ids []*smime.Identity
+	for _, id := range acct.SendingIdentities() {
+		if id.SMIMECert == "" {
+			continue
+		}
+		sid, err := loadSMIMEIdentity(acct, &id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		ids = append(ids, sid)
+	}
+	return ids
+}
+
+// harvestCertificate saves the certificate of a trusted signature for the
+// message's sender so mail to them can be encrypted later. Only the From
+// address is saved, and only if no certificate is stored for it yet.
+func harvestCertificate(cert *x509.Certificate, from string) {
+	store, err := smimeStore()
+	if err != nil {
+		return
+	}
+	if existing, err := store.Lookup(from); err == nil {
+		if !existing.Equal(cert) {
+			fmt.Fprintf(os.Stderr, "Note: %s signed with a different S/MIME certificate than the stored one; import it with 'sog smime import' if you trust it\n", from)
+		}
+		return
+	}
+	added, err := store.Add(cert, from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save S/MIME certificate: %v\n", err)
+		return
+	}
+	if added {
+		fmt.Fprintf(os.Stderr, "Saved S/MIME certificate for %s\n", strings.ToLower(from))
+	}
+}
+
+// protectSMIME signs and/or encrypts msg with S/MIME. Signed messages are
+// signed first and then encrypted.
+func protectSMIME(acct *config.Account, msg *smtp.Message, id *config.Identity, sign, encrypt bool) error {
+	var own *smime.Identity
+	if sign || id.SMIMECert != "" {
+		var err error
+		if own, err = loadSMIMEIdentity(acct, id); err != nil {
+			return err
+		}
+	}
+
+	entity := smtp.BuildEntity(msg)
+	if sign {
+		signed, err := smime.Sign(entity, own)
+		if err != nil {
+			return err
+		}
+		entity = signed
+	}
+
+	if encrypt {
+		store, err := smimeStore()
+		if err != nil {
+			return err
+		}
+
+		// The sender's own certificate comes from the identity when set
+		var others []string
+		for _, r := range encryptionRecipients(msg, id) {
+			if own == nil || !strings.EqualFold(r, id.Email) {
+				others = append(others, r)
+			}
+		}
+		certs, err := store.Recipients(others)
+		if err != nil {
+			return err
+		}
+		if own != nil {
+			certs = append(certs, own.Certificate)
+		}
+
+		if entity, err = smime.Encrypt(entity, certs); err != nil {
+			return err
+		}
+	}
+
+	msg.Entity = entity
+	return nil
+}
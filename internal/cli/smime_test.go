@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/smime"
+	"github.com/visionik/sogcli/internal/smtp"
+)
+
+// writeTestCertificate writes a self-signed S/MIME certificate and key for
+// email as one PEM file and returns its path and certificate.
+func writeTestCertificate(t *testing.T, email string) (string, *x509.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(time.Now().UnixNano()),
+		Subject:        pkix.Name{CommonName: email},
+		EmailAddresses: []string{email},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})...)
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path, cert
+}
+
+func TestProtectAndOpenSMIMEMessage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	myCert, _ := writeTestCertificate(t, "me@example.com")
+	_, bobCert := writeTestCertificate(t, "bob@example.com")
+	store, err := smimeStore()
+	require.NoError(t, err)
+	_, err = store.Import(bobCert)
+	require.NoError(t, err)
+
+	acct := &config.Account{
+		Email:      "me@example.com",
+		Identities: []config.Identity{{Email: "me@example.com", SMIMECert: myCert}},
+	}
+	id := acct.DefaultIdentity()
+
+	// Carol has no certificate yet
+	msg := &smtp.Message{To: []string{"carol@example.com"}, Subject: "Secret", Body: "Hello\n"}
+	err = protectMessage(acct, msg, &id, SecurityFlags{SMIMEEncrypt: true})
+	var missing *smime.MissingCertError
+	require.ErrorAs(t, err, &missing)
+	assert.Equal(t, []string{"carol@example.com"}, missing.Recipients)
+
+	msg = &smtp.Message{To: []string{"bob@example.com"}, Subject: "Secret", Body: "Hello Bob\n"}
+	require.NoError(t, protectMessage(acct, msg, &id, SecurityFlags{SMIMESign: true, SMIMEEncrypt: true}))
+	assert.True(t, smime.IsEncrypted(msg.Entity))
+
+	// The sender can read the sent copy; the self-signed certificate of the
+	// signer is not trusted, so it is not saved
+	raw := append([]byte("Subject: Secret\r\nMIME-Version: 1.0\r\n"), msg.Entity...)
+	text, result, err := openSecureMessage(acct, raw, "me@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "smime", result.Kind)
+	assert.True(t, result.Encrypted)
+	require.NotNil(t, result.SMIME)
+	assert.Equal(t, smime.SignatureUntrusted, result.SMIME.Status)
+	assert.Contains(t, text, "Hello Bob")
+
+	_, err = store.Lookup("me@example.com")
+	assert.Error(t, err)
+
+	err = protectMessage(acct, msg, &id, SecurityFlags{Sign: true, SMIMESign: true})
+	assert.Error(t, err)
+}
+
+func TestProtectMessagesBcc(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	myCert, _ := writeTestCertificate(t, "me@example.com")
+	store, err := smimeStore()
+	require.NoError(t, err)
+	for _, email := range []string{"bob@example.com", "dave@example.com"} {
+		_, cert := writeTestCertificate(t, email)
+		_, err = store.Import(cert)
+		require.NoError(t, err)
+	}
+	acct := &config.Account{
+		Email:      "me@example.com",
+		Identities: []config.Identity{{Email: "me@example.com", SMIMECert: myCert}},
+	}
+	id := acct.DefaultIdentity()
+
+	msg := &smtp.Message{
+		To:      []string{"bob@example.com"},
+		Bcc:     []string{"dave@example.com", "me@example.com"},
+		Subject: "Secret",
+		Body:    "Hello\n",
+	}
+	msgs, err := protectMessages(acct, msg, &id, SecurityFlags{SMIMEEncrypt: true})
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, []string{"me@example.com"}, msgs[0].Bcc)
+	assert.Nil(t, msgs[0].Recipients)
+	assert.Equal(t, []string{"dave@example.com"}, msgs[1].Recipients)
+	assert.Equal(t, []string{"bob@example.com"}, msgs[1].To)
+	for _, m := range msgs {
+		assert.True(t, smime.IsEncrypted(m.Entity))
+	}
+	assert.NotEqual(t, msgs[0].Entity, msgs[1].Entity)
+
+	// Without encryption Bcc recipients share the message
+	msg = &smtp.Message{To: []string{"bob@example.com"}, Bcc: []string{"dave@example.com"}, Body: "Hi\n"}
+	msgs, err = protectMessages(acct, msg, &id, SecurityFlags{SMIMESign: true})
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, []string{"dave@example.com"}, msgs[0].Bcc)
+}
+
+func TestHarvestCertificate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := smimeStore()
+	require.NoError(t, err)
+	_, bob := writeTestCertificate(t, "bob@example.com")
+	_, mallory := writeTestCertificate(t, "bob@example.com")
+
+	// Only for the sender's address
+	harvestCertificate(bob, "alice@example.com")
+	_, err = store.Lookup("alice@example.com")
+	assert.Error(t, err)
+	_, err = store.Lookup("bob@example.com")
+	assert.Error(t, err)
+
+	harvestCertificate(bob, "Bob@example.com")
+	cert, err := store.Lookup("bob@example.com")
+	require.NoError(t, err)
+	assert.True(t, cert.Equal(bob))
+
+	// A stored certificate is not replaced by another signer's
+	harvestCertificate(mallory, "bob@example.com")
+	cert, err = store.Lookup("bob@example.com")
+	require.NoError(t, err)
+	assert.True(t, cert.Equal(bob))
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/webcal"
+)
+
+// CalSubscribeCmd subscribes to a read-only iCalendar feed.
+type CalSubscribeCmd struct {
+	URL  string `arg:"" optional:"" help:"Feed URL (webcal://, https://)"`
+	Name string `required:"" help:"Name to show the feed's events under"`
+	File string `help:"Subscribe to a local .ics file instead of a URL" type:"path"`
+}
+
+// Run executes the cal subscribe command.
+func (c *CalSubscribeCmd) Run(root *Root) error {
+	if (c.URL == "") == (c.File == "") {
+		return fmt.Errorf("give either a feed URL or --file")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	sub := config.Subscription{Name: c.Name, URL: c.URL}
+	if c.File != "" {
+		if sub.File, err = filepath.Abs(c.File); err != nil {
+			return fmt.Errorf("invalid --file: %w", err)
+		}
+	}
+	if cfg.FindSubscription(sub.Name) != nil {
+		return fmt.Errorf("subscription already exists: %s", sub.Name)
+	}
+
+	// Fetch the feed once, so a bad URL is caught now and the cache is warm
+	feed, err := subscriptionFeed(&sub)
+	if err != nil {
+		return err
+	}
+	cal, err := feed.Load(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := cfg.AddSubscription(sub); err != nil {
+		return err
+	}
+
+	events := 0
+	for _, child := range cal.Children {
+		if child.Name == "VEVENT" {
+			events++
+		}
+	}
+	fmt.Printf("Subscribed to %s (%d events)\n", sub.Name, events)
+	return nil
+}
+
+// CalUnsubscribeCmd removes a subscription.
+type CalUnsubscribeCmd struct {
+	Name string `arg:"" help:"Subscription name"`
+}
+
+// Run executes the cal unsubscribe command.
+func (c *CalUnsubscribeCmd) Run(root *Root) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	sub := cfg.FindSubscription(c.Name)
+	if sub == nil {
+		return fmt.Errorf("subscription not found: %s", c.Name)
+	}
+	feed, err := subscriptionFeed(sub)
+	if err != nil {
+		return err
+	}
+	name := sub.Name
+	if err := cfg.RemoveSubscription(name); err != nil {
+		return err
+	}
+	if err := feed.RemoveCache(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	fmt.Printf("Unsubscribed from %s\n", name)
+	return nil
+}
+
+// CalSubscriptionsCmd lists subscriptions.
+type CalSubscriptionsCmd struct{}
+
+// Run executes the cal subscriptions command.
+func (c *CalSubscriptionsCmd) Run(root *Root) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if len(cfg.Subscriptions) == 0 {
+		fmt.Println("No subscriptions.")
+		return nil
+	}
+	for _, sub := range cfg.Subscriptions {
+		if root.JSON {
+			fmt.Printf(`{"name":"%s","url":"%s","file":"%s"}`+"\n", sub.Name, sub.URL, sub.File)
+			continue
+		}
+		fmt.Printf("%-20s %s\n", sub.Name, sub.Source())
+	}
+	return nil
+}
+
+// subscriptionFeed returns the feed for a subscription, cached under the
+// config directory.
+func subscriptionFeed(sub *config.Subscription) (*webcal.Feed, error) {
+	feed := &webcal.Feed{Name: sub.Name, URL: sub.URL, File: sub.File}
+	if sub.File == "" {
+		dir, err := config.Dir()
+		if err != nil {
+			return nil, err
+		}
+		feed.Cache = filepath.Join(dir, "subscriptions", unsafeFileChars.ReplaceAllString(sub.Name, "_")+".ics")
+	}
+	return feed, nil
+}
+
+// subscriptionEvents returns the events of subscriptions in [start, end).
+// Feeds that cannot be read are skipped with a warning.
+func subscriptionEvents(ctx context.Context, subs []config.Subscription, start, end time.Time) ([]caldav.Event, error) {
+	var events []caldav.Event
+	for i := range subs {
+		feed, err := subscriptionFeed(&subs[i])
+		if err != nil {
+			return nil, err
+		}
+		feedEvents, err := feed.Events(ctx, start, end)
+		switch {
+		case errors.Is(err, webcal.ErrStale):
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", subs[i].Name, err)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", subs[i].Name, err)
+			continue
+		}
+		events = append(events, feedEvents...)
+	}
+	return events, nil
+}
+
+// hasCalDAV reports whether the account in use has a CalDAV server.
+func hasCalDAV(cfg *config.Config, root *Root) bool {
+	email := root.Account
+	if email == "" {
+		email = cfg.DefaultAccount
+	}
+	acct, ok := cfg.Accounts[email]
+	return ok && acct.CalDAV.URL != ""
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/config"
+)
+
+func TestAgendaEventsSubscriptions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	feed := func(name, uid, day string) string {
+		path := filepath.Join(home, name+".ics")
+		data := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\n" +
+			"BEGIN:VEVENT\r\nUID:" + uid + "\r\nDTSTAMP:20260101T000000Z\r\n" +
+			"DTSTART;VALUE=DATE:" + day + "\r\nSUMMARY:" + name + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+		require.NoError(t, os.WriteFile(path, []byte(data), 0600))
+		return path
+	}
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	require.NoError(t, cfg.AddSubscription(config.Subscription{Name: "holidays", File: feed("holidays", "h@example.com", "20261225")}))
+	require.NoError(t, cfg.AddSubscription(config.Subscription{Name: "sports", File: feed("sports", "s@example.com", "20261201")}))
+	require.NoError(t, cfg.AddSubscription(config.Subscription{Name: "broken", File: filepath.Join(home, "missing.ics")}))
+
+	start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 2, 0)
+
+	// Without a CalDAV account, all readable subscriptions are merged
+	events, _, err := agendaEvents(&Root{}, AgendaFlags{}, start, end)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "sports", events[0].Source)
+	assert.Equal(t, "holidays", events[1].Source)
+
+	events, _, err = agendaEvents(&Root{}, AgendaFlags{Calendars: []string{"Holidays"}}, start, end)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "holidays", events[0].Summary)
+
+	_, _, err = agendaEvents(&Root{}, AgendaFlags{Calendars: []string{"broken"}}, start, end)
+	assert.Error(t, err)
+}
@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+// taskFinished reports whether a task no longer needs doing.
+func taskFinished(t *caldav.Task) bool {
+	return t.Status == caldav.TaskStatusCompleted || t.Status == caldav.TaskStatusCancelled
+}
+
+// treeTask is a task in a task tree with its depth below the top level.
+type treeTask struct {
+	caldav.Task
+	Depth int
+}
+
+// taskTree orders tasks as a tree: each task is followed by its sub-tasks.
+// A task is a sub-task of another if it names it as PARENT or the other
+// lists it as CHILD. Tasks whose parent is not in tasks are top-level.
+// Otherwise the order of tasks is kept.
+func taskTree(tasks []caldav.Task) []treeTask {
+	byUID := make(map[string]int, len(tasks))
+	for i, t := range tasks {
+		byUID[t.UID] = i
+	}
+	parent := make([]int, len(tasks))
+	for i, t := range tasks {
+		parent[i] = -1
+		if p, ok := byUID[t.Parent]; ok && p != i {
+			parent[i] = p
+		}
+	}
+	for i, t := range tasks {
+		for _, uid := range t.Children {
+			if c, ok := byUID[uid]; ok && c != i && parent[c] == -1 {
+				parent[c] = i
+			}
+		}
+	}
+	children := make([][]int, len(tasks))
+	for i, p := range parent {
+		if p >= 0 {
+			children[p] = append(children[p], i)
+		}
+	}
+
+	out := make([]treeTask, 0, len(tasks))
+	seen := make([]bool, len(tasks))
+	var walk func(i, depth int)
+	walk = func(i, depth int) {
+		if seen[i] {
+			return
+		}
+		seen[i] = true
+		out = append(out, treeTask{Task: tasks[i], Depth: depth})
+		for _, c := range children[i] {
+			walk(c, depth+1)
+		}
+	}
+	for i := range tasks {
+		if parent[i] == -1 {
+			walk(i, 0)
+		}
+	}
+	// Tasks in a parent cycle have no top-level ancestor
+	for i := range tasks {
+		walk(i, 0)
+	}
+	return out
+}
+
+// openDescendants returns the unfinished sub-tasks of the task uid, at
+// any depth.
+func openDescendants(tasks []caldav.Task, uid string) []caldav.Task {
+	var out []caldav.Task
+	tree := taskTree(tasks)
+	for i, t := range tree {
+		if t.UID != uid {
+			continue
+		}
+		for _, sub := range tree[i+1:] {
+			if sub.Depth <= t.Depth {
+				break
+			}
+			if !taskFinished(&sub.Task) {
+				out = append(out, sub.Task)
+			}
+		}
+		break
+	}
+	return out
+}
+
+// blockers returns the dependencies of t that are unfinished, by UID.
+// Dependencies that are not in tasks count as unfinished.
+func blockers(t *caldav.Task, tasks []caldav.Task) []string {
+	var out []string
+	for _, uid := range t.DependsOn {
+		finished := false
+		for i := range tasks {
+			if tasks[i].UID == uid {
+				finished = taskFinished(&tasks[i])
+				break
+			}
+		}
+		if !finished {
+			out = append(out, uid)
+		}
+	}
+	return out
+}
+
+// outputTasksTree outputs tasks as a table with sub-tasks indented under
+// their parent.
+func outputTasksTree(tasks []caldav.Task) error {
+	fmt.Printf("%-4s %-12s %-8s %s\n", "PRI", "DUE", "STATUS", "SUMMARY")
+	for _, t := range taskTree(tasks) {
+		pri := "-"
+		if t.Priority > 0 {
+			pri = fmt.Sprintf("%d", t.Priority)
+		}
+		due := "-"
+		if !t.Due.IsZero() {
+			due = t.Due.Format("2006-01-02")
+		}
+		summary := t.Summary
+		if len(summary) > 50 {
+			summary = summary[:47] + "..."
+		}
+		if t.Depth > 0 {
+			summary = strings.Repeat("  ", t.Depth-1) + "└ " + summary
+		}
+		fmt.Printf("%-4s %-12s %-8s %s\n", pri, due, statusShort(t.Status), summary)
+	}
+	return nil
+}
+
+// completeSubtasks completes the open sub-tasks of the task uid, or with
+// all false only mentions them.
+func completeSubtasks(ctx context.Context, client *caldav.Client, listPath, uid string, all bool) error {
+	tasks, err := client.ListTasks(ctx, listPath, true)
+	if err != nil {
+		return fmt.Errorf("failed to list sub-tasks: %w", err)
+	}
+	open := openDescendants(tasks, uid)
+	if len(open) == 0 {
+		return nil
+	}
+	if !all {
+		fmt.Fprintf(os.Stderr, "Note: %d sub-task(s) still open; use --children to complete them too\n", len(open))
+		return nil
+	}
+	for _, t := range open {
+		if _, err := client.CompleteTask(ctx, listPath, t.UID); err != nil {
+			return fmt.Errorf("failed to complete sub-task %s: %w", t.UID, conflictHint(err, false))
+		}
+		fmt.Printf("Completed sub-task: %s\n", t.UID)
+	}
+	return nil
+}
+
+// TasksBlockedCmd lists tasks waiting on unfinished dependencies.
+type TasksBlockedCmd struct {
+	List string `arg:"" optional:"" help:"Task list path (default: primary)"`
+}
+
+// Run executes the tasks blocked command.
+func (c *TasksBlockedCmd) Run(root *Root) error {
+	client, listPath, err := getCalDAVClientForTasks(root)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if c.List != "" {
+		listPath = c.List
+	}
+
+	ctx := context.Background()
+	tasks, err := listTasks(ctx, root, client, listPath, true)
+	if err != nil {
+		return fmt.Errorf("failed to list tasks: %w", err)
+	}
+	summaries := make(map[string]string, len(tasks))
+	for _, t := range tasks {
+		summaries[t.UID] = t.Summary
+	}
+
+	var blocked []caldav.Task
+	var waiting [][]string
+	for i := range tasks {
+		if taskFinished(&tasks[i]) {
+			continue
+		}
+		if b := blockers(&tasks[i], tasks); len(b) > 0 {
+			blocked = append(blocked, tasks[i])
+			waiting = append(waiting, b)
+		}
+	}
+
+	if len(blocked) == 0 {
+		if !root.JSON {
+			fmt.Println("No blocked tasks.")
+		}
+		return nil
+	}
+	if root.JSON {
+		return outputTasksJSON(blocked)
+	}
+
+	fmt.Printf("%-12s %-40s %s\n", "DUE", "SUMMARY", "WAITING ON")
+	for i, t := range blocked {
+		due := "-"
+		if !t.Due.IsZero() {
+			due = t.Due.Format("2006-01-02")
+		}
+		names := make([]string, len(waiting[i]))
+		for j, uid := range waiting[i] {
+			summary, ok := summaries[uid]
+			if !ok {
+				summary = uid + " (not found)"
+			}
+			names[j] = summary
+		}
+		fmt.Printf("%-12s %-40s %s\n", due, fit(t.Summary, 40), strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// uidsJSON returns a list of UIDs as a JSON array.
+func uidsJSON(uids []string) string {
+	if uids == nil {
+		uids = []string{}
+	}
+	data, _ := json.Marshal(uids)
+	return string(data)
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+func subtasksFixture() []caldav.Task {
+	return []caldav.Task{
+		{UID: "chapter", Summary: "Draft chapter", Parent: "book"},
+		{UID: "book", Summary: "Write book", Children: []string{"cover"}},
+		{UID: "cover", Summary: "Design cover", Status: caldav.TaskStatusCompleted},
+		{UID: "intro", Summary: "Intro", Parent: "chapter"},
+		{UID: "orphan", Summary: "Orphan", Parent: "gone"},
+		{UID: "loop-a", Summary: "Loop A", Parent: "loop-b"},
+		{UID: "loop-b", Summary: "Loop B", Parent: "loop-a"},
+	}
+}
+
+func TestTaskTree(t *testing.T) {
+	var got []string
+	for _, task := range taskTree(subtasksFixture()) {
+		got = append(got, task.UID+":"+string(rune('0'+task.Depth)))
+	}
+	assert.Equal(t, []string{
+		"book:0", "chapter:1", "intro:2", "cover:1",
+		"orphan:0",
+		"loop-a:0", "loop-b:1",
+	}, got)
+}
+
+func TestOpenDescendants(t *testing.T) {
+	var uids []string
+	for _, task := range openDescendants(subtasksFixture(), "book") {
+		uids = append(uids, task.UID)
+	}
+	assert.Equal(t, []string{"chapter", "intro"}, uids)
+	assert.Empty(t, openDescendants(subtasksFixture(), "intro"))
+}
+
+func TestBlockers(t *testing.T) {
+	tasks := []caldav.Task{
+		{UID: "outline", Status: caldav.TaskStatusCompleted},
+		{UID: "research", Status: caldav.TaskStatusInProcess},
+		{UID: "draft", DependsOn: []string{"outline", "research", "gone"}},
+	}
+	assert.Equal(t, []string{"research", "gone"}, blockers(&tasks[2], tasks))
+	assert.Empty(t, blockers(&tasks[0], tasks))
+}
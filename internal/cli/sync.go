@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/carddav"
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/davsync"
+)
+
+// Cache kinds
+const (
+	cacheCalendar = "cal"
+	cacheContacts = "contacts"
+)
+
+// SyncCmd brings the local copies of an account's calendars, task lists and
+// address books up to date.
+type SyncCmd struct{}
+
+// Run executes the sync command.
+func (c *SyncCmd) Run(root *Root) error {
+	if root.Offline {
+		return fmt.Errorf("sog sync cannot run with --offline")
+	}
+	cfg, email, err := loadAccountConfig(root)
+	if err != nil {
+		return err
+	}
+	acct, err := cfg.GetAccount(email)
+	if err != nil {
+		return err
+	}
+	if acct.CalDAV.URL == "" && acct.CardDAV.URL == "" {
+		return fmt.Errorf("no CalDAV or CardDAV server configured for %s", email)
+	}
+
+	ctx := context.Background()
+	failed := 0
+	report := func(path string, result *davsync.Result, err error) {
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			return
+		}
+		if root.JSON {
+			data, _ := json.Marshal(result)
+			fmt.Println(string(data))
+			return
+		}
+		fmt.Printf("%-40s %-16s +%d ~%d -%d (%d items)\n", path, result.Method,
+			result.Added, result.Updated, result.Deleted, result.Total)
+	}
+
+	if acct.CalDAV.URL != "" {
+		client, _, err := getCalDAVClient(root)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		calendars, err := client.FindCalendars(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list calendars: %w", err)
+		}
+		for _, cal := range calendars {
+			_, result, err := syncCollection(ctx, root, client.Syncer(), cacheCalendar, cal.Path)
+			report(cal.Path, result, err)
+		}
+	}
+	if acct.CardDAV.URL != "" {
+		client, _, err := getCardDAVClient(root)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		books, err := client.FindAddressBooks(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list address books: %w", err)
+		}
+		for _, book := range books {
+			_, result, err := syncCollection(ctx, root, client.Syncer(), cacheContacts, book.Path)
+			report(book.Path, result, err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d collection(s) could not be synced", failed)
+	}
+	return nil
+}
+
+// cacheStore returns the local cache of the account in use.
+func cacheStore(root *Root) (*davsync.Store, error) {
+	_, email, err := loadAccountConfig(root)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return &davsync.Store{Dir: filepath.Join(dir, "cache", unsafeFileChars.ReplaceAllString(email, "_"))}, nil
+}
+
+// syncCollection brings the cached copy of the collection at path up to
+// date and returns it. --refresh discards the cached copy first.
+func syncCollection(ctx context.Context, root *Root, syncer *davsync.Syncer, kind, path string) (*davsync.Collection, *davsync.Result, error) {
+	store, err := cacheStore(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	col, err := store.Load(kind, path)
+	switch {
+	case root.Refresh || errors.Is(err, davsync.ErrNotCached):
+		col = &davsync.Collection{Path: path}
+	case err != nil:
+		// A damaged cache is rebuilt
+		fmt.Fprintf(os.Stderr, "Warning: %v; rebuilding the cache\n", err)
+		col = &davsync.Collection{Path: path}
+	}
+	result, err := syncer.Sync(ctx, col)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	if err := store.Save(kind, col); err != nil {
+		return nil, nil, err
+	}
+	return col, result, nil
+}
+
+// cachedCollection returns the cached copy of the collection at path for
+// the read commands. It is synced first unless --offline; if the server
+// cannot be reached, an earlier copy is used with a warning.
+func cachedCollection(ctx context.Context, root *Root, syncer *davsync.Syncer, kind, path string) (*davsync.Collection, error) {
+	if !root.Offline {
+		col, _, err := syncCollection(ctx, root, syncer, kind, path)
+		if err == nil || root.Refresh {
+			return col, err
+		}
+		store, storeErr := cacheStore(root)
+		if storeErr != nil {
+			return nil, err
+		}
+		cached, loadErr := store.Load(kind, path)
+		if loadErr != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v; using the copy from %s\n", err, cached.Synced.Local().Format("2006-01-02 15:04"))
+		return cached, nil
+	}
+
+	store, err := cacheStore(root)
+	if err != nil {
+		return nil, err
+	}
+	col, err := store.Load(kind, path)
+	if errors.Is(err, davsync.ErrNotCached) {
+		return nil, fmt.Errorf("%w; run 'sog sync' first", err)
+	}
+	return col, err
+}
+
+// listEvents returns the events of a calendar in [start, end) from the
+// local cache.
+func listEvents(ctx context.Context, root *Root, client *caldav.Client, calPath string, start, end time.Time) ([]caldav.Event, error) {
+	col, err := cachedCollection(ctx, root, client.Syncer(), cacheCalendar, calPath)
+	if err != nil {
+		return nil, err
+	}
+	return caldav.CachedEvents(col, start, end)
+}
+
+// listTasks returns the tasks of a task list from the local cache.
+func listTasks(ctx context.Context, root *Root, client *caldav.Client, listPath string, includeCompleted bool) ([]caldav.Task, error) {
+	col, err := cachedCollection(ctx, root, client.Syncer(), cacheCalendar, listPath)
+	if err != nil {
+		return nil, err
+	}
+	return caldav.CachedTasks(col, includeCompleted)
+}
+
+// listContacts returns the contacts of an address book from the local
+// cache.
+func listContacts(ctx context.Context, root *Root, client *carddav.Client, bookPath string) ([]carddav.Contact, error) {
+	col, err := cachedCollection(ctx, root, client.Syncer(), cacheContacts, bookPath)
+	if err != nil {
+		return nil, err
+	}
+	return carddav.CachedContacts(col), nil
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/carddav"
+	"github.com/visionik/sogcli/internal/davsync"
+)
+
+func TestCachedCollection(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	root := &Root{Account: "me@example.com"}
+
+	// The server is down
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	syncer := &davsync.Syncer{HTTP: http.DefaultClient, BaseURL: server.URL + "/", Kind: davsync.CalDAV}
+
+	root.Offline = true
+	_, err := cachedCollection(ctx, root, syncer, cacheCalendar, "/cal/")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sog sync")
+
+	root.Offline = false
+	_, err = cachedCollection(ctx, root, syncer, cacheCalendar, "/cal/")
+	require.Error(t, err)
+
+	store, err := cacheStore(root)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(cacheCalendar, &davsync.Collection{Path: "/cal/", Objects: map[string]davsync.Object{
+		"/cal/a.ics": {ETag: "1", Data: "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"},
+	}}))
+
+	// An earlier copy is used when the server cannot be reached
+	col, err := cachedCollection(ctx, root, syncer, cacheCalendar, "/cal/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/cal/a.ics"}, col.Hrefs())
+
+	root.Offline = true
+	col, err = cachedCollection(ctx, root, syncer, cacheCalendar, "/cal/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/cal/a.ics"}, col.Hrefs())
+
+	// --refresh insists on the server
+	root.Offline = false
+	root.Refresh = true
+	_, err = cachedCollection(ctx, root, syncer, cacheCalendar, "/cal/")
+	assert.Error(t, err)
+}
+
+func TestSearchContacts(t *testing.T) {
+	contacts := []carddav.Contact{{FullName: "Ada Lovelace"}, {FullName: "Alan Turing"}, {FullName: "Grace Hopper"}}
+	assert.Equal(t, []carddav.Contact{{FullName: "Ada Lovelace"}, {FullName: "Alan Turing"}}, searchContacts(contacts, "LA"))
+	assert.Empty(t, searchContacts(contacts, "nobody"))
+}
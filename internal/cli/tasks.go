@@ -3,10 +3,13 @@ package cli
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/emersion/go-ical"
 	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/timezone"
 )
 
 // TasksCmd handles task operations.
@@ -21,59 +24,52 @@ type TasksCmd struct {
 	Clear   TasksClearCmd   `cmd:"" help:"Clear completed tasks"`
 	Due     TasksDueCmd     `cmd:"" help:"Tasks due by date"`
 	Overdue TasksOverdueCmd `cmd:"" help:"Overdue tasks"`
+	Blocked TasksBlockedCmd `cmd:"" help:"Tasks waiting on unfinished dependencies"`
+	View    TasksViewCmd    `cmd:"" help:"Run a saved task view"`
+	Views   TasksViewsCmd   `cmd:"" help:"List saved task views"`
 	Lists   TasksListsCmd   `cmd:"" help:"List task lists (calendars)"`
+	Export  TasksExportCmd  `cmd:"" help:"Export a task list as iCalendar (.ics) to stdout"`
+	Import  TasksImportCmd  `cmd:"" help:"Import tasks from an .ics file"`
 }
 
 // TasksListCmd lists tasks.
 type TasksListCmd struct {
-	List      string `arg:"" optional:"" help:"Task list path (default: primary)"`
-	All       bool   `help:"Include completed tasks"`
-	Max       int    `help:"Maximum tasks to return" default:"50"`
+	List   string `arg:"" optional:"" help:"Task list path or name (default: primary)"`
+	All    bool   `help:"Include completed tasks"`
+	Max    int    `help:"Maximum tasks to return" default:"50"`
+	SaveAs string `help:"Save the list, filter and sort as a named view for 'sog tasks view'"`
+	TaskQueryFlags
 }
 
 // Run executes the tasks list command.
 func (c *TasksListCmd) Run(root *Root) error {
-	client, listPath, err := getCalDAVClientForTasks(root)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
+	view := c.view()
 	if c.List != "" {
-		listPath = c.List
-	}
-
-	ctx := context.Background()
-	tasks, err := client.ListTasks(ctx, listPath, c.All)
-	if err != nil {
-		return fmt.Errorf("failed to list tasks: %w", err)
+		view.Lists = append([]string{c.List}, view.Lists...)
 	}
+	view.All = c.All
 
-	if len(tasks) == 0 {
-		fmt.Println("No tasks found.")
-		return nil
-	}
-
-	// Limit results
-	if c.Max > 0 && len(tasks) > c.Max {
-		tasks = tasks[:c.Max]
-	}
-
-	if root.JSON {
-		return outputTasksJSON(tasks)
+	if c.SaveAs != "" {
+		if err := saveTaskView(c.SaveAs, view); err != nil {
+			return err
+		}
 	}
-
-	return outputTasksTable(tasks)
+	return runTaskView(root, &view, c.Max, "No tasks found.")
 }
 
 // TasksAddCmd adds a new task.
 type TasksAddCmd struct {
 	Title       string   `arg:"" help:"Task title"`
-	Due         string   `help:"Due date (YYYY-MM-DD or YYYY-MM-DDTHH:MM)"`
+	Due         string   `help:"Due date (YYYY-MM-DD, YYYY-MM-DDTHH:MM, 'friday', 'tomorrow 5pm', eod, +3d)"`
 	Priority    int      `help:"Priority (1-9, 1=highest)" short:"p"`
 	Description string   `help:"Task description" short:"d"`
 	Categories  []string `help:"Categories/tags" short:"c"`
 	List        string   `help:"Task list path (default: primary)"`
+	Remind      []string `help:"Reminder before the due date (e.g., 1h, 1d); repeatable"`
+	Parent      string   `help:"UID of the parent task (makes this a sub-task)"`
+	DependsOn   []string `name:"depends-on" help:"UID of a task that must be finished first; repeatable"`
+	Repeat      string   `help:"Repeat from the due date (e.g., 'daily', 'weekly', 'every 2 weeks on mon', 'monthly 6 times')"`
+	RRule       string   `name:"rrule" help:"Raw iCalendar RRULE (e.g., FREQ=WEEKLY;BYDAY=FR)"`
 }
 
 // Run executes the tasks add command.
@@ -95,6 +91,8 @@ func (c *TasksAddCmd) Run(root *Root) error {
 		Priority:    c.Priority,
 		Categories:  c.Categories,
 		Status:      caldav.TaskStatusNeedsAction,
+		Parent:      c.Parent,
+		DependsOn:   c.DependsOn,
 	}
 
 	// Parse due date
@@ -105,8 +103,40 @@ func (c *TasksAddCmd) Run(root *Root) error {
 		}
 		task.Due = due
 	}
+	if len(c.Remind) > 0 {
+		if task.Due.IsZero() {
+			return fmt.Errorf("--remind requires --due")
+		}
+		task.Alarms, _, err = parseReminders(c.Remind, true)
+		if err != nil {
+			return err
+		}
+	}
+	if c.Repeat != "" || c.RRule != "" {
+		if task.Due.IsZero() {
+			return fmt.Errorf("--repeat and --rrule require --due")
+		}
+		task.RRule, err = eventRepeatRule(c.Repeat, c.RRule, task.Due, false)
+		if err != nil {
+			return err
+		}
+		// The rule is anchored at DTSTART, which Apple Reminders and
+		// Nextcloud Tasks both expect next to DUE
+		task.Start = task.Due
+	}
 
 	ctx := context.Background()
+	if c.Parent != "" || len(c.DependsOn) > 0 {
+		tasks, err := listTasks(ctx, root, client, listPath, true)
+		if err != nil {
+			return fmt.Errorf("failed to list tasks: %w", err)
+		}
+		for _, uid := range append([]string{c.Parent}, c.DependsOn...) {
+			if uid != "" && !slices.ContainsFunc(tasks, func(t caldav.Task) bool { return t.UID == uid }) {
+				return fmt.Errorf("task not found: %s", uid)
+			}
+		}
+	}
 	if err := client.CreateTask(ctx, listPath, task); err != nil {
 		return fmt.Errorf("failed to create task: %w", err)
 	}
@@ -152,12 +182,15 @@ func (c *TasksGetCmd) Run(root *Root) error {
 
 // TasksUpdateCmd updates a task.
 type TasksUpdateCmd struct {
-	UID         string `arg:"" help:"Task UID"`
-	Title       string `help:"New title"`
-	Due         string `help:"New due date (YYYY-MM-DD)"`
-	Priority    int    `help:"New priority (1-9)" short:"p"`
-	Description string `help:"New description" short:"d"`
-	List        string `help:"Task list path (default: primary)"`
+	UID         string   `arg:"" help:"Task UID"`
+	Title       string   `help:"New title"`
+	Due         string   `help:"New due date (same formats as 'tasks add --due')"`
+	Priority    int      `help:"New priority (1-9)" short:"p"`
+	Description string   `help:"New description" short:"d"`
+	List        string   `help:"Task list path (default: primary)"`
+	Remind      []string `help:"Replace reminders (e.g., 1h, 1d; 'none' removes them)"`
+	Repeat      string   `help:"New repeat rule ('none' to stop repeating)"`
+	RRule       string   `name:"rrule" help:"New raw iCalendar RRULE"`
 }
 
 // Run executes the tasks update command.
@@ -172,7 +205,7 @@ func (c *TasksUpdateCmd) Run(root *Root) error {
 		listPath = c.List
 	}
 
-	ctx := context.Background()
+	ctx := writeContext(root.Force)
 	task, err := client.GetTask(ctx, listPath, c.UID)
 	if err != nil {
 		return fmt.Errorf("failed to get task: %w", err)
@@ -187,6 +220,10 @@ func (c *TasksUpdateCmd) Run(root *Root) error {
 		if err != nil {
 			return fmt.Errorf("invalid --due: %w", err)
 		}
+		// A recurring task's start moves with its due date
+		if task.IsRecurring() && task.Start.Equal(task.Due) {
+			task.Start = due
+		}
 		task.Due = due
 	}
 	if c.Priority > 0 {
@@ -195,9 +232,36 @@ func (c *TasksUpdateCmd) Run(root *Root) error {
 	if c.Description != "" {
 		task.Description = c.Description
 	}
+	if len(c.Remind) > 0 {
+		alarms, clear, err := parseReminders(c.Remind, true)
+		if err != nil {
+			return err
+		}
+		if !clear && task.Due.IsZero() {
+			return fmt.Errorf("--remind requires a due date")
+		}
+		task.Alarms = alarms
+	}
+	if strings.EqualFold(c.Repeat, "none") {
+		if c.RRule != "" {
+			return fmt.Errorf("use either --repeat or --rrule, not both")
+		}
+		task.RRule = ""
+	} else if c.Repeat != "" || c.RRule != "" {
+		if task.Start.IsZero() {
+			task.Start = task.Due
+		}
+		if task.Start.IsZero() {
+			return fmt.Errorf("--repeat and --rrule require a due date")
+		}
+		task.RRule, err = eventRepeatRule(c.Repeat, c.RRule, task.Start, false)
+		if err != nil {
+			return err
+		}
+	}
 
 	if err := client.UpdateTask(ctx, listPath, task); err != nil {
-		return fmt.Errorf("failed to update task: %w", err)
+		return fmt.Errorf("failed to update task: %w", conflictHint(err, false))
 	}
 
 	fmt.Printf("Updated task: %s\n", c.UID)
@@ -206,8 +270,9 @@ func (c *TasksUpdateCmd) Run(root *Root) error {
 
 // TasksDoneCmd marks a task as complete.
 type TasksDoneCmd struct {
-	UID  string `arg:"" help:"Task UID"`
-	List string `help:"Task list path (default: primary)"`
+	UID      string `arg:"" help:"Task UID"`
+	List     string `help:"Task list path (default: primary)"`
+	Children bool   `help:"Also complete the task's open sub-tasks"`
 }
 
 // Run executes the tasks done command.
@@ -223,12 +288,21 @@ func (c *TasksDoneCmd) Run(root *Root) error {
 	}
 
 	ctx := context.Background()
-	if err := client.CompleteTask(ctx, listPath, c.UID); err != nil {
-		return fmt.Errorf("failed to complete task: %w", err)
+	task, err := client.CompleteTask(ctx, listPath, c.UID)
+	if err != nil {
+		return fmt.Errorf("failed to complete task: %w", conflictHint(err, false))
 	}
 
-	fmt.Printf("Completed task: %s\n", c.UID)
-	return nil
+	if task.Status == caldav.TaskStatusCompleted {
+		fmt.Printf("Completed task: %s\n", c.UID)
+	} else {
+		next := task.Due
+		if next.IsZero() {
+			next = task.Start
+		}
+		fmt.Printf("Completed this occurrence of %s; next due %s\n", c.UID, next.Format("2006-01-02 15:04"))
+	}
+	return completeSubtasks(ctx, client, listPath, c.UID, c.Children)
 }
 
 // TasksUndoCmd marks a task as incomplete.
@@ -251,7 +325,7 @@ func (c *TasksUndoCmd) Run(root *Root) error {
 
 	ctx := context.Background()
 	if err := client.UncompleteTask(ctx, listPath, c.UID); err != nil {
-		return fmt.Errorf("failed to uncomplete task: %w", err)
+		return fmt.Errorf("failed to uncomplete task: %w", conflictHint(err, false))
 	}
 
 	fmt.Printf("Uncompleted task: %s\n", c.UID)
@@ -260,8 +334,8 @@ func (c *TasksUndoCmd) Run(root *Root) error {
 
 // TasksDeleteCmd deletes a task.
 type TasksDeleteCmd struct {
-	UID  string `arg:"" help:"Task UID"`
-	List string `help:"Task list path (default: primary)"`
+	UID   string `arg:"" help:"Task UID"`
+	List  string `help:"Task list path (default: primary)"`
 }
 
 // Run executes the tasks delete command.
@@ -276,9 +350,9 @@ func (c *TasksDeleteCmd) Run(root *Root) error {
 		listPath = c.List
 	}
 
-	ctx := context.Background()
+	ctx := writeContext(root.Force)
 	if err := client.DeleteTask(ctx, listPath, c.UID); err != nil {
-		return fmt.Errorf("failed to delete task: %w", err)
+		return fmt.Errorf("failed to delete task: %w", conflictHint(err, false))
 	}
 
 	fmt.Printf("Deleted task: %s\n", c.UID)
@@ -311,7 +385,7 @@ func (c *TasksClearCmd) Run(root *Root) error {
 	count := 0
 	for _, t := range tasks {
 		if t.Status == caldav.TaskStatusCompleted {
-			if err := client.DeleteTask(ctx, listPath, t.UID); err != nil {
+			if err := client.RemoveTask(ctx, &t); err != nil {
 				fmt.Printf("Failed to delete %s: %v\n", t.UID, err)
 				continue
 			}
@@ -325,98 +399,35 @@ func (c *TasksClearCmd) Run(root *Root) error {
 
 // TasksDueCmd lists tasks due by a date.
 type TasksDueCmd struct {
-	Date string `arg:"" help:"Due date (YYYY-MM-DD, today, tomorrow, +Nd)"`
-	List string `help:"Task list path (default: primary)"`
+	Date string `arg:"" help:"Due date (YYYY-MM-DD, today, friday, +Nd, eow)"`
+	TaskQueryFlags
 }
 
 // Run executes the tasks due command.
 func (c *TasksDueCmd) Run(root *Root) error {
-	client, listPath, err := getCalDAVClientForTasks(root)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	if c.List != "" {
-		listPath = c.List
-	}
-
-	dueBy, err := parseDate(c.Date)
-	if err != nil {
-		return fmt.Errorf("invalid date: %w", err)
-	}
-	// Include the full day
-	dueBy = dueBy.AddDate(0, 0, 1)
-
-	ctx := context.Background()
-	allTasks, err := client.ListTasks(ctx, listPath, false)
-	if err != nil {
-		return fmt.Errorf("failed to list tasks: %w", err)
-	}
-
-	// Filter by due date
-	var tasks []caldav.Task
-	for _, t := range allTasks {
-		if !t.Due.IsZero() && t.Due.Before(dueBy) {
-			tasks = append(tasks, t)
-		}
+	if strings.ContainsAny(c.Date, `"'`) {
+		return fmt.Errorf("invalid date: %s", c.Date)
 	}
-
-	if len(tasks) == 0 {
-		fmt.Println("No tasks due.")
-		return nil
+	// A day includes all of it
+	view := narrowView(c.view(), `due<="`+c.Date+`"`)
+	if view.Sort == "" {
+		view.Sort = "due"
 	}
-
-	if root.JSON {
-		return outputTasksJSON(tasks)
-	}
-
-	return outputTasksTable(tasks)
+	return runTaskView(root, &view, 0, "No tasks due.")
 }
 
 // TasksOverdueCmd lists overdue tasks.
 type TasksOverdueCmd struct {
-	List string `help:"Task list path (default: primary)"`
+	TaskQueryFlags
 }
 
 // Run executes the tasks overdue command.
 func (c *TasksOverdueCmd) Run(root *Root) error {
-	client, listPath, err := getCalDAVClientForTasks(root)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	if c.List != "" {
-		listPath = c.List
-	}
-
-	now := time.Now()
-
-	ctx := context.Background()
-	allTasks, err := client.ListTasks(ctx, listPath, false)
-	if err != nil {
-		return fmt.Errorf("failed to list tasks: %w", err)
-	}
-
-	// Filter overdue
-	var tasks []caldav.Task
-	for _, t := range allTasks {
-		if !t.Due.IsZero() && t.Due.Before(now) {
-			tasks = append(tasks, t)
-		}
-	}
-
-	if len(tasks) == 0 {
-		fmt.Println("No overdue tasks.")
-		return nil
-	}
-
-	if root.JSON {
-		return outputTasksJSON(tasks)
+	view := narrowView(c.view(), "due<now")
+	if view.Sort == "" {
+		view.Sort = "due"
 	}
-
-	return outputTasksTable(tasks)
+	return runTaskView(root, &view, 0, "No overdue tasks.")
 }
 
 // TasksListsCmd lists available task lists.
@@ -436,18 +447,26 @@ func (c *TasksListsCmd) Run(root *Root) error {
 		return fmt.Errorf("failed to list task lists: %w", err)
 	}
 
-	if len(calendars) == 0 {
+	// Only collections that can hold tasks
+	var lists []caldav.Calendar
+	for _, cal := range calendars {
+		if cal.Supports(ical.CompToDo) {
+			lists = append(lists, cal)
+		}
+	}
+
+	if len(lists) == 0 {
 		fmt.Println("No task lists found.")
 		return nil
 	}
 
 	if root.JSON {
-		return outputCalendarsJSON(calendars)
+		return outputCalendarsJSON(lists)
 	}
 
 	fmt.Printf("%-50s %s\n", "PATH", "NAME")
-	for _, cal := range calendars {
-		fmt.Printf("%-50s %s\n", cal.Path, cal.Name)
+	for _, cal := range lists {
+		fmt.Printf("%-50s %s\n", cal.Path, calendarName(&cal))
 	}
 	return nil
 }
@@ -458,22 +477,17 @@ func getCalDAVClientForTasks(root *Root) (*caldav.Client, string, error) {
 	return getCalDAVClient(root)
 }
 
-// parseTaskDate parses a date string for task due dates.
+// parseTaskDate parses a date expression for task due dates. A date
+// without a time means the end of that day.
 func parseTaskDate(s string) (time.Time, error) {
-	// Try datetime first
-	t, err := time.Parse("2006-01-02T15:04", s)
-	if err == nil {
-		return t, nil
+	t, dateOnly, err := parseDateTime(s, timezone.System())
+	if err != nil {
+		return time.Time{}, err
 	}
-
-	// Try date only (set to end of day)
-	t, err = time.Parse("2006-01-02", s)
-	if err == nil {
-		return t.Add(23*time.Hour + 59*time.Minute), nil
+	if dateOnly {
+		t = t.Add(23*time.Hour + 59*time.Minute)
 	}
-
-	// Try relative dates
-	return parseDate(s)
+	return t, nil
 }
 
 // generateTaskUID generates a unique identifier for a task.
@@ -484,16 +498,21 @@ func generateTaskUID() string {
 // outputTasksJSON outputs tasks as JSON.
 func outputTasksJSON(tasks []caldav.Task) error {
 	for _, t := range tasks {
-		dueStr := ""
-		if !t.Due.IsZero() {
-			dueStr = t.Due.Format(time.RFC3339)
-		}
-		fmt.Printf(`{"uid":"%s","summary":"%s","status":"%s","due":"%s","priority":%d}`+"\n",
-			t.UID, t.Summary, t.Status, dueStr, t.Priority)
+		fmt.Println(taskJSON(&t))
 	}
 	return nil
 }
 
+// taskJSON formats a task as a JSON object.
+func taskJSON(t *caldav.Task) string {
+	dueStr := ""
+	if !t.Due.IsZero() {
+		dueStr = t.Due.Format(time.RFC3339)
+	}
+	return fmt.Sprintf(`{"uid":"%s","summary":"%s","status":"%s","due":"%s","priority":%d,"reminders":%s,"parent":"%s","depends_on":%s,"rrule":"%s"}`,
+		t.UID, t.Summary, t.Status, dueStr, t.Priority, remindersJSON(t.Alarms), t.Parent, uidsJSON(t.DependsOn), t.RRule)
+}
+
 // outputTasksTable outputs tasks as a table.
 func outputTasksTable(tasks []caldav.Task) error {
 	fmt.Printf("%-4s %-12s %-8s %s\n", "PRI", "DUE", "STATUS", "SUMMARY")
@@ -558,5 +577,20 @@ func outputTaskDetail(task *caldav.Task) error {
 	if task.Description != "" {
 		fmt.Printf("Description: %s\n", task.Description)
 	}
+	if task.RRule != "" {
+		fmt.Printf("Repeats:     %s\n", task.RRule)
+	}
+	for _, a := range task.Alarms {
+		fmt.Printf("Reminder:    %s\n", a)
+	}
+	if task.Parent != "" {
+		fmt.Printf("Parent:      %s\n", task.Parent)
+	}
+	for _, uid := range task.Children {
+		fmt.Printf("Sub-task:    %s\n", uid)
+	}
+	for _, uid := range task.DependsOn {
+		fmt.Printf("Depends on:  %s\n", uid)
+	}
 	return nil
 }
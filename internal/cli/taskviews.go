@@ -0,0 +1,298 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/config"
+	"github.com/visionik/sogcli/internal/taskquery"
+	"github.com/visionik/sogcli/internal/timezone"
+)
+
+// TaskQueryFlags select the task lists read by the task listing commands
+// and filter and sort their tasks.
+type TaskQueryFlags struct {
+	Lists       []string `name:"list" sep:"none" help:"Task list path or name; repeatable (default: primary)"`
+	Where       string   `help:"Filter expression (e.g., 'priority<=3 and category:work and due<+7d')"`
+	Sort        string   `help:"Sort keys, comma-separated; '-' reverses one (e.g., due,priority or -priority)"`
+	AllLists    bool     `help:"Read every task list of the account"`
+	AllAccounts bool     `help:"Read every account with a CalDAV server"`
+}
+
+// view returns the flags as a task view.
+func (f TaskQueryFlags) view() config.TaskView {
+	return config.TaskView{
+		Where:       f.Where,
+		Sort:        f.Sort,
+		Lists:       f.Lists,
+		AllLists:    f.AllLists,
+		AllAccounts: f.AllAccounts,
+	}
+}
+
+// narrowView returns the view with its filter narrowed by expr.
+func narrowView(view config.TaskView, expr string) config.TaskView {
+	if view.Where != "" {
+		expr += " and (" + view.Where + ")"
+	}
+	view.Where = expr
+	return view
+}
+
+// taskQuery is a parsed task view.
+type taskQuery struct {
+	filter *taskquery.Filter
+	order  taskquery.Order
+}
+
+// parseTaskView parses the filter and sort order of a view.
+func parseTaskView(view *config.TaskView) (*taskQuery, error) {
+	q := &taskQuery{}
+	var err error
+	if view.Where != "" {
+		q.filter, err = taskquery.Parse(view.Where, time.Now().In(timezone.System()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+	q.order, err = taskquery.ParseOrder(view.Sort)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sort: %w", err)
+	}
+	return q, nil
+}
+
+// runTaskView prints the tasks a view selects, at most max of them.
+// Completed tasks are left out unless the view includes them or its filter
+// refers to the status or completion date. A single list without a sort
+// order is shown as a tree of sub-tasks.
+func runTaskView(root *Root, view *config.TaskView, max int, none string) error {
+	q, err := parseTaskView(view)
+	if err != nil {
+		return err
+	}
+	tasks, lists, err := queryTasks(root, view)
+	if err != nil {
+		return err
+	}
+
+	includeCompleted := view.All || q.filter.Uses("status") || q.filter.Uses("completed")
+	var matched []taskquery.Task
+	for i := range tasks {
+		if !includeCompleted && tasks[i].Status == caldav.TaskStatusCompleted {
+			continue
+		}
+		if q.filter.Match(&tasks[i]) {
+			matched = append(matched, tasks[i])
+		}
+	}
+	q.order.Sort(matched)
+
+	if len(matched) == 0 {
+		fmt.Println(none)
+		return nil
+	}
+	if max > 0 && len(matched) > max {
+		matched = matched[:max]
+	}
+
+	if root.JSON {
+		return outputTaskResultsJSON(matched)
+	}
+	if lists == 1 && len(q.order) == 0 {
+		plain := make([]caldav.Task, len(matched))
+		for i := range matched {
+			plain[i] = matched[i].Task
+		}
+		return outputTasksTree(plain)
+	}
+	return outputTaskResultsTable(matched, lists > 1)
+}
+
+// queryTasks reads the tasks of the lists a view selects, with the number
+// of lists read. When several lists are read, one that fails is skipped
+// with a warning.
+func queryTasks(root *Root, view *config.TaskView) ([]taskquery.Task, int, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load config: %w", err)
+	}
+	ctx := context.Background()
+
+	flags := AgendaFlags{Calendars: view.Lists, AllCalendars: view.AllLists, AllAccounts: view.AllAccounts}
+	sources, err := agendaSources(ctx, root, cfg, flags, ical.CompToDo)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, src := range sources {
+		defer src.client.Close()
+	}
+
+	var tasks []taskquery.Task
+	for _, src := range sources {
+		list, err := listTasks(ctx, src.root, src.client, src.path, true)
+		if err != nil {
+			if len(sources) == 1 {
+				return nil, 0, fmt.Errorf("failed to list tasks: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", src.name(), err)
+			continue
+		}
+		label := src.label
+		if label == "" {
+			label = calendarLabel(src.path, src.account)
+		}
+		for _, t := range list {
+			tasks = append(tasks, taskquery.Task{Task: t, List: label, ListPath: src.path, Account: src.account})
+		}
+	}
+	return tasks, len(sources), nil
+}
+
+// outputTaskResultsTable outputs tasks as a table, with the list of each
+// task if several were read.
+func outputTaskResultsTable(tasks []taskquery.Task, showList bool) error {
+	if !showList {
+		plain := make([]caldav.Task, len(tasks))
+		for i := range tasks {
+			plain[i] = tasks[i].Task
+		}
+		return outputTasksTable(plain)
+	}
+	fmt.Printf("%-4s %-12s %-8s %-16s %s\n", "PRI", "DUE", "STATUS", "LIST", "SUMMARY")
+	for _, t := range tasks {
+		pri := "-"
+		if t.Priority > 0 {
+			pri = fmt.Sprintf("%d", t.Priority)
+		}
+		due := "-"
+		if !t.Due.IsZero() {
+			due = t.Due.Format("2006-01-02")
+		}
+		summary := t.Summary
+		if len(summary) > 50 {
+			summary = summary[:47] + "..."
+		}
+		fmt.Printf("%-4s %-12s %-8s %s %s\n", pri, due, statusShort(t.Status), fit(t.List, 16), summary)
+	}
+	return nil
+}
+
+// outputTaskResultsJSON outputs tasks as JSON, like outputTasksJSON, with
+// the list and account of each.
+func outputTaskResultsJSON(tasks []taskquery.Task) error {
+	for _, t := range tasks {
+		fmt.Println(strings.TrimSuffix(taskJSON(&t.Task), "}") +
+			fmt.Sprintf(`,"list":"%s","list_path":"%s","account":"%s"}`, t.List, t.ListPath, t.Account))
+	}
+	return nil
+}
+
+// TasksViewCmd runs a saved task view.
+type TasksViewCmd struct {
+	Name   string `arg:"" help:"View name"`
+	Max    int    `help:"Maximum tasks to return" default:"50"`
+	Delete bool   `help:"Delete the saved view instead of running it"`
+}
+
+// Run executes the tasks view command.
+func (c *TasksViewCmd) Run(root *Root) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if c.Delete {
+		if err := cfg.RemoveTaskView(c.Name); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted view: %s\n", c.Name)
+		return nil
+	}
+
+	view := cfg.FindTaskView(c.Name)
+	if view == nil {
+		return fmt.Errorf("task view not found: %s (see 'sog tasks views')", c.Name)
+	}
+	return runTaskView(root, view, c.Max, "No tasks found.")
+}
+
+// TasksViewsCmd lists saved task views.
+type TasksViewsCmd struct{}
+
+// Run executes the tasks views command.
+func (c *TasksViewsCmd) Run(root *Root) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if len(cfg.TaskViews) == 0 {
+		if !root.JSON {
+			fmt.Println("No saved views. Save one with: sog tasks list --where <filter> --save-as <name>")
+		}
+		return nil
+	}
+
+	if root.JSON {
+		for _, view := range cfg.TaskViews {
+			data, err := json.Marshal(view)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		}
+		return nil
+	}
+
+	fmt.Printf("%-16s %-16s %s\n", "NAME", "SORT", "FILTER")
+	for _, view := range cfg.TaskViews {
+		order := view.Sort
+		if order == "" {
+			order = "-"
+		}
+		where := view.Where
+		if where == "" {
+			where = "(all tasks)"
+		}
+		var scope []string
+		switch {
+		case view.AllLists:
+			scope = append(scope, "all lists")
+		case len(view.Lists) > 0:
+			scope = append(scope, "lists: "+strings.Join(view.Lists, ", "))
+		}
+		if view.AllAccounts {
+			scope = append(scope, "all accounts")
+		}
+		if view.All {
+			scope = append(scope, "with completed")
+		}
+		if len(scope) > 0 {
+			where += " [" + strings.Join(scope, "; ") + "]"
+		}
+		fmt.Printf("%-16s %-16s %s\n", view.Name, order, where)
+	}
+	return nil
+}
+
+// saveTaskView stores a view under a name after checking that it parses.
+func saveTaskView(name string, view config.TaskView) error {
+	if _, err := parseTaskView(&view); err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	view.Name = name
+	if err := cfg.SaveTaskView(view); err != nil {
+		return fmt.Errorf("failed to save view: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Saved view: %s (run it with 'sog tasks view %s')\n", name, name)
+	return nil
+}
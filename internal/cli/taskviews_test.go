@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/config"
+)
+
+func TestNarrowView(t *testing.T) {
+	view := narrowView(config.TaskView{Where: "category:work or priority<=2", Sort: "priority"}, "due<now")
+	assert.Equal(t, "due<now and (category:work or priority<=2)", view.Where)
+	assert.Equal(t, "priority", view.Sort)
+	assert.Equal(t, "due<now", narrowView(config.TaskView{}, "due<now").Where)
+}
+
+func TestSaveTaskView(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	assert.Error(t, saveTaskView("broken", config.TaskView{Where: "priority<=high"}))
+	assert.Error(t, saveTaskView("unsorted", config.TaskView{Sort: "colour"}))
+
+	flags := TaskQueryFlags{Where: "category:work and due<=today", Sort: "due,priority", AllLists: true}
+	require.NoError(t, saveTaskView("today-work", flags.view()))
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	view := cfg.FindTaskView("today-work")
+	require.NotNil(t, view)
+	assert.Equal(t, "category:work and due<=today", view.Where)
+	assert.True(t, view.AllLists)
+	assert.Nil(t, cfg.FindTaskView("broken"))
+
+	assert.Error(t, (&TasksViewCmd{Name: "missing"}).Run(&Root{}))
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cli
+
+import "os"
+
+// terminalColumns returns the width of the terminal f, or 0 where it
+// cannot be detected.
+func terminalColumns(f *os.File) int {
+	return 0
+}
@@ -13,23 +13,47 @@ type Config struct {
 	Accounts       map[string]Account `json:"accounts"`
 	DefaultAccount string             `json:"default_account,omitempty"`
 	Storage        string             `json:"storage,omitempty"` // keychain or file
+	Subscriptions  []Subscription     `json:"subscriptions,omitempty"`
+	TaskViews      []TaskView         `json:"task_views,omitempty"`
 	path           string
 }
 
 // Account holds configuration for a mail account.
 type Account struct {
-	Email   string        `json:"email"`
-	IMAP    ServerConfig  `json:"imap"`
-	SMTP    ServerConfig  `json:"smtp"`
-	CalDAV  CalDAVConfig  `json:"caldav,omitempty"`
-	CardDAV CardDAVConfig `json:"carddav,omitempty"`
-	WebDAV  WebDAVConfig  `json:"webdav,omitempty"`
+	Email      string        `json:"email"`
+	IMAP       ServerConfig  `json:"imap"`
+	SMTP       ServerConfig  `json:"smtp"`
+	CalDAV     CalDAVConfig  `json:"caldav,omitempty"`
+	CardDAV    CardDAVConfig `json:"carddav,omitempty"`
+	WebDAV     WebDAVConfig  `json:"webdav,omitempty"`
+	Identities []Identity    `json:"identities,omitempty"`
+	PGP        PGPConfig     `json:"pgp,omitempty"`
+}
+
+// Identity is an address an account can send as (alias or shared mailbox).
+type Identity struct {
+	Email     string `json:"email"`
+	Name      string `json:"name,omitempty"`
+	ReplyTo   string `json:"reply_to,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	BccSelf   bool   `json:"bcc_self,omitempty"`
+	PGPKey    string `json:"pgp_key,omitempty"`    // Signing key ID or fingerprint (default: Email)
+	SMIMECert string `json:"smime_cert,omitempty"` // PKCS#12 or PEM certificate file
+	SMIMEKey  string `json:"smime_key,omitempty"`  // PEM key file, if not in SMIMECert
+}
+
+// PGPConfig holds OpenPGP settings.
+type PGPConfig struct {
+	Backend string `json:"backend,omitempty"`  // keyring (default) or gpg
+	GPGPath string `json:"gpg_path,omitempty"` // gpg binary for the gpg backend
+	WKD     bool   `json:"wkd,omitempty"`      // Look up missing recipient keys via WKD
 }
 
 // CalDAVConfig holds CalDAV server configuration.
 type CalDAVConfig struct {
 	URL             string `json:"url,omitempty"`
 	DefaultCalendar string `json:"default_calendar,omitempty"`
+	TaskRecurrence  string `json:"task_recurrence,omitempty"` // roll (default) or new-instance
 }
 
 // CardDAVConfig holds CardDAV server configuration.
@@ -45,12 +69,12 @@ type WebDAVConfig struct {
 
 // ServerConfig holds server connection details.
 type ServerConfig struct {
-	Host       string `json:"host"`
-	Port       int    `json:"port"`
-	TLS        bool   `json:"tls,omitempty"`
-	StartTLS   bool   `json:"starttls,omitempty"`
-	Insecure   bool   `json:"insecure,omitempty"`   // Skip TLS cert verification
-	NoTLS      bool   `json:"no_tls,omitempty"`     // Disable TLS entirely
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	TLS      bool   `json:"tls,omitempty"`
+	StartTLS bool   `json:"starttls,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"` // Skip TLS cert verification
+	NoTLS    bool   `json:"no_tls,omitempty"`   // Disable TLS entirely
 }
 
 // configDir returns the config directory path.
@@ -62,6 +86,11 @@ func configDir() (string, error) {
 	return filepath.Join(home, ".config", "sog"), nil
 }
 
+// Dir returns the sog config directory (~/.config/sog).
+func Dir() (string, error) {
+	return configDir()
+}
+
 // configPath returns the config file path.
 func configPath() (string, error) {
 	dir, err := configDir()
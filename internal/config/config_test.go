@@ -154,3 +154,102 @@ func TestConfigRemoveAccount(t *testing.T) {
 	assert.Len(t, cfg.Accounts, 1)
 	assert.Equal(t, "c@example.com", cfg.DefaultAccount)
 }
+
+func TestAccountIdentities(t *testing.T) {
+	acct := Account{
+		Email: "me@example.com",
+		Identities: []Identity{
+			{Email: "sales@example.com", Name: "Sales", ReplyTo: "crm@example.com"},
+			{Email: "ME@example.com", Name: "Me"},
+		},
+	}
+
+	ids := acct.SendingIdentities()
+	require.Len(t, ids, 2)
+	assert.Equal(t, "Me", ids[0].Name, "own address comes first and keeps its settings")
+	assert.Equal(t, "sales@example.com", ids[1].Email)
+
+	id, err := acct.FindIdentity("Sales@Example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "crm@example.com", id.ReplyTo)
+
+	_, err = acct.FindIdentity("other@example.com")
+	assert.Error(t, err)
+
+	assert.Equal(t, "sales@example.com", acct.MatchIdentity([]string{"bob@example.com", "sales@example.com"}).Email)
+	assert.Equal(t, "ME@example.com", acct.MatchIdentity([]string{"bob@example.com"}).Email)
+	assert.True(t, acct.IsOwnAddress("sales@example.com"))
+	assert.False(t, acct.IsOwnAddress("bob@example.com"))
+}
+
+func TestConfigSetRemoveIdentity(t *testing.T) {
+	tmpDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	cfg.Accounts["me@example.com"] = Account{Email: "me@example.com"}
+
+	require.NoError(t, cfg.SetIdentity("me@example.com", Identity{Email: "alias@example.com"}))
+	require.NoError(t, cfg.SetIdentity("me@example.com", Identity{Email: "alias@example.com", Name: "Alias"}))
+	assert.Len(t, cfg.Accounts["me@example.com"].Identities, 1)
+	assert.Equal(t, "Alias", cfg.Accounts["me@example.com"].Identities[0].Name)
+
+	require.NoError(t, cfg.RemoveIdentity("me@example.com", "alias@example.com"))
+	assert.Empty(t, cfg.Accounts["me@example.com"].Identities)
+	assert.Error(t, cfg.RemoveIdentity("me@example.com", "alias@example.com"))
+	assert.Error(t, cfg.SetIdentity("nobody@example.com", Identity{Email: "x@example.com"}))
+}
+
+func TestConfigSubscriptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	require.NoError(t, cfg.AddSubscription(Subscription{Name: "holidays", URL: "webcal://example.com/holidays.ics"}))
+	assert.Error(t, cfg.AddSubscription(Subscription{Name: "Holidays", File: "/tmp/h.ics"}))
+	assert.Error(t, cfg.AddSubscription(Subscription{Name: "both", URL: "https://example.com/a.ics", File: "/tmp/a.ics"}))
+	assert.Error(t, cfg.AddSubscription(Subscription{Name: "neither"}))
+
+	loaded, err := Load()
+	require.NoError(t, err)
+	sub := loaded.FindSubscription("HOLIDAYS")
+	require.NotNil(t, sub)
+	assert.Equal(t, "webcal://example.com/holidays.ics", sub.Source())
+
+	require.NoError(t, loaded.RemoveSubscription("holidays"))
+	assert.Nil(t, loaded.FindSubscription("holidays"))
+	assert.Error(t, loaded.RemoveSubscription("holidays"))
+}
+
+func TestConfigTaskViews(t *testing.T) {
+	tmpDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	require.NoError(t, cfg.SaveTaskView(TaskView{Name: "today-work", Where: "category:work and due<=today"}))
+	require.NoError(t, cfg.SaveTaskView(TaskView{Name: "Today-Work", Where: "category:work", Sort: "due"}))
+	assert.Error(t, cfg.SaveTaskView(TaskView{Where: "due<today"}))
+
+	loaded, err := Load()
+	require.NoError(t, err)
+	require.Len(t, loaded.TaskViews, 1)
+	view := loaded.FindTaskView("today-work")
+	require.NotNil(t, view)
+	assert.Equal(t, "category:work", view.Where)
+	assert.Equal(t, "due", view.Sort)
+
+	require.NoError(t, loaded.RemoveTaskView("TODAY-WORK"))
+	assert.Nil(t, loaded.FindTaskView("today-work"))
+	assert.Error(t, loaded.RemoveTaskView("today-work"))
+}
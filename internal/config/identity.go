@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SendingIdentities returns the identities an account can send as.
+// The account's own address is always included, first, even when it is
+// not listed explicitly.
+func (a *Account) SendingIdentities() []Identity {
+	ids := make([]Identity, 0, len(a.Identities)+1)
+	ids = append(ids, a.DefaultIdentity())
+	for _, id := range a.Identities {
+		if strings.EqualFold(id.Email, a.Email) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// DefaultIdentity returns the identity for the account's own address.
+// Settings from a matching entry in Identities are used if present.
+func (a *Account) DefaultIdentity() Identity {
+	for _, id := range a.Identities {
+		if strings.EqualFold(id.Email, a.Email) {
+			return id
+		}
+	}
+	return Identity{Email: a.Email}
+}
+
+// FindIdentity returns the identity with the given address.
+func (a *Account) FindIdentity(email string) (*Identity, error) {
+	for _, id := range a.SendingIdentities() {
+		if strings.EqualFold(id.Email, email) {
+			return &id, nil
+		}
+	}
+	return nil, fmt.Errorf("identity not found: %s (see 'sog auth identity list')", email)
+}
+
+// MatchIdentity returns the first identity whose address appears in addrs,
+// or the default identity if none match. Used to pick the From address
+// when replying to a message sent to an alias.
+func (a *Account) MatchIdentity(addrs []string) Identity {
+	ids := a.SendingIdentities()
+	for _, addr := range addrs {
+		for _, id := range ids {
+			if strings.EqualFold(id.Email, addr) {
+				return id
+			}
+		}
+	}
+	return a.DefaultIdentity()
+}
+
+// IsOwnAddress reports whether addr belongs to one of the account's identities.
+func (a *Account) IsOwnAddress(addr string) bool {
+	for _, id := range a.SendingIdentities() {
+		if strings.EqualFold(id.Email, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIdentity adds or replaces an identity on an account and saves the config.
+func (c *Config) SetIdentity(email string, id Identity) error {
+	acct, ok := c.Accounts[email]
+	if !ok {
+		return fmt.Errorf("account not found: %s", email)
+	}
+
+	replaced := false
+	for i, existing := range acct.Identities {
+		if strings.EqualFold(existing.Email, id.Email) {
+			acct.Identities[i] = id
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		acct.Identities = append(acct.Identities, id)
+	}
+
+	c.Accounts[email] = acct
+	return c.Save()
+}
+
+// RemoveIdentity removes an identity from an account and saves the config.
+func (c *Config) RemoveIdentity(email, address string) error {
+	acct, ok := c.Accounts[email]
+	if !ok {
+		return fmt.Errorf("account not found: %s", email)
+	}
+
+	ids := make([]Identity, 0, len(acct.Identities))
+	for _, id := range acct.Identities {
+		if !strings.EqualFold(id.Email, address) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == len(acct.Identities) {
+		return fmt.Errorf("identity not found: %s", address)
+	}
+
+	acct.Identities = ids
+	c.Accounts[email] = acct
+	return c.Save()
+}
@@ -41,6 +41,8 @@ const (
 	ProtocolCalDAV  Protocol = "caldav"
 	ProtocolCardDAV Protocol = "carddav"
 	ProtocolWebDAV  Protocol = "webdav"
+	ProtocolPGP     Protocol = "pgp"   // OpenPGP secret key passphrase
+	ProtocolSMIME   Protocol = "smime" // S/MIME PKCS#12 password
 )
 
 // credentialsFilePath returns the path to the credentials file.
@@ -205,6 +207,22 @@ func GetPasswordForProtocol(email string, protocol Protocol) (string, error) {
 	return GetPassword(email)
 }
 
+// GetPassphrase retrieves a key passphrase stored for a protocol (such as
+// ProtocolPGP). Unlike GetPasswordForProtocol it never falls back to the
+// account password.
+func GetPassphrase(email string, protocol Protocol) (string, error) {
+	key := fmt.Sprintf("%s:%s", email, protocol)
+	if passphrase, err := GetPassword(key); err == nil {
+		return passphrase, nil
+	}
+
+	envKey := fmt.Sprintf("SOG_PASSWORD_%s_%s", sanitizeEnvKey(email), strings.ToUpper(string(protocol)))
+	if envPass := os.Getenv(envKey); envPass != "" {
+		return envPass, nil
+	}
+	return "", fmt.Errorf("no %s passphrase for %s (set with 'sog auth password %s --%s' or %s)", protocol, email, email, protocol, envKey)
+}
+
 // DeletePassword removes a password using the current storage type.
 func DeletePassword(email string) error {
 	switch CurrentStorage {
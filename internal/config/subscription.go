@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Subscription is a read-only calendar: an iCalendar feed published at a
+// URL (webcal) or a local .ics file.
+type Subscription struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+	File string `json:"file,omitempty"`
+}
+
+// Source returns the subscription's URL or file.
+func (s *Subscription) Source() string {
+	if s.File != "" {
+		return s.File
+	}
+	return s.URL
+}
+
+// FindSubscription returns the subscription with a name, or nil.
+func (c *Config) FindSubscription(name string) *Subscription {
+	for i := range c.Subscriptions {
+		if strings.EqualFold(c.Subscriptions[i].Name, name) {
+			return &c.Subscriptions[i]
+		}
+	}
+	return nil
+}
+
+// AddSubscription adds a subscription and saves the config.
+func (c *Config) AddSubscription(sub Subscription) error {
+	if sub.Name == "" {
+		return fmt.Errorf("subscription needs a name")
+	}
+	if (sub.URL == "") == (sub.File == "") {
+		return fmt.Errorf("subscription needs either a URL or a file")
+	}
+	if c.FindSubscription(sub.Name) != nil {
+		return fmt.Errorf("subscription already exists: %s", sub.Name)
+	}
+	c.Subscriptions = append(c.Subscriptions, sub)
+	return c.Save()
+}
+
+// RemoveSubscription removes a subscription and saves the config.
+func (c *Config) RemoveSubscription(name string) error {
+	subs := make([]Subscription, 0, len(c.Subscriptions))
+	for _, sub := range c.Subscriptions {
+		if !strings.EqualFold(sub.Name, name) {
+			subs = append(subs, sub)
+		}
+	}
+	if len(subs) == len(c.Subscriptions) {
+		return fmt.Errorf("subscription not found: %s", name)
+	}
+	c.Subscriptions = subs
+	return c.Save()
+}
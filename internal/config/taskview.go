@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TaskView is a saved task query, run with 'sog tasks view <name>'.
+type TaskView struct {
+	Name        string   `json:"name"`
+	Where       string   `json:"where,omitempty"` // Filter expression
+	Sort        string   `json:"sort,omitempty"`  // Sort keys, e.g. "due,priority"
+	Lists       []string `json:"lists,omitempty"` // Task list paths or names (default: the account's default)
+	AllLists    bool     `json:"all_lists,omitempty"`
+	AllAccounts bool     `json:"all_accounts,omitempty"`
+	All         bool     `json:"all,omitempty"` // Include completed tasks
+}
+
+// FindTaskView returns the saved task view with a name, or nil.
+func (c *Config) FindTaskView(name string) *TaskView {
+	for i := range c.TaskViews {
+		if strings.EqualFold(c.TaskViews[i].Name, name) {
+			return &c.TaskViews[i]
+		}
+	}
+	return nil
+}
+
+// SaveTaskView adds or replaces a task view and saves the config.
+func (c *Config) SaveTaskView(view TaskView) error {
+	if view.Name == "" {
+		return fmt.Errorf("task view needs a name")
+	}
+	if existing := c.FindTaskView(view.Name); existing != nil {
+		*existing = view
+	} else {
+		c.TaskViews = append(c.TaskViews, view)
+	}
+	return c.Save()
+}
+
+// RemoveTaskView removes a task view and saves the config.
+func (c *Config) RemoveTaskView(name string) error {
+	views := make([]TaskView, 0, len(c.TaskViews))
+	for _, view := range c.TaskViews {
+		if !strings.EqualFold(view.Name, name) {
+			views = append(views, view)
+		}
+	}
+	if len(views) == len(c.TaskViews) {
+		return fmt.Errorf("task view not found: %s", name)
+	}
+	c.TaskViews = views
+	return c.Save()
+}
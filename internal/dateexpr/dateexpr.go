@@ -0,0 +1,579 @@
+// Package dateexpr parses the date and time expressions accepted on the
+// command line, such as "tomorrow 2pm", "next friday 9:30", "in 3 days",
+// "eod", "+2w" or "2026-03-01 14:00".
+//
+// Expressions are resolved against a reference time passed by the caller,
+// in the reference time's location, so results are reproducible in tests.
+package dateexpr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse parses a date expression relative to now. Times of day are in
+// now's location. If the expression names only a day, such as a date or
+// "friday", the result is midnight and dateOnly is true. Relative
+// expressions without a day, such as "in 3 days" or "+1w", count from now.
+//
+// Accepted forms, combinable as "<day> [at] <time>":
+//
+//	days:      today, tomorrow, yesterday, monday..sunday (today or later),
+//	           next/last <weekday>, this/next/last week|month|year,
+//	           2026-03-01, 1-Mar-2026, 03/01/2026, "mar 1", "1 march 2026"
+//	times:     14:00, 9:30, 2pm, 2:30pm, "2 pm", noon, midnight
+//	relative:  now, in 3 days, in 2 hours, 3 days ago, +3d, -2w, +1h30m
+//	ends:      eod (23:59), eow (Sunday 23:59), eom (last day 23:59)
+//
+// RFC 3339 timestamps are also accepted.
+func Parse(s string, now time.Time) (t time.Time, dateOnly bool, err error) {
+	p := &parser{now: now, loc: now.Location()}
+	if err := p.parse(s); err != nil {
+		return time.Time{}, false, err
+	}
+	t, dateOnly = p.result()
+	return t, dateOnly, nil
+}
+
+// Date parses a date expression relative to now and returns midnight of the
+// day it names.
+func Date(s string, now time.Time) (time.Time, error) {
+	t, _, err := Parse(s, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+}
+
+// Range parses a span of time relative to now:
+//
+//	"next week", "this month": the whole week (Monday to Sunday) or month
+//	"tomorrow", "friday":      that day
+//	"tomorrow 2pm":            from then to the end of the day
+//	"7d", "2w":                from now for that long
+//	"A to B", "A..B":          from A to B, or to the end of B if it is a day
+func Range(s string, now time.Time) (start, end time.Time, err error) {
+	input := strings.TrimSpace(s)
+	for _, sep := range []string{"..", " to ", " until "} {
+		i := strings.Index(strings.ToLower(input), sep)
+		if i < 0 {
+			continue
+		}
+		from, to := input[:i], input[i+len(sep):]
+		if start, _, _, err = span(from, now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		var at time.Time
+		var point bool
+		if at, end, point, err = span(to, now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if point {
+			end = at
+		}
+		if !end.After(start) {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid range: %s ends before it starts", s)
+		}
+		return start, end, nil
+	}
+	start, end, _, err = span(input, now)
+	return start, end, err
+}
+
+// span returns the start and end of a single range expression. point
+// reports whether the expression names a time rather than a day or a
+// longer period.
+func span(s string, now time.Time) (start, end time.Time, point bool, err error) {
+	s = strings.TrimSpace(s)
+	if s != "" && s[0] != '+' && s[0] != '-' {
+		// Days and weeks are calendar days, as across a DST change
+		if d, ok := compactDuration(strings.ToLower(s)); ok && d.years == 0 && d.months == 0 {
+			return now, now.AddDate(0, 0, d.days).Add(d.exact), false, nil
+		}
+	}
+	p := &parser{now: now, loc: now.Location()}
+	if err := p.parse(s); err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+	t, dateOnly := p.result()
+	if !dateOnly {
+		return t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()), true, nil
+	}
+	length := p.span
+	if length == (offset{}) {
+		length = offset{days: 1}
+	}
+	return t, t.AddDate(length.years, length.months, length.days), false, nil
+}
+
+// ParseDuration parses a positive duration like time.ParseDuration, and
+// also accepts days and weeks ("1d", "2w", "1d12h"). A day is 24 hours.
+func ParseDuration(s string) (time.Duration, error) {
+	d, ok := compactDuration(strings.ToLower(strings.TrimSpace(s)))
+	if !ok || d.years != 0 || d.months != 0 {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	total := time.Duration(d.days)*24*time.Hour + d.exact
+	if total <= 0 {
+		return 0, fmt.Errorf("invalid duration: %s (must be positive)", s)
+	}
+	return total, nil
+}
+
+// parser collects the parts of an expression.
+type parser struct {
+	now time.Time
+	loc *time.Location
+
+	day     *time.Time // Midnight of the day named, if any
+	hour    int
+	minute  int
+	hasTime bool
+	offset  offset // Relative to now or to the day
+	exact   *time.Time
+	span    offset // Length of the week, month or year named, for Range
+}
+
+// offset is a relative amount of time. Calendar units are kept apart so
+// that "+1mo" is the same day next month.
+type offset struct {
+	years, months, days int
+	exact               time.Duration // Hours, minutes and seconds
+}
+
+func (o offset) scale(n int) offset {
+	return offset{years: o.years * n, months: o.months * n, days: o.days * n, exact: o.exact * time.Duration(n)}
+}
+
+func (o *offset) add(other offset) {
+	o.years += other.years
+	o.months += other.months
+	o.days += other.days
+	o.exact += other.exact
+}
+
+func (p *parser) today() time.Time {
+	n := p.now.In(p.loc)
+	return time.Date(n.Year(), n.Month(), n.Day(), 0, 0, 0, 0, p.loc)
+}
+
+func (p *parser) setDay(t time.Time) {
+	p.day = &t
+}
+
+func (p *parser) setTime(hour, minute int) {
+	p.hour, p.minute, p.hasTime = hour, minute, true
+}
+
+// absoluteLayouts are single-token dates and times.
+var absoluteLayouts = []struct {
+	layout  string
+	hasTime bool
+}{
+	{"2006-01-02", false},
+	{"2006-01-02T15:04", true},
+	{"2006-01-02T15:04:05", true},
+	{"2-Jan-2006", false},
+	{"02-Jan-2006", false},
+	{"01/02/2006", false},
+	{"1/2/2006", false},
+	{"20060102", false},
+	{"20060102T150405", true},
+}
+
+func (p *parser) parse(s string) error {
+	input := strings.TrimSpace(s)
+	if input == "" {
+		return fmt.Errorf("empty date")
+	}
+	if t, err := time.Parse(time.RFC3339, input); err == nil {
+		t = t.In(p.loc)
+		p.exact = &t
+		return nil
+	}
+
+	original := strings.Fields(strings.ReplaceAll(input, ",", " "))
+	tokens := strings.Fields(strings.ToLower(strings.ReplaceAll(input, ",", " ")))
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		next := func() string {
+			if i+1 < len(tokens) {
+				return tokens[i+1]
+			}
+			return ""
+		}
+
+		if p.absolute(original[i]) {
+			continue
+		}
+		n, err := p.monthDay(tokens[i:])
+		if err != nil {
+			return fmt.Errorf("invalid date %q: %w", s, err)
+		}
+		if n > 0 {
+			i += n - 1
+			continue
+		}
+		if hour, minute, ok := clock(tok, next()); ok {
+			if next() == "am" || next() == "pm" {
+				i++
+			}
+			p.setTime(hour, minute)
+			continue
+		}
+		if d, ok := compactDuration(tok); ok && (tok[0] == '+' || tok[0] == '-') {
+			p.offset.add(d)
+			continue
+		}
+		if wd, ok := weekday(tok); ok {
+			p.setDay(p.weekday(wd, 0))
+			continue
+		}
+
+		switch tok {
+		case "at", "on", "by":
+		case "now":
+			now := p.now.In(p.loc)
+			p.exact = &now
+		case "today":
+			p.setDay(p.today())
+		case "tomorrow", "tmr", "tmrw":
+			p.setDay(p.today().AddDate(0, 0, 1))
+		case "yesterday":
+			p.setDay(p.today().AddDate(0, 0, -1))
+		case "noon", "midday":
+			p.setTime(12, 0)
+		case "midnight":
+			p.setTime(0, 0)
+		case "eod":
+			p.setTime(23, 59)
+		case "eow":
+			p.setDay(p.weekday(time.Sunday, 0))
+			p.setTime(23, 59)
+		case "eom":
+			t := p.today()
+			p.setDay(time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, p.loc))
+			p.setTime(23, 59)
+		case "next", "last", "this":
+			dir := map[string]int{"next": 1, "last": -1, "this": 0}[tok]
+			if err := p.relativeNamed(dir, next()); err != nil {
+				return err
+			}
+			i++
+		case "in":
+			d, n, err := amount(tokens[i+1:])
+			if err != nil {
+				return fmt.Errorf("invalid date %q: %w", s, err)
+			}
+			p.offset.add(d)
+			i += n
+		default:
+			// "3 days ago"
+			if d, n, err := amount(tokens[i:]); err == nil && i+n < len(tokens) && tokens[i+n] == "ago" {
+				p.offset.add(d.scale(-1))
+				i += n
+				continue
+			}
+			return fmt.Errorf("invalid date: %s (try YYYY-MM-DD, 'tomorrow 2pm', 'next friday', 'in 3 days' or +Nd)", s)
+		}
+	}
+	return nil
+}
+
+// absolute handles a token in one of absoluteLayouts.
+func (p *parser) absolute(tok string) bool {
+	for _, l := range absoluteLayouts {
+		t, err := time.ParseInLocation(l.layout, tok, p.loc)
+		if err != nil {
+			// Month names are matched case-insensitively
+			t, err = time.ParseInLocation(l.layout, titleMonth(strings.ToLower(tok)), p.loc)
+		}
+		if err != nil {
+			continue
+		}
+		p.setDay(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, p.loc))
+		if l.hasTime {
+			p.setTime(t.Hour(), t.Minute())
+		}
+		return true
+	}
+	return false
+}
+
+// titleMonth capitalizes the month in "1-jan-2026".
+func titleMonth(tok string) string {
+	parts := strings.Split(tok, "-")
+	if len(parts) == 3 && len(parts[1]) == 3 {
+		parts[1] = strings.ToUpper(parts[1][:1]) + parts[1][1:]
+	}
+	return strings.Join(parts, "-")
+}
+
+// monthDay handles "mar 1", "march 1st 2026" and "1 mar [2026]", returning
+// the number of tokens used. Without a year the current year is assumed.
+// A day the month doesn't have, such as "feb 30", is an error.
+func (p *parser) monthDay(tokens []string) (int, error) {
+	if len(tokens) < 2 {
+		return 0, nil
+	}
+	var month time.Month
+	var day int
+	if m, ok := monthName(tokens[0]); ok {
+		if d, ok := dayNumber(tokens[1]); ok {
+			month, day = m, d
+		}
+	} else if d, ok := dayNumber(tokens[0]); ok {
+		if m, ok := monthName(tokens[1]); ok {
+			month, day = m, d
+		}
+	}
+	if month == 0 {
+		return 0, nil
+	}
+
+	used := 2
+	year := p.today().Year()
+	if len(tokens) > 2 && len(tokens[2]) == 4 {
+		if y, err := strconv.Atoi(tokens[2]); err == nil {
+			year = y
+			used++
+		}
+	}
+	date := time.Date(year, month, day, 0, 0, 0, 0, p.loc)
+	if date.Day() != day {
+		return 0, fmt.Errorf("%s %d has no day %d", month, year, day)
+	}
+	p.setDay(date)
+	return used, nil
+}
+
+// relativeNamed handles "next friday", "last week", "this month" and the
+// like.
+func (p *parser) relativeNamed(dir int, what string) error {
+	today := p.today()
+	if wd, ok := weekday(what); ok {
+		p.setDay(p.weekday(wd, dir))
+		return nil
+	}
+	switch strings.TrimSuffix(what, "s") {
+	case "day":
+		p.setDay(today.AddDate(0, 0, dir))
+	case "week", "wk":
+		// Weeks start on Monday
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		p.setDay(monday.AddDate(0, 0, 7*dir))
+		p.span = offset{days: 7}
+	case "month":
+		p.setDay(time.Date(today.Year(), today.Month()+time.Month(dir), 1, 0, 0, 0, 0, p.loc))
+		p.span = offset{months: 1}
+	case "year":
+		p.setDay(time.Date(today.Year()+dir, 1, 1, 0, 0, 0, 0, p.loc))
+		p.span = offset{years: 1}
+	default:
+		return fmt.Errorf("invalid date: expected a weekday, week, month or year after %q", map[int]string{1: "next", -1: "last", 0: "this"}[dir])
+	}
+	return nil
+}
+
+// weekday returns the day with weekday wd: for dir 0 today or the next
+// one, for dir 1 the next one after today, for dir -1 the last one before
+// today.
+func (p *parser) weekday(wd time.Weekday, dir int) time.Time {
+	today := p.today()
+	diff := (int(wd) - int(today.Weekday()) + 7) % 7
+	switch dir {
+	case 1:
+		if diff == 0 {
+			diff = 7
+		}
+	case -1:
+		diff -= 7
+	}
+	return today.AddDate(0, 0, diff)
+}
+
+func (p *parser) result() (time.Time, bool) {
+	if p.exact != nil {
+		return p.apply(*p.exact), false
+	}
+	if p.day == nil && !p.hasTime && p.offset != (offset{}) {
+		// "in 2 hours" and "in 3 days" count from now
+		return p.apply(p.now.In(p.loc)), false
+	}
+
+	day := p.today()
+	if p.day != nil {
+		day = *p.day
+	}
+	if !p.hasTime {
+		return p.apply(day), true
+	}
+	t := time.Date(day.Year(), day.Month(), day.Day(), p.hour, p.minute, 0, 0, p.loc)
+	return p.apply(t), false
+}
+
+func (p *parser) apply(t time.Time) time.Time {
+	o := p.offset
+	t = t.AddDate(o.years, o.months, o.days)
+	return t.Add(o.exact)
+}
+
+var clockPattern = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm|a|p)?$`)
+
+// clock parses a time of day. A bare number is only a time when followed
+// by "am" or "pm", so "mar 2" stays a date.
+func clock(tok, next string) (hour, minute int, ok bool) {
+	m := clockPattern.FindStringSubmatch(tok)
+	if m == nil {
+		return 0, 0, false
+	}
+	suffix := m[3]
+	if suffix == "" && (next == "am" || next == "pm") {
+		suffix = next
+	}
+	if m[2] == "" && suffix == "" {
+		return 0, 0, false
+	}
+
+	hour, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if minute > 59 {
+		return 0, 0, false
+	}
+	switch suffix {
+	case "":
+		if hour > 23 {
+			return 0, 0, false
+		}
+	default:
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if suffix[0] == 'p' {
+			hour += 12
+		}
+	}
+	return hour, minute, true
+}
+
+// amount parses "3 days", "a week", "2h" or "1h30m" at the start of tokens,
+// returning the number of tokens used.
+func amount(tokens []string) (offset, int, error) {
+	if len(tokens) == 0 {
+		return offset{}, 0, fmt.Errorf("missing amount")
+	}
+	if d, ok := compactDuration(tokens[0]); ok {
+		return d, 1, nil
+	}
+	if len(tokens) < 2 {
+		return offset{}, 0, fmt.Errorf("missing unit after %q", tokens[0])
+	}
+	n, err := strconv.Atoi(tokens[0])
+	if tokens[0] == "a" || tokens[0] == "an" {
+		n, err = 1, nil
+	}
+	if err != nil {
+		return offset{}, 0, fmt.Errorf("invalid amount %q", tokens[0])
+	}
+	d, ok := unit(tokens[1])
+	if !ok {
+		return offset{}, 0, fmt.Errorf("invalid unit %q", tokens[1])
+	}
+	return d.scale(n), 2, nil
+}
+
+var compactPattern = regexp.MustCompile(`(\d+)([a-z]+)`)
+
+// compactDuration parses "+3d", "-2w", "1h30m" or "90m".
+func compactDuration(tok string) (offset, bool) {
+	sign := 1
+	rest := tok
+	switch {
+	case strings.HasPrefix(rest, "+"):
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "-"):
+		sign, rest = -1, rest[1:]
+	}
+	if rest == "" {
+		return offset{}, false
+	}
+	matches := compactPattern.FindAllStringSubmatchIndex(rest, -1)
+	var total offset
+	pos := 0
+	for _, m := range matches {
+		if m[0] != pos {
+			return offset{}, false
+		}
+		n, _ := strconv.Atoi(rest[m[2]:m[3]])
+		d, ok := unit(rest[m[4]:m[5]])
+		if !ok {
+			return offset{}, false
+		}
+		total.add(d.scale(n))
+		pos = m[1]
+	}
+	if pos != len(rest) {
+		return offset{}, false
+	}
+	return total.scale(sign), true
+}
+
+// unit returns one of a unit of time.
+func unit(s string) (offset, bool) {
+	switch s {
+	case "s", "sec", "secs", "second", "seconds":
+		return offset{exact: time.Second}, true
+	case "m", "min", "mins", "minute", "minutes":
+		return offset{exact: time.Minute}, true
+	case "h", "hr", "hrs", "hour", "hours":
+		return offset{exact: time.Hour}, true
+	case "d", "day", "days":
+		return offset{days: 1}, true
+	case "w", "wk", "wks", "week", "weeks":
+		return offset{days: 7}, true
+	case "mo", "month", "months":
+		return offset{months: 1}, true
+	case "y", "yr", "yrs", "year", "years":
+		return offset{years: 1}, true
+	}
+	return offset{}, false
+}
+
+func weekday(s string) (time.Weekday, bool) {
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if s == name || s == name[:3] || (len(s) >= 3 && strings.HasPrefix(name, s)) {
+			return wd, true
+		}
+	}
+	return 0, false
+}
+
+func monthName(s string) (time.Month, bool) {
+	s = strings.TrimSuffix(s, ".")
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		if len(s) >= 3 && strings.HasPrefix(name, s) {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// dayNumber parses a day of the month like "1", "01" or "1st".
+func dayNumber(s string) (int, bool) {
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		s = strings.TrimSuffix(s, suffix)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 31 {
+		return 0, false
+	}
+	return n, true
+}
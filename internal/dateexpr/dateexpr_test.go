@@ -0,0 +1,170 @@
+package dateexpr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// Wednesday
+	now := time.Date(2026, 3, 4, 10, 30, 0, 0, berlin)
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, berlin)
+	}
+	at := func(y int, m time.Month, d, hour, minute int) time.Time {
+		return time.Date(y, m, d, hour, minute, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		expr     string
+		want     time.Time
+		dateOnly bool
+	}{
+		{"today", day(2026, 3, 4), true},
+		{"Tomorrow", day(2026, 3, 5), true},
+		{"yesterday", day(2026, 3, 3), true},
+		{"tomorrow 2pm", at(2026, 3, 5, 14, 0), false},
+		{"tomorrow at 2 PM", at(2026, 3, 5, 14, 0), false},
+		{"friday", day(2026, 3, 6), true},
+		{"wed", day(2026, 3, 4), true},
+		{"next wednesday", day(2026, 3, 11), true},
+		{"last wednesday", day(2026, 2, 25), true},
+		{"next friday 9:30", at(2026, 3, 6, 9, 30), false},
+		{"monday noon", at(2026, 3, 9, 12, 0), false},
+		{"next week", day(2026, 3, 9), true},
+		{"this week", day(2026, 3, 2), true},
+		{"next month", day(2026, 4, 1), true},
+		{"last year", day(2025, 1, 1), true},
+		{"in 3 days", at(2026, 3, 7, 10, 30), false},
+		{"in a week", at(2026, 3, 11, 10, 30), false},
+		{"in 2 hours", at(2026, 3, 4, 12, 30), false},
+		{"in 1h30m", at(2026, 3, 4, 12, 0), false},
+		{"3 days ago", at(2026, 3, 1, 10, 30), false},
+		{"+3d", at(2026, 3, 7, 10, 30), false},
+		{"-2w", at(2026, 2, 18, 10, 30), false},
+		{"+1mo", at(2026, 4, 4, 10, 30), false},
+		{"tomorrow +2d", day(2026, 3, 7), true},
+		{"+30m", at(2026, 3, 4, 11, 0), false},
+		{"now", now, false},
+		{"eod", at(2026, 3, 4, 23, 59), false},
+		{"friday eod", at(2026, 3, 6, 23, 59), false},
+		{"eow", at(2026, 3, 8, 23, 59), false},
+		{"eom", at(2026, 3, 31, 23, 59), false},
+		{"2026-03-01", day(2026, 3, 1), true},
+		{"2026-03-01 14:00", at(2026, 3, 1, 14, 0), false},
+		{"2026-03-01T14:00", at(2026, 3, 1, 14, 0), false},
+		{"1-Jan-2026", day(2026, 1, 1), true},
+		{"01/15/2026", day(2026, 1, 15), true},
+		{"mar 10", day(2026, 3, 10), true},
+		{"March 10th, 2027 9am", at(2027, 3, 10, 9, 0), false},
+		{"10 march", day(2026, 3, 10), true},
+		{"feb 29 2028", day(2028, 2, 29), true},
+		{"12am", at(2026, 3, 4, 0, 0), false},
+		{"12:15pm", at(2026, 3, 4, 12, 15), false},
+		{"2026-03-01T14:00:00Z", at(2026, 3, 1, 15, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, dateOnly, err := Parse(tt.expr, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.String(), got.String())
+			assert.Equal(t, tt.dateOnly, dateOnly)
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)
+	for _, expr := range []string{"", "someday", "next", "next fortnight", "in", "in 3 parsecs", "25:00", "13pm", "2026-13-01", "feb 30", "apr 31", "31 june", "feb 29 2026"} {
+		_, _, err := Parse(expr, now)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestParseAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// Clocks go forward on Sunday 29 March 2026
+	now := time.Date(2026, 3, 28, 9, 0, 0, 0, berlin)
+
+	got, _, err := Parse("tomorrow 9am", now)
+	require.NoError(t, err)
+	assert.Equal(t, 9, got.Hour())
+	assert.Equal(t, 23*time.Hour, got.Sub(now))
+
+	got, _, err = Parse("+1d", now)
+	require.NoError(t, err)
+	assert.Equal(t, 29, got.Day())
+	assert.Equal(t, 9, got.Hour())
+
+	// Ranges of days keep the time of day too
+	start, end, err := Range("7d", now)
+	require.NoError(t, err)
+	assert.Equal(t, now, start)
+	assert.Equal(t, time.Date(2026, 4, 4, 9, 0, 0, 0, berlin), end)
+}
+
+func TestDate(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)
+	got, err := Date("tomorrow 2pm", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC), got)
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30m":   30 * time.Minute,
+		"1h30m": 90 * time.Minute,
+		"1d":    24 * time.Hour,
+		"2w":    14 * 24 * time.Hour,
+		"1d12h": 36 * time.Hour,
+	}
+	for s, want := range tests {
+		got, err := ParseDuration(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+	for _, s := range []string{"", "1mo", "abc", "1x", "0m", "-15m"} {
+		_, err := ParseDuration(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestRange(t *testing.T) {
+	// Wednesday
+	now := time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)
+	day := func(m time.Month, d int) time.Time {
+		return time.Date(2026, m, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		expr       string
+		start, end time.Time
+	}{
+		{"next week", day(3, 9), day(3, 16)},
+		{"this month", day(3, 1), day(4, 1)},
+		{"tomorrow", day(3, 5), day(3, 6)},
+		{"friday", day(3, 6), day(3, 7)},
+		{"tomorrow 2pm", time.Date(2026, 3, 5, 14, 0, 0, 0, time.UTC), day(3, 6)},
+		{"7d", now, now.Add(7 * 24 * time.Hour)},
+		{"friday to monday", day(3, 6), day(3, 10)},
+		{"2026-03-05T09:00..2026-03-05T17:00", time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 5, 17, 0, 0, 0, time.UTC)},
+		{"now until eow", now, time.Date(2026, 3, 8, 23, 59, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		start, end, err := Range(tt.expr, now)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.start, start, tt.expr)
+		assert.Equal(t, tt.end, end, tt.expr)
+	}
+
+	_, _, err := Range("tomorrow to yesterday", now)
+	assert.Error(t, err)
+	_, _, err = Range("someday", now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,119 @@
+// Package dav adds optimistic concurrency to the CalDAV, CardDAV and WebDAV
+// clients: conditional requests (If-Match, If-None-Match) and a typed
+// error for writes rejected because the resource changed on the server.
+package dav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emersion/go-webdav"
+)
+
+type contextKey int
+
+const (
+	ifMatchKey contextKey = iota
+	ifNoneMatchKey
+	forceKey
+)
+
+// IfMatch returns a context whose writes only succeed if the resource still
+// has the given ETag. An empty etag leaves ctx unchanged.
+func IfMatch(ctx context.Context, etag string) context.Context {
+	if etag == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, ifMatchKey, etag)
+}
+
+// IfNoneMatch returns a context whose writes only succeed if the resource
+// does not exist yet (If-None-Match: *).
+func IfNoneMatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ifNoneMatchKey, true)
+}
+
+// Force returns a context whose writes are sent without preconditions,
+// overwriting whatever is on the server.
+func Force(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceKey, true)
+}
+
+// IsForced reports whether ctx was created by Force.
+func IsForced(ctx context.Context) bool {
+	forced, _ := ctx.Value(forceKey).(bool)
+	return forced
+}
+
+// ConflictError is returned when the server rejects a conditional write
+// with 412 Precondition Failed.
+type ConflictError struct {
+	Path string // Resource path
+	ETag string // ETag the write expected; empty for creates
+}
+
+func (e *ConflictError) Error() string {
+	if e.ETag == "" {
+		return fmt.Sprintf("%s already exists on the server", e.Path)
+	}
+	return fmt.Sprintf("%s was changed on the server since it was read", e.Path)
+}
+
+// IsConflict reports whether err is or wraps a ConflictError.
+func IsConflict(err error) bool {
+	var conflict *ConflictError
+	return errors.As(err, &conflict)
+}
+
+// HTTPClient wraps c so that requests carry the preconditions set on their
+// context, and 412 responses to them become a *ConflictError.
+func HTTPClient(c webdav.HTTPClient) webdav.HTTPClient {
+	return &conditionalClient{c}
+}
+
+type conditionalClient struct {
+	c webdav.HTTPClient
+}
+
+func (c *conditionalClient) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if IsForced(ctx) {
+		return c.c.Do(req)
+	}
+
+	etag, _ := ctx.Value(ifMatchKey).(string)
+	create, _ := ctx.Value(ifNoneMatchKey).(bool)
+	if etag == "" && !create {
+		return c.c.Do(req)
+	}
+
+	req = req.Clone(ctx)
+	if etag != "" {
+		req.Header.Set("If-Match", quoteETag(etag))
+	}
+	if create {
+		req.Header.Set("If-None-Match", "*")
+	}
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		resp.Body.Close()
+		return nil, &ConflictError{Path: req.URL.Path, ETag: etag}
+	}
+	return resp, nil
+}
+
+// quoteETag turns an ETag as reported by go-webdav (unquoted) back into
+// its header form. Weak and already quoted tags are left as they are.
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return fmt.Sprintf("%q", etag)
+}
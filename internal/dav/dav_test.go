@@ -0,0 +1,109 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-webdav"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient(t *testing.T) {
+	var ifMatch, ifNoneMatch string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifMatch, ifNoneMatch = r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+		if ifMatch == `"stale"` || ifNoneMatch == "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, err := webdav.NewClient(HTTPClient(http.DefaultClient), server.URL)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Unconditional
+	require.NoError(t, client.RemoveAll(ctx, "/a.ics"))
+	assert.Empty(t, ifMatch)
+	assert.Empty(t, ifNoneMatch)
+
+	require.NoError(t, client.RemoveAll(IfMatch(ctx, "current"), "/a.ics"))
+	assert.Equal(t, `"current"`, ifMatch)
+
+	err = client.RemoveAll(IfMatch(ctx, "stale"), "/a.ics")
+	var conflict *ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "/a.ics", conflict.Path)
+	assert.Equal(t, "stale", conflict.ETag)
+	assert.Contains(t, err.Error(), "changed on the server")
+
+	err = client.RemoveAll(IfNoneMatch(ctx), "/a.ics")
+	assert.True(t, IsConflict(err))
+	assert.Contains(t, err.Error(), "already exists")
+
+	// Force drops all preconditions
+	require.NoError(t, client.RemoveAll(Force(IfMatch(ctx, "stale")), "/a.ics"))
+	assert.Empty(t, ifMatch)
+}
+
+func TestQuoteETag(t *testing.T) {
+	assert.Equal(t, `"abc"`, quoteETag("abc"))
+	assert.Equal(t, `"abc"`, quoteETag(`"abc"`))
+	assert.Equal(t, `W/"abc"`, quoteETag(`W/"abc"`))
+}
+
+type record struct {
+	Name  string    `json:"name"`
+	Tags  []string  `json:"tags,omitempty"`
+	When  time.Time `json:"when"`
+	Notes string
+	ETag  string `json:"etag"`
+}
+
+func TestMerge(t *testing.T) {
+	when := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	base := record{Name: "a", Tags: []string{"x"}, When: when, Notes: "n", ETag: "1"}
+
+	mine := base
+	mine.Name = "mine"
+	mine.When = when.In(time.FixedZone("X", 3600)) // same instant
+	mine.Notes = "mine"
+
+	theirs := base
+	theirs.Tags = []string{"x", "y"}
+	theirs.Notes = "theirs"
+	theirs.ETag = "2"
+
+	var conflicts []string
+	merged := Merge(base, mine, theirs, func(c Conflict) bool {
+		conflicts = append(conflicts, c.Field)
+		assert.Equal(t, "mine", c.Mine)
+		assert.Equal(t, "theirs", c.Theirs)
+		return true
+	})
+	assert.Equal(t, []string{"Notes"}, conflicts)
+	assert.Equal(t, "mine", merged.Name)
+	assert.Equal(t, []string{"x", "y"}, merged.Tags)
+	assert.Equal(t, "mine", merged.Notes)
+	assert.Equal(t, "2", merged.ETag)
+	assert.True(t, merged.When.Equal(when))
+
+	merged = Merge(base, mine, theirs, func(Conflict) bool { return false })
+	assert.Equal(t, "theirs", merged.Notes)
+}
+
+func TestJSONName(t *testing.T) {
+	var fields []string
+	Merge(record{}, record{Name: "a", Tags: []string{"t"}}, record{Name: "b", Tags: []string{"u"}}, func(c Conflict) bool {
+		fields = append(fields, c.Field)
+		return false
+	})
+	assert.Equal(t, "name,tags", strings.Join(fields, ","))
+}
@@ -0,0 +1,73 @@
+package dav
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Conflict is a field changed to different values locally and on the
+// server.
+type Conflict struct {
+	Field  string // JSON name of the field
+	Mine   any
+	Theirs any
+}
+
+// Merge does a three-way merge of two edits of the struct base: each
+// exported field changed on only one side takes that side's value, and
+// fields changed on both sides to different values are passed to resolve,
+// which returns true to keep mine. Fields neither side touched, and
+// bookkeeping like ETag, come from theirs so the result can be written
+// against the server's current version.
+func Merge[T any](base, mine, theirs T, resolve func(Conflict) bool) T {
+	merged := theirs
+	b, m := reflect.ValueOf(base), reflect.ValueOf(mine)
+	t := reflect.ValueOf(theirs)
+	out := reflect.ValueOf(&merged).Elem()
+
+	for i := 0; i < out.NumField(); i++ {
+		field := out.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		bf, mf, tf := b.Field(i), m.Field(i), t.Field(i)
+		switch {
+		case equal(mf, bf), equal(mf, tf):
+			// Only theirs changed, or both made the same change
+		case equal(tf, bf):
+			out.Field(i).Set(mf)
+		case resolve(Conflict{Field: jsonName(field), Mine: mf.Interface(), Theirs: tf.Interface()}):
+			out.Field(i).Set(mf)
+		}
+	}
+	return merged
+}
+
+// equal compares field values, treating times as equal when they are the
+// same instant.
+func equal(a, b reflect.Value) bool {
+	if ta, ok := a.Interface().(time.Time); ok {
+		return ta.Equal(b.Interface().(time.Time))
+	}
+	if a.Kind() == reflect.Slice {
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equal(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
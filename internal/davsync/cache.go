@@ -0,0 +1,107 @@
+package davsync
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Collection is the cached copy of a collection.
+type Collection struct {
+	Path             string            `json:"path"`
+	SyncToken        string            `json:"sync_token,omitempty"`
+	CTag             string            `json:"ctag,omitempty"`
+	NoSyncCollection bool              `json:"no_sync_collection,omitempty"` // The server rejected sync-collection
+	Synced           time.Time         `json:"synced,omitempty"`
+	Objects          map[string]Object `json:"objects"` // By href
+}
+
+// Object is a cached calendar object or vCard.
+type Object struct {
+	ETag string `json:"etag,omitempty"`
+	Data string `json:"data"`
+}
+
+// Hrefs returns the hrefs of the cached objects, sorted.
+func (c *Collection) Hrefs() []string {
+	hrefs := make([]string, 0, len(c.Objects))
+	for href := range c.Objects {
+		hrefs = append(hrefs, href)
+	}
+	sort.Strings(hrefs)
+	return hrefs
+}
+
+// ErrNotCached is returned by Store.Load for collections never synced.
+var ErrNotCached = errors.New("collection is not cached")
+
+// Store keeps cached collections as JSON files in a directory, one per
+// collection.
+type Store struct {
+	Dir string
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// file returns the cache file of a collection. The name keeps a readable
+// prefix and is made unique by a hash of the path.
+func (s *Store) file(kind, path string) string {
+	sum := sha1.Sum([]byte(path))
+	name := unsafeChars.ReplaceAllString(filepath.Base(filepath.Clean("/"+path)), "_")
+	return filepath.Join(s.Dir, kind+"-"+name+"-"+hex.EncodeToString(sum[:6])+".json")
+}
+
+// Load returns the cached collection at path, or ErrNotCached. kind keeps
+// caches of different protocols apart ("cal", "contacts").
+func (s *Store) Load(kind, path string) (*Collection, error) {
+	data, err := os.ReadFile(s.file(kind, path))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotCached)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+	var col Collection
+	if err := json.Unmarshal(data, &col); err != nil {
+		return nil, fmt.Errorf("failed to parse cache: %w", err)
+	}
+	if col.Objects == nil {
+		col.Objects = map[string]Object{}
+	}
+	return &col, nil
+}
+
+// Save writes a collection to the cache.
+func (s *Store) Save(kind string, col *Collection) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	data, err := json.Marshal(col)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache: %w", err)
+	}
+	path := s.file(kind, col.Path)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes a collection from the cache.
+func (s *Store) Remove(kind, path string) error {
+	if err := os.Remove(s.file(kind, path)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cache: %w", err)
+	}
+	return nil
+}
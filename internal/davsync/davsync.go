@@ -0,0 +1,448 @@
+// Package davsync keeps local copies of CalDAV and CardDAV collections up
+// to date. Changes are fetched with sync-collection (RFC 6578) when the
+// server supports it; otherwise the collection's ctag tells whether
+// anything changed, and member ETags which objects did. Only new and
+// changed objects are downloaded, with calendar-multiget or
+// addressbook-multiget.
+package davsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-webdav"
+)
+
+// Kind describes the multiget REPORT of a DAV protocol.
+type Kind struct {
+	Namespace string // XML namespace of the REPORT and data property
+	MultiGet  string // Name of the multiget REPORT
+	Data      string // Name of the object data property
+}
+
+// Protocols
+var (
+	CalDAV  = Kind{Namespace: "urn:ietf:params:xml:ns:caldav", MultiGet: "calendar-multiget", Data: "calendar-data"}
+	CardDAV = Kind{Namespace: "urn:ietf:params:xml:ns:carddav", MultiGet: "addressbook-multiget", Data: "address-data"}
+)
+
+// Sync methods
+const (
+	MethodSyncCollection = "sync-collection"
+	MethodCTag           = "ctag"
+	MethodETag           = "etag"
+)
+
+// multigetBatch is the number of objects fetched per multiget REPORT.
+const multigetBatch = 100
+
+// Syncer brings cached collections up to date from a server.
+type Syncer struct {
+	HTTP    webdav.HTTPClient
+	BaseURL string // Server URL that collection paths are resolved against
+	Kind    Kind
+}
+
+// Result summarizes a sync.
+type Result struct {
+	Path    string `json:"path"`
+	Method  string `json:"method"` // sync-collection, ctag or etag
+	Added   int    `json:"added"`
+	Updated int    `json:"updated"`
+	Deleted int    `json:"deleted"`
+	Total   int    `json:"total"`
+}
+
+// Changed reports whether the sync changed the cached collection.
+func (r *Result) Changed() bool {
+	return r.Added+r.Updated+r.Deleted > 0
+}
+
+// Sync updates col, the cached copy of the collection at col.Path, with the
+// changes on the server. A rejected sync token makes it start over.
+func (s *Syncer) Sync(ctx context.Context, col *Collection) (*Result, error) {
+	if col.Objects == nil {
+		col.Objects = map[string]Object{}
+	}
+	result := &Result{Path: col.Path}
+
+	if !col.NoSyncCollection {
+		err := s.syncCollection(ctx, col, result)
+		if tokenRejected(err) && col.SyncToken != "" {
+			// The token may have expired: start over
+			col.SyncToken = ""
+			*result = Result{Path: col.Path}
+			err = s.syncCollection(ctx, col, result)
+		}
+		if err == nil {
+			return s.finish(col, result), nil
+		}
+		if !unsupported(err) {
+			return nil, err
+		}
+		col.NoSyncCollection = true
+		*result = Result{Path: col.Path}
+	}
+
+	if err := s.syncETags(ctx, col, result); err != nil {
+		return nil, err
+	}
+	return s.finish(col, result), nil
+}
+
+func (s *Syncer) finish(col *Collection, result *Result) *Result {
+	col.Synced = time.Now().UTC()
+	result.Total = len(col.Objects)
+	return result
+}
+
+// syncCollection fetches the changes since col.SyncToken, all members if
+// it is empty.
+func (s *Syncer) syncCollection(ctx context.Context, col *Collection, result *Result) error {
+	result.Method = MethodSyncCollection
+	token := col.SyncToken
+	initial := token == ""
+	var changed []string
+	seen := map[string]bool{}
+	// A server may truncate the results (507) and expects another request
+	// with the token it returned
+	for round := 0; round < 100; round++ {
+		body := `<?xml version="1.0" encoding="utf-8"?>` + "\n" +
+			`<D:sync-collection xmlns:D="DAV:"><D:sync-token>` + xmlEscape(token) + `</D:sync-token>` +
+			`<D:sync-level>1</D:sync-level><D:prop><D:getetag/></D:prop></D:sync-collection>`
+		var ms multistatus
+		if err := s.report(ctx, col.Path, "1", body, &ms); err != nil {
+			return err
+		}
+		truncated := false
+		for _, resp := range ms.Responses {
+			href := hrefPath(resp.Href)
+			if s.isCollection(href, col.Path) {
+				truncated = truncated || strings.Contains(resp.Status, " 507")
+				continue
+			}
+			if strings.Contains(resp.Status, " 404") {
+				if _, ok := col.Objects[href]; ok {
+					delete(col.Objects, href)
+					result.Deleted++
+				}
+				continue
+			}
+			if seen[href] {
+				continue
+			}
+			seen[href] = true
+			etag := resp.etag()
+			if obj, ok := col.Objects[href]; ok && etag != "" && obj.ETag == etag {
+				continue
+			}
+			changed = append(changed, href)
+		}
+		if ms.SyncToken == "" {
+			return fmt.Errorf("server returned no sync token")
+		}
+		token = ms.SyncToken
+		if !truncated {
+			break
+		}
+	}
+
+	if initial {
+		// A full listing: whatever it did not list is gone
+		for href := range col.Objects {
+			if !seen[href] {
+				delete(col.Objects, href)
+				result.Deleted++
+			}
+		}
+	}
+	if err := s.fetch(ctx, col, changed, result); err != nil {
+		return err
+	}
+	col.SyncToken = token
+	return nil
+}
+
+// syncETags compares the members' ETags with the cache, after checking
+// the collection's ctag when the server has one.
+func (s *Syncer) syncETags(ctx context.Context, col *Collection, result *Result) error {
+	result.Method = MethodETag
+	var ms multistatus
+	err := s.propfind(ctx, col.Path, "0", `<CS:getctag xmlns:CS="http://calendarserver.org/ns/"/>`, &ms)
+	if err != nil {
+		return err
+	}
+	ctag := ""
+	for _, resp := range ms.Responses {
+		if v := resp.prop().CTag; v != "" {
+			ctag = strings.TrimSpace(v)
+		}
+	}
+	if ctag != "" {
+		result.Method = MethodCTag
+		if ctag == col.CTag && !col.Synced.IsZero() {
+			return nil
+		}
+	}
+
+	ms = multistatus{}
+	if err := s.propfind(ctx, col.Path, "1", `<D:getetag/><D:resourcetype/>`, &ms); err != nil {
+		return err
+	}
+	present := map[string]bool{}
+	var changed []string
+	for _, resp := range ms.Responses {
+		href := hrefPath(resp.Href)
+		if s.isCollection(href, col.Path) || resp.prop().ResourceType.Collection != nil {
+			continue
+		}
+		present[href] = true
+		etag := resp.etag()
+		if obj, ok := col.Objects[href]; ok && etag != "" && obj.ETag == etag {
+			continue
+		}
+		changed = append(changed, href)
+	}
+	for href := range col.Objects {
+		if !present[href] {
+			delete(col.Objects, href)
+			result.Deleted++
+		}
+	}
+	if err := s.fetch(ctx, col, changed, result); err != nil {
+		return err
+	}
+	col.CTag = ctag
+	return nil
+}
+
+// fetch downloads objects with multiget REPORTs and stores them in col.
+// Objects deleted in the meantime are dropped.
+func (s *Syncer) fetch(ctx context.Context, col *Collection, hrefs []string, result *Result) error {
+	for len(hrefs) > 0 {
+		batch := hrefs
+		if len(batch) > multigetBatch {
+			batch = batch[:multigetBatch]
+		}
+		hrefs = hrefs[len(batch):]
+
+		var buf bytes.Buffer
+		buf.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+		fmt.Fprintf(&buf, `<X:%s xmlns:D="DAV:" xmlns:X="%s"><D:prop><D:getetag/><X:%s/></D:prop>`,
+			s.Kind.MultiGet, s.Kind.Namespace, s.Kind.Data)
+		for _, href := range batch {
+			fmt.Fprintf(&buf, `<D:href>%s</D:href>`, xmlEscape(href))
+		}
+		fmt.Fprintf(&buf, `</X:%s>`, s.Kind.MultiGet)
+
+		var ms multistatus
+		if err := s.report(ctx, col.Path, "1", buf.String(), &ms); err != nil {
+			return err
+		}
+		for _, resp := range ms.Responses {
+			href := hrefPath(resp.Href)
+			prop := resp.prop()
+			data := prop.CalendarData
+			if s.Kind == CardDAV {
+				data = prop.AddressData
+			}
+			if strings.TrimSpace(data) == "" {
+				if _, ok := col.Objects[href]; ok {
+					delete(col.Objects, href)
+					result.Deleted++
+				}
+				continue
+			}
+			if _, ok := col.Objects[href]; ok {
+				result.Updated++
+			} else {
+				result.Added++
+			}
+			col.Objects[href] = Object{ETag: resp.etag(), Data: data}
+		}
+	}
+	return nil
+}
+
+// statusError is an unexpected HTTP response status.
+type statusError struct {
+	Method string
+	Path   string
+	Code   int
+	Status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.Path, e.Status)
+}
+
+// tokenRejected reports whether err may be a server's refusal of an
+// expired sync token: 403 with DAV:valid-sync-token (RFC 6578), or 400 or
+// 409 on some servers.
+func tokenRejected(err error) bool {
+	var status *statusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	switch status.Code {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusConflict:
+		return true
+	}
+	return false
+}
+
+// unsupported reports whether err is a server's refusal of a REPORT it
+// does not support. Other errors, such as 401 or 503, say nothing about
+// sync-collection and must not turn it off for good.
+func unsupported(err error) bool {
+	var status *statusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	switch status.Code {
+	case http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+func (s *Syncer) report(ctx context.Context, path, depth, body string, v *multistatus) error {
+	return s.do(ctx, "REPORT", path, depth, body, v)
+}
+
+func (s *Syncer) propfind(ctx context.Context, path, depth, props string, v *multistatus) error {
+	body := `<?xml version="1.0" encoding="utf-8"?>` + "\n" +
+		`<D:propfind xmlns:D="DAV:"><D:prop>` + props + `</D:prop></D:propfind>`
+	return s.do(ctx, "PROPFIND", path, depth, body, v)
+}
+
+// do sends a request expecting a 207 Multi-Status response.
+func (s *Syncer) do(ctx context.Context, method, path, depth, body string, v *multistatus) error {
+	base, err := url.Parse(s.BaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid server URL: %w", err)
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("invalid path %q: %w", path, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, base.ResolveReference(ref).String(), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", depth)
+	resp, err := s.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", method, err)
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return &statusError{Method: method, Path: path, Code: resp.StatusCode, Status: resp.Status}
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", method, err)
+	}
+	return nil
+}
+
+// multistatus is a WebDAV 207 response body.
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []response `xml:"DAV: response"`
+	SyncToken string     `xml:"DAV: sync-token"`
+}
+
+type response struct {
+	Href      string `xml:"DAV: href"`
+	Status    string `xml:"DAV: status"`
+	Propstats []struct {
+		Prop   props  `xml:"DAV: prop"`
+		Status string `xml:"DAV: status"`
+	} `xml:"DAV: propstat"`
+}
+
+type props struct {
+	ETag         string `xml:"DAV: getetag"`
+	ResourceType struct {
+		Collection *struct{} `xml:"DAV: collection"`
+	} `xml:"DAV: resourcetype"`
+	CTag         string `xml:"http://calendarserver.org/ns/ getctag"`
+	CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+	AddressData  string `xml:"urn:ietf:params:xml:ns:carddav address-data"`
+}
+
+// prop returns the properties found with a 2xx status, merged.
+func (r *response) prop() props {
+	var p props
+	for _, ps := range r.Propstats {
+		if ps.Status != "" && !strings.Contains(ps.Status, " 2") {
+			continue
+		}
+		if ps.Prop.ETag != "" {
+			p.ETag = ps.Prop.ETag
+		}
+		if ps.Prop.ResourceType.Collection != nil {
+			p.ResourceType = ps.Prop.ResourceType
+		}
+		if ps.Prop.CTag != "" {
+			p.CTag = ps.Prop.CTag
+		}
+		if ps.Prop.CalendarData != "" {
+			p.CalendarData = ps.Prop.CalendarData
+		}
+		if ps.Prop.AddressData != "" {
+			p.AddressData = ps.Prop.AddressData
+		}
+	}
+	return p
+}
+
+// etag returns the response's ETag without quotes, as go-webdav does.
+func (r *response) etag() string {
+	etag := strings.TrimSpace(r.prop().ETag)
+	if unquoted, err := strconv.Unquote(etag); err == nil {
+		return unquoted
+	}
+	return etag
+}
+
+// hrefPath returns the path of an href, which servers may send as a full
+// URL. Escaping is kept so that the href can be sent back.
+func hrefPath(href string) string {
+	href = strings.TrimSpace(href)
+	if u, err := url.Parse(href); err == nil && u.Scheme != "" {
+		return u.EscapedPath()
+	}
+	return href
+}
+
+// isCollection reports whether href is the collection at path.
+func (s *Syncer) isCollection(href, path string) bool {
+	target := hrefPath(path)
+	if base, err := url.Parse(s.BaseURL); err == nil {
+		if ref, err := url.Parse(path); err == nil {
+			target = base.ResolveReference(ref).EscapedPath()
+		}
+	}
+	return strings.TrimSuffix(href, "/") == strings.TrimSuffix(target, "/")
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
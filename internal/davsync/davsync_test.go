@@ -0,0 +1,250 @@
+package davsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCollection is a calendar collection at /cal/ whose changes are
+// numbered, so that a sync token is the number of the last change seen.
+type fakeCollection struct {
+	sync     bool // Supports sync-collection
+	version  int
+	objects  map[string]int // href to the version that last changed it
+	deleted  map[string]int // href to the version that deleted it
+	minToken int            // Older tokens are rejected
+	multiget []string       // hrefs requested with multiget
+	status   int            // If set, the status of every response
+}
+
+func newFakeCollection(sync bool) *fakeCollection {
+	return &fakeCollection{sync: sync, objects: map[string]int{}, deleted: map[string]int{}}
+}
+
+func (f *fakeCollection) put(name string) {
+	f.version++
+	f.objects["/cal/"+name] = f.version
+	delete(f.deleted, "/cal/"+name)
+}
+
+func (f *fakeCollection) remove(name string) {
+	f.version++
+	delete(f.objects, "/cal/"+name)
+	f.deleted["/cal/"+name] = f.version
+}
+
+var (
+	tokenPattern = regexp.MustCompile(`<D:sync-token>([^<]*)</D:sync-token>`)
+	hrefPattern  = regexp.MustCompile(`<D:href>([^<]*)</D:href>`)
+)
+
+func (f *fakeCollection) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	multistatus := func(responses string) {
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:CS="http://calendarserver.org/ns/">%s</D:multistatus>`, responses)
+	}
+	member := func(href string, version int, extra string) string {
+		return fmt.Sprintf(`<D:response><D:href>%s</D:href><D:propstat><D:prop><D:getetag>"%d"</D:getetag>%s</D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`, href, version, extra)
+	}
+
+	switch {
+	case f.status != 0:
+		w.WriteHeader(f.status)
+	case r.Method == "REPORT" && strings.Contains(string(body), "sync-collection"):
+		if !f.sync {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		since := 0
+		if m := tokenPattern.FindStringSubmatch(string(body)); m != nil && m[1] != "" {
+			fmt.Sscanf(m[1], "token-%d", &since)
+			if since < f.minToken {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+		var out strings.Builder
+		for href, v := range f.objects {
+			if v > since {
+				out.WriteString(member(href, v, ""))
+			}
+		}
+		for href, v := range f.deleted {
+			if v > since && since > 0 {
+				fmt.Fprintf(&out, `<D:response><D:href>%s</D:href><D:status>HTTP/1.1 404 Not Found</D:status></D:response>`, href)
+			}
+		}
+		fmt.Fprintf(&out, `<D:sync-token>token-%d</D:sync-token>`, f.version)
+		multistatus(out.String())
+	case r.Method == "REPORT" && strings.Contains(string(body), "calendar-multiget"):
+		var out strings.Builder
+		for _, m := range hrefPattern.FindAllStringSubmatch(string(body), -1) {
+			href := html.UnescapeString(m[1])
+			f.multiget = append(f.multiget, href)
+			if v, ok := f.objects[href]; ok {
+				out.WriteString(member(href, v, fmt.Sprintf(`<C:calendar-data>BEGIN:VCALENDAR&#13;
+UID:%s-%d&#13;
+END:VCALENDAR&#13;
+</C:calendar-data>`, href, v)))
+			}
+		}
+		multistatus(out.String())
+	case r.Method == "PROPFIND" && r.Header.Get("Depth") == "0":
+		multistatus(fmt.Sprintf(`<D:response><D:href>/cal/</D:href><D:propstat><D:prop><CS:getctag>c%d</CS:getctag></D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`, f.version))
+	case r.Method == "PROPFIND":
+		out := `<D:response><D:href>/cal/</D:href><D:propstat><D:prop><D:resourcetype><D:collection/></D:resourcetype></D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`
+		for href, v := range f.objects {
+			out += member(href, v, "")
+		}
+		multistatus(out)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func newTestSyncer(t *testing.T, f *fakeCollection) *Syncer {
+	t.Helper()
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return &Syncer{HTTP: http.DefaultClient, BaseURL: server.URL + "/", Kind: CalDAV}
+}
+
+func TestSyncCollection(t *testing.T) {
+	f := newFakeCollection(true)
+	f.put("a.ics")
+	f.put("b.ics")
+	syncer := newTestSyncer(t, f)
+	col := &Collection{Path: "/cal/"}
+
+	result, err := syncer.Sync(context.Background(), col)
+	require.NoError(t, err)
+	assert.Equal(t, MethodSyncCollection, result.Method)
+	assert.Equal(t, 2, result.Added)
+	assert.Equal(t, "token-2", col.SyncToken)
+	assert.Contains(t, col.Objects["/cal/a.ics"].Data, "UID:/cal/a.ics-1")
+	assert.Equal(t, "1", col.Objects["/cal/a.ics"].ETag)
+
+	// Only the changes are fetched
+	f.multiget = nil
+	f.put("b.ics")
+	f.put("c.ics")
+	f.remove("a.ics")
+	result, err = syncer.Sync(context.Background(), col)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Path: "/cal/", Method: MethodSyncCollection, Added: 1, Updated: 1, Deleted: 1, Total: 2}, *result)
+	assert.ElementsMatch(t, []string{"/cal/b.ics", "/cal/c.ics"}, f.multiget)
+	assert.Equal(t, []string{"/cal/b.ics", "/cal/c.ics"}, col.Hrefs())
+
+	// Nothing changed
+	f.multiget = nil
+	result, err = syncer.Sync(context.Background(), col)
+	require.NoError(t, err)
+	assert.False(t, result.Changed())
+	assert.Empty(t, f.multiget)
+
+	// An expired token starts over, dropping what is gone
+	f.remove("c.ics")
+	f.minToken = f.version + 1
+	col.SyncToken = "token-1"
+	result, err = syncer.Sync(context.Background(), col)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Deleted)
+	assert.Equal(t, []string{"/cal/b.ics"}, col.Hrefs())
+	assert.Equal(t, fmt.Sprintf("token-%d", f.version), col.SyncToken)
+}
+
+func TestSyncErrors(t *testing.T) {
+	f := newFakeCollection(true)
+	f.put("a.ics")
+	syncer := newTestSyncer(t, f)
+	col := &Collection{Path: "/cal/"}
+	_, err := syncer.Sync(context.Background(), col)
+	require.NoError(t, err)
+	token := col.SyncToken
+
+	// Failures keep sync-collection and the token for the next time
+	for _, code := range []int{http.StatusUnauthorized, http.StatusServiceUnavailable} {
+		f.status = code
+		_, err = syncer.Sync(context.Background(), col)
+		assert.Error(t, err)
+		assert.False(t, col.NoSyncCollection)
+		assert.Equal(t, token, col.SyncToken)
+	}
+	f.status = 0
+	f.put("b.ics")
+	result, err := syncer.Sync(context.Background(), col)
+	require.NoError(t, err)
+	assert.Equal(t, MethodSyncCollection, result.Method)
+	assert.Equal(t, []string{"/cal/a.ics", "/cal/b.ics"}, col.Hrefs())
+}
+
+func TestSyncETags(t *testing.T) {
+	f := newFakeCollection(false)
+	f.put("a.ics")
+	f.put("b.ics")
+	syncer := newTestSyncer(t, f)
+	col := &Collection{Path: "/cal/"}
+
+	result, err := syncer.Sync(context.Background(), col)
+	require.NoError(t, err)
+	assert.Equal(t, MethodCTag, result.Method)
+	assert.Equal(t, 2, result.Added)
+	assert.True(t, col.NoSyncCollection)
+	assert.Equal(t, "c2", col.CTag)
+
+	// The ctag is unchanged: no listing, no downloads
+	f.multiget = nil
+	result, err = syncer.Sync(context.Background(), col)
+	require.NoError(t, err)
+	assert.False(t, result.Changed())
+	assert.Empty(t, f.multiget)
+
+	f.put("a.ics")
+	f.remove("b.ics")
+	result, err = syncer.Sync(context.Background(), col)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, 1, result.Deleted)
+	assert.Equal(t, []string{"/cal/a.ics"}, f.multiget)
+	assert.Contains(t, col.Objects["/cal/a.ics"].Data, "UID:/cal/a.ics-3")
+}
+
+func TestStore(t *testing.T) {
+	store := &Store{Dir: t.TempDir()}
+	_, err := store.Load("cal", "/cal/")
+	assert.True(t, errors.Is(err, ErrNotCached))
+
+	col := &Collection{Path: "/cal/", SyncToken: "token-1", Objects: map[string]Object{
+		"/cal/a.ics": {ETag: `"1"`, Data: "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"},
+	}}
+	require.NoError(t, store.Save("cal", col))
+	loaded, err := store.Load("cal", "/cal/")
+	require.NoError(t, err)
+	assert.Equal(t, col.Objects, loaded.Objects)
+	assert.Equal(t, "token-1", loaded.SyncToken)
+
+	// Kinds and paths are kept apart
+	_, err = store.Load("contacts", "/cal/")
+	assert.True(t, errors.Is(err, ErrNotCached))
+	_, err = store.Load("cal", "/cal2/")
+	assert.True(t, errors.Is(err, ErrNotCached))
+
+	require.NoError(t, store.Remove("cal", "/cal/"))
+	_, err = store.Load("cal", "/cal/")
+	assert.True(t, errors.Is(err, ErrNotCached))
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/emersion/go-imap/v2"
 	"github.com/emersion/go-imap/v2/imapclient"
+	"github.com/visionik/sogcli/internal/dateexpr"
 )
 
 // Client wraps an IMAP connection.
@@ -92,7 +93,8 @@ type Message struct {
 	UID     uint32
 	Subject string
 	From    string
-	To      string
+	To      string // Comma-separated addresses
+	Cc      string // Comma-separated addresses
 	Date    string
 	Seen    bool
 	Body    string
@@ -217,9 +219,8 @@ func (c *Client) GetMessage(folder string, uid uint32, headersOnly bool) (*Messa
 		if len(buf.Envelope.From) > 0 {
 			m.From = buf.Envelope.From[0].Addr()
 		}
-		if len(buf.Envelope.To) > 0 {
-			m.To = buf.Envelope.To[0].Addr()
-		}
+		m.To = joinAddresses(buf.Envelope.To)
+		m.Cc = joinAddresses(buf.Envelope.Cc)
 	}
 
 	// Extract body
@@ -234,6 +235,17 @@ func (c *Client) GetMessage(folder string, uid uint32, headersOnly bool) (*Messa
 	return m, nil
 }
 
+// joinAddresses formats envelope addresses as a comma-separated list.
+func joinAddresses(addrs []imap.Address) string {
+	list := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if addr := a.Addr(); addr != "" {
+			list = append(list, addr)
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
 // SearchMessages searches for messages matching the query.
 // Query format: IMAP-style search terms (FROM, TO, SUBJECT, SINCE, BEFORE, TEXT, etc.)
 // Examples:
@@ -338,6 +350,94 @@ func (c *Client) SearchMessages(folder, query string, max int) ([]Message, error
 	return messages, nil
 }
 
+// ListCalendarMessages returns the messages among the last max in a
+// folder that carry a text/calendar or application/ics part, such as
+// meeting invitations (iMIP). Body holds the full RFC822 message and From
+// the sender's address.
+func (c *Client) ListCalendarMessages(folder string, max int) ([]Message, error) {
+	selectData, err := c.client.Select(folder, nil).Wait()
+	if err != nil {
+		return nil, fmt.Errorf("failed to select folder: %w", err)
+	}
+	if selectData.NumMessages == 0 {
+		return nil, nil
+	}
+
+	start := uint32(1)
+	if selectData.NumMessages > uint32(max) {
+		start = selectData.NumMessages - uint32(max) + 1
+	}
+	seqSet := imap.SeqSet{}
+	seqSet.AddRange(start, selectData.NumMessages)
+
+	// Find the messages with calendar parts from their structure, so only
+	// those are downloaded
+	structures, err := c.client.Fetch(seqSet, &imap.FetchOptions{
+		UID:           true,
+		BodyStructure: &imap.FetchItemBodyStructure{},
+	}).Collect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch: %w", err)
+	}
+	uidSet := imap.UIDSet{}
+	for _, buf := range structures {
+		if buf.BodyStructure != nil && hasCalendarPart(buf.BodyStructure) {
+			uidSet.AddNum(buf.UID)
+		}
+	}
+	if len(uidSet) == 0 {
+		return nil, nil
+	}
+
+	bufs, err := c.client.Fetch(uidSet, &imap.FetchOptions{
+		Flags:       true,
+		Envelope:    true,
+		UID:         true,
+		BodySection: []*imap.FetchItemBodySection{{Peek: true}},
+	}).Collect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch: %w", err)
+	}
+
+	messages := make([]Message, 0, len(bufs))
+	for _, buf := range bufs {
+		m := Message{UID: uint32(buf.UID)}
+		if buf.Envelope != nil {
+			m.Subject = buf.Envelope.Subject
+			m.Date = buf.Envelope.Date.String()
+			if len(buf.Envelope.From) > 0 {
+				m.From = buf.Envelope.From[0].Addr()
+			}
+			m.To = joinAddresses(buf.Envelope.To)
+			m.Cc = joinAddresses(buf.Envelope.Cc)
+		}
+		for _, f := range buf.Flags {
+			if f == imap.FlagSeen {
+				m.Seen = true
+				break
+			}
+		}
+		if len(buf.BodySection) > 0 {
+			m.Body = string(buf.BodySection[0].Bytes)
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// hasCalendarPart reports whether a body structure contains iCalendar data.
+func hasCalendarPart(bs imap.BodyStructure) bool {
+	found := false
+	bs.Walk(func(path []int, part imap.BodyStructure) bool {
+		switch strings.ToLower(part.MediaType()) {
+		case "text/calendar", "application/ics":
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
 // parseSearchQuery parses a simple search query into IMAP search criteria.
 // Returns nil criteria for "ALL" to indicate list-all fallback.
 func parseSearchQuery(query string) (*imap.SearchCriteria, error) {
@@ -345,15 +445,15 @@ func parseSearchQuery(query string) (*imap.SearchCriteria, error) {
 	if strings.ToUpper(strings.TrimSpace(query)) == "ALL" {
 		return nil, nil
 	}
-	
+
 	criteria := &imap.SearchCriteria{}
-	
+
 	// Simple parser: look for known keywords
 	tokens := strings.Fields(query)
-	
+
 	for i := 0; i < len(tokens); i++ {
 		keyword := strings.ToUpper(tokens[i])
-		
+
 		switch keyword {
 		case "FROM":
 			if i+1 < len(tokens) {
@@ -392,48 +492,51 @@ func parseSearchQuery(query string) (*imap.SearchCriteria, error) {
 			criteria.Flag = append(criteria.Flag, imap.FlagFlagged)
 		case "SINCE":
 			if i+1 < len(tokens) {
-				i++
-				t, err := parseDate(tokens[i])
+				t, n, err := searchDate(tokens[i+1:])
 				if err == nil {
 					criteria.Since = t
 				}
+				i += n
 			}
 		case "BEFORE":
 			if i+1 < len(tokens) {
-				i++
-				t, err := parseDate(tokens[i])
+				t, n, err := searchDate(tokens[i+1:])
 				if err == nil {
 					criteria.Before = t
 				}
+				i += n
 			}
 		default:
 			// Treat as text search
 			criteria.Text = append(criteria.Text, tokens[i])
 		}
 	}
-	
+
 	return criteria, nil
 }
 
-// parseDate parses common date formats.
-func parseDate(s string) (time.Time, error) {
-	formats := []string{
-		"2-Jan-2006",
-		"02-Jan-2006",
-		"2006-01-02",
-		"01/02/2006",
-		"1/2/2006",
-	}
-	
-	for _, format := range formats {
-		if t, err := time.Parse(format, s); err == nil {
-			return t, nil
+// searchDate parses the date after SINCE or BEFORE, which may span a few
+// words ("last friday", "3 days ago"). It returns the number of tokens
+// used, at least one.
+func searchDate(tokens []string) (time.Time, int, error) {
+	for n := min(3, len(tokens)); n > 1; n-- {
+		if t, err := parseDate(strings.Join(tokens[:n], " ")); err == nil {
+			return t, n, nil
 		}
 	}
-	
-	return time.Time{}, fmt.Errorf("unable to parse date: %s", s)
+	t, err := parseDate(tokens[0])
+	return t, 1, err
 }
 
+// parseDate parses a date expression like "1-Jan-2026", "2026-01-15",
+// "yesterday" or "-2w" (see dateexpr.Parse).
+func parseDate(s string) (time.Time, error) {
+	t, err := dateexpr.Date(s, time.Now())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse date: %s", s)
+	}
+	return t, nil
+}
 
 // MoveMessage moves a message to a different folder.
 func (c *Client) MoveMessage(srcFolder string, uid uint32, dstFolder string) error {
@@ -565,7 +668,6 @@ func (c *Client) RenameFolder(oldName, newName string) error {
 	return nil
 }
 
-
 // SaveDraft saves a message to the Drafts folder.
 func (c *Client) SaveDraft(msg *Message) (uint32, error) {
 	// Build RFC822 message
@@ -3,6 +3,7 @@ package imap
 import (
 	"testing"
 
+	"github.com/emersion/go-imap/v2"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -76,3 +77,28 @@ func TestMessageUnread(t *testing.T) {
 
 	assert.False(t, msg.Seen)
 }
+
+func TestHasCalendarPart(t *testing.T) {
+	invite := &imap.BodyStructureMultiPart{
+		Subtype: "mixed",
+		Children: []imap.BodyStructure{
+			&imap.BodyStructureMultiPart{
+				Subtype: "alternative",
+				Children: []imap.BodyStructure{
+					&imap.BodyStructureSinglePart{Type: "text", Subtype: "plain"},
+					&imap.BodyStructureSinglePart{Type: "TEXT", Subtype: "CALENDAR"},
+				},
+			},
+		},
+	}
+	assert.True(t, hasCalendarPart(invite))
+
+	attachment := &imap.BodyStructureSinglePart{Type: "application", Subtype: "ics"}
+	assert.True(t, hasCalendarPart(attachment))
+
+	plain := &imap.BodyStructureMultiPart{
+		Subtype:  "alternative",
+		Children: []imap.BodyStructure{&imap.BodyStructureSinglePart{Type: "text", Subtype: "plain"}},
+	}
+	assert.False(t, hasCalendarPart(plain))
+}
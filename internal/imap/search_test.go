@@ -106,6 +106,17 @@ func TestParseSearchQuerySINCE(t *testing.T) {
 	assert.Equal(t, 1, criteria.Since.Day())
 }
 
+func TestParseSearchQueryRelativeDate(t *testing.T) {
+	criteria, err := parseSearchQuery("SINCE 3 days ago FROM viz")
+	require.NoError(t, err)
+	require.NotNil(t, criteria)
+	want := time.Now().AddDate(0, 0, -3)
+	assert.Equal(t, want.Day(), criteria.Since.Day())
+	require.Len(t, criteria.Header, 1)
+	assert.Equal(t, "viz", criteria.Header[0].Value)
+	assert.Empty(t, criteria.Text)
+}
+
 func TestParseSearchQueryBEFORE(t *testing.T) {
 	criteria, err := parseSearchQuery("BEFORE 31-Dec-2025")
 	require.NoError(t, err)
@@ -10,17 +10,22 @@ import (
 	"time"
 
 	ical "github.com/emersion/go-ical"
+	"github.com/teambition/rrule-go"
+	"github.com/visionik/sogcli/internal/timezone"
 )
 
 // Method represents an iTIP method.
 type Method string
 
 const (
-	MethodRequest Method = "REQUEST" // Invite attendees
-	MethodReply   Method = "REPLY"   // Respond to invite
-	MethodCancel  Method = "CANCEL"  // Cancel meeting
-	MethodCounter Method = "COUNTER" // Propose different time
-	MethodRefresh Method = "REFRESH" // Request updated info
+	MethodPublish        Method = "PUBLISH"        // Publish an event without attendees
+	MethodRequest        Method = "REQUEST"        // Invite attendees or update a meeting
+	MethodReply          Method = "REPLY"          // Respond to invite
+	MethodAdd            Method = "ADD"            // Add occurrences to a recurring meeting
+	MethodCancel         Method = "CANCEL"         // Cancel meeting
+	MethodRefresh        Method = "REFRESH"        // Request updated info
+	MethodCounter        Method = "COUNTER"        // Propose different time
+	MethodDeclineCounter Method = "DECLINECOUNTER" // Reject a counter-proposal
 )
 
 // ParticipantStatus represents attendee participation status.
@@ -33,7 +38,8 @@ const (
 	StatusTentative   ParticipantStatus = "TENTATIVE"
 )
 
-// Invite represents a meeting invitation.
+// Invite represents a meeting invitation, or more generally the event in
+// an iTIP message.
 type Invite struct {
 	Method      Method
 	UID         string
@@ -47,6 +53,45 @@ type Invite struct {
 	Sequence    int
 	Created     time.Time
 	LastMod     time.Time
+	RRule       string      // Recurrence rule of a recurring meeting
+	RDates      []time.Time // Occurrences added to the rule
+	ExDates     []time.Time // Occurrences taken out of the rule
+	Occurrence  time.Time   // RECURRENCE-ID: the occurrence an override changes
+	Status      string      // STATUS, e.g. CANCELLED
+	Comment     string      // COMMENT, e.g. the reason for a counter-proposal
+	Overrides   []Invite    // The message's other VEVENTs: changed occurrences
+}
+
+// Over returns when the meeting is over: the end of its last occurrence,
+// including RDates and occurrences moved by Overrides. It is zero if the meeting
+// recurs without end or its rule can't be read.
+func (inv *Invite) Over() time.Time {
+	over := inv.End
+	if inv.RRule != "" {
+		opt, err := rrule.StrToROptionInLocation(inv.RRule, inv.Start.Location())
+		if err != nil || (opt.Count == 0 && opt.Until.IsZero()) {
+			return time.Time{}
+		}
+		opt.Dtstart = inv.Start
+		rule, err := rrule.NewRRule(*opt)
+		if err != nil {
+			return time.Time{}
+		}
+		if all := rule.All(); len(all) > 0 {
+			over = all[len(all)-1].Add(inv.End.Sub(inv.Start))
+		}
+	}
+	for _, t := range inv.RDates {
+		if end := t.Add(inv.End.Sub(inv.Start)); end.After(over) {
+			over = end
+		}
+	}
+	for _, o := range inv.Overrides {
+		if o.End.After(over) {
+			over = o.End
+		}
+	}
+	return over
 }
 
 // Participant represents an organizer or attendee.
@@ -67,92 +112,171 @@ type Response struct {
 	Sequence  int
 }
 
-// CreateInvite creates an iTIP REQUEST for a new meeting.
+// CreateInvite creates an iTIP REQUEST for a new meeting, or an update of
+// one (with a higher Sequence). Attendees without a Status are asked to
+// reply.
 func CreateInvite(inv *Invite) ([]byte, error) {
+	return createMessage(MethodRequest, inv, true)
+}
+
+// CreatePublish creates an iTIP PUBLISH: the event for others to put on
+// their calendars, without attendees or replies.
+func CreatePublish(inv *Invite) ([]byte, error) {
+	return createMessage(MethodPublish, inv, false)
+}
+
+// CreateAdd creates an iTIP ADD, adding the occurrences in inv and its
+// Overrides to a recurring meeting the attendees already have.
+func CreateAdd(inv *Invite) ([]byte, error) {
+	return createMessage(MethodAdd, inv, true)
+}
+
+// CreateCounter creates an iTIP COUNTER: an attendee's proposal to change
+// a meeting, sent to the organizer. inv is the meeting as the attendee
+// wants it, with the attendee as the only entry in Attendees and the
+// invitation's Sequence.
+func CreateCounter(inv *Invite) ([]byte, error) {
+	if len(inv.Attendees) == 0 {
+		return nil, fmt.Errorf("counter-proposal needs the proposing attendee")
+	}
+	return createMessage(MethodCounter, inv, true)
+}
+
+// createMessage creates an iTIP message with the events in inv.
+func createMessage(method Method, inv *Invite, attendees bool) ([]byte, error) {
+	cal := newMessage(method)
+	now := time.Now().UTC()
+	cal.Children = append(cal.Children, eventComponent(inv, attendees, now))
+	for i := range inv.Overrides {
+		cal.Children = append(cal.Children, eventComponent(&inv.Overrides[i], attendees, now))
+	}
+	return encode(cal, strings.ToLower(string(method)))
+}
+
+// newMessage starts an iTIP message.
+func newMessage(method Method) *ical.Calendar {
 	cal := ical.NewCalendar()
 	cal.Props.SetText(ical.PropVersion, "2.0")
 	cal.Props.SetText(ical.PropProductID, "-//sog//sogcli//EN")
-	cal.Props.SetText(ical.PropMethod, string(MethodRequest))
+	cal.Props.SetText(ical.PropMethod, string(method))
+	return cal
+}
 
+// encode encodes an iTIP message with the time zones it uses.
+func encode(cal *ical.Calendar, what string) ([]byte, error) {
+	timezone.Embed(cal)
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", what, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// eventComponent builds the VEVENT for inv.
+func eventComponent(inv *Invite, attendees bool, now time.Time) *ical.Component {
 	event := ical.NewComponent(ical.CompEvent)
 	event.Props.SetText(ical.PropUID, inv.UID)
-	event.Props.SetText(ical.PropSummary, inv.Summary)
-	event.Props.SetDateTime(ical.PropDateTimeStart, inv.Start)
-	event.Props.SetDateTime(ical.PropDateTimeEnd, inv.End)
-	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
-	event.Props.SetDateTime(ical.PropCreated, inv.Created)
-	seqProp := ical.NewProp(ical.PropSequence)
-	seqProp.Value = fmt.Sprintf("%d", inv.Sequence)
-	event.Props.Set(seqProp)
-
+	event.Props.SetDateTime(ical.PropDateTimeStamp, now)
+	setSequence(event, inv.Sequence)
+	if !inv.Occurrence.IsZero() {
+		rid := ical.NewProp(ical.PropRecurrenceID)
+		timezone.SetTime(rid, inv.Occurrence)
+		event.Props.Set(rid)
+	}
+	if inv.Summary != "" {
+		event.Props.SetText(ical.PropSummary, inv.Summary)
+	}
+	if !inv.Start.IsZero() {
+		startProp := ical.NewProp(ical.PropDateTimeStart)
+		timezone.SetTime(startProp, inv.Start)
+		event.Props.Set(startProp)
+	}
+	if !inv.End.IsZero() {
+		endProp := ical.NewProp(ical.PropDateTimeEnd)
+		timezone.SetTime(endProp, inv.End.In(inv.Start.Location()))
+		event.Props.Set(endProp)
+	}
+	if !inv.Created.IsZero() {
+		event.Props.SetDateTime(ical.PropCreated, inv.Created.UTC())
+	}
+	if inv.RRule != "" {
+		rule := ical.NewProp(ical.PropRecurrenceRule)
+		rule.Value = inv.RRule
+		event.Props.Set(rule)
+	}
+	for _, t := range inv.RDates {
+		prop := ical.NewProp(ical.PropRecurrenceDates)
+		timezone.SetTime(prop, t.In(inv.Start.Location()))
+		event.Props.Add(prop)
+	}
+	for _, t := range inv.ExDates {
+		prop := ical.NewProp(ical.PropExceptionDates)
+		timezone.SetTime(prop, t.In(inv.Start.Location()))
+		event.Props.Add(prop)
+	}
 	if inv.Description != "" {
 		event.Props.SetText(ical.PropDescription, inv.Description)
 	}
 	if inv.Location != "" {
 		event.Props.SetText(ical.PropLocation, inv.Location)
 	}
-
-	// Organizer
-	orgProp := ical.NewProp(ical.PropOrganizer)
-	orgProp.Value = "mailto:" + inv.Organizer.Email
-	if inv.Organizer.Name != "" {
-		orgProp.Params.Set(ical.ParamCommonName, inv.Organizer.Name)
+	if inv.Status != "" {
+		status := ical.NewProp(ical.PropStatus)
+		status.Value = inv.Status
+		event.Props.Set(status)
+	}
+	if inv.Comment != "" {
+		event.Props.SetText(ical.PropComment, inv.Comment)
 	}
-	event.Props.Set(orgProp)
 
-	// Attendees
+	event.Props.Set(participantProp(ical.PropOrganizer, inv.Organizer))
+	if !attendees {
+		return event
+	}
 	for _, att := range inv.Attendees {
-		attProp := ical.NewProp(ical.PropAttendee)
-		attProp.Value = "mailto:" + att.Email
-		if att.Name != "" {
-			attProp.Params.Set(ical.ParamCommonName, att.Name)
+		attProp := participantProp(ical.PropAttendee, att)
+		status := att.Status
+		if status == "" {
+			status = StatusNeedsAction
 		}
-		attProp.Params.Set(ical.ParamParticipationStatus, string(StatusNeedsAction))
+		attProp.Params.Set(ical.ParamParticipationStatus, string(status))
 		if att.RSVP {
 			attProp.Params.Set(ical.ParamRSVP, "TRUE")
 		}
 		attProp.Params.Set("ROLE", "REQ-PARTICIPANT")
-		event.Props.Set(attProp)
+		event.Props.Add(attProp)
 	}
+	return event
+}
 
-	cal.Children = append(cal.Children, event)
-
-	var buf bytes.Buffer
-	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
-		return nil, fmt.Errorf("failed to encode invite: %w", err)
+// participantProp builds an ORGANIZER or ATTENDEE property.
+func participantProp(name string, p Participant) *ical.Prop {
+	prop := ical.NewProp(name)
+	prop.Value = "mailto:" + p.Email
+	if p.Name != "" {
+		prop.Params.Set(ical.ParamCommonName, p.Name)
 	}
+	return prop
+}
 
-	return buf.Bytes(), nil
+func setSequence(event *ical.Component, sequence int) {
+	seqProp := ical.NewProp(ical.PropSequence)
+	seqProp.Value = fmt.Sprintf("%d", sequence)
+	event.Props.Set(seqProp)
 }
 
 // CreateReply creates an iTIP REPLY for responding to an invitation.
 func CreateReply(resp *Response) ([]byte, error) {
-	cal := ical.NewCalendar()
-	cal.Props.SetText(ical.PropVersion, "2.0")
-	cal.Props.SetText(ical.PropProductID, "-//sog//sogcli//EN")
-	cal.Props.SetText(ical.PropMethod, string(MethodReply))
+	cal := newMessage(MethodReply)
 
 	event := ical.NewComponent(ical.CompEvent)
 	event.Props.SetText(ical.PropUID, resp.UID)
 	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
-	seqProp := ical.NewProp(ical.PropSequence)
-	seqProp.Value = fmt.Sprintf("%d", resp.Sequence)
-	event.Props.Set(seqProp)
-
-	// Organizer
-	orgProp := ical.NewProp(ical.PropOrganizer)
-	orgProp.Value = "mailto:" + resp.Organizer.Email
-	if resp.Organizer.Name != "" {
-		orgProp.Params.Set(ical.ParamCommonName, resp.Organizer.Name)
-	}
-	event.Props.Set(orgProp)
+	setSequence(event, resp.Sequence)
+	event.Props.Set(participantProp(ical.PropOrganizer, resp.Organizer))
 
 	// Attendee (the responder)
-	attProp := ical.NewProp(ical.PropAttendee)
-	attProp.Value = "mailto:" + resp.Attendee.Email
-	if resp.Attendee.Name != "" {
-		attProp.Params.Set(ical.ParamCommonName, resp.Attendee.Name)
-	}
+	attProp := participantProp(ical.PropAttendee, resp.Attendee)
 	attProp.Params.Set(ical.ParamParticipationStatus, string(resp.Status))
 	event.Props.Set(attProp)
 
@@ -161,61 +285,70 @@ func CreateReply(resp *Response) ([]byte, error) {
 	}
 
 	cal.Children = append(cal.Children, event)
+	return encode(cal, "reply")
+}
 
-	var buf bytes.Buffer
-	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
-		return nil, fmt.Errorf("failed to encode reply: %w", err)
+// CreateDeclineCounter creates an iTIP DECLINECOUNTER, the organizer's
+// rejection of an attendee's counter-proposal. resp.Attendee is the
+// attendee who proposed; Status is not used.
+func CreateDeclineCounter(resp *Response) ([]byte, error) {
+	cal := newMessage(MethodDeclineCounter)
+
+	event := ical.NewComponent(ical.CompEvent)
+	event.Props.SetText(ical.PropUID, resp.UID)
+	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	setSequence(event, resp.Sequence)
+	event.Props.Set(participantProp(ical.PropOrganizer, resp.Organizer))
+	event.Props.Set(participantProp(ical.PropAttendee, resp.Attendee))
+	if resp.Comment != "" {
+		event.Props.SetText(ical.PropComment, resp.Comment)
 	}
 
-	return buf.Bytes(), nil
+	cal.Children = append(cal.Children, event)
+	return encode(cal, "decline-counter")
+}
+
+// CreateRefresh creates an iTIP REFRESH: an attendee's request for the
+// latest version of a meeting.
+func CreateRefresh(uid string, organizer, attendee Participant) ([]byte, error) {
+	cal := newMessage(MethodRefresh)
+
+	event := ical.NewComponent(ical.CompEvent)
+	event.Props.SetText(ical.PropUID, uid)
+	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	event.Props.Set(participantProp(ical.PropOrganizer, organizer))
+	event.Props.Set(participantProp(ical.PropAttendee, attendee))
+
+	cal.Children = append(cal.Children, event)
+	return encode(cal, "refresh")
 }
 
 // CreateCancel creates an iTIP CANCEL to cancel a meeting.
 func CreateCancel(uid string, organizer Participant, attendees []Participant, sequence int) ([]byte, error) {
-	cal := ical.NewCalendar()
-	cal.Props.SetText(ical.PropVersion, "2.0")
-	cal.Props.SetText(ical.PropProductID, "-//sog//sogcli//EN")
-	cal.Props.SetText(ical.PropMethod, string(MethodCancel))
+	cal := newMessage(MethodCancel)
 
 	event := ical.NewComponent(ical.CompEvent)
 	event.Props.SetText(ical.PropUID, uid)
 	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
-	seqProp := ical.NewProp(ical.PropSequence)
-	seqProp.Value = fmt.Sprintf("%d", sequence)
-	event.Props.Set(seqProp)
+	setSequence(event, sequence)
 	statusProp := ical.NewProp(ical.PropStatus)
 	statusProp.Value = "CANCELLED"
 	event.Props.Set(statusProp)
-
-	// Organizer
-	orgProp := ical.NewProp(ical.PropOrganizer)
-	orgProp.Value = "mailto:" + organizer.Email
-	if organizer.Name != "" {
-		orgProp.Params.Set(ical.ParamCommonName, organizer.Name)
-	}
-	event.Props.Set(orgProp)
+	event.Props.Set(participantProp(ical.PropOrganizer, organizer))
 
 	// Attendees
 	for _, att := range attendees {
-		attProp := ical.NewProp(ical.PropAttendee)
-		attProp.Value = "mailto:" + att.Email
-		if att.Name != "" {
-			attProp.Params.Set(ical.ParamCommonName, att.Name)
-		}
-		event.Props.Set(attProp)
+		event.Props.Add(participantProp(ical.PropAttendee, att))
 	}
 
 	cal.Children = append(cal.Children, event)
-
-	var buf bytes.Buffer
-	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
-		return nil, fmt.Errorf("failed to encode cancel: %w", err)
-	}
-
-	return buf.Bytes(), nil
+	return encode(cal, "cancel")
 }
 
-// ParseInvite parses an iCalendar blob and extracts invite information.
+// ParseInvite parses an iTIP message of any method. The Invite describes
+// the message's main VEVENT: the series, or the first event if the message
+// only changes single occurrences. Its other VEVENTs, such as the
+// overrides of a recurring meeting, are in Overrides.
 func ParseInvite(data []byte) (*Invite, error) {
 	dec := ical.NewDecoder(bytes.NewReader(data))
 	cal, err := dec.Decode()
@@ -223,66 +356,126 @@ func ParseInvite(data []byte) (*Invite, error) {
 		return nil, fmt.Errorf("failed to decode iCalendar: %w", err)
 	}
 
-	inv := &Invite{}
-
-	// Get method
+	timezone.Register(cal)
+	var method Method
 	if prop := cal.Props.Get(ical.PropMethod); prop != nil {
-		inv.Method = Method(prop.Value)
+		method = Method(strings.ToUpper(prop.Value))
 	}
 
-	// Find VEVENT
+	var events []Invite
+	main := -1
 	for _, child := range cal.Children {
 		if child.Name != ical.CompEvent {
 			continue
 		}
-
-		if prop := child.Props.Get(ical.PropUID); prop != nil {
-			inv.UID = prop.Value
-		}
-		if prop := child.Props.Get(ical.PropSummary); prop != nil {
-			inv.Summary = prop.Value
-		}
-		if prop := child.Props.Get(ical.PropDescription); prop != nil {
-			inv.Description = prop.Value
-		}
-		if prop := child.Props.Get(ical.PropLocation); prop != nil {
-			inv.Location = prop.Value
-		}
-		if prop := child.Props.Get(ical.PropSequence); prop != nil {
-			fmt.Sscanf(prop.Value, "%d", &inv.Sequence)
+		inv := parseEvent(child)
+		inv.Method = method
+		if main < 0 && inv.Occurrence.IsZero() {
+			main = len(events)
 		}
+		events = append(events, inv)
+	}
+	if len(events) == 0 {
+		return &Invite{Method: method}, nil
+	}
+	if main < 0 {
+		main = 0
+	}
 
-		// Parse times
-		if prop := child.Props.Get(ical.PropDateTimeStart); prop != nil {
-			if t, err := prop.DateTime(time.UTC); err == nil {
-				inv.Start = t
-			}
-		}
-		if prop := child.Props.Get(ical.PropDateTimeEnd); prop != nil {
-			if t, err := prop.DateTime(time.UTC); err == nil {
-				inv.End = t
-			}
+	inv := events[main]
+	for i, e := range events {
+		if i != main {
+			inv.Overrides = append(inv.Overrides, e)
 		}
-		if prop := child.Props.Get(ical.PropCreated); prop != nil {
-			if t, err := prop.DateTime(time.UTC); err == nil {
-				inv.Created = t
+	}
+	return &inv, nil
+}
+
+// parseEvent extracts the invite information of a VEVENT.
+func parseEvent(child *ical.Component) Invite {
+	inv := Invite{}
+	if prop := child.Props.Get(ical.PropUID); prop != nil {
+		inv.UID = prop.Value
+	}
+	inv.Summary = textProp(child, ical.PropSummary)
+	inv.Description = textProp(child, ical.PropDescription)
+	inv.Location = textProp(child, ical.PropLocation)
+	inv.Comment = textProp(child, ical.PropComment)
+	if prop := child.Props.Get(ical.PropSequence); prop != nil {
+		fmt.Sscanf(prop.Value, "%d", &inv.Sequence)
+	}
+	if prop := child.Props.Get(ical.PropRecurrenceRule); prop != nil {
+		inv.RRule = prop.Value
+	}
+	if prop := child.Props.Get(ical.PropStatus); prop != nil {
+		inv.Status = strings.ToUpper(prop.Value)
+	}
+
+	// Parse times
+	inv.Start = timeProp(child, ical.PropDateTimeStart)
+	inv.End = timeProp(child, ical.PropDateTimeEnd)
+	if inv.End.IsZero() && !inv.Start.IsZero() {
+		if prop := child.Props.Get(ical.PropDuration); prop != nil {
+			if d, err := prop.Duration(); err == nil {
+				inv.End = inv.Start.Add(d)
 			}
 		}
+	}
+	inv.Created = timeProp(child, ical.PropCreated)
+	inv.LastMod = timeProp(child, ical.PropLastModified)
+	inv.Occurrence = timeProp(child, ical.PropRecurrenceID)
+	inv.RDates = timeProps(child, ical.PropRecurrenceDates)
+	inv.ExDates = timeProps(child, ical.PropExceptionDates)
 
-		// Organizer
-		if prop := child.Props.Get(ical.PropOrganizer); prop != nil {
-			inv.Organizer = parseParticipant(prop)
-		}
+	// Organizer
+	if prop := child.Props.Get(ical.PropOrganizer); prop != nil {
+		inv.Organizer = parseParticipant(prop)
+	}
 
-		// Attendees
-		for _, prop := range child.Props.Values(ical.PropAttendee) {
-			inv.Attendees = append(inv.Attendees, parseParticipant(&prop))
-		}
+	// Attendees
+	for _, prop := range child.Props.Values(ical.PropAttendee) {
+		inv.Attendees = append(inv.Attendees, parseParticipant(&prop))
+	}
+	return inv
+}
 
-		break // Only process first VEVENT
+// textProp returns the unescaped value of a text property, or "".
+func textProp(comp *ical.Component, name string) string {
+	prop := comp.Props.Get(name)
+	if prop == nil {
+		return ""
 	}
+	if text, err := prop.Text(); err == nil {
+		return text
+	}
+	return prop.Value
+}
 
-	return inv, nil
+// timeProp parses a date or date-time property, or returns the zero time.
+func timeProp(comp *ical.Component, name string) time.Time {
+	prop := comp.Props.Get(name)
+	if prop == nil {
+		return time.Time{}
+	}
+	t, err := timezone.ParseProp(prop, time.UTC)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// timeProps parses the values of a multi-valued date property like EXDATE.
+func timeProps(comp *ical.Component, name string) []time.Time {
+	var times []time.Time
+	for _, prop := range comp.Props[name] {
+		for _, value := range strings.Split(prop.Value, ",") {
+			single := ical.Prop{Name: prop.Name, Params: prop.Params, Value: value}
+			if t, err := timezone.ParseProp(&single, time.UTC); err == nil {
+				times = append(times, t)
+			}
+		}
+	}
+	return times
 }
 
 // parseParticipant extracts participant info from an ORGANIZER or ATTENDEE property.
@@ -303,7 +496,7 @@ func parseParticipant(prop *ical.Prop) Participant {
 
 	// Participation status
 	if ps := prop.Params.Get(ical.ParamParticipationStatus); ps != "" {
-		p.Status = ParticipantStatus(ps)
+		p.Status = ParticipantStatus(strings.ToUpper(ps))
 	}
 
 	// RSVP
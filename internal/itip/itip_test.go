@@ -0,0 +1,180 @@
+package itip
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testInvite() *Invite {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	return &Invite{
+		UID:       "meeting@example.com",
+		Summary:   "Planning",
+		Location:  "Room 1",
+		Start:     start,
+		End:       start.Add(time.Hour),
+		Organizer: Participant{Email: "alice@example.com", Name: "Alice"},
+		Attendees: []Participant{
+			{Email: "bob@example.com", RSVP: true},
+			{Email: "carol@example.com", RSVP: true},
+		},
+		Sequence: 1,
+	}
+}
+
+func TestCreateInvite(t *testing.T) {
+	data, err := CreateInvite(testInvite())
+	require.NoError(t, err)
+
+	inv, err := ParseInvite(data)
+	require.NoError(t, err)
+	assert.Equal(t, MethodRequest, inv.Method)
+	assert.Equal(t, "Planning", inv.Summary)
+	assert.Equal(t, 1, inv.Sequence)
+	assert.Equal(t, "Alice", inv.Organizer.Name)
+	require.Len(t, inv.Attendees, 2)
+	assert.Equal(t, "bob@example.com", inv.Attendees[0].Email)
+	assert.Equal(t, StatusNeedsAction, inv.Attendees[1].Status)
+	assert.True(t, inv.Attendees[1].RSVP)
+	assert.NotContains(t, string(data), "CREATED")
+}
+
+func TestCreatePublish(t *testing.T) {
+	data, err := CreatePublish(testInvite())
+	require.NoError(t, err)
+
+	inv, err := ParseInvite(data)
+	require.NoError(t, err)
+	assert.Equal(t, MethodPublish, inv.Method)
+	assert.Empty(t, inv.Attendees)
+	assert.Equal(t, "alice@example.com", inv.Organizer.Email)
+}
+
+func TestCreateCounter(t *testing.T) {
+	proposal := testInvite()
+	proposal.Start = proposal.Start.Add(2 * time.Hour)
+	proposal.End = proposal.End.Add(2 * time.Hour)
+	proposal.Attendees = []Participant{{Email: "bob@example.com", Status: StatusTentative}}
+	proposal.Comment = "Mornings are busy"
+	data, err := CreateCounter(proposal)
+	require.NoError(t, err)
+
+	inv, err := ParseInvite(data)
+	require.NoError(t, err)
+	assert.Equal(t, MethodCounter, inv.Method)
+	assert.True(t, proposal.Start.Equal(inv.Start))
+	assert.Equal(t, "Mornings are busy", inv.Comment)
+	require.Len(t, inv.Attendees, 1)
+	assert.Equal(t, StatusTentative, inv.Attendees[0].Status)
+
+	proposal.Attendees = nil
+	_, err = CreateCounter(proposal)
+	assert.Error(t, err)
+}
+
+func TestCreateDeclineCounter(t *testing.T) {
+	data, err := CreateDeclineCounter(&Response{
+		UID:       "meeting@example.com",
+		Organizer: Participant{Email: "alice@example.com"},
+		Attendee:  Participant{Email: "bob@example.com"},
+		Comment:   "The room is taken",
+		Sequence:  1,
+	})
+	require.NoError(t, err)
+
+	inv, err := ParseInvite(data)
+	require.NoError(t, err)
+	assert.Equal(t, MethodDeclineCounter, inv.Method)
+	assert.Equal(t, "The room is taken", inv.Comment)
+	assert.Equal(t, 1, inv.Sequence)
+	require.Len(t, inv.Attendees, 1)
+	assert.Equal(t, "bob@example.com", inv.Attendees[0].Email)
+}
+
+func TestCreateRefresh(t *testing.T) {
+	data, err := CreateRefresh("meeting@example.com",
+		Participant{Email: "alice@example.com"}, Participant{Email: "bob@example.com"})
+	require.NoError(t, err)
+
+	inv, err := ParseInvite(data)
+	require.NoError(t, err)
+	assert.Equal(t, MethodRefresh, inv.Method)
+	assert.Equal(t, "meeting@example.com", inv.UID)
+	assert.Equal(t, "alice@example.com", inv.Organizer.Email)
+	require.Len(t, inv.Attendees, 1)
+}
+
+func TestCreateAdd(t *testing.T) {
+	extra := testInvite()
+	extra.Start = extra.Start.AddDate(0, 0, 3)
+	extra.End = extra.End.AddDate(0, 0, 3)
+	data, err := CreateAdd(extra)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "METHOD:ADD")
+	assert.Equal(t, 2, strings.Count(string(data), "ATTENDEE"))
+}
+
+func TestParseInviteOverrides(t *testing.T) {
+	// The override comes first; the series is still the main event
+	data := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\nMETHOD:request\r\n" +
+		"BEGIN:VEVENT\r\nUID:weekly@example.com\r\nDTSTAMP:20260301T000000Z\r\n" +
+		"RECURRENCE-ID:20260309T090000Z\r\nDTSTART:20260309T110000Z\r\nDURATION:PT1H\r\n" +
+		"SUMMARY:Weekly (moved)\r\nSEQUENCE:2\r\nORGANIZER:MAILTO:alice@example.com\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:weekly@example.com\r\nDTSTAMP:20260301T000000Z\r\n" +
+		"DTSTART:20260302T090000Z\r\nDTEND:20260302T100000Z\r\nRRULE:FREQ=WEEKLY;COUNT=4\r\n" +
+		"EXDATE:20260316T090000Z,20260323T090000Z\r\nRDATE:20260401T090000Z\r\n" +
+		"SUMMARY:Weekly\r\nSEQUENCE:2\r\nORGANIZER:MAILTO:alice@example.com\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	inv, err := ParseInvite([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, MethodRequest, inv.Method)
+	assert.Equal(t, "Weekly", inv.Summary)
+	assert.Equal(t, "FREQ=WEEKLY;COUNT=4", inv.RRule)
+	assert.Equal(t, "alice@example.com", inv.Organizer.Email)
+	assert.Len(t, inv.ExDates, 2)
+	assert.Len(t, inv.RDates, 1)
+	require.Len(t, inv.Overrides, 1)
+	override := inv.Overrides[0]
+	assert.Equal(t, "Weekly (moved)", override.Summary)
+	assert.Equal(t, time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC), override.Occurrence.UTC())
+	assert.Equal(t, time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC), override.End.UTC())
+
+	// Overrides survive a round trip
+	out, err := CreateInvite(inv)
+	require.NoError(t, err)
+	again, err := ParseInvite(out)
+	require.NoError(t, err)
+	require.Len(t, again.Overrides, 1)
+	assert.True(t, override.Occurrence.Equal(again.Overrides[0].Occurrence))
+	assert.Equal(t, "FREQ=WEEKLY;COUNT=4", again.RRule)
+	require.Len(t, again.ExDates, 2)
+	assert.True(t, again.ExDates[1].Equal(time.Date(2026, 3, 23, 9, 0, 0, 0, time.UTC)))
+	require.Len(t, again.RDates, 1)
+	assert.True(t, again.RDates[0].Equal(time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)))
+}
+
+func TestInviteOver(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	inv := &Invite{Start: start, End: start.Add(time.Hour)}
+	assert.Equal(t, start.Add(time.Hour), inv.Over())
+
+	inv.RRule = "FREQ=WEEKLY;COUNT=4"
+	assert.Equal(t, time.Date(2026, 3, 23, 10, 0, 0, 0, time.UTC), inv.Over())
+	inv.RRule = "FREQ=DAILY;UNTIL=20260310T090000Z"
+	assert.Equal(t, time.Date(2026, 3, 10, 10, 0, 0, 0, time.UTC), inv.Over())
+
+	// A moved last occurrence
+	inv.Overrides = []Invite{{End: time.Date(2026, 3, 12, 18, 0, 0, 0, time.UTC)}}
+	assert.Equal(t, time.Date(2026, 3, 12, 18, 0, 0, 0, time.UTC), inv.Over())
+
+	// An added occurrence after the rule's last
+	inv.RDates = []time.Time{time.Date(2026, 3, 20, 9, 0, 0, 0, time.UTC)}
+	assert.Equal(t, time.Date(2026, 3, 20, 10, 0, 0, 0, time.UTC), inv.Over())
+
+	inv.RRule = "FREQ=WEEKLY"
+	assert.True(t, inv.Over().IsZero())
+}
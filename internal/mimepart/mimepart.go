@@ -0,0 +1,222 @@
+// Package mimepart parses MIME entities while keeping the exact bytes of
+// each part, which signature verification (PGP/MIME, S/MIME) depends on.
+package mimepart
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+)
+
+// Part is a parsed MIME entity.
+type Part struct {
+	Header    textproto.MIMEHeader
+	MediaType string            // Lowercased, e.g. "multipart/signed"
+	Params    map[string]string // Content-Type parameters
+	Raw       []byte            // Headers and body exactly as received
+	Body      []byte            // Body, still transfer-encoded
+	Parts     []*Part           // Children of a multipart entity
+}
+
+// Parse parses a MIME entity (a full message or a single body part).
+func Parse(raw []byte) (*Part, error) {
+	headerEnd, bodyStart := splitHeader(raw)
+
+	header := textproto.MIMEHeader{}
+	if headerEnd > 0 {
+		r := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(raw[:headerEnd:headerEnd], "\r\n\r\n"...))))
+		h, err := r.ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to parse MIME header: %w", err)
+		}
+		header = h
+	}
+
+	p := &Part{
+		Header:    header,
+		MediaType: "text/plain",
+		Params:    map[string]string{},
+		Raw:       raw,
+		Body:      raw[bodyStart:],
+	}
+
+	if ct := header.Get("Content-Type"); ct != "" {
+		mediaType, params, err := mime.ParseMediaType(ct)
+		if err == nil {
+			p.MediaType = strings.ToLower(mediaType)
+			p.Params = params
+		}
+	}
+
+	if strings.HasPrefix(p.MediaType, "multipart/") {
+		boundary := p.Params["boundary"]
+		if boundary == "" {
+			return nil, fmt.Errorf("multipart entity without boundary")
+		}
+		for _, rawPart := range SplitMultipart(p.Body, boundary) {
+			child, err := Parse(rawPart)
+			if err != nil {
+				return nil, err
+			}
+			p.Parts = append(p.Parts, child)
+		}
+	}
+
+	return p, nil
+}
+
+// splitHeader returns the end of the header block and the start of the body.
+func splitHeader(raw []byte) (headerEnd, bodyStart int) {
+	if bytes.HasPrefix(raw, []byte("\r\n")) {
+		return 0, 2
+	}
+	if bytes.HasPrefix(raw, []byte("\n")) {
+		return 0, 1
+	}
+	crlf := bytes.Index(raw, []byte("\r\n\r\n"))
+	lf := bytes.Index(raw, []byte("\n\n"))
+	switch {
+	case crlf >= 0 && (lf < 0 || crlf < lf):
+		return crlf, crlf + 4
+	case lf >= 0:
+		return lf, lf + 2
+	default:
+		return len(raw), len(raw)
+	}
+}
+
+// SplitMultipart splits a multipart body into the raw bytes of its parts.
+// The line break before each delimiter belongs to the delimiter (RFC 2046),
+// so each returned part is exactly what a signature covers.
+func SplitMultipart(body []byte, boundary string) [][]byte {
+	delim := []byte("--" + boundary)
+
+	var parts [][]byte
+	start := -1
+	pos := 0
+	for pos <= len(body) {
+		idx := bytes.Index(body[pos:], delim)
+		if idx < 0 {
+			break
+		}
+		idx += pos
+
+		// Delimiters must start a line
+		if idx > 0 && body[idx-1] != '\n' {
+			pos = idx + len(delim)
+			continue
+		}
+
+		if start >= 0 {
+			end := idx
+			if end > 0 && body[end-1] == '\n' {
+				end--
+				if end > 0 && body[end-1] == '\r' {
+					end--
+				}
+			}
+			parts = append(parts, body[start:end])
+		}
+
+		after := idx + len(delim)
+		if bytes.HasPrefix(body[after:], []byte("--")) {
+			return parts
+		}
+
+		// Skip transport padding and the line break after the delimiter
+		nl := bytes.IndexByte(body[after:], '\n')
+		if nl < 0 {
+			return parts
+		}
+		start = after + nl + 1
+		pos = start
+	}
+	return parts
+}
+
+// Decoded returns the body with its Content-Transfer-Encoding removed.
+func (p *Part) Decoded() ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(p.Header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		clean := bytes.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, p.Body)
+		out := make([]byte, base64.StdEncoding.DecodedLen(len(clean)))
+		n, err := base64.StdEncoding.Decode(out, clean)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64: %w", err)
+		}
+		return out[:n], nil
+	case "quoted-printable":
+		out, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(p.Body)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode quoted-printable: %w", err)
+		}
+		return out, nil
+	default:
+		return p.Body, nil
+	}
+}
+
+// Text returns the first text/plain body in the entity, decoded, or the
+// first text/* body if there is no plain text. It returns "" if none exists.
+func (p *Part) Text() string {
+	if t := p.findText("text/plain"); t != nil {
+		body, _ := t.Decoded()
+		return string(body)
+	}
+	if t := p.findText("text/"); t != nil {
+		body, _ := t.Decoded()
+		return string(body)
+	}
+	return ""
+}
+
+func (p *Part) findText(prefix string) *Part {
+	if len(p.Parts) > 0 {
+		for _, child := range p.Parts {
+			if t := child.findText(prefix); t != nil {
+				return t
+			}
+		}
+		return nil
+	}
+	if strings.HasPrefix(p.MediaType, prefix) && !strings.HasPrefix(strings.ToLower(p.Header.Get("Content-Disposition")), "attachment") {
+		return p
+	}
+	return nil
+}
+
+// Find returns the single parts in the entity with one of the media
+// types, attachments included, in order.
+func (p *Part) Find(mediaTypes ...string) []*Part {
+	if len(p.Parts) > 0 {
+		var found []*Part
+		for _, child := range p.Parts {
+			found = append(found, child.Find(mediaTypes...)...)
+		}
+		return found
+	}
+	for _, mt := range mediaTypes {
+		if p.MediaType == mt {
+			return []*Part{p}
+		}
+	}
+	return nil
+}
+
+// Canonicalize converts line endings to CRLF, as required for data that
+// is signed in MIME security multiparts.
+func Canonicalize(data []byte) []byte {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+}
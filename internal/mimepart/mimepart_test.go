@@ -0,0 +1,71 @@
+package mimepart
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const multipartMessage = "From: alice@example.com\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"XYZ\"\r\n" +
+	"\r\n" +
+	"preamble\r\n" +
+	"--XYZ\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Caf=C3=A9 at noon\r\n" +
+	"--XYZ\r\n" +
+	"Content-Type: application/octet-stream\r\n" +
+	"Content-Disposition: attachment; filename=\"a.bin\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"aGVsbG8=\r\n" +
+	"--XYZ--\r\n"
+
+func TestParseMultipart(t *testing.T) {
+	p, err := Parse([]byte(multipartMessage))
+	require.NoError(t, err)
+
+	assert.Equal(t, "multipart/mixed", p.MediaType)
+	assert.Equal(t, "alice@example.com", p.Header.Get("From"))
+	require.Len(t, p.Parts, 2)
+
+	// Raw parts exclude the line break that belongs to the next delimiter
+	assert.Equal(t, "Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nCaf=C3=A9 at noon", string(p.Parts[0].Raw))
+	assert.Equal(t, "Café at noon", p.Text())
+
+	data, err := p.Parts[1].Decoded()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestParseSinglePart(t *testing.T) {
+	p, err := Parse([]byte("Subject: hi\n\nbody\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", p.MediaType)
+	assert.Equal(t, "body\n", p.Text())
+
+	p, err = Parse([]byte("\r\nno headers"))
+	require.NoError(t, err)
+	assert.Equal(t, "no headers", p.Text())
+}
+
+func TestCanonicalize(t *testing.T) {
+	assert.Equal(t, "a\r\nb\r\nc", string(Canonicalize([]byte("a\nb\r\nc"))))
+}
+
+func TestFind(t *testing.T) {
+	p, err := Parse([]byte(multipartMessage))
+	require.NoError(t, err)
+
+	found := p.Find("application/octet-stream", "application/ics")
+	require.Len(t, found, 1)
+	body, err := found[0].Decoded()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+
+	assert.Empty(t, p.Find("text/calendar"))
+	assert.Len(t, p.Find("text/plain"), 1)
+}
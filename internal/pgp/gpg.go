@@ -0,0 +1,194 @@
+package pgp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GPG is a Backend that runs a gpg binary, using the user's GnuPG keyring
+// and agent for passphrases.
+type GPG struct {
+	Path string // gpg binary (default: "gpg")
+	WKD  bool   // Locate missing recipient keys via WKD
+}
+
+func (g *GPG) run(stdin []byte, args ...string) (stdout, status []byte, err error) {
+	path := g.Path
+	if path == "" {
+		path = "gpg"
+	}
+
+	args = append([]string{"--batch", "--no-tty", "--status-fd", "2"}, args...)
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+
+	err = cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stripStatus(errOut.String()))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return out.Bytes(), errOut.Bytes(), fmt.Errorf("gpg: %s", msg)
+	}
+	return out.Bytes(), errOut.Bytes(), nil
+}
+
+// DetachSign implements Backend.
+func (g *GPG) DetachSign(data []byte, signer string) ([]byte, error) {
+	out, _, err := g.run(data, "--armor", "--detach-sign", "--digest-algo", "SHA256", "--local-user", signer)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Encrypt implements Backend.
+func (g *GPG) Encrypt(data []byte, recipients []string, signer string) ([]byte, error) {
+	args := []string{"--armor", "--encrypt"}
+	if g.WKD {
+		args = append(args, "--auto-key-locate", "local,wkd")
+	}
+	for _, r := range recipients {
+		args = append(args, "--recipient", r)
+	}
+	if signer != "" {
+		args = append(args, "--sign", "--local-user", signer, "--digest-algo", "SHA256")
+	}
+
+	out, _, err := g.run(data, args...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Decrypt implements Backend.
+func (g *GPG) Decrypt(data []byte) ([]byte, *Signature, error) {
+	out, status, err := g.run(data, "--decrypt")
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, parseGPGStatus(status), nil
+}
+
+// Verify implements Backend.
+func (g *GPG) Verify(data, sig []byte) *Signature {
+	// gpg reads the signature from a file and the data from stdin
+	f, err := os.CreateTemp("", "sog-sig-*.asc")
+	if err != nil {
+		return &Signature{Status: SignatureError, Error: err.Error()}
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(sig); err != nil {
+		f.Close()
+		return &Signature{Status: SignatureError, Error: err.Error()}
+	}
+	f.Close()
+
+	_, status, err := g.run(data, "--verify", f.Name(), "-")
+	if s := parseGPGStatus(status); s != nil {
+		return s
+	}
+	if err == nil {
+		err = fmt.Errorf("no signature found")
+	}
+	return &Signature{Status: SignatureError, Error: err.Error()}
+}
+
+// parseGPGStatus reads the signature result from gpg --status-fd output.
+// It returns nil if the output reports no signature.
+func parseGPGStatus(status []byte) *Signature {
+	var sig *Signature
+	get := func() *Signature {
+		if sig == nil {
+			sig = &Signature{}
+		}
+		return sig
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "[GNUPG:] ")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "GOODSIG", "EXPSIG", "EXPKEYSIG", "REVKEYSIG":
+			s := get()
+			s.Status = SignatureGood
+			if fields[0] != "GOODSIG" {
+				s.Status = SignatureBad
+				s.Error = strings.ToLower(fields[0])
+			}
+			if len(fields) > 1 {
+				s.KeyID = fields[1]
+			}
+			if len(fields) > 2 {
+				s.Signer = strings.Join(fields[2:], " ")
+			}
+		case "BADSIG":
+			s := get()
+			s.Status = SignatureBad
+			s.Error = "bad signature"
+			if len(fields) > 1 {
+				s.KeyID = fields[1]
+			}
+			if len(fields) > 2 {
+				s.Signer = strings.Join(fields[2:], " ")
+			}
+		case "ERRSIG":
+			s := get()
+			if len(fields) > 1 {
+				s.KeyID = fields[1]
+			}
+			// ERRSIG <keyid> <pkalgo> <hashalgo> <sig_class> <time> <rc>; rc 9 = missing key
+			if len(fields) > 6 && fields[6] == "9" {
+				s.Status = SignatureUnknownKey
+			} else if s.Status == "" {
+				s.Status = SignatureError
+				s.Error = "signature could not be checked"
+			}
+		case "NO_PUBKEY":
+			s := get()
+			if s.Status != SignatureGood && s.Status != SignatureBad {
+				s.Status = SignatureUnknownKey
+			}
+		case "VALIDSIG":
+			s := get()
+			if len(fields) > 1 {
+				s.Fingerprint = fields[1]
+			}
+			if len(fields) > 3 {
+				if ts, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+					s.Created = time.Unix(ts, 0)
+				}
+			}
+		}
+	}
+	return sig
+}
+
+// stripStatus removes [GNUPG:] status lines from gpg stderr output.
+func stripStatus(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if !strings.HasPrefix(line, "[GNUPG:] ") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,521 @@
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+const (
+	publicKeyringFile = "pubring.gpg"
+	secretKeyringFile = "secring.gpg"
+)
+
+// Keyring is a Backend using keyring files in a directory
+// (~/.config/sog/pgp by default). Secret keys protected by a passphrase
+// are unlocked with the Passphrase callback.
+type Keyring struct {
+	dir        string
+	public     openpgp.EntityList
+	secret     openpgp.EntityList
+	Passphrase func() (string, error)
+}
+
+// KeyInfo summarizes a key in the keyring.
+type KeyInfo struct {
+	Fingerprint string    `json:"fingerprint"`
+	KeyID       string    `json:"key_id"`
+	UserIDs     []string  `json:"user_ids"`
+	Emails      []string  `json:"emails"`
+	Secret      bool      `json:"secret"`
+	Created     time.Time `json:"created"`
+	Expires     time.Time `json:"expires,omitempty"`
+}
+
+// OpenKeyring loads the keyring files in dir. Missing files are treated
+// as empty keyrings.
+func OpenKeyring(dir string) (*Keyring, error) {
+	k := &Keyring{dir: dir}
+
+	var err error
+	if k.public, err = readKeyringFile(filepath.Join(dir, publicKeyringFile)); err != nil {
+		return nil, err
+	}
+	if k.secret, err = readKeyringFile(filepath.Join(dir, secretKeyringFile)); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func readKeyringFile(path string) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+	keys, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse keyring %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// Import adds keys (armored or binary, one or more) to the keyring and
+// saves it. Keys already present are replaced. Secret keys are also added
+// to the public keyring.
+func (k *Keyring) Import(r io.Reader) ([]KeyInfo, error) {
+	keys, err := ReadKeys(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys found")
+	}
+
+	var infos []KeyInfo
+	for _, e := range keys {
+		if e.PrivateKey != nil {
+			k.secret = replaceEntity(k.secret, e)
+		}
+		k.public = replaceEntity(k.public, e)
+		infos = append(infos, keyInfo(e, e.PrivateKey != nil))
+	}
+
+	if err := k.save(); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// ReadKeys parses one or more keys, armored or binary.
+func ReadKeys(r io.Reader) (openpgp.EntityList, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+
+	if !bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		keys, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse keys: %w", err)
+		}
+		return keys, nil
+	}
+
+	// Armored input may hold several blocks (e.g. public and secret)
+	var keys openpgp.EntityList
+	rest := data
+	for {
+		start := bytes.Index(rest, []byte("-----BEGIN PGP"))
+		if start < 0 {
+			break
+		}
+		block, err := armor.Decode(bytes.NewReader(rest[start:]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode armor: %w", err)
+		}
+		el, err := openpgp.ReadKeyRing(block.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse keys: %w", err)
+		}
+		keys = append(keys, el...)
+
+		end := bytes.Index(rest[start:], []byte("-----END PGP"))
+		if end < 0 {
+			break
+		}
+		rest = rest[start+end+len("-----END PGP"):]
+	}
+	return keys, nil
+}
+
+// replaceEntity adds e to list, replacing a key with the same fingerprint.
+func replaceEntity(list openpgp.EntityList, e *openpgp.Entity) openpgp.EntityList {
+	for i, existing := range list {
+		if bytes.Equal(existing.PrimaryKey.Fingerprint, e.PrimaryKey.Fingerprint) {
+			list[i] = e
+			return list
+		}
+	}
+	return append(list, e)
+}
+
+// Delete removes a key (public and secret) by fingerprint, key ID or email.
+func (k *Keyring) Delete(id string) error {
+	before := len(k.public) + len(k.secret)
+	k.public = filterEntities(k.public, id)
+	k.secret = filterEntities(k.secret, id)
+	if len(k.public)+len(k.secret) == before {
+		return fmt.Errorf("key not found: %s", id)
+	}
+	return k.save()
+}
+
+func filterEntities(list openpgp.EntityList, id string) openpgp.EntityList {
+	out := list[:0]
+	for _, e := range list {
+		if !matchEntity(e, id) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (k *Keyring) save() error {
+	if err := os.MkdirAll(k.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create keyring dir: %w", err)
+	}
+
+	var pub bytes.Buffer
+	for _, e := range k.public {
+		if err := e.Serialize(&pub); err != nil {
+			return fmt.Errorf("failed to serialize key: %w", err)
+		}
+	}
+	var sec bytes.Buffer
+	for _, e := range k.secret {
+		if err := e.SerializePrivateWithoutSigning(&sec, nil); err != nil {
+			return fmt.Errorf("failed to serialize secret key: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(k.dir, publicKeyringFile), pub.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(k.dir, secretKeyringFile), sec.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	return nil
+}
+
+// List returns the keys in the keyring, sorted by first user ID.
+func (k *Keyring) List() []KeyInfo {
+	secret := map[string]bool{}
+	for _, e := range k.secret {
+		secret[fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)] = true
+	}
+
+	infos := make([]KeyInfo, 0, len(k.public))
+	for _, e := range k.public {
+		infos = append(infos, keyInfo(e, secret[fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)]))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return strings.Join(infos[i].UserIDs, ",") < strings.Join(infos[j].UserIDs, ",")
+	})
+	return infos
+}
+
+// Export returns the armored public key matching id.
+func (k *Keyring) Export(id string) ([]byte, error) {
+	e := findEntity(k.public, id)
+	if e == nil {
+		return nil, fmt.Errorf("key not found: %s", id)
+	}
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.Serialize(w); err != nil {
+		return nil, fmt.Errorf("failed to serialize key: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// HasKey reports whether the keyring has a usable encryption key for email.
+func (k *Keyring) HasKey(email string) bool {
+	e := findEntity(k.public, email)
+	if e == nil {
+		return false
+	}
+	_, ok := e.EncryptionKey(time.Now())
+	return ok
+}
+
+// DetachSign implements Backend.
+func (k *Keyring) DetachSign(data []byte, signer string) ([]byte, error) {
+	e, err := k.signingEntity(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&buf, e, bytes.NewReader(data), packetConfig()); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// Encrypt implements Backend.
+func (k *Keyring) Encrypt(data []byte, recipients []string, signer string) ([]byte, error) {
+	var to []*openpgp.Entity
+	var missing []string
+	for _, r := range recipients {
+		e := findEntity(k.public, r)
+		if e == nil {
+			missing = append(missing, r)
+			continue
+		}
+		to = append(to, e)
+	}
+	if len(missing) > 0 {
+		return nil, &MissingKeyError{Recipients: missing}
+	}
+
+	var signed *openpgp.Entity
+	if signer != "" {
+		var err error
+		if signed, err = k.signingEntity(signer); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	armored, err := armor.Encode(&buf, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+	w, err := openpgp.Encrypt(armored, to, signed, nil, packetConfig())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := armored.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// Decrypt implements Backend.
+func (k *Keyring) Decrypt(data []byte) ([]byte, *Signature, error) {
+	r, err := dearmor(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyring := append(append(openpgp.EntityList{}, k.secret...), k.public...)
+	tried := false
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if symmetric || tried || k.Passphrase == nil {
+			return nil, fmt.Errorf("secret key is locked (set a passphrase with 'sog auth password <email> --pgp')")
+		}
+		tried = true
+		passphrase, err := k.Passphrase()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if key.PrivateKey != nil && key.PrivateKey.Encrypted {
+				_ = key.PrivateKey.Decrypt([]byte(passphrase))
+			}
+		}
+		return nil, nil
+	}
+
+	md, err := openpgp.ReadMessage(r, keyring, prompt, packetConfig())
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !md.IsSigned {
+		return plaintext, nil, nil
+	}
+	return plaintext, messageSignature(md.SignedByKeyId, md.SignedBy, md.Signature, md.SignatureError), nil
+}
+
+// Verify implements Backend.
+func (k *Keyring) Verify(data, sig []byte) *Signature {
+	sigReader, err := dearmor(sig)
+	if err != nil {
+		return &Signature{Status: SignatureError, Error: err.Error()}
+	}
+
+	packetSig, signer, err := openpgp.VerifyDetachedSignature(k.public, bytes.NewReader(data), sigReader, packetConfig())
+	var keyID uint64
+	if packetSig != nil && packetSig.IssuerKeyId != nil {
+		keyID = *packetSig.IssuerKeyId
+	}
+	var key *openpgp.Key
+	if signer != nil {
+		if keys := k.public.KeysById(keyID); len(keys) > 0 {
+			key = &keys[0]
+		} else {
+			key = &openpgp.Key{Entity: signer, PublicKey: signer.PrimaryKey}
+		}
+	}
+	return messageSignature(keyID, key, packetSig, err)
+}
+
+// messageSignature converts a go-crypto verification result.
+func messageSignature(keyID uint64, key *openpgp.Key, sig *packet.Signature, err error) *Signature {
+	s := &Signature{Status: SignatureGood}
+	if keyID != 0 {
+		s.KeyID = fmt.Sprintf("%016X", keyID)
+	}
+	if sig != nil {
+		s.Created = sig.CreationTime
+	}
+	if key != nil && key.Entity != nil {
+		s.Fingerprint = fmt.Sprintf("%X", key.Entity.PrimaryKey.Fingerprint)
+		if id := key.Entity.PrimaryIdentity(); id != nil {
+			s.Signer = id.Name
+		}
+	}
+
+	switch {
+	case err == nil && key == nil:
+		s.Status = SignatureUnknownKey
+	case err == nil:
+	case errors.Is(err, pgperrors.ErrUnknownIssuer):
+		s.Status = SignatureUnknownKey
+	case isSignatureError(err):
+		s.Status = SignatureBad
+		s.Error = err.Error()
+	default:
+		s.Status = SignatureError
+		s.Error = err.Error()
+	}
+	return s
+}
+
+func isSignatureError(err error) bool {
+	var sigErr pgperrors.SignatureError
+	return errors.As(err, &sigErr)
+}
+
+// signingEntity returns the unlocked secret key for signer.
+func (k *Keyring) signingEntity(signer string) (*openpgp.Entity, error) {
+	e := findEntity(k.secret, signer)
+	if e == nil {
+		return nil, fmt.Errorf("no secret key for %s (import one with 'sog pgp import')", signer)
+	}
+	if err := k.unlock(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// unlock decrypts an entity's secret keys with the passphrase if needed.
+func (k *Keyring) unlock(e *openpgp.Entity) error {
+	locked := e.PrivateKey != nil && e.PrivateKey.Encrypted
+	for _, sub := range e.Subkeys {
+		if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+			locked = true
+		}
+	}
+	if !locked {
+		return nil
+	}
+	if k.Passphrase == nil {
+		return fmt.Errorf("secret key is locked and no passphrase is configured")
+	}
+
+	passphrase, err := k.Passphrase()
+	if err != nil {
+		return err
+	}
+	if err := e.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+		return fmt.Errorf("failed to unlock secret key: %w", err)
+	}
+	return nil
+}
+
+// findEntity finds a key by fingerprint, key ID (long or short) or email.
+func findEntity(list openpgp.EntityList, id string) *openpgp.Entity {
+	for _, e := range list {
+		if matchEntity(e, id) {
+			return e
+		}
+	}
+	return nil
+}
+
+func matchEntity(e *openpgp.Entity, id string) bool {
+	id = strings.TrimPrefix(strings.ToUpper(strings.ReplaceAll(id, " ", "")), "0X")
+	fpr := fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)
+	if id == fpr || (len(id) >= 8 && strings.HasSuffix(fpr, id)) {
+		return true
+	}
+	for _, ident := range e.Identities {
+		if strings.EqualFold(ident.UserId.Email, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func keyInfo(e *openpgp.Entity, secret bool) KeyInfo {
+	info := KeyInfo{
+		Fingerprint: fmt.Sprintf("%X", e.PrimaryKey.Fingerprint),
+		KeyID:       e.PrimaryKey.KeyIdString(),
+		Secret:      secret,
+		Created:     e.PrimaryKey.CreationTime,
+	}
+	for name, ident := range e.Identities {
+		info.UserIDs = append(info.UserIDs, name)
+		if ident.UserId.Email != "" {
+			info.Emails = append(info.Emails, strings.ToLower(ident.UserId.Email))
+		}
+	}
+	sort.Strings(info.UserIDs)
+	sort.Strings(info.Emails)
+	if sig, _ := e.PrimarySelfSignature(); sig != nil && sig.KeyLifetimeSecs != nil && *sig.KeyLifetimeSecs > 0 {
+		info.Expires = info.Created.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second)
+	}
+	return info
+}
+
+// dearmor returns a reader for armored or binary OpenPGP data.
+func dearmor(data []byte) (io.Reader, error) {
+	if !bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		return bytes.NewReader(data), nil
+	}
+	block, err := armor.Decode(bytes.NewReader(data[bytes.Index(data, []byte("-----BEGIN PGP")):]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode armor: %w", err)
+	}
+	return block.Body, nil
+}
+
+func packetConfig() *packet.Config {
+	return &packet.Config{DefaultHash: crypto.SHA256}
+}
+
+// MissingKeyError is returned when recipients have no public key.
+type MissingKeyError struct {
+	Recipients []string
+}
+
+func (e *MissingKeyError) Error() string {
+	return fmt.Sprintf("no public key for %s (import with 'sog pgp import' or look up with 'sog pgp lookup')", strings.Join(e.Recipients, ", "))
+}
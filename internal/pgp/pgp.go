@@ -0,0 +1,246 @@
+// Package pgp provides OpenPGP signing and encryption for mail using
+// PGP/MIME (RFC 3156). Keys come from a local keyring (see Keyring) or
+// from a gpg binary (see GPG).
+package pgp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/visionik/sogcli/internal/mimepart"
+)
+
+// Backend performs OpenPGP operations.
+type Backend interface {
+	// DetachSign returns an armored detached signature over data, made
+	// with the key for signer (an email address, key ID or fingerprint).
+	// Signatures use SHA-256 (micalg=pgp-sha256).
+	DetachSign(data []byte, signer string) ([]byte, error)
+
+	// Encrypt returns an armored OpenPGP message encrypted to recipients.
+	// When signer is not empty the message is also signed.
+	Encrypt(data []byte, recipients []string, signer string) ([]byte, error)
+
+	// Decrypt decrypts an OpenPGP message. The signature is nil when the
+	// message was not signed.
+	Decrypt(data []byte) ([]byte, *Signature, error)
+
+	// Verify checks a detached signature over data.
+	Verify(data, sig []byte) *Signature
+}
+
+// SignatureStatus is the outcome of a signature check.
+type SignatureStatus string
+
+// Signature statuses.
+const (
+	SignatureGood       SignatureStatus = "good"
+	SignatureBad        SignatureStatus = "bad"
+	SignatureUnknownKey SignatureStatus = "unknown-key"
+	SignatureError      SignatureStatus = "error"
+)
+
+// Signature describes a checked signature.
+type Signature struct {
+	Status      SignatureStatus `json:"status"`
+	KeyID       string          `json:"key_id,omitempty"`
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	Signer      string          `json:"signer,omitempty"` // Primary user ID of the signing key
+	Created     time.Time       `json:"created,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// String describes the signature for display.
+func (s *Signature) String() string {
+	key := s.Fingerprint
+	if key == "" {
+		key = s.KeyID
+	}
+	switch s.Status {
+	case SignatureGood:
+		return fmt.Sprintf("Good signature from %s (key %s)", s.Signer, key)
+	case SignatureBad:
+		return fmt.Sprintf("BAD signature from %s (key %s)", s.Signer, key)
+	case SignatureUnknownKey:
+		return fmt.Sprintf("Signed with unknown key %s", key)
+	default:
+		return fmt.Sprintf("Signature could not be checked: %s", s.Error)
+	}
+}
+
+// Result is an opened (decrypted and/or verified) message.
+type Result struct {
+	Encrypted bool       `json:"encrypted"`
+	Signed    bool       `json:"signed"`
+	Signature *Signature `json:"signature,omitempty"`
+	Entity    []byte     `json:"-"` // Inner MIME entity
+}
+
+// IsPGP reports whether a message is PGP/MIME signed or encrypted, or
+// contains an inline PGP message.
+func IsPGP(raw []byte) bool {
+	part, err := mimepart.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return isEncrypted(part) || isSigned(part) ||
+		strings.Contains(part.Text(), "-----BEGIN PGP MESSAGE-----")
+}
+
+// IsEncrypted reports whether a message is PGP encrypted.
+func IsEncrypted(raw []byte) bool {
+	part, err := mimepart.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return isEncrypted(part) || strings.Contains(part.Text(), "-----BEGIN PGP MESSAGE-----")
+}
+
+func isEncrypted(p *mimepart.Part) bool {
+	return p.MediaType == "multipart/encrypted" &&
+		strings.EqualFold(p.Params["protocol"], "application/pgp-encrypted")
+}
+
+func isSigned(p *mimepart.Part) bool {
+	return p.MediaType == "multipart/signed" &&
+		strings.EqualFold(p.Params["protocol"], "application/pgp-signature")
+}
+
+// SignMIME wraps a MIME entity in multipart/signed (RFC 3156 section 5).
+// The returned entity starts with its Content-Type header.
+func SignMIME(b Backend, entity []byte, signer string) ([]byte, error) {
+	entity = mimepart.Canonicalize(entity)
+	sig, err := b.DetachSign(entity, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+
+	boundary := newBoundary()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Content-Type: multipart/signed; boundary=\"%s\"; micalg=pgp-sha256; protocol=\"application/pgp-signature\"\r\n", boundary)
+	buf.WriteString("\r\n")
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.Write(entity)
+	fmt.Fprintf(&buf, "\r\n--%s\r\n", boundary)
+	buf.WriteString("Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n")
+	buf.WriteString("Content-Description: OpenPGP digital signature\r\n")
+	buf.WriteString("Content-Disposition: attachment; filename=\"signature.asc\"\r\n")
+	buf.WriteString("\r\n")
+	buf.Write(mimepart.Canonicalize(sig))
+	fmt.Fprintf(&buf, "\r\n--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+// EncryptMIME wraps a MIME entity in multipart/encrypted (RFC 3156
+// section 4). When signer is set the entity is signed and encrypted in
+// one OpenPGP message (section 6.2).
+func EncryptMIME(b Backend, entity []byte, recipients []string, signer string) ([]byte, error) {
+	ciphertext, err := b.Encrypt(mimepart.Canonicalize(entity), recipients, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+
+	boundary := newBoundary()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Content-Type: multipart/encrypted; boundary=\"%s\"; protocol=\"application/pgp-encrypted\"\r\n", boundary)
+	buf.WriteString("\r\n")
+	buf.WriteString("This is an OpenPGP/MIME encrypted message (RFC 3156).\r\n")
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: application/pgp-encrypted\r\n")
+	buf.WriteString("Content-Description: PGP/MIME version identification\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString("Version: 1\r\n")
+	fmt.Fprintf(&buf, "\r\n--%s\r\n", boundary)
+	buf.WriteString("Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n")
+	buf.WriteString("Content-Description: OpenPGP encrypted message\r\n")
+	buf.WriteString("Content-Disposition: inline; filename=\"encrypted.asc\"\r\n")
+	buf.WriteString("\r\n")
+	buf.Write(mimepart.Canonicalize(ciphertext))
+	fmt.Fprintf(&buf, "\r\n--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+// Open decrypts and verifies a message (or MIME entity). Messages that
+// are not PGP are returned unchanged with Encrypted and Signed false.
+// A signed message inside an encrypted one is verified too.
+func Open(b Backend, raw []byte) (*Result, error) {
+	part, err := mimepart.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Entity: raw}
+	for depth := 0; depth < 3; depth++ {
+		switch {
+		case isEncrypted(part):
+			if len(part.Parts) < 2 {
+				return nil, fmt.Errorf("malformed multipart/encrypted message")
+			}
+			plaintext, sig, err := b.Decrypt(part.Parts[1].Body)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt: %w", err)
+			}
+			result.Encrypted = true
+			if sig != nil {
+				result.Signed = true
+				result.Signature = sig
+			}
+			result.Entity = plaintext
+
+		case isSigned(part):
+			if len(part.Parts) < 2 {
+				return nil, fmt.Errorf("malformed multipart/signed message")
+			}
+			result.Signed = true
+			result.Signature = b.Verify(mimepart.Canonicalize(part.Parts[0].Raw), part.Parts[1].Body)
+			result.Entity = part.Parts[0].Raw
+
+		default:
+			if depth == 0 {
+				return openInline(b, part, result)
+			}
+			return result, nil
+		}
+
+		if part, err = mimepart.Parse(result.Entity); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// openInline handles traditional inline PGP in a text body.
+func openInline(b Backend, part *mimepart.Part, result *Result) (*Result, error) {
+	text := part.Text()
+	start := strings.Index(text, "-----BEGIN PGP MESSAGE-----")
+	if start < 0 {
+		return result, nil
+	}
+	end := strings.Index(text[start:], "-----END PGP MESSAGE-----")
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated inline PGP message")
+	}
+	end += start + len("-----END PGP MESSAGE-----")
+
+	plaintext, sig, err := b.Decrypt([]byte(text[start:end]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	result.Encrypted = true
+	if sig != nil {
+		result.Signed = true
+		result.Signature = sig
+	}
+	result.Entity = append([]byte("Content-Type: text/plain; charset=utf-8\r\n\r\n"), plaintext...)
+	return result, nil
+}
+
+// newBoundary generates a random MIME boundary.
+func newBoundary() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("----=_PGP_%x", b)
+}
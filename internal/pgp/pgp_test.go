@@ -0,0 +1,180 @@
+package pgp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestKeyring creates a keyring with secret keys for the given emails.
+func newTestKeyring(t *testing.T, emails ...string) *Keyring {
+	t.Helper()
+	k, err := OpenKeyring(t.TempDir())
+	require.NoError(t, err)
+
+	for _, email := range emails {
+		e, err := openpgp.NewEntity(strings.Split(email, "@")[0], "", email, nil)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+		require.NoError(t, err)
+		require.NoError(t, e.SerializePrivate(w, nil))
+		require.NoError(t, w.Close())
+
+		_, err = k.Import(&buf)
+		require.NoError(t, err)
+	}
+	return k
+}
+
+const testEntity = "Content-Type: text/plain; charset=utf-8\r\n\r\nHello Bob\r\n"
+
+func TestSignMIMERoundTrip(t *testing.T) {
+	k := newTestKeyring(t, "alice@example.com")
+
+	signed, err := SignMIME(k, []byte(testEntity), "alice@example.com")
+	require.NoError(t, err)
+	assert.Contains(t, string(signed), "multipart/signed")
+	assert.Contains(t, string(signed), "micalg=pgp-sha256")
+
+	msg := append([]byte("From: alice@example.com\r\nSubject: hi\r\nMIME-Version: 1.0\r\n"), signed...)
+	result, err := Open(k, msg)
+	require.NoError(t, err)
+	assert.False(t, result.Encrypted)
+	assert.True(t, result.Signed)
+	require.NotNil(t, result.Signature)
+	assert.Equal(t, SignatureGood, result.Signature.Status, result.Signature.Error)
+	assert.Contains(t, result.Signature.Signer, "alice@example.com")
+	assert.Equal(t, testEntity, string(result.Entity))
+
+	// Tampering breaks the signature
+	tampered := bytes.Replace(msg, []byte("Hello Bob"), []byte("Hello Eve"), 1)
+	result, err = Open(k, tampered)
+	require.NoError(t, err)
+	assert.Equal(t, SignatureBad, result.Signature.Status)
+
+	// A keyring without the signer's key reports an unknown key
+	other := newTestKeyring(t, "carol@example.com")
+	result, err = Open(other, msg)
+	require.NoError(t, err)
+	assert.Equal(t, SignatureUnknownKey, result.Signature.Status)
+}
+
+func TestEncryptMIMERoundTrip(t *testing.T) {
+	k := newTestKeyring(t, "alice@example.com", "bob@example.com")
+
+	encrypted, err := EncryptMIME(k, []byte(testEntity), []string{"bob@example.com", "alice@example.com"}, "alice@example.com")
+	require.NoError(t, err)
+	assert.Contains(t, string(encrypted), "multipart/encrypted")
+	assert.NotContains(t, string(encrypted), "Hello Bob")
+	assert.True(t, IsEncrypted(encrypted))
+
+	result, err := Open(k, encrypted)
+	require.NoError(t, err)
+	assert.True(t, result.Encrypted)
+	assert.True(t, result.Signed)
+	assert.Equal(t, SignatureGood, result.Signature.Status)
+	assert.Equal(t, testEntity, string(result.Entity))
+
+	_, err = EncryptMIME(k, []byte(testEntity), []string{"nobody@example.com"}, "")
+	var missing *MissingKeyError
+	require.ErrorAs(t, err, &missing)
+	assert.Equal(t, []string{"nobody@example.com"}, missing.Recipients)
+}
+
+func TestOpenPlainMessage(t *testing.T) {
+	k := newTestKeyring(t)
+	msg := []byte("Subject: hi\r\n\r\nplain text\r\n")
+
+	result, err := Open(k, msg)
+	require.NoError(t, err)
+	assert.False(t, result.Encrypted)
+	assert.False(t, result.Signed)
+	assert.Equal(t, msg, result.Entity)
+	assert.False(t, IsEncrypted(msg))
+}
+
+func TestKeyringPersistence(t *testing.T) {
+	k := newTestKeyring(t, "alice@example.com")
+
+	reopened, err := OpenKeyring(k.dir)
+	require.NoError(t, err)
+	keys := reopened.List()
+	require.Len(t, keys, 1)
+	assert.True(t, keys[0].Secret)
+	assert.Equal(t, []string{"alice@example.com"}, keys[0].Emails)
+	assert.True(t, reopened.HasKey("Alice@Example.com"))
+
+	exported, err := reopened.Export(keys[0].Fingerprint)
+	require.NoError(t, err)
+	assert.Contains(t, string(exported), "BEGIN PGP PUBLIC KEY BLOCK")
+
+	// Importing only the public key into another keyring
+	other := newTestKeyring(t)
+	infos, err := other.Import(bytes.NewReader(exported))
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.False(t, infos[0].Secret)
+
+	require.NoError(t, reopened.Delete("alice@example.com"))
+	assert.Empty(t, reopened.List())
+	assert.Error(t, reopened.Delete("alice@example.com"))
+}
+
+func TestLockedKeyUsesPassphrase(t *testing.T) {
+	k := newTestKeyring(t)
+	e, err := openpgp.NewEntity("alice", "", "alice@example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, e.EncryptPrivateKeys([]byte("hunter2"), nil))
+
+	var buf bytes.Buffer
+	require.NoError(t, e.SerializePrivateWithoutSigning(&buf, nil))
+	_, err = k.Import(&buf)
+	require.NoError(t, err)
+
+	_, err = k.DetachSign([]byte("data"), "alice@example.com")
+	assert.ErrorContains(t, err, "locked")
+
+	k.Passphrase = func() (string, error) { return "hunter2", nil }
+	sig, err := k.DetachSign([]byte("data"), "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, SignatureGood, k.Verify([]byte("data"), sig).Status)
+}
+
+func TestWKDURLs(t *testing.T) {
+	// Test vector from draft-koch-openpgp-webkey-service
+	urls, err := WKDURLs("Joe.Doe@Example.ORG")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"https://openpgpkey.example.org/.well-known/openpgpkey/example.org/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+		"https://example.org/.well-known/openpgpkey/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+	}, urls)
+
+	_, err = WKDURLs("not-an-address")
+	assert.Error(t, err)
+}
+
+func TestParseGPGStatus(t *testing.T) {
+	good := "[GNUPG:] NEWSIG\n[GNUPG:] GOODSIG 1234ABCD5678EF00 Alice <alice@example.com>\n" +
+		"[GNUPG:] VALIDSIG 0123456789ABCDEF0123456789ABCDEF01234567 2026-01-01 1767225600 0 4 0 1 8 00 0123456789ABCDEF0123456789ABCDEF01234567\n"
+	sig := parseGPGStatus([]byte(good))
+	require.NotNil(t, sig)
+	assert.Equal(t, SignatureGood, sig.Status)
+	assert.Equal(t, "Alice <alice@example.com>", sig.Signer)
+	assert.Equal(t, "0123456789ABCDEF0123456789ABCDEF01234567", sig.Fingerprint)
+	assert.Equal(t, int64(1767225600), sig.Created.Unix())
+
+	sig = parseGPGStatus([]byte("[GNUPG:] BADSIG 1234ABCD5678EF00 Alice <alice@example.com>\n"))
+	assert.Equal(t, SignatureBad, sig.Status)
+
+	sig = parseGPGStatus([]byte("[GNUPG:] ERRSIG 1234ABCD5678EF00 1 8 00 1767225600 9 -\n[GNUPG:] NO_PUBKEY 1234ABCD5678EF00\n"))
+	assert.Equal(t, SignatureUnknownKey, sig.Status)
+
+	assert.Nil(t, parseGPGStatus([]byte("[GNUPG:] DECRYPTION_OKAY\n")))
+}
@@ -0,0 +1,93 @@
+package pgp
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// zbase32Alphabet is the z-base-32 alphabet used by WKD.
+const zbase32Alphabet = "ybndrfg8ejkmcpqxot1uwisza345h769"
+
+// WKDURLs returns the Web Key Directory URLs for an address, advanced
+// method first, then direct.
+func WKDURLs(email string) ([]string, error) {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" || domain == "" {
+		return nil, fmt.Errorf("invalid email address: %s", email)
+	}
+	domain = strings.ToLower(domain)
+
+	sum := sha1.Sum([]byte(strings.ToLower(local)))
+	hash := zbase32(sum[:])
+	query := "?l=" + url.QueryEscape(local)
+
+	return []string{
+		fmt.Sprintf("https://openpgpkey.%s/.well-known/openpgpkey/%s/hu/%s%s", domain, domain, hash, query),
+		fmt.Sprintf("https://%s/.well-known/openpgpkey/hu/%s%s", domain, hash, query),
+	}, nil
+}
+
+// LookupWKD fetches the public key for email from its domain's Web Key
+// Directory. The result is a binary key that can be passed to Import.
+func LookupWKD(ctx context.Context, client *http.Client, email string) ([]byte, error) {
+	urls, err := WKDURLs(email)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var lastErr error
+	for _, u := range urls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("%s: %s", u, resp.Status)
+			continue
+		}
+		if len(data) == 0 {
+			lastErr = fmt.Errorf("%s: empty response", u)
+			continue
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("no WKD key for %s: %w", email, lastErr)
+}
+
+// zbase32 encodes data with z-base-32 (RFC 6189 section 5.1.6).
+func zbase32(data []byte) string {
+	var sb strings.Builder
+	var buffer uint32
+	bits := 0
+	for _, b := range data {
+		buffer = buffer<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			sb.WriteByte(zbase32Alphabet[(buffer>>uint(bits))&0x1f])
+		}
+	}
+	if bits > 0 {
+		sb.WriteByte(zbase32Alphabet[(buffer<<uint(5-bits))&0x1f])
+	}
+	return sb.String()
+}
@@ -0,0 +1,132 @@
+package smime
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ErrPasswordRequired is returned when a PKCS#12 file needs a password
+// that was not given.
+var ErrPasswordRequired = errors.New("PKCS#12 file is password protected")
+
+// LoadIdentity loads a certificate and private key. certPath is either a
+// PKCS#12 file (.p12/.pfx) or PEM with the certificate first, optionally
+// followed by its chain and the key. keyPath names a separate PEM key
+// file and may be empty. password unlocks PKCS#12 files.
+func LoadIdentity(certPath, keyPath, password string) (*Identity, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(certPath)) {
+	case ".p12", ".pfx":
+		return loadPKCS12(data, password)
+	}
+
+	certs, key, err := parsePEM(data)
+	if err != nil {
+		return nil, err
+	}
+	if keyPath != "" {
+		keyData, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key: %w", err)
+		}
+		if _, key, err = parsePEM(keyData); err != nil {
+			return nil, err
+		}
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate in %s", certPath)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no private key in %s (use a PKCS#12 file or a separate key file)", certPath)
+	}
+	return &Identity{Certificate: certs[0], Chain: certs[1:], Key: key}, nil
+}
+
+func loadPKCS12(data []byte, password string) (*Identity, error) {
+	key, cert, chain, err := pkcs12.DecodeChain(data, password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		if password == "" {
+			return nil, ErrPasswordRequired
+		}
+		return nil, fmt.Errorf("incorrect PKCS#12 password")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PKCS#12 file: %w", err)
+	}
+	return &Identity{Certificate: cert, Chain: chain, Key: key}, nil
+}
+
+// parsePEM returns the certificates and the first private key in PEM data.
+func parsePEM(data []byte) ([]*x509.Certificate, crypto.PrivateKey, error) {
+	var certs []*x509.Certificate
+	var key crypto.PrivateKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			if key != nil {
+				continue
+			}
+			if _, encrypted := block.Headers["Proc-Type"]; encrypted {
+				return nil, nil, fmt.Errorf("encrypted PEM keys are not supported; use a PKCS#12 file")
+			}
+			k, err := parsePrivateKey(block)
+			if err != nil {
+				return nil, nil, err
+			}
+			key = k
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, nil, fmt.Errorf("encrypted PEM keys are not supported; use a PKCS#12 file")
+		}
+	}
+	return certs, key, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.PrivateKey, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		return key, nil
+	}
+}
+
+// ReadCertificates reads certificates from PEM or DER data.
+func ReadCertificates(data []byte) ([]*x509.Certificate, error) {
+	if certs, _, err := parsePEM(data); err != nil || len(certs) > 0 {
+		return certs, err
+	}
+	certs, err := x509.ParseCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return certs, nil
+}
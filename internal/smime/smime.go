@@ -0,0 +1,348 @@
+// Package smime provides S/MIME (RFC 8551) signing, encryption,
+// verification and decryption for mail, plus a store of recipient
+// certificates harvested from signed messages.
+package smime
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/smallstep/pkcs7"
+	"github.com/visionik/sogcli/internal/mimepart"
+)
+
+func init() {
+	// AES-256-CBC is what mail clients widely support; the pkcs7 default
+	// is DES.
+	pkcs7.ContentEncryptionAlgorithm = pkcs7.EncryptionAlgorithmAES256CBC
+}
+
+// Identity is a signing/decryption certificate with its private key.
+type Identity struct {
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate // Intermediates, issuer first
+	Key         crypto.PrivateKey
+}
+
+// SignatureStatus is the outcome of a signature check.
+type SignatureStatus string
+
+// Signature statuses.
+const (
+	SignatureGood      SignatureStatus = "good"      // Valid and chains to a trusted root
+	SignatureUntrusted SignatureStatus = "untrusted" // Valid, but the certificate is not trusted
+	SignatureBad       SignatureStatus = "bad"
+	SignatureError     SignatureStatus = "error"
+)
+
+// CertInfo describes a certificate for display.
+type CertInfo struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	Emails      []string  `json:"emails,omitempty"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"fingerprint"` // SHA-256, hex
+}
+
+// Signature describes a checked signature.
+type Signature struct {
+	Status  SignatureStatus `json:"status"`
+	Signer  string          `json:"signer,omitempty"` // Subject common name
+	Emails  []string        `json:"emails,omitempty"` // Signer certificate addresses
+	Created time.Time       `json:"created,omitempty"`
+	Chain   []CertInfo      `json:"chain,omitempty"` // Signer first
+	Error   string          `json:"error,omitempty"`
+}
+
+// String describes the signature for display.
+func (s *Signature) String() string {
+	who := s.Signer
+	if len(s.Emails) > 0 {
+		who = fmt.Sprintf("%s <%s>", s.Signer, s.Emails[0])
+	}
+	switch s.Status {
+	case SignatureGood:
+		return fmt.Sprintf("Good S/MIME signature from %s", who)
+	case SignatureUntrusted:
+		return fmt.Sprintf("Valid S/MIME signature from %s (certificate not trusted: %s)", who, s.Error)
+	case SignatureBad:
+		return fmt.Sprintf("BAD S/MIME signature from %s: %s", who, s.Error)
+	default:
+		return fmt.Sprintf("S/MIME signature could not be checked: %s", s.Error)
+	}
+}
+
+// Result is an opened (decrypted and/or verified) message.
+type Result struct {
+	Encrypted bool       `json:"encrypted"`
+	Signed    bool       `json:"signed"`
+	Signature *Signature `json:"signature,omitempty"`
+	Entity    []byte     `json:"-"` // Inner MIME entity
+
+	// Signer is the signing certificate when the signature is
+	// cryptographically valid, for harvesting into a Store.
+	Signer *x509.Certificate `json:"-"`
+}
+
+// IsSMIME reports whether a message is S/MIME signed or encrypted.
+func IsSMIME(raw []byte) bool {
+	part, err := mimepart.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return isPKCS7MIME(part) || isSigned(part)
+}
+
+// IsEncrypted reports whether a message is S/MIME encrypted.
+func IsEncrypted(raw []byte) bool {
+	part, err := mimepart.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return isPKCS7MIME(part) && strings.EqualFold(part.Params["smime-type"], "enveloped-data")
+}
+
+func isPKCS7MIME(p *mimepart.Part) bool {
+	return p.MediaType == "application/pkcs7-mime" || p.MediaType == "application/x-pkcs7-mime"
+}
+
+func isSigned(p *mimepart.Part) bool {
+	if p.MediaType != "multipart/signed" {
+		return false
+	}
+	protocol := strings.ToLower(p.Params["protocol"])
+	return protocol == "application/pkcs7-signature" || protocol == "application/x-pkcs7-signature"
+}
+
+// Sign wraps a MIME entity in opaque signed-data (application/pkcs7-mime;
+// smime-type=signed-data), which survives transport unchanged. The
+// returned entity starts with its Content-Type header.
+func Sign(entity []byte, id *Identity) ([]byte, error) {
+	sd, err := pkcs7.NewSignedData(mimepart.Canonicalize(entity))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSignerChain(id.Certificate, id.Key, id.Chain, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	der, err := sd.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	return pkcs7Entity("signed-data", der), nil
+}
+
+// Encrypt wraps a MIME entity in enveloped-data (application/pkcs7-mime;
+// smime-type=enveloped-data) for the given recipient certificates.
+func Encrypt(entity []byte, recipients []*x509.Certificate) ([]byte, error) {
+	der, err := pkcs7.Encrypt(mimepart.Canonicalize(entity), recipients)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return pkcs7Entity("enveloped-data", der), nil
+}
+
+// pkcs7Entity builds an application/pkcs7-mime entity.
+func pkcs7Entity(smimeType string, der []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Content-Type: application/pkcs7-mime; smime-type=%s; name=\"smime.p7m\"\r\n", smimeType)
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	buf.WriteString("Content-Disposition: attachment; filename=\"smime.p7m\"\r\n")
+	buf.WriteString("\r\n")
+	enc := base64.StdEncoding.EncodeToString(der)
+	for len(enc) > 76 {
+		buf.WriteString(enc[:76] + "\r\n")
+		enc = enc[76:]
+	}
+	buf.WriteString(enc + "\r\n")
+	return buf.Bytes()
+}
+
+// Open decrypts and verifies a message (or MIME entity). ids are tried in
+// turn for decryption. Signer certificates are checked against roots, or
+// the system roots if nil. Messages that are not S/MIME are returned
+// unchanged with Encrypted and Signed false.
+func Open(raw []byte, ids []*Identity, roots *x509.CertPool) (*Result, error) {
+	part, err := mimepart.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Entity: raw}
+	for depth := 0; depth < 3; depth++ {
+		switch {
+		case isSigned(part):
+			if len(part.Parts) < 2 {
+				return nil, fmt.Errorf("malformed multipart/signed message")
+			}
+			der, err := part.Parts[1].Decoded()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode signature: %w", err)
+			}
+			result.Signed = true
+			result.Signature, result.Signer = verify(der, mimepart.Canonicalize(part.Parts[0].Raw), roots)
+			result.Entity = part.Parts[0].Raw
+
+		case isPKCS7MIME(part):
+			der, err := part.Decoded()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode S/MIME body: %w", err)
+			}
+			p7, err := pkcs7.Parse(der)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse S/MIME body: %w", err)
+			}
+			if len(p7.Signers) > 0 {
+				result.Signed = true
+				result.Signature, result.Signer = verify(der, nil, roots)
+				result.Entity = p7.Content
+			} else {
+				plaintext, err := decrypt(p7, ids)
+				if err != nil {
+					return nil, err
+				}
+				result.Encrypted = true
+				result.Entity = plaintext
+			}
+
+		default:
+			return result, nil
+		}
+
+		if part, err = mimepart.Parse(result.Entity); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// decrypt decrypts enveloped-data with the first identity it is addressed to.
+func decrypt(p7 *pkcs7.PKCS7, ids []*Identity) ([]byte, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("message is S/MIME encrypted but no S/MIME certificate is configured")
+	}
+	var lastErr error
+	for _, id := range ids {
+		plaintext, err := p7.Decrypt(id.Certificate, id.Key)
+		if err == nil {
+			return plaintext, nil
+		}
+		if errors.Is(err, pkcs7.ErrNotEncryptedContent) {
+			return nil, fmt.Errorf("unsupported S/MIME content")
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("failed to decrypt: %w", lastErr)
+}
+
+// verify checks a signed-data structure. detached is the signed content
+// for multipart/signed messages, nil for opaque signed-data. The signer
+// certificate is returned when the signature itself is valid.
+func verify(der, detached []byte, roots *x509.CertPool) (*Signature, *x509.Certificate) {
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		return &Signature{Status: SignatureError, Error: err.Error()}, nil
+	}
+	if detached != nil {
+		p7.Content = detached
+	}
+
+	signer := p7.GetOnlySigner()
+	if signer == nil {
+		return &Signature{Status: SignatureError, Error: "expected exactly one signer"}, nil
+	}
+
+	sig := &Signature{
+		Signer: signer.Subject.CommonName,
+		Emails: signer.EmailAddresses,
+		Chain:  chainInfo(orderChain(signer, p7.Certificates)),
+	}
+	var created time.Time
+	if err := p7.UnmarshalSignedAttribute(pkcs7.OIDAttributeSigningTime, &created); err == nil {
+		sig.Created = created
+	}
+
+	if err := p7.Verify(); err != nil {
+		sig.Status = SignatureBad
+		sig.Error = err.Error()
+		return sig, nil
+	}
+
+	// The signature is valid; now check the certificate
+	intermediates := x509.NewCertPool()
+	for _, c := range p7.Certificates {
+		if c != signer {
+			intermediates.AddCert(c)
+		}
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	// The signing time is claimed by the signer, so the chain is checked
+	// as of now
+	chains, err := signer.Verify(opts)
+	if err != nil {
+		sig.Status = SignatureUntrusted
+		sig.Error = err.Error()
+		return sig, signer
+	}
+	sig.Status = SignatureGood
+	sig.Chain = chainInfo(chains[0])
+	return sig, signer
+}
+
+// orderChain returns leaf followed by its issuers found in certs.
+func orderChain(leaf *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	chain := []*x509.Certificate{leaf}
+	for cur := leaf; len(chain) <= len(certs); {
+		if bytes.Equal(cur.RawIssuer, cur.RawSubject) {
+			break
+		}
+		var next *x509.Certificate
+		for _, c := range certs {
+			if bytes.Equal(c.RawSubject, cur.RawIssuer) && c != cur {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		chain = append(chain, next)
+		cur = next
+	}
+	return chain
+}
+
+func chainInfo(chain []*x509.Certificate) []CertInfo {
+	infos := make([]CertInfo, 0, len(chain))
+	for _, c := range chain {
+		infos = append(infos, Info(c))
+	}
+	return infos
+}
+
+// Info describes a certificate.
+func Info(c *x509.Certificate) CertInfo {
+	sum := sha256.Sum256(c.Raw)
+	return CertInfo{
+		Subject:     c.Subject.String(),
+		Issuer:      c.Issuer.String(),
+		Emails:      c.EmailAddresses,
+		NotBefore:   c.NotBefore,
+		NotAfter:    c.NotAfter,
+		Fingerprint: strings.ToUpper(hex.EncodeToString(sum[:])),
+	}
+}
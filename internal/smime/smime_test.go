@@ -0,0 +1,274 @@
+package smime
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallstep/pkcs7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newTestCA returns a CA certificate and key.
+func newTestCA(t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// newTestIdentity issues a certificate for email from the CA.
+func newTestIdentity(t *testing.T, ca *x509.Certificate, caKey *rsa.PrivateKey, email string, serial int64) *Identity {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(serial),
+		Subject:        pkix.Name{CommonName: strings.Split(email, "@")[0]},
+		EmailAddresses: []string{email},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Duration(serial) * time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &Identity{Certificate: cert, Chain: []*x509.Certificate{ca}, Key: key}
+}
+
+const testEntity = "Content-Type: text/plain; charset=utf-8\r\n\r\nHello Bob\r\n"
+
+func TestSignRoundTrip(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	alice := newTestIdentity(t, ca, caKey, "alice@example.com", 10)
+
+	signed, err := Sign([]byte(testEntity), alice)
+	require.NoError(t, err)
+	assert.Contains(t, string(signed), "smime-type=signed-data")
+	assert.True(t, IsSMIME(signed))
+	assert.False(t, IsEncrypted(signed))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	result, err := Open(signed, nil, roots)
+	require.NoError(t, err)
+	assert.True(t, result.Signed)
+	assert.False(t, result.Encrypted)
+	assert.Equal(t, SignatureGood, result.Signature.Status)
+	assert.Equal(t, []string{"alice@example.com"}, result.Signature.Emails)
+	require.Len(t, result.Signature.Chain, 2)
+	assert.Equal(t, "CN=Test CA", result.Signature.Chain[1].Subject)
+	assert.Equal(t, testEntity, string(result.Entity))
+	assert.True(t, alice.Certificate.Equal(result.Signer))
+
+	// Unknown CA: the signature is valid but not trusted
+	result, err = Open(signed, nil, x509.NewCertPool())
+	require.NoError(t, err)
+	assert.Equal(t, SignatureUntrusted, result.Signature.Status)
+	assert.NotNil(t, result.Signer)
+}
+
+func TestVerifyDetachedSignature(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	alice := newTestIdentity(t, ca, caKey, "alice@example.com", 10)
+
+	// Build multipart/signed the way other clients do
+	sigDER := detachedSignature(t, alice, []byte(testEntity))
+	msg := fmt.Sprintf("Content-Type: multipart/signed; protocol=\"application/pkcs7-signature\"; micalg=sha-256; boundary=\"b\"\r\n\r\n"+
+		"--b\r\n%s\r\n--b\r\nContent-Type: application/pkcs7-signature; name=smime.p7s\r\nContent-Transfer-Encoding: base64\r\n\r\n%s\r\n--b--\r\n",
+		testEntity, base64.StdEncoding.EncodeToString(sigDER))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	result, err := Open([]byte(msg), nil, roots)
+	require.NoError(t, err)
+	assert.Equal(t, SignatureGood, result.Signature.Status)
+	assert.Equal(t, testEntity, string(result.Entity))
+
+	tampered := strings.Replace(msg, "Hello Bob", "Hello Eve", 1)
+	result, err = Open([]byte(tampered), nil, roots)
+	require.NoError(t, err)
+	assert.Equal(t, SignatureBad, result.Signature.Status)
+	assert.Nil(t, result.Signer)
+}
+
+// detachedSignature returns a detached signed-data structure over data.
+func detachedSignature(t *testing.T, id *Identity, data []byte) []byte {
+	t.Helper()
+	sd, err := pkcs7.NewSignedData(data)
+	require.NoError(t, err)
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	require.NoError(t, sd.AddSignerChain(id.Certificate, id.Key, id.Chain, pkcs7.SignerInfoConfig{}))
+	sd.Detach()
+	der, err := sd.Finish()
+	require.NoError(t, err)
+	return der
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	alice := newTestIdentity(t, ca, caKey, "alice@example.com", 10)
+	bob := newTestIdentity(t, ca, caKey, "bob@example.com", 11)
+	eve := newTestIdentity(t, ca, caKey, "eve@example.com", 12)
+
+	signed, err := Sign([]byte(testEntity), alice)
+	require.NoError(t, err)
+	encrypted, err := Encrypt(signed, []*x509.Certificate{bob.Certificate, alice.Certificate})
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, string(encrypted), "Hello Bob")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	result, err := Open(encrypted, []*Identity{eve, bob}, roots)
+	require.NoError(t, err)
+	assert.True(t, result.Encrypted)
+	assert.True(t, result.Signed)
+	assert.Equal(t, SignatureGood, result.Signature.Status)
+	assert.Equal(t, testEntity, string(result.Entity))
+
+	_, err = Open(encrypted, []*Identity{eve}, roots)
+	assert.Error(t, err)
+	_, err = Open(encrypted, nil, roots)
+	assert.Error(t, err)
+}
+
+func TestOpenPlainMessage(t *testing.T) {
+	raw := []byte("Subject: hi\r\n\r\nhello\r\n")
+	assert.False(t, IsSMIME(raw))
+	result, err := Open(raw, nil, nil)
+	require.NoError(t, err)
+	assert.False(t, result.Signed)
+	assert.False(t, result.Encrypted)
+	assert.Equal(t, raw, result.Entity)
+}
+
+func TestLoadIdentityPEM(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	alice := newTestIdentity(t, ca, caKey, "alice@example.com", 10)
+
+	dir := t.TempDir()
+	var certPEM bytes.Buffer
+	pem.Encode(&certPEM, &pem.Block{Type: "CERTIFICATE", Bytes: alice.Certificate.Raw})
+	pem.Encode(&certPEM, &pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	keyDER, err := x509.MarshalPKCS8PrivateKey(alice.Key)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	certPath := filepath.Join(dir, "alice.crt")
+	keyPath := filepath.Join(dir, "alice.key")
+	require.NoError(t, os.WriteFile(certPath, certPEM.Bytes(), 0600))
+	require.NoError(t, os.WriteFile(keyPath, keyPEM, 0600))
+
+	id, err := LoadIdentity(certPath, keyPath, "")
+	require.NoError(t, err)
+	assert.True(t, id.Certificate.Equal(alice.Certificate))
+	require.Len(t, id.Chain, 1)
+	assert.True(t, id.Chain[0].Equal(ca))
+
+	_, err = LoadIdentity(certPath, "", "")
+	assert.ErrorContains(t, err, "no private key")
+
+	// Key and certificate in one file
+	combined := filepath.Join(dir, "alice.pem")
+	require.NoError(t, os.WriteFile(combined, append(certPEM.Bytes(), keyPEM...), 0600))
+	_, err = LoadIdentity(combined, "", "")
+	require.NoError(t, err)
+}
+
+func TestStore(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	older := newTestIdentity(t, ca, caKey, "Bob@example.com", 10)
+	newer := newTestIdentity(t, ca, caKey, "bob@example.com", 20)
+
+	s := OpenStore(filepath.Join(t.TempDir(), "certs"))
+	_, err := s.Lookup("bob@example.com")
+	assert.IsType(t, &MissingCertError{}, err)
+
+	added, err := s.Add(newer.Certificate, "carol@example.com")
+	require.NoError(t, err)
+	assert.False(t, added, "certificate of another address")
+	added, err = s.Add(newer.Certificate, "bob@example.com")
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	// Add never replaces a stored certificate, even by a newer one
+	added, err = s.Add(older.Certificate, "bob@example.com")
+	require.NoError(t, err)
+	assert.False(t, added)
+	cert, err := s.Lookup("BOB@example.com")
+	require.NoError(t, err)
+	assert.True(t, cert.Equal(newer.Certificate))
+
+	// Import does
+	imported, err := s.Import(older.Certificate)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob@example.com"}, imported)
+	imported, err = s.Import(older.Certificate)
+	require.NoError(t, err)
+	assert.Empty(t, imported)
+	cert, err = s.Lookup("bob@example.com")
+	require.NoError(t, err)
+	assert.True(t, cert.Equal(older.Certificate))
+
+	_, err = s.Recipients([]string{"bob@example.com", "carol@example.com"})
+	var missing *MissingCertError
+	require.ErrorAs(t, err, &missing)
+	assert.Equal(t, []string{"carol@example.com"}, missing.Recipients)
+
+	list, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "bob@example.com", list[0].Email)
+
+	require.NoError(t, s.Delete("bob@example.com"))
+	assert.Error(t, s.Delete("bob@example.com"))
+}
+
+func TestLoadIdentityPKCS12(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	alice := newTestIdentity(t, ca, caKey, "alice@example.com", 10)
+
+	data, err := pkcs12.Modern.Encode(alice.Key, alice.Certificate, []*x509.Certificate{ca}, "secret")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "alice.p12")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	_, err = LoadIdentity(path, "", "")
+	assert.ErrorIs(t, err, ErrPasswordRequired)
+	_, err = LoadIdentity(path, "", "wrong")
+	assert.Error(t, err)
+
+	id, err := LoadIdentity(path, "", "secret")
+	require.NoError(t, err)
+	assert.True(t, id.Certificate.Equal(alice.Certificate))
+	require.Len(t, id.Chain, 1)
+}
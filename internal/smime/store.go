@@ -0,0 +1,182 @@
+package smime
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Store keeps recipient certificates, one PEM file per email address.
+type Store struct {
+	dir string
+}
+
+// StoredCert is a certificate in the store.
+type StoredCert struct {
+	Email string `json:"email"`
+	CertInfo
+}
+
+// OpenStore opens (without creating) the certificate store in dir.
+func OpenStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) path(email string) string {
+	return filepath.Join(s.dir, strings.ToLower(email)+".pem")
+}
+
+// validAddress reports whether email is safe to use as a file name.
+func validAddress(email string) bool {
+	return strings.Contains(email, "@") && !strings.ContainsAny(email, "/\\") && !strings.HasPrefix(email, ".")
+}
+
+// Import stores cert for each of its email addresses, replacing the
+// certificates stored for them. It is meant for certificates the user
+// chose to trust. It returns the addresses that were added or updated.
+func (s *Store) Import(cert *x509.Certificate) ([]string, error) {
+	var updated []string
+	for _, email := range cert.EmailAddresses {
+		if !validAddress(email) {
+			continue
+		}
+		if existing, err := s.Lookup(email); err == nil && existing.Equal(cert) {
+			continue
+		}
+		if err := s.write(email, cert); err != nil {
+			return updated, err
+		}
+		updated = append(updated, strings.ToLower(email))
+	}
+	return updated, nil
+}
+
+// Add stores cert for email if the certificate is issued to that address
+// and none is stored for it yet. A stored certificate is never replaced,
+// so that a signed message can't change the key later mail is encrypted
+// to; use Import for that. It reports whether cert was stored.
+func (s *Store) Add(cert *x509.Certificate, email string) (bool, error) {
+	if !validAddress(email) || !hasAddress(cert, email) {
+		return false, nil
+	}
+	if _, err := s.Lookup(email); err == nil {
+		return false, nil
+	}
+	if err := s.write(email, cert); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// hasAddress reports whether cert is issued to email.
+func hasAddress(cert *x509.Certificate, email string) bool {
+	for _, e := range cert.EmailAddresses {
+		if strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) write(email string, cert *x509.Certificate) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create certificate store: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(s.path(email), data, 0600); err != nil {
+		return fmt.Errorf("failed to save certificate: %w", err)
+	}
+	return nil
+}
+
+// Lookup returns the certificate stored for email.
+func (s *Store) Lookup(email string) (*x509.Certificate, error) {
+	if !validAddress(email) {
+		return nil, fmt.Errorf("invalid email address: %s", email)
+	}
+	data, err := os.ReadFile(s.path(email))
+	if os.IsNotExist(err) {
+		return nil, &MissingCertError{Recipients: []string{email}}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	certs, err := ReadCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate in %s", s.path(email))
+	}
+	return certs[0], nil
+}
+
+// Recipients returns the certificates for emails. Missing certificates
+// are reported together in a *MissingCertError.
+func (s *Store) Recipients(emails []string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	var missing []string
+	for _, email := range emails {
+		cert, err := s.Lookup(email)
+		if _, ok := err.(*MissingCertError); ok {
+			missing = append(missing, email)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(missing) > 0 {
+		return nil, &MissingCertError{Recipients: missing}
+	}
+	return certs, nil
+}
+
+// List returns all stored certificates sorted by email.
+func (s *Store) List() ([]StoredCert, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate store: %w", err)
+	}
+
+	var list []StoredCert
+	for _, e := range entries {
+		email, ok := strings.CutSuffix(e.Name(), ".pem")
+		if !ok || e.IsDir() {
+			continue
+		}
+		cert, err := s.Lookup(email)
+		if err != nil {
+			continue
+		}
+		list = append(list, StoredCert{Email: email, CertInfo: Info(cert)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Email < list[j].Email })
+	return list, nil
+}
+
+// Delete removes the certificate stored for email.
+func (s *Store) Delete(email string) error {
+	err := os.Remove(s.path(email))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no certificate for %s", email)
+	}
+	return err
+}
+
+// MissingCertError is returned when recipients have no certificate.
+type MissingCertError struct {
+	Recipients []string
+}
+
+func (e *MissingCertError) Error() string {
+	return fmt.Sprintf("no S/MIME certificate for %s (import with 'sog smime import' or ask them for a signed message)", strings.Join(e.Recipients, ", "))
+}
@@ -6,11 +6,15 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"mime/quotedprintable"
+	"net/mail"
 	"strings"
 
 	"github.com/emersion/go-sasl"
 	"github.com/emersion/go-smtp"
+	"github.com/visionik/sogcli/internal/mimepart"
 )
 
 // Client wraps SMTP configuration.
@@ -56,7 +60,8 @@ func Connect(cfg Config) (*Client, error) {
 	return NewClient(cfg), nil
 }
 
-// Close closes the client (no-op for SMTP as connections are per-send).
+// Close closes the client (no-op for SMTP as connections are per-send;
+// see Dial for a reusable session).
 func (c *Client) Close() error {
 	return nil
 }
@@ -64,6 +69,8 @@ func (c *Client) Close() error {
 // Message represents an email to send.
 type Message struct {
 	From           string
+	FromName       string // Display name for the From header
+	ReplyTo        string
 	To             []string
 	Cc             []string
 	Bcc            []string
@@ -71,116 +78,98 @@ type Message struct {
 	Body           string
 	CalendarData   []byte // iCalendar attachment for invites
 	CalendarMethod string // iTIP method (REQUEST, REPLY, CANCEL)
-}
 
-// Send sends an email message.
-func (c *Client) Send(ctx context.Context, msg *Message) error {
-	addr := fmt.Sprintf("%s:%d", c.host, c.port)
+	// Entity replaces the MIME body built from Body and CalendarData.
+	// Set it to a signed or encrypted form of BuildEntity(msg).
+	Entity []byte
 
-	// Build recipients list
+	// Recipients replaces the envelope recipients, which are otherwise
+	// To, Cc and Bcc. Set it to deliver a copy to some recipients only.
+	Recipients []string
+}
+
+// recipients returns the envelope recipients of msg.
+func (msg *Message) recipients() []string {
+	if msg.Recipients != nil {
+		return msg.Recipients
+	}
 	recipients := make([]string, 0, len(msg.To)+len(msg.Cc)+len(msg.Bcc))
 	recipients = append(recipients, msg.To...)
 	recipients = append(recipients, msg.Cc...)
-	recipients = append(recipients, msg.Bcc...)
-
-	// Build email content
-	var content strings.Builder
-	content.WriteString(fmt.Sprintf("From: %s\r\n", msg.From))
-	content.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(msg.To, ", ")))
-	if len(msg.Cc) > 0 {
-		content.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(msg.Cc, ", ")))
-	}
-	content.WriteString(fmt.Sprintf("Subject: %s\r\n", msg.Subject))
-	content.WriteString("MIME-Version: 1.0\r\n")
-
-	// Handle calendar attachment (iMIP)
-	if len(msg.CalendarData) > 0 {
-		boundary := generateBoundary()
-		method := msg.CalendarMethod
-		if method == "" {
-			method = "REQUEST"
-		}
-
-		content.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=\"%s\"\r\n", boundary))
-		content.WriteString("\r\n")
-
-		// Text part
-		content.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		content.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
-		content.WriteString("\r\n")
-		content.WriteString(msg.Body)
-		content.WriteString("\r\n")
-
-		// Calendar part (inline for mail clients)
-		content.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		content.WriteString(fmt.Sprintf("Content-Type: text/calendar; charset=utf-8; method=%s\r\n", method))
-		content.WriteString("\r\n")
-		content.WriteString(string(msg.CalendarData))
-		content.WriteString("\r\n")
-
-		// Calendar attachment (for download)
-		content.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		content.WriteString(fmt.Sprintf("Content-Type: application/ics; name=\"invite.ics\"\r\n"))
-		content.WriteString("Content-Disposition: attachment; filename=\"invite.ics\"\r\n")
-		content.WriteString("Content-Transfer-Encoding: base64\r\n")
-		content.WriteString("\r\n")
-		content.WriteString(base64.StdEncoding.EncodeToString(msg.CalendarData))
-		content.WriteString("\r\n")
+	return append(recipients, msg.Bcc...)
+}
 
-		content.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
-	} else {
-		content.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
-		content.WriteString("\r\n")
-		content.WriteString(msg.Body)
+// Send sends an email message over a new connection.
+func (c *Client) Send(ctx context.Context, msg *Message) error {
+	session, err := c.Dial(ctx)
+	if err != nil {
+		return err
 	}
+	defer session.client.Close()
 
-	tlsConfig := &tls.Config{
-		ServerName:         c.host,
-		InsecureSkipVerify: c.insecure,
+	if err := session.Send(msg); err != nil {
+		return err
 	}
+	return session.Close()
+}
 
-	var client *smtp.Client
-	var err error
+// Session is an authenticated SMTP connection that can send several
+// messages. Use it instead of Client.Send when sending in bulk.
+type Session struct {
+	client *smtp.Client
+}
 
-	if c.noTLS {
-		client, err = smtp.Dial(addr)
-	} else if c.tls {
-		client, err = smtp.DialTLS(addr, tlsConfig)
-	} else if c.startTLS {
-		client, err = smtp.DialStartTLS(addr, tlsConfig)
-	} else {
-		client, err = smtp.Dial(addr)
-	}
+// Dial connects and authenticates, returning a reusable session.
+// The caller must Close the session.
+func (c *Client) Dial(ctx context.Context) (*Session, error) {
+	client, err := c.dial()
 	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+		return nil, err
 	}
-	defer client.Close()
 
 	// Authenticate
 	auth := sasl.NewPlainClient("", c.email, c.password)
 	if err := client.Auth(auth); err != nil {
-		return fmt.Errorf("failed to authenticate: %w", err)
+		client.Close()
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	return &Session{client: client}, nil
+}
+
+// Send sends a message on the session. If the server rejects the message
+// the transaction is reset so the session can be used for the next one.
+func (s *Session) Send(msg *Message) error {
+	if err := s.send(msg); err != nil {
+		_ = s.client.Reset()
+		return err
 	}
+	return nil
+}
+
+func (s *Session) send(msg *Message) error {
+	recipients := msg.recipients()
+	content := buildContent(msg)
 
 	// Set sender
-	if err := client.Mail(msg.From, nil); err != nil {
+	if err := s.client.Mail(msg.From, nil); err != nil {
 		return fmt.Errorf("failed to set sender: %w", err)
 	}
 
 	// Set recipients
 	for _, rcpt := range recipients {
-		if err := client.Rcpt(rcpt, nil); err != nil {
+		if err := s.client.Rcpt(rcpt, nil); err != nil {
 			return fmt.Errorf("failed to set recipient %s: %w", rcpt, err)
 		}
 	}
 
 	// Send data
-	wc, err := client.Data()
+	wc, err := s.client.Data()
 	if err != nil {
 		return fmt.Errorf("failed to start data: %w", err)
 	}
 
-	if _, err := wc.Write([]byte(content.String())); err != nil {
+	if _, err := wc.Write([]byte(content)); err != nil {
 		return fmt.Errorf("failed to write data: %w", err)
 	}
 
@@ -188,28 +177,40 @@ func (c *Client) Send(ctx context.Context, msg *Message) error {
 		return fmt.Errorf("failed to close data: %w", err)
 	}
 
-	return client.Quit()
+	return nil
 }
 
-// generateBoundary generates a random MIME boundary.
-func generateBoundary() string {
-	b := make([]byte, 16)
-	rand.Read(b)
-	return fmt.Sprintf("----=_Part_%x", b)
+// IsConnectionError reports whether an error from Session.Send means the
+// connection can no longer be used, rather than that the server refused
+// the message.
+func IsConnectionError(err error) bool {
+	var smtpErr *smtp.SMTPError
+	if errors.As(err, &smtpErr) {
+		// 421: the server is closing the connection
+		return smtpErr.Code == 421
+	}
+	return err != nil
 }
 
-// TestConnection tests the SMTP connection.
-func (c *Client) TestConnection() error {
-	addr := fmt.Sprintf("%s:%d", c.host, c.port)
+// Close ends the session with QUIT and closes the connection.
+func (s *Session) Close() error {
+	err := s.client.Quit()
+	s.client.Close()
+	return err
+}
 
-	var client *smtp.Client
-	var err error
+// dial opens a connection using the configured TLS mode.
+func (c *Client) dial() (*smtp.Client, error) {
+	addr := fmt.Sprintf("%s:%d", c.host, c.port)
 
 	tlsConfig := &tls.Config{
 		ServerName:         c.host,
 		InsecureSkipVerify: c.insecure,
 	}
 
+	var client *smtp.Client
+	var err error
+
 	if c.noTLS {
 		// Plain text connection
 		client, err = smtp.Dial(addr)
@@ -223,15 +224,132 @@ func (c *Client) TestConnection() error {
 		client, err = smtp.Dial(addr)
 	}
 	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
-	defer client.Close()
+	return client, nil
+}
 
-	// Authenticate
-	auth := sasl.NewPlainClient("", c.email, c.password)
-	if err := client.Auth(auth); err != nil {
-		return fmt.Errorf("failed to authenticate: %w", err)
+// buildContent renders the RFC 5322 message for msg.
+func buildContent(msg *Message) string {
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf("From: %s\r\n", formatAddress(msg.FromName, msg.From)))
+	if msg.ReplyTo != "" {
+		content.WriteString(fmt.Sprintf("Reply-To: %s\r\n", msg.ReplyTo))
+	}
+	content.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(msg.To, ", ")))
+	if len(msg.Cc) > 0 {
+		content.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(msg.Cc, ", ")))
+	}
+	content.WriteString(fmt.Sprintf("Subject: %s\r\n", msg.Subject))
+	content.WriteString("MIME-Version: 1.0\r\n")
+	content.Write(BuildEntity(msg))
+	return content.String()
+}
+
+// BuildEntity renders the MIME body entity for msg: its Content-* headers,
+// a blank line, and the body, with CRLF line endings. This is the part of
+// the message that PGP/MIME and S/MIME sign or encrypt.
+func BuildEntity(msg *Message) []byte {
+	if msg.Entity != nil {
+		return msg.Entity
 	}
 
-	return client.Quit()
+	var content strings.Builder
+
+	// Handle calendar attachment (iMIP)
+	if len(msg.CalendarData) > 0 {
+		boundary := generateBoundary()
+		method := msg.CalendarMethod
+		if method == "" {
+			method = "REQUEST"
+		}
+
+		content.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=\"%s\"\r\n", boundary))
+		content.WriteString("\r\n")
+
+		// Text part
+		content.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+		writeTextPart(&content, msg.Body)
+		content.WriteString("\r\n")
+
+		// Calendar part (inline for mail clients)
+		content.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+		content.WriteString(fmt.Sprintf("Content-Type: text/calendar; charset=utf-8; method=%s\r\n", method))
+		content.WriteString("\r\n")
+		content.WriteString(string(msg.CalendarData))
+		content.WriteString("\r\n")
+
+		// Calendar attachment (for download)
+		content.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+		content.WriteString("Content-Type: application/ics; name=\"invite.ics\"\r\n")
+		content.WriteString("Content-Disposition: attachment; filename=\"invite.ics\"\r\n")
+		content.WriteString("Content-Transfer-Encoding: base64\r\n")
+		content.WriteString("\r\n")
+		content.WriteString(base64.StdEncoding.EncodeToString(msg.CalendarData))
+		content.WriteString("\r\n")
+
+		content.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+	} else {
+		writeTextPart(&content, msg.Body)
+	}
+
+	return mimepart.Canonicalize([]byte(content.String()))
+}
+
+// writeTextPart writes a text/plain part. Bodies that would not survive
+// transport unchanged (8-bit characters, long lines, trailing whitespace)
+// are quoted-printable encoded so signatures over them stay valid.
+func writeTextPart(w *strings.Builder, body string) {
+	w.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	if !needsQuotedPrintable(body) {
+		w.WriteString("\r\n")
+		w.WriteString(body)
+		return
+	}
+
+	w.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	w.WriteString("\r\n")
+	qp := quotedprintable.NewWriter(w)
+	qp.Write([]byte(body))
+	qp.Close()
+}
+
+// needsQuotedPrintable reports whether body must be encoded to pass
+// through mail transport unmodified.
+func needsQuotedPrintable(body string) bool {
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		if len(line) > 76 || strings.HasSuffix(line, " ") || strings.HasSuffix(line, "\t") || strings.HasPrefix(line, "From ") {
+			return true
+		}
+		for i := 0; i < len(line); i++ {
+			if line[i] >= 0x80 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatAddress formats an address with an optional display name.
+func formatAddress(name, email string) string {
+	if name == "" {
+		return email
+	}
+	return (&mail.Address{Name: name, Address: email}).String()
+}
+
+// generateBoundary generates a random MIME boundary.
+func generateBoundary() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("----=_Part_%x", b)
+}
+
+// TestConnection tests the SMTP connection.
+func (c *Client) TestConnection() error {
+	session, err := c.Dial(context.Background())
+	if err != nil {
+		return err
+	}
+	return session.Close()
 }
@@ -1,9 +1,19 @@
 package smtp
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/emersion/go-sasl"
+	gosmtp "github.com/emersion/go-smtp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewClient(t *testing.T) {
@@ -77,4 +87,162 @@ func TestMessageRecipients(t *testing.T) {
 	assert.Len(t, msg.Bcc, 1)
 	assert.Equal(t, "Test", msg.Subject)
 	assert.Equal(t, "Hello", msg.Body)
+	assert.Equal(t, []string{"to1@example.com", "to2@example.com", "cc@example.com", "bcc@example.com"}, msg.recipients())
+
+	msg.Recipients = []string{"bcc@example.com"}
+	assert.Equal(t, []string{"bcc@example.com"}, msg.recipients())
+}
+
+func TestBuildContentIdentityHeaders(t *testing.T) {
+	msg := &Message{
+		From:     "sales@example.com",
+		FromName: "Sales Team",
+		ReplyTo:  "crm@example.com",
+		To:       []string{"bob@example.com"},
+		Subject:  "Quote",
+		Body:     "Hello",
+	}
+
+	content := buildContent(msg)
+
+	assert.Contains(t, content, "From: \"Sales Team\" <sales@example.com>\r\n")
+	assert.Contains(t, content, "Reply-To: crm@example.com\r\n")
+	assert.Contains(t, content, "To: bob@example.com\r\n")
+}
+
+func TestBuildContentPlainFrom(t *testing.T) {
+	content := buildContent(&Message{From: "me@example.com", To: []string{"bob@example.com"}})
+
+	assert.Contains(t, content, "From: me@example.com\r\n")
+	assert.NotContains(t, content, "Reply-To:")
+}
+
+// testBackend is an in-memory SMTP server backend that counts connections
+// and records delivered messages.
+type testBackend struct {
+	mu       sync.Mutex
+	conns    int
+	messages []string
+}
+
+func (b *testBackend) NewSession(_ *gosmtp.Conn) (gosmtp.Session, error) {
+	b.mu.Lock()
+	b.conns++
+	b.mu.Unlock()
+	return &testSession{backend: b}, nil
+}
+
+type testSession struct {
+	backend *testBackend
+}
+
+func (s *testSession) AuthMechanisms() []string { return []string{sasl.Plain} }
+
+func (s *testSession) Auth(mech string) (sasl.Server, error) {
+	return sasl.NewPlainServer(func(identity, username, password string) error {
+		if password != "secret" {
+			return errors.New("invalid credentials")
+		}
+		return nil
+	}), nil
+}
+
+func (s *testSession) Mail(from string, _ *gosmtp.MailOptions) error { return nil }
+
+func (s *testSession) Rcpt(to string, _ *gosmtp.RcptOptions) error {
+	if strings.HasPrefix(to, "reject") {
+		return &gosmtp.SMTPError{Code: 550, Message: "no such user"}
+	}
+	return nil
+}
+
+func (s *testSession) Data(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.backend.mu.Lock()
+	s.backend.messages = append(s.backend.messages, string(data))
+	s.backend.mu.Unlock()
+	return nil
+}
+
+func (s *testSession) Reset()        {}
+func (s *testSession) Logout() error { return nil }
+
+func startTestServer(t *testing.T) (*testBackend, Config) {
+	t.Helper()
+	be := &testBackend{}
+	srv := gosmtp.NewServer(be)
+	srv.AllowInsecureAuth = true
+	srv.Domain = "localhost"
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return be, Config{
+		Host:     "127.0.0.1",
+		Port:     addr.Port,
+		NoTLS:    true,
+		Email:    "me@example.com",
+		Password: "secret",
+	}
+}
+
+func TestSessionReusesConnection(t *testing.T) {
+	be, cfg := startTestServer(t)
+	client := NewClient(cfg)
+
+	session, err := client.Dial(context.Background())
+	require.NoError(t, err)
+
+	for _, to := range []string{"a@example.com", "reject@example.com", "b@example.com"} {
+		err := session.Send(&Message{
+			From:    "me@example.com",
+			To:      []string{to},
+			Subject: "Hello",
+			Body:    "Hi",
+		})
+		if strings.HasPrefix(to, "reject") {
+			assert.Error(t, err, "rejected recipient")
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+	require.NoError(t, session.Close())
+
+	assert.Equal(t, 1, be.conns)
+	require.Len(t, be.messages, 2)
+	assert.Contains(t, be.messages[1], "To: b@example.com")
+}
+
+func TestIsConnectionError(t *testing.T) {
+	_, cfg := startTestServer(t)
+	session, err := NewClient(cfg).Dial(context.Background())
+	require.NoError(t, err)
+
+	msg := &Message{From: "me@example.com", To: []string{"reject@example.com"}, Subject: "Hello", Body: "Hi"}
+	err = session.Send(msg)
+	require.Error(t, err)
+	assert.False(t, IsConnectionError(err), "rejected recipient")
+
+	session.client.Close()
+	msg.To = []string{"a@example.com"}
+	err = session.Send(msg)
+	require.Error(t, err)
+	assert.True(t, IsConnectionError(err), "closed connection")
+
+	assert.False(t, IsConnectionError(nil))
+	assert.True(t, IsConnectionError(fmt.Errorf("failed to start data: %w", &gosmtp.SMTPError{Code: 421})))
+}
+
+func TestClientSendAuthFailure(t *testing.T) {
+	_, cfg := startTestServer(t)
+	cfg.Password = "wrong"
+
+	err := NewClient(cfg).Send(context.Background(), &Message{From: "me@example.com", To: []string{"a@example.com"}})
+	assert.ErrorContains(t, err, "failed to authenticate")
 }
@@ -0,0 +1,134 @@
+package taskquery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+// sortKey is one key of a sort order.
+type sortKey struct {
+	field      string
+	descending bool
+}
+
+// Order is a parsed sort order such as "due,priority" or "-priority,due".
+type Order []sortKey
+
+// sortFields are the fields tasks can be sorted by.
+var sortFields = map[string]bool{
+	"due": true, "start": true, "completed": true, "priority": true, "percent": true,
+	"summary": true, "status": true, "list": true, "account": true,
+}
+
+// ParseOrder parses comma-separated sort keys. A leading "-" reverses a
+// key. Field names are those of filter expressions.
+func ParseOrder(s string) (Order, error) {
+	var order Order
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		key := sortKey{}
+		if strings.HasPrefix(part, "-") {
+			key.descending = true
+			part = part[1:]
+		}
+		key.field = fieldNames[strings.TrimPrefix(part, "+")]
+		if !sortFields[key.field] {
+			return nil, fmt.Errorf("cannot sort by %q (use due, start, completed, priority, percent, summary, status, list or account)", part)
+		}
+		order = append(order, key)
+	}
+	return order, nil
+}
+
+// Sort sorts tasks by the order's keys, keeping the original order of
+// tasks that compare equal. Tasks without a date or priority come last
+// whichever the direction.
+func (o Order) Sort(tasks []Task) {
+	if len(o) == 0 {
+		return
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		for _, key := range o {
+			if c := compareBy(key, &tasks[i], &tasks[j]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// compareBy compares two tasks by one key.
+func compareBy(key sortKey, a, b *Task) int {
+	switch key.field {
+	case "due":
+		return compareTimes(a.Due, b.Due, key.descending)
+	case "start":
+		return compareTimes(a.Start, b.Start, key.descending)
+	case "completed":
+		return compareTimes(a.Completed, b.Completed, key.descending)
+	case "priority":
+		return compareSet(a.Priority, b.Priority, a.Priority > 0, b.Priority > 0, key.descending)
+	case "percent":
+		return direction(a.Percent-b.Percent, key.descending)
+	case "status":
+		return direction(statusRank(a.Status)-statusRank(b.Status), key.descending)
+	case "summary":
+		return direction(strings.Compare(strings.ToLower(a.Summary), strings.ToLower(b.Summary)), key.descending)
+	case "list":
+		return direction(strings.Compare(strings.ToLower(a.List), strings.ToLower(b.List)), key.descending)
+	case "account":
+		return direction(strings.Compare(a.Account, b.Account), key.descending)
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time, descending bool) int {
+	c := 0
+	switch {
+	case a.Before(b):
+		c = -1
+	case a.After(b):
+		c = 1
+	}
+	return compareSet(c, 0, !a.IsZero(), !b.IsZero(), descending)
+}
+
+// compareSet compares a and b, putting unset values last.
+func compareSet(a, b int, aSet, bSet bool, descending bool) int {
+	switch {
+	case aSet && !bSet:
+		return -1
+	case !aSet && bSet:
+		return 1
+	case !aSet && !bSet:
+		return 0
+	}
+	return direction(a-b, descending)
+}
+
+func direction(c int, descending bool) int {
+	if descending {
+		return -c
+	}
+	return c
+}
+
+// statusRank orders tasks in progress first and finished ones last.
+func statusRank(status string) int {
+	switch status {
+	case caldav.TaskStatusInProcess:
+		return 0
+	case caldav.TaskStatusCompleted:
+		return 2
+	case caldav.TaskStatusCancelled:
+		return 3
+	}
+	return 1
+}
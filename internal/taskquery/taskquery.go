@@ -0,0 +1,513 @@
+// Package taskquery filters and sorts tasks with the expressions accepted
+// by 'sog tasks list --where ... --sort ...' and saved task views:
+//
+//	priority<=3 and category:work and due<+7d and status!=completed
+//	(due<today or status=doing) and not list:someday
+//
+// Conditions are joined with "and" (also implied between adjacent
+// conditions), "or" and "not", and grouped with parentheses. Values with
+// spaces are quoted: due<"next friday".
+//
+// Like dateexpr, dates are resolved against a reference time passed by the
+// caller, so results are reproducible in tests.
+package taskquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/visionik/sogcli/internal/caldav"
+	"github.com/visionik/sogcli/internal/dateexpr"
+)
+
+// Task is a task with the list and account it was read from.
+type Task struct {
+	caldav.Task
+	List     string // Name of the task list, or its path
+	ListPath string
+	Account  string
+}
+
+// Filter is a parsed filter expression.
+type Filter struct {
+	root   node
+	fields map[string]bool // Fields the expression refers to
+}
+
+// Match reports whether t passes the filter. A nil filter passes all.
+func (f *Filter) Match(t *Task) bool {
+	return f == nil || f.root.match(t)
+}
+
+// Uses reports whether the expression refers to a field, by its canonical
+// name (e.g. "status" for "status" or "state").
+func (f *Filter) Uses(field string) bool {
+	return f != nil && f.fields[field]
+}
+
+// fieldNames maps the field names accepted in expressions to their
+// canonical names.
+var fieldNames = map[string]string{
+	"priority": "priority", "pri": "priority",
+	"percent": "percent", "progress": "percent",
+	"due": "due", "start": "start", "completed": "completed",
+	"status": "status", "state": "status",
+	"category": "category", "categories": "category", "cat": "category", "tag": "category",
+	"summary": "summary", "title": "summary",
+	"text":    "text",
+	"list":    "list",
+	"account": "account",
+	"has":     "has",
+	"uid":     "uid",
+	"parent":  "parent",
+}
+
+// statusNames maps status words to VTODO STATUS values. "open" is any
+// status but completed and cancelled.
+var statusNames = map[string]string{
+	"needs-action": caldav.TaskStatusNeedsAction, "todo": caldav.TaskStatusNeedsAction,
+	"in-process": caldav.TaskStatusInProcess, "doing": caldav.TaskStatusInProcess,
+	"completed": caldav.TaskStatusCompleted, "done": caldav.TaskStatusCompleted,
+	"cancelled": caldav.TaskStatusCancelled, "canceled": caldav.TaskStatusCancelled,
+	"open": "open",
+}
+
+// Parse parses a filter expression. Dates are resolved against now, in
+// now's location.
+func Parse(expr string, now time.Time) (*Filter, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, now: now, fields: map[string]bool{}}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter expression")
+	}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter expression", p.tokens[p.pos].text)
+	}
+	return &Filter{root: root, fields: p.fields}, nil
+}
+
+// Tokens
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokOp
+	tokOpen
+	tokClose
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	quoted bool
+}
+
+// lex splits an expression into words, operators and parentheses. The
+// value after an operator, with or without spaces between them, runs to
+// the next space or parenthesis, so it may itself contain operator
+// characters (due < 2026-10-20T10:00).
+func lex(s string) ([]token, error) {
+	var tokens []token
+	afterOp := false
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, token{kind: tokOpen, text: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokClose, text: ")"})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in filter expression")
+			}
+			tokens = append(tokens, token{kind: tokWord, text: s[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		case !afterOp && strings.IndexByte("<>=!:", c) >= 0:
+			op := string(c)
+			if i+1 < len(s) && s[i+1] == '=' && c != '=' && c != ':' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unknown operator '!' in filter expression (use != or not)")
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op})
+			i += len(op)
+			afterOp = true
+			continue
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t\n()\"'", s[j]) < 0 &&
+				(afterOp || strings.IndexByte("<>=!:", s[j]) < 0) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokWord, text: s[i:j]})
+			i = j
+		}
+		afterOp = false
+	}
+	return tokens, nil
+}
+
+// Parser
+
+type parser struct {
+	tokens []token
+	pos    int
+	now    time.Time
+	fields map[string]bool
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// keyword reports whether the next token is the unquoted keyword kw.
+func (p *parser) keyword(kw string) bool {
+	t := p.peek()
+	return t != nil && t.kind == tokWord && !t.quoted && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t == nil || t.kind == tokClose || p.keyword("or") {
+			return left, nil
+		}
+		if p.keyword("and") {
+			p.pos++
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	t := p.peek()
+	switch {
+	case t == nil:
+		return nil, fmt.Errorf("filter expression ends too early")
+	case p.keyword("not"):
+		p.pos++
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	case t.kind == tokOpen:
+		p.pos++
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t == nil || t.kind != tokClose {
+			return nil, fmt.Errorf("missing ')' in filter expression")
+		}
+		p.pos++
+		return n, nil
+	case t.kind != tokWord:
+		return nil, fmt.Errorf("unexpected %q in filter expression", t.text)
+	}
+	p.pos++
+
+	// A word on its own searches the summary and description
+	op := p.peek()
+	if op == nil || op.kind != tokOp {
+		p.fields["text"] = true
+		return textNode{strings.ToLower(t.text)}, nil
+	}
+	p.pos++
+	value := p.peek()
+	if value == nil || value.kind != tokWord {
+		return nil, fmt.Errorf("missing value after %s%s", t.text, op.text)
+	}
+	p.pos++
+	return p.condition(strings.ToLower(t.text), op.text, value.text)
+}
+
+// condition builds the node for "field op value".
+func (p *parser) condition(name, op, value string) (node, error) {
+	field, ok := fieldNames[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q in filter expression", name)
+	}
+	p.fields[field] = true
+
+	switch field {
+	case "priority", "percent":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: not a number", field, value)
+		}
+		if op == ":" {
+			op = "="
+		}
+		return numberNode{field: field, op: op, value: n}, nil
+	case "due", "start", "completed":
+		if op == ":" {
+			op = "="
+		}
+		t, dateOnly, err := dateexpr.Parse(value, p.now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s date %q: %w", field, value, err)
+		}
+		return dateNode{field: field, op: op, at: t, dateOnly: dateOnly}, nil
+	case "status":
+		status, ok := statusNames[strings.ToLower(value)]
+		if !ok {
+			return nil, fmt.Errorf("unknown status %q (use todo, doing, done, cancelled or open)", value)
+		}
+		return stringNode{field: field, op: equality(op), value: status}.check()
+	case "has":
+		if op != ":" && op != "=" {
+			return nil, fmt.Errorf("use has:<field>, e.g. has:due")
+		}
+		if _, ok := hasFields[strings.ToLower(value)]; !ok {
+			return nil, fmt.Errorf("unknown field in has:%s", value)
+		}
+		return hasNode{strings.ToLower(value)}, nil
+	case "text":
+		if op != ":" && op != "=" {
+			return nil, fmt.Errorf("use text:<words>")
+		}
+		return textNode{strings.ToLower(value)}, nil
+	}
+	return stringNode{field: field, op: op, value: value}.check()
+}
+
+// equality maps ":" to "=" for fields that are compared as a whole.
+func equality(op string) string {
+	if op == ":" {
+		return "="
+	}
+	return op
+}
+
+// Nodes
+
+type node interface {
+	match(t *Task) bool
+}
+
+type andNode struct{ a, b node }
+
+func (n andNode) match(t *Task) bool { return n.a.match(t) && n.b.match(t) }
+
+type orNode struct{ a, b node }
+
+func (n orNode) match(t *Task) bool { return n.a.match(t) || n.b.match(t) }
+
+type notNode struct{ n node }
+
+func (n notNode) match(t *Task) bool { return !n.n.match(t) }
+
+// textNode matches tasks whose summary or description contains a text,
+// ignoring case.
+type textNode struct{ text string }
+
+func (n textNode) match(t *Task) bool {
+	return strings.Contains(strings.ToLower(t.Summary), n.text) ||
+		strings.Contains(strings.ToLower(t.Description), n.text)
+}
+
+// numberNode compares priority or percent. A task without a priority
+// (0) never passes an ordering comparison, so priority<=3 means "set and
+// at most 3".
+type numberNode struct {
+	field string
+	op    string
+	value int
+}
+
+func (n numberNode) match(t *Task) bool {
+	v := t.Percent
+	if n.field == "priority" {
+		v = t.Priority
+		if v == 0 && n.op != "=" && n.op != "!=" {
+			return false
+		}
+	}
+	return compare(v, n.value, n.op)
+}
+
+// dateNode compares due, start or completed. A day (today, friday, +7d)
+// stands for the whole day: due<=friday includes Friday and due=friday is
+// any time on Friday. Tasks without the date never match.
+type dateNode struct {
+	field    string
+	op       string
+	at       time.Time
+	dateOnly bool
+}
+
+func (n dateNode) match(t *Task) bool {
+	var v time.Time
+	switch n.field {
+	case "due":
+		v = t.Due
+	case "start":
+		v = t.Start
+	case "completed":
+		v = t.Completed
+	}
+	if v.IsZero() {
+		return false
+	}
+	start, end := n.at, n.at
+	if n.dateOnly {
+		end = n.at.AddDate(0, 0, 1)
+	}
+	switch n.op {
+	case "<":
+		return v.Before(start)
+	case "<=":
+		return v.Before(end) || (!n.dateOnly && v.Equal(end))
+	case ">":
+		return !v.Before(end) && (n.dateOnly || v.After(end))
+	case ">=":
+		return !v.Before(start)
+	case "=":
+		if n.dateOnly {
+			return !v.Before(start) && v.Before(end)
+		}
+		return v.Equal(start)
+	case "!=":
+		return !dateNode{field: n.field, op: "=", at: n.at, dateOnly: n.dateOnly}.match(t)
+	}
+	return false
+}
+
+// stringNode matches status, category, summary, list, account, uid or
+// parent. "=" compares the whole value and ":" looks for a part of it,
+// both ignoring case; categories and status are always compared whole.
+type stringNode struct {
+	field string
+	op    string
+	value string
+}
+
+// check rejects ordering operators, which strings do not support.
+func (n stringNode) check() (node, error) {
+	switch n.op {
+	case "=", "!=", ":":
+		return n, nil
+	}
+	return nil, fmt.Errorf("%s does not support %s (use =, != or :)", n.field, n.op)
+}
+
+func (n stringNode) match(t *Task) bool {
+	var values []string
+	switch n.field {
+	case "status":
+		if n.value == "open" {
+			open := t.Status != caldav.TaskStatusCompleted && t.Status != caldav.TaskStatusCancelled
+			return open == (n.op == "=")
+		}
+		values = []string{t.Status}
+	case "category":
+		values = t.Categories
+	case "summary":
+		values = []string{t.Summary}
+	case "list":
+		values = []string{t.List, strings.TrimSuffix(t.ListPath, "/")}
+	case "account":
+		values = []string{t.Account}
+	case "uid":
+		values = []string{t.UID}
+	case "parent":
+		values = []string{t.Parent}
+	}
+
+	value := n.value
+	if n.field == "list" {
+		value = strings.TrimSuffix(value, "/")
+	}
+	found := false
+	for _, v := range values {
+		if strings.EqualFold(v, value) ||
+			(n.op == ":" && n.field != "category" && n.field != "status" &&
+				strings.Contains(strings.ToLower(v), strings.ToLower(value))) {
+			found = true
+			break
+		}
+	}
+	return found == (n.op != "!=")
+}
+
+// hasFields are the fields has: can test, with how.
+var hasFields = map[string]func(t *Task) bool{
+	"due":         func(t *Task) bool { return !t.Due.IsZero() },
+	"start":       func(t *Task) bool { return !t.Start.IsZero() },
+	"priority":    func(t *Task) bool { return t.Priority > 0 },
+	"description": func(t *Task) bool { return t.Description != "" },
+	"category":    func(t *Task) bool { return len(t.Categories) > 0 },
+	"categories":  func(t *Task) bool { return len(t.Categories) > 0 },
+	"reminders":   func(t *Task) bool { return len(t.Alarms) > 0 },
+	"parent":      func(t *Task) bool { return t.Parent != "" },
+	"children":    func(t *Task) bool { return len(t.Children) > 0 },
+	"deps":        func(t *Task) bool { return len(t.DependsOn) > 0 },
+	"repeat":      func(t *Task) bool { return t.IsRecurring() },
+}
+
+type hasNode struct{ field string }
+
+func (n hasNode) match(t *Task) bool { return hasFields[n.field](t) }
+
+func compare(a, b int, op string) bool {
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	}
+	return false
+}
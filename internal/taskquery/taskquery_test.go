@@ -0,0 +1,131 @@
+package taskquery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+// Sunday afternoon
+var now = time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+
+func fixture() []Task {
+	day := func(d, hour int) time.Time { return time.Date(2026, 10, d, hour, 0, 0, 0, time.UTC) }
+	task := func(uid string, pri int, due time.Time, status string, cats ...string) Task {
+		return Task{
+			Task:     caldav.Task{UID: uid, Summary: "Task " + uid, Priority: pri, Due: due, Status: status, Categories: cats},
+			List:     "Work",
+			ListPath: "/cal/work/",
+			Account:  "me@example.com",
+		}
+	}
+	tasks := []Task{
+		task("report", 1, day(20, 17), caldav.TaskStatusNeedsAction, "work"),
+		task("slides", 5, day(24, 9), caldav.TaskStatusInProcess, "work", "talk"),
+		task("taxes", 2, day(30, 23), caldav.TaskStatusNeedsAction),
+		task("old", 3, day(10, 12), caldav.TaskStatusCompleted, "Work"),
+		task("someday", 0, time.Time{}, caldav.TaskStatusNeedsAction),
+		task("today", 9, day(18, 18), caldav.TaskStatusCancelled),
+	}
+	tasks[2].List, tasks[2].ListPath = "Home", "/cal/home/"
+	tasks[4].Description = "Learn the banjo"
+	return tasks
+}
+
+func matching(t *testing.T, expr string) []string {
+	t.Helper()
+	f, err := Parse(expr, now)
+	require.NoError(t, err, expr)
+	var uids []string
+	for _, task := range fixture() {
+		if f.Match(&task) {
+			uids = append(uids, task.UID)
+		}
+	}
+	return uids
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"priority<=3 and category:work and due<+7d and status!=completed", []string{"report"}},
+		{"priority<=3", []string{"report", "taxes", "old"}},
+		{"priority = 0", []string{"someday"}},
+		{"category:WORK", []string{"report", "slides", "old"}},
+		{"due<=friday", []string{"report", "old", "today"}},
+		{"due=2026-10-24", []string{"slides"}},
+		{"due>2026-10-24", []string{"taxes"}},
+		{"due < 2026-10-20T18:00", []string{"report", "old", "today"}},
+		{"due: 2026-10-24 or summary: 'Task old'", []string{"slides", "old"}},
+		{"due<now", []string{"old"}},
+		{"status=open", []string{"report", "slides", "taxes", "someday"}},
+		{"status:doing or list:home", []string{"slides", "taxes"}},
+		{"list=/cal/home", []string{"taxes"}},
+		{"not has:due", []string{"someday"}},
+		{"banjo", []string{"someday"}},
+		{"summary:sl", []string{"slides"}},
+		{"(due<today or priority>=9) and status!=cancelled", []string{"old"}},
+		{"category:work category:talk", []string{"slides"}},
+		{`due<"next friday" status=todo`, []string{"report"}},
+		{"account:me@example.com and uid=taxes", []string{"taxes"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matching(t, tt.expr), tt.expr)
+	}
+}
+
+func TestFilterUses(t *testing.T) {
+	f, err := Parse("state!=done and pri<3", now)
+	require.NoError(t, err)
+	assert.True(t, f.Uses("status"))
+	assert.True(t, f.Uses("priority"))
+	assert.False(t, f.Uses("due"))
+	assert.True(t, (*Filter)(nil).Match(&Task{}))
+}
+
+func TestFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"colour=red",
+		"priority<=high",
+		"due<someday-ish",
+		"status=sleeping",
+		"summary<abc",
+		"(priority<3",
+		"priority<3)",
+		"priority<",
+		"has:wings",
+		`summary:"open`,
+		"not",
+	} {
+		_, err := Parse(expr, now)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	sorted := func(keys string) []string {
+		order, err := ParseOrder(keys)
+		require.NoError(t, err)
+		tasks := fixture()
+		order.Sort(tasks)
+		var uids []string
+		for _, task := range tasks {
+			uids = append(uids, task.UID)
+		}
+		return uids
+	}
+	assert.Equal(t, []string{"old", "today", "report", "slides", "taxes", "someday"}, sorted("due"))
+	assert.Equal(t, []string{"taxes", "slides", "report", "today", "old", "someday"}, sorted("-due"))
+	assert.Equal(t, []string{"report", "taxes", "old", "slides", "today", "someday"}, sorted("priority"))
+	assert.Equal(t, []string{"slides", "report", "taxes", "someday", "old", "today"}, sorted("status, pri"))
+	assert.Equal(t, []string{"taxes", "today", "slides", "old", "report", "someday"}, sorted("list,-priority"))
+
+	_, err := ParseOrder("colour")
+	assert.Error(t, err)
+}
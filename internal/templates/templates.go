@@ -0,0 +1,277 @@
+// Package templates renders message templates stored in the sog config dir.
+//
+// A template is a Go text/template file with an optional front-matter block
+// that sets message headers:
+//
+//	---
+//	Subject: Weekly status, week {{.Vars.week}}
+//	To: team@example.com
+//	Cc: lead@example.com
+//	---
+//	Hi {{default "team" .Contact.FirstName}},
+//	...
+//
+// Header values are templates too, rendered with the same data as the body.
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/visionik/sogcli/internal/carddav"
+	"github.com/visionik/sogcli/internal/config"
+)
+
+// extensions are tried in order when resolving a template name.
+var extensions = []string{"", ".tmpl", ".txt"}
+
+// Template is a parsed message template.
+type Template struct {
+	Name    string
+	subject *template.Template
+	to      *template.Template
+	cc      *template.Template
+	bcc     *template.Template
+	body    *template.Template
+}
+
+// Data is the data available to a template.
+type Data struct {
+	Vars       map[string]string // --var key=value pairs or CSV columns
+	Contact    carddav.Contact   // Recipient's contact card (zero if unknown)
+	HasContact bool              // Whether Contact was found
+	Recipient  string            // Primary recipient address
+	From       config.Identity   // Sending identity
+	Date       time.Time         // Render time
+}
+
+// Rendered is the output of rendering a template.
+type Rendered struct {
+	Subject string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Body    string
+}
+
+// Dir returns the templates directory (~/.config/sog/templates).
+func Dir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+// List returns the names of available templates.
+func List() ([]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates dir: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		name := e.Name()
+		for _, ext := range extensions[1:] {
+			name = strings.TrimSuffix(name, ext)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Load reads and parses a template by name from the templates directory.
+// A path to a file may also be given.
+func Load(name string) (*Template, error) {
+	if strings.ContainsRune(name, filepath.Separator) {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		return Parse(filepath.Base(name), string(data))
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ext := range extensions {
+		data, err := os.ReadFile(filepath.Join(dir, name+ext))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		return Parse(name, string(data))
+	}
+
+	return nil, fmt.Errorf("template not found: %s (looked in %s)", name, dir)
+}
+
+// Parse parses template source with optional front-matter.
+func Parse(name, src string) (*Template, error) {
+	headers, body, err := splitFrontMatter(src)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", name, err)
+	}
+
+	t := &Template{Name: name}
+	parse := func(field, text string) (*template.Template, error) {
+		tmpl, err := template.New(name + ":" + field).
+			Funcs(funcs).
+			Option("missingkey=error").
+			Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		return tmpl, nil
+	}
+
+	if t.subject, err = parse("subject", headers["subject"]); err != nil {
+		return nil, err
+	}
+	if t.to, err = parse("to", headers["to"]); err != nil {
+		return nil, err
+	}
+	if t.cc, err = parse("cc", headers["cc"]); err != nil {
+		return nil, err
+	}
+	if t.bcc, err = parse("bcc", headers["bcc"]); err != nil {
+		return nil, err
+	}
+	if t.body, err = parse("body", body); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Render executes the template with data.
+func (t *Template) Render(data Data) (*Rendered, error) {
+	if data.Vars == nil {
+		data.Vars = map[string]string{}
+	}
+	if data.Date.IsZero() {
+		data.Date = time.Now()
+	}
+
+	exec := func(tmpl *template.Template) (string, error) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("failed to render template: %w", err)
+		}
+		return buf.String(), nil
+	}
+
+	r := &Rendered{}
+	var err error
+	var to, cc, bcc string
+	if r.Subject, err = exec(t.subject); err != nil {
+		return nil, err
+	}
+	if to, err = exec(t.to); err != nil {
+		return nil, err
+	}
+	if cc, err = exec(t.cc); err != nil {
+		return nil, err
+	}
+	if bcc, err = exec(t.bcc); err != nil {
+		return nil, err
+	}
+	if r.Body, err = exec(t.body); err != nil {
+		return nil, err
+	}
+
+	r.Subject = strings.TrimSpace(r.Subject)
+	r.To = splitList(to)
+	r.Cc = splitList(cc)
+	r.Bcc = splitList(bcc)
+	return r, nil
+}
+
+// splitFrontMatter separates a leading "---" header block from the body.
+func splitFrontMatter(src string) (map[string]string, string, error) {
+	headers := map[string]string{}
+	src = strings.TrimPrefix(src, "\ufeff")
+	if !strings.HasPrefix(src, "---\n") && !strings.HasPrefix(src, "---\r\n") {
+		return headers, src, nil
+	}
+
+	// Lines are split on "\n" only, so the body keeps its line endings
+	_, rest, _ := strings.Cut(src, "\n") // opening ---
+	for rest != "" {
+		var line string
+		line, rest, _ = strings.Cut(rest, "\n")
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "---" {
+			return headers, rest, nil
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, "", fmt.Errorf("invalid front-matter line: %q", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		switch key {
+		case "subject", "to", "cc", "bcc":
+			headers[key] = strings.TrimSpace(value)
+		default:
+			return nil, "", fmt.Errorf("unknown front-matter field: %s", key)
+		}
+	}
+
+	return nil, "", fmt.Errorf("unterminated front-matter (missing closing ---)")
+}
+
+// splitList splits a comma-separated address list.
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// funcs are the helper functions available in templates.
+var funcs = template.FuncMap{
+	// default returns def when value is empty: {{default "there" .Contact.FirstName}}
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	// date formats a time with a Go layout: {{date "2006-01-02" .Date}}
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+}
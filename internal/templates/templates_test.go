@@ -0,0 +1,132 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/visionik/sogcli/internal/carddav"
+	"github.com/visionik/sogcli/internal/config"
+)
+
+const weeklyStatus = `---
+Subject: Weekly status, week {{.Vars.week}}
+To: team@example.com, lead@example.com
+Cc: {{.From.Email}}
+---
+Hi {{default "team" .Contact.FirstName}},
+
+Status for week {{.Vars.week}}.
+`
+
+func TestParseAndRender(t *testing.T) {
+	tmpl, err := Parse("weekly-status", weeklyStatus)
+	require.NoError(t, err)
+
+	r, err := tmpl.Render(Data{
+		Vars: map[string]string{"week": "42"},
+		From: config.Identity{Email: "me@example.com"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Weekly status, week 42", r.Subject)
+	assert.Equal(t, []string{"team@example.com", "lead@example.com"}, r.To)
+	assert.Equal(t, []string{"me@example.com"}, r.Cc)
+	assert.Nil(t, r.Bcc)
+	assert.Equal(t, "Hi team,\n\nStatus for week 42.\n", r.Body)
+}
+
+func TestRenderWithContact(t *testing.T) {
+	tmpl, err := Parse("hello", "Hello {{.Contact.FirstName}} at {{.Contact.Org}}")
+	require.NoError(t, err)
+
+	r, err := tmpl.Render(Data{
+		Contact:    carddav.Contact{FirstName: "Alice", Org: "Acme"},
+		HasContact: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello Alice at Acme", r.Body)
+	assert.Empty(t, r.Subject)
+}
+
+func TestRenderMissingVar(t *testing.T) {
+	tmpl, err := Parse("t", "Week {{.Vars.week}}")
+	require.NoError(t, err)
+
+	_, err = tmpl.Render(Data{})
+	assert.Error(t, err)
+}
+
+func TestRenderDateFunc(t *testing.T) {
+	tmpl, err := Parse("t", `{{date "2006-01-02" .Date}}`)
+	require.NoError(t, err)
+
+	r, err := tmpl.Render(Data{Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	assert.Equal(t, "2026-03-01", r.Body)
+}
+
+func TestParseCRLF(t *testing.T) {
+	src := strings.ReplaceAll(weeklyStatus, "\n", "\r\n")
+	tmpl, err := Parse("weekly-status", src)
+	require.NoError(t, err)
+
+	r, err := tmpl.Render(Data{Vars: map[string]string{"week": "42"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Weekly status, week 42", r.Subject)
+	assert.Equal(t, []string{"team@example.com", "lead@example.com"}, r.To)
+	assert.Equal(t, "Hi team,\r\n\r\nStatus for week 42.\r\n", r.Body)
+}
+
+func TestParseFrontMatterErrors(t *testing.T) {
+	_, err := Parse("t", "---\nSubject: x\n")
+	assert.ErrorContains(t, err, "unterminated")
+
+	_, err = Parse("t", "---\nFoo: x\n---\nbody")
+	assert.ErrorContains(t, err, "unknown front-matter field")
+
+	_, err = Parse("t", "---\nnot a header\n---\nbody")
+	assert.ErrorContains(t, err, "invalid front-matter line")
+}
+
+func TestParseNoFrontMatter(t *testing.T) {
+	tmpl, err := Parse("t", "just a body")
+	require.NoError(t, err)
+
+	r, err := tmpl.Render(Data{})
+	require.NoError(t, err)
+	assert.Equal(t, "just a body", r.Body)
+	assert.Nil(t, r.To)
+}
+
+func TestLoadAndList(t *testing.T) {
+	tmpDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	names, err := List()
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	dir, err := Dir()
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "weekly-status.tmpl"), []byte(weeklyStatus), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plain"), []byte("hi"), 0600))
+
+	names, err = List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"plain", "weekly-status"}, names)
+
+	tmpl, err := Load("weekly-status")
+	require.NoError(t, err)
+	assert.Equal(t, "weekly-status", tmpl.Name)
+
+	_, err = Load("missing")
+	assert.ErrorContains(t, err, "template not found")
+}
@@ -0,0 +1,249 @@
+// Package timezone maps iCalendar time zones (TZID parameters and VTIMEZONE
+// components) to Go locations and back.
+//
+// Times are written with the IANA name of their location as TZID and a
+// VTIMEZONE generated from the Go time zone database. When reading, TZIDs
+// that are not IANA names (as sent by Outlook and others) are resolved
+// from the VTIMEZONE in the same calendar; see Register.
+package timezone
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+const localFormat = "20060102T150405"
+
+var (
+	systemOnce sync.Once
+	system     *time.Location
+)
+
+// System returns the local time zone under its IANA name, so that it can
+// be written as a TZID. If the name cannot be determined, time.Local is
+// returned.
+func System() *time.Location {
+	systemOnce.Do(func() {
+		system = detectSystem()
+	})
+	return system
+}
+
+func detectSystem() *time.Location {
+	name, set := os.LookupEnv("TZ")
+	name = strings.TrimPrefix(name, ":")
+	if set && name == "" {
+		return time.UTC
+	}
+	if name == "" {
+		if target, err := os.Readlink("/etc/localtime"); err == nil {
+			name = target
+		} else if data, err := os.ReadFile("/etc/timezone"); err == nil {
+			name = strings.TrimSpace(string(data))
+		}
+	}
+	if i := strings.Index(name, "zoneinfo/"); i >= 0 {
+		name = name[i+len("zoneinfo/"):]
+	}
+	if name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// Load returns the location for a time zone name given by the user. An
+// empty name or "local" is the system zone.
+func Load(name string) (*time.Location, error) {
+	switch strings.ToLower(name) {
+	case "", "local":
+		return System(), nil
+	case "utc", "z":
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone: %s", name)
+	}
+	return loc, nil
+}
+
+// TZID returns the TZID to write for times in loc, or "" if they should be
+// written in UTC.
+func TZID(loc *time.Location) string {
+	if loc == time.Local {
+		loc = System()
+	}
+	if loc == nil || loc == time.UTC || loc == time.Local || loc.String() == "UTC" {
+		return ""
+	}
+	return loc.String()
+}
+
+// SetTime sets a DATE-TIME property to t: with a TZID for times in a named
+// zone, in UTC otherwise. Embed adds the VTIMEZONE the TZID refers to.
+func SetTime(prop *ical.Prop, t time.Time) {
+	prop.Params.Del(ical.ParamTimezoneID)
+	if TZID(t.Location()) == "" {
+		prop.SetDateTime(t.UTC())
+		return
+	}
+	if t.Location() == time.Local {
+		t = t.In(System())
+	}
+	prop.SetDateTime(t)
+}
+
+// ParseProp parses a DATE or DATE-TIME property. TZIDs are resolved with
+// Lookup; floating times and unknown TZIDs are read in floating.
+func ParseProp(prop *ical.Prop, floating *time.Location) (time.Time, error) {
+	tzid := prop.Params.Get(ical.ParamTimezoneID)
+	if tzid == "" || prop.ValueType() == ical.ValueDate || len(prop.Value) != len(localFormat) {
+		// Dates and UTC times ignore the TZID
+		return prop.DateTime(floating)
+	}
+	loc := Lookup(tzid)
+	if loc == nil {
+		loc = floating
+	}
+	return time.ParseInLocation(localFormat, prop.Value, loc)
+}
+
+// zone is a time zone learned from a VTIMEZONE.
+type zone struct {
+	loc  *time.Location
+	comp *ical.Component
+}
+
+// zones holds the VTIMEZONEs seen by Register, by TZID.
+var zones sync.Map
+
+// Register learns the VTIMEZONEs of cal whose TZID is not an IANA name,
+// so that Lookup can resolve them. It should be called on every calendar
+// read from a server or file before its times are parsed.
+func Register(cal *ical.Calendar) {
+	for _, child := range cal.Children {
+		if child.Name != ical.CompTimezone {
+			continue
+		}
+		tzid := propValue(child, ical.PropTimezoneID)
+		if tzid == "" {
+			continue
+		}
+		if _, err := time.LoadLocation(tzid); err == nil {
+			continue
+		}
+		if _, ok := zones.Load(tzid); ok {
+			continue
+		}
+		loc, err := FromVTimezone(child)
+		if err != nil {
+			continue
+		}
+		zones.Store(tzid, &zone{loc: loc, comp: child})
+	}
+}
+
+// Lookup returns the location for a TZID: an IANA name, a zone learned by
+// Register, or a vendor-prefixed IANA name like
+// /mozilla.org/20050126_1/America/New_York. It returns nil if the TZID is
+// unknown.
+func Lookup(tzid string) *time.Location {
+	if z, ok := zones.Load(tzid); ok {
+		return z.(*zone).loc
+	}
+	if loc, err := time.LoadLocation(tzid); err == nil {
+		return loc
+	}
+	parts := strings.Split(strings.Trim(tzid, "/"), "/")
+	for n := 3; n >= 2; n-- {
+		if len(parts) > n {
+			if loc, err := time.LoadLocation(strings.Join(parts[len(parts)-n:], "/")); err == nil {
+				return loc
+			}
+		}
+	}
+	return nil
+}
+
+// Embed adds a VTIMEZONE to cal for every TZID used in it that has none.
+// Zones learned by Register are copied as they were received; IANA zones
+// are generated from the time zone database.
+func Embed(cal *ical.Calendar) {
+	have := map[string]bool{}
+	for _, child := range cal.Children {
+		if child.Name == ical.CompTimezone {
+			have[propValue(child, ical.PropTimezoneID)] = true
+		}
+	}
+
+	// Earliest local time used with each missing TZID
+	needed := map[string]time.Time{}
+	var walk func(*ical.Component)
+	walk = func(comp *ical.Component) {
+		for _, props := range comp.Props {
+			for _, prop := range props {
+				tzid := prop.Params.Get(ical.ParamTimezoneID)
+				if tzid == "" || have[tzid] {
+					continue
+				}
+				for _, value := range strings.Split(prop.Value, ",") {
+					t, err := time.Parse(localFormat, value)
+					if err != nil {
+						continue
+					}
+					if first, ok := needed[tzid]; !ok || t.Before(first) {
+						needed[tzid] = t
+					}
+				}
+			}
+		}
+		for _, child := range comp.Children {
+			walk(child)
+		}
+	}
+	for _, child := range cal.Children {
+		if child.Name != ical.CompTimezone {
+			walk(child)
+		}
+	}
+
+	tzids := make([]string, 0, len(needed))
+	for tzid := range needed {
+		tzids = append(tzids, tzid)
+	}
+	sort.Strings(tzids)
+
+	var added []*ical.Component
+	for _, tzid := range tzids {
+		if z, ok := zones.Load(tzid); ok {
+			added = append(added, z.(*zone).comp)
+			continue
+		}
+		loc := Lookup(tzid)
+		if loc == nil {
+			continue
+		}
+		from := time.Date(needed[tzid].Year(), needed[tzid].Month(), needed[tzid].Day(), 0, 0, 0, 0, loc)
+		comp := VTimezone(loc, from)
+		comp.Props.SetText(ical.PropTimezoneID, tzid)
+		added = append(added, comp)
+	}
+	if len(added) > 0 {
+		cal.Children = append(added, cal.Children...)
+	}
+}
+
+func propValue(comp *ical.Component, name string) string {
+	if prop := comp.Props.Get(name); prop != nil {
+		return prop.Value
+	}
+	return ""
+}
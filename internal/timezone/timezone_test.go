@@ -0,0 +1,172 @@
+package timezone
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVTimezoneRoundTrip(t *testing.T) {
+	from := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{
+		"Europe/Berlin", "America/New_York", "Australia/Sydney", "Australia/Lord_Howe",
+		"Asia/Kathmandu", "Asia/Tokyo", "America/Sao_Paulo", "Africa/Casablanca",
+	} {
+		t.Run(name, func(t *testing.T) {
+			loc, err := time.LoadLocation(name)
+			require.NoError(t, err)
+
+			comp := VTimezone(loc, from)
+			assert.Equal(t, name, propValue(comp, ical.PropTimezoneID))
+			parsed, err := FromVTimezone(comp)
+			require.NoError(t, err)
+
+			// Same offsets as the tz database for the next couple of years
+			for ts := from; ts.Before(from.AddDate(2, 0, 0)); ts = ts.Add(5 * time.Hour) {
+				_, want := ts.In(loc).Zone()
+				_, got := ts.In(parsed).Zone()
+				require.Equal(t, want, got, "%s at %s", name, ts)
+			}
+		})
+	}
+}
+
+func TestVTimezoneRules(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	comp := VTimezone(loc, time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	require.Len(t, comp.Children, 2)
+	standard, daylight := comp.Children[0], comp.Children[1]
+	assert.Equal(t, ical.CompTimezoneStandard, standard.Name)
+	assert.Equal(t, "FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU", propValue(standard, ical.PropRecurrenceRule))
+	assert.Equal(t, "20251026T030000", propValue(standard, ical.PropDateTimeStart))
+	assert.Equal(t, "+0200", propValue(standard, ical.PropTimezoneOffsetFrom))
+	assert.Equal(t, "+0100", propValue(standard, ical.PropTimezoneOffsetTo))
+	assert.Equal(t, "CET", propValue(standard, ical.PropTimezoneName))
+	assert.Equal(t, ical.CompTimezoneDaylight, daylight.Name)
+	assert.Equal(t, "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU", propValue(daylight, ical.PropRecurrenceRule))
+}
+
+const outlookCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:Microsoft Exchange Server 2010
+BEGIN:VTIMEZONE
+TZID:W. Europe Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=10
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:16010101T020000
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=3
+END:DAYLIGHT
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:outlook@example.com
+DTSTAMP:20260101T000000Z
+DTSTART;TZID=W. Europe Standard Time:20260715T100000
+DTEND;TZID=W. Europe Standard Time:20260715T110000
+SUMMARY:Summer meeting
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestRegisterAndParse(t *testing.T) {
+	cal, err := ical.NewDecoder(strings.NewReader(strings.ReplaceAll(outlookCalendar, "\n", "\r\n"))).Decode()
+	require.NoError(t, err)
+	Register(cal)
+
+	event := cal.Children[1]
+	start, err := ParseProp(event.Props.Get(ical.PropDateTimeStart), time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 7, 15, 8, 0, 0, 0, time.UTC), start.UTC())
+	assert.Equal(t, "W. Europe Standard Time", start.Location().String())
+
+	// Winter uses standard time
+	winter := ical.NewProp(ical.PropDateTimeStart)
+	winter.Params.Set(ical.ParamTimezoneID, "W. Europe Standard Time")
+	winter.Value = "20261215T100000"
+	start, err = ParseProp(winter, time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, 9, start.UTC().Hour())
+
+	// Written back with the original TZID and VTIMEZONE
+	out := ical.NewCalendar()
+	out.Props.SetText(ical.PropVersion, "2.0")
+	out.Props.SetText(ical.PropProductID, "-//test//EN")
+	out.Children = append(out.Children, event)
+	Embed(out)
+	require.Len(t, out.Children, 2)
+	assert.Equal(t, "16010101T030000", propValue(out.Children[0].Children[0], ical.PropDateTimeStart))
+}
+
+func TestLookup(t *testing.T) {
+	assert.Equal(t, "America/New_York", Lookup("/mozilla.org/20050126_1/America/New_York").String())
+	assert.Equal(t, "Europe/Berlin", Lookup("Europe/Berlin").String())
+	assert.Nil(t, Lookup("Nowhere Standard Time"))
+
+	// Unknown zones fall back to the floating location
+	prop := ical.NewProp(ical.PropDateTimeStart)
+	prop.Params.Set(ical.ParamTimezoneID, "Nowhere Standard Time")
+	prop.Value = "20260715T100000"
+	start, err := ParseProp(prop, time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 7, 15, 10, 0, 0, 0, time.UTC), start)
+}
+
+func TestSetTimeAndEmbed(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, "tz@example.com")
+	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	start := ical.NewProp(ical.PropDateTimeStart)
+	SetTime(start, time.Date(2026, 1, 15, 10, 0, 0, 0, berlin))
+	event.Props.Set(start)
+	end := ical.NewProp(ical.PropDateTimeEnd)
+	SetTime(end, time.Date(2026, 1, 15, 11, 0, 0, 0, time.UTC))
+	event.Props.Set(end)
+
+	assert.Equal(t, "Europe/Berlin", start.Params.Get(ical.ParamTimezoneID))
+	assert.Equal(t, "20260115T100000", start.Value)
+	assert.Equal(t, "", end.Params.Get(ical.ParamTimezoneID))
+	assert.Equal(t, "20260115T110000Z", end.Value)
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//test//EN")
+	cal.Children = append(cal.Children, event.Component)
+	Embed(cal)
+	Embed(cal) // idempotent
+	require.Len(t, cal.Children, 2)
+	assert.Equal(t, ical.CompTimezone, cal.Children[0].Name)
+	assert.Equal(t, "Europe/Berlin", propValue(cal.Children[0], ical.PropTimezoneID))
+
+	var buf bytes.Buffer
+	require.NoError(t, ical.NewEncoder(&buf).Encode(cal))
+	assert.Contains(t, buf.String(), "BEGIN:VTIMEZONE")
+}
+
+func TestOffsets(t *testing.T) {
+	assert.Equal(t, "+0545", formatOffset(5*3600+45*60))
+	assert.Equal(t, "-0330", formatOffset(-(3*3600 + 30*60)))
+	assert.Equal(t, "+001730", formatOffset(17*60+30))
+	for _, s := range []string{"+0545", "-0330", "+001730"} {
+		offset, err := parseOffset(s)
+		require.NoError(t, err)
+		assert.Equal(t, s, formatOffset(offset))
+	}
+	_, err := parseOffset("0100")
+	assert.Error(t, err)
+}
@@ -0,0 +1,366 @@
+package timezone
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/teambition/rrule-go"
+)
+
+// transition is a change of UTC offset.
+type transition struct {
+	at         int64 // Unix time of the change
+	offsetFrom int   // Seconds east of UTC before
+	offsetTo   int   // Seconds east of UTC after
+	name       string
+	dst        bool
+}
+
+// wall returns the local time at which the transition happens, in the
+// offset before it, as a floating time.
+func (t transition) wall() time.Time {
+	return time.Unix(t.at+int64(t.offsetFrom), 0).UTC()
+}
+
+// VTimezone generates a VTIMEZONE describing loc from the start of from
+// onwards. Zones that follow the same daylight saving rule every year get
+// one STANDARD and one DAYLIGHT observance with a yearly RRULE; others get
+// an observance per transition for a few years.
+func VTimezone(loc *time.Location, from time.Time) *ical.Component {
+	comp := ical.NewComponent(ical.CompTimezone)
+	comp.Props.SetText(ical.PropTimezoneID, loc.String())
+
+	start := from.AddDate(-1, 0, 0)
+	ts := transitions(loc, start, from.AddDate(4, 0, 0))
+	if len(ts) == 0 {
+		name, offset := from.In(loc).Zone()
+		comp.Children = append(comp.Children, observance(transition{
+			at:         time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).Unix() - int64(offset),
+			offsetFrom: offset,
+			offsetTo:   offset,
+			name:       name,
+		}, ""))
+		return comp
+	}
+
+	if rules, ok := yearlyRules(ts); ok {
+		for _, dst := range []bool{false, true} {
+			for _, t := range ts {
+				if t.dst == dst {
+					comp.Children = append(comp.Children, observance(t, rules[dst]))
+					break
+				}
+			}
+		}
+		return comp
+	}
+
+	// Irregular zone: the offset in effect at the start, then every change
+	name, offset := start.In(loc).Zone()
+	comp.Children = append(comp.Children, observance(transition{
+		at:         start.Unix(),
+		offsetFrom: offset,
+		offsetTo:   offset,
+		name:       name,
+		dst:        start.In(loc).IsDST(),
+	}, ""))
+	for _, t := range ts {
+		comp.Children = append(comp.Children, observance(t, ""))
+	}
+	return comp
+}
+
+// transitions finds the offset changes of loc between from and to.
+func transitions(loc *time.Location, from, to time.Time) []transition {
+	zoneAt := func(unix int64) (string, int, bool) {
+		t := time.Unix(unix, 0).In(loc)
+		name, offset := t.Zone()
+		return name, offset, t.IsDST()
+	}
+
+	var ts []transition
+	const day = 24 * 60 * 60
+	prev := from.Unix()
+	_, prevOffset, prevDST := zoneAt(prev)
+	for next := prev + day; next <= to.Unix()+day; next += day {
+		name, offset, dst := zoneAt(next)
+		if offset != prevOffset || dst != prevDST {
+			// Binary search for the second of the change
+			lo, hi := prev, next
+			for hi-lo > 1 {
+				mid := lo + (hi-lo)/2
+				if _, o, d := zoneAt(mid); o == prevOffset && d == prevDST {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			ts = append(ts, transition{at: hi, offsetFrom: prevOffset, offsetTo: offset, name: name, dst: dst})
+		}
+		prev, prevOffset, prevDST = next, offset, dst
+	}
+	return ts
+}
+
+// yearlyRules returns an RRULE for standard and daylight transitions if
+// each kind happens every year on the same weekday rule, at the same local
+// time and with the same offsets.
+func yearlyRules(ts []transition) (map[bool]string, bool) {
+	rules := map[bool]string{}
+	counts := map[bool]int{}
+	for _, t := range ts {
+		rule := yearlyRule(t)
+		key := fmt.Sprintf("%s %s %d %d", rule, t.wall().Format("150405"), t.offsetFrom, t.offsetTo)
+		if counts[t.dst] > 0 && rules[t.dst] != key {
+			return nil, false
+		}
+		rules[t.dst] = key
+		counts[t.dst]++
+	}
+	// A single change of a kind may be a zone abolishing daylight saving
+	if counts[false] < 2 || counts[true] < 2 {
+		return nil, false
+	}
+	for dst, key := range rules {
+		rules[dst], _, _ = strings.Cut(key, " ")
+	}
+	return rules, true
+}
+
+// yearlyRule describes the day of a transition like "the last Sunday in
+// March".
+func yearlyRule(t transition) string {
+	w := t.wall()
+	n := (w.Day()-1)/7 + 1
+	if w.Day()+7 > daysIn(w.Year(), w.Month()) {
+		n = -1
+	}
+	weekday := strings.ToUpper(w.Weekday().String()[:2])
+	return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", int(w.Month()), n, weekday)
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// observance builds a STANDARD or DAYLIGHT component starting at t.
+func observance(t transition, rule string) *ical.Component {
+	name := ical.CompTimezoneStandard
+	if t.dst {
+		name = ical.CompTimezoneDaylight
+	}
+	comp := ical.NewComponent(name)
+
+	start := ical.NewProp(ical.PropDateTimeStart)
+	start.Value = t.wall().Format(localFormat)
+	comp.Props.Set(start)
+	from := ical.NewProp(ical.PropTimezoneOffsetFrom)
+	from.Value = formatOffset(t.offsetFrom)
+	comp.Props.Set(from)
+	to := ical.NewProp(ical.PropTimezoneOffsetTo)
+	to.Value = formatOffset(t.offsetTo)
+	comp.Props.Set(to)
+	if t.name != "" {
+		comp.Props.SetText(ical.PropTimezoneName, t.name)
+	}
+	if rule != "" {
+		prop := ical.NewProp(ical.PropRecurrenceRule)
+		prop.Value = rule
+		comp.Props.Set(prop)
+	}
+	return comp
+}
+
+func formatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	s := fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset%3600/60)
+	if offset%60 != 0 {
+		s += fmt.Sprintf("%02d", offset%60)
+	}
+	return s
+}
+
+func parseOffset(s string) (int, error) {
+	if len(s) != 5 && len(s) != 7 || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf("invalid UTC offset: %s", s)
+	}
+	offset := 0
+	for i, unit := range []int{3600, 60, 1} {
+		if 1+2*i >= len(s) {
+			break
+		}
+		n, err := strconv.Atoi(s[1+2*i : 3+2*i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid UTC offset: %s", s)
+		}
+		offset += n * unit
+	}
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// limit bounds the expansion of observance rules.
+var limit = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// FromVTimezone builds a location from a VTIMEZONE by expanding its
+// observances. The location is named after the TZID.
+func FromVTimezone(comp *ical.Component) (*time.Location, error) {
+	tzid := propValue(comp, ical.PropTimezoneID)
+	var ts []transition
+	for _, child := range comp.Children {
+		if child.Name != ical.CompTimezoneStandard && child.Name != ical.CompTimezoneDaylight {
+			continue
+		}
+		from, err := parseOffset(propValue(child, ical.PropTimezoneOffsetFrom))
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseOffset(propValue(child, ical.PropTimezoneOffsetTo))
+		if err != nil {
+			return nil, err
+		}
+		start, err := time.Parse(localFormat, propValue(child, ical.PropDateTimeStart))
+		if err != nil {
+			return nil, fmt.Errorf("invalid observance start in %s: %w", tzid, err)
+		}
+		name := propValue(child, ical.PropTimezoneName)
+		if name == "" {
+			name = strings.TrimSuffix(formatOffset(to), "00")
+		}
+
+		walls := []time.Time{start}
+		if value := propValue(child, ical.PropRecurrenceRule); value != "" {
+			opt, err := rrule.StrToROption(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid observance rule in %s: %w", tzid, err)
+			}
+			// Outlook starts rules in 1601; expanding from there runs into
+			// rrule-go's iteration limit, so skip whole intervals to 1970
+			opt.Dtstart = start
+			if interval := max(opt.Interval, 1); opt.Count == 0 && start.Year() < 1970 {
+				opt.Dtstart = start.AddDate((1970-start.Year())/interval*interval, 0, 0)
+			}
+			rule, err := rrule.NewRRule(*opt)
+			if err != nil {
+				return nil, fmt.Errorf("invalid observance rule in %s: %w", tzid, err)
+			}
+			walls = append(walls, rule.Between(opt.Dtstart, limit, true)...)
+		}
+		for _, prop := range child.Props[ical.PropRecurrenceDates] {
+			for _, value := range strings.Split(prop.Value, ",") {
+				if t, err := time.Parse(localFormat, value); err == nil {
+					walls = append(walls, t)
+				} else if t, err := time.Parse(localFormat+"Z", value); err == nil {
+					walls = append(walls, t.Add(time.Duration(from)*time.Second))
+				}
+			}
+		}
+
+		for _, w := range walls {
+			ts = append(ts, transition{
+				at:         w.Unix() - int64(from),
+				offsetFrom: from,
+				offsetTo:   to,
+				name:       name,
+				dst:        child.Name == ical.CompTimezoneDaylight,
+			})
+		}
+	}
+	if len(ts) == 0 {
+		return nil, fmt.Errorf("time zone %s has no observances", tzid)
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i].at < ts[j].at })
+
+	return time.LoadLocationFromTZData(tzid, tzif(ts))
+}
+
+// tzif encodes transitions in the TZif format (RFC 8536, version 2) that
+// time.LoadLocationFromTZData reads.
+func tzif(ts []transition) []byte {
+	type zoneType struct {
+		offset int
+		dst    bool
+		name   string
+	}
+	// Before the first transition the zone has its "from" offset
+	first := zoneType{offset: ts[0].offsetFrom, name: strings.TrimSuffix(formatOffset(ts[0].offsetFrom), "00")}
+	for _, t := range ts {
+		if t.offsetTo == first.offset && !t.dst {
+			first.name = t.name
+			break
+		}
+	}
+	types := []zoneType{first}
+	indexes := make([]byte, len(ts))
+	for i, t := range ts {
+		zt := zoneType{offset: t.offsetTo, dst: t.dst, name: t.name}
+		idx := -1
+		for j, existing := range types {
+			if existing == zt {
+				idx = j
+				break
+			}
+		}
+		if idx < 0 {
+			idx = len(types)
+			types = append(types, zt)
+		}
+		indexes[i] = byte(idx)
+	}
+
+	var chars []byte
+	abbrevs := map[string]int{}
+	for _, zt := range types {
+		if _, ok := abbrevs[zt.name]; !ok {
+			abbrevs[zt.name] = len(chars)
+			chars = append(append(chars, zt.name...), 0)
+		}
+	}
+
+	var buf bytes.Buffer
+	header := func(timecnt, typecnt, charcnt int) {
+		buf.WriteString("TZif2")
+		buf.Write(make([]byte, 15))
+		for _, n := range []int{0, 0, 0, timecnt, typecnt, charcnt} {
+			binary.Write(&buf, binary.BigEndian, uint32(n))
+		}
+	}
+	ttinfo := func(zt zoneType) {
+		binary.Write(&buf, binary.BigEndian, int32(zt.offset))
+		dst := byte(0)
+		if zt.dst {
+			dst = 1
+		}
+		buf.WriteByte(dst)
+		buf.WriteByte(byte(abbrevs[zt.name]))
+	}
+
+	// Version 1 block with just the initial type; readers of version 2
+	// skip it
+	header(0, 1, len(chars))
+	ttinfo(types[0])
+	buf.Write(chars)
+
+	header(len(ts), len(types), len(chars))
+	for _, t := range ts {
+		binary.Write(&buf, binary.BigEndian, t.at)
+	}
+	buf.Write(indexes)
+	for _, zt := range types {
+		ttinfo(zt)
+	}
+	buf.Write(chars)
+	buf.WriteString("\n\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,217 @@
+// Package webcal reads subscribed calendars: iCalendar feeds published at a
+// URL (webcal://, https://) or kept in a local file. Fetched feeds are
+// cached, and refreshed with conditional requests (ETag, Last-Modified) so
+// an unchanged feed is not downloaded again.
+package webcal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/visionik/sogcli/internal/caldav"
+)
+
+// ErrStale is wrapped by the error Load returns along with the cached copy
+// when a feed could not be refreshed.
+var ErrStale = errors.New("feed could not be refreshed, using the cached copy")
+
+// Feed is a subscribed calendar.
+type Feed struct {
+	Name   string
+	URL    string       // webcal://, http:// or https:// URL
+	File   string       // Local .ics file, used instead of URL
+	Cache  string       // Path of the cached copy of URL feeds; "" disables caching
+	Client *http.Client // Defaults to a client with a 30 second timeout
+}
+
+// cacheMeta records the validators of the cached copy.
+type cacheMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Fetched      time.Time `json:"fetched"`
+}
+
+// Load returns the feed's calendar. URL feeds are fetched unless the
+// server reports the cached copy unchanged. If fetching fails and a cached
+// copy exists, it is returned with an error wrapping ErrStale.
+func (f *Feed) Load(ctx context.Context) (*ical.Calendar, error) {
+	if f.File != "" {
+		data, err := os.ReadFile(f.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.File, err)
+		}
+		return caldav.ReadICalendar(bytes.NewReader(data))
+	}
+
+	data, err := f.fetch(ctx)
+	if err == nil {
+		return caldav.ReadICalendar(bytes.NewReader(data))
+	}
+	cached, cacheErr := f.readCache()
+	if cacheErr != nil {
+		return nil, err
+	}
+	cal, decodeErr := caldav.ReadICalendar(bytes.NewReader(cached))
+	if decodeErr != nil {
+		return nil, err
+	}
+	return cal, fmt.Errorf("%w: %v", ErrStale, err)
+}
+
+// fetch downloads the feed, or returns the cached copy if the server
+// answers 304 Not Modified.
+func (f *Feed) fetch(ctx context.Context) ([]byte, error) {
+	url := HTTPURL(f.URL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feed URL: %w", err)
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	meta := f.readMeta()
+	if meta != nil && meta.URL == url {
+		if _, err := os.Stat(f.Cache); err == nil {
+			if meta.ETag != "" {
+				req.Header.Set("If-None-Match", meta.ETag)
+			}
+			if meta.LastModified != "" {
+				req.Header.Set("If-Modified-Since", meta.LastModified)
+			}
+		}
+	}
+
+	client := f.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		data, err := f.readCache()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cached feed: %w", err)
+		}
+		return data, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch feed: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	if _, err := caldav.ReadICalendar(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if err := f.writeCache(data, &cacheMeta{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Fetched:      time.Now().UTC(),
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (f *Feed) readCache() ([]byte, error) {
+	if f.Cache == "" {
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(f.Cache)
+}
+
+func (f *Feed) readMeta() *cacheMeta {
+	if f.Cache == "" {
+		return nil
+	}
+	data, err := os.ReadFile(f.Cache + ".json")
+	if err != nil {
+		return nil
+	}
+	var meta cacheMeta
+	if json.Unmarshal(data, &meta) != nil {
+		return nil
+	}
+	return &meta
+}
+
+func (f *Feed) writeCache(data []byte, meta *cacheMeta) error {
+	if f.Cache == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Cache), 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := os.WriteFile(f.Cache, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache metadata: %w", err)
+	}
+	if err := os.WriteFile(f.Cache+".json", metaData, 0600); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
+
+// RemoveCache deletes the cached copy of the feed.
+func (f *Feed) RemoveCache() error {
+	if f.Cache == "" {
+		return nil
+	}
+	for _, path := range []string{f.Cache, f.Cache + ".json"} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove cache: %w", err)
+		}
+	}
+	return nil
+}
+
+// Events returns the occurrences of the feed's events in [start, end),
+// tagged with the feed's name. An error wrapping ErrStale comes with the
+// events of the cached copy.
+func (f *Feed) Events(ctx context.Context, start, end time.Time) ([]caldav.Event, error) {
+	cal, loadErr := f.Load(ctx)
+	if cal == nil {
+		return nil, loadErr
+	}
+	events, err := caldav.ExpandEvents(cal, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed %s: %w", f.Name, err)
+	}
+	for i := range events {
+		events[i].Source = f.Name
+	}
+	return events, loadErr
+}
+
+// HTTPURL turns a webcal:// or webcals:// URL into the https:// URL it
+// stands for. Other URLs are returned as they are.
+func HTTPURL(url string) string {
+	lower := strings.ToLower(url)
+	switch {
+	case strings.HasPrefix(lower, "webcals://"):
+		return "https://" + url[len("webcals://"):]
+	case strings.HasPrefix(lower, "webcal://"):
+		return "https://" + url[len("webcal://"):]
+	}
+	return url
+}
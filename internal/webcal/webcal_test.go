@@ -0,0 +1,91 @@
+package webcal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const holidays = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\n" +
+	"BEGIN:VEVENT\r\nUID:newyear@example.com\r\nDTSTAMP:20260101T000000Z\r\n" +
+	"DTSTART;VALUE=DATE:20270101\r\nDTEND;VALUE=DATE:20270102\r\nSUMMARY:New Year\r\n" +
+	"RRULE:FREQ=YEARLY\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func TestFeedConditionalFetch(t *testing.T) {
+	var requests, downloads int
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/calendar")
+		_, _ = w.Write([]byte(holidays))
+	}))
+	defer srv.Close()
+
+	feed := &Feed{Name: "holidays", URL: srv.URL, Cache: filepath.Join(t.TempDir(), "holidays.ics")}
+
+	start := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(2, 0, 0)
+	events, err := feed.Events(context.Background(), start, end)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "holidays", events[0].Source)
+	assert.Equal(t, "New Year", events[0].Summary)
+
+	// The second load is answered 304 and read from the cache
+	events, err = feed.Events(context.Background(), start, end)
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, downloads)
+
+	// A failing server falls back to the cache
+	fail = true
+	events, err = feed.Events(context.Background(), start, end)
+	assert.True(t, errors.Is(err, ErrStale))
+	assert.Len(t, events, 2)
+
+	require.NoError(t, feed.RemoveCache())
+	_, err = feed.Load(context.Background())
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrStale))
+}
+
+func TestFeedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holidays.ics")
+	require.NoError(t, os.WriteFile(path, []byte(holidays), 0600))
+
+	feed := &Feed{Name: "local", File: path}
+	start := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	events, err := feed.Events(context.Background(), start, start.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "local", events[0].Source)
+	assert.True(t, events[0].AllDay)
+
+	_, err = (&Feed{File: filepath.Join(t.TempDir(), "missing.ics")}).Load(context.Background())
+	assert.Error(t, err)
+}
+
+func TestHTTPURL(t *testing.T) {
+	assert.Equal(t, "https://example.com/a.ics", HTTPURL("webcal://example.com/a.ics"))
+	assert.Equal(t, "https://example.com/a.ics", HTTPURL("WEBCALS://example.com/a.ics"))
+	assert.Equal(t, "http://example.com/a.ics", HTTPURL("http://example.com/a.ics"))
+}
@@ -12,9 +12,14 @@ import (
 	"time"
 
 	"github.com/emersion/go-webdav"
+	"github.com/visionik/sogcli/internal/dav"
 )
 
 // Client wraps a WebDAV client with convenience methods.
+//
+// Uploads, deletes and moves honour preconditions set on their context with
+// dav.IfMatch or dav.IfNoneMatch, and fail with a *dav.ConflictError when
+// the server rejects them.
 type Client struct {
 	client *webdav.Client
 	url    string
@@ -40,7 +45,7 @@ type FileInfo struct {
 
 // Connect establishes a connection to a WebDAV server.
 func Connect(cfg Config) (*Client, error) {
-	httpClient := webdav.HTTPClientWithBasicAuth(http.DefaultClient, cfg.Email, cfg.Password)
+	httpClient := dav.HTTPClient(webdav.HTTPClientWithBasicAuth(http.DefaultClient, cfg.Email, cfg.Password))
 
 	client, err := webdav.NewClient(httpClient, cfg.URL)
 	if err != nil {
@@ -149,18 +154,7 @@ func (c *Client) Upload(ctx context.Context, localPath, remotePath string) error
 	}
 	defer file.Close()
 
-	writer, err := c.client.Create(ctx, remotePath)
-	if err != nil {
-		return fmt.Errorf("failed to create remote file: %w", err)
-	}
-	defer writer.Close()
-
-	_, err = io.Copy(writer, file)
-	if err != nil {
-		return fmt.Errorf("failed to upload file: %w", err)
-	}
-
-	return nil
+	return c.UploadFromReader(ctx, remotePath, file)
 }
 
 // UploadFromReader uploads from an io.Reader to a remote path.
@@ -169,9 +163,13 @@ func (c *Client) UploadFromReader(ctx context.Context, remotePath string, r io.R
 	if err != nil {
 		return fmt.Errorf("failed to create remote file: %w", err)
 	}
-	defer writer.Close()
 
+	// The server's response, including a failed precondition, arrives on
+	// Close; it explains a failed copy better than the closed pipe does
 	_, err = io.Copy(writer, r)
+	if closeErr := writer.Close(); closeErr != nil {
+		err = closeErr
+	}
 	if err != nil {
 		return fmt.Errorf("failed to upload file: %w", err)
 	}